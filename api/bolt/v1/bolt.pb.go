@@ -0,0 +1,1279 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: bolt/v1/bolt.proto
+
+// The gRPC API of bolt server, for orchestrators such as CI systems and
+// internal portals. Regenerate the Go code with `make proto`.
+
+package boltv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type RunStatus int32
+
+const (
+	RunStatus_RUN_STATUS_UNSPECIFIED RunStatus = 0
+	RunStatus_RUN_STATUS_QUEUED      RunStatus = 1
+	RunStatus_RUN_STATUS_RUNNING     RunStatus = 2
+	// Every task succeeded and none changed its host.
+	RunStatus_RUN_STATUS_OK RunStatus = 3
+	// Every task succeeded and some changed their hosts.
+	RunStatus_RUN_STATUS_CHANGED RunStatus = 4
+	// A task failed.
+	RunStatus_RUN_STATUS_FAILED RunStatus = 5
+	// The run could not start, e.g. the playbook is invalid.
+	RunStatus_RUN_STATUS_ERROR    RunStatus = 6
+	RunStatus_RUN_STATUS_CANCELED RunStatus = 7
+)
+
+// Enum value maps for RunStatus.
+var (
+	RunStatus_name = map[int32]string{
+		0: "RUN_STATUS_UNSPECIFIED",
+		1: "RUN_STATUS_QUEUED",
+		2: "RUN_STATUS_RUNNING",
+		3: "RUN_STATUS_OK",
+		4: "RUN_STATUS_CHANGED",
+		5: "RUN_STATUS_FAILED",
+		6: "RUN_STATUS_ERROR",
+		7: "RUN_STATUS_CANCELED",
+	}
+	RunStatus_value = map[string]int32{
+		"RUN_STATUS_UNSPECIFIED": 0,
+		"RUN_STATUS_QUEUED":      1,
+		"RUN_STATUS_RUNNING":     2,
+		"RUN_STATUS_OK":          3,
+		"RUN_STATUS_CHANGED":     4,
+		"RUN_STATUS_FAILED":      5,
+		"RUN_STATUS_ERROR":       6,
+		"RUN_STATUS_CANCELED":    7,
+	}
+)
+
+func (x RunStatus) Enum() *RunStatus {
+	p := new(RunStatus)
+	*p = x
+	return p
+}
+
+func (x RunStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RunStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_bolt_v1_bolt_proto_enumTypes[0].Descriptor()
+}
+
+func (RunStatus) Type() protoreflect.EnumType {
+	return &file_bolt_v1_bolt_proto_enumTypes[0]
+}
+
+func (x RunStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RunStatus.Descriptor instead.
+func (RunStatus) EnumDescriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{0}
+}
+
+type TaskStatus int32
+
+const (
+	TaskStatus_TASK_STATUS_UNSPECIFIED TaskStatus = 0
+	TaskStatus_TASK_STATUS_OK          TaskStatus = 1
+	TaskStatus_TASK_STATUS_CHANGED     TaskStatus = 2
+	TaskStatus_TASK_STATUS_FAILED      TaskStatus = 3
+	// The task could not reach its host.
+	TaskStatus_TASK_STATUS_UNREACHABLE TaskStatus = 4
+	TaskStatus_TASK_STATUS_SKIPPED     TaskStatus = 5
+)
+
+// Enum value maps for TaskStatus.
+var (
+	TaskStatus_name = map[int32]string{
+		0: "TASK_STATUS_UNSPECIFIED",
+		1: "TASK_STATUS_OK",
+		2: "TASK_STATUS_CHANGED",
+		3: "TASK_STATUS_FAILED",
+		4: "TASK_STATUS_UNREACHABLE",
+		5: "TASK_STATUS_SKIPPED",
+	}
+	TaskStatus_value = map[string]int32{
+		"TASK_STATUS_UNSPECIFIED": 0,
+		"TASK_STATUS_OK":          1,
+		"TASK_STATUS_CHANGED":     2,
+		"TASK_STATUS_FAILED":      3,
+		"TASK_STATUS_UNREACHABLE": 4,
+		"TASK_STATUS_SKIPPED":     5,
+	}
+)
+
+func (x TaskStatus) Enum() *TaskStatus {
+	p := new(TaskStatus)
+	*p = x
+	return p
+}
+
+func (x TaskStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TaskStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_bolt_v1_bolt_proto_enumTypes[1].Descriptor()
+}
+
+func (TaskStatus) Type() protoreflect.EnumType {
+	return &file_bolt_v1_bolt_proto_enumTypes[1]
+}
+
+func (x TaskStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TaskStatus.Descriptor instead.
+func (TaskStatus) EnumDescriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{1}
+}
+
+type ListPlaybooksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPlaybooksRequest) Reset() {
+	*x = ListPlaybooksRequest{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPlaybooksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPlaybooksRequest) ProtoMessage() {}
+
+func (x *ListPlaybooksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPlaybooksRequest.ProtoReflect.Descriptor instead.
+func (*ListPlaybooksRequest) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{0}
+}
+
+type ListPlaybooksResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Paths of the playbooks, relative to the playbook directory.
+	Playbooks     []string `protobuf:"bytes,1,rep,name=playbooks,proto3" json:"playbooks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPlaybooksResponse) Reset() {
+	*x = ListPlaybooksResponse{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPlaybooksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPlaybooksResponse) ProtoMessage() {}
+
+func (x *ListPlaybooksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPlaybooksResponse.ProtoReflect.Descriptor instead.
+func (*ListPlaybooksResponse) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListPlaybooksResponse) GetPlaybooks() []string {
+	if x != nil {
+		return x.Playbooks
+	}
+	return nil
+}
+
+type RunRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Path of the playbook, relative to the playbook directory.
+	Playbook string `protobuf:"bytes,1,opt,name=playbook,proto3" json:"playbook,omitempty"`
+	// Show what the run would change without changing it.
+	DryRun bool `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// Show what changed tasks change.
+	Diff bool `protobuf:"varint,3,opt,name=diff,proto3" json:"diff,omitempty"`
+	// Only run on the hosts matching these patterns.
+	Limit []string `protobuf:"bytes,4,rep,name=limit,proto3" json:"limit,omitempty"`
+	// Variables overriding those of the playbook, like -e key=value.
+	ExtraVars     map[string]string `protobuf:"bytes,5,rep,name=extra_vars,json=extraVars,proto3" json:"extra_vars,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunRequest) Reset() {
+	*x = RunRequest{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunRequest) ProtoMessage() {}
+
+func (x *RunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunRequest.ProtoReflect.Descriptor instead.
+func (*RunRequest) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RunRequest) GetPlaybook() string {
+	if x != nil {
+		return x.Playbook
+	}
+	return ""
+}
+
+func (x *RunRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *RunRequest) GetDiff() bool {
+	if x != nil {
+		return x.Diff
+	}
+	return false
+}
+
+func (x *RunRequest) GetLimit() []string {
+	if x != nil {
+		return x.Limit
+	}
+	return nil
+}
+
+func (x *RunRequest) GetExtraVars() map[string]string {
+	if x != nil {
+		return x.ExtraVars
+	}
+	return nil
+}
+
+type RunEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*RunEvent_Queued
+	//	*RunEvent_Output
+	//	*RunEvent_Task
+	//	*RunEvent_Finished
+	//	*RunEvent_Confirm
+	Event         isRunEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunEvent) Reset() {
+	*x = RunEvent{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunEvent) ProtoMessage() {}
+
+func (x *RunEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunEvent.ProtoReflect.Descriptor instead.
+func (*RunEvent) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RunEvent) GetEvent() isRunEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *RunEvent) GetQueued() *Run {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Queued); ok {
+			return x.Queued
+		}
+	}
+	return nil
+}
+
+func (x *RunEvent) GetOutput() string {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Output); ok {
+			return x.Output
+		}
+	}
+	return ""
+}
+
+func (x *RunEvent) GetTask() *TaskResult {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Task); ok {
+			return x.Task
+		}
+	}
+	return nil
+}
+
+func (x *RunEvent) GetFinished() *Run {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Finished); ok {
+			return x.Finished
+		}
+	}
+	return nil
+}
+
+func (x *RunEvent) GetConfirm() *Confirmation {
+	if x != nil {
+		if x, ok := x.Event.(*RunEvent_Confirm); ok {
+			return x.Confirm
+		}
+	}
+	return nil
+}
+
+type isRunEvent_Event interface {
+	isRunEvent_Event()
+}
+
+type RunEvent_Queued struct {
+	// The run was queued.
+	Queued *Run `protobuf:"bytes,1,opt,name=queued,proto3,oneof"`
+}
+
+type RunEvent_Output struct {
+	// Output of the run, in complete lines, as bolt run prints it.
+	Output string `protobuf:"bytes,2,opt,name=output,proto3,oneof"`
+}
+
+type RunEvent_Task struct {
+	// A task finished on a host.
+	Task *TaskResult `protobuf:"bytes,3,opt,name=task,proto3,oneof"`
+}
+
+type RunEvent_Finished struct {
+	// The run has finished.
+	Finished *Run `protobuf:"bytes,4,opt,name=finished,proto3,oneof"`
+}
+
+type RunEvent_Confirm struct {
+	// The run waits for an answer to Confirm.
+	Confirm *Confirmation `protobuf:"bytes,5,opt,name=confirm,proto3,oneof"`
+}
+
+func (*RunEvent_Queued) isRunEvent_Event() {}
+
+func (*RunEvent_Output) isRunEvent_Event() {}
+
+func (*RunEvent_Task) isRunEvent_Event() {}
+
+func (*RunEvent_Finished) isRunEvent_Event() {}
+
+func (*RunEvent_Confirm) isRunEvent_Event() {}
+
+// Run is a run of a playbook.
+type Run struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Playbook string                 `protobuf:"bytes,2,opt,name=playbook,proto3" json:"playbook,omitempty"`
+	DryRun   bool                   `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	Status   RunStatus              `protobuf:"varint,4,opt,name=status,proto3,enum=bolt.v1.RunStatus" json:"status,omitempty"`
+	Created  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created,proto3" json:"created,omitempty"`
+	Started  *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=started,proto3" json:"started,omitempty"`
+	Finished *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=finished,proto3" json:"finished,omitempty"`
+	// Outcome of a run that finished, unless it could not start.
+	Summary *Summary `protobuf:"bytes,8,opt,name=summary,proto3" json:"summary,omitempty"`
+	// Why the run could not start.
+	Error string `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	// The question the run waits for an answer to.
+	Confirmation  *Confirmation `protobuf:"bytes,10,opt,name=confirmation,proto3" json:"confirmation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Run) Reset() {
+	*x = Run{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Run) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Run) ProtoMessage() {}
+
+func (x *Run) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Run.ProtoReflect.Descriptor instead.
+func (*Run) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Run) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Run) GetPlaybook() string {
+	if x != nil {
+		return x.Playbook
+	}
+	return ""
+}
+
+func (x *Run) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *Run) GetStatus() RunStatus {
+	if x != nil {
+		return x.Status
+	}
+	return RunStatus_RUN_STATUS_UNSPECIFIED
+}
+
+func (x *Run) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *Run) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *Run) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *Run) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+func (x *Run) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Run) GetConfirmation() *Confirmation {
+	if x != nil {
+		return x.Confirmation
+	}
+	return nil
+}
+
+// Confirmation asks whether a play or task may run on a host.
+type Confirmation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Play  string                 `protobuf:"bytes,1,opt,name=play,proto3" json:"play,omitempty"`
+	Host  string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	// The task about to run, or empty to confirm the play.
+	Task          string `protobuf:"bytes,3,opt,name=task,proto3" json:"task,omitempty"`
+	Question      string `protobuf:"bytes,4,opt,name=question,proto3" json:"question,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Confirmation) Reset() {
+	*x = Confirmation{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Confirmation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Confirmation) ProtoMessage() {}
+
+func (x *Confirmation) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Confirmation.ProtoReflect.Descriptor instead.
+func (*Confirmation) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Confirmation) GetPlay() string {
+	if x != nil {
+		return x.Play
+	}
+	return ""
+}
+
+func (x *Confirmation) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Confirmation) GetTask() string {
+	if x != nil {
+		return x.Task
+	}
+	return ""
+}
+
+func (x *Confirmation) GetQuestion() string {
+	if x != nil {
+		return x.Question
+	}
+	return ""
+}
+
+// Summary is the outcome of a run, as sent to notification sinks.
+type Summary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Ok            int32                  `protobuf:"varint,2,opt,name=ok,proto3" json:"ok,omitempty"`
+	Changed       int32                  `protobuf:"varint,3,opt,name=changed,proto3" json:"changed,omitempty"`
+	Failed        int32                  `protobuf:"varint,4,opt,name=failed,proto3" json:"failed,omitempty"`
+	Skipped       int32                  `protobuf:"varint,5,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,6,opt,name=duration,proto3" json:"duration,omitempty"`
+	Failures      []*Failure             `protobuf:"bytes,7,rep,name=failures,proto3" json:"failures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Summary) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *Summary) GetOk() int32 {
+	if x != nil {
+		return x.Ok
+	}
+	return 0
+}
+
+func (x *Summary) GetChanged() int32 {
+	if x != nil {
+		return x.Changed
+	}
+	return 0
+}
+
+func (x *Summary) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *Summary) GetSkipped() int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *Summary) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *Summary) GetFailures() []*Failure {
+	if x != nil {
+		return x.Failures
+	}
+	return nil
+}
+
+// Failure is a task that failed a run.
+type Failure struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Play          string                 `protobuf:"bytes,1,opt,name=play,proto3" json:"play,omitempty"`
+	Host          string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Task          string                 `protobuf:"bytes,3,opt,name=task,proto3" json:"task,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Failure) Reset() {
+	*x = Failure{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Failure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Failure) ProtoMessage() {}
+
+func (x *Failure) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Failure.ProtoReflect.Descriptor instead.
+func (*Failure) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Failure) GetPlay() string {
+	if x != nil {
+		return x.Play
+	}
+	return ""
+}
+
+func (x *Failure) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Failure) GetTask() string {
+	if x != nil {
+		return x.Task
+	}
+	return ""
+}
+
+func (x *Failure) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// TaskResult is the result of a task on a host.
+type TaskResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Play          string                 `protobuf:"bytes,1,opt,name=play,proto3" json:"play,omitempty"`
+	Host          string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Task          string                 `protobuf:"bytes,3,opt,name=task,proto3" json:"task,omitempty"`
+	Module        string                 `protobuf:"bytes,4,opt,name=module,proto3" json:"module,omitempty"`
+	Status        TaskStatus             `protobuf:"varint,5,opt,name=status,proto3,enum=bolt.v1.TaskStatus" json:"status,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,7,opt,name=duration,proto3" json:"duration,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskResult) Reset() {
+	*x = TaskResult{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskResult) ProtoMessage() {}
+
+func (x *TaskResult) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskResult.ProtoReflect.Descriptor instead.
+func (*TaskResult) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TaskResult) GetPlay() string {
+	if x != nil {
+		return x.Play
+	}
+	return ""
+}
+
+func (x *TaskResult) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *TaskResult) GetTask() string {
+	if x != nil {
+		return x.Task
+	}
+	return ""
+}
+
+func (x *TaskResult) GetModule() string {
+	if x != nil {
+		return x.Module
+	}
+	return ""
+}
+
+func (x *TaskResult) GetStatus() TaskStatus {
+	if x != nil {
+		return x.Status
+	}
+	return TaskStatus_TASK_STATUS_UNSPECIFIED
+}
+
+func (x *TaskResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *TaskResult) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+type CancelRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the run.
+	Id            string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelRequest) Reset() {
+	*x = CancelRequest{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelRequest) ProtoMessage() {}
+
+func (x *CancelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
+func (*CancelRequest) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CancelRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CancelResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Run           *Run                   `protobuf:"bytes,1,opt,name=run,proto3" json:"run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelResponse) Reset() {
+	*x = CancelResponse{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelResponse) ProtoMessage() {}
+
+func (x *CancelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelResponse.ProtoReflect.Descriptor instead.
+func (*CancelResponse) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CancelResponse) GetRun() *Run {
+	if x != nil {
+		return x.Run
+	}
+	return nil
+}
+
+type ConfirmRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the run.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Let the play or task run; otherwise it fails.
+	Approve       bool `protobuf:"varint,2,opt,name=approve,proto3" json:"approve,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfirmRequest) Reset() {
+	*x = ConfirmRequest{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfirmRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfirmRequest) ProtoMessage() {}
+
+func (x *ConfirmRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfirmRequest.ProtoReflect.Descriptor instead.
+func (*ConfirmRequest) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ConfirmRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ConfirmRequest) GetApprove() bool {
+	if x != nil {
+		return x.Approve
+	}
+	return false
+}
+
+type ConfirmResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Run           *Run                   `protobuf:"bytes,1,opt,name=run,proto3" json:"run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfirmResponse) Reset() {
+	*x = ConfirmResponse{}
+	mi := &file_bolt_v1_bolt_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfirmResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfirmResponse) ProtoMessage() {}
+
+func (x *ConfirmResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bolt_v1_bolt_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfirmResponse.ProtoReflect.Descriptor instead.
+func (*ConfirmResponse) Descriptor() ([]byte, []int) {
+	return file_bolt_v1_bolt_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ConfirmResponse) GetRun() *Run {
+	if x != nil {
+		return x.Run
+	}
+	return nil
+}
+
+var File_bolt_v1_bolt_proto protoreflect.FileDescriptor
+
+const file_bolt_v1_bolt_proto_rawDesc = "" +
+	"\n" +
+	"\x12bolt/v1/bolt.proto\x12\abolt.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x16\n" +
+	"\x14ListPlaybooksRequest\"5\n" +
+	"\x15ListPlaybooksResponse\x12\x1c\n" +
+	"\tplaybooks\x18\x01 \x03(\tR\tplaybooks\"\xec\x01\n" +
+	"\n" +
+	"RunRequest\x12\x1a\n" +
+	"\bplaybook\x18\x01 \x01(\tR\bplaybook\x12\x17\n" +
+	"\adry_run\x18\x02 \x01(\bR\x06dryRun\x12\x12\n" +
+	"\x04diff\x18\x03 \x01(\bR\x04diff\x12\x14\n" +
+	"\x05limit\x18\x04 \x03(\tR\x05limit\x12A\n" +
+	"\n" +
+	"extra_vars\x18\x05 \x03(\v2\".bolt.v1.RunRequest.ExtraVarsEntryR\textraVars\x1a<\n" +
+	"\x0eExtraVarsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xdf\x01\n" +
+	"\bRunEvent\x12&\n" +
+	"\x06queued\x18\x01 \x01(\v2\f.bolt.v1.RunH\x00R\x06queued\x12\x18\n" +
+	"\x06output\x18\x02 \x01(\tH\x00R\x06output\x12)\n" +
+	"\x04task\x18\x03 \x01(\v2\x13.bolt.v1.TaskResultH\x00R\x04task\x12*\n" +
+	"\bfinished\x18\x04 \x01(\v2\f.bolt.v1.RunH\x00R\bfinished\x121\n" +
+	"\aconfirm\x18\x05 \x01(\v2\x15.bolt.v1.ConfirmationH\x00R\aconfirmB\a\n" +
+	"\x05event\"\x97\x03\n" +
+	"\x03Run\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bplaybook\x18\x02 \x01(\tR\bplaybook\x12\x17\n" +
+	"\adry_run\x18\x03 \x01(\bR\x06dryRun\x12*\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x12.bolt.v1.RunStatusR\x06status\x124\n" +
+	"\acreated\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x124\n" +
+	"\astarted\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x12*\n" +
+	"\asummary\x18\b \x01(\v2\x10.bolt.v1.SummaryR\asummary\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\x129\n" +
+	"\fconfirmation\x18\n" +
+	" \x01(\v2\x15.bolt.v1.ConfirmationR\fconfirmation\"f\n" +
+	"\fConfirmation\x12\x12\n" +
+	"\x04play\x18\x01 \x01(\tR\x04play\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04task\x18\x03 \x01(\tR\x04task\x12\x1a\n" +
+	"\bquestion\x18\x04 \x01(\tR\bquestion\"\xe4\x01\n" +
+	"\aSummary\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x0e\n" +
+	"\x02ok\x18\x02 \x01(\x05R\x02ok\x12\x18\n" +
+	"\achanged\x18\x03 \x01(\x05R\achanged\x12\x16\n" +
+	"\x06failed\x18\x04 \x01(\x05R\x06failed\x12\x18\n" +
+	"\askipped\x18\x05 \x01(\x05R\askipped\x125\n" +
+	"\bduration\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12,\n" +
+	"\bfailures\x18\a \x03(\v2\x10.bolt.v1.FailureR\bfailures\"[\n" +
+	"\aFailure\x12\x12\n" +
+	"\x04play\x18\x01 \x01(\tR\x04play\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04task\x18\x03 \x01(\tR\x04task\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xde\x01\n" +
+	"\n" +
+	"TaskResult\x12\x12\n" +
+	"\x04play\x18\x01 \x01(\tR\x04play\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04task\x18\x03 \x01(\tR\x04task\x12\x16\n" +
+	"\x06module\x18\x04 \x01(\tR\x06module\x12+\n" +
+	"\x06status\x18\x05 \x01(\x0e2\x13.bolt.v1.TaskStatusR\x06status\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x125\n" +
+	"\bduration\x18\a \x01(\v2\x19.google.protobuf.DurationR\bduration\"\x1f\n" +
+	"\rCancelRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"0\n" +
+	"\x0eCancelResponse\x12\x1e\n" +
+	"\x03run\x18\x01 \x01(\v2\f.bolt.v1.RunR\x03run\":\n" +
+	"\x0eConfirmRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\aapprove\x18\x02 \x01(\bR\aapprove\"1\n" +
+	"\x0fConfirmResponse\x12\x1e\n" +
+	"\x03run\x18\x01 \x01(\v2\f.bolt.v1.RunR\x03run*\xc7\x01\n" +
+	"\tRunStatus\x12\x1a\n" +
+	"\x16RUN_STATUS_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11RUN_STATUS_QUEUED\x10\x01\x12\x16\n" +
+	"\x12RUN_STATUS_RUNNING\x10\x02\x12\x11\n" +
+	"\rRUN_STATUS_OK\x10\x03\x12\x16\n" +
+	"\x12RUN_STATUS_CHANGED\x10\x04\x12\x15\n" +
+	"\x11RUN_STATUS_FAILED\x10\x05\x12\x14\n" +
+	"\x10RUN_STATUS_ERROR\x10\x06\x12\x17\n" +
+	"\x13RUN_STATUS_CANCELED\x10\a*\xa4\x01\n" +
+	"\n" +
+	"TaskStatus\x12\x1b\n" +
+	"\x17TASK_STATUS_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eTASK_STATUS_OK\x10\x01\x12\x17\n" +
+	"\x13TASK_STATUS_CHANGED\x10\x02\x12\x16\n" +
+	"\x12TASK_STATUS_FAILED\x10\x03\x12\x1b\n" +
+	"\x17TASK_STATUS_UNREACHABLE\x10\x04\x12\x17\n" +
+	"\x13TASK_STATUS_SKIPPED\x10\x052\x80\x02\n" +
+	"\x04Bolt\x12N\n" +
+	"\rListPlaybooks\x12\x1d.bolt.v1.ListPlaybooksRequest\x1a\x1e.bolt.v1.ListPlaybooksResponse\x12/\n" +
+	"\x03Run\x12\x13.bolt.v1.RunRequest\x1a\x11.bolt.v1.RunEvent0\x01\x129\n" +
+	"\x06Cancel\x12\x16.bolt.v1.CancelRequest\x1a\x17.bolt.v1.CancelResponse\x12<\n" +
+	"\aConfirm\x12\x17.bolt.v1.ConfirmRequest\x1a\x18.bolt.v1.ConfirmResponseB2Z0github.com/eugenetaranov/bolt/api/bolt/v1;boltv1b\x06proto3"
+
+var (
+	file_bolt_v1_bolt_proto_rawDescOnce sync.Once
+	file_bolt_v1_bolt_proto_rawDescData []byte
+)
+
+func file_bolt_v1_bolt_proto_rawDescGZIP() []byte {
+	file_bolt_v1_bolt_proto_rawDescOnce.Do(func() {
+		file_bolt_v1_bolt_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_bolt_v1_bolt_proto_rawDesc), len(file_bolt_v1_bolt_proto_rawDesc)))
+	})
+	return file_bolt_v1_bolt_proto_rawDescData
+}
+
+var file_bolt_v1_bolt_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_bolt_v1_bolt_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_bolt_v1_bolt_proto_goTypes = []any{
+	(RunStatus)(0),                // 0: bolt.v1.RunStatus
+	(TaskStatus)(0),               // 1: bolt.v1.TaskStatus
+	(*ListPlaybooksRequest)(nil),  // 2: bolt.v1.ListPlaybooksRequest
+	(*ListPlaybooksResponse)(nil), // 3: bolt.v1.ListPlaybooksResponse
+	(*RunRequest)(nil),            // 4: bolt.v1.RunRequest
+	(*RunEvent)(nil),              // 5: bolt.v1.RunEvent
+	(*Run)(nil),                   // 6: bolt.v1.Run
+	(*Confirmation)(nil),          // 7: bolt.v1.Confirmation
+	(*Summary)(nil),               // 8: bolt.v1.Summary
+	(*Failure)(nil),               // 9: bolt.v1.Failure
+	(*TaskResult)(nil),            // 10: bolt.v1.TaskResult
+	(*CancelRequest)(nil),         // 11: bolt.v1.CancelRequest
+	(*CancelResponse)(nil),        // 12: bolt.v1.CancelResponse
+	(*ConfirmRequest)(nil),        // 13: bolt.v1.ConfirmRequest
+	(*ConfirmResponse)(nil),       // 14: bolt.v1.ConfirmResponse
+	nil,                           // 15: bolt.v1.RunRequest.ExtraVarsEntry
+	(*timestamppb.Timestamp)(nil), // 16: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 17: google.protobuf.Duration
+}
+var file_bolt_v1_bolt_proto_depIdxs = []int32{
+	15, // 0: bolt.v1.RunRequest.extra_vars:type_name -> bolt.v1.RunRequest.ExtraVarsEntry
+	6,  // 1: bolt.v1.RunEvent.queued:type_name -> bolt.v1.Run
+	10, // 2: bolt.v1.RunEvent.task:type_name -> bolt.v1.TaskResult
+	6,  // 3: bolt.v1.RunEvent.finished:type_name -> bolt.v1.Run
+	7,  // 4: bolt.v1.RunEvent.confirm:type_name -> bolt.v1.Confirmation
+	0,  // 5: bolt.v1.Run.status:type_name -> bolt.v1.RunStatus
+	16, // 6: bolt.v1.Run.created:type_name -> google.protobuf.Timestamp
+	16, // 7: bolt.v1.Run.started:type_name -> google.protobuf.Timestamp
+	16, // 8: bolt.v1.Run.finished:type_name -> google.protobuf.Timestamp
+	8,  // 9: bolt.v1.Run.summary:type_name -> bolt.v1.Summary
+	7,  // 10: bolt.v1.Run.confirmation:type_name -> bolt.v1.Confirmation
+	17, // 11: bolt.v1.Summary.duration:type_name -> google.protobuf.Duration
+	9,  // 12: bolt.v1.Summary.failures:type_name -> bolt.v1.Failure
+	1,  // 13: bolt.v1.TaskResult.status:type_name -> bolt.v1.TaskStatus
+	17, // 14: bolt.v1.TaskResult.duration:type_name -> google.protobuf.Duration
+	6,  // 15: bolt.v1.CancelResponse.run:type_name -> bolt.v1.Run
+	6,  // 16: bolt.v1.ConfirmResponse.run:type_name -> bolt.v1.Run
+	2,  // 17: bolt.v1.Bolt.ListPlaybooks:input_type -> bolt.v1.ListPlaybooksRequest
+	4,  // 18: bolt.v1.Bolt.Run:input_type -> bolt.v1.RunRequest
+	11, // 19: bolt.v1.Bolt.Cancel:input_type -> bolt.v1.CancelRequest
+	13, // 20: bolt.v1.Bolt.Confirm:input_type -> bolt.v1.ConfirmRequest
+	3,  // 21: bolt.v1.Bolt.ListPlaybooks:output_type -> bolt.v1.ListPlaybooksResponse
+	5,  // 22: bolt.v1.Bolt.Run:output_type -> bolt.v1.RunEvent
+	12, // 23: bolt.v1.Bolt.Cancel:output_type -> bolt.v1.CancelResponse
+	14, // 24: bolt.v1.Bolt.Confirm:output_type -> bolt.v1.ConfirmResponse
+	21, // [21:25] is the sub-list for method output_type
+	17, // [17:21] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_bolt_v1_bolt_proto_init() }
+func file_bolt_v1_bolt_proto_init() {
+	if File_bolt_v1_bolt_proto != nil {
+		return
+	}
+	file_bolt_v1_bolt_proto_msgTypes[3].OneofWrappers = []any{
+		(*RunEvent_Queued)(nil),
+		(*RunEvent_Output)(nil),
+		(*RunEvent_Task)(nil),
+		(*RunEvent_Finished)(nil),
+		(*RunEvent_Confirm)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bolt_v1_bolt_proto_rawDesc), len(file_bolt_v1_bolt_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_bolt_v1_bolt_proto_goTypes,
+		DependencyIndexes: file_bolt_v1_bolt_proto_depIdxs,
+		EnumInfos:         file_bolt_v1_bolt_proto_enumTypes,
+		MessageInfos:      file_bolt_v1_bolt_proto_msgTypes,
+	}.Build()
+	File_bolt_v1_bolt_proto = out.File
+	file_bolt_v1_bolt_proto_goTypes = nil
+	file_bolt_v1_bolt_proto_depIdxs = nil
+}
@@ -0,0 +1,264 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: bolt/v1/bolt.proto
+
+// The gRPC API of bolt server, for orchestrators such as CI systems and
+// internal portals. Regenerate the Go code with `make proto`.
+
+package boltv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Bolt_ListPlaybooks_FullMethodName = "/bolt.v1.Bolt/ListPlaybooks"
+	Bolt_Run_FullMethodName           = "/bolt.v1.Bolt/Run"
+	Bolt_Cancel_FullMethodName        = "/bolt.v1.Bolt/Cancel"
+	Bolt_Confirm_FullMethodName       = "/bolt.v1.Bolt/Confirm"
+)
+
+// BoltClient is the client API for Bolt service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Bolt runs the playbooks of the directory bolt server serves. Runs share
+// the queue of the REST API: they run one at a time, in the order they
+// were requested, and show up in its history.
+type BoltClient interface {
+	// ListPlaybooks lists the playbooks that may be run.
+	ListPlaybooks(ctx context.Context, in *ListPlaybooksRequest, opts ...grpc.CallOption) (*ListPlaybooksResponse, error)
+	// Run queues a run of a playbook and streams its events until it has
+	// finished. The first event is queued, with the ID of the run; the last
+	// is finished. Canceling the call cancels the run.
+	Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunEvent], error)
+	// Cancel cancels a queued or running run.
+	Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error)
+	// Confirm answers the confirmation a run waits for, before a play or
+	// task with confirm runs on a host.
+	Confirm(ctx context.Context, in *ConfirmRequest, opts ...grpc.CallOption) (*ConfirmResponse, error)
+}
+
+type boltClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBoltClient(cc grpc.ClientConnInterface) BoltClient {
+	return &boltClient{cc}
+}
+
+func (c *boltClient) ListPlaybooks(ctx context.Context, in *ListPlaybooksRequest, opts ...grpc.CallOption) (*ListPlaybooksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPlaybooksResponse)
+	err := c.cc.Invoke(ctx, Bolt_ListPlaybooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *boltClient) Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Bolt_ServiceDesc.Streams[0], Bolt_Run_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunRequest, RunEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bolt_RunClient = grpc.ServerStreamingClient[RunEvent]
+
+func (c *boltClient) Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelResponse)
+	err := c.cc.Invoke(ctx, Bolt_Cancel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *boltClient) Confirm(ctx context.Context, in *ConfirmRequest, opts ...grpc.CallOption) (*ConfirmResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfirmResponse)
+	err := c.cc.Invoke(ctx, Bolt_Confirm_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BoltServer is the server API for Bolt service.
+// All implementations must embed UnimplementedBoltServer
+// for forward compatibility.
+//
+// Bolt runs the playbooks of the directory bolt server serves. Runs share
+// the queue of the REST API: they run one at a time, in the order they
+// were requested, and show up in its history.
+type BoltServer interface {
+	// ListPlaybooks lists the playbooks that may be run.
+	ListPlaybooks(context.Context, *ListPlaybooksRequest) (*ListPlaybooksResponse, error)
+	// Run queues a run of a playbook and streams its events until it has
+	// finished. The first event is queued, with the ID of the run; the last
+	// is finished. Canceling the call cancels the run.
+	Run(*RunRequest, grpc.ServerStreamingServer[RunEvent]) error
+	// Cancel cancels a queued or running run.
+	Cancel(context.Context, *CancelRequest) (*CancelResponse, error)
+	// Confirm answers the confirmation a run waits for, before a play or
+	// task with confirm runs on a host.
+	Confirm(context.Context, *ConfirmRequest) (*ConfirmResponse, error)
+	mustEmbedUnimplementedBoltServer()
+}
+
+// UnimplementedBoltServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBoltServer struct{}
+
+func (UnimplementedBoltServer) ListPlaybooks(context.Context, *ListPlaybooksRequest) (*ListPlaybooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPlaybooks not implemented")
+}
+func (UnimplementedBoltServer) Run(*RunRequest, grpc.ServerStreamingServer[RunEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (UnimplementedBoltServer) Cancel(context.Context, *CancelRequest) (*CancelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedBoltServer) Confirm(context.Context, *ConfirmRequest) (*ConfirmResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Confirm not implemented")
+}
+func (UnimplementedBoltServer) mustEmbedUnimplementedBoltServer() {}
+func (UnimplementedBoltServer) testEmbeddedByValue()              {}
+
+// UnsafeBoltServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BoltServer will
+// result in compilation errors.
+type UnsafeBoltServer interface {
+	mustEmbedUnimplementedBoltServer()
+}
+
+func RegisterBoltServer(s grpc.ServiceRegistrar, srv BoltServer) {
+	// If the following call pancis, it indicates UnimplementedBoltServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Bolt_ServiceDesc, srv)
+}
+
+func _Bolt_ListPlaybooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPlaybooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoltServer).ListPlaybooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bolt_ListPlaybooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoltServer).ListPlaybooks(ctx, req.(*ListPlaybooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bolt_Run_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BoltServer).Run(m, &grpc.GenericServerStream[RunRequest, RunEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bolt_RunServer = grpc.ServerStreamingServer[RunEvent]
+
+func _Bolt_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoltServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bolt_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoltServer).Cancel(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bolt_Confirm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoltServer).Confirm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bolt_Confirm_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoltServer).Confirm(ctx, req.(*ConfirmRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Bolt_ServiceDesc is the grpc.ServiceDesc for Bolt service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Bolt_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bolt.v1.Bolt",
+	HandlerType: (*BoltServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPlaybooks",
+			Handler:    _Bolt_ListPlaybooks_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Bolt_Cancel_Handler,
+		},
+		{
+			MethodName: "Confirm",
+			Handler:    _Bolt_Confirm_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Run",
+			Handler:       _Bolt_Run_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bolt/v1/bolt.proto",
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eugenetaranov/bolt/internal/agent"
+	"github.com/eugenetaranov/bolt/internal/executor"
+	"github.com/eugenetaranov/bolt/internal/gitrepo"
+	"github.com/eugenetaranov/bolt/internal/output"
+)
+
+// agentCmd runs a playbook on a schedule
+var agentCmd = &cobra.Command{
+	Use:   "agent <playbook.yaml>",
+	Short: "Run a playbook on a schedule",
+	Long: `Run a playbook repeatedly, on an interval or a cron schedule, until
+interrupted. Each run corrects any drift of the hosts; with --detect-drift
+runs only report it.
+
+With --interval the first run starts right away; with --schedule it waits
+for the first scheduled time. Runs hold a lock file, so a run is skipped
+rather than overlapping with another agent on the same playbook.
+
+Examples:
+  bolt agent site.yaml --interval 30m
+  bolt agent site.yaml --schedule "*/15 * * * *" --pull
+  bolt agent site.yaml --detect-drift --metrics-addr :9470`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAgent,
+}
+
+func init() {
+	agentCmd.Flags().Duration("interval", 30*time.Minute, "Time between the end of a run and the start of the next")
+	agentCmd.Flags().String("schedule", "", "Cron expression for the start of runs (e.g. \"0 * * * *\" or @hourly), in local time")
+	agentCmd.MarkFlagsMutuallyExclusive("interval", "schedule")
+	agentCmd.Flags().Bool("pull", false, "Update the playbook's git checkout (fast-forward only) before each run")
+	agentCmd.Flags().String("lock-file", "", "Lock file preventing overlapping runs (default: .bolt/agent.lock next to the playbook)")
+	agentCmd.Flags().String("metrics-addr", "", "Serve Prometheus metrics on this address (e.g. :9470)")
+	agentCmd.Flags().String("metrics-file", "", "Write Prometheus metrics to this file after each run (for the node exporter textfile collector)")
+	agentCmd.Flags().Bool("detect-drift", false, "Only check for drift (dry run) instead of correcting it")
+	addPlaybookFlags(agentCmd)
+}
+
+func runAgent(cmd *cobra.Command, args []string) error {
+	playbookPath := args[0]
+
+	var schedule agent.Schedule
+	immediate := true
+	if expr, _ := cmd.Flags().GetString("schedule"); expr != "" {
+		s, err := agent.ParseCron(expr)
+		if err != nil {
+			return err
+		}
+		schedule, immediate = s, false
+	} else {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		schedule = agent.Every(interval)
+	}
+
+	useColor, unicode, err := terminalStyle()
+	if err != nil {
+		return err
+	}
+	out := output.New(os.Stdout)
+	out.SetColor(useColor)
+	out.SetUnicode(unicode)
+
+	lockFile, _ := cmd.Flags().GetString("lock-file")
+	if lockFile == "" {
+		lockFile = filepath.Join(filepath.Dir(playbookPath), ".bolt", "agent.lock")
+	}
+	metricsFile, _ := cmd.Flags().GetString("metrics-file")
+
+	a := &agent.Agent{
+		Schedule:  schedule,
+		Immediate: immediate,
+		Run: func(ctx context.Context) (*executor.RunResult, error) {
+			// Parse the playbook anew: it may have changed since the last run
+			run, err := newPlaybookRun(cmd, playbookPath)
+			if err != nil {
+				return nil, err
+			}
+			return run.Run(ctx)
+		},
+		LockFile:    lockFile,
+		Metrics:     agent.NewMetrics(),
+		MetricsFile: metricsFile,
+		Output:      out,
+	}
+	if pull, _ := cmd.Flags().GetBool("pull"); pull {
+		dir := filepath.Dir(playbookPath)
+		a.Pull = func(ctx context.Context) error {
+			if err := gitrepo.Pull(ctx, dir); err != nil {
+				return err
+			}
+			if head, err := gitrepo.Head(ctx, dir); err == nil {
+				out.Info("Playbook at commit %.12s", head)
+			}
+			return nil
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if addr, _ := cmd.Flags().GetString("metrics-addr"); addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", a.Metrics)
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				out.Warn("Metrics server stopped: %v", err)
+			}
+		}()
+		defer srv.Close()
+		out.Info("Serving metrics on http://%s/metrics", ln.Addr())
+	}
+
+	return a.Start(ctx)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/eugenetaranov/bolt/internal/inventory"
+)
+
+// completionCmd generates shell completion scripts
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish",
+	Short: "Generate shell completion scripts",
+	Long: `Generate a completion script for your shell.
+
+Besides commands and flags, bolt completes playbook files, --tags with the
+tags of the playbook on the command line, and --limit with the hosts and
+groups of the inventory given with -i.
+
+Bash (requires bash-completion):
+  source <(bolt completion bash)
+  # or permanently:
+  bolt completion bash > /etc/bash_completion.d/bolt
+
+Zsh:
+  bolt completion zsh > "${fpath[1]}/_bolt"
+
+Fish:
+  bolt completion fish > ~/.config/fish/completions/bolt.fish`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish"},
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		}
+		return cmd.Help()
+	},
+}
+
+// registerCompletions adds dynamic completions to the commands. It runs
+// from main, once every command has defined its flags.
+func registerCompletions() {
+	rootCmd.AddCommand(completionCmd)
+
+	for _, cmd := range []*cobra.Command{runCmd, agentCmd, validateCmd, driftCmd, lockCmd, verifyCmd} {
+		cmd.ValidArgsFunction = completePlaybooks
+	}
+	recordCmd.ValidArgsFunction = completeCassette
+	replayCmd.ValidArgsFunction = completeCassette
+	for _, cmd := range []*cobra.Command{runCmd, agentCmd, pullCmd, driftCmd, inventoryCmd, recordCmd, replayCmd, serverCmd} {
+		_ = cmd.RegisterFlagCompletionFunc("inventory", completePlaybooks)
+	}
+	for _, cmd := range []*cobra.Command{runCmd, agentCmd, driftCmd, recordCmd, replayCmd, serverCmd} {
+		_ = cmd.RegisterFlagCompletionFunc("limit", completeHosts)
+	}
+	_ = runCmd.RegisterFlagCompletionFunc("tags", completeTags)
+	_ = runCmd.RegisterFlagCompletionFunc("skip-tags", completeTags)
+	inventoryCmd.ValidArgsFunction = completeGroups
+}
+
+// completeCassette completes the playbook and then the cassette of the
+// record and replay commands.
+func completeCassette(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completePlaybooks(cmd, args, toComplete)
+	case 1:
+		return []string{"json"}, cobra.ShellCompDirectiveFilterFileExt
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePlaybooks completes YAML files.
+func completePlaybooks(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if cmd.Name() != "validate" && len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return []string{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeHosts completes the host and group names of the inventory given
+// with -i.
+func completeHosts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	inv := completionInventory(cmd)
+	if inv == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, g := range inv.Groups() {
+		names = append(names, g.Name)
+	}
+	for _, h := range inv.Hosts() {
+		names = append(names, h.Name)
+	}
+	return completeList(names, toComplete)
+}
+
+// completeGroups completes the group names of the inventory given with -i.
+func completeGroups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	inv := completionInventory(cmd)
+	if inv == nil || len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, g := range inv.Groups() {
+		names = append(names, g.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionInventory loads the inventory given with -i, or returns nil.
+// Errors are ignored; completion simply offers nothing.
+func completionInventory(cmd *cobra.Command) *inventory.Inventory {
+	path, _ := cmd.Flags().GetString("inventory")
+	if path == "" {
+		return nil
+	}
+	inv, err := inventory.Load(path)
+	if err != nil {
+		return nil
+	}
+	return inv
+}
+
+// completeTags completes the tags used in the playbook given as argument.
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	tags := make(map[string]bool)
+	collectTags(doc, tags)
+
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	return completeList(names, toComplete)
+}
+
+// completeList completes the last item of a comma-separated list.
+func completeList(names []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix := toComplete[:i+1]
+		for j, name := range names {
+			names[j] = prefix + name
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// collectTags adds the values of every tags key in a parsed YAML document
+// to tags. It reads the raw document, so it finds tags on plays, tasks,
+// and nested blocks alike.
+func collectTags(node any, tags map[string]bool) {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if key == "tags" {
+				switch t := value.(type) {
+				case string:
+					for _, tag := range strings.Split(t, ",") {
+						if tag = strings.TrimSpace(tag); tag != "" {
+							tags[tag] = true
+						}
+					}
+				case []any:
+					for _, tag := range t {
+						if s, ok := tag.(string); ok {
+							tags[s] = true
+						}
+					}
+				}
+				continue
+			}
+			collectTags(value, tags)
+		}
+	case []any:
+		for _, item := range v {
+			collectTags(item, tags)
+		}
+	}
+}
@@ -2,25 +2,77 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
 
 	// Import modules to register them
+	_ "github.com/eugenetaranov/bolt/internal/module/alternatives"
 	_ "github.com/eugenetaranov/bolt/internal/module/apt"
+	_ "github.com/eugenetaranov/bolt/internal/module/archive"
+	_ "github.com/eugenetaranov/bolt/internal/module/assert"
 	_ "github.com/eugenetaranov/bolt/internal/module/brew"
+	_ "github.com/eugenetaranov/bolt/internal/module/capabilities"
+	_ "github.com/eugenetaranov/bolt/internal/module/certificate"
 	_ "github.com/eugenetaranov/bolt/internal/module/command"
 	_ "github.com/eugenetaranov/bolt/internal/module/copy"
+	_ "github.com/eugenetaranov/bolt/internal/module/dnf"
+	_ "github.com/eugenetaranov/bolt/internal/module/dock"
+	_ "github.com/eugenetaranov/bolt/internal/module/dotfiles"
 	_ "github.com/eugenetaranov/bolt/internal/module/file"
+	_ "github.com/eugenetaranov/bolt/internal/module/filesystem"
+	_ "github.com/eugenetaranov/bolt/internal/module/fonts"
+	_ "github.com/eugenetaranov/bolt/internal/module/golang"
+	_ "github.com/eugenetaranov/bolt/internal/module/group"
+	_ "github.com/eugenetaranov/bolt/internal/module/homebrewinstall"
+	_ "github.com/eugenetaranov/bolt/internal/module/journald"
+	_ "github.com/eugenetaranov/bolt/internal/module/knownhosts"
+	_ "github.com/eugenetaranov/bolt/internal/module/lineinfile"
+	_ "github.com/eugenetaranov/bolt/internal/module/listenportsfacts"
+	_ "github.com/eugenetaranov/bolt/internal/module/loginitem"
+	_ "github.com/eugenetaranov/bolt/internal/module/logrotate"
+	_ "github.com/eugenetaranov/bolt/internal/module/lvg"
+	_ "github.com/eugenetaranov/bolt/internal/module/lvol"
+	_ "github.com/eugenetaranov/bolt/internal/module/macosfirewall"
+	_ "github.com/eugenetaranov/bolt/internal/module/macospower"
+	_ "github.com/eugenetaranov/bolt/internal/module/mysqldb"
+	_ "github.com/eugenetaranov/bolt/internal/module/mysqluser"
+	_ "github.com/eugenetaranov/bolt/internal/module/nodejs"
+	_ "github.com/eugenetaranov/bolt/internal/module/packagefacts"
+	_ "github.com/eugenetaranov/bolt/internal/module/pamlimits"
+	_ "github.com/eugenetaranov/bolt/internal/module/pkgng"
+	_ "github.com/eugenetaranov/bolt/internal/module/replace"
+	_ "github.com/eugenetaranov/bolt/internal/module/rustup"
+	_ "github.com/eugenetaranov/bolt/internal/module/slurp"
+	_ "github.com/eugenetaranov/bolt/internal/module/sshconfig"
+	_ "github.com/eugenetaranov/bolt/internal/module/sshdconfig"
+	_ "github.com/eugenetaranov/bolt/internal/module/tailscale"
 	_ "github.com/eugenetaranov/bolt/internal/module/template"
+	_ "github.com/eugenetaranov/bolt/internal/module/timesync"
+	_ "github.com/eugenetaranov/bolt/internal/module/user"
+	_ "github.com/eugenetaranov/bolt/internal/module/vscodeextension"
+	_ "github.com/eugenetaranov/bolt/internal/module/wireguard"
 
+	"github.com/eugenetaranov/bolt/internal/ansible"
+	"github.com/eugenetaranov/bolt/internal/config"
 	"github.com/eugenetaranov/bolt/internal/executor"
+	"github.com/eugenetaranov/bolt/internal/integrity"
+	"github.com/eugenetaranov/bolt/internal/inventory"
 	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/notify"
+	"github.com/eugenetaranov/bolt/internal/output"
 	"github.com/eugenetaranov/bolt/internal/playbook"
+	"github.com/eugenetaranov/bolt/internal/scenario"
+	"github.com/eugenetaranov/bolt/internal/state"
 )
 
 var (
@@ -31,12 +83,18 @@ var (
 
 // Global flags
 var (
-	debug   bool
-	dryRun  bool
-	noColor bool
+	debug            bool
+	verbose          bool
+	quiet            bool
+	changedOnly      bool
+	dryRun           bool
+	noColor          bool
+	colorFlag        string
+	suppressWarnings bool
 )
 
 func main() {
+	registerCompletions()
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -56,13 +114,33 @@ Supports local execution, SSH, and AWS SSM connectors.`,
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug output with detailed task information")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show the module, host, and duration of every task")
 	rootCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what would be done without making changes")
-	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output (same as --color=never)")
+	rootCmd.PersistentFlags().StringVar(&colorFlag, "color", "auto", "Use colors and Unicode glyphs: auto, always, or never (env: NO_COLOR)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print failed tasks and the recap")
+	rootCmd.PersistentFlags().BoolVar(&changedOnly, "changed-only", false, "Only print changed and failed tasks; print nothing if nothing changed")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "changed-only")
+	rootCmd.PersistentFlags().BoolVar(&suppressWarnings, "suppress-warnings", envBool("BOLT_SUPPRESS_WARNINGS"), "Do not print warnings about deprecated or insecure playbook content (env: BOLT_SUPPRESS_WARNINGS)")
 
 	// Add subcommands
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(agentCmd)
+	rootCmd.AddCommand(pullCmd)
+	rootCmd.AddCommand(recordCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(modulesCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(driftCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(inventoryCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
 }
 
 // runCmd executes a playbook
@@ -74,40 +152,58 @@ var runCmd = &cobra.Command{
 Examples:
   bolt run setup.yaml
   bolt run setup.yaml --debug
-  bolt run setup.yaml --dry-run`,
+  bolt run setup.yaml --dry-run
+  bolt run setup.yaml --dry-run --diff
+  bolt run setup.yaml --detect-drift
+  bolt run setup.yaml --idempotency-check
+  bolt run site.yaml -i hosts.yaml --limit webservers
+  bolt run site.yaml --tags "setup or upgrade" --skip-tags slow`,
 	Args: cobra.ExactArgs(1),
 	RunE: runPlaybook,
 }
 
 func init() {
 	// Run-specific flags can be added here
-	runCmd.Flags().StringP("inventory", "i", "", "Inventory file (not yet implemented)")
-	runCmd.Flags().StringSliceP("extra-vars", "e", nil, "Extra variables (key=value)")
-	runCmd.Flags().StringSlice("tags", nil, "Only run tasks with these tags")
-	runCmd.Flags().StringSlice("skip-tags", nil, "Skip tasks with these tags")
+	addPlaybookFlags(runCmd)
+	runCmd.Flags().StringSlice("tags", nil, "Only run tasks whose tags match these expressions (e.g. \"web and not slow\")")
+	runCmd.Flags().StringSlice("skip-tags", nil, "Skip tasks whose tags match these expressions")
 	runCmd.Flags().IntP("forks", "f", 1, "Number of parallel processes (not yet implemented)")
+	runCmd.Flags().Bool("detect-drift", false, "Dry run that exits with code 2 if any task would change")
+	runCmd.Flags().Bool("diff", false, "Show what changed tasks change, including the content of files (with --dry-run: what they would change)")
+	runCmd.Flags().Bool("idempotency-check", false, "Run the playbook twice and exit with code 2 if the second run changes any task")
+	runCmd.Flags().Bool("progress", false, "Show a live status line per host instead of every task (plain lines when not a terminal)")
+}
+
+// addPlaybookFlags adds the flags read by newPlaybookRun to cmd.
+func addPlaybookFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("inventory", "i", "", "Inventory file resolving the hosts of plays")
+	cmd.Flags().StringSliceP("limit", "l", nil, "Only run on hosts matching these patterns")
+	cmd.Flags().StringSliceP("extra-vars", "e", nil, "Extra variables (key=value)")
+	cmd.Flags().String("state-file", "", "State file recording applied resources (default: .bolt/state.json next to the playbook)")
+	cmd.Flags().Bool("no-state", false, "Do not record applied resources")
+	cmd.Flags().Bool("skip-verify", false, "Run even if the playbook does not match its bolt.lock")
+	cmd.Flags().Bool("force-handlers", false, "Run notified handlers even if a task fails")
+	cmd.Flags().Bool("strict-vars", envBool("BOLT_STRICT_VARS"), "Fail tasks that reference undefined variables (env: BOLT_STRICT_VARS)")
+	cmd.Flags().String("config", os.Getenv("BOLT_CONFIG"), "Configuration file (default: bolt.yaml next to the playbook or in the current directory) (env: BOLT_CONFIG)")
+	cmd.Flags().Bool("no-lock", false, "Do not lock hosts against concurrent runs")
+	cmd.Flags().Bool("offline", envBool("BOLT_OFFLINE"), "Keep modules from reaching the internet, for air-gapped hosts (env: BOLT_OFFLINE)")
+	cmd.Flags().Bool("no-notify", false, "Do not send the run summary to the notification sinks of the configuration")
+	cmd.Flags().BoolP("yes", "y", false, "Approve every play and task that asks for confirmation without asking")
 }
 
 func runPlaybook(cmd *cobra.Command, args []string) error {
 	playbookPath := args[0]
-
-	// Check if file exists
-	if _, err := os.Stat(playbookPath); os.IsNotExist(err) {
-		return fmt.Errorf("playbook not found: %s", playbookPath)
+	detectDrift, _ := cmd.Flags().GetBool("detect-drift")
+	idempotencyCheck, _ := cmd.Flags().GetBool("idempotency-check")
+	if idempotencyCheck && (dryRun || detectDrift) {
+		return fmt.Errorf("--idempotency-check cannot be combined with --dry-run or --detect-drift")
 	}
 
-	// Parse playbook
-	pb, err := playbook.ParseFileRaw(playbookPath)
+	run, err := newPlaybookRun(cmd, playbookPath)
 	if err != nil {
-		return fmt.Errorf("failed to parse playbook: %w", err)
+		return err
 	}
-
-	// Create executor
-	exec := executor.New()
-	exec.Debug = debug
-	exec.DryRun = dryRun
-	exec.Output.SetColor(!noColor)
-	exec.Output.SetDebug(debug)
+	exec := run.exec
 
 	// Setup context with signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -118,23 +214,397 @@ func runPlaybook(cmd *cobra.Command, args []string) error {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigCh
-		fmt.Fprintln(os.Stderr, "\nInterrupted, cleaning up...")
+		fmt.Fprintln(os.Stderr, "\nInterrupted, cleaning up... (interrupt again to exit immediately)")
 		cancel()
+		<-sigCh
+		os.Exit(130)
 	}()
 
 	// Run playbook
-	result, err := exec.Run(ctx, pb)
+	result, err := run.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !result.Success {
+		os.Exit(1)
+	}
+
+	if detectDrift {
+		if n := result.Stats.Unchecked; n > 0 {
+			exec.Output.Warn("%d task(s) not checked: their modules do not support check mode", n)
+		}
+		if n := result.Stats.Changed; n > 0 {
+			exec.Output.Warn("Drift detected: %d task(s) would change", n)
+			os.Exit(2)
+		}
+	}
+
+	if idempotencyCheck {
+		if err := checkIdempotency(ctx, cmd, playbookPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkIdempotency runs the playbook a second time and exits with code 2
+// if any task changed its host again, listing those tasks.
+func checkIdempotency(ctx context.Context, cmd *cobra.Command, playbookPath string) error {
+	run, err := newPlaybookRun(cmd, playbookPath)
 	if err != nil {
 		return err
 	}
+	// The first run already sent the summary
+	run.notifier = nil
 
+	run.exec.Output.Section("IDEMPOTENCY CHECK")
+	result, err := run.Run(ctx)
+	if err != nil {
+		return err
+	}
 	if !result.Success {
 		os.Exit(1)
 	}
 
+	if len(result.Changes) > 0 {
+		for _, c := range result.Changes {
+			run.exec.Output.Error("Not idempotent: %s on %s (play: %s)", c.Task, c.Host, c.Play)
+		}
+		run.exec.Output.Warn("%d task(s) changed on the second run", len(result.Changes))
+		os.Exit(2)
+	}
 	return nil
 }
 
+// playbookRun is a run of a playbook set up from the flags of the run
+// and agent commands.
+type playbookRun struct {
+	path     string
+	pb       *playbook.Playbook
+	exec     *executor.Executor
+	notifier *notify.Notifier
+}
+
+// newPlaybookRun parses the playbook and sets up its executor from the
+// flags of cmd.
+func newPlaybookRun(cmd *cobra.Command, playbookPath string) (*playbookRun, error) {
+	// Check if file exists
+	if _, err := os.Stat(playbookPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("playbook not found: %s", playbookPath)
+	}
+
+	// Parse playbook
+	pb, err := playbook.ParseFileRaw(playbookPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse playbook: %w", err)
+	}
+
+	// Refuse to run content that drifted from the lock file
+	if skip, _ := cmd.Flags().GetBool("skip-verify"); !skip {
+		if err := verifyLock(playbookPath, false); err != nil {
+			return nil, err
+		}
+	}
+
+	detectDrift, _ := cmd.Flags().GetBool("detect-drift")
+	stateFile, _ := cmd.Flags().GetString("state-file")
+	noState, _ := cmd.Flags().GetBool("no-state")
+	strictVars, _ := cmd.Flags().GetBool("strict-vars")
+	forceHandlers, _ := cmd.Flags().GetBool("force-handlers")
+	progress, _ := cmd.Flags().GetBool("progress")
+	noLock, _ := cmd.Flags().GetBool("no-lock")
+	offline, _ := cmd.Flags().GetBool("offline")
+	diff, _ := cmd.Flags().GetBool("diff")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	extraVars, _ := cmd.Flags().GetStringSlice("extra-vars")
+	vars, err := parseExtraVars(extraVars)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := tagExpression(cmd, "tags")
+	if err != nil {
+		return nil, err
+	}
+	skipTags, err := tagExpression(cmd, "skip-tags")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadConfig(cmd, playbookPath)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := loadNotifier(cmd, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create executor
+	exec := executor.New()
+	exec.Debug = debug
+	exec.DryRun = dryRun || detectDrift
+	exec.ExtraVars = vars
+	exec.ModuleDefaults = cfg.ModuleDefaults
+	exec.Policy = cfg.Policy
+	exec.Tags = tags
+	exec.SkipTags = skipTags
+	exec.StrictVars = strictVars
+	exec.Offline = offline
+	exec.Diff = diff
+	exec.ForceHandlers = forceHandlers
+	exec.SuppressWarnings = suppressWarnings
+	exec.AssumeYes = yes
+	// Dry runs change nothing, so they need not wait for other runs
+	exec.Lock = !noLock && !exec.DryRun
+	if err := setInventory(cmd, exec); err != nil {
+		return nil, err
+	}
+
+	useColor, unicode, err := terminalStyle()
+	if err != nil {
+		return nil, err
+	}
+
+	mode := outputMode()
+	if progress {
+		// Task lines go above the host lines; only failures by default
+		exec.Progress = output.NewProgress(os.Stdout, output.IsTerminal(os.Stdout))
+		exec.Progress.SetColor(useColor)
+		exec.Progress.SetUnicode(unicode)
+		exec.Output = output.New(exec.Progress)
+		if mode == output.ModeNormal {
+			mode = output.ModeQuiet
+		}
+	}
+
+	exec.Output.SetColor(useColor)
+	exec.Output.SetUnicode(unicode)
+	exec.Output.SetVerbose(verbose)
+	exec.Output.SetMode(mode)
+	exec.Output.SetDebug(debug)
+	exec.Confirm = confirmAtTerminal(exec.Output)
+
+	// Load the state so this run's resources are merged into it
+	if !noState && !exec.DryRun {
+		if stateFile == "" {
+			stateFile = state.DefaultPath(playbookPath)
+		}
+		st, err := state.Load(stateFile)
+		if err != nil {
+			return nil, err
+		}
+		exec.State = st
+	}
+
+	return &playbookRun{path: playbookPath, pb: pb, exec: exec, notifier: notifier}, nil
+}
+
+// Run runs the playbook, saves the state, and sends the run summary to
+// the notification sinks.
+func (r *playbookRun) Run(ctx context.Context) (*executor.RunResult, error) {
+	result, err := r.exec.Run(ctx, r.pb)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save state even for failed runs: tasks that succeeded were applied
+	if r.exec.State != nil {
+		if err := r.exec.State.Save(); err != nil {
+			r.exec.Output.Warn("Failed to save state: %v", err)
+		}
+	}
+
+	if r.notifier != nil {
+		sum := runSummary(r.path, r.exec.DryRun, result)
+		if err := r.notifier.Send(context.Background(), sum); err != nil {
+			r.exec.Output.Warn("Failed to send notification: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// loadConfig loads the configuration given with --config, or found next
+// to the playbook. Module defaults must name known modules; they are keyed
+// by the modules' registered names.
+func loadConfig(cmd *cobra.Command, playbookPath string) (*config.Config, error) {
+	var cfg *config.Config
+	var err error
+	if path, _ := cmd.Flags().GetString("config"); path != "" {
+		cfg, err = config.Load(path)
+	} else {
+		cfg, err = config.Find(playbookPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for name, params := range cfg.ModuleDefaults {
+		resolved := module.Resolve(name, nil)
+		if resolved == "" {
+			return nil, fmt.Errorf("%s: module_defaults: unknown module '%s'", cfg.Path, name)
+		}
+		if resolved != name {
+			delete(cfg.ModuleDefaults, name)
+			cfg.ModuleDefaults[resolved] = params
+		}
+	}
+	return cfg, nil
+}
+
+// loadNotifier returns the notifier of the sinks of cfg. It returns nil
+// if there are none or --no-notify is set.
+func loadNotifier(cmd *cobra.Command, cfg *config.Config) (*notify.Notifier, error) {
+	if off, _ := cmd.Flags().GetBool("no-notify"); off {
+		return nil, nil
+	}
+	if len(cfg.Notify) == 0 {
+		return nil, nil
+	}
+
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Path, err)
+	}
+	return notifier, nil
+}
+
+// runSummary returns the summary of a run for notifications.
+func runSummary(playbookPath string, dryRun bool, result *executor.RunResult) *notify.Summary {
+	controller, _ := os.Hostname()
+	stats := result.Stats
+	sum := &notify.Summary{
+		Playbook:   playbookPath,
+		Controller: controller,
+		Success:    result.Success,
+		DryRun:     dryRun,
+		OK:         stats.OK,
+		Changed:    stats.Changed,
+		Failed:     stats.Failed,
+		Skipped:    stats.Skipped,
+		Duration:   stats.Duration(),
+	}
+	for _, f := range result.Failures {
+		sum.Failures = append(sum.Failures, notify.Failure(f))
+	}
+	return sum
+}
+
+// tagExpression returns the tag expression of the flag name: its values
+// joined with or, so --tags web,db selects the tasks tagged either.
+func tagExpression(cmd *cobra.Command, name string) (string, error) {
+	exprs, _ := cmd.Flags().GetStringSlice(name)
+	for _, expr := range exprs {
+		if err := executor.CheckTags(expr); err != nil {
+			return "", fmt.Errorf("--%s: %w", name, err)
+		}
+	}
+	if len(exprs) < 2 {
+		return strings.Join(exprs, ""), nil
+	}
+	for i, expr := range exprs {
+		exprs[i] = "(" + expr + ")"
+	}
+	return strings.Join(exprs, " or "), nil
+}
+
+// setInventory loads the inventory given with --inventory and applies
+// --limit to the executor.
+func setInventory(cmd *cobra.Command, exec *executor.Executor) error {
+	if path, _ := cmd.Flags().GetString("inventory"); path != "" {
+		inv, err := inventory.Load(path)
+		if err != nil {
+			return err
+		}
+		exec.Inventory = inv
+	}
+	exec.Limit, _ = cmd.Flags().GetStringSlice("limit")
+	return nil
+}
+
+// envBool reports whether the environment variable name is set to a true
+// value (1, true, yes).
+// terminalStyle returns whether output to stdout uses colors and Unicode
+// glyphs. With --color=auto (the default) both are used on a terminal and
+// neither in pipes and CI logs, and a non-empty NO_COLOR disables colors.
+// --no-color is the same as --color=never.
+func terminalStyle() (useColor, unicode bool, err error) {
+	tty := output.IsTerminal(os.Stdout)
+
+	color := colorFlag
+	if noColor {
+		color = "never"
+	}
+
+	switch color {
+	case "auto":
+		return tty && os.Getenv("NO_COLOR") == "", tty, nil
+	case "always":
+		return true, true, nil
+	case "never":
+		return false, tty, nil
+	}
+	return false, false, fmt.Errorf("invalid --color value %q (want auto, always, or never)", colorFlag)
+}
+
+// confirmAtTerminal returns the confirmation of plays and tasks with
+// confirm: a question at the terminal, or an error without one.
+func confirmAtTerminal(out *output.Output) func(context.Context, executor.Confirmation) (bool, error) {
+	if !output.IsTerminal(os.Stdin) {
+		return func(context.Context, executor.Confirmation) (bool, error) {
+			return false, errors.New("stdin is not a terminal (pass --yes to approve without asking)")
+		}
+	}
+	in := bufio.NewReader(os.Stdin)
+	return func(ctx context.Context, c executor.Confirmation) (bool, error) {
+		target := c.Task
+		if target == "" {
+			target = "play " + c.Play
+		}
+		ok, err := out.Confirm(in, fmt.Sprintf("[%s] %s: %s", c.Host, target, c.Question))
+		if errors.Is(err, io.EOF) {
+			return false, errors.New("no answer on stdin (pass --yes to approve without asking)")
+		}
+		return ok, err
+	}
+}
+
+// outputMode returns the output mode selected by --quiet and --changed-only.
+func outputMode() output.Mode {
+	switch {
+	case quiet:
+		return output.ModeQuiet
+	case changedOnly:
+		return output.ModeChangedOnly
+	}
+	return output.ModeNormal
+}
+
+func envBool(name string) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// parseExtraVars parses key=value pairs given with -e.
+func parseExtraVars(pairs []string) (map[string]any, error) {
+	vars := make(map[string]any, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid extra var %q: expected key=value", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
 // validateCmd validates a playbook without running it
 var validateCmd = &cobra.Command{
 	Use:   "validate <playbook.yaml> [playbook2.yaml ...]",
@@ -147,6 +617,9 @@ This checks for:
   - Valid module names
   - Task structure
 
+Warnings about deprecated syntax, insecure parameters, and commands that a
+module could replace are printed below each playbook.
+
 Examples:
   bolt validate setup.yaml
   bolt validate *.yaml`,
@@ -158,12 +631,18 @@ func validatePlaybooks(cmd *cobra.Command, args []string) error {
 	var hasErrors bool
 
 	for _, playbookPath := range args {
-		if err := validatePlaybook(playbookPath); err != nil {
+		warnings, err := validatePlaybook(playbookPath)
+		if err != nil {
 			fmt.Printf("FAIL: %s - %v\n", playbookPath, err)
 			hasErrors = true
 		} else {
 			fmt.Printf("OK: %s\n", playbookPath)
 		}
+		if !suppressWarnings {
+			for _, w := range warnings {
+				fmt.Printf("  WARN: %s\n", w)
+			}
+		}
 	}
 
 	if hasErrors {
@@ -174,39 +653,442 @@ func validatePlaybooks(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func validatePlaybook(playbookPath string) error {
+// validatePlaybook checks a playbook and returns its warnings.
+func validatePlaybook(playbookPath string) ([]playbook.Warning, error) {
 	// Check if file exists
 	if _, err := os.Stat(playbookPath); os.IsNotExist(err) {
-		return fmt.Errorf("not found")
+		return nil, fmt.Errorf("not found")
 	}
 
 	// Parse playbook
 	pb, err := playbook.ParseFileRaw(playbookPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Validate modules exist
 	var errors []string
 	for _, play := range pb.Plays {
-		for _, task := range play.Tasks {
+		for name := range play.ModuleDefaults {
+			if module.Get(name) == nil {
+				err := fmt.Errorf("module_defaults: unknown module '%s'", name)
+				errors = append(errors, playbook.ErrorAt(play.Pos, "", err).Error())
+			}
+		}
+		for alias, target := range play.ModuleAliases {
+			if module.Get(play.ModuleName(alias)) == nil {
+				err := fmt.Errorf("module_aliases: '%s' refers to unknown module '%s'", alias, target)
+				errors = append(errors, playbook.ErrorAt(play.Pos, "", err).Error())
+			}
+		}
+		tasks := play.Tasks
+		if s := play.UpdateStrategy; s != nil {
+			tasks = append(append(append([]*playbook.Task{}, tasks...), s.PreBatch...), s.PostBatch...)
+		}
+		for _, task := range tasks {
 			playbook.ExpandShorthand(task)
 			if err := playbook.ResolveModule(task); err != nil {
-				errors = append(errors, fmt.Sprintf("%s: %v", task.String(), err))
+				errors = append(errors, playbook.ErrorAt(task.Pos, task.String(), err).Error())
 			}
 		}
 		for _, handler := range play.Handlers {
 			playbook.ExpandShorthand(handler)
 			if err := playbook.ResolveModule(handler); err != nil {
-				errors = append(errors, fmt.Sprintf("%s: %v", handler.String(), err))
+				errors = append(errors, playbook.ErrorAt(handler.Pos, handler.String(), err).Error())
 			}
 		}
 	}
 
 	if len(errors) > 0 {
-		return fmt.Errorf("%d error(s): %s", len(errors), errors[0])
+		return nil, fmt.Errorf("%d error(s): %s", len(errors), errors[0])
+	}
+
+	return pb.Warnings(), nil
+}
+
+// testCmd runs playbook test scenarios in containers
+var testCmd = &cobra.Command{
+	Use:   "test [scenario.test.yaml | dir ...]",
+	Short: "Test playbooks in ephemeral containers",
+	Long: `Run test scenarios against fresh Docker containers.
+
+Each scenario (a *.test.yaml file) starts a container, runs a playbook
+against it, optionally runs it again to check idempotence, and then runs
+verify tasks such as assert. Directories are searched recursively;
+without arguments the current directory is used.
+
+Examples:
+  bolt test
+  bolt test tests/
+  bolt test tests/nginx.test.yaml`,
+	RunE: runTests,
+}
+
+func runTests(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		args = []string{"."}
 	}
 
+	files, err := scenario.Discover(args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no scenarios found (files ending in %s)", scenario.FileSuffix)
+	}
+
+	// Load all scenarios first so typos fail fast
+	scenarios := make([]*scenario.Scenario, 0, len(files))
+	for _, file := range files {
+		sc, err := scenario.Load(file)
+		if err != nil {
+			return err
+		}
+		scenarios = append(scenarios, sc)
+	}
+
+	useColor, unicode, err := terminalStyle()
+	if err != nil {
+		return err
+	}
+
+	runner := scenario.NewRunner(os.Stdout)
+	runner.Debug = debug
+	runner.Output.SetColor(useColor)
+	runner.Output.SetUnicode(unicode)
+	runner.Output.SetVerbose(verbose)
+	runner.Output.SetMode(outputMode())
+	runner.Output.SetDebug(debug)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var failed int
+	results := make([]*scenario.Result, 0, len(scenarios))
+	for _, sc := range scenarios {
+		result := runner.Run(ctx, sc)
+		results = append(results, result)
+		if !result.Passed {
+			failed++
+		}
+	}
+
+	fmt.Println()
+	for _, result := range results {
+		if result.Passed {
+			fmt.Printf("PASS: %s (%.1fs)\n", result.Scenario.Name, result.Duration.Seconds())
+		} else {
+			fmt.Printf("FAIL: %s [%s] - %v\n", result.Scenario.Name, result.Stage, result.Err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenario(s) failed", failed, len(results))
+	}
+
+	fmt.Printf("\nAll %d scenario(s) passed.\n", len(results))
+	return nil
+}
+
+// driftCmd compares live hosts with the last applied state
+var driftCmd = &cobra.Command{
+	Use:   "drift <playbook.yaml>",
+	Short: "Compare hosts against the last applied state",
+	Long: `Check whether the resources a playbook last applied have changed.
+
+bolt run records a fingerprint of every file and package it manages in a
+state file. drift re-fingerprints those resources on the playbook's hosts
+and lists any that no longer match. Exits with code 2 if drift is found.
+
+Examples:
+  bolt drift site.yaml
+  bolt drift site.yaml --state-file /var/lib/bolt/site.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDrift,
+}
+
+func init() {
+	driftCmd.Flags().String("state-file", "", "State file to compare against (default: .bolt/state.json next to the playbook)")
+	driftCmd.Flags().StringP("inventory", "i", "", "Inventory file resolving the hosts of plays")
+	driftCmd.Flags().StringSliceP("limit", "l", nil, "Only check hosts matching these patterns")
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	playbookPath := args[0]
+
+	pb, err := playbook.ParseFileRaw(playbookPath)
+	if err != nil {
+		return fmt.Errorf("failed to parse playbook: %w", err)
+	}
+
+	stateFile, _ := cmd.Flags().GetString("state-file")
+	if stateFile == "" {
+		stateFile = state.DefaultPath(playbookPath)
+	}
+	st, err := state.Load(stateFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	exec := executor.New()
+	if err := setInventory(cmd, exec); err != nil {
+		return err
+	}
+
+	var drifted int
+	seen := make(map[string]bool)
+
+	for _, play := range pb.Plays {
+		hosts, err := exec.Hosts(play)
+		if err != nil {
+			return err
+		}
+
+		for _, host := range hosts {
+			if seen[host.Name] {
+				continue
+			}
+			seen[host.Name] = true
+
+			n, err := checkDrift(ctx, exec, st, play, host)
+			if err != nil {
+				return err
+			}
+			drifted += n
+		}
+	}
+
+	if drifted > 0 {
+		os.Exit(2)
+	}
+	return nil
+}
+
+// checkDrift prints the resources recorded for host that drifted and
+// returns how many did.
+func checkDrift(ctx context.Context, exec *executor.Executor, st *state.State, play *playbook.Play, host *inventory.Host) (int, error) {
+	fmt.Printf("HOST %s\n", host.Name)
+
+	entries := st.Entries(host.Name)
+	if len(entries) == 0 {
+		fmt.Println("  no recorded state")
+		return 0, nil
+	}
+
+	conn, err := exec.ConnectorFor(play, host)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create connector: %w", err)
+	}
+	if err := conn.Connect(ctx); err != nil {
+		return 0, fmt.Errorf("failed to connect to %s: %w", host.Name, err)
+	}
+
+	divergent := state.Check(ctx, conn, entries)
+	_ = conn.Close()
+
+	for _, d := range divergent {
+		if d.Err != nil {
+			fmt.Printf("  ERROR %s: %v\n", d.Entry.Resource, d.Err)
+		} else {
+			fmt.Printf("  DRIFT %s (task: %s)\n", d.Entry.Resource, d.Entry.Task)
+			fmt.Printf("        applied: %s\n", d.Entry.Fingerprint)
+			fmt.Printf("        live:    %s\n", d.Live)
+		}
+	}
+	fmt.Printf("  %d of %d resource(s) drifted\n", len(divergent), len(entries))
+	return len(divergent), nil
+}
+
+// inventoryCmd shows how an inventory resolves
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory [group]",
+	Short: "Show the hosts and groups of an inventory",
+	Long: `Show how bolt reads an inventory, like ansible-inventory.
+
+--list prints all groups and the merged variables of every host as JSON.
+--graph prints the tree of groups and hosts below a group (default: all).
+
+Examples:
+  bolt inventory -i hosts.yaml --list
+  bolt inventory -i hosts.yaml --graph
+  bolt inventory -i hosts.yaml --graph webservers --vars`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runInventory,
+}
+
+func init() {
+	inventoryCmd.Flags().StringP("inventory", "i", "", "Inventory file")
+	inventoryCmd.Flags().Bool("list", false, "Print groups and host variables as JSON")
+	inventoryCmd.Flags().Bool("graph", false, "Print the tree of groups and hosts (optionally below the group given as argument)")
+	inventoryCmd.Flags().Bool("vars", false, "Include variables in --graph")
+	_ = inventoryCmd.MarkFlagRequired("inventory")
+	inventoryCmd.MarkFlagsOneRequired("list", "graph")
+	inventoryCmd.MarkFlagsMutuallyExclusive("list", "graph")
+}
+
+func runInventory(cmd *cobra.Command, args []string) error {
+	path, _ := cmd.Flags().GetString("inventory")
+	inv, err := inventory.Load(path)
+	if err != nil {
+		return err
+	}
+
+	if list, _ := cmd.Flags().GetBool("list"); list {
+		if len(args) > 0 {
+			return fmt.Errorf("--list takes no group argument")
+		}
+		return inv.List(os.Stdout)
+	}
+
+	group := "all"
+	if len(args) > 0 {
+		group = args[0]
+	}
+	vars, _ := cmd.Flags().GetBool("vars")
+	return inv.Graph(os.Stdout, group, vars)
+}
+
+// lockCmd records checksums of a playbook's content
+var lockCmd = &cobra.Command{
+	Use:   "lock <playbook.yaml>",
+	Short: "Record checksums of a playbook's content",
+	Long: `Write bolt.lock next to the playbook with a SHA256 checksum of the
+playbook, every file of the roles it uses, and the local files its copy and
+template tasks read.
+
+Once a lock file exists, bolt run refuses to run if any of these files
+changed. Run bolt lock again after reviewing changes to accept them.
+
+Examples:
+  bolt lock site.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		playbookPath := args[0]
+
+		lock, err := integrity.Generate(playbookPath)
+		if err != nil {
+			return err
+		}
+		path := integrity.LockPath(playbookPath)
+		if err := lock.Save(path); err != nil {
+			return err
+		}
+
+		fmt.Printf("Locked %d file(s) in %s\n", len(lock.Files), path)
+		return nil
+	},
+}
+
+// verifyCmd checks a playbook's content against its lock file
+var verifyCmd = &cobra.Command{
+	Use:   "verify <playbook.yaml>",
+	Short: "Check a playbook's content against bolt.lock",
+	Long: `Compare the playbook, its roles, and the files it copies with the
+checksums recorded by bolt lock. Lists every modified, missing, or added
+file and exits with code 1 if anything changed.
+
+Examples:
+  bolt verify site.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := verifyLock(args[0], true); err != nil {
+			return err
+		}
+		fmt.Println("✓ Content matches", integrity.LockPath(args[0]))
+		return nil
+	},
+}
+
+// verifyLock checks the playbook's content against its lock file. A missing
+// lock file is only an error if required is set.
+func verifyLock(playbookPath string, required bool) error {
+	path := integrity.LockPath(playbookPath)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if required {
+			return fmt.Errorf("no lock file at %s; run 'bolt lock %s' first", path, playbookPath)
+		}
+		return nil
+	}
+
+	lock, err := integrity.Load(path)
+	if err != nil {
+		return err
+	}
+	mismatches, err := integrity.Verify(playbookPath, lock)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	for _, m := range mismatches {
+		fmt.Fprintf(os.Stderr, "  %s\n", m)
+	}
+	return fmt.Errorf("%d file(s) do not match %s; review the changes and run 'bolt lock' to accept them", len(mismatches), path)
+}
+
+// convertCmd converts an Ansible playbook to a bolt playbook
+var convertCmd = &cobra.Command{
+	Use:   "convert <ansible-playbook.yml>",
+	Short: "Convert an Ansible playbook to bolt",
+	Long: `Rewrite an Ansible playbook as a bolt playbook.
+
+Fully qualified module names, shell, args, loop_control, yes/no booleans,
+service and systemd tasks, and ansible_* facts are converted. Directives
+bolt has no equivalent for are removed, and constructs that need manual
+changes (blocks, includes, unknown modules) are kept and reported.
+
+The converted playbook is written to stdout, or to the file given with
+-o. Notes about what changed are written to stderr. Exits with code 1 if
+anything needs manual changes.
+
+Examples:
+  bolt convert site.yml > site.yaml
+  bolt convert site.yml -o site.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConvert,
+}
+
+func init() {
+	convertCmd.Flags().StringP("output", "o", "", "Write the converted playbook to a file instead of stdout")
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read playbook: %w", err)
+	}
+
+	res, err := ansible.Convert(data, func(name string) bool {
+		return module.Get(name) != nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	outPath, _ := cmd.Flags().GetString("output")
+	if outPath == "" {
+		os.Stdout.Write(res.Playbook)
+	} else if err := os.WriteFile(outPath, res.Playbook, 0644); err != nil {
+		return fmt.Errorf("failed to write playbook: %w", err)
+	}
+
+	for _, note := range res.Notes {
+		prefix := "note"
+		if note.Unsupported {
+			prefix = "TODO"
+		}
+		fmt.Fprintf(os.Stderr, "%s:%d: %s: %s\n", args[0], note.Line, prefix, note.Message)
+	}
+
+	if n := res.Unsupported(); n > 0 {
+		fmt.Fprintf(os.Stderr, "%d construct(s) need manual changes\n", n)
+		os.Exit(1)
+	}
 	return nil
 }
 
@@ -231,3 +1113,42 @@ var modulesCmd = &cobra.Command{
 		fmt.Printf("Total: %d modules\n", len(modules))
 	},
 }
+
+// schemaCmd prints the JSON Schema of playbooks
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of playbooks",
+	Long: `Print a JSON Schema describing plays, task directives, and the parameters
+of every module, for completion and validation in editors.
+
+Examples:
+  bolt schema > bolt.schema.json
+  bolt schema -o .vscode/bolt.schema.json
+
+With the YAML language server (VS Code, Neovim, ...), reference the schema
+from a playbook:
+  # yaml-language-server: $schema=./bolt.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := json.MarshalIndent(playbook.Schema(), "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+
+		path, _ := cmd.Flags().GetString("output")
+		if path == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write schema: %w", err)
+		}
+		fmt.Printf("Wrote %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	schemaCmd.Flags().StringP("output", "o", "", "Write the schema to this file instead of stdout")
+}
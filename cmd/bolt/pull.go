@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eugenetaranov/bolt/internal/gitrepo"
+	"github.com/eugenetaranov/bolt/internal/output"
+)
+
+// pullCmd checks out a playbook repository and runs a playbook from it
+var pullCmd = &cobra.Command{
+	Use:   "pull --url <repo>",
+	Short: "Run a playbook from a git repository",
+	Long: `Clone a git repository into a cache directory, or update the clone,
+and run a playbook from it on this machine, so hosts can configure
+themselves from a central repository (like ansible-pull).
+
+The clone belongs to bolt: local changes in it are discarded on update.
+Run it from cron or a systemd timer, or see 'bolt agent' for a
+long-running alternative.
+
+Examples:
+  bolt pull --url https://github.com/example/config.git
+  bolt pull --url git@github.com:example/config.git --playbook web.yaml --checkout staging
+  bolt pull --url https://github.com/example/config.git --only-if-changed`,
+	Args: cobra.NoArgs,
+	RunE: runPull,
+}
+
+func init() {
+	pullCmd.Flags().StringP("url", "U", "", "URL of the playbook repository")
+	_ = pullCmd.MarkFlagRequired("url")
+	pullCmd.Flags().String("playbook", "site.yaml", "Playbook to run, relative to the repository root")
+	pullCmd.Flags().StringP("checkout", "C", "", "Branch or tag to check out (default: the repository's default branch)")
+	pullCmd.Flags().String("directory", "", "Directory of the clone (default: a directory per URL in the user cache directory)")
+	pullCmd.Flags().BoolP("only-if-changed", "o", false, "Only run the playbook if the repository changed since the last pull")
+	addPlaybookFlags(pullCmd)
+}
+
+func runPull(cmd *cobra.Command, args []string) error {
+	url, _ := cmd.Flags().GetString("url")
+	ref, _ := cmd.Flags().GetString("checkout")
+	book, _ := cmd.Flags().GetString("playbook")
+	if !filepath.IsLocal(book) {
+		return fmt.Errorf("--playbook must be a path inside the repository: %s", book)
+	}
+
+	dir, _ := cmd.Flags().GetString("directory")
+	if dir == "" {
+		var err error
+		if dir, err = pullDir(url); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	useColor, unicode, err := terminalStyle()
+	if err != nil {
+		return err
+	}
+	out := output.New(os.Stdout)
+	out.SetColor(useColor)
+	out.SetUnicode(unicode)
+
+	changed, err := gitrepo.Sync(ctx, url, dir, ref)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", dir, err)
+	}
+	head, err := gitrepo.Head(ctx, dir)
+	if err != nil {
+		return err
+	}
+	out.Info("Checked out %.12s of %s in %s", head, url, dir)
+
+	if onlyIfChanged, _ := cmd.Flags().GetBool("only-if-changed"); onlyIfChanged && !changed {
+		out.Info("Repository unchanged, not running the playbook")
+		return nil
+	}
+
+	run, err := newPlaybookRun(cmd, filepath.Join(dir, book))
+	if err != nil {
+		return err
+	}
+	result, err := run.Run(ctx)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// pullDir returns the default clone directory for a repository URL: a
+// directory named after the repository, made unique by a hash of the
+// URL, in the user cache directory.
+func pullDir(url string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("no cache directory, use --directory: %w", err)
+	}
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(url, "/")), ".git")
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		// scp-like URLs without a path (host:repo)
+		name = name[i+1:]
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cache, "bolt", "pull", name+"-"+hex.EncodeToString(sum[:4])), nil
+}
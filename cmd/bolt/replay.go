@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/cassette"
+	"github.com/eugenetaranov/bolt/internal/inventory"
+)
+
+// recordCmd runs a playbook and records what it does on its hosts
+var recordCmd = &cobra.Command{
+	Use:   "record <playbook.yaml> <cassette.json>",
+	Short: "Run a playbook and record its commands in a cassette",
+	Long: `Run a playbook like bolt run and record every command, upload, and
+download on its hosts, with their results, in a cassette file. bolt replay
+runs the playbook again against the cassette.
+
+The cassette holds the output of every command and the content of every
+downloaded file, so treat it like the hosts' secrets.
+
+Examples:
+  bolt record site.yaml testdata/site.cassette.json -i hosts.yaml
+  bolt record site.yaml testdata/check.cassette.json --dry-run`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRecord,
+}
+
+// replayCmd runs a playbook against a cassette
+var replayCmd = &cobra.Command{
+	Use:   "replay <playbook.yaml> <cassette.json>",
+	Short: "Run a playbook against a recorded cassette",
+	Long: `Run a playbook against a cassette made by bolt record instead of its
+hosts. Nothing is connected to: commands get their recorded results, and
+uploads are checked against the recorded content. The replay fails if the
+playbook does something that was not recorded, or leaves recorded
+operations undone, which makes it a fast regression test for changes to
+playbooks, variables, or bolt itself.
+
+Pass the flags the cassette was recorded with, such as --dry-run, -e, and
+--limit. Runs of ten or more digits, such as the timestamps in the names
+of temp files, are ignored when matching commands. Replays do not save
+state, take locks, or send notifications.
+
+Examples:
+  bolt replay site.yaml testdata/site.cassette.json -i hosts.yaml
+  bolt replay site.yaml testdata/check.cassette.json --dry-run`,
+	Args: cobra.ExactArgs(2),
+	RunE: runReplay,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{recordCmd, replayCmd} {
+		addPlaybookFlags(cmd)
+		cmd.Flags().Bool("diff", false, "Show what changed tasks change, including the content of files (with --dry-run: what they would change)")
+	}
+}
+
+func runRecord(cmd *cobra.Command, args []string) error {
+	playbookPath, cassettePath := args[0], args[1]
+
+	run, err := newPlaybookRun(cmd, playbookPath)
+	if err != nil {
+		return err
+	}
+	c := cassette.New(playbookPath)
+	run.exec.WrapConnector = func(host string, conn connector.Connector) connector.Connector {
+		return c.Record(host, conn)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := run.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A failed run is worth replaying too
+	if err := c.Save(cassettePath); err != nil {
+		return err
+	}
+	run.exec.Output.Info("Recorded %d operation(s) in %s", c.Len(), cassettePath)
+
+	if !result.Success {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	playbookPath, cassettePath := args[0], args[1]
+
+	c, err := cassette.Load(cassettePath)
+	if err != nil {
+		return err
+	}
+	run, err := newPlaybookRun(cmd, playbookPath)
+	if err != nil {
+		return err
+	}
+	// Nothing of a replay reaches the hosts or the outside world
+	run.exec.State = nil
+	run.exec.Lock = false
+	run.notifier = nil
+	hosts := c.HostNames()
+	run.exec.NewConnector = func(host *inventory.Host) (connector.Connector, error) {
+		if !slices.Contains(hosts, host.Name) {
+			return nil, fmt.Errorf("host %s is not in the cassette", host.Name)
+		}
+		return c.Player(host.Name), nil
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := run.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The failed task shows where the run diverged
+	if !result.Success {
+		os.Exit(1)
+	}
+
+	out := run.exec.Output
+	unplayed := c.Unplayed()
+	n := 0
+	for _, host := range hosts {
+		for _, op := range unplayed[host] {
+			out.Error("Not replayed on %s: %s", host, op)
+			n++
+		}
+	}
+	if n > 0 {
+		out.Warn("%d recorded operation(s) were not replayed", n)
+		os.Exit(1)
+	}
+	out.Info("Replayed %d operation(s) from %s", c.Len(), cassettePath)
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eugenetaranov/bolt/internal/executor"
+	"github.com/eugenetaranov/bolt/internal/notify"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/server"
+)
+
+// serverCmd serves the APIs and dashboard for running playbooks
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Serve a REST API and web dashboard for running playbooks",
+	Long: `Serve a REST API and a web dashboard for running the playbooks of a
+directory: start runs, follow their output live, and browse past runs.
+Runs are queued and run one at a time, and kept in a history directory.
+With --grpc-addr, the same runs are also served over gRPC, with typed
+task results (see api/bolt/v1/bolt.proto).
+
+The flags of bolt run apply to every run; a request can add --dry-run,
+--diff, --limit, and extra variables. Anyone who can reach the server can
+run its playbooks, so it only listens on other addresses than loopback
+with --token.
+
+Examples:
+  bolt server --dir playbooks -i hosts.yaml
+  BOLT_SERVER_TOKEN=secret bolt server --addr :8470 --dir playbooks
+  bolt server --dir playbooks --grpc-addr 127.0.0.1:8471`,
+	Args: cobra.NoArgs,
+	RunE: runServer,
+}
+
+func init() {
+	serverCmd.Flags().String("addr", "127.0.0.1:8470", "Address to listen on")
+	serverCmd.Flags().String("grpc-addr", "", "Address to serve the gRPC API on (default: no gRPC API)")
+	serverCmd.Flags().String("dir", ".", "Directory of the playbooks that may be run")
+	serverCmd.Flags().String("token", os.Getenv("BOLT_SERVER_TOKEN"), "Token API requests must send, as a bearer token (env: BOLT_SERVER_TOKEN)")
+	serverCmd.Flags().String("history-dir", "", "Directory keeping past runs and their output (default: .bolt/server next to the playbooks)")
+	serverCmd.Flags().Int("max-history", server.DefaultMaxHistory, "Number of past runs to keep")
+	addPlaybookFlags(serverCmd)
+}
+
+func runServer(cmd *cobra.Command, args []string) error {
+	addr, _ := cmd.Flags().GetString("addr")
+	grpcAddr, _ := cmd.Flags().GetString("grpc-addr")
+	dir, _ := cmd.Flags().GetString("dir")
+	token, _ := cmd.Flags().GetString("token")
+	historyDir, _ := cmd.Flags().GetString("history-dir")
+	maxHistory, _ := cmd.Flags().GetInt("max-history")
+	if historyDir == "" {
+		historyDir = filepath.Join(dir, ".bolt", "server")
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("playbook directory not found: %s", dir)
+	}
+
+	useColor, unicode, err := terminalStyle()
+	if err != nil {
+		return err
+	}
+	out := output.New(os.Stdout)
+	out.SetColor(useColor)
+	out.SetUnicode(unicode)
+
+	srv := &server.Server{
+		Dir: dir,
+		Run: func(ctx context.Context, path string, req *server.Request, sink server.Sink) (*notify.Summary, error) {
+			return serverRun(ctx, cmd, path, req, sink)
+		},
+		Token:      token,
+		HistoryDir: historyDir,
+		MaxHistory: maxHistory,
+		Output:     out,
+	}
+
+	ln, err := listen(addr, token)
+	if err != nil {
+		return err
+	}
+	var grpcLn net.Listener
+	if grpcAddr != "" {
+		if grpcLn, err = listen(grpcAddr, token); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if err := srv.Start(ctx); err != nil {
+		ln.Close()
+		if grpcLn != nil {
+			grpcLn.Close()
+		}
+		return err
+	}
+	if grpcLn != nil {
+		grpcSrv := srv.GRPC()
+		go func() {
+			<-ctx.Done()
+			// Run streams stay open, so do not wait for them for long
+			timer := time.AfterFunc(5*time.Second, grpcSrv.Stop)
+			defer timer.Stop()
+			grpcSrv.GracefulStop()
+		}()
+		go func() {
+			if err := grpcSrv.Serve(grpcLn); err != nil {
+				out.Error("gRPC server: %v", err)
+			}
+		}()
+		out.Info("Serving the gRPC API on %s", grpcLn.Addr())
+	}
+	httpSrv := &http.Server{Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		// Event streams stay open, so do not wait for them for long
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+			httpSrv.Close()
+		}
+	}()
+
+	out.Info("Serving %s on http://%s", dir, ln.Addr())
+	if err := httpSrv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serverRun runs the playbook at path as the request asks, on top of the
+// flags of the server command, writing the output and task results to
+// sink.
+func serverRun(ctx context.Context, cmd *cobra.Command, path string, req *server.Request, sink server.Sink) (*notify.Summary, error) {
+	run, err := newPlaybookRun(cmd, path)
+	if err != nil {
+		return nil, err
+	}
+	exec := run.exec
+
+	exec.Output = output.New(sink)
+	exec.Output.SetColor(false)
+	exec.Output.SetUnicode(false)
+	exec.Output.SetVerbose(verbose)
+	exec.Output.SetMode(outputMode())
+	exec.Output.SetDebug(debug)
+
+	if req.DryRun {
+		exec.DryRun = true
+		exec.State = nil
+		exec.Lock = false
+	}
+	exec.Diff = exec.Diff || req.Diff
+	if len(req.Limit) > 0 {
+		exec.Limit = req.Limit
+	}
+	maps.Copy(exec.ExtraVars, req.ExtraVars)
+	exec.OnTask = func(ev executor.TaskEvent) {
+		sink.Task(server.Task(ev))
+	}
+	exec.Confirm = func(ctx context.Context, c executor.Confirmation) (bool, error) {
+		return sink.Confirm(ctx, server.Confirmation(c))
+	}
+
+	result, err := run.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return runSummary(path, exec.DryRun, result), nil
+}
+
+// listen listens on addr, which without a token must be a loopback
+// address.
+func listen(addr, token string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	if token == "" && !loopback(ln.Addr()) {
+		ln.Close()
+		return nil, fmt.Errorf("refusing to listen on %s without --token: anyone reaching it could run playbooks", ln.Addr())
+	}
+	return ln, nil
+}
+
+// loopback reports whether addr is a loopback address.
+func loopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eugenetaranov/bolt/internal/update"
+)
+
+// versionCmd prints the version and optionally checks for a newer one
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of bolt",
+	Long: `Print the version of bolt.
+
+With --check-update, also check GitHub for a newer release.
+
+Examples:
+  bolt version
+  bolt version --check-update`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+func init() {
+	versionCmd.Flags().Bool("check-update", false, "Check GitHub for a newer release")
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	fmt.Printf("bolt %s\n", rootCmd.Version)
+
+	if check, _ := cmd.Flags().GetBool("check-update"); !check {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rel, err := update.NewClient().Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case update.Newer(version, rel.Version()):
+		fmt.Printf("A newer version is available: %s (%s)\n", rel.Tag, rel.URL)
+		fmt.Println("Run 'bolt self-update' to install it.")
+	case version == "dev":
+		fmt.Printf("Development build; the latest release is %s\n", rel.Tag)
+	default:
+		fmt.Println("bolt is up to date")
+	}
+	return nil
+}
+
+// selfUpdateCmd replaces the running binary with the latest release
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update bolt to the latest release",
+	Long: `Download the latest release of bolt for this platform and replace the
+running binary with it.
+
+The release archive is verified against the SHA256 checksums published
+with the release before anything is replaced. Installations managed by
+Homebrew should be updated with brew upgrade instead.
+
+Examples:
+  bolt self-update
+  sudo bolt self-update    # if bolt is installed in a root-owned directory`,
+	Args: cobra.NoArgs,
+	RunE: runSelfUpdate,
+}
+
+func init() {
+	selfUpdateCmd.Flags().Bool("force", false, "Reinstall even if bolt is up to date or a development build")
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	force, _ := cmd.Flags().GetBool("force")
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the bolt binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("failed to locate the bolt binary: %w", err)
+	}
+	if strings.Contains(exe, "/Cellar/") {
+		return fmt.Errorf("bolt is installed with Homebrew; run 'brew upgrade bolt' instead")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	client := update.NewClient()
+	rel, err := client.Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !force && !update.Newer(version, rel.Version()) {
+		if version == "dev" {
+			return fmt.Errorf("this is a development build; use --force to replace it with %s", rel.Tag)
+		}
+		fmt.Printf("bolt %s is up to date\n", version)
+		return nil
+	}
+
+	fmt.Printf("Downloading bolt %s for %s/%s...\n", rel.Tag, runtime.GOOS, runtime.GOARCH)
+	binary, err := client.Download(ctx, rel, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
+	if err := update.Replace(exe, binary); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	fmt.Printf("Updated %s to %s\n", exe, rel.Tag)
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package agent runs a playbook on a schedule, turning bolt into a
+// pull-based configuration management agent: each host runs its own
+// playbook periodically and corrects any drift.
+//
+// Runs hold a lock file so they never overlap with each other or with
+// another agent on the same playbook, and their outcome is exposed as
+// Prometheus metrics.
+package agent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/executor"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/runlock"
+)
+
+// Agent runs a playbook on a schedule.
+type Agent struct {
+	// Schedule decides when runs start.
+	Schedule Schedule
+
+	// Immediate starts the first run right away instead of at the first
+	// scheduled time.
+	Immediate bool
+
+	// Pull updates the playbook before each run (e.g., git pull). If it
+	// fails, the run uses the playbook as it is.
+	Pull func(ctx context.Context) error
+
+	// Run runs the playbook once. An error means the run could not start
+	// (e.g., the playbook does not parse); failed tasks are reported in
+	// the result.
+	Run func(ctx context.Context) (*executor.RunResult, error)
+
+	// LockFile is held during each run. A run that finds it held is
+	// skipped.
+	LockFile string
+
+	// Metrics records the outcome of runs.
+	Metrics *Metrics
+
+	// MetricsFile, if set, is rewritten with the metrics after each run.
+	MetricsFile string
+
+	// Output logs the agent's progress.
+	Output *output.Output
+}
+
+// Start runs the playbook on the schedule until ctx is canceled.
+func (a *Agent) Start(ctx context.Context) error {
+	next := time.Now()
+	if !a.Immediate {
+		next = a.Schedule.Next(next)
+	}
+
+	for {
+		if next.IsZero() {
+			return errors.New("schedule has no further runs")
+		}
+		a.Output.Info("Next run at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		a.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		next = a.Schedule.Next(time.Now())
+	}
+}
+
+// RunOnce updates and runs the playbook unless another run holds the
+// lock, and records the outcome.
+func (a *Agent) RunOnce(ctx context.Context) {
+	defer a.writeMetrics()
+
+	lock, err := runlock.File(a.LockFile)
+	if err != nil {
+		if errors.Is(err, runlock.ErrLocked) {
+			a.Output.Warn("Skipping run: %v", err)
+			a.Metrics.Skipped()
+			return
+		}
+		a.Output.Error("Run failed: %v", err)
+		a.Metrics.Record(nil)
+		return
+	}
+	defer lock.Unlock()
+
+	if a.Pull != nil {
+		if err := a.Pull(ctx); err != nil {
+			a.Output.Warn("Failed to update playbook, running the current version: %v", err)
+		}
+	}
+
+	result, err := a.Run(ctx)
+	if err != nil {
+		a.Output.Error("Run failed: %v", err)
+		result = nil
+	}
+	a.Metrics.Record(result)
+}
+
+// writeMetrics writes the metrics file, if any.
+func (a *Agent) writeMetrics() {
+	if a.MetricsFile == "" {
+		return
+	}
+	if err := a.Metrics.WriteFile(a.MetricsFile); err != nil {
+		a.Output.Warn("Failed to write metrics: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/executor"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/runlock"
+)
+
+func TestParseCron(t *testing.T) {
+	// Wednesday
+	from := time.Date(2024, 1, 10, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 10, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 10, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17 * * 1-5", time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 1 * 5", time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) expected error", expr)
+		}
+	}
+
+	if s, _ := ParseCron("0 0 31 2 *"); !s.Next(from).IsZero() {
+		t.Error("expected no next run for February 31")
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	dir := t.TempDir()
+	var pulled, ran int
+	a := &Agent{
+		Pull: func(ctx context.Context) error {
+			pulled++
+			return errors.New("network down")
+		},
+		Run: func(ctx context.Context) (*executor.RunResult, error) {
+			ran++
+			return &executor.RunResult{
+				Success: true,
+				Stats:   &executor.Stats{OK: 3, Changed: 2},
+			}, nil
+		},
+		LockFile:    filepath.Join(dir, "agent.lock"),
+		Metrics:     NewMetrics(),
+		MetricsFile: filepath.Join(dir, "bolt.prom"),
+		Output:      output.New(io.Discard),
+	}
+
+	a.RunOnce(context.Background())
+	if pulled != 1 || ran != 1 {
+		t.Fatalf("pulled=%d ran=%d, want a run despite the failed pull", pulled, ran)
+	}
+
+	// A run holding the lock makes the next one skip
+	lock, err := runlock.File(a.LockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.RunOnce(context.Background())
+	lock.Unlock()
+	if ran != 1 {
+		t.Errorf("ran=%d, want the locked run skipped", ran)
+	}
+
+	var buf bytes.Buffer
+	a.Metrics.WriteTo(&buf)
+	for _, want := range []string{
+		`bolt_agent_runs_total{result="success"} 1`,
+		`bolt_agent_runs_total{result="skipped"} 1`,
+		`bolt_agent_drift_runs_total 1`,
+		`bolt_agent_last_run_success 1`,
+		`bolt_agent_last_run_tasks{status="changed"} 2`,
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := 0
+	a := &Agent{
+		Schedule:  Every(time.Millisecond),
+		Immediate: true,
+		Run: func(ctx context.Context) (*executor.RunResult, error) {
+			runs++
+			if runs == 3 {
+				cancel()
+			}
+			return nil, errors.New("parse error")
+		},
+		LockFile: filepath.Join(t.TempDir(), "agent.lock"),
+		Metrics:  NewMetrics(),
+		Output:   output.New(io.Discard),
+	}
+
+	done := make(chan error)
+	go func() { done <- a.Start(ctx) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start() did not stop when canceled")
+	}
+	if runs != 3 {
+		t.Errorf("runs = %d, want 3", runs)
+	}
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/executor"
+)
+
+// Metrics are the counters of an agent, exposed in the Prometheus text
+// format. They are safe for concurrent use.
+type Metrics struct {
+	mu sync.Mutex
+
+	// runs counts runs by result: success, failure, or skipped.
+	runs map[string]int
+
+	// driftRuns counts runs that changed something (or, in drift
+	// detection mode, would have).
+	driftRuns int
+
+	// last is the result of the last completed run.
+	last         *executor.Stats
+	lastSuccess  bool
+	lastFinished time.Time
+}
+
+// NewMetrics creates empty metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{runs: map[string]int{"success": 0, "failure": 0, "skipped": 0}}
+}
+
+// Record adds the result of a run. A nil result is a run that could not
+// start, e.g., because the playbook failed to parse.
+func (m *Metrics) Record(result *executor.RunResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastFinished = time.Now()
+	if result == nil {
+		m.runs["failure"]++
+		m.lastSuccess = false
+		m.last = nil
+		return
+	}
+
+	if result.Success {
+		m.runs["success"]++
+	} else {
+		m.runs["failure"]++
+	}
+	if result.Stats.Changed > 0 {
+		m.driftRuns++
+	}
+	m.lastSuccess = result.Success
+	m.last = result.Stats
+}
+
+// Skipped counts a run that was skipped because another run held the
+// lock.
+func (m *Metrics) Skipped() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs["skipped"]++
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	metric("bolt_agent_runs_total", "counter", "Runs by result.")
+	for _, result := range []string{"success", "failure", "skipped"} {
+		fmt.Fprintf(&b, "bolt_agent_runs_total{result=%q} %d\n", result, m.runs[result])
+	}
+
+	metric("bolt_agent_drift_runs_total", "counter", "Runs that found tasks to change.")
+	fmt.Fprintf(&b, "bolt_agent_drift_runs_total %d\n", m.driftRuns)
+
+	if !m.lastFinished.IsZero() {
+		metric("bolt_agent_last_run_timestamp_seconds", "gauge", "When the last run ended.")
+		fmt.Fprintf(&b, "bolt_agent_last_run_timestamp_seconds %d\n", m.lastFinished.Unix())
+
+		metric("bolt_agent_last_run_success", "gauge", "Whether the last run succeeded.")
+		fmt.Fprintf(&b, "bolt_agent_last_run_success %d\n", boolGauge(m.lastSuccess))
+	}
+
+	if m.last != nil {
+		metric("bolt_agent_last_run_duration_seconds", "gauge", "How long the last run took.")
+		fmt.Fprintf(&b, "bolt_agent_last_run_duration_seconds %g\n", m.last.Duration().Seconds())
+
+		metric("bolt_agent_last_run_tasks", "gauge", "Tasks of the last run by status; changed tasks are drift.")
+		for _, s := range []struct {
+			status string
+			n      int
+		}{
+			{"ok", m.last.OK},
+			{"changed", m.last.Changed},
+			{"failed", m.last.Failed},
+			{"skipped", m.last.Skipped},
+		} {
+			fmt.Fprintf(&b, "bolt_agent_last_run_tasks{status=%q} %d\n", s.status, s.n)
+		}
+	}
+
+	return b.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics for Prometheus to scrape.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteFile writes the metrics to path atomically, for the textfile
+// collector of the Prometheus node exporter.
+func (m *Metrics) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".bolt-metrics-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := m.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// boolGauge returns 1 for true and 0 for false.
+func boolGauge(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
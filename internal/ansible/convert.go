@@ -0,0 +1,676 @@
+// Package ansible converts Ansible playbooks to bolt playbooks.
+//
+// Conversion works on the YAML tree, so key order and comments survive.
+// Constructs with a bolt equivalent are rewritten (module names, loop and
+// service syntax, fact names); directives without one are removed; and
+// anything that needs a human is left in place. Each of these is reported
+// as a Note so the user knows what to review.
+package ansible
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// Note describes something the converter changed or could not convert.
+type Note struct {
+	// Line is the line in the Ansible playbook the note refers to.
+	Line int
+
+	// Message explains the change or the problem.
+	Message string
+
+	// Unsupported is set if the playbook needs manual changes before bolt
+	// can run it.
+	Unsupported bool
+}
+
+func (n Note) String() string {
+	return fmt.Sprintf("line %d: %s", n.Line, n.Message)
+}
+
+// Result holds a converted playbook.
+type Result struct {
+	// Playbook is the converted playbook as YAML.
+	Playbook []byte
+
+	// Notes lists changes and problems, ordered by line.
+	Notes []Note
+}
+
+// Unsupported returns the number of notes that need manual changes.
+func (r *Result) Unsupported() int {
+	n := 0
+	for _, note := range r.Notes {
+		if note.Unsupported {
+			n++
+		}
+	}
+	return n
+}
+
+// converter holds the state of a single conversion.
+type converter struct {
+	// supported reports whether bolt has a module with the given name.
+	supported func(name string) bool
+
+	notes []Note
+}
+
+// Convert converts an Ansible playbook. supported reports whether bolt has
+// a module with the given name; modules it rejects are reported.
+func Convert(data []byte, supported func(name string) bool) (*Result, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid playbook: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("playbook is empty")
+	}
+
+	c := &converter{supported: supported}
+	root := doc.Content[0]
+
+	switch root.Kind {
+	case yaml.SequenceNode:
+		for _, play := range root.Content {
+			if play.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: play is not a mapping", play.Line)
+			}
+			if err := c.convertPlay(play); err != nil {
+				return nil, err
+			}
+		}
+	case yaml.MappingNode:
+		if err := c.convertPlay(root); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("playbook must be a list of plays")
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode playbook: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode playbook: %w", err)
+	}
+
+	sort.SliceStable(c.notes, func(i, j int) bool {
+		return c.notes[i].Line < c.notes[j].Line
+	})
+	return &Result{Playbook: buf.Bytes(), Notes: c.notes}, nil
+}
+
+// note records a change.
+func (c *converter) note(node *yaml.Node, format string, args ...any) {
+	c.notes = append(c.notes, Note{Line: node.Line, Message: fmt.Sprintf(format, args...)})
+}
+
+// unsupported records something that needs manual changes.
+func (c *converter) unsupported(node *yaml.Node, format string, args ...any) {
+	c.notes = append(c.notes, Note{Line: node.Line, Message: fmt.Sprintf(format, args...), Unsupported: true})
+}
+
+// droppedPlayKeys are play keywords without a bolt equivalent that are safe
+// to remove.
+var droppedPlayKeys = map[string]string{
+	"serial":              "lists of batch sizes are not supported; plays always run on one host at a time",
+	"strategy":            "plays always run tasks in order",
+	"any_errors_fatal":    "a failed task always stops the play",
+	"max_fail_percentage": "a failed task always stops the play",
+	"collections":         "Ansible collections have no bolt equivalent",
+	"environment":         "environment is not supported, except for proxy settings",
+}
+
+// convertPlay converts a play in place.
+func (c *converter) convertPlay(play *yaml.Node) error {
+	var tasks, preTasks, postTasks *yaml.Node
+
+	for i := 0; i < len(play.Content); i += 2 {
+		key, value := play.Content[i], play.Content[i+1]
+
+		if key.Value == "environment" && c.convertEnvironment(key, value) {
+			continue
+		}
+		if key.Value == "serial" && c.convertSerial(key, value) {
+			continue
+		}
+		if reason, ok := droppedPlayKeys[key.Value]; ok {
+			c.note(key, "removed '%s': %s", key.Value, reason)
+			play.Content = append(play.Content[:i], play.Content[i+2:]...)
+			i -= 2
+			continue
+		}
+
+		switch key.Value {
+		case "become", "gather_facts", "force_handlers", "ignore_unreachable":
+			c.convertBool(value)
+		case "roles":
+			c.convertRoles(value)
+		case "vars_files", "vars_prompt":
+			c.unsupported(key, "'%s' is not supported; move the variables into 'vars'", key.Value)
+		case "tasks":
+			tasks = value
+		case "pre_tasks":
+			preTasks = value
+			c.note(key, "merged 'pre_tasks' into 'tasks'")
+		case "post_tasks":
+			postTasks = value
+			c.note(key, "merged 'post_tasks' into 'tasks'")
+		case "handlers":
+			c.convertTasks(value)
+		case "hosts":
+			if !hasKey(play, "connection") && value.Value != "localhost" && value.Value != "127.0.0.1" {
+				c.unsupported(key, "bolt connects locally unless 'connection' is set; add 'connection: docker' or another connector for host '%s'", value.Value)
+			}
+		}
+	}
+
+	// bolt has a single task list: pre_tasks, tasks, post_tasks
+	if preTasks != nil || postTasks != nil {
+		merged := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, list := range []*yaml.Node{preTasks, tasks, postTasks} {
+			if list != nil && list.Kind == yaml.SequenceNode {
+				merged.Content = append(merged.Content, list.Content...)
+			}
+		}
+		removeKey(play, "pre_tasks")
+		removeKey(play, "post_tasks")
+		if tasks == nil {
+			play.Content = append(play.Content, scalar("tasks"), merged)
+		} else {
+			*tasks = *merged
+		}
+		tasks = merged
+	}
+
+	if tasks != nil {
+		c.convertTasks(tasks)
+	}
+	return nil
+}
+
+// convertRoles turns role entries with parameters into plain role names.
+func (c *converter) convertRoles(roles *yaml.Node) {
+	if roles.Kind != yaml.SequenceNode {
+		return
+	}
+	for i, role := range roles.Content {
+		if role.Kind != yaml.MappingNode {
+			continue
+		}
+		name := mappingValue(role, "role")
+		if name == nil {
+			name = mappingValue(role, "name")
+		}
+		if name == nil || name.Kind != yaml.ScalarNode {
+			c.unsupported(role, "role entry without a name")
+			continue
+		}
+		if len(role.Content) > 2 {
+			c.unsupported(role, "role parameters of '%s' are not supported; set them as play vars", name.Value)
+		}
+		roles.Content[i] = scalar(name.Value)
+	}
+}
+
+// taskDirectives are Ansible task keywords bolt supports as they are.
+var taskDirectives = map[string]bool{
+	"name":               true,
+	"when":               true,
+	"register":           true,
+	"notify":             true,
+	"listen":             true,
+	"loop":               true,
+	"loop_var":           true,
+	"loop_parallel":      true,
+	"with_items":         true,
+	"with_first_found":   true,
+	"ignore_errors":      true,
+	"ignore_unreachable": true,
+	"retries":            true,
+	"delay":              true,
+	"become":             true,
+	"become_user":        true,
+	"changed_when":       true,
+	"failed_when":        true,
+	"creates":            true,
+	"removes":            true,
+	"vars":               true,
+	"proxy":              true,
+}
+
+// convertEnvironment turns an environment that only sets proxy variables
+// into bolt's proxy setting and reports whether it did.
+func (c *converter) convertEnvironment(key, env *yaml.Node) bool {
+	if env.Kind != yaml.MappingNode || len(env.Content) == 0 {
+		return false
+	}
+	proxy := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	seen := map[string]bool{}
+	for i := 0; i < len(env.Content); i += 2 {
+		name := strings.ToLower(env.Content[i].Value)
+		switch name {
+		case "http_proxy", "https_proxy", "no_proxy":
+		default:
+			return false
+		}
+		if !seen[name] {
+			seen[name] = true
+			proxy.Content = append(proxy.Content, scalar(name), env.Content[i+1])
+		}
+	}
+	c.note(key, "converted 'environment' to 'proxy'")
+	key.Value = "proxy"
+	*env = *proxy
+	return true
+}
+
+// convertSerial turns a play's serial batch size into an update_strategy.
+// It reports false for lists of batch sizes, which bolt does not support.
+func (c *converter) convertSerial(key, serial *yaml.Node) bool {
+	if serial.Kind != yaml.ScalarNode {
+		return false
+	}
+	c.note(key, "converted 'serial' to 'update_strategy'; move delegated pre_tasks and post_tasks to its pre_batch and post_batch")
+	key.Value = "update_strategy"
+	*serial = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{scalar("batch_size"), {Kind: yaml.ScalarNode, Value: serial.Value}}}
+	return true
+}
+
+// droppedTaskKeys are task keywords without a bolt equivalent that are safe
+// to remove.
+var droppedTaskKeys = map[string]string{
+	"no_log":        "no_log is not supported",
+	"check_mode":    "check_mode is not supported",
+	"diff":          "diff is not supported",
+	"environment":   "environment is not supported, except for proxy settings",
+	"become_method": "bolt always uses sudo",
+}
+
+// unsupportedTaskKeys are task keywords that change behavior and need
+// manual attention.
+var unsupportedTaskKeys = map[string]string{
+	"block":         "blocks are not supported; move the tasks to the task list",
+	"rescue":        "blocks are not supported",
+	"always":        "blocks are not supported",
+	"include_tasks": "including task files is not supported; use a role",
+	"import_tasks":  "importing task files is not supported; use a role",
+	"include_role":  "including roles from tasks is not supported; list the role under 'roles'",
+	"import_role":   "importing roles from tasks is not supported; list the role under 'roles'",
+	"delegate_to":   "delegation is not supported",
+	"local_action":  "local_action is not supported; use a play with connection: local",
+	"run_once":      "run_once is not supported",
+	"until":         "until is not supported; use retries with failed_when set to the opposite condition",
+	"async":         "async tasks are not supported",
+	"poll":          "async tasks are not supported",
+}
+
+// convertTasks converts a list of tasks in place.
+func (c *converter) convertTasks(list *yaml.Node) {
+	if list.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, task := range list.Content {
+		if task.Kind == yaml.MappingNode {
+			c.convertTask(task)
+		}
+	}
+}
+
+// convertTask converts a task in place.
+func (c *converter) convertTask(task *yaml.Node) {
+	var module, params, args *yaml.Node
+	block := false
+
+	for i := 0; i < len(task.Content); i += 2 {
+		key, value := task.Content[i], task.Content[i+1]
+
+		if key.Value == "environment" && c.convertEnvironment(key, value) {
+			continue
+		}
+		if reason, ok := droppedTaskKeys[key.Value]; ok {
+			c.note(key, "removed '%s': %s", key.Value, reason)
+			task.Content = append(task.Content[:i], task.Content[i+2:]...)
+			i -= 2
+			continue
+		}
+		if reason, ok := unsupportedTaskKeys[key.Value]; ok {
+			c.unsupported(key, "'%s': %s", key.Value, reason)
+			block = block || key.Value == "block"
+			continue
+		}
+
+		switch {
+		case key.Value == "args":
+			args = value
+		case key.Value == "loop_control":
+			c.convertLoopControl(task, i)
+		case key.Value == "become" || key.Value == "ignore_errors" || key.Value == "ignore_unreachable":
+			c.convertBool(value)
+		case key.Value == "changed_when" || key.Value == "failed_when":
+			c.convertCondition(value)
+		case key.Value == "when":
+			c.convertCondition(value)
+		case key.Value == "loop" || key.Value == "with_items":
+			if value.Kind != yaml.SequenceNode {
+				c.unsupported(key, "'%s' must be a literal list in bolt", key.Value)
+			}
+		case key.Value == "with_first_found":
+			if !scalarList(value) {
+				c.unsupported(key, "'with_first_found' must be a literal list of files in bolt")
+			}
+		case strings.HasPrefix(key.Value, "with_"):
+			c.unsupported(key, "'%s' is not supported; use loop with a literal list", key.Value)
+		case !taskDirectives[key.Value]:
+			module, params = key, value
+		}
+	}
+
+	// Rewrite facts referenced anywhere in the task
+	renameFacts(task)
+
+	if module == nil {
+		if !block {
+			c.unsupported(task, "task has no module")
+		}
+		return
+	}
+
+	if args != nil {
+		c.mergeArgs(task, params, args)
+	}
+
+	c.convertModule(task, module, params)
+}
+
+// convertLoopControl replaces loop_control.loop_var with loop_var.
+func (c *converter) convertLoopControl(task *yaml.Node, i int) {
+	key, value := task.Content[i], task.Content[i+1]
+	loopVar := mappingValue(value, "loop_var")
+	if loopVar == nil || len(value.Content) > 2 {
+		c.unsupported(key, "only 'loop_control.loop_var' is supported")
+		return
+	}
+	key.Value = "loop_var"
+	task.Content[i+1] = loopVar
+}
+
+// mergeArgs moves the keys of a task's args into the module parameters.
+func (c *converter) mergeArgs(task, params, args *yaml.Node) {
+	if args.Kind != yaml.MappingNode {
+		return
+	}
+	if params.Kind == yaml.ScalarNode && params.Value != "" {
+		// Free-form command: cmd plus args
+		cmd := params.Value
+		params.Kind = yaml.MappingNode
+		params.Tag = "!!map"
+		params.Value = ""
+		params.Style = 0
+		params.Content = []*yaml.Node{scalar("cmd"), scalar(cmd)}
+	}
+	if params.Kind != yaml.MappingNode {
+		return
+	}
+	params.Content = append(params.Content, args.Content...)
+	removeKey(task, "args")
+}
+
+// convertModule rewrites the module name and parameters.
+func (c *converter) convertModule(task, module, params *yaml.Node) {
+	original := module.Value
+	name := playbook.ModuleName(original)
+
+	switch name {
+	case "service", "systemd", "systemd_service":
+		c.convertService(module, params)
+		return
+	case "apt":
+		renameKey(params, "pkg", "name")
+	case "copy", "template":
+		if v := mappingValue(params, "remote_src"); v != nil {
+			c.unsupported(module, "'remote_src' is not supported; bolt copies files from the controller")
+		}
+	}
+
+	if name != original {
+		c.note(module, "module '%s' is '%s' in bolt", original, name)
+		module.Value = name
+	}
+
+	if !c.supported(name) {
+		c.unsupported(module, "module '%s' has no bolt equivalent", original)
+		return
+	}
+
+	// Ansible's yes/no are strings in YAML 1.2
+	if params.Kind == yaml.MappingNode {
+		for i := 1; i < len(params.Content); i += 2 {
+			c.convertBool(params.Content[i])
+		}
+	}
+}
+
+// convertService turns a service or systemd task into a systemctl command.
+// bolt has no service module; the command reports a change on every run.
+func (c *converter) convertService(module, params *yaml.Node) {
+	nameNode := mappingValue(params, "name")
+	if nameNode == nil {
+		c.unsupported(module, "'%s' task without a name", module.Value)
+		return
+	}
+	name := nameNode.Value
+
+	var steps []string
+	if v := mappingValue(params, "daemon_reload"); v != nil {
+		c.convertBool(v)
+		if v.Value == "true" {
+			steps = append(steps, "systemctl daemon-reload")
+		}
+	}
+
+	state := ""
+	if v := mappingValue(params, "state"); v != nil {
+		state = v.Value
+	}
+	enabled := ""
+	if v := mappingValue(params, "enabled"); v != nil {
+		c.convertBool(v)
+		enabled = v.Value
+	}
+
+	switch {
+	case enabled == "true" && state == "started":
+		steps = append(steps, "systemctl enable --now "+name)
+		state = ""
+	case enabled == "true":
+		steps = append(steps, "systemctl enable "+name)
+	case enabled == "false":
+		steps = append(steps, "systemctl disable "+name)
+	}
+
+	switch state {
+	case "":
+	case "started":
+		steps = append(steps, "systemctl start "+name)
+	case "stopped":
+		steps = append(steps, "systemctl stop "+name)
+	case "restarted":
+		steps = append(steps, "systemctl restart "+name)
+	case "reloaded":
+		steps = append(steps, "systemctl reload "+name)
+	default:
+		c.unsupported(module, "unknown service state '%s'", state)
+		return
+	}
+
+	if len(steps) == 0 {
+		c.unsupported(module, "'%s' task has nothing to do", module.Value)
+		return
+	}
+
+	c.note(module, "converted '%s' to a systemctl command, which reports a change on every run", module.Value)
+	module.Value = "command"
+	*params = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		scalar("cmd"), scalar(strings.Join(steps, " && ")),
+	}}
+}
+
+// convertBool turns Ansible's yes/no strings into YAML booleans.
+func (c *converter) convertBool(node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode || node.Tag != "!!str" || node.Style != 0 {
+		return
+	}
+	switch strings.ToLower(node.Value) {
+	case "yes", "true", "on":
+		node.Value, node.Tag = "true", "!!bool"
+	case "no", "false", "off":
+		node.Value, node.Tag = "false", "!!bool"
+	}
+}
+
+// convertCondition turns a when, changed_when, or failed_when condition
+// into bolt's single string form.
+func (c *converter) convertCondition(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!bool" {
+			// changed_when: false
+			node.Tag = "!!str"
+			node.Style = 0
+			return
+		}
+		expr := strings.TrimSpace(node.Value)
+		if strings.HasPrefix(expr, "{{") && strings.HasSuffix(expr, "}}") {
+			node.Value = strings.TrimSpace(expr[2 : len(expr)-2])
+		}
+		for _, op := range conditionOperators {
+			if strings.Contains(" "+node.Value+" ", op) {
+				c.unsupported(node, "condition '%s' uses '%s', which bolt does not support", node.Value, strings.TrimSpace(op))
+				return
+			}
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) == 1 && node.Content[0].Kind == yaml.ScalarNode {
+			*node = *node.Content[0]
+			c.convertCondition(node)
+			return
+		}
+		c.unsupported(node, "a list of conditions is not supported; bolt evaluates a single condition")
+	}
+}
+
+// conditionOperators are Jinja2 operators bolt conditions lack. bolt
+// supports truthiness, ==, !=, in, not in, not, and .changed.
+var conditionOperators = []string{" and ", " or ", " is ", "|"}
+
+// factNames maps Ansible fact names to bolt's.
+var factNames = map[string]string{
+	"os_family":            "facts.os_family",
+	"distribution":         "facts.distribution",
+	"distribution_version": "facts.distribution_version",
+	"system":               "facts.os_type",
+	"architecture":         "facts.architecture",
+	"kernel":               "facts.kernel",
+	"hostname":             "facts.hostname",
+	"user_id":              "facts.user",
+	"user_dir":             "facts.home",
+	"pkg_mgr":              "facts.pkg_manager",
+	"service_mgr":          "facts.service_mgr",
+	"virtualization_type":  "facts.virtualization_type",
+	"env":                  "env",
+}
+
+// factPattern matches ansible_facts['name'], ansible_facts.name, and
+// ansible_name.
+var factPattern = regexp.MustCompile(`ansible_facts\[['"](\w+)['"]\]|ansible_facts\.(\w+)|\bansible_(\w+)`)
+
+// renameFacts rewrites Ansible fact references in every string below node.
+func renameFacts(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		if node.Tag == "!!str" && strings.Contains(node.Value, "ansible_") {
+			node.Value = factPattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+				m := factPattern.FindStringSubmatch(match)
+				name := m[1] + m[2] + m[3]
+				if bolt, ok := factNames[name]; ok {
+					return bolt
+				}
+				return match
+			})
+		}
+		return
+	}
+	for _, child := range node.Content {
+		renameFacts(child)
+	}
+}
+
+// hasKey reports whether a mapping has the key.
+func hasKey(m *yaml.Node, key string) bool {
+	return mappingValue(m, key) != nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// removeKey deletes key from a mapping node.
+func removeKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// renameKey renames a key of a mapping node.
+func renameKey(m *yaml.Node, from, to string) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == from {
+			m.Content[i].Value = to
+		}
+	}
+}
+
+// scalar returns a plain string node.
+func scalar(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// scalarList reports whether node is a list of scalars.
+func scalarList(node *yaml.Node) bool {
+	if node.Kind != yaml.SequenceNode {
+		return false
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,278 @@
+package ansible
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// supported accepts the modules the tests use.
+func supported(name string) bool {
+	switch name {
+	case "apt", "command", "copy", "template", "file":
+		return true
+	}
+	return false
+}
+
+func convert(t *testing.T, src string) *Result {
+	t.Helper()
+	res, err := Convert([]byte(src), supported)
+	if err != nil {
+		t.Fatalf("Convert() error: %v", err)
+	}
+	return res
+}
+
+// parse checks that the converted playbook is valid bolt syntax.
+func parse(t *testing.T, res *Result) *playbook.Play {
+	t.Helper()
+	pb, err := playbook.ParseRaw(res.Playbook, "converted.yaml")
+	if err != nil {
+		t.Fatalf("converted playbook does not parse: %v\n%s", err, res.Playbook)
+	}
+	return pb.Plays[0]
+}
+
+func hasNote(res *Result, substr string, unsupported bool) bool {
+	for _, n := range res.Notes {
+		if strings.Contains(n.Message, substr) && n.Unsupported == unsupported {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConvertModules(t *testing.T) {
+	res := convert(t, `
+- hosts: localhost
+  become: yes
+  tasks:
+    - name: Install nginx
+      ansible.builtin.apt:
+        pkg: nginx
+        update_cache: yes
+    - name: Configure nginx
+      ansible.builtin.template:
+        src: nginx.conf.j2
+        dest: /etc/nginx/nginx.conf
+      notify: restart nginx
+    - name: Run script
+      shell: ./setup.sh
+      args:
+        chdir: /opt/app
+  handlers:
+    - name: restart nginx
+      ansible.builtin.service:
+        name: nginx
+        state: restarted
+`)
+	play := parse(t, res)
+
+	if !play.Become {
+		t.Error("expected become: yes to convert to true")
+	}
+
+	apt := play.Tasks[0]
+	if apt.Module != "apt" || apt.Params["name"] != "nginx" || apt.Params["update_cache"] != true {
+		t.Errorf("apt task = %s %v", apt.Module, apt.Params)
+	}
+
+	if play.Tasks[1].Module != "template" {
+		t.Errorf("expected template, got %s", play.Tasks[1].Module)
+	}
+
+	script := play.Tasks[2]
+	if script.Module != "command" || script.Params["cmd"] != "./setup.sh" || script.Params["chdir"] != "/opt/app" {
+		t.Errorf("shell task = %s %v", script.Module, script.Params)
+	}
+
+	handler := play.Handlers[0]
+	if handler.Module != "command" || handler.Params["cmd"] != "systemctl restart nginx" {
+		t.Errorf("service handler = %s %v", handler.Module, handler.Params)
+	}
+	if !hasNote(res, "systemctl", false) {
+		t.Error("expected a note about the service conversion")
+	}
+	if res.Unsupported() != 0 {
+		t.Errorf("expected no unsupported notes, got %v", res.Notes)
+	}
+}
+
+func TestConvertService(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		want   string
+	}{
+		{"start and enable", "state: started\n        enabled: yes", "systemctl enable --now app"},
+		{"stop", "state: stopped", "systemctl stop app"},
+		{"reload units", "state: restarted\n        daemon_reload: true", "systemctl daemon-reload && systemctl restart app"},
+		{"disable", "enabled: no", "systemctl disable app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := convert(t, `
+- hosts: localhost
+  tasks:
+    - systemd:
+        name: app
+        `+tt.params+`
+`)
+			task := parse(t, res).Tasks[0]
+			if task.Params["cmd"] != tt.want {
+				t.Errorf("cmd = %v, want %q", task.Params["cmd"], tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertDirectives(t *testing.T) {
+	res := convert(t, `
+- hosts: localhost
+  serial: 1
+  pre_tasks:
+    - name: First
+      command: echo first
+  tasks:
+    - name: Second
+      command: echo {{ item }}
+      loop: [a, b]
+      loop_control:
+        loop_var: pkg
+      tags: [setup]
+      when: "{{ ansible_os_family == 'Debian' }}"
+      changed_when: false
+`)
+	play := parse(t, res)
+
+	if len(play.Tasks) != 2 || play.Tasks[0].Name != "First" {
+		t.Fatalf("expected pre_tasks merged first, got %d tasks", len(play.Tasks))
+	}
+
+	if play.UpdateStrategy == nil || play.UpdateStrategy.BatchSize != "1" {
+		t.Errorf("update_strategy = %+v, want batch_size 1 from serial", play.UpdateStrategy)
+	}
+
+	task := play.Tasks[1]
+	if task.LoopVar != "pkg" {
+		t.Errorf("loop_var = %q, want pkg", task.LoopVar)
+	}
+	if task.When != "facts.os_family == 'Debian'" {
+		t.Errorf("when = %q", task.When)
+	}
+	if task.ChangedWhen != "false" {
+		t.Errorf("changed_when = %q, want false", task.ChangedWhen)
+	}
+	if len(task.Tags) != 1 || task.Tags[0] != "setup" {
+		t.Errorf("tags = %v, want [setup]", task.Tags)
+	}
+
+	for _, want := range []string{"'serial'", "pre_tasks"} {
+		if !hasNote(res, want, false) {
+			t.Errorf("expected a note mentioning %s, got %v", want, res.Notes)
+		}
+	}
+}
+
+func TestConvertEnvironment(t *testing.T) {
+	res := convert(t, `
+- hosts: localhost
+  environment:
+    http_proxy: http://proxy.corp:3128
+    HTTP_PROXY: http://proxy.corp:3128
+  tasks:
+    - command: make
+      environment:
+        CC: clang
+`)
+	play := parse(t, res)
+
+	if play.Proxy == nil || play.Proxy.HTTP != "http://proxy.corp:3128" {
+		t.Errorf("proxy = %+v, want the environment's http_proxy", play.Proxy)
+	}
+	if play.Tasks[0].Proxy != nil {
+		t.Errorf("task proxy = %+v, want none", play.Tasks[0].Proxy)
+	}
+	if !hasNote(res, "converted 'environment' to 'proxy'", false) || !hasNote(res, "removed 'environment'", false) {
+		t.Errorf("notes = %v", res.Notes)
+	}
+}
+
+func TestConvertFacts(t *testing.T) {
+	res := convert(t, `
+- hosts: localhost
+  tasks:
+    - command: echo {{ ansible_facts['distribution'] }} {{ ansible_env.HOME }} {{ ansible_facts.architecture }} {{ ansible_check_mode }}
+`)
+	task := parse(t, res).Tasks[0]
+	want := "echo {{ facts.distribution }} {{ env.HOME }} {{ facts.architecture }} {{ ansible_check_mode }}"
+	if task.Params["_raw"] != want {
+		t.Errorf("got %q, want %q", task.Params["_raw"], want)
+	}
+}
+
+func TestConvertUnsupported(t *testing.T) {
+	res := convert(t, `
+- hosts: webservers
+  vars_files:
+    - vars.yml
+  tasks:
+    - name: Fetch
+      ansible.builtin.get_url:
+        url: https://example.com/app.tar.gz
+        dest: /tmp/app.tar.gz
+    - block:
+        - command: echo hi
+    - command: echo hi
+      when: a is defined and b
+    - copy:
+        src: "{{ item }}"
+        dest: /etc/app.conf
+      with_first_found:
+        - files: [app.conf]
+          paths: [files]
+`)
+
+	for _, want := range []string{"connection", "vars_files", "get_url", "blocks", "'and'", "with_first_found"} {
+		if !hasNote(res, want, true) {
+			t.Errorf("expected an unsupported note mentioning %s, got %v", want, res.Notes)
+		}
+	}
+	if res.Unsupported() != 6 {
+		t.Errorf("Unsupported() = %d, want 6", res.Unsupported())
+	}
+
+	for i := 1; i < len(res.Notes); i++ {
+		if res.Notes[i].Line < res.Notes[i-1].Line {
+			t.Errorf("notes not sorted by line: %v", res.Notes)
+		}
+	}
+}
+
+func TestConvertKeepsComments(t *testing.T) {
+	res := convert(t, `
+# Web servers
+- hosts: localhost
+  tasks:
+    # Install the web server
+    - ansible.builtin.apt:
+        name: nginx
+`)
+	out := string(res.Playbook)
+	for _, want := range []string{"# Web servers", "# Install the web server", "apt:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	for _, src := range []string{"", "just a string", "- [1, 2]", "key: [unclosed"} {
+		if _, err := Convert([]byte(src), supported); err == nil {
+			t.Errorf("Convert(%q) expected error", src)
+		}
+	}
+}
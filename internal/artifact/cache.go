@@ -0,0 +1,265 @@
+// Package artifact caches downloads on the machine running bolt, so an
+// artifact that many hosts need, such as a release tarball or a .deb, is
+// downloaded from the internet once and then uploaded to each host over its
+// connection.
+//
+// Artifacts with a checksum are kept across runs, keyed by URL and
+// checksum. Artifacts without one may change behind their URL, so they are
+// only reused within a run.
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout bounds how long a single download may take.
+const Timeout = 30 * time.Minute
+
+// Cache is a directory of downloaded artifacts. It is safe for concurrent
+// use: hosts fetching the same artifact at once wait for one download.
+type Cache struct {
+	// Dir is the cache directory. If empty, DefaultDir is used.
+	Dir string
+
+	// Client downloads artifacts. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	mu sync.Mutex
+	// keys serializes fetches of the same artifact.
+	keys map[string]*sync.Mutex
+	// fetched holds the artifacts without checksum fetched by this
+	// process.
+	fetched map[string]bool
+}
+
+// Default is the cache used by modules.
+var Default = &Cache{}
+
+// ErrNotCached is returned by Lookup for an artifact missing from the
+// cache.
+var ErrNotCached = errors.New("artifact not in cache")
+
+// Fetch downloads url into the default cache, unless it is there already,
+// and returns the path of the artifact and its SHA256 checksum.
+func Fetch(ctx context.Context, url, checksum string) (string, string, error) {
+	return Default.Fetch(ctx, url, checksum)
+}
+
+// Lookup returns the path and SHA256 checksum of url in the default cache,
+// without downloading it.
+func Lookup(url, checksum string) (string, string, error) {
+	return Default.Lookup(url, checksum)
+}
+
+// DefaultDir returns the default cache directory, bolt/artifacts in the
+// user cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bolt", "artifacts"), nil
+}
+
+// ParseChecksum returns the hex SHA256 digest of a checksum given as
+// "sha256:<hex>" or "<hex>". An empty checksum is valid and returns "".
+func ParseChecksum(checksum string) (string, error) {
+	if checksum == "" {
+		return "", nil
+	}
+	digest := strings.ToLower(strings.TrimPrefix(checksum, "sha256:"))
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid checksum %q: expected sha256:<64 hex digits>", checksum)
+	}
+	return digest, nil
+}
+
+// Fetch downloads url into the cache, unless it is there already, and
+// returns the path of the artifact and its SHA256 checksum. If checksum is
+// set, the download must match it.
+func (c *Cache) Fetch(ctx context.Context, url, checksum string) (string, string, error) {
+	want, file, key, err := c.locate(url, checksum)
+	if err != nil {
+		return "", "", err
+	}
+
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if got, ok := c.cached(file, key, want); ok {
+		return file, got, nil
+	}
+
+	got, err := c.download(ctx, url, file)
+	if err != nil {
+		return "", "", err
+	}
+	if want != "" && got != want {
+		os.Remove(file)
+		return "", "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, want, got)
+	}
+
+	c.mu.Lock()
+	if c.fetched == nil {
+		c.fetched = make(map[string]bool)
+	}
+	c.fetched[key] = true
+	c.mu.Unlock()
+
+	return file, got, nil
+}
+
+// Lookup returns the path of url in the cache and its SHA256 checksum
+// without downloading it, as Fetch would return them. It fails with
+// ErrNotCached if url has not been fetched with checksum before, or, if
+// checksum is empty, not by this process.
+func (c *Cache) Lookup(url, checksum string) (string, string, error) {
+	want, file, key, err := c.locate(url, checksum)
+	if err != nil {
+		return "", "", err
+	}
+
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if got, ok := c.cached(file, key, want); ok {
+		return file, got, nil
+	}
+	return "", "", fmt.Errorf("%w: %s", ErrNotCached, url)
+}
+
+// locate validates url and checksum and returns the wanted digest, the
+// path of the artifact in the cache, and its key.
+func (c *Cache) locate(url, checksum string) (want, file, key string, err error) {
+	if want, err = ParseChecksum(checksum); err != nil {
+		return "", "", "", err
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", "", "", fmt.Errorf("unsupported artifact URL %q: must be http or https", url)
+	}
+
+	dir := c.Dir
+	if dir == "" {
+		if dir, err = DefaultDir(); err != nil {
+			return "", "", "", fmt.Errorf("no artifact cache directory: %w", err)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(url + "\x00" + want))
+	key = hex.EncodeToString(sum[:])
+	name := path.Base(strings.SplitN(url, "?", 2)[0])
+	if name == "/" || name == "." {
+		name = "artifact"
+	}
+	return want, filepath.Join(dir, key[:2], key, name), key, nil
+}
+
+// cached returns the checksum of the cached file of key, if it can be
+// reused: artifacts with a checksum must match it, those without must have
+// been fetched by this process.
+func (c *Cache) cached(file, key, want string) (string, bool) {
+	if want == "" && !c.wasFetched(key) {
+		return "", false
+	}
+	got, err := fileChecksum(file)
+	if err != nil || (want != "" && got != want) {
+		return "", false
+	}
+	return got, true
+}
+
+// lock returns the mutex serializing fetches of key.
+func (c *Cache) lock(key string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]*sync.Mutex)
+	}
+	if c.keys[key] == nil {
+		c.keys[key] = &sync.Mutex{}
+	}
+	return c.keys[key]
+}
+
+// wasFetched reports whether this process fetched key.
+func (c *Cache) wasFetched(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fetched[key]
+}
+
+// download writes the body of url to file atomically and returns its
+// SHA256 checksum.
+func (c *Cache) download(ctx context.Context, url, file string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileChecksum returns the SHA256 checksum of a local file.
+func fileChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
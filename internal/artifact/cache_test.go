@@ -0,0 +1,146 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+// server serves body on every path and counts the requests.
+func server(t *testing.T, body string) (*httptest.Server, *int) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetch(t *testing.T) {
+	srv, requests := server(t, "release")
+	dir := t.TempDir()
+	url := srv.URL + "/app-1.0.tar.gz?token=x"
+	sum := "sha256:" + checksum("release")
+
+	c := &Cache{Dir: dir}
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file, got, err := c.Fetch(context.Background(), url, sum)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !strings.HasSuffix(file, "/app-1.0.tar.gz") || got != checksum("release") {
+				t.Errorf("Fetch() = %s, %s", file, got)
+			}
+		}()
+	}
+	wg.Wait()
+	if *requests != 1 {
+		t.Errorf("requests = %d, want concurrent fetches to share one download", *requests)
+	}
+
+	// Artifacts with a checksum are kept across runs
+	if _, _, err := (&Cache{Dir: dir}).Fetch(context.Background(), url, sum); err != nil || *requests != 1 {
+		t.Errorf("Fetch() error = %v, requests = %d; want the cached artifact", err, *requests)
+	}
+
+	// Artifacts without are only reused within a run
+	c = &Cache{Dir: dir}
+	for range 2 {
+		if _, _, err := c.Fetch(context.Background(), url, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := (&Cache{Dir: dir}).Fetch(context.Background(), url, ""); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 3 {
+		t.Errorf("requests = %d, want 3", *requests)
+	}
+
+	if _, _, err := c.Fetch(context.Background(), srv.URL+"/other", "sha256:"+checksum("other")); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Fetch() error = %v, want checksum mismatch", err)
+	}
+	if _, _, err := c.Fetch(context.Background(), url, "md5:abc"); err == nil {
+		t.Error("expected an error for an invalid checksum")
+	}
+	if _, _, err := c.Fetch(context.Background(), "ftp://example.com/a", ""); err == nil {
+		t.Error("expected an error for an ftp URL")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	srv, requests := server(t, "release")
+	dir := t.TempDir()
+	url := srv.URL + "/app-1.0.tar.gz"
+	sum := "sha256:" + checksum("release")
+
+	c := &Cache{Dir: dir}
+	if _, _, err := c.Lookup(url, sum); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("Lookup() error = %v, want ErrNotCached", err)
+	}
+	fetched, _, err := c.Fetch(context.Background(), url, sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, got, err := (&Cache{Dir: dir}).Lookup(url, sum)
+	if err != nil || file != fetched || got != checksum("release") {
+		t.Errorf("Lookup() = %s, %s, %v; want the fetched artifact", file, got, err)
+	}
+	if _, _, err := (&Cache{Dir: dir}).Lookup(url, ""); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Lookup() error = %v, want ErrNotCached for an artifact without checksum of another run", err)
+	}
+	if *requests != 1 {
+		t.Errorf("requests = %d, want Lookup not to download", *requests)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	srv, requests := server(t, "package")
+	orig := Default
+	Default = &Cache{Dir: t.TempDir()}
+	t.Cleanup(func() { Default = orig })
+
+	fake := connectortest.New()
+	fake.OnPrefix("if command -v sha256sum").Return("").Once()
+	fake.OnPrefix("if command -v sha256sum").Return(checksum("package"))
+
+	for _, want := range []bool{true, false} {
+		uploaded, err := Upload(context.Background(), fake, srv.URL+"/app.deb", "", "/tmp/app.deb", 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uploaded != want {
+			t.Errorf("Upload() = %v, want %v", uploaded, want)
+		}
+	}
+	if content, _ := fake.File("/tmp/app.deb"); string(content) != "package" {
+		t.Errorf("uploaded %q", content)
+	}
+	if *requests != 1 {
+		t.Errorf("requests = %d, want 1", *requests)
+	}
+}
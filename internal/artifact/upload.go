@@ -0,0 +1,47 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+// Upload fetches url into the default cache and uploads it to dst on the
+// target, unless dst already has the same content. It reports whether it
+// uploaded the file.
+func Upload(ctx context.Context, conn connector.Connector, url, checksum, dst string, mode uint32) (bool, error) {
+	file, sum, err := Fetch(ctx, url, checksum)
+	if err != nil {
+		return false, err
+	}
+
+	if current, err := module.RemoteChecksum(ctx, conn, dst); err == nil && current == sum {
+		return false, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if err := conn.Upload(ctx, f, dst, mode); err != nil {
+		return false, fmt.Errorf("failed to upload %s: %w", url, err)
+	}
+
+	// Catch transfers truncated by a flaky connection
+	got, err := module.RemoteChecksum(ctx, conn, dst)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify upload: %w", err)
+	}
+	if got != "" && got != sum {
+		return false, &connector.ConnectivityError{
+			Target: conn.String(),
+			Err:    fmt.Errorf("checksum mismatch after uploading %s: expected %s, got %s", dst, sum, got),
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,89 @@
+// Package config loads bolt.yaml, the project configuration of bolt.
+//
+// bolt.yaml holds settings that belong to a project rather than to a
+// single run, such as where run summaries are sent:
+//
+//	notify:
+//	  - type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	    on: failure
+//
+//	module_defaults:
+//	  apt:
+//	    mirror: http://mirror.example.com
+//
+//	policy:
+//	  - name: no-command-in-production
+//	    hosts: production
+//	    module: command
+//	    deny: true
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/eugenetaranov/bolt/internal/notify"
+	"github.com/eugenetaranov/bolt/internal/policy"
+)
+
+// FileName is the name of the configuration file.
+const FileName = "bolt.yaml"
+
+// Config is the project configuration.
+type Config struct {
+	// Path is the file the configuration was loaded from, or "" if there
+	// was none.
+	Path string `yaml:"-"`
+
+	// Notify are the sinks that receive the summary of every run.
+	Notify []notify.Config `yaml:"notify"`
+
+	// ModuleDefaults holds default parameters per module name for the
+	// tasks of every play, e.g. the package mirror of the apt module. The
+	// module_defaults of a play take precedence.
+	ModuleDefaults map[string]map[string]any `yaml:"module_defaults"`
+
+	// Policy holds the rules the tasks of every run must follow, checked
+	// before any task runs.
+	Policy []policy.Rule `yaml:"policy"`
+}
+
+// Load reads the configuration file at path. Unknown keys are errors, so
+// typos do not silently disable a setting.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg := &Config{Path: path}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := policy.Validate(cfg.Policy); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Find loads the bolt.yaml next to the playbook, or else the one in the
+// current directory. Without either, the configuration is empty.
+func Find(playbookPath string) (*Config, error) {
+	dirs := []string{filepath.Dir(playbookPath), "."}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		}
+	}
+	return &Config{}, nil
+}
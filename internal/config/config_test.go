@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	os.WriteFile(path, []byte(`
+notify:
+  - type: slack
+    url: https://hooks.slack.com/services/x
+    on: failure
+  - type: email
+    smtp: mail.example.com:587
+    from: bolt@example.com
+    to: [ops@example.com]
+module_defaults:
+  apt:
+    mirror: http://mirror.example.com
+policy:
+  - name: no-command
+    module: command
+    deny: true
+`), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Notify) != 2 || cfg.Notify[0].On != "failure" || cfg.Notify[1].To[0] != "ops@example.com" {
+		t.Errorf("Notify = %+v", cfg.Notify)
+	}
+	if cfg.ModuleDefaults["apt"]["mirror"] != "http://mirror.example.com" {
+		t.Errorf("ModuleDefaults = %v", cfg.ModuleDefaults)
+	}
+	if len(cfg.Policy) != 1 || cfg.Policy[0].Module != "command" || !cfg.Policy[0].Deny {
+		t.Errorf("Policy = %+v", cfg.Policy)
+	}
+	if cfg.Path != path {
+		t.Errorf("Path = %q, want %q", cfg.Path, path)
+	}
+
+	os.WriteFile(path, []byte("notfiy: []\n"), 0644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for unknown key")
+	}
+
+	os.WriteFile(path, []byte("policy:\n  - name: vague\n    module: command\n"), 0644)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for a rule that neither denies nor requires")
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	playbook := filepath.Join(dir, "site.yaml")
+
+	cfg, err := Find(playbook)
+	if err != nil || cfg.Path != "" || len(cfg.Notify) != 0 {
+		t.Errorf("Find() without bolt.yaml = %+v, %v, want empty config", cfg, err)
+	}
+
+	path := filepath.Join(dir, FileName)
+	os.WriteFile(path, []byte("notify:\n  - type: webhook\n    url: http://example.com\n"), 0644)
+	cfg, err = Find(playbook)
+	if err != nil || cfg.Path != path || len(cfg.Notify) != 1 {
+		t.Errorf("Find() = %+v, %v, want the playbook's bolt.yaml", cfg, err)
+	}
+}
@@ -0,0 +1,437 @@
+// Package cassette records the operations a run performs on its hosts and
+// replays them without connecting to anything.
+//
+// A Recorder wraps the connector of a host and appends every command,
+// upload, and download, with its result, to a Cassette. A Player answers
+// the same operations from the cassette, so a playbook can be run again
+// against the recording to check that changes to the executor or to
+// variable handling still make the hosts do the same thing:
+//
+//	c := cassette.New("site.yaml")
+//	conn = c.Record("web1", conn)
+//	... run the playbook ...
+//	c.Save("site.cassette.json")
+//
+//	c, _ = cassette.Load("site.cassette.json")
+//	conn = c.Player("web1")
+//	... run the playbook again ...
+//	c.Unplayed() // recorded operations the replay did not perform
+//
+// Operations are matched by kind and command or path rather than by
+// position, so tasks that run concurrently may replay in another order.
+// Runs of ten or more digits, such as the timestamps in the names of temp
+// files and backups, are ignored when matching.
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Version is the version of the cassette file format.
+const Version = 1
+
+// Kinds of operations.
+const (
+	OpExecute  = "execute"
+	OpUpload   = "upload"
+	OpDownload = "download"
+)
+
+// Interaction is a recorded operation and its outcome.
+type Interaction struct {
+	// Op is the kind of operation: execute, upload, or download.
+	Op string `json:"op"`
+
+	// Cmd is the command run by an execute.
+	Cmd string `json:"cmd,omitempty"`
+
+	// Path is the file on the target of an upload or download.
+	Path string `json:"path,omitempty"`
+
+	// Mode is the mode of an uploaded file.
+	Mode uint32 `json:"mode,omitempty"`
+
+	// SHA256 is the checksum of the content of an upload.
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Content is the content of a download.
+	Content []byte `json:"content,omitempty"`
+
+	// Stdout, Stderr, and ExitCode are the result of an execute.
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+
+	// Error is the error the operation failed with, if any.
+	Error string `json:"error,omitempty"`
+
+	// Connectivity is set if the error was a connectivity failure, and
+	// Transient if it was worth retrying.
+	Connectivity bool `json:"connectivity,omitempty"`
+	Transient    bool `json:"transient,omitempty"`
+
+	// played is set once a Player has answered an operation with it.
+	played bool
+}
+
+// String describes the operation, e.g. "execute: uname -s".
+func (i *Interaction) String() string {
+	if i.Op == OpExecute {
+		return i.Op + ": " + i.Cmd
+	}
+	return i.Op + ": " + i.Path
+}
+
+// err returns the recorded error, or nil.
+func (i *Interaction) err(target string) error {
+	if i.Error == "" {
+		return nil
+	}
+	if i.Connectivity {
+		return &connector.ConnectivityError{Target: target, Err: errors.New(i.Error), Transient: i.Transient}
+	}
+	return errors.New(i.Error)
+}
+
+// setErr records err as the outcome of the operation.
+func (i *Interaction) setErr(err error) {
+	if err == nil {
+		return
+	}
+	i.Error = err.Error()
+	var connErr *connector.ConnectivityError
+	if errors.As(err, &connErr) {
+		i.Connectivity = true
+		i.Transient = connErr.Transient
+	}
+}
+
+// Cassette holds the operations of a run by host name. It is safe for
+// concurrent use.
+type Cassette struct {
+	mu sync.Mutex
+
+	// Playbook is the playbook the cassette was recorded from.
+	Playbook string
+
+	// Recorded is when the recording started.
+	Recorded time.Time
+
+	// Hosts holds the operations of every host in the order they ran.
+	Hosts map[string][]*Interaction
+}
+
+// file is the cassette file format.
+type file struct {
+	Version  int                       `json:"version"`
+	Playbook string                    `json:"playbook"`
+	Recorded time.Time                 `json:"recorded"`
+	Hosts    map[string][]*Interaction `json:"hosts"`
+}
+
+// New returns an empty cassette to record a run of playbook.
+func New(playbook string) *Cassette {
+	return &Cassette{
+		Playbook: playbook,
+		Recorded: time.Now(),
+		Hosts:    make(map[string][]*Interaction),
+	}
+}
+
+// Load reads a cassette file.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("unsupported cassette version %d in %s", f.Version, path)
+	}
+	if f.Hosts == nil {
+		f.Hosts = make(map[string][]*Interaction)
+	}
+	return &Cassette{Playbook: f.Playbook, Recorded: f.Recorded, Hosts: f.Hosts}, nil
+}
+
+// Save writes the cassette to path.
+func (c *Cassette) Save(path string) error {
+	// Commands are easier to read without HTML escaping
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	c.mu.Lock()
+	err := enc.Encode(file{Version: Version, Playbook: c.Playbook, Recorded: c.Recorded, Hosts: c.Hosts})
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	}
+	// Recorded downloads may hold secrets of the hosts
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of recorded operations.
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, ops := range c.Hosts {
+		n += len(ops)
+	}
+	return n
+}
+
+// Unplayed returns the recorded operations that were not replayed, by
+// host name. A replay that performed every operation returns an empty
+// map.
+func (c *Cassette) Unplayed() map[string][]*Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unplayed := make(map[string][]*Interaction)
+	for host, ops := range c.Hosts {
+		for _, op := range ops {
+			if !op.played {
+				unplayed[host] = append(unplayed[host], op)
+			}
+		}
+	}
+	return unplayed
+}
+
+// HostNames returns the names of the recorded hosts, sorted.
+func (c *Cassette) HostNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.Hosts))
+	for name := range c.Hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// add appends an operation of host.
+func (c *Cassette) add(host string, op *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Hosts[host] = append(c.Hosts[host], op)
+}
+
+// next returns the first operation of host not played yet that matches
+// want, and marks it played.
+func (c *Cassette) next(host string, want *Interaction) (*Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := matchKey(want)
+	for _, op := range c.Hosts[host] {
+		if !op.played && matchKey(op) == key {
+			op.played = true
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// volatile matches runs of digits that differ between runs, such as the
+// nanosecond timestamps of temp files and the times of backups.
+var volatile = regexp.MustCompile(`[0-9]{10,}`)
+
+// matchKey returns what identifies an operation when replaying.
+func matchKey(op *Interaction) string {
+	return op.Op + "\x00" + volatile.ReplaceAllString(op.Cmd, "#") + "\x00" + volatile.ReplaceAllString(op.Path, "#")
+}
+
+// Recorder wraps a connector and records its operations in a cassette.
+// Connecting and health checks are passed through unrecorded.
+type Recorder struct {
+	connector.Connector
+
+	cassette *Cassette
+	host     string
+}
+
+// Record returns conn wrapped to record the operations of host in c.
+func (c *Cassette) Record(host string, conn connector.Connector) *Recorder {
+	c.mu.Lock()
+	if _, ok := c.Hosts[host]; !ok {
+		// A host that ran nothing is still part of the run
+		c.Hosts[host] = []*Interaction{}
+	}
+	c.mu.Unlock()
+	return &Recorder{Connector: conn, cassette: c, host: host}
+}
+
+// Execute runs cmd and records it with its result.
+func (r *Recorder) Execute(ctx context.Context, cmd string) (*connector.Result, error) {
+	result, err := r.Connector.Execute(ctx, cmd)
+	op := &Interaction{Op: OpExecute, Cmd: cmd}
+	if result != nil {
+		op.Stdout, op.Stderr, op.ExitCode = result.Stdout, result.Stderr, result.ExitCode
+	}
+	op.setErr(err)
+	r.cassette.add(r.host, op)
+	return result, err
+}
+
+// Upload uploads src and records the checksum of its content.
+func (r *Recorder) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	h := sha256.New()
+	if seeker, ok := src.(io.ReadSeeker); ok {
+		// Hash up front, so the wrapped connector still gets a reader it
+		// can rewind to retry
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			_, err = io.Copy(h, seeker)
+		}
+		if err == nil {
+			_, err = seeker.Seek(start, io.SeekStart)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read upload: %w", err)
+		}
+	} else {
+		src = io.TeeReader(src, h)
+	}
+
+	err := r.Connector.Upload(ctx, src, dst, mode)
+	op := &Interaction{Op: OpUpload, Path: dst, Mode: mode, SHA256: hex.EncodeToString(h.Sum(nil))}
+	op.setErr(err)
+	r.cassette.add(r.host, op)
+	return err
+}
+
+// Download downloads src and records its content.
+func (r *Recorder) Download(ctx context.Context, src string, dst io.Writer) error {
+	var buf bytes.Buffer
+	err := r.Connector.Download(ctx, src, io.MultiWriter(dst, &buf))
+	op := &Interaction{Op: OpDownload, Path: src, Content: buf.Bytes()}
+	op.setErr(err)
+	r.cassette.add(r.host, op)
+	return err
+}
+
+// Unwrap returns the wrapped connector.
+func (r *Recorder) Unwrap() connector.Connector {
+	return r.Connector
+}
+
+// Player is a connector that answers the operations of a host from a
+// cassette. An operation that was not recorded, or an upload whose content
+// differs from the recording, fails.
+type Player struct {
+	cassette *Cassette
+	host     string
+}
+
+// Player returns a connector replaying the operations of host.
+func (c *Cassette) Player(host string) *Player {
+	return &Player{cassette: c, host: host}
+}
+
+// Connect does nothing; nothing is connected.
+func (p *Player) Connect(ctx context.Context) error {
+	return nil
+}
+
+// Execute returns the recorded result of cmd.
+func (p *Player) Execute(ctx context.Context, cmd string) (*connector.Result, error) {
+	op, err := p.next(&Interaction{Op: OpExecute, Cmd: cmd})
+	if err != nil {
+		return nil, err
+	}
+	if err := op.err(p.String()); err != nil {
+		return nil, err
+	}
+	return &connector.Result{Stdout: op.Stdout, Stderr: op.Stderr, ExitCode: op.ExitCode}, nil
+}
+
+// Upload reads src and checks it against the recorded upload to dst.
+func (p *Player) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	op, err := p.next(&Interaction{Op: OpUpload, Path: dst})
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != op.SHA256 {
+		return fmt.Errorf("upload to %s differs from the recording (sha256 %s, recorded %s)", dst, sum, op.SHA256)
+	}
+	if mode != op.Mode {
+		return fmt.Errorf("upload to %s has mode %04o, recorded %04o", dst, mode, op.Mode)
+	}
+	return op.err(p.String())
+}
+
+// Download writes the recorded content of src to dst.
+func (p *Player) Download(ctx context.Context, src string, dst io.Writer) error {
+	op, err := p.next(&Interaction{Op: OpDownload, Path: src})
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(op.Content); err != nil {
+		return err
+	}
+	return op.err(p.String())
+}
+
+// Healthy reports that the replayed connection is usable.
+func (p *Player) Healthy(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing.
+func (p *Player) Close() error {
+	return nil
+}
+
+// String returns the host and that it is replayed.
+func (p *Player) String() string {
+	return "replay:" + p.host
+}
+
+// next returns the recorded operation matching want.
+func (p *Player) next(want *Interaction) (*Interaction, error) {
+	op, ok := p.cassette.next(p.host, want)
+	if !ok {
+		return nil, fmt.Errorf("not in the cassette for %s: %s", p.host, want)
+	}
+	return op, nil
+}
+
+// Ensure Recorder and Player implement the connector.Connector interface.
+var (
+	_ connector.Connector = (*Recorder)(nil)
+	_ connector.Connector = (*Player)(nil)
+)
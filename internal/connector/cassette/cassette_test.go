@@ -0,0 +1,141 @@
+package cassette_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/cassette"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+// record runs a few operations through a recorder and saves the cassette.
+func record(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+
+	fake := connectortest.New()
+	fake.On("uname -s").Return("Linux\n")
+	fake.On("test -f /etc/motd").Fail(1, "")
+	fake.On("cat /tmp/bolt-copy-1760000000123456789 > /etc/motd").Error(&connector.ConnectivityError{Target: "fake", Err: errors.New("unexpected EOF"), Transient: true})
+	fake.SetFile("/etc/hostname", []byte("web1\n"), 0644)
+
+	c := cassette.New("site.yaml")
+	conn := c.Record("web1", fake)
+	if _, err := conn.Execute(ctx, "uname -s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Execute(ctx, "test -f /etc/motd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Upload(ctx, strings.NewReader("hello\n"), "/tmp/bolt-copy-1760000000123456789", 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Execute(ctx, "cat /tmp/bolt-copy-1760000000123456789 > /etc/motd"); err == nil {
+		t.Fatal("Execute() error = nil, want the connectivity error")
+	}
+	var buf bytes.Buffer
+	if err := conn.Download(ctx, "/etc/hostname", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "web1\n" {
+		t.Fatalf("Download() = %q, want the file passed through", buf.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "site.cassette.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	c, err := cassette.Load(record(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Playbook != "site.yaml" || c.Len() != 5 {
+		t.Fatalf("Load() = playbook %q with %d operations, want site.yaml with 5", c.Playbook, c.Len())
+	}
+
+	p := c.Player("web1")
+	if err := p.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Out of order, as concurrent tasks may run
+	result, err := p.Execute(ctx, "test -f /etc/motd")
+	if err != nil || result.ExitCode != 1 {
+		t.Errorf("Execute(test) = %+v, %v, want exit code 1", result, err)
+	}
+	result, err = p.Execute(ctx, "uname -s")
+	if err != nil || result.Stdout != "Linux\n" {
+		t.Errorf("Execute(uname) = %+v, %v, want the recorded output", result, err)
+	}
+
+	// Another timestamp in the temp file name still matches
+	if err := p.Upload(ctx, strings.NewReader("hello\n"), "/tmp/bolt-copy-1760000000999999999", 0644); err != nil {
+		t.Errorf("Upload() error = %v", err)
+	}
+	_, err = p.Execute(ctx, "cat /tmp/bolt-copy-1760000000999999999 > /etc/motd")
+	if !connector.Transient(err) {
+		t.Errorf("Execute(cat) error = %v, want the recorded transient error", err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.Download(ctx, "/etc/hostname", &buf); err != nil || buf.String() != "web1\n" {
+		t.Errorf("Download() = %q, %v, want the recorded content", buf.String(), err)
+	}
+
+	if unplayed := c.Unplayed(); len(unplayed) != 0 {
+		t.Errorf("Unplayed() = %v, want none", unplayed)
+	}
+
+	// Everything was played once
+	if _, err := p.Execute(ctx, "uname -s"); err == nil {
+		t.Error("Execute(uname) again: error = nil, want not in the cassette")
+	}
+}
+
+func TestReplayDiverges(t *testing.T) {
+	ctx := context.Background()
+	c, err := cassette.Load(record(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := c.Player("web1")
+
+	if _, err := p.Execute(ctx, "uname -a"); err == nil || !strings.Contains(err.Error(), "not in the cassette") {
+		t.Errorf("Execute(unrecorded) error = %v, want not in the cassette", err)
+	}
+	if err := p.Upload(ctx, strings.NewReader("bye\n"), "/tmp/bolt-copy-1760000000123456789", 0644); err == nil || !strings.Contains(err.Error(), "differs from the recording") {
+		t.Errorf("Upload(other content) error = %v, want differs from the recording", err)
+	}
+	if _, err := c.Player("web2").Execute(ctx, "uname -s"); err == nil {
+		t.Error("Execute() on an unrecorded host: error = nil")
+	}
+
+	unplayed := c.Unplayed()["web1"]
+	if len(unplayed) != 4 {
+		t.Fatalf("Unplayed() = %v, want the 4 operations not replayed", unplayed)
+	}
+	if got := unplayed[0].String(); got != "execute: uname -s" {
+		t.Errorf("Unplayed()[0] = %q, want execute: uname -s", got)
+	}
+}
+
+func TestLoadVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "hosts": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cassette.Load(path); err == nil || !strings.Contains(err.Error(), "unsupported cassette version 2") {
+		t.Errorf("Load() error = %v, want unsupported version", err)
+	}
+}
@@ -4,6 +4,8 @@ package connector
 import (
 	"context"
 	"io"
+
+	"github.com/eugenetaranov/bolt/internal/shellutil"
 )
 
 // Result holds the output from command execution.
@@ -27,6 +29,10 @@ type Connector interface {
 	// Download copies a file from remote source to local destination.
 	Download(ctx context.Context, src string, dst io.Writer) error
 
+	// Healthy checks that the connection is still usable and returns the
+	// reason if it is not (e.g., the session timed out).
+	Healthy(ctx context.Context) error
+
 	// Close terminates the connection.
 	Close() error
 
@@ -34,6 +40,24 @@ type Connector interface {
 	String() string
 }
 
+// ArgvExecutor is implemented by connectors that can run a program with
+// its arguments directly, without a shell on the target interpreting them.
+type ArgvExecutor interface {
+	// ExecuteArgv runs argv[0] with the arguments argv[1:] and returns
+	// the result. A program that is not found exits with 127, as it does
+	// in a shell.
+	ExecuteArgv(ctx context.Context, argv []string) (*Result, error)
+}
+
+// ExecuteArgv runs argv on conn: directly if conn is an ArgvExecutor, and
+// otherwise as a command line with every argument quoted.
+func ExecuteArgv(ctx context.Context, conn Connector, argv ...string) (*Result, error) {
+	if e, ok := conn.(ArgvExecutor); ok {
+		return e.ExecuteArgv(ctx, argv)
+	}
+	return conn.Execute(ctx, shellutil.Join(argv...))
+}
+
 // Config holds common configuration for connectors.
 type Config struct {
 	// Host is the target hostname or IP address.
@@ -45,3 +69,25 @@ type Config struct {
 	// Timeout is the connection timeout in seconds.
 	Timeout int
 }
+
+// ConnectivityError reports a failure to reach the target or to run
+// anything on it, as opposed to a command that ran and failed.
+type ConnectivityError struct {
+	// Target describes the connection (e.g., "docker:web1").
+	Target string
+
+	// Err is the underlying error.
+	Err error
+
+	// Transient is set if the failure may go away on its own (e.g., a
+	// dropped connection) and the operation is worth retrying.
+	Transient bool
+}
+
+func (e *ConnectivityError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectivityError) Unwrap() error {
+	return e.Err
+}
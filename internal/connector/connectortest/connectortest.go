@@ -0,0 +1,312 @@
+// Package connectortest provides a scriptable fake connector for unit tests.
+//
+// A Connector is programmed with expected commands and the canned results
+// they return, so modules and playbooks can be exercised without a real
+// target:
+//
+//	conn := connectortest.New()
+//	conn.On("command -v apt-get").Return("/usr/bin/apt-get")
+//	conn.OnPrefix("dpkg-query").Fail(1, "not installed")
+//	conn.OnMatch(`^apt-get install`).Return("")
+//
+//	result, err := mod.Run(ctx, conn, params)
+//	conn.AssertExpectations(t)
+package connectortest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Expectation is a scripted response to commands matching a pattern.
+type Expectation struct {
+	desc   string
+	match  func(cmd string) bool
+	result connector.Result
+	err    error
+	times  int // 0 means unlimited
+	calls  int
+}
+
+// Return makes matching commands succeed with the given stdout.
+func (x *Expectation) Return(stdout string) *Expectation {
+	x.result = connector.Result{Stdout: stdout}
+	return x
+}
+
+// ReturnResult makes matching commands return the given result.
+func (x *Expectation) ReturnResult(result connector.Result) *Expectation {
+	x.result = result
+	return x
+}
+
+// Fail makes matching commands exit with the given code and stderr.
+func (x *Expectation) Fail(exitCode int, stderr string) *Expectation {
+	x.result = connector.Result{Stderr: stderr, ExitCode: exitCode}
+	return x
+}
+
+// Error makes matching commands fail at the transport level.
+func (x *Expectation) Error(err error) *Expectation {
+	x.err = err
+	return x
+}
+
+// Times limits how often the expectation may match. Once used up, later
+// commands fall through to the next matching expectation.
+func (x *Expectation) Times(n int) *Expectation {
+	x.times = n
+	return x
+}
+
+// Once is shorthand for Times(1).
+func (x *Expectation) Once() *Expectation {
+	return x.Times(1)
+}
+
+// exhausted reports whether the expectation has no calls left.
+func (x *Expectation) exhausted() bool {
+	return x.times > 0 && x.calls >= x.times
+}
+
+// Connector is a fake connector that replays scripted command results and
+// keeps uploaded files in memory. It is safe for concurrent use.
+type Connector struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	commands     []string
+	files        map[string][]byte
+	modes        map[string]uint32
+	fallback     *connector.Result
+	connected    bool
+	closed       bool
+	connects     int
+	dropped      error
+}
+
+// New creates a fake connector with no expectations. Commands that match
+// no expectation fail with an error unless a fallback is set with Default.
+func New() *Connector {
+	return &Connector{
+		files: make(map[string][]byte),
+		modes: make(map[string]uint32),
+	}
+}
+
+// On expects a command that equals cmd exactly.
+func (c *Connector) On(cmd string) *Expectation {
+	return c.expect(fmt.Sprintf("%q", cmd), func(s string) bool { return s == cmd })
+}
+
+// OnPrefix expects a command that starts with prefix.
+func (c *Connector) OnPrefix(prefix string) *Expectation {
+	return c.expect(fmt.Sprintf("prefix %q", prefix), func(s string) bool { return strings.HasPrefix(s, prefix) })
+}
+
+// OnMatch expects a command that matches the regular expression pattern.
+func (c *Connector) OnMatch(pattern string) *Expectation {
+	re := regexp.MustCompile(pattern)
+	return c.expect(fmt.Sprintf("pattern %q", pattern), re.MatchString)
+}
+
+// expect registers a new expectation.
+func (c *Connector) expect(desc string, match func(string) bool) *Expectation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	x := &Expectation{desc: desc, match: match}
+	c.expectations = append(c.expectations, x)
+	return x
+}
+
+// Default sets the result returned for commands that match no expectation.
+func (c *Connector) Default(result connector.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallback = &result
+}
+
+// SetFile seeds a file on the fake target.
+func (c *Connector) SetFile(path string, content []byte, mode uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = content
+	c.modes[path] = mode
+}
+
+// File returns the content of an uploaded or seeded file.
+func (c *Connector) File(path string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.files[path]
+	return content, ok
+}
+
+// FileMode returns the mode an uploaded or seeded file was written with.
+func (c *Connector) FileMode(path string) (uint32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mode, ok := c.modes[path]
+	return mode, ok
+}
+
+// Commands returns every executed command in order.
+func (c *Connector) Commands() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.commands...)
+}
+
+// Executed reports whether a command equal to cmd was executed.
+func (c *Connector) Executed(cmd string) bool {
+	for _, executed := range c.Commands() {
+		if executed == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertExpectations fails the test if an expectation limited with Times
+// was not used up, or one without a limit was never matched.
+func (c *Connector) AssertExpectations(t testing.TB) {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, x := range c.expectations {
+		switch {
+		case x.times > 0 && x.calls < x.times:
+			t.Errorf("expected command %s %d time(s), got %d", x.desc, x.times, x.calls)
+		case x.times == 0 && x.calls == 0:
+			t.Errorf("expected command %s was never executed", x.desc)
+		}
+	}
+}
+
+// Connect marks the connector as connected, restoring a dropped
+// connection.
+func (c *Connector) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = true
+	c.connects++
+	c.dropped = nil
+	return nil
+}
+
+// Drop simulates a lost connection: Healthy and Execute fail with err until
+// Connect is called again.
+func (c *Connector) Drop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropped = err
+}
+
+// Healthy returns the error passed to Drop, if the connection was dropped.
+func (c *Connector) Healthy(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Execute records cmd and returns the result of the first matching
+// expectation that is not used up.
+func (c *Connector) Execute(ctx context.Context, cmd string) (*connector.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.commands = append(c.commands, cmd)
+
+	if c.dropped != nil {
+		return nil, c.dropped
+	}
+
+	for _, x := range c.expectations {
+		if x.exhausted() || !x.match(cmd) {
+			continue
+		}
+		x.calls++
+		if x.err != nil {
+			return nil, x.err
+		}
+		result := x.result
+		return &result, nil
+	}
+
+	if c.fallback != nil {
+		result := *c.fallback
+		return &result, nil
+	}
+	return nil, fmt.Errorf("connectortest: unexpected command: %s", cmd)
+}
+
+// Upload stores the content in memory under dst.
+func (c *Connector) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("failed to read upload source: %w", err)
+	}
+	c.SetFile(dst, content, mode)
+	return nil
+}
+
+// Download writes the content of a stored file to dst.
+func (c *Connector) Download(ctx context.Context, src string, dst io.Writer) error {
+	content, ok := c.File(src)
+	if !ok {
+		return fmt.Errorf("connectortest: no such file: %s", src)
+	}
+	_, err := io.Copy(dst, bytes.NewReader(content))
+	return err
+}
+
+// Close marks the connector as closed.
+func (c *Connector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// Connected reports whether Connect was called.
+func (c *Connector) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Connects returns how often Connect was called.
+func (c *Connector) Connects() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connects
+}
+
+// Closed reports whether Close was called.
+func (c *Connector) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// String returns a description of the connector.
+func (c *Connector) String() string {
+	return "fake"
+}
+
+// Ensure Connector implements the connector.Connector interface.
+var _ connector.Connector = (*Connector)(nil)
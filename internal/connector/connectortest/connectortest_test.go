@@ -0,0 +1,166 @@
+package connectortest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+func TestExecuteMatching(t *testing.T) {
+	ctx := context.Background()
+	conn := New()
+	conn.On("uname -s").Return("Linux\n")
+	conn.OnPrefix("dpkg-query").Fail(1, "not installed")
+	conn.OnMatch(`^apt-get (install|remove)`).ReturnResult(connector.Result{Stdout: "done", ExitCode: 0})
+	conn.On("flaky").Error(errors.New("connection reset"))
+
+	tests := []struct {
+		cmd      string
+		stdout   string
+		exitCode int
+		wantErr  bool
+	}{
+		{"uname -s", "Linux\n", 0, false},
+		{"dpkg-query -W curl", "", 1, false},
+		{"apt-get install -y curl", "done", 0, false},
+		{"flaky", "", 0, true},
+		{"rm -rf /", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cmd, func(t *testing.T) {
+			result, err := conn.Execute(ctx, tt.cmd)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Stdout != tt.stdout || result.ExitCode != tt.exitCode {
+				t.Errorf("got %+v, want stdout %q exit %d", result, tt.stdout, tt.exitCode)
+			}
+		})
+	}
+
+	if got := len(conn.Commands()); got != len(tests) {
+		t.Errorf("recorded %d commands, want %d", got, len(tests))
+	}
+	if !conn.Executed("uname -s") {
+		t.Error("expected uname -s to be recorded")
+	}
+}
+
+func TestExpectationTimes(t *testing.T) {
+	ctx := context.Background()
+	conn := New()
+	conn.On("systemctl is-active nginx").Fail(3, "").Once()
+	conn.On("systemctl is-active nginx").Return("active")
+
+	first, _ := conn.Execute(ctx, "systemctl is-active nginx")
+	second, _ := conn.Execute(ctx, "systemctl is-active nginx")
+
+	if first.ExitCode != 3 {
+		t.Errorf("first exit code = %d, want 3", first.ExitCode)
+	}
+	if second.Stdout != "active" {
+		t.Errorf("second stdout = %q, want active", second.Stdout)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestDefault(t *testing.T) {
+	conn := New()
+	conn.Default(connector.Result{Stdout: "ok"})
+
+	result, err := conn.Execute(context.Background(), "anything")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Stdout != "ok" {
+		t.Errorf("stdout = %q, want ok", result.Stdout)
+	}
+}
+
+func TestAssertExpectations(t *testing.T) {
+	conn := New()
+	conn.On("never run")
+	conn.On("run once").Times(2)
+	_, _ = conn.Execute(context.Background(), "run once")
+
+	rec := &recorder{TB: t}
+	conn.AssertExpectations(rec)
+	if len(rec.errors) != 2 {
+		t.Errorf("got %d assertion errors, want 2: %v", len(rec.errors), rec.errors)
+	}
+}
+
+func TestUploadDownload(t *testing.T) {
+	ctx := context.Background()
+	conn := New()
+
+	if err := conn.Upload(ctx, strings.NewReader("hello"), "/etc/motd", 0644); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	content, ok := conn.File("/etc/motd")
+	if !ok || string(content) != "hello" {
+		t.Errorf("file content = %q, %v", content, ok)
+	}
+	if mode, _ := conn.FileMode("/etc/motd"); mode != 0644 {
+		t.Errorf("mode = %o, want 644", mode)
+	}
+
+	var buf bytes.Buffer
+	if err := conn.Download(ctx, "/etc/motd", &buf); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if buf.String() != "hello" {
+		t.Errorf("downloaded %q, want hello", buf.String())
+	}
+
+	if err := conn.Download(ctx, "/missing", &buf); err == nil {
+		t.Error("expected error downloading missing file")
+	}
+}
+
+func TestDrop(t *testing.T) {
+	ctx := context.Background()
+	conn := New()
+	conn.Default(connector.Result{})
+
+	conn.Drop(errors.New("broken pipe"))
+	if err := conn.Healthy(ctx); err == nil {
+		t.Error("expected dropped connection to be unhealthy")
+	}
+	if _, err := conn.Execute(ctx, "true"); err == nil {
+		t.Error("expected command on dropped connection to fail")
+	}
+
+	if err := conn.Connect(ctx); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := conn.Healthy(ctx); err != nil {
+		t.Errorf("Healthy() after reconnect = %v", err)
+	}
+	if conn.Connects() != 1 {
+		t.Errorf("Connects() = %d, want 1", conn.Connects())
+	}
+}
+
+// recorder captures Errorf calls instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, format)
+}
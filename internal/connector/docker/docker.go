@@ -2,13 +2,16 @@
 package docker
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/eugenetaranov/bolt/internal/connector"
 )
@@ -66,18 +69,26 @@ func New(container string, opts ...Option) *Connector {
 func (c *Connector) Connect(ctx context.Context) error {
 	// Check if docker is available
 	if _, err := exec.LookPath("docker"); err != nil {
-		return fmt.Errorf("docker command not found: %w", err)
+		return &connector.ConnectivityError{Target: c.String(), Err: fmt.Errorf("docker command not found: %w", err)}
 	}
 
 	// Check if container exists and is running
 	cmd := exec.CommandContext(ctx, "docker", "inspect", "-f", "{{.State.Running}}", c.container)
 	output, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("container '%s' not found or not accessible: %w", c.container, err)
+		var stderr string
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = string(exitErr.Stderr)
+		}
+		return &connector.ConnectivityError{
+			Target:    c.String(),
+			Err:       fmt.Errorf("container '%s' not found or not accessible: %w", c.container, err),
+			Transient: daemonError(stderr),
+		}
 	}
 
 	if strings.TrimSpace(string(output)) != "true" {
-		return fmt.Errorf("container '%s' is not running", c.container)
+		return &connector.ConnectivityError{Target: c.String(), Err: fmt.Errorf("container '%s' is not running", c.container)}
 	}
 
 	return nil
@@ -85,8 +96,20 @@ func (c *Connector) Connect(ctx context.Context) error {
 
 // Execute runs a command inside the container.
 func (c *Connector) Execute(ctx context.Context, cmd string) (*connector.Result, error) {
-	args := c.buildExecArgs(cmd)
+	return c.run(ctx, c.buildExecArgs("/bin/sh", "-c", cmd))
+}
+
+// ExecuteArgv runs argv inside the container without a shell.
+func (c *Connector) ExecuteArgv(ctx context.Context, argv []string) (*connector.Result, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("no command to execute")
+	}
+	return c.run(ctx, c.buildExecArgs(argv...))
+}
 
+// run runs docker with args and returns the result of the command it
+// ran in the container.
+func (c *Connector) run(ctx context.Context, args []string) (*connector.Result, error) {
 	execCmd := exec.CommandContext(ctx, "docker", args...)
 
 	var stdout, stderr bytes.Buffer
@@ -102,17 +125,54 @@ func (c *Connector) Execute(ctx context.Context, cmd string) (*connector.Result,
 
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
+			// The docker CLI itself failed, so the command may not
+			// have run at all
+			if daemonError(result.Stderr) {
+				return nil, &connector.ConnectivityError{
+					Target:    c.String(),
+					Err:       fmt.Errorf("failed to execute command in container: %s", strings.TrimSpace(result.Stderr)),
+					Transient: true,
+				}
+			}
 			result.ExitCode = exitErr.ExitCode()
 		} else {
-			return nil, fmt.Errorf("failed to execute command in container: %w", err)
+			return nil, &connector.ConnectivityError{Target: c.String(), Err: fmt.Errorf("failed to execute command in container: %w", err)}
 		}
 	}
 
 	return result, nil
 }
 
-// buildExecArgs builds the docker exec command arguments.
-func (c *Connector) buildExecArgs(cmd string) []string {
+// daemonErrors are causes reported in "Error response from daemon"
+// messages that mean the connection to the daemon was lost, as opposed to
+// errors of the command or the container.
+var daemonErrors = []string{
+	"unexpected EOF",
+	"connection reset by peer",
+	"i/o timeout",
+}
+
+// daemonError reports whether output of the docker CLI shows a transient
+// failure to talk to the daemon.
+func daemonError(output string) bool {
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "error during connect") ||
+		strings.HasPrefix(output, "Cannot connect to the Docker daemon") {
+		return true
+	}
+	if !strings.HasPrefix(output, "Error response from daemon") {
+		return false
+	}
+	for _, msg := range daemonErrors {
+		if strings.Contains(output, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildExecArgs builds the docker exec arguments that run argv.
+func (c *Connector) buildExecArgs(argv ...string) []string {
 	args := []string{"exec"}
 
 	// Add interactive flag for proper stdin handling
@@ -134,77 +194,180 @@ func (c *Connector) buildExecArgs(cmd string) []string {
 	}
 
 	// Add container and command
-	args = append(args, c.container, "/bin/sh", "-c", cmd)
+	args = append(args, c.container)
+	args = append(args, argv...)
 
 	return args
 }
 
-// Upload copies content to a file inside the container.
+// Upload copies content to a file inside the container. With a user set,
+// the file is written as that user.
+//
+// The content is streamed to docker cp as a tar archive, so it is neither
+// held in memory nor written to disk on the controller. A tar header needs
+// the size of the file up front; content of unknown size is spooled to a
+// temp file first.
 func (c *Connector) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
-	// Docker cp doesn't support stdin directly, so we need a temp file
-	tmpFile, err := os.CreateTemp("", "bolt-upload-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+	if c.user != "" {
+		return c.userUpload(ctx, src, dst, mode)
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
 
-	// Write content to temp file
-	if _, err := io.Copy(tmpFile, src); err != nil {
-		tmpFile.Close()
-		return fmt.Errorf("failed to write temp file: %w", err)
+	size, ok := connector.Size(src)
+	if !ok {
+		spooled, err := spool(src)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(spooled.Name())
+		defer spooled.Close()
+		if size, ok = connector.Size(spooled); !ok {
+			return fmt.Errorf("failed to get the size of %s", spooled.Name())
+		}
+		src = spooled
 	}
-	tmpFile.Close()
 
-	// Set permissions on temp file
-	if err := os.Chmod(tmpPath, os.FileMode(mode)); err != nil {
-		return fmt.Errorf("failed to set temp file mode: %w", err)
+	pr, pw := io.Pipe()
+	archived := make(chan error, 1)
+	go func() {
+		err := writeTar(pw, path.Base(dst), src, size, mode)
+		pw.CloseWithError(err)
+		archived <- err
+	}()
+
+	// docker cp extracts the archive into the parent directory of dst
+	cmd := exec.CommandContext(ctx, "docker", "cp", "-", fmt.Sprintf("%s:%s", c.container, path.Dir(dst)))
+	cmd.Stdin = pr
+	output, err := cmd.CombinedOutput()
+	// Stop the archiver if docker cp ended early
+	pr.CloseWithError(io.ErrClosedPipe)
+	if archiveErr := <-archived; archiveErr != nil && archiveErr != io.ErrClosedPipe {
+		return fmt.Errorf("failed to read the content of %s: %w", dst, archiveErr)
+	}
+	if err != nil {
+		return &connector.ConnectivityError{
+			Target:    c.String(),
+			Err:       fmt.Errorf("failed to copy file to container: %s: %w", string(output), err),
+			Transient: daemonError(string(output)),
+		}
 	}
+	return nil
+}
 
-	// Copy to container
-	cmd := exec.CommandContext(ctx, "docker", "cp", tmpPath, fmt.Sprintf("%s:%s", c.container, dst))
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to copy file to container: %s: %w", string(output), err)
+// writeTar writes a tar archive with a single file, name, of size bytes
+// read from src, to w.
+func writeTar(w io.Writer, name string, src io.Reader, size int64, mode uint32) error {
+	tw := tar.NewWriter(w)
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     int64(mode),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tw, src, size); err != nil {
+		return err
 	}
+	return tw.Close()
+}
 
-	// Set permissions inside container
-	chmodCmd := fmt.Sprintf("chmod %o %s", mode, dst)
-	if _, err := c.Execute(ctx, chmodCmd); err != nil {
-		return fmt.Errorf("failed to set file permissions in container: %w", err)
+// spool copies src into a temp file, positioned at its start.
+func spool(src io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "bolt-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
 	}
+	return f, nil
+}
 
+// userUpload streams src into dst with docker exec as the connector's
+// user. docker cp always writes as root, which would leave the file owned
+// by root and, for an existing file, unwritable by the user's chmod.
+func (c *Connector) userUpload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	script := fmt.Sprintf(`cat > "$1" && chmod %o "$1"`, mode)
+	// Pass dst as $1 of the script
+	args := c.buildExecArgs("/bin/sh", "-c", script, "sh", dst)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdin = src
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		output := stderr.String()
+		if _, ok := err.(*exec.ExitError); ok && !daemonError(output) {
+			return fmt.Errorf("failed to write %s as %s: %s", dst, c.user, strings.TrimSpace(output))
+		}
+		return &connector.ConnectivityError{
+			Target:    c.String(),
+			Err:       fmt.Errorf("failed to copy file to container: %s: %w", output, err),
+			Transient: daemonError(output),
+		}
+	}
 	return nil
 }
 
-// Download copies content from a file inside the container.
+// Download copies content from a file inside the container. docker cp
+// writes the file as a tar archive to stdout, which is unpacked on the fly.
 func (c *Connector) Download(ctx context.Context, src string, dst io.Writer) error {
-	// Docker cp doesn't support stdout directly, so we need a temp file
-	tmpFile, err := os.CreateTemp("", "bolt-download-*")
+	cmd := exec.CommandContext(ctx, "docker", "cp", fmt.Sprintf("%s:%s", c.container, src), "-")
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return &connector.ConnectivityError{Target: c.String(), Err: fmt.Errorf("failed to copy file from container: %w", err)}
 	}
-	tmpPath := tmpFile.Name()
-	tmpFile.Close()
-	defer os.Remove(tmpPath)
 
-	// Copy from container
-	cmd := exec.CommandContext(ctx, "docker", "cp", fmt.Sprintf("%s:%s", c.container, src), tmpPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to copy file from container: %s: %w", string(output), err)
+	extractErr := extractFile(stdout, dst)
+	// Drain the rest, so docker cp can exit
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		output := stderr.String()
+		return &connector.ConnectivityError{
+			Target:    c.String(),
+			Err:       fmt.Errorf("failed to copy file from container: %s: %w", output, err),
+			Transient: daemonError(output),
+		}
 	}
+	if extractErr != nil {
+		return fmt.Errorf("failed to read %s from the archive of docker cp: %w", src, extractErr)
+	}
+	return nil
+}
 
-	// Read temp file and write to dst
-	f, err := os.Open(tmpPath)
+// extractFile copies the content of the first entry of a tar archive to
+// dst. It fails if the entry is not a regular file, e.g. a directory.
+func extractFile(r io.Reader, dst io.Writer) error {
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
 	if err != nil {
-		return fmt.Errorf("failed to open temp file: %w", err)
+		return err
 	}
-	defer f.Close()
-
-	if _, err := io.Copy(dst, f); err != nil {
-		return fmt.Errorf("failed to read downloaded file: %w", err)
+	if hdr.Typeflag != tar.TypeReg {
+		return fmt.Errorf("%s is not a regular file", hdr.Name)
 	}
+	_, err = io.Copy(dst, tr)
+	return err
+}
 
-	return nil
+// Healthy verifies the container is still running.
+func (c *Connector) Healthy(ctx context.Context) error {
+	return c.Connect(ctx)
 }
 
 // Close is a no-op for Docker connections.
@@ -221,5 +384,18 @@ func (c *Connector) String() string {
 	return desc
 }
 
+// RsyncShell lets rsync reach the container through docker exec, as the
+// connector's user if one is set.
+func (c *Connector) RsyncShell() (string, string) {
+	rsh := "docker exec -i"
+	if c.user != "" {
+		rsh += " -u " + c.user
+	}
+	return rsh, c.container
+}
+
 // Ensure Connector implements the connector.Connector interface.
-var _ connector.Connector = (*Connector)(nil)
+var (
+	_ connector.Connector = (*Connector)(nil)
+	_ connector.Rsyncer   = (*Connector)(nil)
+)
@@ -0,0 +1,80 @@
+package connector
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// Env wraps a connector and runs every command with extra environment
+// variables, such as the proxy settings of a play. Uploads and downloads
+// are passed through unchanged.
+type Env struct {
+	Connector
+
+	// Vars are the variables set for every command.
+	Vars map[string]string
+}
+
+// WithEnv returns conn wrapped to run commands with vars set.
+func WithEnv(conn Connector, vars map[string]string) *Env {
+	return &Env{Connector: conn, Vars: vars}
+}
+
+// Execute runs cmd with the variables set.
+func (e *Env) Execute(ctx context.Context, cmd string) (*Result, error) {
+	return e.Connector.Execute(ctx, EnvCommand(e.Vars, cmd))
+}
+
+// ExecuteArgv runs argv with the variables set, through env(1) so no
+// shell is involved.
+func (e *Env) ExecuteArgv(ctx context.Context, argv []string) (*Result, error) {
+	return ExecuteArgv(ctx, e.Connector, EnvArgv(e.Vars, argv)...)
+}
+
+// Unwrap returns the wrapped connector.
+func (e *Env) Unwrap() Connector {
+	return e.Connector
+}
+
+// EnvCommand returns cmd run by /bin/sh with vars set through env(1).
+// Running the whole command in its own shell keeps the variables for
+// every part of it, also when a connector runs commands under sudo.
+func EnvCommand(vars map[string]string, cmd string) string {
+	if len(vars) == 0 {
+		return cmd
+	}
+	var b strings.Builder
+	b.WriteString("env")
+	for _, assignment := range assignments(vars) {
+		b.WriteString(" " + shellutil.Quote(assignment))
+	}
+	b.WriteString(" /bin/sh -c " + shellutil.Quote(cmd))
+	return b.String()
+}
+
+// EnvArgv returns argv run by env(1) with vars set.
+func EnvArgv(vars map[string]string, argv []string) []string {
+	if len(vars) == 0 {
+		return argv
+	}
+	return append(append([]string{"env"}, assignments(vars)...), argv...)
+}
+
+// assignments returns vars as NAME=value arguments of env(1), sorted by
+// name.
+func assignments(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, len(names))
+	for i, name := range names {
+		args[i] = name + "=" + vars[name]
+	}
+	return args
+}
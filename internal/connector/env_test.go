@@ -0,0 +1,61 @@
+package connector_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/connector/local"
+)
+
+func TestEnv(t *testing.T) {
+	conn := connector.WithEnv(local.New(), map[string]string{
+		"http_proxy": "http://proxy.corp:3128",
+		"BOLT_QUOTE": "it's",
+	})
+
+	result, err := conn.Execute(context.Background(), `printf '%s|' "$http_proxy" && printf '%s' "$BOLT_QUOTE"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://proxy.corp:3128|it's"; result.Stdout != want {
+		t.Errorf("Execute() stdout = %q, want %q", result.Stdout, want)
+	}
+
+	if got := connector.EnvCommand(nil, "true"); got != "true" {
+		t.Errorf("EnvCommand() without vars = %q, want the command unchanged", got)
+	}
+}
+
+func TestEnvExecuteArgv(t *testing.T) {
+	conn := connector.WithEnv(local.New(), map[string]string{"BOLT_QUOTE": "it's"})
+
+	result, err := connector.ExecuteArgv(context.Background(), conn, "sh", "-c", `printf '%s|%s' "$BOLT_QUOTE" "$1"`, "sh", "$HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "it's|$HOME"; result.Stdout != want {
+		t.Errorf("ExecuteArgv() stdout = %q, want %q", result.Stdout, want)
+	}
+
+	argv := []string{"true"}
+	if got := connector.EnvArgv(nil, argv); len(got) != 1 || got[0] != "true" {
+		t.Errorf("EnvArgv() without vars = %q, want argv unchanged", got)
+	}
+}
+
+func TestExecuteArgvFallback(t *testing.T) {
+	// connectortest only runs command lines, so argv is quoted into one
+	fake := connectortest.New()
+	fake.On(`'printf' '%s' 'a b' '$HOME'`).Return("ok")
+
+	result, err := connector.ExecuteArgv(context.Background(), connector.WithRetry(fake, connector.RetryPolicy{}), "printf", "%s", "a b", "$HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "ok" {
+		t.Errorf("ExecuteArgv() stdout = %q", result.Stdout)
+	}
+	fake.AssertExpectations(t)
+}
@@ -4,14 +4,18 @@ package local
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/user"
 	"runtime"
+	"strings"
 
 	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
 )
 
 // Connector executes commands on the local machine.
@@ -68,6 +72,8 @@ func (c *Connector) Connect(ctx context.Context) error {
 	switch runtime.GOOS {
 	case "darwin", "linux":
 		return nil
+	case "windows":
+		return fmt.Errorf("unsupported platform: windows (run bolt inside WSL to manage it)")
 	default:
 		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
@@ -80,8 +86,23 @@ func (c *Connector) Execute(ctx context.Context, cmd string) (*connector.Result,
 
 	// Create the exec.Cmd
 	args := append(c.shellArgs, fullCmd)
-	execCmd := exec.CommandContext(ctx, c.shell, args...)
+	return c.run(exec.CommandContext(ctx, c.shell, args...))
+}
+
+// ExecuteArgv runs argv locally without a shell, through sudo if
+// configured.
+func (c *Connector) ExecuteArgv(ctx context.Context, argv []string) (*connector.Result, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("no command to execute")
+	}
+	if c.sudo {
+		return c.run(exec.CommandContext(ctx, "sudo", c.sudoArgs(argv...)...))
+	}
+	return c.run(exec.CommandContext(ctx, argv[0], argv[1:]...))
+}
 
+// run runs execCmd and returns its result.
+func (c *Connector) run(execCmd *exec.Cmd) (*connector.Result, error) {
 	var stdout, stderr bytes.Buffer
 	execCmd.Stdout = &stdout
 	execCmd.Stderr = &stderr
@@ -96,11 +117,20 @@ func (c *Connector) Execute(ctx context.Context, cmd string) (*connector.Result,
 
 	// Get exit code
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
 			result.ExitCode = exitErr.ExitCode()
-		} else {
+		case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+			// Report a missing program like a shell does
+			result.ExitCode = 127
+			result.Stderr = fmt.Sprintf("%s: command not found\n", execCmd.Args[0])
+		case errors.Is(err, fs.ErrPermission):
+			result.ExitCode = 126
+			result.Stderr = fmt.Sprintf("%s: permission denied\n", execCmd.Args[0])
+		default:
 			// Command failed to start
-			return nil, fmt.Errorf("failed to execute command: %w", err)
+			return nil, &connector.ConnectivityError{Target: c.String(), Err: fmt.Errorf("failed to execute command: %w", err)}
 		}
 	}
 
@@ -114,12 +144,13 @@ func (c *Connector) buildCommand(cmd string) string {
 	}
 
 	if c.sudoUser != "" {
-		return fmt.Sprintf("sudo -u %s -- %s", c.sudoUser, cmd)
+		return fmt.Sprintf("sudo -u %s -- %s", shellutil.Quote(c.sudoUser), cmd)
 	}
 	return fmt.Sprintf("sudo -- %s", cmd)
 }
 
-// Upload writes content from src to a local file at dst.
+// Upload writes content from src to a local file at dst. With sudo the
+// file is written as the sudo user.
 func (c *Connector) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
 	// Check for context cancellation
 	select {
@@ -128,6 +159,10 @@ func (c *Connector) Upload(ctx context.Context, src io.Reader, dst string, mode
 	default:
 	}
 
+	if c.sudo {
+		return c.sudoUpload(ctx, src, dst, mode)
+	}
+
 	// Create the destination file
 	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(mode))
 	if err != nil {
@@ -143,7 +178,44 @@ func (c *Connector) Upload(ctx context.Context, src io.Reader, dst string, mode
 	return nil
 }
 
-// Download reads content from a local file at src to dst.
+// sudoUpload streams src into dst through sudo. Unlike writing a temp
+// file as the current user and moving it into place, this works for any
+// path the sudo user can write (a non-root sudo user cannot move files out
+// of a sticky /tmp), never leaves the content readable by others, and
+// keeps the owner and attributes of an existing file.
+func (c *Connector) sudoUpload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	script := `cat > "$1" && chmod "$2" "$1"`
+	cmd := exec.CommandContext(ctx, "sudo", c.sudoArgs("/bin/sh", "-c", script, "sh", dst, fmt.Sprintf("%o", mode))...)
+	cmd.Stdin = src
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to write %s as %s: %s", dst, c.becomeUser(), strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// sudoArgs returns the sudo arguments that run the command given by args
+// as the sudo user.
+func (c *Connector) sudoArgs(args ...string) []string {
+	var sudoArgs []string
+	if c.sudoUser != "" {
+		sudoArgs = append(sudoArgs, "-u", c.sudoUser)
+	}
+	return append(append(sudoArgs, "--"), args...)
+}
+
+// becomeUser returns the user sudo runs commands as.
+func (c *Connector) becomeUser() string {
+	if c.sudoUser == "" {
+		return "root"
+	}
+	return c.sudoUser
+}
+
+// Download reads content from a local file at src to dst. With sudo the
+// file is read as the sudo user.
 func (c *Connector) Download(ctx context.Context, src string, dst io.Writer) error {
 	// Check for context cancellation
 	select {
@@ -152,6 +224,18 @@ func (c *Connector) Download(ctx context.Context, src string, dst io.Writer) err
 	default:
 	}
 
+	if c.sudo {
+		cmd := exec.CommandContext(ctx, "sudo", c.sudoArgs("cat", "--", src)...)
+		cmd.Stdout = dst
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to read %s as %s: %s", src, c.becomeUser(), strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+
 	f, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open file %s: %w", src, err)
@@ -165,6 +249,11 @@ func (c *Connector) Download(ctx context.Context, src string, dst io.Writer) err
 	return nil
 }
 
+// Healthy always succeeds for local connections.
+func (c *Connector) Healthy(ctx context.Context) error {
+	return nil
+}
+
 // Close is a no-op for local connections.
 func (c *Connector) Close() error {
 	return nil
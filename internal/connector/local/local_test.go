@@ -0,0 +1,126 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSudo puts a sudo on PATH that logs its arguments and runs the
+// command after "--" as the current user.
+func fakeSudo(t *testing.T) (logPath string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	logPath = filepath.Join(dir, "sudo.log")
+	script := `#!/bin/sh
+echo "$@" >> ` + logPath + `
+while [ "$1" != "--" ]; do shift; done
+shift
+exec "$@"
+`
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logPath
+}
+
+func TestSudoUploadDownload(t *testing.T) {
+	logPath := fakeSudo(t)
+	ctx := context.Background()
+	conn := New(WithSudo("deploy"))
+
+	dst := filepath.Join(t.TempDir(), "motd")
+	if err := conn.Upload(ctx, strings.NewReader("welcome\n"), dst, 0640); err != nil {
+		t.Fatalf("Upload() error: %v", err)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %o, want 640", info.Mode().Perm())
+	}
+
+	var buf bytes.Buffer
+	if err := conn.Download(ctx, dst, &buf); err != nil {
+		t.Fatalf("Download() error: %v", err)
+	}
+	if buf.String() != "welcome\n" {
+		t.Errorf("downloaded %q", buf.String())
+	}
+
+	log, _ := os.ReadFile(logPath)
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("sudo ran %d times, want 2:\n%s", len(lines), log)
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "-u deploy -- ") {
+			t.Errorf("sudo %s: want it to run as deploy", line)
+		}
+	}
+}
+
+func TestSudoUploadFailure(t *testing.T) {
+	fakeSudo(t)
+	conn := New(WithSudo(""))
+
+	dst := filepath.Join(t.TempDir(), "missing", "motd")
+	err := conn.Upload(context.Background(), strings.NewReader("x"), dst, 0644)
+	if err == nil {
+		t.Fatal("expected error writing into a missing directory")
+	}
+	if !strings.Contains(err.Error(), "as root") {
+		t.Errorf("error = %v, want it to name the sudo user", err)
+	}
+}
+
+func TestExecuteArgv(t *testing.T) {
+	conn := New()
+	ctx := context.Background()
+
+	// No shell sees the arguments, so nothing in them is expanded
+	result, err := conn.ExecuteArgv(ctx, []string{"printf", "%s|", "a b", "$HOME", "'q'", "; true"})
+	if err != nil {
+		t.Fatalf("ExecuteArgv() error: %v", err)
+	}
+	if result.ExitCode != 0 || result.Stdout != "a b|$HOME|'q'|; true|" {
+		t.Errorf("ExecuteArgv() = %d %q", result.ExitCode, result.Stdout)
+	}
+
+	result, err = conn.ExecuteArgv(ctx, []string{"sh", "-c", "exit 3"})
+	if err != nil || result.ExitCode != 3 {
+		t.Errorf("ExecuteArgv(exit 3) = %+v, %v, want exit code 3", result, err)
+	}
+
+	for _, name := range []string{"bolt-no-such-command", "/no/such/command"} {
+		result, err = conn.ExecuteArgv(ctx, []string{name})
+		if err != nil || result.ExitCode != 127 {
+			t.Errorf("ExecuteArgv(%s) = %+v, %v, want exit code 127", name, result, err)
+		}
+	}
+}
+
+func TestSudoExecuteArgv(t *testing.T) {
+	logPath := fakeSudo(t)
+	conn := New(WithSudo("deploy"))
+
+	result, err := conn.ExecuteArgv(context.Background(), []string{"echo", "a b"})
+	if err != nil {
+		t.Fatalf("ExecuteArgv() error: %v", err)
+	}
+	if result.Stdout != "a b\n" {
+		t.Errorf("stdout = %q", result.Stdout)
+	}
+
+	log, _ := os.ReadFile(logPath)
+	if got := strings.TrimSpace(string(log)); got != "-u deploy -- echo a b" {
+		t.Errorf("sudo %s: want it to run echo as deploy", got)
+	}
+}
@@ -0,0 +1,205 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultProgressThreshold is the size from which a Metered connector
+// reports the progress of a transfer.
+const DefaultProgressThreshold = 16 << 20
+
+// DefaultProgressInterval is how often a Metered connector reports the
+// progress of a transfer.
+const DefaultProgressInterval = time.Second
+
+// Transfer is the progress of an upload or download.
+type Transfer struct {
+	// Op is "upload" or "download".
+	Op string
+
+	// Path is the file on the target.
+	Path string
+
+	// Bytes is the number of bytes transferred so far.
+	Bytes int64
+
+	// Total is the size of the file, or 0 if it is not known.
+	Total int64
+
+	// Elapsed is the time since the transfer started.
+	Elapsed time.Duration
+
+	// Done is set on the last report of a transfer.
+	Done bool
+}
+
+// Percent returns how much of the file was transferred, or -1 if its size
+// is not known.
+func (t Transfer) Percent() int {
+	if t.Total <= 0 {
+		return -1
+	}
+	return int(t.Bytes * 100 / t.Total)
+}
+
+// Rate returns the average transfer rate in bytes per second.
+func (t Transfer) Rate() float64 {
+	if t.Elapsed <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) / t.Elapsed.Seconds()
+}
+
+// Metered wraps a connector and reports the progress of large uploads and
+// downloads, so a long transfer does not leave a silent terminal. Progress
+// is measured as the connector reads the upload or writes the download; a
+// connector that stages a file before sending it reports the staging.
+type Metered struct {
+	Connector
+
+	// Threshold is the size from which transfers are reported. Transfers
+	// of unknown size are reported once they pass it.
+	Threshold int64
+
+	// Interval is how often a transfer is reported.
+	Interval time.Duration
+
+	// OnProgress is called with the progress of every reported transfer,
+	// and a last time with Done set when it ends.
+	OnProgress func(Transfer)
+}
+
+// WithProgress returns conn wrapped to report the progress of transfers
+// larger than DefaultProgressThreshold to onProgress.
+func WithProgress(conn Connector, onProgress func(Transfer)) *Metered {
+	return &Metered{
+		Connector:  conn,
+		Threshold:  DefaultProgressThreshold,
+		Interval:   DefaultProgressInterval,
+		OnProgress: onProgress,
+	}
+}
+
+// Upload uploads src, reporting its progress.
+func (m *Metered) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	total, _ := Size(src)
+	meter := m.meter("upload", dst, total)
+	err := m.Connector.Upload(ctx, &meterReader{r: src, meter: meter}, dst, mode)
+	meter.done()
+	return err
+}
+
+// Download downloads src, reporting its progress. The size of the file is
+// not known in advance.
+func (m *Metered) Download(ctx context.Context, src string, dst io.Writer) error {
+	meter := m.meter("download", src, 0)
+	err := m.Connector.Download(ctx, src, &meterWriter{w: dst, meter: meter})
+	meter.done()
+	return err
+}
+
+// ExecuteArgv runs argv on the wrapped connector.
+func (m *Metered) ExecuteArgv(ctx context.Context, argv []string) (*Result, error) {
+	return ExecuteArgv(ctx, m.Connector, argv...)
+}
+
+// Unwrap returns the wrapped connector.
+func (m *Metered) Unwrap() Connector {
+	return m.Connector
+}
+
+// meter starts measuring a transfer.
+func (m *Metered) meter(op, path string, total int64) *meter {
+	now := time.Now()
+	return &meter{
+		m:        m,
+		progress: Transfer{Op: op, Path: path, Total: total},
+		start:    now,
+		last:     now,
+	}
+}
+
+// meter tracks the progress of a single transfer.
+type meter struct {
+	m        *Metered
+	progress Transfer
+	start    time.Time
+	last     time.Time
+	reported bool
+}
+
+// add counts n more bytes and reports the progress if it is due.
+func (t *meter) add(n int) {
+	t.progress.Bytes += int64(n)
+	if t.m.OnProgress == nil || max(t.progress.Bytes, t.progress.Total) < t.m.Threshold {
+		return
+	}
+	now := time.Now()
+	if t.reported && now.Sub(t.last) < t.m.Interval {
+		return
+	}
+	t.last = now
+	t.reported = true
+	t.progress.Elapsed = now.Sub(t.start)
+	t.m.OnProgress(t.progress)
+}
+
+// done reports the end of a transfer that was reported before.
+func (t *meter) done() {
+	if !t.reported {
+		return
+	}
+	t.progress.Elapsed = time.Since(t.start)
+	t.progress.Done = true
+	t.m.OnProgress(t.progress)
+}
+
+// meterReader counts the bytes read from r.
+type meterReader struct {
+	r     io.Reader
+	meter *meter
+}
+
+func (r *meterReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.meter.add(n)
+	return n, err
+}
+
+// meterWriter counts the bytes written to w.
+type meterWriter struct {
+	w     io.Writer
+	meter *meter
+}
+
+func (w *meterWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.meter.add(n)
+	return n, err
+}
+
+// Size returns the number of bytes left in r, if it can tell without
+// reading r: for in-memory readers, regular files, and the readers
+// connectors wrap them in.
+func Size(r io.Reader) (int64, bool) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		pos, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		return info.Size() - pos, true
+	case *meterReader:
+		return Size(r.r)
+	}
+	return 0, false
+}
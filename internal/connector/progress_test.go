@@ -0,0 +1,108 @@
+package connector_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestMetered(t *testing.T) {
+	fake := connectortest.New()
+	var reports []connector.Transfer
+	conn := connector.WithProgress(fake, func(tr connector.Transfer) {
+		reports = append(reports, tr)
+	})
+	conn.Threshold = 1000
+	conn.Interval = 0
+
+	// Small transfers are not reported
+	if err := conn.Upload(context.Background(), strings.NewReader("small"), "/tmp/small", 0644); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 0 {
+		t.Fatalf("reported %+v for a small upload", reports)
+	}
+
+	content := bytes.Repeat([]byte("x"), 100000)
+	if err := conn.Upload(context.Background(), bytes.NewReader(content), "/tmp/big", 0644); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("reports = %+v, want progress and the end", reports)
+	}
+	first, last := reports[0], reports[len(reports)-1]
+	if first.Op != "upload" || first.Path != "/tmp/big" || first.Total != int64(len(content)) || first.Done {
+		t.Errorf("first report = %+v", first)
+	}
+	if !last.Done || last.Bytes != int64(len(content)) || last.Percent() != 100 {
+		t.Errorf("last report = %+v", last)
+	}
+	if data, _ := fake.File("/tmp/big"); !bytes.Equal(data, content) {
+		t.Errorf("uploaded %d bytes", len(data))
+	}
+
+	// Downloads of unknown size are reported once they pass the threshold
+	reports = nil
+	var buf bytes.Buffer
+	if err := conn.Download(context.Background(), "/tmp/big", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) == 0 || reports[0].Op != "download" || reports[0].Percent() != -1 || !reports[len(reports)-1].Done {
+		t.Errorf("download reports = %+v", reports)
+	}
+}
+
+func TestSize(t *testing.T) {
+	if n, ok := connector.Size(strings.NewReader("hello")); !ok || n != 5 {
+		t.Errorf("Size(strings.Reader) = %d, %v", n, ok)
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("0123456789"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := connector.Size(f); !ok || n != 6 {
+		t.Errorf("Size(file at 4) = %d, %v, want 6", n, ok)
+	}
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	if _, ok := connector.Size(pr); ok {
+		t.Error("Size(pipe) reported a size")
+	}
+
+	// Connectors see the reader a Metered connector wraps around src
+	fake := connectortest.New()
+	var seen int64
+	conn := connector.WithProgress(sizeRecorder{fake, &seen}, nil)
+	if err := conn.Upload(context.Background(), bytes.NewReader(make([]byte, 42)), "/tmp/f", 0644); err != nil {
+		t.Fatal(err)
+	}
+	if seen != 42 {
+		t.Errorf("wrapped Size = %d, want 42", seen)
+	}
+}
+
+// sizeRecorder records the size of the content of an upload.
+type sizeRecorder struct {
+	connector.Connector
+	size *int64
+}
+
+func (r sizeRecorder) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	*r.size, _ = connector.Size(src)
+	return r.Connector.Upload(ctx, src, dst, mode)
+}
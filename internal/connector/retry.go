@@ -0,0 +1,216 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// RetryPolicy controls how a Retrying connector retries transient
+// failures.
+type RetryPolicy struct {
+	// Attempts is the number of retries after the first attempt.
+	Attempts int
+
+	// Delay is the wait before the first retry. It doubles with every
+	// further retry, up to MaxDelay.
+	Delay time.Duration
+
+	// MaxDelay caps the wait between retries (default: no cap).
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by plays that do not configure connection
+// retries.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts: 2,
+	Delay:    time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+// backoff returns the wait before the given retry (1 for the first).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.Delay
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return d
+}
+
+// Transient reports whether err is a connectivity failure that may go away
+// on its own, such as a dropped connection.
+func Transient(err error) bool {
+	var connErr *ConnectivityError
+	return errors.As(err, &connErr) && connErr.Transient
+}
+
+// Retrying wraps a connector and retries operations that fail with a
+// transient error, backing off exponentially between attempts. Commands
+// that run and exit non-zero are never retried; that is what task-level
+// retries are for.
+type Retrying struct {
+	Connector
+
+	policy RetryPolicy
+
+	// OnRetry, if set, is called before every retry.
+	OnRetry func(op string, retry int, wait time.Duration, err error)
+}
+
+// WithRetry returns conn wrapped to retry transient failures according to
+// policy.
+func WithRetry(conn Connector, policy RetryPolicy) *Retrying {
+	return &Retrying{Connector: conn, policy: policy}
+}
+
+// Connect establishes the connection, retrying transient failures.
+func (r *Retrying) Connect(ctx context.Context) error {
+	return r.do(ctx, "connect", func() error {
+		return r.Connector.Connect(ctx)
+	})
+}
+
+// Execute runs a command, retrying if it could not be run because of a
+// transient failure.
+func (r *Retrying) Execute(ctx context.Context, cmd string) (*Result, error) {
+	var result *Result
+	err := r.do(ctx, "execute", func() error {
+		var err error
+		result, err = r.Connector.Execute(ctx, cmd)
+		return err
+	})
+	return result, err
+}
+
+// ExecuteArgv runs argv, retrying if it could not be run because of a
+// transient failure.
+func (r *Retrying) ExecuteArgv(ctx context.Context, argv []string) (*Result, error) {
+	var result *Result
+	err := r.do(ctx, "execute", func() error {
+		var err error
+		result, err = ExecuteArgv(ctx, r.Connector, argv...)
+		return err
+	})
+	return result, err
+}
+
+// Upload copies a file to the target. Uploads are only retried if src can
+// be rewound, since a failed attempt may have consumed part of it.
+func (r *Retrying) Upload(ctx context.Context, src io.Reader, dst string, mode uint32) error {
+	seeker, ok := src.(io.Seeker)
+	if !ok {
+		return r.Connector.Upload(ctx, src, dst, mode)
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return r.Connector.Upload(ctx, src, dst, mode)
+	}
+
+	first := true
+	return r.do(ctx, "upload", func() error {
+		if !first {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+		return r.Connector.Upload(ctx, src, dst, mode)
+	})
+}
+
+// Download copies a file from the target. Downloads are only retried if
+// nothing was written to dst yet.
+func (r *Retrying) Download(ctx context.Context, src string, dst io.Writer) error {
+	w := &countingWriter{w: dst}
+	return r.do(ctx, "download", func() error {
+		err := r.Connector.Download(ctx, src, w)
+		if err != nil && w.n > 0 {
+			// Retrying would duplicate what was already written
+			return permanent{err}
+		}
+		return err
+	})
+}
+
+// Unwrap returns the wrapped connector.
+func (r *Retrying) Unwrap() Connector {
+	return r.Connector
+}
+
+// Reconnect closes conn and connects it again. Unlike other operations, a
+// failed reconnect is retried whatever the error, since a target that just
+// went away (e.g., a restarting container) often refuses connections for a
+// while.
+func Reconnect(ctx context.Context, conn Connector, policy RetryPolicy, onRetry func(retry int, wait time.Duration, err error)) error {
+	// The policy's retries replace those of a Retrying connector
+	if r, ok := conn.(*Retrying); ok {
+		conn = r.Connector
+	}
+
+	for retry := 1; ; retry++ {
+		_ = conn.Close()
+		err := conn.Connect(ctx)
+		if err == nil || retry > policy.Attempts {
+			return err
+		}
+
+		wait := policy.backoff(retry)
+		if onRetry != nil {
+			onRetry(retry, wait, err)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// do runs op, retrying it while it fails with a transient error.
+func (r *Retrying) do(ctx context.Context, name string, op func() error) error {
+	for retry := 1; ; retry++ {
+		err := op()
+		var p permanent
+		if errors.As(err, &p) {
+			return p.err
+		}
+		if err == nil || !Transient(err) || retry > r.policy.Attempts {
+			return err
+		}
+
+		wait := r.policy.backoff(retry)
+		if r.OnRetry != nil {
+			r.OnRetry(name, retry, wait, err)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// permanent marks an error that must not be retried even if transient.
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
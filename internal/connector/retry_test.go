@@ -0,0 +1,118 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestRetryingExecute(t *testing.T) {
+	dropped := &connector.ConnectivityError{Target: "fake", Err: errors.New("unexpected EOF"), Transient: true}
+	refused := &connector.ConnectivityError{Target: "fake", Err: errors.New("no such container")}
+
+	tests := []struct {
+		name      string
+		setup     func(c *connectortest.Connector)
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name: "transient then success",
+			setup: func(c *connectortest.Connector) {
+				c.On("uptime").Error(dropped).Times(2)
+				c.On("uptime").Return("up")
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "transient exhausts attempts",
+			setup: func(c *connectortest.Connector) {
+				c.On("uptime").Error(dropped)
+			},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		{
+			name: "permanent not retried",
+			setup: func(c *connectortest.Connector) {
+				c.On("uptime").Error(refused)
+			},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name: "exit code not retried",
+			setup: func(c *connectortest.Connector) {
+				c.On("uptime").Fail(1, "boom")
+			},
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := connectortest.New()
+			tt.setup(fake)
+
+			var retries int
+			conn := connector.WithRetry(fake, connector.RetryPolicy{Attempts: 2, Delay: time.Millisecond})
+			conn.OnRetry = func(op string, retry int, wait time.Duration, err error) {
+				retries++
+			}
+
+			_, err := conn.Execute(context.Background(), "uptime")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(fake.Commands()); got != tt.wantCalls {
+				t.Errorf("ran %d times, want %d", got, tt.wantCalls)
+			}
+			if retries != tt.wantCalls-1 {
+				t.Errorf("OnRetry called %d times, want %d", retries, tt.wantCalls-1)
+			}
+		})
+	}
+}
+
+func TestRetryingStopsOnCancel(t *testing.T) {
+	fake := connectortest.New()
+	fake.On("uptime").Error(&connector.ConnectivityError{Err: errors.New("EOF"), Transient: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := connector.WithRetry(fake, connector.RetryPolicy{Attempts: 5, Delay: time.Hour})
+	conn.OnRetry = func(string, int, time.Duration, error) { cancel() }
+
+	if _, err := conn.Execute(ctx, "uptime"); !connector.Transient(err) {
+		t.Fatalf("Execute() error = %v, want the transient error", err)
+	}
+	if got := len(fake.Commands()); got != 1 {
+		t.Errorf("ran %d times, want 1", got)
+	}
+}
+
+func TestRetryingBackoff(t *testing.T) {
+	fake := connectortest.New()
+	fake.On("uptime").Error(&connector.ConnectivityError{Err: errors.New("EOF"), Transient: true})
+
+	var waits []time.Duration
+	conn := connector.WithRetry(fake, connector.RetryPolicy{Attempts: 4, Delay: time.Millisecond, MaxDelay: 3 * time.Millisecond})
+	conn.OnRetry = func(op string, retry int, wait time.Duration, err error) {
+		waits = append(waits, wait)
+	}
+
+	_, _ = conn.Execute(context.Background(), "uptime")
+
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 3 * time.Millisecond}
+	if len(waits) != len(want) {
+		t.Fatalf("waits = %v, want %v", waits, want)
+	}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("wait %d = %s, want %s", i+1, waits[i], want[i])
+		}
+	}
+}
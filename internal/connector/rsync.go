@@ -0,0 +1,27 @@
+package connector
+
+// Rsyncer is implemented by connectors whose target rsync can reach
+// through a remote shell, so that a file can be transferred as a delta
+// against the copy already on the target.
+type Rsyncer interface {
+	// RsyncShell returns the remote shell for rsync's -e option and the
+	// host to prefix remote paths with (e.g., "docker exec -i" and "web1").
+	RsyncShell() (rsh string, host string)
+}
+
+// As returns the first connector in the chain of wrapped connectors
+// starting at conn that implements T.
+func As[T any](conn Connector) (T, bool) {
+	for conn != nil {
+		if t, ok := conn.(T); ok {
+			return t, true
+		}
+		w, ok := conn.(interface{ Unwrap() Connector })
+		if !ok {
+			break
+		}
+		conn = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,24 @@
+package connector_test
+
+import (
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/connector/docker"
+)
+
+func TestAs(t *testing.T) {
+	conn := connector.WithRetry(docker.New("web1", docker.WithUser("app")), connector.DefaultRetryPolicy)
+	rsyncer, ok := connector.As[connector.Rsyncer](conn)
+	if !ok {
+		t.Fatal("As() found no Rsyncer behind the retrying connector")
+	}
+	if rsh, host := rsyncer.RsyncShell(); rsh != "docker exec -i -u app" || host != "web1" {
+		t.Errorf("RsyncShell() = %q, %q", rsh, host)
+	}
+
+	if _, ok := connector.As[connector.Rsyncer](connectortest.New()); ok {
+		t.Error("As() found an Rsyncer in a connector without one")
+	}
+}
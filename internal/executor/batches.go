@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/inventory"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// runBatches runs a play with an update strategy: its hosts in batches,
+// each between the pre_batch and post_batch hooks run on the delegate
+// host. A failing hook or host stops the play after its batch, so the
+// remaining batches keep serving.
+func (e *Executor) runBatches(ctx context.Context, pctxs []*PlayContext, hosts []*inventory.Host, roles []*playbook.Role, stats *Stats) error {
+	play := pctxs[0].Play
+	strategy := play.UpdateStrategy
+
+	delegate, err := e.prepareDelegate(ctx, play, strategy.GetDelegateTo(), hosts)
+	if err != nil {
+		e.failures = append(e.failures, Failure{Play: playName(play), Host: strategy.GetDelegateTo(), Error: err.Error()})
+		return fmt.Errorf("delegate %s: %w", strategy.GetDelegateTo(), err)
+	}
+
+	size := strategy.BatchSizeFor(len(pctxs))
+	count := (len(pctxs) + size - 1) / size
+	for i := 0; i < len(pctxs); i += size {
+		batch := pctxs[i:min(i+size, len(pctxs))]
+		number := i/size + 1
+
+		names := make([]string, len(batch))
+		batchHosts := make([]any, len(batch))
+		for j, pctx := range batch {
+			names[j] = pctx.Host.Name
+			batchHosts[j] = pctx.Host.Name
+		}
+		e.Output.Section(fmt.Sprintf("BATCH %d/%d: %s", number, count, strings.Join(names, ", ")))
+
+		// The hooks and the hosts of the batch see which batch runs
+		batchVars := map[string]any{
+			"batch_hosts":  batchHosts,
+			"batch_number": number,
+			"batch_count":  count,
+		}
+		delegate.Vars.Merge(LayerBuiltin, batchVars)
+		for _, pctx := range batch {
+			pctx.Vars.Merge(LayerBuiltin, batchVars)
+		}
+
+		if err := e.runHooks(ctx, delegate, strategy.PreBatch, stats); err != nil {
+			return fmt.Errorf("batch %d: pre_batch: %w", number, err)
+		}
+		if err := e.runHosts(ctx, batch, len(hosts), roles, stats); err != nil {
+			return fmt.Errorf("batch %d: %w", number, err)
+		}
+		if err := e.runHooks(ctx, delegate, strategy.PostBatch, stats); err != nil {
+			return fmt.Errorf("batch %d: post_batch: %w", number, err)
+		}
+	}
+	return nil
+}
+
+// prepareDelegate returns the play context of the host the hooks of an
+// update strategy run on: the inventory host of that name, or a host of
+// its own if the inventory has none.
+func (e *Executor) prepareDelegate(ctx context.Context, play *playbook.Play, name string, hosts []*inventory.Host) (*PlayContext, error) {
+	var host *inventory.Host
+	if e.Inventory != nil {
+		host, _ = e.Inventory.Host(name)
+	}
+	if host == nil {
+		literal, err := inventory.Literal([]string{name})
+		if err != nil {
+			return nil, err
+		}
+		host = literal[0]
+	}
+	return e.prepareHost(ctx, play, host, hosts, nil)
+}
+
+// runHooks runs the hook tasks of an update strategy on the delegate host.
+// Their notifications are ignored, since the play's handlers are meant for
+// its hosts.
+func (e *Executor) runHooks(ctx context.Context, pctx *PlayContext, tasks []*playbook.Task, stats *Stats) error {
+	for _, task := range tasks {
+		stats.Tasks++
+		if err := e.ensureConnected(ctx, pctx); err != nil {
+			if e.taskFailed(pctx, task, err, stats) {
+				continue
+			}
+			return playbook.ErrorAt(task.Pos, "", err)
+		}
+
+		result, err := e.runTask(ctx, pctx, task)
+		e.publishFacts(pctx)
+		if err != nil {
+			if e.taskFailed(pctx, task, err, stats) {
+				continue
+			}
+			return playbook.ErrorAt(task.Pos, "", err)
+		}
+		stats.count(result)
+		e.addChange(pctx, task, result)
+	}
+	return nil
+}
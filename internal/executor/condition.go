@@ -0,0 +1,170 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// boolExpr is a parsed boolean expression, as written in when conditions
+// and tag expressions. Operands are joined with or and and, negated with
+// not (binding tightest to loosest: not, and, or), and grouped with
+// parentheses. Anything else is an atom the caller evaluates: a
+// comparison or variable for when, a tag name for --tags.
+type boolExpr struct {
+	// op is "or", "and", "not", or "" for an atom.
+	op string
+
+	// atom is the text of an atom.
+	atom string
+
+	// operands are the operands of op.
+	operands []*boolExpr
+}
+
+// parseBoolExpr parses a boolean expression.
+func parseBoolExpr(s string) (*boolExpr, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("missing operand")
+	}
+
+	for _, op := range []string{"or", "and"} {
+		parts, err := splitKeyword(s, op)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 1 {
+			continue
+		}
+		x := &boolExpr{op: op}
+		for _, part := range parts {
+			operand, err := parseBoolExpr(part)
+			if err != nil {
+				return nil, err
+			}
+			x.operands = append(x.operands, operand)
+		}
+		return x, nil
+	}
+
+	if rest, ok := strings.CutPrefix(s, "not"); ok && isBoundary(s, len("not"), '(') {
+		operand, err := parseBoolExpr(rest)
+		if err != nil {
+			return nil, err
+		}
+		return &boolExpr{op: "not", operands: []*boolExpr{operand}}, nil
+	}
+
+	if inner, ok := parenthesized(s); ok {
+		return parseBoolExpr(inner)
+	}
+	return &boolExpr{atom: s}, nil
+}
+
+// eval evaluates the expression, with atom evaluating its atoms. and and
+// or stop at the first operand that decides their value, like Jinja.
+func (x *boolExpr) eval(atom func(string) (bool, error)) (bool, error) {
+	switch x.op {
+	case "not":
+		v, err := x.operands[0].eval(atom)
+		return !v, err
+	case "and", "or":
+		// and stops at the first false operand, or at the first true one
+		decisive := x.op == "or"
+		for _, operand := range x.operands {
+			v, err := operand.eval(atom)
+			if err != nil || v == decisive {
+				return v, err
+			}
+		}
+		return !decisive, nil
+	}
+	return atom(x.atom)
+}
+
+// atoms returns the atoms of the expression, in order.
+func (x *boolExpr) atoms() []string {
+	if x.op == "" {
+		return []string{x.atom}
+	}
+	var atoms []string
+	for _, operand := range x.operands {
+		atoms = append(atoms, operand.atoms()...)
+	}
+	return atoms
+}
+
+// splitKeyword splits s around the keyword operator kw where it stands
+// between spaces outside quoted strings and parentheses.
+func splitKeyword(s, kw string) ([]string, error) {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced ')' in %q", s)
+			}
+		case depth == 0 && strings.HasPrefix(s[i:], kw) && isBoundary(s, i-1, ')') && isBoundary(s, i+len(kw), '('):
+			parts = append(parts, s[start:i])
+			start = i + len(kw)
+			i = start - 1
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unbalanced '(' in %q", s)
+	}
+	return append(parts, s[start:]), nil
+}
+
+// parenthesized returns what is inside s if all of s is in parentheses.
+func parenthesized(s string) (string, bool) {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return "", false
+	}
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 && i < len(s)-1 {
+				// The first group closes early, as in "(a) == (b)"
+				return "", false
+			}
+		}
+	}
+	return s[1 : len(s)-1], true
+}
+
+// isBoundary reports whether a keyword operator may end or start next to
+// s[i]: at either end of s, at whitespace, or at the parenthesis paren.
+func isBoundary(s string, i int, paren byte) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	switch s[i] {
+	case ' ', '\t', '\n', paren:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// Error kinds exposed as the "error_kind" of a failed task's registered
+// result.
+const (
+	errorKindParam        = "param"
+	errorKindConnectivity = "connectivity"
+	errorKindCommand      = "command"
+	errorKindOffline      = "offline"
+	errorKindOther        = "error"
+)
+
+// ErrNotConfirmed fails a play or task whose confirmation was declined
+// or could not be asked for.
+var ErrNotConfirmed = errors.New("not confirmed")
+
+// errorKind classifies a task error by the typed error it wraps.
+func errorKind(err error) string {
+	var paramErr *module.ParamError
+	var connErr *connector.ConnectivityError
+	var cmdErr *module.CommandFailed
+	var offlineErr *module.OfflineError
+
+	switch {
+	case errors.As(err, &paramErr):
+		return errorKindParam
+	case errors.As(err, &connErr):
+		return errorKindConnectivity
+	case errors.As(err, &cmdErr):
+		return errorKindCommand
+	case errors.As(err, &offlineErr):
+		return errorKindOffline
+	}
+	return errorKindOther
+}
+
+// failedStatus returns the status of a task that failed with err:
+// "unreachable" if it could not reach its host, "failed" otherwise.
+func failedStatus(err error) string {
+	var connErr *connector.ConnectivityError
+	if errors.As(err, &connErr) {
+		return "unreachable"
+	}
+	return "failed"
+}
+
+// ignoresFailure reports whether task of play ignores that it failed with
+// err: ignore_errors ignores errors of the task, and ignore_unreachable
+// that the task could not reach its host, so one does not hide the other.
+func ignoresFailure(play *playbook.Play, task *playbook.Task, err error) bool {
+	if failedStatus(err) == "unreachable" {
+		return play.IgnoresUnreachable(task)
+	}
+	return task.IgnoreErrors
+}
+
+// retryable reports whether running a task again could succeed. Invalid
+// parameters fail the same way on every attempt, as does a task needing the
+// network in offline mode.
+func retryable(err error) bool {
+	var paramErr *module.ParamError
+	var offlineErr *module.OfflineError
+	return !errors.As(err, &paramErr) && !errors.As(err, &offlineErr)
+}
+
+// errorData returns the details of a failed command (rc, stdout, and
+// stderr), or nil if err does not wrap a CommandFailed.
+func errorData(err error) map[string]any {
+	var cmdErr *module.CommandFailed
+	if !errors.As(err, &cmdErr) {
+		return nil
+	}
+
+	data := map[string]any{
+		"rc":     cmdErr.RC,
+		"stdout": strings.TrimSpace(cmdErr.Stdout),
+		"stderr": strings.TrimSpace(cmdErr.Stderr),
+	}
+	if cmdErr.Cmd != "" {
+		data["cmd"] = cmdErr.Cmd
+	}
+	return data
+}
+
+// failedResult is the registered result of a task that failed with err, so
+// later tasks of a play with ignore_errors can inspect the failure.
+func failedResult(err error) map[string]any {
+	result := map[string]any{
+		"changed":    false,
+		"failed":     true,
+		"message":    err.Error(),
+		"error_kind": errorKind(err),
+	}
+	if data := errorData(err); data != nil {
+		result["data"] = data
+		result["rc"] = data["rc"]
+	}
+	return result
+}
@@ -3,18 +3,30 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/eugenetaranov/bolt/internal/connector"
 	"github.com/eugenetaranov/bolt/internal/connector/docker"
 	"github.com/eugenetaranov/bolt/internal/connector/local"
+	"github.com/eugenetaranov/bolt/internal/inventory"
+	"github.com/eugenetaranov/bolt/internal/lookup"
 	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
 	"github.com/eugenetaranov/bolt/internal/output"
 	"github.com/eugenetaranov/bolt/internal/playbook"
+	"github.com/eugenetaranov/bolt/internal/policy"
+	"github.com/eugenetaranov/bolt/internal/runlock"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+	"github.com/eugenetaranov/bolt/internal/state"
 	"github.com/eugenetaranov/bolt/pkg/facts"
 )
 
@@ -26,11 +38,145 @@ type Executor struct {
 	// DryRun only shows what would be done without making changes.
 	DryRun bool
 
+	// Diff shows what changed tasks changed (or, in a dry run, would
+	// change), including the content of the files they replace.
+	Diff bool
+
+	// Offline keeps modules from reaching the internet, for runs against
+	// air-gapped hosts provisioned from local mirrors. Modules skip
+	// optional network operations and fail tasks that need the network.
+	Offline bool
+
 	// Debug enables detailed output.
 	Debug bool
 
+	// State records the fingerprints of managed resources after each
+	// successful task. Nil disables recording.
+	State *state.State
+
+	// ExtraVars override play and role variables (e.g., from -e).
+	ExtraVars map[string]any
+
+	// StrictVars fails tasks that reference undefined variables instead of
+	// rendering them empty. Plays can override it with strict_vars.
+	StrictVars bool
+
+	// Progress shows a live status line per host. Nil disables it.
+	Progress *output.Progress
+
+	// ForceHandlers runs the handlers notified so far when a task fails,
+	// so changes already made (e.g., a written config) still take effect.
+	// Plays can override it with force_handlers.
+	ForceHandlers bool
+
+	// Inventory resolves the host patterns of plays. Without one, every
+	// name in a play's hosts is a host of its own.
+	Inventory *inventory.Inventory
+
+	// ModuleDefaults holds default parameters per module name for every
+	// play (e.g., from bolt.yaml). The module_defaults of a play take
+	// precedence.
+	ModuleDefaults map[string]map[string]any
+
+	// Policy holds the rules the tasks of every play must follow (e.g.,
+	// from bolt.yaml). A run whose tasks break any of them fails before
+	// its first task.
+	Policy []policy.Rule
+
+	// Limit restricts every play to the hosts matching these patterns
+	// (e.g., from --limit).
+	Limit []string
+
+	// Tags selects the tasks to run by their tags (e.g., from --tags): an
+	// expression of tag names joined with and, or, and not, such as
+	// "web and not slow". Empty selects every task but those tagged
+	// never. Handlers and the hooks of update strategies always run.
+	Tags string
+
+	// SkipTags skips the tasks whose tags match this expression, even
+	// those tagged always (e.g., from --skip-tags).
+	SkipTags string
+
+	// Lock takes the run lock on every host before its first task and
+	// holds it until the run ends, so concurrent runs against a host do
+	// not interleave changes (see package runlock).
+	Lock bool
+
+	// LockStaleAfter is how old a remote lock of another controller must
+	// be to be removed as left behind by a crashed run. Zero means
+	// runlock.DefaultStaleAfter.
+	LockStaleAfter time.Duration
+
+	// SuppressWarnings stops the warnings of the run from being printed
+	// at its end. They are still returned in the RunResult.
+	SuppressWarnings bool
+
+	// NewConnector, if set, creates the connector of every host instead of
+	// its connection type, e.g. to replay a recorded run.
+	NewConnector func(host *inventory.Host) (connector.Connector, error)
+
+	// WrapConnector, if set, wraps the connection to every host once it is
+	// connected and locked, e.g. to record the operations of the run.
+	WrapConnector func(host string, conn connector.Connector) connector.Connector
+
+	// OnTask, if set, is called with the result of every task on every
+	// host as it is reported, e.g. to stream the run to an API client.
+	// Tasks of parallel loops report concurrently.
+	OnTask func(TaskEvent)
+
+	// Confirm, if set, asks whether a play or task with confirm may run
+	// on a host, e.g. at a terminal or through an API. It is called for
+	// one confirmation at a time. Without it, such plays and tasks fail
+	// unless AssumeYes is set. Dry runs ask nothing, since they change
+	// nothing.
+	Confirm func(ctx context.Context, c Confirmation) (bool, error)
+
+	// AssumeYes approves every confirmation without asking (e.g., from
+	// --yes).
+	AssumeYes bool
+
+	// confirmMu makes confirmations of concurrent tasks wait their turn.
+	confirmMu sync.Mutex
+
+	// tags and skipTags are the parsed Tags and SkipTags of the run, or
+	// nil if unset.
+	tags, skipTags *boolExpr
+
 	// connectors caches connectors by host.
 	connectors map[string]connector.Connector
+
+	// hostvars holds the variables of every host the run has seen, by host
+	// name, including its facts once gathered. Tasks see it as hostvars.
+	hostvars map[string]any
+
+	// open lists the connectors that were connected, in order, so they
+	// are connected once and closed when the run ends.
+	open []connector.Connector
+
+	// becomeMu guards connectors and open while tasks that become another
+	// user than their play open their own connectors, which tasks of
+	// parallel loops and task graphs do concurrently.
+	becomeMu sync.Mutex
+
+	// lookups caches lookup results for the run.
+	lookups lookupCache
+
+	// warnings collects the warnings of the playbook and its roles.
+	warnings []playbook.Warning
+
+	// failures collects the failed tasks of the run.
+	failures []Failure
+
+	// changes collects the tasks of the run that changed their host.
+	changes []Change
+
+	// failedHosts lists the hosts that failed, in order. They are out of
+	// the run: later plays skip them.
+	failedHosts []string
+
+	// locks holds the run locks taken, by host name (or "local" for the
+	// controller), to release when the run ends.
+	locks map[string]func(context.Context) error
 }
 
 // New creates a new executor.
@@ -38,9 +184,17 @@ func New() *Executor {
 	return &Executor{
 		Output:     output.New(os.Stdout),
 		connectors: make(map[string]connector.Connector),
+		hostvars:   make(map[string]any),
+		locks:      make(map[string]func(context.Context) error),
 	}
 }
 
+// SetConnector makes plays use conn for the named host instead of creating
+// a connector from the host's connection type.
+func (e *Executor) SetConnector(host string, conn connector.Connector) {
+	e.connectors[host] = conn
+}
+
 // RunResult holds the result of a playbook run.
 type RunResult struct {
 	// Success is true if all plays completed successfully.
@@ -48,6 +202,92 @@ type RunResult struct {
 
 	// Stats holds execution statistics.
 	Stats *Stats
+
+	// Warnings are the non-fatal problems found in the playbook and its
+	// roles, such as deprecated directives or insecure parameters.
+	Warnings []playbook.Warning
+
+	// Failures are the tasks that failed the run. A host stops at its
+	// first failure, so there is one per failed host, unless connecting
+	// to a host or loading the play failed outside any task.
+	Failures []Failure
+
+	// FailedHosts are the hosts that failed, in order. A host that fails
+	// is taken out of the run while the other hosts continue; the run
+	// stops when a play has no hosts left.
+	FailedHosts []string
+
+	// Changes are the tasks that reported a change, in the order they
+	// ran.
+	Changes []Change
+}
+
+// Change is a task that changed a host during a run.
+type Change struct {
+	// Play is the name of the play (or its hosts if it has none).
+	Play string
+
+	// Host is the host the task changed.
+	Host string
+
+	// Task is the name of the task.
+	Task string
+}
+
+// Failure is a task that failed a run.
+type Failure struct {
+	// Play is the name of the play (or its hosts if it has none).
+	Play string
+
+	// Host is the host the task failed on, if any.
+	Host string
+
+	// Task is the name of the task, if any.
+	Task string
+
+	// Error is the error of the task.
+	Error string
+}
+
+// TaskEvent is the result of a task on a host, as passed to OnTask.
+type TaskEvent struct {
+	// Play is the name of the play (or its hosts if it has none).
+	Play string
+
+	// Host is the host the task ran on, if any.
+	Host string
+
+	// Task is the name of the task.
+	Task string
+
+	// Module is the module of the task.
+	Module string
+
+	// Status is ok, changed, failed, unreachable, or skipped.
+	Status string
+
+	// Message is the message of the result, or the error of the task.
+	Message string
+
+	// Duration is how long the task took.
+	Duration time.Duration
+}
+
+// Confirmation asks whether a play or task may run on a host, as passed
+// to Confirm.
+type Confirmation struct {
+	// Play is the name of the play (or its hosts if it has none).
+	Play string
+
+	// Host is the host the play or task is about to run on.
+	Host string
+
+	// Task is the name of the task, or empty to confirm the play.
+	Task string
+
+	// Question is the question of the confirm directive, with its
+	// variables rendered.
+	Question string
 }
 
 // Stats holds execution statistics.
@@ -58,8 +298,13 @@ type Stats struct {
 	Changed   int
 	Failed    int
 	Skipped   int
+	Unchecked int // tasks a dry run could not check
 	StartTime time.Time
 	EndTime   time.Time
+
+	// Unreachable counts the tasks that could not reach their host, which
+	// are not counted as failed.
+	Unreachable int
 }
 
 // Duration returns the total execution time.
@@ -76,35 +321,81 @@ func (s *Stats) GetChanged() int { return s.Changed }
 // GetFailed returns the Failed count (implements output.Stats).
 func (s *Stats) GetFailed() int { return s.Failed }
 
+// GetUnreachable returns the Unreachable count (implements output.Stats).
+func (s *Stats) GetUnreachable() int { return s.Unreachable }
+
 // GetSkipped returns the Skipped count (implements output.Stats).
 func (s *Stats) GetSkipped() int { return s.Skipped }
 
 // GetDuration returns the duration (implements output.Stats).
 func (s *Stats) GetDuration() time.Duration { return s.Duration() }
 
+// fail counts a task that ended with status, as returned by
+// failedStatus.
+func (s *Stats) fail(status string) {
+	if status == "unreachable" {
+		s.Unreachable++
+		return
+	}
+	s.Failed++
+}
+
+// count adds the result of a task that did not fail to the stats.
+func (s *Stats) count(result *TaskResult) {
+	switch result.Status {
+	case "ok":
+		s.OK++
+	case "changed":
+		s.Changed++
+	case "skipped":
+		s.Skipped++
+		if result.Unchecked {
+			s.Unchecked++
+		}
+	}
+}
+
 // PlayContext holds state for a play execution.
 type PlayContext struct {
 	// Play is the current play.
 	Play *playbook.Play
 
-	// Vars holds all variables (play vars + facts + registered).
-	Vars map[string]any
+	// Host is the host the play is running on.
+	Host *inventory.Host
 
-	// Facts holds gathered system facts.
-	Facts map[string]any
+	// Vars holds all variables visible to the play's tasks, including
+	// facts and registered results.
+	Vars *VarScope
 
-	// Registered holds task results stored via register.
-	Registered map[string]any
+	// Strict makes references to undefined variables fail the task.
+	Strict bool
 
-	// NotifiedHandlers tracks which handlers should run.
-	NotifiedHandlers map[string]bool
+	// NotifiedHandlers collects the notifications raised by changed tasks.
+	// They are resolved to handlers when the handlers run.
+	NotifiedHandlers map[Notification]bool
 
 	// Connector is the connection to the target.
 	Connector connector.Connector
+
+	// Task is the task being run, if any.
+	Task *playbook.Task
+
+	// KeepOwner is the user bolt connects to the host as, if the play has
+	// become and become_keep_ownership and it is not the become user.
+	// Files created by the keepOwnershipModules go to this user.
+	KeepOwner string
 }
 
 // Run executes a playbook.
 func (e *Executor) Run(ctx context.Context, pb *playbook.Playbook) (*RunResult, error) {
+	var err error
+	if e.tags, err = parseTags(e.Tags); err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+	if e.skipTags, err = parseTags(e.SkipTags); err != nil {
+		return nil, fmt.Errorf("skip tags: %w", err)
+	}
+
 	stats := &Stats{
 		StartTime: time.Now(),
 		Plays:     len(pb.Plays),
@@ -116,104 +407,355 @@ func (e *Executor) Run(ctx context.Context, pb *playbook.Playbook) (*RunResult,
 	}
 
 	e.Output.PlaybookStart(pb.Path)
+	e.warnings = nil
+	e.failures = nil
+	e.changes = nil
+	e.failedHosts = nil
+	e.addWarnings(pb.Warnings())
 
 	// Determine roles directory (relative to playbook)
 	rolesDir := filepath.Join(filepath.Dir(pb.Path), "roles")
 
-	for _, play := range pb.Plays {
+	// Check the policy before anything runs, so a run that breaks it
+	// changes no host
+	var plays []*playbook.Play
+	if err := e.checkPolicy(pb, rolesDir); err != nil {
+		result.Success = false
+		e.Output.Error("%v", err)
+	} else {
+		plays = pb.Plays
+	}
+
+	e.Progress.Start()
+
+	for _, play := range plays {
 		if err := e.runPlay(ctx, play, stats, rolesDir); err != nil {
 			result.Success = false
+			if len(e.failures) == 0 {
+				e.failures = append(e.failures, Failure{Play: playName(play), Error: err.Error()})
+			}
 			e.Output.Error("Play failed: %v", err)
 			break
 		}
 	}
 
+	e.Progress.Stop()
+
+	if err := e.Close(); err != nil {
+		e.Output.Warn("Failed to close connections: %v", err)
+	}
+
 	stats.EndTime = time.Now()
 	e.Output.PlaybookEnd(stats)
 
+	result.Warnings = e.warnings
+	result.Failures = e.failures
+	result.Changes = e.changes
+	result.FailedHosts = e.failedHosts
+	if len(e.failedHosts) > 0 {
+		result.Success = false
+	}
+	if !e.SuppressWarnings {
+		e.Output.Warnings(result.Warnings)
+	}
+
 	return result, nil
 }
 
-// runPlay executes a single play.
+// addWarnings collects warnings, skipping those already collected (e.g.,
+// of a role used by several plays).
+func (e *Executor) addWarnings(warnings []playbook.Warning) {
+	for _, w := range warnings {
+		if !slices.Contains(e.warnings, w) {
+			e.warnings = append(e.warnings, w)
+		}
+	}
+}
+
+// addFailure records that task failed the run on the host of pctx.
+func (e *Executor) addFailure(pctx *PlayContext, task *playbook.Task, err error) {
+	e.failures = append(e.failures, Failure{
+		Play:  playName(pctx.Play),
+		Host:  pctx.Host.Name,
+		Task:  task.String(),
+		Error: err.Error(),
+	})
+}
+
+// taskFailed counts task, which failed on the host of pctx with err, and
+// reports whether the host goes on with its next task, since the task
+// ignores the failure. Otherwise the failure is recorded for the run.
+func (e *Executor) taskFailed(pctx *PlayContext, task *playbook.Task, err error, stats *Stats) bool {
+	status := failedStatus(err)
+	e.Progress.TaskDone(pctx.Host.Name, status)
+	stats.fail(status)
+	if !ignoresFailure(pctx.Play, task, err) {
+		e.addFailure(pctx, task, err)
+		return false
+	}
+	// The error was shown with the task's result
+	e.Output.TaskResult(task.String(), status+" (ignored)", false, "")
+	return true
+}
+
+// addChange records that task changed the host of pctx, if its result
+// says so.
+func (e *Executor) addChange(pctx *PlayContext, task *playbook.Task, result *TaskResult) {
+	if result.Status != "changed" {
+		return
+	}
+	e.changes = append(e.changes, Change{
+		Play: playName(pctx.Play),
+		Host: pctx.Host.Name,
+		Task: task.String(),
+	})
+}
+
+// playName returns the name of the play, or its hosts if it has none.
+func playName(play *playbook.Play) string {
+	if play.Name != "" {
+		return play.Name
+	}
+	return play.Hosts
+}
+
+// runPlay executes a single play on each of its hosts in turn.
 func (e *Executor) runPlay(ctx context.Context, play *playbook.Play, stats *Stats, rolesDir string) error {
+	hosts, err := e.Hosts(play)
+	if err != nil {
+		return err
+	}
+
 	e.Output.PlayStart(play)
+	if len(hosts) == 0 {
+		e.Output.Warn("No hosts matched, skipping play")
+		return nil
+	}
+	live := e.liveHosts(hosts)
+	if len(live) == 0 {
+		e.Output.Warn("No hosts left, skipping play")
+		return nil
+	}
 
 	// Load roles if specified
 	var roles []*playbook.Role
 	if len(play.Roles) > 0 {
-		var err error
 		roles, err = playbook.LoadRoles(play.Roles, rolesDir)
 		if err != nil {
 			return fmt.Errorf("failed to load roles: %w", err)
 		}
+		for _, role := range roles {
+			play.ResolveModules(role.Tasks)
+			play.ResolveModules(role.Handlers)
+			e.addWarnings(role.Warnings())
+		}
+	}
+
+	// Connect to every host and gather facts before any host runs its
+	// tasks, so tasks can use the facts of all hosts through hostvars. A
+	// rolling update stops at a host it cannot prepare; otherwise the
+	// host is out of the run.
+	pctxs := make([]*PlayContext, 0, len(live))
+	var lastErr error
+	for _, host := range live {
+		pctx, err := e.prepareHost(ctx, play, host, hosts, roles)
+		if err != nil {
+			e.Progress.HostDone(host.Name, err)
+			e.failures = append(e.failures, Failure{Play: playName(play), Host: host.Name, Error: err.Error()})
+			e.hostFailed(host, err, len(hosts))
+			lastErr = hostError(host, err, len(hosts))
+			if play.UpdateStrategy != nil {
+				return lastErr
+			}
+			continue
+		}
+		pctxs = append(pctxs, pctx)
+	}
+	if len(pctxs) == 0 {
+		return lastErr
+	}
+
+	if play.UpdateStrategy != nil {
+		return e.runBatches(ctx, pctxs, hosts, roles, stats)
+	}
+	if err := e.runHosts(ctx, pctxs, len(hosts), roles, stats); err != nil && len(e.liveHosts(hosts)) == 0 {
+		return err
+	}
+	return nil
+}
+
+// runHosts runs the play on each prepared host in turn. A host that fails
+// is out of the run while the others continue; runHosts returns the error
+// of the last host that failed. hosts is the number of hosts of the play.
+func (e *Executor) runHosts(ctx context.Context, pctxs []*PlayContext, hosts int, roles []*playbook.Role, stats *Stats) error {
+	var lastErr error
+	for _, pctx := range pctxs {
+		if hosts > 1 {
+			e.Output.Section(fmt.Sprintf("HOST %s", pctx.Host.Name))
+		}
+		err := e.runHost(ctx, pctx, roles, stats)
+		e.Progress.HostDone(pctx.Host.Name, err)
+		if err != nil {
+			e.hostFailed(pctx.Host, err, hosts)
+			lastErr = hostError(pctx.Host, err, hosts)
+		}
+	}
+	return lastErr
+}
+
+// hostFailed takes host out of the run after err failed it. hosts is the
+// number of hosts of the play: the error of a play's only host is
+// reported as the play's.
+func (e *Executor) hostFailed(host *inventory.Host, err error, hosts int) {
+	e.failedHosts = append(e.failedHosts, host.Name)
+	if hosts > 1 {
+		e.Output.Error("Host %s failed: %v", host.Name, err)
+	}
+}
+
+// liveHosts returns the hosts that have not failed.
+func (e *Executor) liveHosts(hosts []*inventory.Host) []*inventory.Host {
+	var live []*inventory.Host
+	for _, host := range hosts {
+		if !slices.Contains(e.failedHosts, host.Name) {
+			live = append(live, host)
+		}
+	}
+	return live
+}
+
+// hostError adds the host's name to an error of a play with several
+// hosts.
+func hostError(host *inventory.Host, err error, hosts int) error {
+	if hosts > 1 {
+		return fmt.Errorf("%s: %w", host.Name, err)
 	}
+	return err
+}
 
-	// Create play context
+// prepareHost creates the play context for host, one of the play's hosts,
+// connects to it, and gathers its facts if the play asks for them.
+func (e *Executor) prepareHost(ctx context.Context, play *playbook.Play, host *inventory.Host, hosts []*inventory.Host, roles []*playbook.Role) (*PlayContext, error) {
 	pctx := &PlayContext{
 		Play:             play,
-		Vars:             make(map[string]any),
-		Facts:            make(map[string]any),
-		Registered:       make(map[string]any),
-		NotifiedHandlers: make(map[string]bool),
+		Host:             host,
+		Vars:             NewVarScope(),
+		Strict:           play.StrictVarsOr(e.StrictVars),
+		NotifiedHandlers: make(map[Notification]bool),
 	}
 
-	// Merge variables with correct precedence: role defaults < role vars < play vars
-	pctx.Vars = playbook.MergeRoleVars(roles, play.Vars)
-
-	// Add environment variables
-	pctx.Vars["env"] = getEnvMap()
+	hostvars := e.hostVarsFor(host)
+	pctx.Vars.Set(LayerBuiltin, "env", getEnvMap())
+	pctx.Vars.Set(LayerBuiltin, "inventory_hostname", host.Name)
+	pctx.Vars.Set(LayerBuiltin, "hostvars", e.hostvars)
+	pctx.Vars.Set(LayerBuiltin, "groups", e.groupsVar(hosts))
+	for _, role := range roles {
+		pctx.Vars.Merge(LayerRoleDefaults, role.Defaults)
+		pctx.Vars.Merge(LayerRoleVars, role.Vars)
+	}
+	pctx.Vars.Merge(LayerPlayVars, play.Vars)
+	pctx.Vars.Merge(LayerHostVars, host.Vars)
+	pctx.Vars.Merge(LayerExtraVars, e.ExtraVars)
 
-	// Get connector for this play
-	conn, err := e.getConnector(play)
+	// Get connector for this host; earlier plays may have connected it
+	// already
+	cached, err := e.ConnectorFor(play, host)
 	if err != nil {
-		return fmt.Errorf("failed to create connector: %w", err)
+		return nil, fmt.Errorf("failed to create connector: %w", err)
 	}
+	conn := e.withRetry(e.withProgress(cached, host), play)
 	pctx.Connector = conn
 
-	// Connect
-	if err := conn.Connect(ctx); err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+	if slices.Contains(e.open, cached) {
+		if err := e.ensureConnected(ctx, pctx); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := conn.Connect(ctx); err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		e.open = append(e.open, cached)
+	}
+
+	if e.Lock {
+		if err := e.lockHost(ctx, host, cached, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.WrapConnector != nil {
+		pctx.Connector = e.WrapConnector(host.Name, pctx.Connector)
+	}
+
+	if play.Become && play.BecomeKeepOwnership {
+		user, err := e.connectingUser(ctx, play, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine the connecting user: %w", err)
+		}
+		if user != play.GetBecomeUser() {
+			pctx.KeepOwner = user
+		}
 	}
 
 	// Gather facts if enabled
 	if play.ShouldGatherFacts() {
-		e.Output.TaskStart("Gathering Facts", "")
-		f, err := facts.Gather(ctx, conn)
+		name := "Gathering Facts"
+		if len(hosts) > 1 {
+			name += " [" + host.Name + "]"
+		}
+		e.Output.TaskStart(name, "")
+		f, err := facts.Gather(ctx, pctx.Connector)
 		if err != nil {
-			e.Output.TaskResult("Gathering Facts", "failed", false, err.Error())
-			return fmt.Errorf("failed to gather facts: %w", err)
+			e.Output.TaskResult(name, failedStatus(err), false, err.Error())
+			return nil, fmt.Errorf("failed to gather facts: %w", err)
 		}
-		pctx.Facts = f
-		pctx.Vars["facts"] = f
-		e.Output.TaskResult("Gathering Facts", "ok", false, "")
+		// Facts returned by modules in earlier plays are kept
+		if prev, ok := hostvars["facts"].(map[string]any); ok {
+			pctx.Vars.Set(LayerBuiltin, "facts", prev)
+		}
+		mergeFacts(pctx.Vars, f)
+		e.publishFacts(pctx)
+		e.Output.TaskResult(name, "ok", false, "")
+	} else if f, ok := hostvars["facts"]; ok {
+		// Facts gathered by an earlier play are still valid
+		pctx.Vars.Set(LayerBuiltin, "facts", f)
+	}
+
+	// Ask before the play runs on the host, with its facts known, so
+	// every host is approved before any of them changes
+	if err := e.confirm(ctx, pctx, nil); err != nil {
+		return nil, err
 	}
 
+	return pctx, nil
+}
+
+// runHost executes the tasks and handlers of a play on a single host.
+func (e *Executor) runHost(ctx context.Context, pctx *PlayContext, roles []*playbook.Role, stats *Stats) error {
+	play, host := pctx.Play, pctx.Host
+
 	// Expand role tasks and handlers
 	allTasks := playbook.ExpandRoleTasks(roles, play.Tasks)
 	allHandlers := playbook.ExpandRoleHandlers(roles, play.Handlers)
 
-	// Execute tasks
-	for _, task := range allTasks {
-		stats.Tasks++
+	selected := e.selectTasks(play, allTasks)
 
-		taskResult, err := e.runTask(ctx, pctx, task)
-		if err != nil {
-			stats.Failed++
-			if !task.IgnoreErrors {
-				return err
-			}
-			e.Output.TaskResult(task.String(), "failed (ignored)", false, err.Error())
-			continue
-		}
+	e.Progress.HostStart(host.Name, len(selected))
 
-		switch taskResult.Status {
-		case "ok":
-			stats.OK++
-		case "changed":
-			stats.Changed++
-		case "skipped":
-			stats.Skipped++
+	// Execute tasks; a task graph needs all tasks to resolve dependencies
+	var err error
+	if play.TaskGraph {
+		err = e.runTaskGraph(ctx, pctx, allTasks, stats)
+	} else {
+		err = e.runTasks(ctx, pctx, selected, stats)
+	}
+	if err != nil {
+		if play.ForceHandlersOr(e.ForceHandlers) {
+			// The task error is what failed the play; a failing
+			// handler is reported by its own task result
+			_ = e.runHandlersExpanded(ctx, pctx, stats, allHandlers)
 		}
+		return err
 	}
 
 	// Run notified handlers (using expanded handlers)
@@ -224,42 +766,112 @@ func (e *Executor) runPlay(ctx context.Context, play *playbook.Play, stats *Stat
 	return nil
 }
 
+// runTasks executes tasks on the host of pctx one by one, stopping at the
+// first task that fails without ignore_errors.
+func (e *Executor) runTasks(ctx context.Context, pctx *PlayContext, tasks []*playbook.Task, stats *Stats) error {
+	// Role tasks are only checked here, since roles are loaded after
+	// parsing
+	for _, task := range tasks {
+		if task.HasDependencies() {
+			return playbook.ErrorAt(task.Pos, task.String(), playbook.ErrNoTaskGraph)
+		}
+	}
+
+	host := pctx.Host
+	for _, task := range tasks {
+		stats.Tasks++
+
+		e.Progress.TaskStart(host.Name, task.String())
+		if err := e.ensureConnected(ctx, pctx); err != nil {
+			if e.taskFailed(pctx, task, err, stats) {
+				continue
+			}
+			return playbook.ErrorAt(task.Pos, "", err)
+		}
+
+		taskResult, err := e.runTask(ctx, pctx, task)
+		e.publishFacts(pctx)
+		if err != nil {
+			if e.taskFailed(pctx, task, err, stats) {
+				continue
+			}
+			return playbook.ErrorAt(task.Pos, "", err)
+		}
+
+		e.Progress.TaskDone(host.Name, taskResult.Status)
+		stats.count(taskResult)
+		e.addChange(pctx, task, taskResult)
+	}
+	return nil
+}
+
 // TaskResult holds the result of a task execution.
 type TaskResult struct {
-	Status  string // ok, changed, skipped, failed
+	Status  string // ok, changed, skipped, failed, unreachable
 	Changed bool
 	Data    map[string]any
 	Error   error
+
+	// Unchecked is set when a dry run skipped the task because its module
+	// does not support check mode.
+	Unchecked bool
 }
 
 // runTask executes a single task.
 func (e *Executor) runTask(ctx context.Context, pctx *PlayContext, task *playbook.Task) (*TaskResult, error) {
-	taskName := task.String()
+	// Task vars are visible to this task only, including its condition
+	defer enterTask(pctx, task)()
 
 	// Check 'when' condition
 	if task.When != "" {
 		shouldRun, err := e.evaluateCondition(task.When, pctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate 'when' condition: %w", err)
+			err = fmt.Errorf("failed to evaluate 'when' condition: %w", err)
+			e.reportTask(pctx, task, time.Now(), failedStatus(err), err.Error(), nil)
+			return nil, err
 		}
 		if !shouldRun {
-			e.Output.TaskResult(taskName, "skipped", false, "when condition not met")
+			e.reportTask(pctx, task, time.Now(), "skipped", "when condition not met", nil)
 			return &TaskResult{Status: "skipped"}, nil
 		}
 	}
 
+	// Ask once for all loop items
+	if err := e.confirm(ctx, pctx, task); err != nil {
+		e.reportTask(pctx, task, time.Now(), failedStatus(err), err.Error(), nil)
+		return nil, err
+	}
+
 	// Handle loops
 	if len(task.Loop) > 0 {
 		return e.runTaskLoop(ctx, pctx, task)
 	}
+	if len(task.FirstFound) > 0 {
+		return e.runTaskFirstFound(ctx, pctx, task)
+	}
 
 	// Run single task
 	return e.runSingleTask(ctx, pctx, task)
 }
 
+// enterTask makes task the current task of pctx, and its vars visible,
+// until the returned function is called.
+func enterTask(pctx *PlayContext, task *playbook.Task) func() {
+	pctx.Task = task
+	if len(task.Vars) == 0 {
+		return func() { pctx.Task = nil }
+	}
+	pctx.Vars.Merge(LayerTaskVars, task.Vars)
+	return func() {
+		pctx.Vars.Clear(LayerTaskVars)
+		pctx.Task = nil
+	}
+}
+
 // runSingleTask executes a task once.
 func (e *Executor) runSingleTask(ctx context.Context, pctx *PlayContext, task *playbook.Task) (*TaskResult, error) {
 	taskName := task.String()
+	start := time.Now()
 	e.Output.TaskStart(taskName, task.Module)
 
 	// Expand shorthand syntax
@@ -269,31 +881,78 @@ func (e *Executor) runSingleTask(ctx context.Context, pctx *PlayContext, task *p
 	mod := module.Get(task.Module)
 	if mod == nil {
 		err := fmt.Errorf("unknown module: %s", task.Module)
-		e.Output.TaskResult(taskName, "failed", false, err.Error())
+		e.reportTask(pctx, task, start, failedStatus(err), err.Error(), nil)
 		return nil, err
 	}
 
-	// Interpolate variables in params
-	params, err := e.interpolateParams(task.Params, pctx)
+	// Interpolate variables in params, with the module defaults merged
+	// under them
+	params, err := e.interpolateParams(e.taskParams(pctx.Play, task), pctx)
 	if err != nil {
-		e.Output.TaskResult(taskName, "failed", false, err.Error())
+		e.reportTask(pctx, task, start, failedStatus(err), err.Error(), nil)
 		return nil, fmt.Errorf("failed to interpolate parameters: %w", err)
 	}
+	if err := param.Validate(params, module.Params(mod)); err != nil {
+		e.reportTask(pctx, task, start, failedStatus(err), err.Error(), nil)
+		return nil, err
+	}
+
+	// Check creates/removes guards before running the module
+	if skip, reason, err := e.checkGuards(ctx, pctx, task); err != nil {
+		e.reportTask(pctx, task, start, failedStatus(err), err.Error(), nil)
+		return nil, err
+	} else if skip {
+		e.reportTask(pctx, task, start, "skipped", reason, nil)
+		return &TaskResult{Status: "skipped"}, nil
+	}
 
 	// Inject role path for role tasks (allows modules like copy to find role files)
 	if task.RolePath != "" {
 		params["_role_path"] = task.RolePath
 	}
 
+	// Files the task creates go to the connecting user
+	if pctx.KeepOwner != "" && keepOwnershipModules[task.Module] {
+		params[module.DefaultOwnerParam] = pctx.KeepOwner
+	}
+
 	// Inject template variables for template module
 	if task.Module == "template" {
-		params["_template_vars"] = pctx.Vars
+		vars, err := e.templateVars(pctx)
+		if err != nil {
+			e.reportTask(pctx, task, start, failedStatus(err), err.Error(), nil)
+			return nil, err
+		}
+		params["_template_vars"] = vars
+	}
+
+	// Export the proxy settings to the commands of the module, on the
+	// connector of the user the task becomes
+	conn, err := e.taskConnector(ctx, pctx, task)
+	if err != nil {
+		e.reportTask(pctx, task, start, failedStatus(err), err.Error(), nil)
+		return nil, err
+	}
+	conn, err = e.proxyConnector(conn, pctx, task)
+	if err != nil {
+		e.reportTask(pctx, task, start, failedStatus(err), err.Error(), nil)
+		return nil, err
 	}
 
-	// Handle dry run
+	// Handle dry run: modules that support check mode report what they
+	// would change, the rest are skipped
 	if e.DryRun {
-		e.Output.TaskResult(taskName, "skipped (dry run)", false, "")
-		return &TaskResult{Status: "skipped"}, nil
+		if !module.SupportsCheckMode(mod) {
+			e.reportTask(pctx, task, start, "skipped (dry run)", "", nil)
+			return &TaskResult{Status: "skipped", Unchecked: true}, nil
+		}
+		params[module.CheckModeParam] = true
+	}
+	if e.Offline {
+		params[module.OfflineParam] = true
+	}
+	if e.Diff {
+		params[module.DiffParam] = true
 	}
 
 	// Execute with retries
@@ -310,31 +969,47 @@ func (e *Executor) runSingleTask(ctx context.Context, pctx *PlayContext, task *p
 			time.Sleep(time.Duration(task.Delay) * time.Second)
 		}
 
-		result, lastErr = mod.Run(ctx, pctx.Connector, params)
-		if lastErr == nil {
+		result, lastErr = mod.Run(ctx, conn, params)
+
+		// failed_when and changed_when override what the module
+		// reported, so failed_when also decides whether to retry
+		if task.FailedWhen != "" || task.ChangedWhen != "" {
+			result, lastErr = e.applyResultConditions(pctx, task, result, lastErr)
+		}
+		if lastErr == nil || !retryable(lastErr) {
 			break
 		}
 	}
 
 	if lastErr != nil {
-		e.Output.TaskResult(taskName, "failed", false, lastErr.Error())
-		return &TaskResult{Status: "failed", Error: lastErr}, lastErr
+		// Register the failure so tasks after an ignored error can
+		// inspect it
+		if task.Register != "" {
+			pctx.Vars.Set(LayerRegistered, task.Register, failedResult(lastErr))
+		}
+		e.reportTask(pctx, task, start, failedStatus(lastErr), lastErr.Error(), errorData(lastErr))
+		return &TaskResult{Status: failedStatus(lastErr), Error: lastErr}, lastErr
+	}
+
+	// Record the applied state of managed resources
+	if e.State != nil && !e.DryRun {
+		e.recordState(ctx, pctx, task, params)
+	}
+
+	// Facts returned by the module are visible to later tasks
+	if facts, ok := result.Data[module.FactsKey].(map[string]any); ok {
+		mergeFacts(pctx.Vars, facts)
 	}
 
 	// Store registered result
 	if task.Register != "" {
-		pctx.Registered[task.Register] = map[string]any{
-			"changed": result.Changed,
-			"message": result.Message,
-			"data":    result.Data,
-		}
-		pctx.Vars[task.Register] = pctx.Registered[task.Register]
+		pctx.Vars.Set(LayerRegistered, task.Register, registeredResult(result))
 	}
 
 	// Handle notify
 	if result.Changed && len(task.Notify) > 0 {
-		for _, handler := range task.Notify {
-			pctx.NotifiedHandlers[handler] = true
+		for _, name := range task.Notify {
+			pctx.NotifiedHandlers[Notification{Name: name, RolePath: task.RolePath}] = true
 		}
 	}
 
@@ -344,7 +1019,15 @@ func (e *Executor) runSingleTask(ctx context.Context, pctx *PlayContext, task *p
 		status = "changed"
 	}
 
-	e.Output.TaskResult(taskName, status, result.Changed, result.Message)
+	display := status
+	if e.DryRun {
+		display += " (dry run)"
+	}
+
+	e.reportTask(pctx, task, start, display, result.Message, result.Data)
+	if e.Diff && result.Changed {
+		e.Output.Diff(display, result.Before, result.After)
+	}
 
 	return &TaskResult{
 		Status:  status,
@@ -353,15 +1036,258 @@ func (e *Executor) runSingleTask(ctx context.Context, pctx *PlayContext, task *p
 	}, nil
 }
 
+// registeredResult is the registered result of a task that succeeded
+// with result.
+func registeredResult(result *module.Result) map[string]any {
+	registered := map[string]any{
+		"changed": result.Changed,
+		"failed":  false,
+		"message": result.Message,
+		"data":    result.Data,
+	}
+	if result.Before != nil || result.After != nil {
+		registered["before"] = result.Before
+		registered["after"] = result.After
+	}
+	// Commands report their exit code, which failed results register as
+	// rc, so conditions can test rc either way
+	if rc, ok := result.Data["exit_code"]; ok {
+		registered["rc"] = rc
+	}
+	return registered
+}
+
+// applyResultConditions evaluates the failed_when and changed_when
+// conditions of task against the outcome of its module, result or err,
+// and returns the outcome they decide. The outcome is registered first, so
+// the conditions can refer to it (e.g., failed_when: "out.rc == 2"). A
+// failure that failed_when clears becomes an unchanged result, unless
+// changed_when says otherwise.
+func (e *Executor) applyResultConditions(pctx *PlayContext, task *playbook.Task, result *module.Result, err error) (*module.Result, error) {
+	if task.Register != "" {
+		if err != nil {
+			pctx.Vars.Set(LayerRegistered, task.Register, failedResult(err))
+		} else {
+			pctx.Vars.Set(LayerRegistered, task.Register, registeredResult(result))
+		}
+	}
+
+	if task.FailedWhen != "" {
+		failed, condErr := e.evaluateCondition(task.FailedWhen, pctx)
+		if condErr != nil {
+			return nil, fmt.Errorf("failed to evaluate 'failed_when' condition: %w", condErr)
+		}
+		switch {
+		case failed && err == nil:
+			return nil, fmt.Errorf("failed_when condition met: %s", task.FailedWhen)
+		case !failed && err != nil:
+			result = &module.Result{Message: err.Error(), Data: errorData(err)}
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if task.ChangedWhen != "" {
+		changed, condErr := e.evaluateCondition(task.ChangedWhen, pctx)
+		if condErr != nil {
+			return nil, fmt.Errorf("failed to evaluate 'changed_when' condition: %w", condErr)
+		}
+		result.Changed = changed
+	}
+	return result, nil
+}
+
+// taskParams returns the parameters of task with the module defaults of
+// the play, and then those of the executor, merged under them.
+func (e *Executor) taskParams(play *playbook.Play, task *playbook.Task) map[string]any {
+	params := play.TaskParams(task)
+	defaults := e.ModuleDefaults[task.Module]
+	if len(defaults) == 0 {
+		return params
+	}
+
+	merged := make(map[string]any, len(defaults)+len(params))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeFacts merges facts into the host facts of vars. The merged facts
+// are a new map, so neither the facts of loop items running in parallel
+// nor those already published in hostvars are written to.
+func mergeFacts(vars *VarScope, facts map[string]any) {
+	merged := make(map[string]any, len(facts))
+	if current, ok := vars.Get(LayerBuiltin, "facts"); ok {
+		if m, ok := current.(map[string]any); ok {
+			maps.Copy(merged, m)
+		}
+	}
+	maps.Copy(merged, facts)
+	vars.Set(LayerBuiltin, "facts", merged)
+}
+
+// publishFacts makes the facts of the host of pctx, including those
+// returned by modules, visible to other hosts and later plays.
+func (e *Executor) publishFacts(pctx *PlayContext) {
+	if facts, ok := pctx.Vars.Get(LayerBuiltin, "facts"); ok && pctx.Host != nil {
+		e.hostVarsFor(pctx.Host)["facts"] = facts
+	}
+}
+
+// reportTask prints the result of a task, and passes it to OnTask. In
+// verbose mode the line also shows the module, host, and how long the
+// task took.
+func (e *Executor) reportTask(pctx *PlayContext, task *playbook.Task, start time.Time, status, message string, data map[string]any) {
+	host := ""
+	if pctx.Host != nil {
+		host = pctx.Host.Name
+	}
+	duration := time.Since(start)
+	e.Output.TaskResultDetailed(task.String(), task.Module, host, status, message, duration, data)
+	if e.OnTask != nil {
+		e.OnTask(TaskEvent{
+			Play:     playName(pctx.Play),
+			Host:     host,
+			Task:     task.String(),
+			Module:   task.Module,
+			Status:   strings.TrimSuffix(status, " (dry run)"),
+			Message:  message,
+			Duration: duration,
+		})
+	}
+}
+
+// confirm asks whether task, or the play of pctx if task is nil, may run
+// on the host of pctx, if it has a confirm directive. It returns
+// ErrNotConfirmed unless the answer is yes.
+func (e *Executor) confirm(ctx context.Context, pctx *PlayContext, task *playbook.Task) error {
+	var question string
+	if task != nil {
+		question = task.Confirm
+	} else if pctx.Play != nil {
+		question = pctx.Play.Confirm
+	}
+	if question == "" || e.DryRun || e.AssumeYes {
+		return nil
+	}
+	if e.Confirm == nil {
+		return fmt.Errorf("%w: nobody to ask for confirmation", ErrNotConfirmed)
+	}
+
+	rendered, err := e.interpolateString(question, pctx)
+	if err != nil {
+		return fmt.Errorf("failed to interpolate 'confirm': %w", err)
+	}
+	c := Confirmation{Play: playName(pctx.Play), Question: fmt.Sprintf("%v", rendered)}
+	if pctx.Host != nil {
+		c.Host = pctx.Host.Name
+	}
+	if task != nil {
+		c.Task = task.String()
+	}
+
+	e.confirmMu.Lock()
+	ok, err := e.Confirm(ctx, c)
+	e.confirmMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotConfirmed, err)
+	}
+	if !ok {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+// recordState fingerprints the resources a task manages and stores them in
+// the executor's state. Failures are reported but do not fail the task.
+func (e *Executor) recordState(ctx context.Context, pctx *PlayContext, task *playbook.Task, params map[string]any) {
+	if pctx.Host == nil {
+		return
+	}
+
+	for _, r := range state.ResourcesFor(task.Module, params) {
+		fp, err := state.Fingerprint(ctx, pctx.Connector, r)
+		if err != nil {
+			e.Output.Debug("Not recording %s: %v", r, err)
+			continue
+		}
+		e.State.Record(pctx.Host.Name, &state.Entry{
+			Resource:    r,
+			Fingerprint: fp,
+			Task:        task.String(),
+			AppliedAt:   time.Now().UTC(),
+		})
+	}
+}
+
+// checkGuards evaluates the task-level creates and removes guards.
+// It returns true with a reason if the task should be skipped.
+func (e *Executor) checkGuards(ctx context.Context, pctx *PlayContext, task *playbook.Task) (bool, string, error) {
+	if task.Creates != "" {
+		path, err := e.interpolateString(task.Creates, pctx)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to interpolate 'creates': %w", err)
+		}
+		exists, err := pathExists(ctx, pctx.Connector, fmt.Sprintf("%v", path))
+		if err != nil {
+			return false, "", fmt.Errorf("failed to check 'creates' path: %w", err)
+		}
+		if exists {
+			return true, fmt.Sprintf("%v exists", path), nil
+		}
+	}
+
+	if task.Removes != "" {
+		path, err := e.interpolateString(task.Removes, pctx)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to interpolate 'removes': %w", err)
+		}
+		exists, err := pathExists(ctx, pctx.Connector, fmt.Sprintf("%v", path))
+		if err != nil {
+			return false, "", fmt.Errorf("failed to check 'removes' path: %w", err)
+		}
+		if !exists {
+			return true, fmt.Sprintf("%v does not exist", path), nil
+		}
+	}
+
+	return false, "", nil
+}
+
+// pathExists checks whether a path exists on the target.
+func pathExists(ctx context.Context, conn connector.Connector, path string) (bool, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("test -e %s", shellutil.Quote(path)))
+	if err != nil {
+		return false, err
+	}
+	return result.ExitCode == 0, nil
+}
+
 // runTaskLoop executes a task for each item in a loop.
 func (e *Executor) runTaskLoop(ctx context.Context, pctx *PlayContext, task *playbook.Task) (*TaskResult, error) {
+	// Collapse package name loops into a single module call
+	if squashed, ok := squashLoop(task); ok {
+		e.Output.Debug("Squashed %d loop items into one %s call", len(task.Loop), task.Module)
+		return e.runSingleTask(ctx, pctx, squashed)
+	}
+
+	if task.LoopParallel > 1 {
+		return e.runTaskLoopParallel(ctx, pctx, task)
+	}
+
 	loopVar := task.GetLoopVar()
-	var anyChanged bool
+	var anyChanged, unchecked bool
 
 	for i, item := range task.Loop {
 		// Set loop variable
-		pctx.Vars[loopVar] = item
-		pctx.Vars["loop_index"] = i
+		pctx.Vars.Set(LayerLoop, loopVar, item)
+		pctx.Vars.Set(LayerLoop, "loop_index", i)
 
 		result, err := e.runSingleTask(ctx, pctx, task)
 		if err != nil {
@@ -371,11 +1297,127 @@ func (e *Executor) runTaskLoop(ctx context.Context, pctx *PlayContext, task *pla
 		if result.Changed {
 			anyChanged = true
 		}
+		unchecked = result.Unchecked
+	}
+
+	// Clean up loop variables, uncovering any variables they shadowed
+	pctx.Vars.Delete(LayerLoop, loopVar)
+	pctx.Vars.Delete(LayerLoop, "loop_index")
+
+	// Every item uses the same module, so either all or none were checked
+	if unchecked {
+		return &TaskResult{Status: "skipped", Unchecked: true}, nil
+	}
+
+	status := "ok"
+	if anyChanged {
+		status = "changed"
+	}
+
+	return &TaskResult{Status: status, Changed: anyChanged}, nil
+}
+
+// runTaskFirstFound executes a task once, with the loop variable set to
+// the first of its candidate files that exists on the controller.
+func (e *Executor) runTaskFirstFound(ctx context.Context, pctx *PlayContext, task *playbook.Task) (*TaskResult, error) {
+	candidates := make([]string, 0, len(task.FirstFound))
+	for _, c := range task.FirstFound {
+		val, err := e.interpolateValue(c, pctx, 0)
+		if err != nil {
+			err = fmt.Errorf("with_first_found: %w", err)
+			e.reportTask(pctx, task, time.Now(), failedStatus(err), err.Error(), nil)
+			return nil, err
+		}
+		candidates = append(candidates, fmt.Sprintf("%v", val))
+	}
+
+	path, err := lookup.FindFirst(candidates, taskSearchPath(task))
+	if err != nil {
+		err = fmt.Errorf("with_first_found: %w", err)
+		e.reportTask(pctx, task, time.Now(), failedStatus(err), err.Error(), nil)
+		return nil, err
+	}
+
+	loopVar := task.GetLoopVar()
+	pctx.Vars.Set(LayerLoop, loopVar, path)
+	defer pctx.Vars.Delete(LayerLoop, loopVar)
+
+	return e.runSingleTask(ctx, pctx, task)
+}
+
+// runTaskLoopParallel executes loop items concurrently, at most
+// task.LoopParallel at a time, over the play's connector. Each item runs
+// against its own copy of the play context; notified handlers and the
+// registered result are merged back in item order once all items finish.
+func (e *Executor) runTaskLoopParallel(ctx context.Context, pctx *PlayContext, task *playbook.Task) (*TaskResult, error) {
+	loopVar := task.GetLoopVar()
+
+	// Expand once up front so the goroutines only read the task
+	playbook.ExpandShorthand(task)
+
+	results := make([]*TaskResult, len(task.Loop))
+	errs := make([]error, len(task.Loop))
+	items := make([]*PlayContext, len(task.Loop))
+
+	sem := make(chan struct{}, task.LoopParallel)
+	var wg sync.WaitGroup
+
+	for i, item := range task.Loop {
+		items[i] = pctx.forLoopItem(loopVar, i, item)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i], errs[i] = e.runSingleTask(ctx, items[i], task)
+		}(i)
+	}
+	wg.Wait()
+
+	var anyChanged bool
+	var firstErr error
+	registered := make([]any, 0, len(task.Loop))
+
+	for i, item := range items {
+		if task.Register != "" {
+			reg, _ := item.Vars.Lookup(task.Register)
+			registered = append(registered, reg)
+		}
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		if results[i].Changed {
+			anyChanged = true
+		}
+		for n := range item.NotifiedHandlers {
+			pctx.NotifiedHandlers[n] = true
+		}
+		if facts, ok := item.Vars.Get(LayerBuiltin, "facts"); ok {
+			if m, ok := facts.(map[string]any); ok {
+				mergeFacts(pctx.Vars, m)
+			}
+		}
 	}
 
-	// Clean up loop variables
-	delete(pctx.Vars, loopVar)
-	delete(pctx.Vars, "loop_index")
+	// Register the aggregated results of all items
+	if task.Register != "" {
+		pctx.Vars.Set(LayerRegistered, task.Register, map[string]any{
+			"changed": anyChanged,
+			"results": registered,
+		})
+	}
+
+	if firstErr != nil {
+		return &TaskResult{Status: failedStatus(firstErr), Error: firstErr}, firstErr
+	}
+	if results[0].Unchecked {
+		return &TaskResult{Status: "skipped", Unchecked: true}, nil
+	}
 
 	status := "ok"
 	if anyChanged {
@@ -385,25 +1427,65 @@ func (e *Executor) runTaskLoop(ctx context.Context, pctx *PlayContext, task *pla
 	return &TaskResult{Status: status, Changed: anyChanged}, nil
 }
 
+// forLoopItem returns a copy of the play context for a single loop item.
+// Vars are cloned so concurrent items do not share writable maps; the
+// connector is shared.
+func (pctx *PlayContext) forLoopItem(loopVar string, index int, item any) *PlayContext {
+	fork := pctx.fork()
+	fork.Vars.Set(LayerLoop, loopVar, item)
+	fork.Vars.Set(LayerLoop, "loop_index", index)
+	return fork
+}
+
+// fork returns a copy of the play context to run a task on concurrently
+// with others. Vars are cloned and notifications collected separately; the
+// connector is shared.
+func (pctx *PlayContext) fork() *PlayContext {
+	return &PlayContext{
+		Play:             pctx.Play,
+		Host:             pctx.Host,
+		Vars:             pctx.Vars.Clone(),
+		Strict:           pctx.Strict,
+		NotifiedHandlers: make(map[Notification]bool),
+		Connector:        pctx.Connector,
+		Task:             pctx.Task,
+		KeepOwner:        pctx.KeepOwner,
+	}
+}
+
 // runHandlersExpanded executes notified handlers from the expanded handlers list.
 func (e *Executor) runHandlersExpanded(ctx context.Context, pctx *PlayContext, stats *Stats, handlers []*playbook.Task) error {
 	if len(pctx.NotifiedHandlers) == 0 {
 		return nil
 	}
 
-	e.Output.Section("RUNNING HANDLERS")
+	notified, unknown := resolveHandlers(handlers, pctx.NotifiedHandlers)
+	for _, n := range unknown {
+		e.Output.Warn("notified handler '%s' is not defined", n.Name)
+	}
+	if len(notified) == 0 {
+		return nil
+	}
 
-	for _, handler := range handlers {
-		if !pctx.NotifiedHandlers[handler.Name] {
-			continue
-		}
+	e.Output.Section("RUNNING HANDLERS")
 
+	for _, handler := range notified {
 		stats.Tasks++
 
+		if err := e.ensureConnected(ctx, pctx); err != nil {
+			stats.fail(failedStatus(err))
+			e.addFailure(pctx, handler, err)
+			return playbook.ErrorAt(handler.Pos, fmt.Sprintf("handler '%s' failed", handler.Name), err)
+		}
+
+		leaveTask := enterTask(pctx, handler)
 		result, err := e.runSingleTask(ctx, pctx, handler)
+		leaveTask()
+		e.publishFacts(pctx)
 		if err != nil {
-			stats.Failed++
-			return fmt.Errorf("handler '%s' failed: %w", handler.Name, err)
+			stats.fail(failedStatus(err))
+			e.addFailure(pctx, handler, err)
+			return playbook.ErrorAt(handler.Pos, fmt.Sprintf("handler '%s' failed", handler.Name), err)
 		}
 
 		switch result.Status {
@@ -412,77 +1494,367 @@ func (e *Executor) runHandlersExpanded(ctx context.Context, pctx *PlayContext, s
 		case "changed":
 			stats.Changed++
 		}
+		e.addChange(pctx, handler, result)
 	}
 
 	return nil
 }
 
-// getConnector returns a connector for the play.
-func (e *Executor) getConnector(play *playbook.Play) (connector.Connector, error) {
-	connType := play.GetConnection()
+// proxyConnector returns conn wrapped to run commands with the proxy
+// settings of task, or conn itself if there are none.
+func (e *Executor) proxyConnector(conn connector.Connector, pctx *PlayContext, task *playbook.Task) (connector.Connector, error) {
+	env := pctx.Play.TaskProxy(task).Env()
+	for name, value := range env {
+		v, err := e.interpolateString(value, pctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to interpolate %s: %w", name, err)
+		}
+		env[name] = fmt.Sprintf("%v", v)
+		if env[name] == "" {
+			delete(env, name)
+		}
+	}
+	if len(env) == 0 {
+		return conn, nil
+	}
+	return connector.WithEnv(conn, env), nil
+}
+
+// taskConnector returns the connector task runs on: that of pctx, or for a
+// task whose become or become_user differs from its play's, a connector
+// of its own to the host, connected on first use.
+func (e *Executor) taskConnector(ctx context.Context, pctx *PlayContext, task *playbook.Task) (connector.Connector, error) {
+	play := pctx.Play
+	if play == nil {
+		return pctx.Connector, nil
+	}
+	become := task.ShouldBecome(play.Become)
+	user := play.BecomeUser
+	if become {
+		user = task.GetBecomeUser(play.BecomeUser)
+	}
+	if become == play.Become && user == play.BecomeUser {
+		return pctx.Connector, nil
+	}
+
+	e.becomeMu.Lock()
+	defer e.becomeMu.Unlock()
 
-	switch connType {
-	case "local":
+	escalated := *play
+	escalated.Become = become
+	escalated.BecomeUser = user
+	cached, err := e.ConnectorFor(&escalated, pctx.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connector: %w", err)
+	}
+	conn := e.withRetry(e.withProgress(cached, pctx.Host), play)
+	if !slices.Contains(e.open, cached) {
+		if err := conn.Connect(ctx); err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		e.open = append(e.open, cached)
+	}
+	if e.WrapConnector != nil {
+		conn = e.WrapConnector(pctx.Host.Name, conn)
+	}
+	return conn, nil
+}
+
+// withRetry wraps conn to retry transient connection failures as
+// configured by the play. These retries are independent of a task's
+// retries, which repeat the whole module run.
+func (e *Executor) withRetry(conn connector.Connector, play *playbook.Play) connector.Connector {
+	policy := retryPolicy(play)
+	if policy.Attempts == 0 {
+		return conn
+	}
+
+	retrying := connector.WithRetry(conn, policy)
+	retrying.OnRetry = func(op string, retry int, wait time.Duration, err error) {
+		e.Output.Info("Retrying %s on %s in %s (%d/%d): %v", op, conn, wait, retry, policy.Attempts, err)
+	}
+	return retrying
+}
+
+// withProgress wraps conn to report the progress of large uploads and
+// downloads, on the host's progress line if there is one.
+func (e *Executor) withProgress(conn connector.Connector, host *inventory.Host) connector.Connector {
+	return connector.WithProgress(conn, func(t connector.Transfer) {
+		if e.Progress != nil {
+			e.Progress.Transfer(host.Name, t)
+			return
+		}
+		e.Output.Transfer(host.Name, t)
+	})
+}
+
+// retryPolicy returns the connection retry policy configured by play.
+func retryPolicy(play *playbook.Play) connector.RetryPolicy {
+	policy := connector.DefaultRetryPolicy
+	if play.ConnectionRetries != nil {
+		policy.Attempts = *play.ConnectionRetries
+	}
+	if play.ConnectionRetryDelay != nil {
+		policy.Delay = time.Duration(*play.ConnectionRetryDelay) * time.Second
+	}
+	return policy
+}
+
+// ensureConnected checks the play's connection before a task and
+// reconnects if it was lost, so a connection that dropped during a long
+// play fails no more than the operation that was running at the time.
+func (e *Executor) ensureConnected(ctx context.Context, pctx *PlayContext) error {
+	conn := pctx.Connector
+	err := conn.Healthy(ctx)
+	if err == nil {
+		return nil
+	}
+
+	e.Output.Warn("Lost connection to %s: %v; reconnecting", conn, err)
+	policy := retryPolicy(pctx.Play)
+	err = connector.Reconnect(ctx, conn, policy, func(retry int, wait time.Duration, err error) {
+		e.Output.Info("Reconnecting to %s in %s (%d/%d): %v", conn, wait, retry, policy.Attempts, err)
+	})
+	if err != nil {
+		return &connector.ConnectivityError{
+			Target: conn.String(),
+			Err:    fmt.Errorf("failed to reconnect: %w", err),
+		}
+	}
+	return nil
+}
+
+// Close closes every connector the executor connected, most recent first.
+// Run calls it when the playbook ends, including when it was interrupted.
+func (e *Executor) Close() error {
+	var errs []error
+
+	// Release the locks while the connections are still open
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for name, unlock := range e.locks {
+		if err := unlock(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		delete(e.locks, name)
+	}
+
+	for i := len(e.open) - 1; i >= 0; i-- {
+		if err := e.open[i].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.open[i], err))
+		}
+	}
+	e.open = nil
+	return errors.Join(errs...)
+}
+
+// lockHost takes the run lock on host unless the run holds it already:
+// an flock on the controller for local connections, which the kernel
+// releases if bolt crashes, and a lock directory on the target otherwise.
+func (e *Executor) lockHost(ctx context.Context, host *inventory.Host, cached, conn connector.Connector) error {
+	name := host.Name
+	if _, ok := cached.(*local.Connector); ok {
+		// Every local host is the controller
+		name = "local"
+	}
+	if _, ok := e.locks[name]; ok {
+		return nil
+	}
+	if e.locks == nil {
+		e.locks = make(map[string]func(context.Context) error)
+	}
+
+	if name == "local" {
+		lock, err := runlock.File(runlock.LocalPath)
+		if err != nil {
+			return fmt.Errorf("failed to lock: %w", err)
+		}
+		e.locks[name] = func(context.Context) error { return lock.Unlock() }
+		return nil
+	}
+
+	staleAfter := e.LockStaleAfter
+	if staleAfter == 0 {
+		staleAfter = runlock.DefaultStaleAfter
+	}
+	lock, err := runlock.Host(ctx, conn, staleAfter)
+	if err != nil {
+		return fmt.Errorf("failed to lock: %w", err)
+	}
+	if lock.Broken != nil {
+		e.Output.Warn("Removed stale lock on %s held by %s", host.Name, lock.Broken)
+	}
+	e.locks[name] = lock.Unlock
+	return nil
+}
+
+// Hosts returns the hosts a play runs on: its host patterns resolved
+// through the inventory and restricted by the executor's limit.
+func (e *Executor) Hosts(play *playbook.Play) ([]*inventory.Host, error) {
+	var hosts []*inventory.Host
+	var err error
+	if e.Inventory != nil {
+		hosts, err = e.Inventory.Resolve(play.HostPatterns())
+	} else {
+		hosts, err = inventory.Literal(play.HostPatterns())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.Limit) > 0 {
+		hosts = inventory.Limit(hosts, e.Limit)
+	}
+	return hosts, nil
+}
+
+// ConnectorFor returns the connector a play runs against on host. The
+// host's connection type, if set in the inventory, overrides the play's.
+// Connectors are cached per host and settings, so later plays reuse them.
+func (e *Executor) ConnectorFor(play *playbook.Play, host *inventory.Host) (connector.Connector, error) {
+	if conn, ok := e.connectors[host.Name]; ok {
+		return conn, nil
+	}
+
+	connType := host.Connection
+	if connType == "" {
+		connType = play.GetConnection()
+	}
+
+	key := connType + "://" + host.Name
+	if play.Become {
+		key += "?become=" + play.GetBecomeUser()
+	}
+	if conn, ok := e.connectors[key]; ok {
+		return conn, nil
+	}
+
+	var conn connector.Connector
+	switch {
+	case e.NewConnector != nil:
+		var err error
+		if conn, err = e.NewConnector(host); err != nil {
+			return nil, err
+		}
+
+	case connType == "local":
 		var opts []local.Option
 		if play.Become {
 			opts = append(opts, local.WithSudo(play.GetBecomeUser()))
 		}
-		return local.New(opts...), nil
+		conn = local.New(opts...)
 
-	case "docker":
-		// For docker, hosts is the container name/ID
-		container := play.Hosts
+	case connType == "docker":
+		// For docker, the host's address is the container name/ID
 		var opts []docker.Option
 		if play.Become && play.BecomeUser != "" {
 			opts = append(opts, docker.WithUser(play.GetBecomeUser()))
 		}
-		return docker.New(container, opts...), nil
+		conn = docker.New(host.Address, opts...)
 
-	case "ssh":
+	case connType == "ssh":
 		return nil, fmt.Errorf("SSH connector not yet implemented")
 
-	case "ssm":
+	case connType == "ssm":
 		return nil, fmt.Errorf("SSM connector not yet implemented")
 
 	default:
 		return nil, fmt.Errorf("unknown connection type: %s", connType)
 	}
+
+	e.connectors[key] = conn
+	return conn, nil
 }
 
-// evaluateCondition evaluates a when condition.
-func (e *Executor) evaluateCondition(condition string, pctx *PlayContext) (bool, error) {
-	// Simple condition evaluation
-	// Supports: variable truthiness, comparisons, and registered results
+// keepOwnershipModules are the modules whose new files go to the
+// connecting user with become_keep_ownership (see
+// module.DefaultOwnerParam).
+var keepOwnershipModules = map[string]bool{
+	"copy":     true,
+	"template": true,
+}
+
+// connectingUser returns the user bolt connects to host as for play,
+// before privilege escalation: it runs id -un on the connector of the play
+// without become.
+func (e *Executor) connectingUser(ctx context.Context, play *playbook.Play, host *inventory.Host) (string, error) {
+	unprivileged := *play
+	unprivileged.Become = false
+	cached, err := e.ConnectorFor(&unprivileged, host)
+	if err != nil {
+		return "", err
+	}
+	conn := e.withRetry(cached, play)
+	if !slices.Contains(e.open, cached) {
+		if err := conn.Connect(ctx); err != nil {
+			return "", err
+		}
+		e.open = append(e.open, cached)
+	}
 
-	condition = strings.TrimSpace(condition)
+	result, err := conn.Execute(ctx, "id -un")
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("id -un failed: %s", strings.TrimSpace(result.Stderr))
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
 
-	// Check for negation
-	if strings.HasPrefix(condition, "not ") {
-		result, err := e.evaluateCondition(condition[4:], pctx)
-		return !result, err
+// evaluateCondition evaluates a when condition: comparisons, membership
+// tests, and variables joined with and, or, and not.
+func (e *Executor) evaluateCondition(condition string, pctx *PlayContext) (bool, error) {
+	expr, err := parseBoolExpr(condition)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition %q: %w", condition, err)
 	}
+	return expr.eval(func(atom string) (bool, error) {
+		return e.evaluateAtom(atom, pctx)
+	})
+}
 
+// evaluateAtom evaluates a condition without and, or, and not: variable
+// truthiness, comparisons, membership, and registered results.
+func (e *Executor) evaluateAtom(condition string, pctx *PlayContext) (bool, error) {
 	// Check for registered variable .changed
 	if strings.HasSuffix(condition, ".changed") {
 		varName := strings.TrimSuffix(condition, ".changed")
-		if reg, ok := pctx.Registered[varName]; ok {
-			if regMap, ok := reg.(map[string]any); ok {
-				if changed, ok := regMap["changed"].(bool); ok {
-					return changed, nil
-				}
+		reg, ok := pctx.Vars.Lookup(varName)
+		if !ok && pctx.Strict {
+			return false, &UndefinedError{Name: varName}
+		}
+		if regMap, ok := reg.(map[string]any); ok {
+			if changed, ok := regMap["changed"].(bool); ok {
+				return changed, nil
 			}
 		}
 		return false, nil
 	}
 
+	// Check for membership ('docker-ce' in facts.packages)
+	if left, right, ok := cutUnquoted(condition, " not in "); ok {
+		member, err := e.evaluateMembership(left, right, pctx)
+		return !member, err
+	}
+	if left, right, ok := cutUnquoted(condition, " in "); ok {
+		return e.evaluateMembership(left, right, pctx)
+	}
+
 	// Check for == comparison
 	if strings.Contains(condition, "==") {
 		parts := strings.SplitN(condition, "==", 2)
 		left := strings.TrimSpace(parts[0])
 		right := strings.TrimSpace(parts[1])
 
-		leftVal := e.resolveValue(left, pctx)
-		rightVal := e.resolveValue(right, pctx)
+		leftVal, err := e.resolveValue(left, pctx)
+		if err != nil {
+			return false, err
+		}
+		rightVal, err := e.resolveValue(right, pctx)
+		if err != nil {
+			return false, err
+		}
 
 		return fmt.Sprintf("%v", leftVal) == fmt.Sprintf("%v", rightVal), nil
 	}
@@ -493,55 +1865,122 @@ func (e *Executor) evaluateCondition(condition string, pctx *PlayContext) (bool,
 		left := strings.TrimSpace(parts[0])
 		right := strings.TrimSpace(parts[1])
 
-		leftVal := e.resolveValue(left, pctx)
-		rightVal := e.resolveValue(right, pctx)
+		leftVal, err := e.resolveValue(left, pctx)
+		if err != nil {
+			return false, err
+		}
+		rightVal, err := e.resolveValue(right, pctx)
+		if err != nil {
+			return false, err
+		}
 
 		return fmt.Sprintf("%v", leftVal) != fmt.Sprintf("%v", rightVal), nil
 	}
 
 	// Simple variable truthiness
-	val := e.resolveValue(condition, pctx)
+	val, err := e.resolveValue(condition, pctx)
+	if err != nil {
+		return false, err
+	}
 	return isTruthy(val), nil
 }
 
+// evaluateMembership reports whether the value of left is an item of a
+// list, a key of a map, or a substring of a string. An undefined container
+// has no members.
+func (e *Executor) evaluateMembership(left, right string, pctx *PlayContext) (bool, error) {
+	item, err := e.resolveValue(left, pctx)
+	if err != nil {
+		return false, err
+	}
+	container, err := e.resolveValue(right, pctx)
+	if err != nil {
+		return false, err
+	}
+
+	needle := fmt.Sprintf("%v", item)
+	switch c := container.(type) {
+	case nil:
+		return false, nil
+	case string:
+		return strings.Contains(c, needle), nil
+	case []any:
+		return slices.ContainsFunc(c, func(v any) bool { return fmt.Sprintf("%v", v) == needle }), nil
+	case []string:
+		return slices.Contains(c, needle), nil
+	case map[string]any:
+		_, ok := c[needle]
+		return ok, nil
+	case map[string]string:
+		_, ok := c[needle]
+		return ok, nil
+	default:
+		return false, fmt.Errorf("cannot test membership in %s: not a list, map, or string", strings.TrimSpace(right))
+	}
+}
+
+// cutUnquoted slices s around the first instance of sep that is not inside
+// a quoted string.
+func cutUnquoted(s, sep string) (before, after string, found bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case strings.HasPrefix(s[i:], sep):
+			return s[:i], s[i+len(sep):], true
+		}
+	}
+	return s, "", false
+}
+
+// varNamePattern matches bare variable names and dotted paths.
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z0-9_]+)*$`)
+
 // resolveValue resolves a value that might be a variable reference.
-func (e *Executor) resolveValue(s string, pctx *PlayContext) any {
+func (e *Executor) resolveValue(s string, pctx *PlayContext) (any, error) {
 	s = strings.TrimSpace(s)
 
 	// String literal
 	if (strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'")) ||
 		(strings.HasPrefix(s, "\"") && strings.HasSuffix(s, "\"")) {
-		return s[1 : len(s)-1]
+		return s[1 : len(s)-1], nil
 	}
 
 	// Boolean literals
 	if s == "true" || s == "True" {
-		return true
+		return true, nil
 	}
 	if s == "false" || s == "False" {
-		return false
+		return false, nil
 	}
 
-	// Variable lookup
-	if val, ok := pctx.Vars[s]; ok {
-		return val
+	// Variable lookup, including dotted paths (e.g., facts.os)
+	val, ok, err := e.lookupVariable(s, pctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return val, nil
 	}
 
-	// Dotted variable lookup (e.g., facts.os)
+	// In strict mode anything that looks like a variable must be defined,
+	// so string literals have to be quoted
+	if pctx.Strict && varNamePattern.MatchString(s) {
+		return nil, &UndefinedError{Name: s}
+	}
+
+	// Unresolved dotted paths are undefined variables, anything else is
+	// a bare literal
 	if strings.Contains(s, ".") {
-		parts := strings.Split(s, ".")
-		var current any = pctx.Vars
-		for _, part := range parts {
-			if m, ok := current.(map[string]any); ok {
-				current = m[part]
-			} else {
-				return nil
-			}
-		}
-		return current
+		return nil, nil
 	}
 
-	return s
+	return s, nil
 }
 
 // isTruthy returns whether a value is considered truthy.
@@ -1,13 +1,34 @@
 package executor
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/connector/local"
+	"github.com/eugenetaranov/bolt/internal/inventory"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+	"github.com/eugenetaranov/bolt/internal/policy"
+	"github.com/eugenetaranov/bolt/internal/runlock"
 )
 
 func TestEvaluateCondition(t *testing.T) {
 	exec := New()
 	pctx := &PlayContext{
-		Vars: map[string]any{
+		Vars: testScope(map[string]any{
 			"enabled":   true,
 			"disabled":  false,
 			"name":      "test",
@@ -15,17 +36,18 @@ func TestEvaluateCondition(t *testing.T) {
 			"count":     5,
 			"os_family": "Debian",
 			"facts": map[string]any{
-				"os": "linux",
+				"os":       "linux",
+				"packages": map[string]any{"docker-ce": map[string]any{"version": "24.0"}},
 			},
-		},
-		Registered: map[string]any{
+			"roles": []any{"web", "db"},
+		}, map[string]any{
 			"result": map[string]any{
 				"changed": true,
 			},
 			"unchanged": map[string]any{
 				"changed": false,
 			},
-		},
+		}),
 	}
 
 	tests := []struct {
@@ -58,9 +80,31 @@ func TestEvaluateCondition(t *testing.T) {
 		{"registered changed", "result.changed", true},
 		{"registered not changed", "unchanged.changed", false},
 
+		// Membership
+		{"in map", "'docker-ce' in facts.packages", true},
+		{"not in map", "'podman' not in facts.packages", true},
+		{"in list", "'db' in roles", true},
+		{"not in list", "'web' not in roles", false},
+		{"in string", "'ebi' in os_family", true},
+		{"in undefined", "'web' in missing", false},
+		{"quoted in", "name == 'test in here'", false},
+		{"negated in", "not 'web' in roles", false},
+
 		// Boolean literals
 		{"literal true", "true", true},
 		{"literal false", "false", false},
+
+		// Boolean operators
+		{"and", "enabled and os_family == 'Debian'", true},
+		{"and false", "enabled and disabled", false},
+		{"or", "disabled or count", true},
+		{"or false", "disabled or empty", false},
+		{"not binds tighter", "not disabled and enabled", true},
+		{"and binds tighter", "enabled or disabled and disabled", true},
+		{"parentheses", "(enabled or disabled) and disabled", false},
+		{"negated group", "not (disabled or empty)", true},
+		{"quoted operator", "name == 'a or b'", false},
+		{"membership and", "'db' in roles and 'podman' not in facts.packages", true},
 	}
 
 	for _, tt := range tests {
@@ -75,6 +119,12 @@ func TestEvaluateCondition(t *testing.T) {
 			}
 		})
 	}
+
+	for _, condition := range []string{"enabled and", "(enabled", "enabled)", "enabled or or disabled"} {
+		if _, err := exec.evaluateCondition(condition, pctx); err == nil {
+			t.Errorf("%q: expected an error", condition)
+		}
+	}
 }
 
 func TestIsTruthy(t *testing.T) {
@@ -127,13 +177,12 @@ func TestIsTruthy(t *testing.T) {
 func TestResolveValue(t *testing.T) {
 	exec := New()
 	pctx := &PlayContext{
-		Vars: map[string]any{
+		Vars: testScope(map[string]any{
 			"myvar": "myvalue",
 			"nested": map[string]any{
 				"key": "nested_value",
 			},
-		},
-		Registered: make(map[string]any),
+		}, nil),
 	}
 
 	tests := []struct {
@@ -154,7 +203,10 @@ func TestResolveValue(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := exec.resolveValue(tt.input, pctx)
+			got, err := exec.resolveValue(tt.input, pctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 			if got != tt.want {
 				t.Errorf("resolveValue(%q) = %v, want %v", tt.input, got, tt.want)
 			}
@@ -197,3 +249,1610 @@ func TestGetEnvMap(t *testing.T) {
 		t.Log("PATH not found in environment (might be ok in some test environments)")
 	}
 }
+
+func TestCheckGuards(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "present")
+	if err := os.WriteFile(existing, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	exec := New()
+	pctx := &PlayContext{
+		Vars:      testScope(map[string]any{"dir": dir}, nil),
+		Connector: local.New(),
+	}
+
+	tests := []struct {
+		name     string
+		creates  string
+		removes  string
+		wantSkip bool
+	}{
+		{"no guards", "", "", false},
+		{"creates exists", existing, "", true},
+		{"creates missing", missing, "", false},
+		{"removes exists", "", existing, false},
+		{"removes missing", "", missing, true},
+		{"creates interpolated", "{{ dir }}/present", "", true},
+		{"both pass", missing, existing, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &playbook.Task{Creates: tt.creates, Removes: tt.removes}
+			skip, reason, err := exec.checkGuards(context.Background(), pctx, task)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if skip != tt.wantSkip {
+				t.Errorf("skip = %v, want %v (reason: %q)", skip, tt.wantSkip, reason)
+			}
+		})
+	}
+}
+
+// concurrencyModule records how many runs are in flight at once.
+type concurrencyModule struct {
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (m *concurrencyModule) Name() string { return "test_concurrency" }
+
+func (m *concurrencyModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	n := m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
+	for {
+		peak := m.peak.Load()
+		if n <= peak || m.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return module.ChangedWithData("done", map[string]any{"item": params["item"]}), nil
+}
+
+var testConcurrency = &concurrencyModule{}
+
+func init() {
+	module.Register(testConcurrency)
+}
+
+func TestRunTaskLoopParallel(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	pctx := &PlayContext{
+		Vars:             testScope(nil, nil),
+		NotifiedHandlers: make(map[Notification]bool),
+		Connector:        local.New(),
+	}
+
+	task := &playbook.Task{
+		Name:         "parallel",
+		Module:       "test_concurrency",
+		Params:       map[string]any{"item": "{{ item }}"},
+		Loop:         []any{"a", "b", "c", "d", "e", "f"},
+		LoopParallel: 3,
+		Register:     "out",
+		Notify:       []string{"restart"},
+	}
+
+	testConcurrency.peak.Store(0)
+	result, err := exec.runTask(context.Background(), pctx, task)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Status != "changed" {
+		t.Errorf("status = %q, want changed", result.Status)
+	}
+	if peak := testConcurrency.peak.Load(); peak < 2 || peak > 3 {
+		t.Errorf("peak concurrency = %d, want between 2 and 3", peak)
+	}
+	if !pctx.NotifiedHandlers[Notification{Name: "restart"}] {
+		t.Error("expected handler 'restart' to be notified")
+	}
+	if _, ok := pctx.Vars.Lookup("item"); ok {
+		t.Error("loop variable leaked into play vars")
+	}
+
+	out, _ := pctx.Vars.Lookup("out")
+	reg, ok := out.(map[string]any)
+	if !ok {
+		t.Fatalf("registered result missing: %v", out)
+	}
+	results, ok := reg["results"].([]any)
+	if !ok || len(results) != len(task.Loop) {
+		t.Fatalf("expected %d results, got %v", len(task.Loop), reg["results"])
+	}
+	// Results keep loop order regardless of completion order
+	for i, r := range results {
+		data := r.(map[string]any)["data"].(map[string]any)
+		if data["item"] != task.Loop[i] {
+			t.Errorf("result %d item = %v, want %v", i, data["item"], task.Loop[i])
+		}
+	}
+}
+
+// checkModeModule supports check mode and records the mode it ran in.
+type checkModeModule struct {
+	checked atomic.Bool
+}
+
+func (m *checkModeModule) Name() string { return "test_check_mode" }
+
+func (m *checkModeModule) SupportsCheckMode() bool { return true }
+
+func (m *checkModeModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	m.checked.Store(module.IsCheckMode(params))
+	return module.Changed("would change"), nil
+}
+
+var testCheckMode = &checkModeModule{}
+
+func init() {
+	module.Register(testCheckMode)
+}
+
+func TestDryRunCheckMode(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	exec.DryRun = true
+
+	pctx := &PlayContext{
+		Vars:             testScope(nil, nil),
+		NotifiedHandlers: make(map[Notification]bool),
+		Connector:        local.New(),
+	}
+
+	t.Run("supported", func(t *testing.T) {
+		task := &playbook.Task{Module: "test_check_mode", Params: map[string]any{}}
+		result, err := exec.runTask(context.Background(), pctx, task)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !testCheckMode.checked.Load() {
+			t.Error("expected module to run in check mode")
+		}
+		if result.Status != "changed" || result.Unchecked {
+			t.Errorf("got status %q (unchecked %v), want changed", result.Status, result.Unchecked)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		task := &playbook.Task{Module: "test_concurrency", Params: map[string]any{}}
+		result, err := exec.runTask(context.Background(), pctx, task)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Status != "skipped" || !result.Unchecked {
+			t.Errorf("got status %q (unchecked %v), want unchecked skip", result.Status, result.Unchecked)
+		}
+	})
+
+	t.Run("unsupported loop", func(t *testing.T) {
+		task := &playbook.Task{
+			Module: "test_concurrency",
+			Params: map[string]any{"item": "{{ item }}"},
+			Loop:   []any{"a", "b"},
+		}
+		result, err := exec.runTask(context.Background(), pctx, task)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Unchecked {
+			t.Error("expected loop to be reported as unchecked")
+		}
+	})
+}
+
+func TestRunPlayForceHandlers(t *testing.T) {
+	tests := []struct {
+		name      string
+		play      *bool
+		flag      bool
+		wantForce bool
+	}{
+		{"default", nil, false, false},
+		{"flag", nil, true, true},
+		{"play", boolPtr(true), false, true},
+		{"play overrides flag", boolPtr(false), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := New()
+			exec.Output = output.New(io.Discard)
+			exec.ForceHandlers = tt.flag
+
+			play := &playbook.Play{
+				Hosts:         "localhost",
+				GatherFacts:   boolPtr(false),
+				ForceHandlers: tt.play,
+				Tasks: []*playbook.Task{
+					{Module: "test_concurrency", Params: map[string]any{}, Notify: []string{"restart"}},
+					{Module: "does_not_exist", Params: map[string]any{}},
+				},
+				Handlers: []*playbook.Task{
+					{Name: "restart", Module: "test_record", Params: map[string]any{"value": "restarted"}},
+				},
+			}
+			exec.SetConnector(play.Hosts, local.New())
+
+			testRecord.last.Store("")
+			stats := &Stats{}
+			if err := exec.runPlay(context.Background(), play, stats, t.TempDir()); err == nil {
+				t.Fatal("expected the failing task to fail the play")
+			}
+
+			ran := testRecord.last.Load() == "restarted"
+			if ran != tt.wantForce {
+				t.Errorf("handler ran = %v, want %v", ran, tt.wantForce)
+			}
+		})
+	}
+}
+
+// failModule fails every run with the error selected by its kind
+// parameter and counts its runs.
+type failModule struct {
+	runs atomic.Int32
+}
+
+func (m *failModule) Name() string { return "test_fail" }
+
+func (m *failModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	m.runs.Add(1)
+	if params["kind"] == "param" {
+		return nil, module.ParamErrorf("state", "invalid state 'gone'")
+	}
+	if params["kind"] == "offline" && module.IsOffline(params) {
+		return nil, module.OfflineErrorf("downloading https://example.com/app.deb", "")
+	}
+	return nil, module.CommandFailedf(&connector.Result{ExitCode: 3, Stderr: "lock held\n"}, "apt-get install failed")
+}
+
+var testFail = &failModule{}
+
+func init() {
+	module.Register(testFail)
+}
+
+func TestRunTaskRegistersFailure(t *testing.T) {
+	tests := []struct {
+		kind     string
+		wantRuns int32
+		wantRC   any
+	}{
+		{"param", 1, nil},
+		{"command", 3, 3},
+		{"offline", 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			exec := New()
+			exec.Output = output.New(io.Discard)
+			exec.Offline = tt.kind == "offline"
+
+			pctx := &PlayContext{
+				Vars:             NewVarScope(),
+				NotifiedHandlers: make(map[Notification]bool),
+				Connector:        local.New(),
+			}
+			task := &playbook.Task{
+				Module:   "test_fail",
+				Params:   map[string]any{"kind": tt.kind},
+				Register: "out",
+				Retries:  2,
+			}
+
+			testFail.runs.Store(0)
+			if _, err := exec.runTask(context.Background(), pctx, task); err == nil {
+				t.Fatal("expected task to fail")
+			}
+			if got := testFail.runs.Load(); got != tt.wantRuns {
+				t.Errorf("module ran %d times, want %d", got, tt.wantRuns)
+			}
+
+			reg, _ := pctx.Vars.Lookup("out")
+			result, ok := reg.(map[string]any)
+			if !ok {
+				t.Fatalf("registered %v, want a map", reg)
+			}
+			if result["failed"] != true || result["error_kind"] != tt.kind || result["rc"] != tt.wantRC {
+				t.Errorf("registered %v", result)
+			}
+		})
+	}
+}
+
+// dropModule drops the connection of its fake connector, as if the
+// target went away during the task.
+type dropModule struct {
+	conn *connectortest.Connector
+}
+
+func (m *dropModule) Name() string { return "test_drop" }
+
+func (m *dropModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	m.conn.Drop(errors.New("session timed out"))
+	return module.Unchanged(""), nil
+}
+
+var testDrop = &dropModule{}
+
+func init() {
+	module.Register(testDrop)
+}
+
+func TestRunPlayReconnects(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	testDrop.conn = fake
+
+	play := &playbook.Play{
+		Hosts:       "web1",
+		GatherFacts: boolPtr(false),
+		Tasks: []*playbook.Task{
+			{Module: "test_drop", Params: map[string]any{}},
+			{Module: "test_record", Params: map[string]any{"value": "after reconnect"}},
+		},
+	}
+	exec.SetConnector(play.Hosts, fake)
+
+	testRecord.last.Store("")
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fake.Connects(); got != 2 {
+		t.Errorf("connected %d times, want 2", got)
+	}
+	if got := testRecord.last.Load(); got != "after reconnect" {
+		t.Errorf("second task recorded %v, want it to run", got)
+	}
+}
+
+// echoModule runs "echo <value>" on the target.
+type echoModule struct{}
+
+func (m *echoModule) Name() string { return "test_echo" }
+
+func (m *echoModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	if _, err := conn.Execute(ctx, fmt.Sprintf("echo %v", params["value"])); err != nil {
+		return nil, err
+	}
+	return module.Unchanged(""), nil
+}
+
+func init() {
+	module.Register(&echoModule{})
+}
+
+func TestRunPlayMultipleHosts(t *testing.T) {
+	inv, err := inventory.Parse([]byte(`
+webservers:
+  hosts:
+    web1:
+      greeting: hello
+    web2:
+      greeting: hi
+    web3:
+databases:
+  hosts:
+    db1:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	exec.Inventory = inv
+	exec.Limit = []string{"!web3"}
+
+	fakes := make(map[string]*connectortest.Connector)
+	for _, name := range []string{"web1", "web2", "web3", "db1"} {
+		fakes[name] = connectortest.New()
+		fakes[name].Default(connector.Result{})
+		exec.SetConnector(name, fakes[name])
+	}
+
+	play := &playbook.Play{
+		Hosts:       "webservers",
+		GatherFacts: boolPtr(false),
+		Tasks: []*playbook.Task{
+			{Module: "test_echo", Params: map[string]any{"value": "{{ greeting }} {{ inventory_hostname }}"}},
+		},
+	}
+
+	stats := &Stats{}
+	if err := exec.runPlay(context.Background(), play, stats, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, want := range map[string]string{"web1": "echo hello web1", "web2": "echo hi web2"} {
+		cmds := fakes[name].Commands()
+		if len(cmds) != 1 || cmds[0] != want {
+			t.Errorf("%s ran %v, want [%s]", name, cmds, want)
+		}
+	}
+	for _, name := range []string{"web3", "db1"} {
+		if cmds := fakes[name].Commands(); len(cmds) != 0 {
+			t.Errorf("%s ran %v, want nothing", name, cmds)
+		}
+	}
+	if stats.Tasks != 2 || stats.OK != 2 {
+		t.Errorf("stats = %d tasks, %d ok; want 2 and 2", stats.Tasks, stats.OK)
+	}
+}
+
+func TestHostsWithoutInventory(t *testing.T) {
+	exec := New()
+
+	hosts, err := exec.Hosts(&playbook.Play{Hosts: "app-1, app-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hosts) != 2 || hosts[1].Address != "app-2" {
+		t.Errorf("Hosts() = %+v, want app-1 and app-2 used literally", hosts)
+	}
+
+	if _, err := exec.Hosts(&playbook.Play{Hosts: "webservers"}); err != nil {
+		t.Errorf("plain names need no inventory: %v", err)
+	}
+	if _, err := exec.Hosts(&playbook.Play{Hosts: "all"}); err == nil {
+		t.Error("expected error resolving all without an inventory")
+	}
+}
+
+func TestRunReusesAndClosesConnectors(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	newPlay := func(value string) *playbook.Play {
+		return &playbook.Play{
+			Hosts:       "web1",
+			GatherFacts: boolPtr(false),
+			Tasks: []*playbook.Task{
+				{Module: "test_echo", Params: map[string]any{"value": value}},
+			},
+		}
+	}
+	pb := &playbook.Playbook{
+		Path:  filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{newPlay("one"), newPlay("two")},
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil || !result.Success {
+		t.Fatalf("Run() = %+v, %v", result, err)
+	}
+	if got := fake.Connects(); got != 1 {
+		t.Errorf("connected %d times, want 1 for both plays", got)
+	}
+	if !fake.Closed() {
+		t.Error("connector was not closed at the end of the run")
+	}
+}
+
+func TestRunClosesConnectorsOnFailure(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	exec.SetConnector("web1", fake)
+
+	pb := &playbook.Playbook{
+		Path: filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{{
+			Hosts:       "web1",
+			GatherFacts: boolPtr(false),
+			Tasks: []*playbook.Task{
+				{Module: "test_fail", Params: map[string]any{"kind": "command"}},
+			},
+		}},
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil || result.Success {
+		t.Fatalf("Run() = %+v, %v; want a failed run", result, err)
+	}
+	if !fake.Closed() {
+		t.Error("connector was not closed after the failed run")
+	}
+}
+
+func TestRunPlayHostVars(t *testing.T) {
+	inv, err := inventory.Parse([]byte(`
+cluster:
+  hosts:
+    node1:
+      node_id: 1
+    node2:
+      node_id: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	exec.Inventory = inv
+
+	fakes := make(map[string]*connectortest.Connector)
+	for _, name := range []string{"node1", "node2"} {
+		fakes[name] = connectortest.New()
+		fakes[name].On("hostname").Return(name + ".example.com\n")
+		fakes[name].Default(connector.Result{})
+		exec.SetConnector(name, fakes[name])
+	}
+
+	// node1 runs first but sees the facts of node2, which are gathered
+	// before any host runs its tasks
+	play := &playbook.Play{
+		Hosts: "cluster",
+		Tasks: []*playbook.Task{
+			{Module: "test_echo", Params: map[string]any{"value": "{{ hostvars.node2.facts.hostname }} {{ hostvars.node2.node_id }}"}},
+		},
+	}
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "echo node2.example.com 2"
+	if cmds := fakes["node1"].Commands(); cmds[len(cmds)-1] != want {
+		t.Errorf("node1 ran %q, want %q", cmds[len(cmds)-1], want)
+	}
+
+	if got := fmt.Sprint(exec.groupsVar(nil)["cluster"]); got != "[node1 node2]" {
+		t.Errorf("groups.cluster = %s, want [node1 node2]", got)
+	}
+}
+
+func TestRunWarnings(t *testing.T) {
+	warning := playbook.Warning{Kind: playbook.WarnDeprecated, Task: "loop", Message: "'with_items' is deprecated; use 'loop'"}
+	newPlaybook := func() *playbook.Playbook {
+		return &playbook.Playbook{
+			Path: filepath.Join(t.TempDir(), "site.yaml"),
+			Plays: []*playbook.Play{{
+				Hosts:       "web1",
+				GatherFacts: boolPtr(false),
+				Tasks: []*playbook.Task{{
+					Module:   "test_echo",
+					Params:   map[string]any{"value": "one"},
+					Warnings: []playbook.Warning{warning},
+				}},
+			}},
+		}
+	}
+
+	for _, suppress := range []bool{false, true} {
+		var buf bytes.Buffer
+		exec := New()
+		exec.Output = output.New(&buf)
+		exec.Output.SetColor(false)
+		exec.SuppressWarnings = suppress
+
+		fake := connectortest.New()
+		fake.Default(connector.Result{})
+		exec.SetConnector("web1", fake)
+
+		result, err := exec.Run(context.Background(), newPlaybook())
+		if err != nil || !result.Success {
+			t.Fatalf("Run() = %+v, %v", result, err)
+		}
+		if len(result.Warnings) != 1 || result.Warnings[0] != warning {
+			t.Errorf("Warnings = %v, want the task's warning", result.Warnings)
+		}
+		if printed := strings.Contains(buf.String(), "WARN loop: 'with_items' is deprecated"); printed == suppress {
+			t.Errorf("suppress=%v: output =\n%s", suppress, buf.String())
+		}
+	}
+}
+
+func TestRunPlayTaskVars(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	play := &playbook.Play{
+		Hosts:       "web1",
+		GatherFacts: boolPtr(false),
+		Vars:        map[string]any{"greeting": "hello", "name": "play"},
+		Tasks: []*playbook.Task{
+			{
+				Module: "test_echo",
+				Params: map[string]any{"value": "{{ greeting }} {{ name }}"},
+				Vars:   map[string]any{"name": "task"},
+				When:   "name == 'task'",
+			},
+			{Module: "test_echo", Params: map[string]any{"value": "{{ greeting }} {{ name }}"}},
+			{
+				Module: "test_echo",
+				Params: map[string]any{"value": "{{ name }}{{ item }}"},
+				Vars:   map[string]any{"name": "loop"},
+				Loop:   []any{1, 2},
+			},
+		},
+	}
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"echo hello task", "echo hello play", "echo loop1", "echo loop2"}
+	if got := fake.Commands(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestRunPlayModuleDefaults(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	play := &playbook.Play{
+		Hosts:       "web1",
+		GatherFacts: boolPtr(false),
+		Vars:        map[string]any{"greeting": "hello"},
+		ModuleDefaults: map[string]map[string]any{
+			"test_echo": {"value": "{{ greeting }} default"},
+		},
+		Tasks: []*playbook.Task{
+			{Module: "test_echo", Params: map[string]any{}},
+			{Module: "test_echo", Params: map[string]any{"value": "own"}},
+		},
+	}
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Defaults of the executor apply under those of the play
+	exec.ModuleDefaults = map[string]map[string]any{
+		"test_echo": {"value": "global"},
+	}
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	play.ModuleDefaults = nil
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"echo hello default", "echo own", "echo hello default", "echo own", "echo global", "echo own"}
+	if got := fake.Commands(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestRunPlayProxy(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	play := &playbook.Play{
+		Hosts:       "web1",
+		GatherFacts: boolPtr(false),
+		Vars:        map[string]any{"proxy_host": "proxy.corp"},
+		Proxy:       &playbook.Proxy{HTTP: "http://{{ proxy_host }}:3128"},
+		Tasks: []*playbook.Task{
+			{Module: "test_echo", Params: map[string]any{"value": "play"}},
+			{Module: "test_echo", Params: map[string]any{"value": "task"}, Proxy: &playbook.Proxy{NoProxy: ".corp"}},
+		},
+	}
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		connector.EnvCommand(map[string]string{"http_proxy": "http://proxy.corp:3128", "HTTP_PROXY": "http://proxy.corp:3128"}, "echo play"),
+		connector.EnvCommand(map[string]string{
+			"http_proxy": "http://proxy.corp:3128", "HTTP_PROXY": "http://proxy.corp:3128",
+			"no_proxy": ".corp", "NO_PROXY": ".corp",
+		}, "echo task"),
+	}
+	if got := fake.Commands(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestRunPlayUpdateStrategy(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fakes := make(map[string]*connectortest.Connector)
+	for _, name := range []string{"web1", "web2", "web3", "lb1"} {
+		fakes[name] = connectortest.New()
+		fakes[name].Default(connector.Result{})
+		exec.SetConnector(name, fakes[name])
+	}
+
+	play := &playbook.Play{
+		Hosts:       "web1,web2,web3",
+		GatherFacts: boolPtr(false),
+		UpdateStrategy: &playbook.UpdateStrategy{
+			BatchSize:  "50%",
+			DelegateTo: "lb1",
+			PreBatch: []*playbook.Task{
+				{Module: "test_echo", Params: map[string]any{"value": "disable {{ batch_hosts | join(' ') }}"}},
+			},
+			PostBatch: []*playbook.Task{
+				{Module: "test_echo", Params: map[string]any{"value": "enable {{ batch_number }}/{{ batch_count }}"}},
+			},
+		},
+		Tasks: []*playbook.Task{
+			{Module: "test_echo", Params: map[string]any{"value": "deploy {{ batch_number }}"}},
+		},
+	}
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string][]string{
+		"lb1":  {"echo disable web1", "echo enable 1/3", "echo disable web2", "echo enable 2/3", "echo disable web3", "echo enable 3/3"},
+		"web1": {"echo deploy 1"},
+		"web3": {"echo deploy 3"},
+	}
+	for name, cmds := range want {
+		if got := fakes[name].Commands(); fmt.Sprint(got) != fmt.Sprint(cmds) {
+			t.Errorf("%s commands = %q, want %q", name, got, cmds)
+		}
+	}
+
+	// A failing health check stops the rollout before the next batch
+	exec = New()
+	exec.Output = output.New(io.Discard)
+	for _, name := range []string{"web1", "web2", "web3", "lb1"} {
+		fakes[name] = connectortest.New()
+		fakes[name].Default(connector.Result{})
+		exec.SetConnector(name, fakes[name])
+	}
+	fakes["lb1"].On("echo enable 1/2").Error(errors.New("web1 unhealthy"))
+	play.UpdateStrategy.BatchSize = "2"
+
+	err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "batch 1: post_batch") {
+		t.Fatalf("runPlay() error = %v, want the post_batch failure", err)
+	}
+	if got := fakes["web2"].Commands(); len(got) != 1 {
+		t.Errorf("web2 commands = %q, want the host to run in the first batch", got)
+	}
+	if got := fakes["web3"].Commands(); len(got) != 0 {
+		t.Errorf("web3 commands = %q, want no run after the failed batch", got)
+	}
+}
+
+func TestRunTaskGraph(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.On("echo config").Error(errors.New("404 not found"))
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	play := &playbook.Play{
+		Hosts:       "web1",
+		GatherFacts: boolPtr(false),
+		TaskGraph:   true,
+		Tasks: []*playbook.Task{
+			{Name: "Report", Module: "test_echo", Params: map[string]any{"value": "report {{ app.changed }}"}, After: []string{"Install", "Configure"}},
+			{Name: "Download app", Module: "test_concurrency", Register: "app"},
+			{Name: "Download tools", Module: "test_concurrency"},
+			{Name: "Download config", Module: "test_echo", Params: map[string]any{"value": "config"}, IgnoreErrors: true},
+			{Name: "Install", Module: "test_echo", Params: map[string]any{"value": "install"}, Requires: []string{"Download app", "Download tools"}},
+			{Name: "Configure", Module: "test_echo", Params: map[string]any{"value": "configure"}, Requires: []string{"Download config"}},
+		},
+	}
+	testConcurrency.peak.Store(0)
+	stats := &Stats{}
+	if err := exec.runPlay(context.Background(), play, stats, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The downloads ran at the same time, Configure was skipped, and
+	// Report ran last with the registered result of Download app
+	if peak := testConcurrency.peak.Load(); peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+	cmds := fake.Commands()
+	if len(cmds) != 3 || cmds[2] != "echo report true" || !fake.Executed("echo install") {
+		t.Errorf("commands = %q, want config, install, and the report last", cmds)
+	}
+	if stats.Tasks != 6 || stats.OK != 2 || stats.Changed != 2 || stats.Failed != 1 || stats.Skipped != 1 {
+		t.Errorf("stats = %+v, want 2 ok, 2 changed, 1 failed, 1 skipped", stats)
+	}
+
+	// A failing task stops the graph: the tasks after it do not start
+	exec = New()
+	exec.Output = output.New(io.Discard)
+	fake = connectortest.New()
+	fake.On("echo install").Error(errors.New("disk full"))
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+	play.TaskParallel = 1
+	play.Tasks[1].Module, play.Tasks[2].Module = "test_echo", "test_echo"
+
+	err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("runPlay() error = %v, want the install failure", err)
+	}
+	if fake.Executed("echo report true") {
+		t.Error("Report ran after Install failed")
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.On("echo ignored").Error(errors.New("ignored failure"))
+	fake.On("echo two").Error(errors.New("disk full"))
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	pb := &playbook.Playbook{
+		Path: filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{{
+			Name:        "web",
+			Hosts:       "web1",
+			GatherFacts: boolPtr(false),
+			Tasks: []*playbook.Task{
+				{Name: "ignored", Module: "test_echo", Params: map[string]any{"value": "ignored"}, IgnoreErrors: true},
+				{Name: "one", Module: "test_echo", Params: map[string]any{"value": "one"}},
+				{Name: "two", Module: "test_echo", Params: map[string]any{"value": "two"}},
+				{Name: "three", Module: "test_echo", Params: map[string]any{"value": "three"}},
+			},
+		}},
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success {
+		t.Fatal("expected run to fail")
+	}
+	if len(result.Failures) != 1 {
+		t.Fatalf("Failures = %+v, want one", result.Failures)
+	}
+	f := result.Failures[0]
+	if f.Play != "web" || f.Host != "web1" || f.Task != "two" || !strings.Contains(f.Error, "disk full") {
+		t.Errorf("Failure = %+v", f)
+	}
+}
+
+func TestRunFailedHosts(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fakes := make(map[string]*connectortest.Connector)
+	for _, name := range []string{"web1", "web2", "web3"} {
+		fakes[name] = connectortest.New()
+		fakes[name].Default(connector.Result{})
+		exec.SetConnector(name, fakes[name])
+	}
+	fakes["web2"].On("echo one").Error(errors.New("disk full"))
+
+	echo := func(value string) *playbook.Task {
+		return &playbook.Task{Name: value, Module: "test_echo", Params: map[string]any{"value": value}}
+	}
+	pb := &playbook.Playbook{
+		Path: filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{
+			{Name: "web", Hosts: "web1, web2, web3", GatherFacts: boolPtr(false), Tasks: []*playbook.Task{echo("one"), echo("two")}},
+			{Name: "again", Hosts: "web1, web2, web3", GatherFacts: boolPtr(false), Tasks: []*playbook.Task{echo("three")}},
+			{Name: "web2 only", Hosts: "web2", GatherFacts: boolPtr(false), Tasks: []*playbook.Task{echo("four")}},
+		},
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success {
+		t.Error("expected run to fail")
+	}
+	if !reflect.DeepEqual(result.FailedHosts, []string{"web2"}) {
+		t.Errorf("FailedHosts = %v, want [web2]", result.FailedHosts)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Host != "web2" {
+		t.Errorf("Failures = %+v, want web2's", result.Failures)
+	}
+
+	// The other hosts finished the play and ran the next one; web2 ran
+	// nothing after its failure
+	for _, name := range []string{"web1", "web3"} {
+		if got := fakes[name].Commands(); !reflect.DeepEqual(got, []string{"echo one", "echo two", "echo three"}) {
+			t.Errorf("%s commands = %q", name, got)
+		}
+	}
+	if got := fakes["web2"].Commands(); !reflect.DeepEqual(got, []string{"echo one"}) {
+		t.Errorf("web2 commands = %q, want only the failed task", got)
+	}
+}
+
+func TestRunUnreachable(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	fake.On("echo one").Error(&connector.ConnectivityError{Target: "web1", Err: errors.New("connection refused")})
+	fake.On("echo two").Error(errors.New("disk full"))
+	exec.SetConnector("web1", fake)
+
+	echo := func(value string) *playbook.Task {
+		return &playbook.Task{Name: value, Module: "test_echo", Params: map[string]any{"value": value}}
+	}
+	run := func(play *playbook.Play) *RunResult {
+		t.Helper()
+		play.Name = "web"
+		play.Hosts = "web1"
+		play.GatherFacts = boolPtr(false)
+		result, err := exec.Run(context.Background(), &playbook.Playbook{
+			Path:  filepath.Join(t.TempDir(), "site.yaml"),
+			Plays: []*playbook.Play{play},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// ignore_errors does not cover an unreachable host
+	ignoreErrors := echo("one")
+	ignoreErrors.IgnoreErrors = true
+	result := run(&playbook.Play{Tasks: []*playbook.Task{ignoreErrors, echo("three")}})
+	if result.Success || result.Stats.Unreachable != 1 || result.Stats.Failed != 0 {
+		t.Errorf("ignore_errors: success = %v, stats = %+v, want one unreachable task", result.Success, result.Stats)
+	}
+	if fake.Executed("echo three") {
+		t.Error("ignore_errors: the host ran a task after it was unreachable")
+	}
+
+	// ignore_unreachable on the play covers it, but not the task's error
+	result = run(&playbook.Play{IgnoreUnreachable: true, Tasks: []*playbook.Task{echo("one"), echo("three"), echo("two"), echo("four")}})
+	if result.Success || result.Stats.Unreachable != 1 || result.Stats.Failed != 1 {
+		t.Errorf("ignore_unreachable: success = %v, stats = %+v, want one unreachable and one failed task", result.Success, result.Stats)
+	}
+	if !fake.Executed("echo three") || fake.Executed("echo four") {
+		t.Errorf("ignore_unreachable: commands = %q", fake.Commands())
+	}
+
+	// The task's setting overrides the play's
+	task := echo("one")
+	task.IgnoreUnreachable = boolPtr(false)
+	result = run(&playbook.Play{IgnoreUnreachable: true, Tasks: []*playbook.Task{task}})
+	if result.Success {
+		t.Error("expected ignore_unreachable: false on the task to fail the run")
+	}
+}
+
+func TestRunChanges(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	pb := &playbook.Playbook{
+		Path: filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{{
+			Name:        "web",
+			Hosts:       "web1",
+			GatherFacts: boolPtr(false),
+			Tasks: []*playbook.Task{
+				{Name: "unchanged", Module: "test_echo", Params: map[string]any{"value": "one"}},
+				{Name: "changed", Module: "test_concurrency", Params: map[string]any{}, Notify: []string{"restart"}},
+			},
+			Handlers: []*playbook.Task{
+				{Name: "restart", Module: "test_concurrency", Params: map[string]any{}},
+			},
+		}},
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Play: "web", Host: "web1", Task: "changed"},
+		{Play: "web", Host: "web1", Task: "restart"},
+	}
+	if !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("Changes = %+v, want %+v", result.Changes, want)
+	}
+}
+
+func TestRunOnTask(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	var events []TaskEvent
+	exec.OnTask = func(ev TaskEvent) {
+		ev.Duration = 0
+		events = append(events, ev)
+	}
+
+	pb := &playbook.Playbook{
+		Path: filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{{
+			Name:        "web",
+			Hosts:       "web1",
+			GatherFacts: boolPtr(false),
+			Tasks: []*playbook.Task{
+				{Name: "echo", Module: "test_echo", Params: map[string]any{"value": "one"}},
+				{Name: "never", Module: "test_echo", Params: map[string]any{"value": "two"}, When: "false"},
+			},
+		}},
+	}
+
+	if _, err := exec.Run(context.Background(), pb); err != nil {
+		t.Fatal(err)
+	}
+	want := []TaskEvent{
+		{Play: "web", Host: "web1", Task: "echo", Module: "test_echo", Status: "ok"},
+		{Play: "web", Host: "web1", Task: "never", Module: "test_echo", Status: "skipped", Message: "when condition not met"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("OnTask events = %+v, want %+v", events, want)
+	}
+}
+
+func TestRunConfirm(t *testing.T) {
+	newPlaybook := func(playConfirm string) *playbook.Playbook {
+		return &playbook.Playbook{
+			Path: filepath.Join(t.TempDir(), "site.yaml"),
+			Plays: []*playbook.Play{{
+				Name:        "db",
+				Hosts:       "db1",
+				GatherFacts: boolPtr(false),
+				Confirm:     playConfirm,
+				Vars:        map[string]any{"dir": "/var/lib/db"},
+				Tasks: []*playbook.Task{
+					{Name: "keep", Module: "test_echo"},
+					{Name: "drop", Module: "test_echo", Confirm: "Delete {{ dir }}?"},
+					{Name: "wipe", Module: "test_echo", Confirm: "Wipe?"},
+				},
+			}},
+		}
+	}
+	run := func(t *testing.T, exec *Executor, pb *playbook.Playbook) ([]string, error) {
+		t.Helper()
+		exec.Output = output.New(io.Discard)
+		fake := connectortest.New()
+		fake.Default(connector.Result{})
+		exec.SetConnector("db1", fake)
+		var ran []string
+		exec.OnTask = func(ev TaskEvent) {
+			if ev.Status == "ok" {
+				ran = append(ran, ev.Task)
+			}
+		}
+		result, err := exec.Run(context.Background(), pb)
+		if err != nil {
+			return ran, err
+		}
+		if len(result.Failures) > 0 {
+			return ran, errors.New(result.Failures[0].Error)
+		}
+		return ran, nil
+	}
+
+	t.Run("asks", func(t *testing.T) {
+		exec := New()
+		var asked []Confirmation
+		exec.Confirm = func(ctx context.Context, c Confirmation) (bool, error) {
+			asked = append(asked, c)
+			return c.Task != "wipe", nil
+		}
+		ran, err := run(t, exec, newPlaybook(""))
+		if err == nil || !strings.Contains(err.Error(), ErrNotConfirmed.Error()) {
+			t.Errorf("err = %v, want the declined task to fail", err)
+		}
+		if want := []string{"keep", "drop"}; !reflect.DeepEqual(ran, want) {
+			t.Errorf("ran %v, want %v", ran, want)
+		}
+		want := []Confirmation{
+			{Play: "db", Host: "db1", Task: "drop", Question: "Delete /var/lib/db?"},
+			{Play: "db", Host: "db1", Task: "wipe", Question: "Wipe?"},
+		}
+		if !reflect.DeepEqual(asked, want) {
+			t.Errorf("asked %+v, want %+v", asked, want)
+		}
+	})
+
+	t.Run("play", func(t *testing.T) {
+		exec := New()
+		exec.Confirm = func(ctx context.Context, c Confirmation) (bool, error) {
+			return c.Task != "", nil
+		}
+		ran, err := run(t, exec, newPlaybook("Run on {{ inventory_hostname }}?"))
+		if err == nil || !strings.Contains(err.Error(), ErrNotConfirmed.Error()) {
+			t.Errorf("err = %v, want the declined play to fail", err)
+		}
+		if len(ran) != 0 {
+			t.Errorf("ran %v, want no task of the declined play", ran)
+		}
+	})
+
+	t.Run("nobody to ask", func(t *testing.T) {
+		ran, err := run(t, New(), newPlaybook(""))
+		if err == nil {
+			t.Error("expected the tasks asking for confirmation to fail")
+		}
+		if want := []string{"keep"}; !reflect.DeepEqual(ran, want) {
+			t.Errorf("ran %v, want %v", ran, want)
+		}
+	})
+
+	for name, setup := range map[string]func(*Executor){
+		"assume yes": func(e *Executor) { e.AssumeYes = true },
+		"dry run":    func(e *Executor) { e.DryRun = true },
+	} {
+		t.Run(name, func(t *testing.T) {
+			exec := New()
+			setup(exec)
+			exec.Confirm = func(ctx context.Context, c Confirmation) (bool, error) {
+				t.Errorf("asked %+v", c)
+				return false, nil
+			}
+			if _, err := run(t, exec, newPlaybook("Sure?")); err != nil {
+				t.Errorf("err = %v, want none", err)
+			}
+		})
+	}
+}
+
+func TestRunPolicy(t *testing.T) {
+	pb := &playbook.Playbook{
+		Path: filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{
+			{
+				Name:        "web",
+				Hosts:       "web1",
+				GatherFacts: boolPtr(false),
+				Tasks: []*playbook.Task{
+					{Name: "greet", Module: "test_echo", Params: map[string]any{"value": "drop it"}},
+				},
+			},
+			{
+				Name:        "db",
+				Hosts:       "db1",
+				GatherFacts: boolPtr(false),
+				Tasks: []*playbook.Task{
+					{Name: "keep", Module: "test_echo", Params: map[string]any{"value": "hi"}},
+					{Name: "Wipe data", Module: "test_echo", Params: map[string]any{"value": "drop all"}},
+					{Name: "wipe logs", Module: "test_echo", Confirm: "Sure?"},
+				},
+			},
+		},
+	}
+	rules := []policy.Rule{
+		{Name: "no-drops", Hosts: "db1", Module: "test_*", Params: map[string]string{"value": "drop*"}, Deny: true},
+		{Name: "confirm-wipes", Task: "wipe*", Require: []string{"confirm"}},
+	}
+
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	exec.Policy = rules
+	exec.AssumeYes = true
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+	exec.SetConnector("db1", fake)
+	var ran []string
+	exec.OnTask = func(ev TaskEvent) { ran = append(ran, ev.Task) }
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || len(ran) != 0 {
+		t.Errorf("success = %v, ran %v, want the run to fail before any task", result.Success, ran)
+	}
+	want := []Failure{
+		{Play: "db", Task: "Wipe data", Error: "denied (policy no-drops)"},
+		{Play: "db", Task: "Wipe data", Error: "must set confirm (policy confirm-wipes)"},
+	}
+	if !reflect.DeepEqual(result.Failures, want) {
+		t.Errorf("failures = %+v, want %+v", result.Failures, want)
+	}
+
+	// The same playbook runs once it follows the policy
+	pb.Plays[1].Tasks = pb.Plays[1].Tasks[:1]
+	exec.Policy = rules
+	if result, err := exec.Run(context.Background(), pb); err != nil || !result.Success {
+		t.Errorf("run = %+v, %v, want success", result, err)
+	}
+}
+
+func TestRunLock(t *testing.T) {
+	newPlaybook := func() *playbook.Playbook {
+		return &playbook.Playbook{
+			Path: filepath.Join(t.TempDir(), "site.yaml"),
+			Plays: []*playbook.Play{{
+				Hosts:       "web1",
+				GatherFacts: boolPtr(false),
+				Tasks: []*playbook.Task{{
+					Module: "test_echo",
+					Params: map[string]any{"value": "one"},
+				}},
+			}},
+		}
+	}
+
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	exec.Lock = true
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	result, err := exec.Run(context.Background(), newPlaybook())
+	if err != nil || !result.Success {
+		t.Fatalf("Run() = %+v, %v", result, err)
+	}
+	cmds := fake.Commands()
+	if len(cmds) != 3 || !strings.HasPrefix(cmds[0], "mkdir "+runlock.RemotePath) || !strings.Contains(cmds[2], "rm -rf "+runlock.RemotePath) {
+		t.Errorf("commands = %q, want lock, task, unlock", cmds)
+	}
+
+	// Another controller's live run holds the lock
+	exec = New()
+	exec.Output = output.New(io.Discard)
+	exec.Lock = true
+	fake = connectortest.New()
+	fake.OnPrefix("mkdir ").Fail(1, "")
+	fake.OnPrefix("cat ").Return(fmt.Sprintf("elsewhere 42 ops %d\n", time.Now().Unix()))
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	result, err = exec.Run(context.Background(), newPlaybook())
+	if err != nil || result.Success {
+		t.Fatalf("Run() = %+v, %v, want failure", result, err)
+	}
+	if len(result.Failures) != 1 || !strings.Contains(result.Failures[0].Error, "locked by another run: ops@elsewhere (pid 42)") {
+		t.Errorf("Failures = %+v", result.Failures)
+	}
+	for _, cmd := range fake.Commands() {
+		if strings.HasPrefix(cmd, "echo") {
+			t.Errorf("task ran on a locked host: %q", cmd)
+		}
+	}
+}
+
+// factsModule returns the fact given by its name and value parameters.
+type factsModule struct{}
+
+func (m *factsModule) Name() string { return "test_facts" }
+
+func (m *factsModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, _ := params["name"].(string)
+	return module.Unchanged("").WithFacts(map[string]any{name: params["value"]}), nil
+}
+
+func init() {
+	module.Register(&factsModule{})
+}
+
+func TestRunModuleFacts(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	pb := &playbook.Playbook{
+		Path: filepath.Join(t.TempDir(), "site.yaml"),
+		Plays: []*playbook.Play{
+			{
+				Hosts:       "web1",
+				GatherFacts: boolPtr(false),
+				Tasks: []*playbook.Task{
+					{Module: "test_facts", Params: map[string]any{"name": "java_home", "value": "/opt/java"}},
+					{Module: "test_echo", Params: map[string]any{"value": "{{ facts.java_home }}"}},
+					{
+						Module:       "test_facts",
+						Params:       map[string]any{"name": "jdk_{{ item }}", "value": "{{ item }}"},
+						Loop:         []any{"17", "21"},
+						LoopParallel: 2,
+					},
+				},
+			},
+			{
+				Hosts:       "web1",
+				GatherFacts: boolPtr(false),
+				Tasks: []*playbook.Task{
+					{Module: "test_echo", Params: map[string]any{"value": "{{ facts.java_home }} {{ facts.jdk_17 }} {{ facts.jdk_21 }}"}},
+				},
+			},
+		},
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil || !result.Success {
+		t.Fatalf("Run() = %+v, %v", result, err)
+	}
+	want := []string{"echo /opt/java", "echo /opt/java 17 21"}
+	if got := fake.Commands(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestRunFirstFound(t *testing.T) {
+	dir := t.TempDir()
+	role := filepath.Join(dir, "roles", "nginx")
+	for _, path := range []string{
+		filepath.Join(role, "files", "nginx.conf"),
+		filepath.Join(role, "templates", "nginx.conf.debian"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	pb := &playbook.Playbook{
+		Path: filepath.Join(dir, "site.yaml"),
+		Plays: []*playbook.Play{
+			{
+				Hosts:       "web1",
+				GatherFacts: boolPtr(false),
+				Vars:        map[string]any{"family": "debian", "candidates": []any{"nginx.conf.darwin", "nginx.conf"}},
+				Tasks: []*playbook.Task{
+					{
+						Module:     "test_echo",
+						Params:     map[string]any{"value": "{{ item }}"},
+						FirstFound: []any{"nginx.conf.{{ family }}", "nginx.conf"},
+						RolePath:   role,
+					},
+					{
+						Module:   "test_echo",
+						Params:   map[string]any{"value": "{{ lookup('first_found', candidates) }}"},
+						RolePath: role,
+					},
+				},
+			},
+		},
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil || !result.Success {
+		t.Fatalf("Run() = %+v, %v", result, err)
+	}
+	want := []string{
+		"echo " + filepath.Join(role, "templates", "nginx.conf.debian"),
+		"echo " + filepath.Join(role, "files", "nginx.conf"),
+	}
+	if got := fake.Commands(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	// Without the role, none of the candidates exist
+	pb.Plays[0].Tasks[0].RolePath = ""
+	result, err = exec.Run(context.Background(), pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || len(result.Failures) != 1 ||
+		!strings.Contains(result.Failures[0].Error, "no file found among nginx.conf.debian, nginx.conf") {
+		t.Errorf("Run() = %+v, want no file found", result)
+	}
+}
+
+// ownerModule runs "echo owner=<default owner>" on the target.
+type ownerModule struct{}
+
+func (m *ownerModule) Name() string { return "test_owner" }
+
+func (m *ownerModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	if _, err := conn.Execute(ctx, "echo owner="+module.DefaultOwner(params)); err != nil {
+		return nil, err
+	}
+	return module.Unchanged(""), nil
+}
+
+func init() {
+	module.Register(&ownerModule{})
+	keepOwnershipModules["test_owner"] = true
+}
+
+func TestRunPlayBecomeKeepOwnership(t *testing.T) {
+	tests := []struct {
+		name       string
+		play       playbook.Play
+		user       string
+		wantOwner  string
+		wantLookup bool
+	}{
+		{
+			name:       "connecting user",
+			play:       playbook.Play{Become: true, BecomeKeepOwnership: true},
+			user:       "deploy",
+			wantOwner:  "deploy",
+			wantLookup: true,
+		},
+		{
+			name:       "connecting as the become user",
+			play:       playbook.Play{Become: true, BecomeUser: "app", BecomeKeepOwnership: true},
+			user:       "app",
+			wantLookup: true,
+		},
+		{
+			name: "without become",
+			play: playbook.Play{BecomeKeepOwnership: true},
+			user: "deploy",
+		},
+		{
+			name: "not set",
+			play: playbook.Play{Become: true},
+			user: "deploy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := New()
+			exec.Output = output.New(io.Discard)
+			fake := connectortest.New()
+			fake.On("id -un").Return(tt.user + "\n")
+			fake.Default(connector.Result{})
+			exec.SetConnector("web1", fake)
+
+			play := tt.play
+			play.Hosts = "web1"
+			play.GatherFacts = boolPtr(false)
+			play.Tasks = []*playbook.Task{{Module: "test_owner", Params: map[string]any{}}}
+			if err := exec.runPlay(context.Background(), &play, &Stats{}, t.TempDir()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var want []string
+			if tt.wantLookup {
+				want = append(want, "id -un")
+			}
+			want = append(want, "echo owner="+tt.wantOwner)
+			if got := fake.Commands(); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("commands = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRunTaskResultConditions(t *testing.T) {
+	tests := []struct {
+		name        string
+		module      string
+		failedWhen  string
+		changedWhen string
+		wantErr     bool
+		wantChanged bool
+		wantRuns    int32
+	}{
+		{name: "failure cleared", module: "test_fail", failedWhen: "out.rc == 2", wantRuns: 1},
+		{name: "failure kept", module: "test_fail", failedWhen: "out.rc == 3", wantErr: true, wantRuns: 2},
+		{name: "success failed", module: "test_echo", failedWhen: "not out.failed", wantErr: true},
+		{name: "changed", module: "test_echo", changedWhen: "true", wantChanged: true},
+		{name: "failure cleared and changed", module: "test_fail", failedWhen: "false", changedWhen: "out.rc == 3", wantChanged: true, wantRuns: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := New()
+			exec.Output = output.New(io.Discard)
+
+			fake := connectortest.New()
+			fake.Default(connector.Result{})
+			pctx := &PlayContext{
+				Vars:             NewVarScope(),
+				NotifiedHandlers: make(map[Notification]bool),
+				Connector:        fake,
+			}
+			task := &playbook.Task{
+				Module:      tt.module,
+				Params:      map[string]any{"kind": "command", "value": "hi"},
+				Register:    "out",
+				Retries:     1,
+				FailedWhen:  tt.failedWhen,
+				ChangedWhen: tt.changedWhen,
+			}
+
+			testFail.runs.Store(0)
+			result, err := exec.runTask(context.Background(), pctx, task)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runTask() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result.Changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", result.Changed, tt.wantChanged)
+			}
+			if got := testFail.runs.Load(); got != tt.wantRuns {
+				t.Errorf("module ran %d times, want %d", got, tt.wantRuns)
+			}
+
+			reg, _ := pctx.Vars.Lookup("out")
+			registered, ok := reg.(map[string]any)
+			if !ok || registered["failed"] != tt.wantErr {
+				t.Errorf("registered %v, want failed %v", reg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunPlayTaskBecome(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+
+	var conns []*connectortest.Connector
+	exec.NewConnector = func(host *inventory.Host) (connector.Connector, error) {
+		fake := connectortest.New()
+		fake.Default(connector.Result{})
+		conns = append(conns, fake)
+		return fake, nil
+	}
+
+	play := &playbook.Play{
+		Hosts:       "web1",
+		GatherFacts: boolPtr(false),
+		Tasks: []*playbook.Task{
+			{Module: "test_echo", Params: map[string]any{"value": "play"}},
+			{Module: "test_echo", Params: map[string]any{"value": "root"}, Become: boolPtr(true)},
+			{Module: "test_echo", Params: map[string]any{"value": "root again"}, Become: boolPtr(true)},
+			{Module: "test_echo", Params: map[string]any{"value": "app"}, Become: boolPtr(true), BecomeUser: "app"},
+			{Module: "test_echo", Params: map[string]any{"value": "user only"}, BecomeUser: "app"},
+		},
+	}
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][]string{
+		{"echo play", "echo user only"},
+		{"echo root", "echo root again"},
+		{"echo app"},
+	}
+	if len(conns) != len(want) {
+		t.Fatalf("created %d connectors, want %d", len(conns), len(want))
+	}
+	for i, conn := range conns {
+		if got := conn.Commands(); fmt.Sprint(got) != fmt.Sprint(want[i]) {
+			t.Errorf("connector %d ran %q, want %q", i, got, want[i])
+		}
+		if conn.Connects() != 1 {
+			t.Errorf("connector %d connected %d times, want 1", i, conn.Connects())
+		}
+	}
+}
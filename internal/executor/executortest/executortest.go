@@ -0,0 +1,76 @@
+// Package executortest runs playbooks against a fake connector in unit tests.
+//
+// Combined with connectortest, a playbook can be exercised end to end
+// without Docker or a real target:
+//
+//	conn := connectortest.New()
+//	conn.On("echo hello").Return("hello\n")
+//
+//	result := executortest.Run(t, `
+//	- hosts: localhost
+//	  gather_facts: false
+//	  tasks:
+//	    - command: echo hello
+//	`, conn)
+//	if !result.Success {
+//		t.Fatal("playbook failed")
+//	}
+package executortest
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/executor"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// Run parses playbook YAML and runs every play against conn, regardless of
+// the play's hosts and connection type. Parse errors fail the test
+// immediately; the executor output is logged if the run does not succeed.
+func Run(t testing.TB, source string, conn connector.Connector) *executor.RunResult {
+	t.Helper()
+
+	pb, err := playbook.ParseRaw([]byte(source), "playbook.yaml")
+	if err != nil {
+		t.Fatalf("failed to parse playbook: %v", err)
+	}
+	return RunPlaybook(t, pb, conn)
+}
+
+// RunFile is like Run but reads the playbook from path, so roles are
+// resolved relative to it.
+func RunFile(t testing.TB, path string, conn connector.Connector) *executor.RunResult {
+	t.Helper()
+
+	pb, err := playbook.ParseFileRaw(path)
+	if err != nil {
+		t.Fatalf("failed to parse playbook: %v", err)
+	}
+	return RunPlaybook(t, pb, conn)
+}
+
+// RunPlaybook runs an already parsed playbook against conn.
+func RunPlaybook(t testing.TB, pb *playbook.Playbook, conn connector.Connector) *executor.RunResult {
+	t.Helper()
+
+	var buf bytes.Buffer
+	exec := executor.New()
+	exec.Output = output.New(&buf)
+	exec.Output.SetColor(false)
+	for _, play := range pb.Plays {
+		exec.SetConnector(play.Hosts, conn)
+	}
+
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil {
+		t.Fatalf("failed to run playbook: %v", err)
+	}
+	if !result.Success {
+		t.Logf("playbook output:\n%s", buf.String())
+	}
+	return result
+}
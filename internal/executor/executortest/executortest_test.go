@@ -0,0 +1,68 @@
+package executortest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	_ "github.com/eugenetaranov/bolt/internal/module/command"
+	_ "github.com/eugenetaranov/bolt/internal/module/copy"
+)
+
+func TestRun(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("echo hello").Return("hello\n")
+	conn.OnMatch(`sha256sum`).Return("NO_FILE\n").Once()
+	// Verification of the uploaded motd
+	conn.OnMatch(`sha256sum`).Return("280d44ab1e9f79b5cce2dd4f58f5fe91f0fbacdac9f7447dffc318ceb79f2d02\n")
+	conn.OnPrefix("stat ").Return("644 root root\n")
+	conn.OnMatch(`selinuxenabled`).Fail(1, "")
+
+	result := Run(t, `
+- hosts: web1
+  connection: ssh
+  gather_facts: false
+  tasks:
+    - name: Say hello
+      command: echo hello
+    - name: Write motd
+      copy:
+        content: "welcome"
+        dest: /etc/motd
+`, conn)
+
+	if !result.Success {
+		t.Fatal("expected playbook to succeed")
+	}
+	if result.Stats.Changed != 2 {
+		t.Errorf("changed = %d, want 2", result.Stats.Changed)
+	}
+	if !conn.Connected() {
+		t.Error("expected connector to be connected")
+	}
+	conn.AssertExpectations(t)
+
+	content, ok := conn.File("/etc/motd")
+	if !ok || !strings.Contains(string(content), "welcome") {
+		t.Errorf("motd content = %q, %v", content, ok)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("false").Fail(1, "")
+
+	result := Run(t, `
+- hosts: localhost
+  gather_facts: false
+  tasks:
+    - command: "false"
+`, conn)
+
+	if result.Success {
+		t.Error("expected playbook to fail")
+	}
+	if result.Stats.Failed != 1 {
+		t.Errorf("failed = %d, want 1", result.Stats.Failed)
+	}
+}
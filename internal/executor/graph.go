@@ -0,0 +1,197 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// Task states of a task graph, besides the task statuses.
+const (
+	graphPending    = ""
+	graphRunning    = "running"
+	graphUnselected = "unselected"
+)
+
+// graphTask is a task of a task graph that finished running.
+type graphTask struct {
+	task   *playbook.Task
+	fork   *PlayContext
+	facts  map[string]any // the facts of fork when the task started
+	result *TaskResult
+	err    error
+}
+
+// runTaskGraph executes the tasks of a play with task_graph on the host of
+// pctx. A task starts once the tasks it depends on finished; tasks that
+// are ready at the same time run concurrently, at most task_parallel at a
+// time, in playbook order. Each task runs against its own copy of the play
+// context. Its registered result, facts, and notifications are merged back
+// when it finishes, so the tasks that depend on it see them.
+//
+// A task whose required task failed or was skipped is skipped, but not
+// one whose required task the tags of the run leave out. A task failing
+// without ignore_errors fails the host: no further tasks start, and the
+// running ones are waited for.
+func (e *Executor) runTaskGraph(ctx context.Context, pctx *PlayContext, tasks []*playbook.Task, stats *Stats) error {
+	graph, err := playbook.NewTaskGraph(tasks)
+	if err != nil {
+		return err
+	}
+
+	// Expand once up front so the goroutines only read the tasks
+	for _, task := range tasks {
+		playbook.ExpandShorthand(task)
+	}
+
+	host := pctx.Host.Name
+	limit := pctx.Play.GetTaskParallel()
+	states := make(map[*playbook.Task]string, len(tasks))
+	for _, task := range tasks {
+		// Tasks the tags of the run leave out count as done, so the
+		// tasks that depend on them still run
+		if !e.selected(pctx.Play, task) {
+			states[task] = graphUnselected
+		}
+	}
+	done := make(chan graphTask)
+	running := 0
+	var firstErr error
+
+	for {
+		// Skipping a task may make others ready, so look again until
+		// nothing changes
+		for changed := true; changed && firstErr == nil; {
+			changed = false
+			for _, task := range tasks {
+				if states[task] != graphPending || running >= limit {
+					continue
+				}
+				ready, reason := dependenciesDone(graph, states, task)
+				if !ready {
+					continue
+				}
+				changed = true
+				stats.Tasks++
+
+				if reason != "" {
+					e.reportTask(pctx, task, time.Now(), "skipped", reason, nil)
+					states[task] = "skipped"
+					stats.count(&TaskResult{Status: "skipped"})
+					continue
+				}
+
+				// Reconnecting would break the tasks still running
+				if running == 0 {
+					if err := e.ensureConnected(ctx, pctx); err != nil {
+						states[task] = "failed"
+						if e.taskFailed(pctx, task, err, stats) {
+							continue
+						}
+						firstErr = playbook.ErrorAt(task.Pos, "", err)
+						break
+					}
+				}
+
+				states[task] = graphRunning
+				running++
+				e.Progress.TaskStart(host, task.String())
+
+				fork := pctx.fork()
+				facts, _ := fork.Vars.Get(LayerBuiltin, "facts")
+				go func() {
+					result, err := e.runTask(ctx, fork, task)
+					done <- graphTask{task: task, fork: fork, facts: asMap(facts), result: result, err: err}
+				}()
+			}
+		}
+		if running == 0 {
+			break
+		}
+
+		t := <-done
+		running--
+		pctx.join(t)
+
+		if t.err != nil {
+			states[t.task] = "failed"
+			if e.taskFailed(pctx, t.task, t.err, stats) {
+				continue
+			}
+			if firstErr == nil {
+				firstErr = playbook.ErrorAt(t.task.Pos, "", t.err)
+			}
+			continue
+		}
+
+		e.Progress.TaskDone(host, t.result.Status)
+		stats.count(t.result)
+		e.addChange(pctx, t.task, t.result)
+		states[t.task] = t.result.Status
+		if t.result.Unchecked {
+			// A dry run could not check the task; its dependents are
+			// checked as if it succeeded
+			states[t.task] = "ok"
+		}
+	}
+
+	e.publishFacts(pctx)
+	return firstErr
+}
+
+// dependenciesDone reports whether every task task depends on finished.
+// If so, it returns why task must be skipped, if a required task did not
+// succeed.
+func dependenciesDone(graph *playbook.TaskGraph, states map[*playbook.Task]string, task *playbook.Task) (bool, string) {
+	reason := ""
+	for _, dep := range graph.Dependencies(task) {
+		switch states[dep.Task] {
+		case graphPending, graphRunning:
+			return false, ""
+		case "failed":
+			if dep.Required && reason == "" {
+				reason = fmt.Sprintf("required task '%s' failed", dep.Task)
+			}
+		case "skipped":
+			if dep.Required && reason == "" {
+				reason = fmt.Sprintf("required task '%s' was skipped", dep.Task)
+			}
+		}
+	}
+	return true, reason
+}
+
+// join merges what a task left in its fork of the play context: its
+// registered result, the facts it set, and its notifications.
+func (pctx *PlayContext) join(t graphTask) {
+	if t.task.Register != "" {
+		if reg, ok := t.fork.Vars.Get(LayerRegistered, t.task.Register); ok {
+			pctx.Vars.Set(LayerRegistered, t.task.Register, reg)
+		}
+	}
+
+	// Only facts the task changed are merged, so facts set by tasks that
+	// finished in the meantime are kept
+	facts, _ := t.fork.Vars.Get(LayerBuiltin, "facts")
+	set := map[string]any{}
+	for k, v := range asMap(facts) {
+		if old, ok := t.facts[k]; !ok || !reflect.DeepEqual(old, v) {
+			set[k] = v
+		}
+	}
+	if len(set) > 0 {
+		mergeFacts(pctx.Vars, set)
+	}
+
+	maps.Copy(pctx.NotifiedHandlers, t.fork.NotifiedHandlers)
+}
+
+// asMap returns v if it is a map, or nil.
+func asMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// Notification is a notify entry raised by a changed task.
+type Notification struct {
+	// Name is a handler name or listen topic, optionally qualified with a
+	// role name ("web : restart nginx").
+	Name string
+
+	// RolePath is the role of the notifying task (empty for play tasks).
+	RolePath string
+}
+
+// resolveHandlers returns the handlers the notifications select, each once
+// and in definition order, and the notifications that match no handler.
+//
+// A notification is matched against the handlers of the notifying task's
+// own role first, then the play's handlers, then the handlers of every
+// role; the first scope with a match wins. This way two roles can each
+// define "restart" without one's tasks triggering the other's handler. A
+// name qualified with a role ("web : restart") only matches that role.
+func resolveHandlers(handlers []*playbook.Task, notified map[Notification]bool) ([]*playbook.Task, []Notification) {
+	selected := make(map[*playbook.Task]bool)
+	var unknown []Notification
+
+	for n := range notified {
+		matches := matchHandlers(handlers, n)
+		if len(matches) == 0 {
+			unknown = append(unknown, n)
+		}
+		for _, h := range matches {
+			selected[h] = true
+		}
+	}
+
+	var result []*playbook.Task
+	for _, h := range handlers {
+		if selected[h] {
+			result = append(result, h)
+		}
+	}
+
+	slices.SortFunc(unknown, func(a, b Notification) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, unknown
+}
+
+// matchHandlers returns the handlers a single notification selects.
+func matchHandlers(handlers []*playbook.Task, n Notification) []*playbook.Task {
+	if role, name, ok := strings.Cut(n.Name, " : "); ok {
+		role = strings.TrimSpace(role)
+		return filterHandlers(handlers, strings.TrimSpace(name), func(h *playbook.Task) bool {
+			return h.RolePath != "" && filepath.Base(h.RolePath) == role
+		})
+	}
+
+	scopes := []func(*playbook.Task) bool{
+		func(h *playbook.Task) bool { return n.RolePath != "" && h.RolePath == n.RolePath },
+		func(h *playbook.Task) bool { return h.RolePath == "" },
+		func(h *playbook.Task) bool { return true },
+	}
+	for _, inScope := range scopes {
+		if matches := filterHandlers(handlers, n.Name, inScope); len(matches) > 0 {
+			return matches
+		}
+	}
+	return nil
+}
+
+// filterHandlers returns the handlers in scope that are named name or
+// listen to it.
+func filterHandlers(handlers []*playbook.Task, name string, inScope func(*playbook.Task) bool) []*playbook.Task {
+	var matches []*playbook.Task
+	for _, h := range handlers {
+		if !inScope(h) {
+			continue
+		}
+		if h.Name == name || slices.Contains(h.Listen, name) {
+			matches = append(matches, h)
+		}
+	}
+	return matches
+}
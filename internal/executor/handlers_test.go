@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+func TestResolveHandlers(t *testing.T) {
+	webRestart := &playbook.Task{Name: "restart", RolePath: "roles/web"}
+	dbRestart := &playbook.Task{Name: "restart", RolePath: "roles/db"}
+	dbReload := &playbook.Task{Name: "reload db", RolePath: "roles/db", Listen: []string{"config changed"}}
+	playRestart := &playbook.Task{Name: "restart"}
+	playFlush := &playbook.Task{Name: "flush cache", Listen: []string{"config changed"}}
+	handlers := []*playbook.Task{webRestart, dbRestart, dbReload, playRestart, playFlush}
+
+	tests := []struct {
+		name     string
+		notified []Notification
+		want     []*playbook.Task
+		unknown  int
+	}{
+		{
+			name:     "role handler first",
+			notified: []Notification{{Name: "restart", RolePath: "roles/web"}},
+			want:     []*playbook.Task{webRestart},
+		},
+		{
+			name:     "play task uses play handler",
+			notified: []Notification{{Name: "restart"}},
+			want:     []*playbook.Task{playRestart},
+		},
+		{
+			name:     "role falls back to play handler",
+			notified: []Notification{{Name: "flush cache", RolePath: "roles/web"}},
+			want:     []*playbook.Task{playFlush},
+		},
+		{
+			name:     "play task reaches role handler",
+			notified: []Notification{{Name: "reload db"}},
+			want:     []*playbook.Task{dbReload},
+		},
+		{
+			name:     "qualified name",
+			notified: []Notification{{Name: "db : restart"}},
+			want:     []*playbook.Task{dbRestart},
+		},
+		{
+			name:     "listen topic in play scope",
+			notified: []Notification{{Name: "config changed"}},
+			want:     []*playbook.Task{playFlush},
+		},
+		{
+			name:     "listen topic in role scope",
+			notified: []Notification{{Name: "config changed", RolePath: "roles/db"}},
+			want:     []*playbook.Task{dbReload},
+		},
+		{
+			name: "runs once in definition order",
+			notified: []Notification{
+				{Name: "flush cache"},
+				{Name: "restart", RolePath: "roles/db"},
+				{Name: "db : restart"},
+				{Name: "config changed"},
+			},
+			want: []*playbook.Task{dbRestart, playFlush},
+		},
+		{
+			name:     "unknown",
+			notified: []Notification{{Name: "missing"}, {Name: "web : flush cache"}},
+			unknown:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notified := make(map[Notification]bool)
+			for _, n := range tt.notified {
+				notified[n] = true
+			}
+
+			got, unknown := resolveHandlers(handlers, notified)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d handlers, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("handler %d = %s (%s), want %s (%s)", i, got[i].Name, got[i].RolePath, tt.want[i].Name, tt.want[i].RolePath)
+				}
+			}
+			if len(unknown) != tt.unknown {
+				t.Errorf("got %d unknown notifications, want %d", len(unknown), tt.unknown)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"github.com/eugenetaranov/bolt/internal/inventory"
+)
+
+// hostVarsFor returns the hostvars entry of host, creating it from the
+// host's inventory variables on first use. Facts are added to the entry
+// when they are gathered, so later tasks on any host can read them.
+func (e *Executor) hostVarsFor(host *inventory.Host) map[string]any {
+	if vars, ok := e.hostvars[host.Name].(map[string]any); ok {
+		return vars
+	}
+
+	vars := make(map[string]any, len(host.Vars)+1)
+	for k, v := range host.Vars {
+		vars[k] = v
+	}
+	vars["inventory_hostname"] = host.Name
+	e.hostvars[host.Name] = vars
+	return vars
+}
+
+// groupsVar returns the groups variable: the names of the hosts of every
+// inventory group, in inventory order. Without an inventory, all holds the
+// hosts of the current play.
+func (e *Executor) groupsVar(hosts []*inventory.Host) map[string]any {
+	groups := make(map[string]any)
+	if e.Inventory == nil {
+		groups["all"] = hostNames(hosts)
+		return groups
+	}
+
+	all := e.Inventory.Hosts()
+	for _, g := range e.Inventory.Groups() {
+		groups[g.Name] = hostNames(inventory.Limit(all, []string{g.Name}))
+	}
+	return groups
+}
+
+// hostNames returns the names of hosts as a list variable.
+func hostNames(hosts []*inventory.Host) []any {
+	names := make([]any, len(hosts))
+	for i, h := range hosts {
+		names[i] = h.Name
+	}
+	return names
+}
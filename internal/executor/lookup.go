@@ -0,0 +1,145 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/eugenetaranov/bolt/internal/lookup"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// lookupCache remembers lookup results for the duration of a run so loops
+// and repeated references do not call out to secret managers every time.
+type lookupCache struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// parseLookupCall splits an expression of the form
+// lookup('name', 'arg', ...) [| filter] into its parts. Quoted arguments
+// are literals; unquoted arguments are variable names. ok is false if expr
+// is not a lookup call.
+func parseLookupCall(expr string) (name string, args []string, filter string, ok bool, err error) {
+	rest, found := strings.CutPrefix(expr, "lookup(")
+	if !found {
+		return "", nil, "", false, nil
+	}
+
+	var parts []string
+	var current strings.Builder
+	var quote rune
+	closed := false
+
+	i := 0
+	for i < len(rest) && !closed {
+		r := rune(rest[i])
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			current.WriteRune(r)
+		case r == ',':
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		case r == ')':
+			parts = append(parts, strings.TrimSpace(current.String()))
+			closed = true
+		default:
+			current.WriteRune(r)
+		}
+		i++
+	}
+	if !closed {
+		return "", nil, "", true, fmt.Errorf("unterminated lookup call: %s", expr)
+	}
+
+	rest = strings.TrimSpace(rest[i:])
+	if rest != "" {
+		if !strings.HasPrefix(rest, "|") {
+			return "", nil, "", true, fmt.Errorf("unexpected %q after lookup call", rest)
+		}
+		filter = strings.TrimSpace(rest[1:])
+	}
+
+	if len(parts) == 0 || parts[0] == "" {
+		return "", nil, "", true, fmt.Errorf("lookup call needs a lookup name")
+	}
+	name = strings.Trim(parts[0], "'\"")
+	return name, parts[1:], filter, true, nil
+}
+
+// runLookup resolves the arguments of a lookup call and runs it. A list
+// variable passes its items as separate arguments. Values of secret lookups
+// are masked in all further output.
+func (e *Executor) runLookup(name string, rawArgs []string, pctx *PlayContext, depth int) (string, error) {
+	l := lookup.Get(name)
+	if l == nil {
+		return "", fmt.Errorf("unknown lookup: %s", name)
+	}
+
+	args := make([]string, 0, len(rawArgs))
+	for _, arg := range rawArgs {
+		if len(arg) >= 2 && (arg[0] == '\'' || arg[0] == '"') && arg[len(arg)-1] == arg[0] {
+			args = append(args, arg[1:len(arg)-1])
+			continue
+		}
+		val, ok, err := e.lookupVariable(arg, pctx, depth)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", &UndefinedError{Name: arg}
+		}
+		if items, ok := val.([]any); ok {
+			for _, item := range items {
+				args = append(args, fmt.Sprintf("%v", item))
+			}
+			continue
+		}
+		args = append(args, fmt.Sprintf("%v", val))
+	}
+
+	// Files of role tasks are found in the role first
+	dirs := taskSearchPath(pctx.Task)
+	ctx := lookup.WithSearchPath(context.Background(), dirs)
+
+	key := name + "\x00" + strings.Join(args, "\x00") + "\x00" + strings.Join(dirs, "\x00")
+	e.lookups.mu.Lock()
+	defer e.lookups.mu.Unlock()
+	if val, ok := e.lookups.values[key]; ok {
+		return val, nil
+	}
+
+	val, err := l.Lookup(ctx, args)
+	if err != nil {
+		return "", fmt.Errorf("lookup('%s'): %w", name, err)
+	}
+	if l.Secret() {
+		e.Output.Mask(val)
+	}
+
+	if e.lookups.values == nil {
+		e.lookups.values = make(map[string]string)
+	}
+	e.lookups.values[key] = val
+	return val, nil
+}
+
+// taskSearchPath returns the directories in which lookups for task find
+// relative files: the files and templates directories of its role.
+func taskSearchPath(task *playbook.Task) []string {
+	if task == nil || task.RolePath == "" {
+		return nil
+	}
+	return []string{
+		filepath.Join(task.RolePath, "files"),
+		filepath.Join(task.RolePath, "templates"),
+	}
+}
@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/lookup"
+	"github.com/eugenetaranov/bolt/internal/output"
+)
+
+func TestParseLookupCall(t *testing.T) {
+	tests := []struct {
+		expr       string
+		wantName   string
+		wantArgs   []string
+		wantFilter string
+		wantOK     bool
+		wantErr    bool
+	}{
+		{expr: "name", wantOK: false},
+		{expr: "lookup('env', 'HOME')", wantName: "env", wantArgs: []string{"'HOME'"}, wantOK: true},
+		{expr: `lookup("vault", path, "a,b")`, wantName: "vault", wantArgs: []string{"path", `"a,b"`}, wantOK: true},
+		{expr: "lookup('op', 'op://x/y(z)/f') | upper", wantName: "op", wantArgs: []string{"'op://x/y(z)/f'"}, wantFilter: "upper", wantOK: true},
+		{expr: "lookup('env', 'HOME'", wantOK: true, wantErr: true},
+		{expr: "lookup('env') trailing", wantOK: true, wantErr: true},
+		{expr: "lookup()", wantOK: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			name, args, filter, ok, err := parseLookupCall(tt.expr)
+			if ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Fatalf("ok = %v, err = %v; want ok = %v, error = %v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if err != nil || !ok {
+				return
+			}
+			if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) || filter != tt.wantFilter {
+				t.Errorf("got %q %q %q, want %q %q %q", name, args, filter, tt.wantName, tt.wantArgs, tt.wantFilter)
+			}
+		})
+	}
+}
+
+// secretLookup returns "s3cret-<arg>" and counts its calls.
+type secretLookup struct {
+	calls int
+}
+
+func (l *secretLookup) Name() string { return "test_secret" }
+
+func (l *secretLookup) Secret() bool { return true }
+
+func (l *secretLookup) Lookup(ctx context.Context, args []string) (string, error) {
+	l.calls++
+	return "s3cret-" + strings.Join(args, "-"), nil
+}
+
+var testSecret = &secretLookup{}
+
+func init() {
+	lookup.Register(testSecret)
+}
+
+func TestInterpolateLookup(t *testing.T) {
+	var buf bytes.Buffer
+	exec := New()
+	exec.Output = output.New(&buf)
+	exec.Output.SetColor(false)
+
+	pctx := &PlayContext{Vars: testScope(map[string]any{"key": "db"}, nil)}
+
+	got, err := exec.interpolateString("pw={{ lookup('test_secret', key) }}", pctx)
+	if err != nil || got != "pw=s3cret-db" {
+		t.Fatalf("got %v, %v; want pw=s3cret-db", got, err)
+	}
+
+	got, err = exec.interpolateString("{{ lookup('test_secret', 'db') | upper }}", pctx)
+	if err != nil || got != "S3CRET-DB" {
+		t.Fatalf("got %v, %v; want S3CRET-DB", got, err)
+	}
+	if testSecret.calls != 1 {
+		t.Errorf("lookup ran %d times, want 1 (cached)", testSecret.calls)
+	}
+
+	// Fetched secrets never reach the output
+	exec.Output.Info("connecting with s3cret-db")
+	if out := buf.String(); strings.Contains(out, "s3cret-db") || !strings.Contains(out, "********") {
+		t.Errorf("secret not masked: %q", out)
+	}
+
+	if _, err := exec.interpolateString("x={{ lookup('nope', 'x') }}", pctx); err == nil {
+		t.Error("expected error for unknown lookup")
+	}
+	if _, err := exec.interpolateString("{{ lookup('test_secret', missing) }}", pctx); err == nil {
+		t.Error("expected error for undefined argument")
+	}
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"github.com/eugenetaranov/bolt/internal/playbook"
+	"github.com/eugenetaranov/bolt/internal/policy"
+)
+
+// checkPolicy checks the tasks the tags of the run select, the handlers,
+// and the batch hooks of every play of pb, with those of their roles,
+// against the policy of the run. Each violation is recorded as a failure
+// of the run. Plays whose hosts or roles cannot be resolved are left for
+// runPlay to report.
+func (e *Executor) checkPolicy(pb *playbook.Playbook, rolesDir string) error {
+	if len(e.Policy) == 0 {
+		return nil
+	}
+
+	var violations []policy.Violation
+	for _, play := range pb.Plays {
+		hosts, err := e.Hosts(play)
+		if err != nil || len(hosts) == 0 {
+			continue
+		}
+		roles, err := playbook.LoadRoles(play.Roles, rolesDir)
+		if err != nil {
+			continue
+		}
+		for _, role := range roles {
+			play.ResolveModules(role.Tasks)
+			play.ResolveModules(role.Handlers)
+		}
+
+		tasks := e.selectTasks(play, playbook.ExpandRoleTasks(roles, play.Tasks))
+		tasks = append(tasks, playbook.ExpandRoleHandlers(roles, play.Handlers)...)
+		if strategy := play.UpdateStrategy; strategy != nil {
+			tasks = append(tasks, strategy.PreBatch...)
+			tasks = append(tasks, strategy.PostBatch...)
+		}
+		for _, task := range tasks {
+			playbook.ExpandShorthand(task)
+		}
+
+		violations = append(violations, policy.Check(e.Policy, play, hosts, tasks, func(task *playbook.Task) map[string]any {
+			return e.taskParams(play, task)
+		})...)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	for _, v := range violations {
+		e.failures = append(e.failures, Failure{
+			Play:  v.Play,
+			Task:  v.Task,
+			Error: v.Message + " (policy " + v.Rule + ")",
+		})
+	}
+	return &policy.Error{Violations: violations}
+}
@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Layer is a source of variables. Layers are ordered by precedence: a
+// variable defined in a higher layer hides the same name in all lower ones.
+type Layer int
+
+const (
+	LayerBuiltin      Layer = iota // env and facts
+	LayerRoleDefaults              // roles/*/defaults/main.yaml
+	LayerRoleVars                  // roles/*/vars/main.yaml
+	LayerPlayVars                  // The play's vars section
+	LayerHostVars                  // Per-host variables
+	LayerTaskVars                  // The running task's vars section
+	LayerExtraVars                 // -e/--extra-vars on the command line
+	LayerRegistered                // Task results stored via register
+	LayerLoop                      // The loop variable and loop_index
+
+	numLayers
+)
+
+var layerNames = [numLayers]string{
+	LayerBuiltin:      "builtin",
+	LayerRoleDefaults: "role defaults",
+	LayerRoleVars:     "role vars",
+	LayerPlayVars:     "play vars",
+	LayerHostVars:     "host vars",
+	LayerTaskVars:     "task vars",
+	LayerExtraVars:    "extra vars",
+	LayerRegistered:   "registered",
+	LayerLoop:         "loop",
+}
+
+// String returns the name of the layer.
+func (l Layer) String() string {
+	if l < 0 || l >= numLayers {
+		return fmt.Sprintf("Layer(%d)", int(l))
+	}
+	return layerNames[l]
+}
+
+// Templated reports whether variables in the layer are written by the
+// user and may contain {{ }} templates. Facts, environment variables, and
+// registered results are data from the target and are never expanded.
+func (l Layer) Templated() bool {
+	switch l {
+	case LayerBuiltin, LayerRegistered:
+		return false
+	}
+	return true
+}
+
+// VarScope holds the variables visible to a play's tasks, one map per
+// layer. Lookups walk the layers from highest to lowest precedence, so
+// variables never have to be merged eagerly and removing a variable from
+// one layer uncovers the value beneath it.
+//
+// A VarScope is not safe for concurrent writes; concurrent loop items each
+// work on their own Clone.
+type VarScope struct {
+	layers [numLayers]map[string]any
+}
+
+// NewVarScope returns an empty scope.
+func NewVarScope() *VarScope {
+	return &VarScope{}
+}
+
+// Set defines a variable in the given layer.
+func (s *VarScope) Set(layer Layer, name string, value any) {
+	if s.layers[layer] == nil {
+		s.layers[layer] = make(map[string]any)
+	}
+	s.layers[layer][name] = value
+}
+
+// Merge defines every variable of vars in the given layer.
+func (s *VarScope) Merge(layer Layer, vars map[string]any) {
+	for k, v := range vars {
+		s.Set(layer, k, v)
+	}
+}
+
+// Delete removes a variable from the given layer. Lower layers are not
+// affected.
+func (s *VarScope) Delete(layer Layer, name string) {
+	delete(s.layers[layer], name)
+}
+
+// Clear removes every variable from the given layer.
+func (s *VarScope) Clear(layer Layer) {
+	s.layers[layer] = nil
+}
+
+// Get returns a variable of the given layer only.
+func (s *VarScope) Get(layer Layer, name string) (any, bool) {
+	val, ok := s.layers[layer][name]
+	return val, ok
+}
+
+// Lookup returns the value of a variable and whether it is defined. Names
+// may be dotted paths into maps (e.g., facts.os_family, env.HOME); a name
+// defined verbatim takes priority over a path with the same spelling.
+func (s *VarScope) Lookup(name string) (any, bool) {
+	val, _, ok := s.Resolve(name)
+	return val, ok
+}
+
+// Resolve is like Lookup but also returns the layer the variable, or the
+// root of a dotted path, is defined in.
+func (s *VarScope) Resolve(name string) (any, Layer, bool) {
+	if val, layer, ok := s.lookup(name); ok {
+		return val, layer, true
+	}
+
+	if !strings.Contains(name, ".") {
+		return nil, 0, false
+	}
+
+	parts := strings.Split(name, ".")
+	current, layer, ok := s.lookup(parts[0])
+	if !ok {
+		return nil, 0, false
+	}
+	for _, part := range parts[1:] {
+		switch c := current.(type) {
+		case map[string]any:
+			current, ok = c[part]
+		case map[string]string:
+			current, ok = c[part]
+		default:
+			return nil, 0, false
+		}
+		if !ok {
+			return nil, 0, false
+		}
+	}
+
+	return current, layer, true
+}
+
+// lookup finds a top-level variable in the highest layer defining it.
+func (s *VarScope) lookup(name string) (any, Layer, bool) {
+	for l := numLayers - 1; l >= 0; l-- {
+		if val, ok := s.layers[l][name]; ok {
+			return val, l, true
+		}
+	}
+	return nil, 0, false
+}
+
+// All returns the resolved value of every variable as a single map.
+func (s *VarScope) All() map[string]any {
+	all := make(map[string]any)
+	for _, layer := range s.layers {
+		for k, v := range layer {
+			all[k] = v
+		}
+	}
+	return all
+}
+
+// Clone returns a copy of the scope whose layers can be modified without
+// affecting s. Values themselves are shared.
+func (s *VarScope) Clone() *VarScope {
+	clone := &VarScope{}
+	for l, layer := range s.layers {
+		if layer == nil {
+			continue
+		}
+		clone.layers[l] = make(map[string]any, len(layer))
+		for k, v := range layer {
+			clone.layers[l][k] = v
+		}
+	}
+	return clone
+}
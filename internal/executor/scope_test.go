@@ -0,0 +1,188 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/local"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// testScope returns a scope with vars as play vars and registered as
+// registered results.
+func testScope(vars, registered map[string]any) *VarScope {
+	s := NewVarScope()
+	s.Merge(LayerPlayVars, vars)
+	s.Merge(LayerRegistered, registered)
+	return s
+}
+
+func TestVarScopePrecedence(t *testing.T) {
+	s := NewVarScope()
+	s.Set(LayerRoleDefaults, "port", 80)
+	s.Set(LayerRoleDefaults, "user", "www")
+	s.Set(LayerRoleVars, "port", 8080)
+	s.Set(LayerPlayVars, "port", 9000)
+	s.Set(LayerPlayVars, "name", "play")
+	s.Set(LayerExtraVars, "name", "extra")
+	s.Set(LayerRegistered, "name", "registered")
+	s.Set(LayerRegistered, "item", "registered")
+	s.Set(LayerLoop, "item", "loop")
+
+	tests := []struct {
+		name   string
+		want   any
+		source Layer
+	}{
+		{"user", "www", LayerRoleDefaults},
+		{"port", 9000, LayerPlayVars},
+		{"name", "registered", LayerRegistered},
+		{"item", "loop", LayerLoop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.Lookup(tt.name)
+			if !ok || got != tt.want {
+				t.Errorf("Lookup(%q) = %v, %v; want %v", tt.name, got, ok, tt.want)
+			}
+			if _, src, _ := s.Resolve(tt.name); src != tt.source {
+				t.Errorf("Resolve(%q) layer = %s, want %s", tt.name, src, tt.source)
+			}
+		})
+	}
+}
+
+func TestVarScopeDeleteUncovers(t *testing.T) {
+	s := NewVarScope()
+	s.Set(LayerPlayVars, "item", "play")
+	s.Set(LayerLoop, "item", "loop")
+
+	if got, _ := s.Lookup("item"); got != "loop" {
+		t.Fatalf("Lookup(item) = %v, want loop", got)
+	}
+
+	s.Delete(LayerLoop, "item")
+	if got, _ := s.Lookup("item"); got != "play" {
+		t.Errorf("after Delete, Lookup(item) = %v, want play", got)
+	}
+}
+
+func TestVarScopeLookupDotted(t *testing.T) {
+	s := NewVarScope()
+	s.Set(LayerBuiltin, "env", map[string]string{"HOME": "/root"})
+	s.Set(LayerPlayVars, "app", map[string]any{
+		"port": 8080,
+		"db":   map[string]any{"host": "localhost"},
+	})
+	s.Set(LayerPlayVars, "app.port", "verbatim")
+
+	tests := []struct {
+		name string
+		want any
+		ok   bool
+	}{
+		{"env.HOME", "/root", true},
+		{"app.db.host", "localhost", true},
+		{"app.port", "verbatim", true},
+		{"app.missing", nil, false},
+		{"app.db.host.deeper", nil, false},
+		{"missing.key", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.Lookup(tt.name)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Lookup(%q) = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVarScopeCloneIsolated(t *testing.T) {
+	s := NewVarScope()
+	s.Set(LayerPlayVars, "a", 1)
+
+	clone := s.Clone()
+	clone.Set(LayerPlayVars, "a", 2)
+	clone.Set(LayerLoop, "item", "x")
+
+	if got, _ := s.Lookup("a"); got != 1 {
+		t.Errorf("original a = %v, want 1", got)
+	}
+	if _, ok := s.Lookup("item"); ok {
+		t.Error("clone's loop variable visible in original")
+	}
+}
+
+func TestVarScopeAll(t *testing.T) {
+	s := NewVarScope()
+	s.Set(LayerRoleDefaults, "a", "default")
+	s.Set(LayerRoleDefaults, "b", "default")
+	s.Set(LayerPlayVars, "a", "play")
+
+	all := s.All()
+	if all["a"] != "play" || all["b"] != "default" || len(all) != 2 {
+		t.Errorf("All() = %v", all)
+	}
+}
+
+func TestRunPlayVarPrecedence(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	exec.ExtraVars = map[string]any{"who": "extra"}
+
+	play := &playbook.Play{
+		Hosts:       "localhost",
+		Connection:  "local",
+		GatherFacts: boolPtr(false),
+		Vars:        map[string]any{"who": "play", "item": "play item"},
+		Tasks: []*playbook.Task{
+			{
+				Module: "test_record",
+				Params: map[string]any{"value": "{{ item }}"},
+				Loop:   []any{"a"},
+			},
+			{
+				Module: "test_record",
+				Params: map[string]any{"value": "{{ who }}/{{ item }}"},
+			},
+		},
+	}
+	exec.SetConnector(play.Hosts, local.New())
+
+	if err := exec.runPlay(context.Background(), play, &Stats{}, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Extra vars beat play vars, and the loop variable no longer hides
+	// the play var of the same name once the loop is done
+	if got := testRecord.last.Load(); got != "extra/play item" {
+		t.Errorf("interpolated %v, want extra/play item", got)
+	}
+}
+
+// recordModule records the value parameter of its last run.
+type recordModule struct {
+	last atomic.Value
+}
+
+func (m *recordModule) Name() string { return "test_record" }
+
+func (m *recordModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	m.last.Store(params["value"])
+	return module.Unchanged(""), nil
+}
+
+var testRecord = &recordModule{}
+
+func init() {
+	module.Register(testRecord)
+}
+
+func boolPtr(b bool) *bool { return &b }
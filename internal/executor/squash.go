@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// squashModules are package modules whose name parameter accepts a list.
+// A loop that only varies the package name is collapsed into a single call,
+// so the package manager runs one transaction instead of one per item.
+var squashModules = map[string]bool{
+	"apt":   true,
+	"brew":  true,
+	"dnf":   true,
+	"pkgng": true,
+}
+
+// squashLoop returns a loop-free copy of task with the loop items passed as
+// the name list, or false if the loop cannot be squashed.
+//
+// A loop is squashed only when name is exactly "{{ <loop_var> }}", every item
+// is a string, and no other parameter or guard references the loop variables.
+func squashLoop(task *playbook.Task) (*playbook.Task, bool) {
+	if !squashModules[task.Module] || task.LoopParallel > 1 {
+		return nil, false
+	}
+
+	playbook.ExpandShorthand(task)
+
+	loopVar := task.GetLoopVar()
+	name, ok := task.Params["name"].(string)
+	if !ok {
+		return nil, false
+	}
+	m := varPattern.FindStringSubmatch(strings.TrimSpace(name))
+	if m == nil || m[0] != strings.TrimSpace(name) || strings.TrimSpace(m[1]) != loopVar {
+		return nil, false
+	}
+
+	names := make([]any, 0, len(task.Loop))
+	for _, item := range task.Loop {
+		s, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, s)
+	}
+
+	loopVars := []string{loopVar, "loop_index"}
+	for key, value := range task.Params {
+		if key != "name" && referencesVar(value, loopVars) {
+			return nil, false
+		}
+	}
+	if referencesVar(task.Creates, loopVars) || referencesVar(task.Removes, loopVars) {
+		return nil, false
+	}
+
+	squashed := *task
+	squashed.Loop = nil
+	squashed.Params = make(map[string]any, len(task.Params))
+	for key, value := range task.Params {
+		squashed.Params[key] = value
+	}
+	squashed.Params["name"] = names
+
+	return &squashed, true
+}
+
+// referencesVar reports whether any {{ }} expression in v refers to one of names.
+func referencesVar(v any, names []string) bool {
+	switch val := v.(type) {
+	case string:
+		for _, m := range varPattern.FindAllStringSubmatch(val, -1) {
+			expr := strings.TrimSpace(m[1])
+			root := strings.FieldsFunc(expr, func(r rune) bool {
+				return r == '.' || r == '|' || r == ' ' || r == '['
+			})
+			if len(root) == 0 {
+				continue
+			}
+			for _, name := range names {
+				if root[0] == name {
+					return true
+				}
+			}
+		}
+	case []any:
+		for _, item := range val {
+			if referencesVar(item, names) {
+				return true
+			}
+		}
+	case map[string]any:
+		for _, item := range val {
+			if referencesVar(item, names) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+func TestSquashLoop(t *testing.T) {
+	tests := []struct {
+		name       string
+		task       playbook.Task
+		wantSquash bool
+	}{
+		{
+			name: "apt name loop",
+			task: playbook.Task{
+				Module: "apt",
+				Params: map[string]any{"name": "{{ item }}", "state": "present"},
+				Loop:   []any{"git", "curl"},
+			},
+			wantSquash: true,
+		},
+		{
+			name: "custom loop var",
+			task: playbook.Task{
+				Module:  "brew",
+				Params:  map[string]any{"name": "{{pkg}}"},
+				Loop:    []any{"jq"},
+				LoopVar: "pkg",
+			},
+			wantSquash: true,
+		},
+		{
+			name: "unsupported module",
+			task: playbook.Task{
+				Module: "command",
+				Params: map[string]any{"name": "{{ item }}"},
+				Loop:   []any{"a"},
+			},
+		},
+		{
+			name: "name with extra text",
+			task: playbook.Task{
+				Module: "apt",
+				Params: map[string]any{"name": "lib{{ item }}-dev"},
+				Loop:   []any{"ssl"},
+			},
+		},
+		{
+			name: "item used in other param",
+			task: playbook.Task{
+				Module: "apt",
+				Params: map[string]any{"name": "{{ item }}", "state": "{{ item.state }}"},
+				Loop:   []any{"git"},
+			},
+		},
+		{
+			name: "non-string items",
+			task: playbook.Task{
+				Module: "apt",
+				Params: map[string]any{"name": "{{ item }}"},
+				Loop:   []any{map[string]any{"name": "git"}},
+			},
+		},
+		{
+			name: "guard references item",
+			task: playbook.Task{
+				Module:  "apt",
+				Params:  map[string]any{"name": "{{ item }}"},
+				Loop:    []any{"git"},
+				Creates: "/usr/bin/{{ item }}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			squashed, ok := squashLoop(&tt.task)
+			if ok != tt.wantSquash {
+				t.Fatalf("squash = %v, want %v", ok, tt.wantSquash)
+			}
+			if !ok {
+				return
+			}
+			if squashed.Loop != nil {
+				t.Error("squashed task still has a loop")
+			}
+			names, _ := squashed.Params["name"].([]any)
+			if len(names) != len(tt.task.Loop) {
+				t.Errorf("name = %v, want %v", squashed.Params["name"], tt.task.Loop)
+			}
+			if tt.task.Params["name"] == squashed.Params["name"] {
+				t.Error("original task params were modified")
+			}
+		})
+	}
+}
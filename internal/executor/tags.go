@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// CheckTags checks a tag expression, as set in Tags and SkipTags.
+func CheckTags(expr string) error {
+	_, err := parseTags(expr)
+	return err
+}
+
+// parseTags parses a tag expression: tag names joined with and, or, and
+// not. It returns nil for an empty expression.
+func parseTags(expr string) (*boolExpr, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	x, err := parseBoolExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag expression %q: %w", expr, err)
+	}
+	for _, atom := range x.atoms() {
+		if !playbook.ValidTag(atom) {
+			return nil, fmt.Errorf("invalid tag expression %q: %q is not a tag", expr, atom)
+		}
+	}
+	return x, nil
+}
+
+// matchTags reports whether the tag expression x holds for tags.
+func matchTags(x *boolExpr, tags []string) bool {
+	ok, _ := x.eval(func(tag string) (bool, error) {
+		return slices.Contains(tags, tag), nil
+	})
+	return ok
+}
+
+// selected reports whether task of play runs with the tags of the run.
+// A task tagged never only runs if the expression of Tags names one of
+// the task's own tags, not only those of its play.
+func (e *Executor) selected(play *playbook.Play, task *playbook.Task) bool {
+	tags := play.AllTags(task)
+	switch {
+	case e.skipTags != nil && matchTags(e.skipTags, tags):
+		return false
+	case slices.Contains(tags, playbook.TagAlways):
+		return true
+	case slices.Contains(tags, playbook.TagNever):
+		return e.tags != nil && matchTags(e.tags, tags) &&
+			slices.ContainsFunc(e.tags.atoms(), func(tag string) bool {
+				return tag != playbook.TagNever && slices.Contains(task.Tags, tag)
+			})
+	}
+	return e.tags == nil || matchTags(e.tags, tags)
+}
+
+// selectTasks returns the tasks of play that run with the tags of the run.
+func (e *Executor) selectTasks(play *playbook.Play, tasks []*playbook.Task) []*playbook.Task {
+	return slices.DeleteFunc(slices.Clone(tasks), func(task *playbook.Task) bool {
+		return !e.selected(play, task)
+	})
+}
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+func TestSelected(t *testing.T) {
+	play := &playbook.Play{
+		Tags: []string{"site"},
+		Tasks: []*playbook.Task{
+			{Name: "web", Tags: []string{"web"}},
+			{Name: "web slow", Tags: []string{"web", "slow"}},
+			{Name: "setup", Tags: []string{"setup"}},
+			{Name: "always", Tags: []string{"always"}},
+			{Name: "debug", Tags: []string{"never", "debug"}},
+			{Name: "plain"},
+		},
+	}
+
+	tests := []struct {
+		tags, skip string
+		want       []string
+	}{
+		{"", "", []string{"web", "web slow", "setup", "always", "plain"}},
+		{"web and not slow", "", []string{"web", "always"}},
+		{"setup or upgrade", "", []string{"setup", "always"}},
+		{"(web or setup) and not slow", "", []string{"web", "setup", "always"}},
+		{"site", "", []string{"web", "web slow", "setup", "always", "plain"}},
+		{"debug", "", []string{"always", "debug"}},
+		{"not web", "", []string{"setup", "always", "plain"}},
+		{"", "slow or always", []string{"web", "setup", "plain"}},
+		{"web", "slow", []string{"web", "always"}},
+	}
+	for _, tt := range tests {
+		exec := New()
+		var err error
+		if exec.tags, err = parseTags(tt.tags); err != nil {
+			t.Fatal(err)
+		}
+		if exec.skipTags, err = parseTags(tt.skip); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, task := range exec.selectTasks(play, play.Tasks) {
+			got = append(got, task.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tags %q, skip %q: selected %v, want %v", tt.tags, tt.skip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	for _, expr := range []string{"", "web", "web and not slow", "(a or b) and c-d", "ns:role.x"} {
+		if err := CheckTags(expr); err != nil {
+			t.Errorf("CheckTags(%q) = %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"web and", "(web", "two words", "'web'", "not"} {
+		if err := CheckTags(expr); err == nil {
+			t.Errorf("CheckTags(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestRunTags(t *testing.T) {
+	exec := New()
+	exec.Output = output.New(io.Discard)
+	exec.Tags = "configure"
+	fake := connectortest.New()
+	fake.Default(connector.Result{})
+	exec.SetConnector("web1", fake)
+
+	// A task graph still runs the tasks that require tasks left out
+	pb := &playbook.Playbook{Plays: []*playbook.Play{{
+		Hosts:       "web1",
+		GatherFacts: boolPtr(false),
+		TaskGraph:   true,
+		Tasks: []*playbook.Task{
+			{Name: "Install", Module: "test_echo", Params: map[string]any{"value": "install"}, Tags: []string{"install"}},
+			{Name: "Configure", Module: "test_echo", Params: map[string]any{"value": "configure"}, Tags: []string{"configure"}, Requires: []string{"Install"}},
+		},
+	}}}
+	result, err := exec.Run(context.Background(), pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmds := fake.Commands(); !result.Success || !reflect.DeepEqual(cmds, []string{"echo configure"}) {
+		t.Errorf("success = %v, commands = %q, want only configure", result.Success, cmds)
+	}
+	if result.Stats.Tasks != 1 {
+		t.Errorf("tasks = %d, want 1", result.Stats.Tasks)
+	}
+
+	exec.Tags = "web and"
+	if _, err := exec.Run(context.Background(), pb); err == nil {
+		t.Error("expected an error for an invalid tag expression")
+	}
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"encoding/base64"
 	"fmt"
 	"regexp"
 	"strings"
@@ -9,12 +10,26 @@ import (
 // varPattern matches {{ variable }} syntax.
 var varPattern = regexp.MustCompile(`\{\{\s*([^}]+?)\s*\}\}`)
 
+// maxVarDepth limits how deeply variables referencing other variables are
+// expanded, which stops reference cycles.
+const maxVarDepth = 32
+
+// UndefinedError reports a reference to an undefined variable in strict
+// mode.
+type UndefinedError struct {
+	Name string
+}
+
+func (e *UndefinedError) Error() string {
+	return fmt.Sprintf("undefined variable '%s' (use '{{ %s | default(...) }}' if it is optional)", e.Name, e.Name)
+}
+
 // interpolateParams recursively interpolates variables in task parameters.
 func (e *Executor) interpolateParams(params map[string]any, pctx *PlayContext) (map[string]any, error) {
 	result := make(map[string]any)
 
 	for k, v := range params {
-		interpolated, err := e.interpolateValue(v, pctx)
+		interpolated, err := e.interpolateValue(v, pctx, 0)
 		if err != nil {
 			return nil, fmt.Errorf("parameter '%s': %w", k, err)
 		}
@@ -24,16 +39,17 @@ func (e *Executor) interpolateParams(params map[string]any, pctx *PlayContext) (
 	return result, nil
 }
 
-// interpolateValue interpolates variables in a single value.
-func (e *Executor) interpolateValue(v any, pctx *PlayContext) (any, error) {
+// interpolateValue interpolates variables in a single value. depth counts
+// the variables being expanded to produce v.
+func (e *Executor) interpolateValue(v any, pctx *PlayContext, depth int) (any, error) {
 	switch val := v.(type) {
 	case string:
-		return e.interpolateString(val, pctx)
+		return e.expandString(val, pctx, depth)
 
 	case []any:
 		result := make([]any, len(val))
 		for i, item := range val {
-			interpolated, err := e.interpolateValue(item, pctx)
+			interpolated, err := e.interpolateValue(item, pctx, depth)
 			if err != nil {
 				return nil, err
 			}
@@ -44,7 +60,7 @@ func (e *Executor) interpolateValue(v any, pctx *PlayContext) (any, error) {
 	case map[string]any:
 		result := make(map[string]any)
 		for k, item := range val {
-			interpolated, err := e.interpolateValue(item, pctx)
+			interpolated, err := e.interpolateValue(item, pctx, depth)
 			if err != nil {
 				return nil, err
 			}
@@ -59,6 +75,18 @@ func (e *Executor) interpolateValue(v any, pctx *PlayContext) (any, error) {
 
 // interpolateString replaces {{ var }} patterns with their values.
 func (e *Executor) interpolateString(s string, pctx *PlayContext) (any, error) {
+	return e.expandString(s, pctx, 0)
+}
+
+// expandString replaces {{ var }} patterns in s with their values.
+func (e *Executor) expandString(s string, pctx *PlayContext, depth int) (any, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	if depth > maxVarDepth {
+		return nil, fmt.Errorf("variables nested too deeply in %q (reference cycle?)", s)
+	}
+
 	// Check if the entire string is a single variable reference
 	// In this case, return the actual value (not stringified)
 	trimmed := strings.TrimSpace(s)
@@ -66,7 +94,7 @@ func (e *Executor) interpolateString(s string, pctx *PlayContext) (any, error) {
 		inner := strings.TrimSpace(trimmed[2 : len(trimmed)-2])
 		if !strings.Contains(inner, "{{") {
 			// Single variable reference - return actual value
-			val, err := e.resolveVariable(inner, pctx)
+			val, err := e.resolveVariable(inner, pctx, depth)
 			if err != nil {
 				return nil, err
 			}
@@ -75,6 +103,8 @@ func (e *Executor) interpolateString(s string, pctx *PlayContext) (any, error) {
 	}
 
 	// Multiple variables or mixed content - stringify all values
+	var firstErr error
+	var lookupFailed bool
 	result := varPattern.ReplaceAllStringFunc(s, func(match string) string {
 		// Extract variable name
 		inner := varPattern.FindStringSubmatch(match)
@@ -83,74 +113,114 @@ func (e *Executor) interpolateString(s string, pctx *PlayContext) (any, error) {
 		}
 
 		varExpr := strings.TrimSpace(inner[1])
-		val, err := e.resolveVariable(varExpr, pctx)
+		val, err := e.resolveVariable(varExpr, pctx, depth)
 		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if strings.HasPrefix(varExpr, "lookup(") {
+				lookupFailed = true
+			}
 			return match // Keep original on error
 		}
+		if val == nil {
+			return ""
+		}
 
 		return fmt.Sprintf("%v", val)
 	})
 
+	// Strict mode fails instead of leaving unresolved templates behind, and
+	// a failed lookup never silently yields the template text
+	if firstErr != nil && (pctx.Strict || lookupFailed) {
+		return nil, firstErr
+	}
+
 	return result, nil
 }
 
 // resolveVariable resolves a variable expression.
-func (e *Executor) resolveVariable(expr string, pctx *PlayContext) (any, error) {
+func (e *Executor) resolveVariable(expr string, pctx *PlayContext, depth int) (any, error) {
 	expr = strings.TrimSpace(expr)
 
+	// Handle lookups (e.g., lookup('op', 'op://vault/item/field'))
+	if name, args, filter, ok, err := parseLookupCall(expr); ok {
+		if err != nil {
+			return nil, err
+		}
+		val, err := e.runLookup(name, args, pctx, depth)
+		if err != nil {
+			return nil, err
+		}
+		if filter == "" {
+			return val, nil
+		}
+		return e.applyFilter(val, filter)
+	}
+
 	// Handle filters (e.g., var | default('value'))
+	name, filter := expr, ""
 	if idx := strings.Index(expr, "|"); idx > 0 {
-		varName := strings.TrimSpace(expr[:idx])
-		filter := strings.TrimSpace(expr[idx+1:])
-		return e.applyFilter(varName, filter, pctx)
+		name = strings.TrimSpace(expr[:idx])
+		filter = strings.TrimSpace(expr[idx+1:])
 	}
 
 	// Simple variable or dotted path
-	return e.lookupVariable(expr, pctx), nil
-}
-
-// lookupVariable looks up a variable by name or dotted path.
-func (e *Executor) lookupVariable(name string, pctx *PlayContext) any {
-	// Check registered results first
-	if val, ok := pctx.Registered[name]; ok {
-		return val
+	val, ok, err := e.lookupVariable(name, pctx, depth)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check vars
-	if val, ok := pctx.Vars[name]; ok {
-		return val
+	// default() is the way to reference optional variables in strict mode
+	if !ok && pctx.Strict && !strings.HasPrefix(filter, "default") {
+		return nil, &UndefinedError{Name: name}
 	}
 
-	// Handle dotted paths (e.g., facts.os_family, env.HOME)
-	if strings.Contains(name, ".") {
-		parts := strings.Split(name, ".")
-		var current any = pctx.Vars
+	if filter == "" {
+		return val, nil
+	}
+	return e.applyFilter(val, filter)
+}
 
-		for _, part := range parts {
-			switch c := current.(type) {
-			case map[string]any:
-				current = c[part]
-			case map[string]string:
-				current = c[part]
-			default:
-				return nil
-			}
+// lookupVariable looks up a variable by name or dotted path and reports
+// whether it is defined. Templates in user-defined variables are expanded
+// when the variable is used, so variables can be built from other
+// variables regardless of the order they are defined in.
+func (e *Executor) lookupVariable(name string, pctx *PlayContext, depth int) (any, bool, error) {
+	val, layer, ok := pctx.Vars.Resolve(name)
+	if !ok {
+		return nil, false, nil
+	}
 
-			if current == nil {
-				return nil
-			}
-		}
+	// Registered results and facts are data from the target and are used
+	// verbatim
+	if !layer.Templated() {
+		return val, true, nil
+	}
 
-		return current
+	val, err := e.interpolateValue(val, pctx, depth+1)
+	if err != nil {
+		return nil, true, fmt.Errorf("variable '%s': %w", name, err)
 	}
+	return val, true, nil
+}
 
-	return nil
+// templateVars returns the variables passed to the template module, with
+// templates in user-defined variables expanded.
+func (e *Executor) templateVars(pctx *PlayContext) (map[string]any, error) {
+	vars := pctx.Vars.All()
+	for name := range vars {
+		val, _, err := e.lookupVariable(name, pctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		vars[name] = val
+	}
+	return vars, nil
 }
 
 // applyFilter applies a filter to a value.
-func (e *Executor) applyFilter(varName, filter string, pctx *PlayContext) (any, error) {
-	val := e.lookupVariable(varName, pctx)
-
+func (e *Executor) applyFilter(val any, filter string) (any, error) {
 	// Parse filter name and arguments
 	filterName := filter
 	var filterArg string
@@ -190,6 +260,22 @@ func (e *Executor) applyFilter(varName, filter string, pctx *PlayContext) (any,
 		}
 		return val, nil
 
+	case "b64encode":
+		if s, ok := val.(string); ok {
+			return base64.StdEncoding.EncodeToString([]byte(s)), nil
+		}
+		return val, nil
+
+	case "b64decode":
+		if s, ok := val.(string); ok {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("b64decode: %w", err)
+			}
+			return string(b), nil
+		}
+		return val, nil
+
 	case "bool":
 		return isTruthy(val), nil
 
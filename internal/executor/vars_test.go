@@ -1,13 +1,14 @@
 package executor
 
 import (
+	"errors"
 	"testing"
 )
 
 func TestInterpolateString(t *testing.T) {
 	exec := New()
 	pctx := &PlayContext{
-		Vars: map[string]any{
+		Vars: testScope(map[string]any{
 			"name":     "world",
 			"greeting": "hello",
 			"count":    42,
@@ -19,8 +20,7 @@ func TestInterpolateString(t *testing.T) {
 				"os":        "linux",
 				"os_family": "Debian",
 			},
-		},
-		Registered: make(map[string]any),
+		}, nil),
 	}
 
 	tests := []struct {
@@ -88,7 +88,7 @@ func TestInterpolateString(t *testing.T) {
 func TestLookupVariable(t *testing.T) {
 	exec := New()
 	pctx := &PlayContext{
-		Vars: map[string]any{
+		Vars: testScope(map[string]any{
 			"simple": "value",
 			"nested": map[string]any{
 				"key": "nested_value",
@@ -96,13 +96,12 @@ func TestLookupVariable(t *testing.T) {
 					"value": "deep_value",
 				},
 			},
-		},
-		Registered: map[string]any{
+		}, map[string]any{
 			"result": map[string]any{
 				"changed": true,
 				"data":    "test",
 			},
-		},
+		}),
 	}
 
 	tests := []struct {
@@ -120,7 +119,7 @@ func TestLookupVariable(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := exec.lookupVariable(tt.key, pctx)
+			got, _, _ := exec.lookupVariable(tt.key, pctx, 0)
 			if tt.want == nil && got != nil {
 				t.Errorf("expected nil, got %v", got)
 			} else if tt.want != nil {
@@ -143,15 +142,15 @@ func TestLookupVariable(t *testing.T) {
 func TestApplyFilter(t *testing.T) {
 	exec := New()
 	pctx := &PlayContext{
-		Vars: map[string]any{
+		Vars: testScope(map[string]any{
 			"name":      "Hello World",
 			"empty":     "",
 			"items":     []any{"a", "b", "c"},
 			"number":    "42",
 			"trimmed":   "  spaces  ",
 			"undefined": nil,
-		},
-		Registered: make(map[string]any),
+			"encoded":   "SGVsbG8gV29ybGQ=",
+		}, nil),
 	}
 
 	tests := []struct {
@@ -172,11 +171,14 @@ func TestApplyFilter(t *testing.T) {
 		{"length array", "items", "length", 3},
 		{"join default", "items", "join", "a,b,c"},
 		{"join custom", "items", "join(' ')", "a b c"},
+		{"b64encode", "name", "b64encode", "SGVsbG8gV29ybGQ="},
+		{"b64decode", "encoded", "b64decode", "Hello World"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := exec.applyFilter(tt.varName, tt.filter, pctx)
+			val, _, _ := exec.lookupVariable(tt.varName, pctx, 0)
+			got, err := exec.applyFilter(val, tt.filter)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 				return
@@ -190,12 +192,8 @@ func TestApplyFilter(t *testing.T) {
 
 func TestApplyFilterUnknown(t *testing.T) {
 	exec := New()
-	pctx := &PlayContext{
-		Vars:       map[string]any{"x": "test"},
-		Registered: make(map[string]any),
-	}
 
-	_, err := exec.applyFilter("x", "unknownfilter", pctx)
+	_, err := exec.applyFilter("test", "unknownfilter")
 	if err == nil {
 		t.Error("expected error for unknown filter")
 	}
@@ -204,11 +202,10 @@ func TestApplyFilterUnknown(t *testing.T) {
 func TestInterpolateParams(t *testing.T) {
 	exec := New()
 	pctx := &PlayContext{
-		Vars: map[string]any{
+		Vars: testScope(map[string]any{
 			"pkg":  "nginx",
 			"path": "/var/www",
-		},
-		Registered: make(map[string]any),
+		}, nil),
 	}
 
 	params := map[string]any{
@@ -236,10 +233,9 @@ func TestInterpolateParams(t *testing.T) {
 func TestInterpolateNestedParams(t *testing.T) {
 	exec := New()
 	pctx := &PlayContext{
-		Vars: map[string]any{
+		Vars: testScope(map[string]any{
 			"user": "admin",
-		},
-		Registered: make(map[string]any),
+		}, nil),
 	}
 
 	params := map[string]any{
@@ -265,3 +261,104 @@ func TestInterpolateNestedParams(t *testing.T) {
 		t.Errorf("items[0]: expected 'admin', got %v", items[0])
 	}
 }
+
+func TestInterpolateStrict(t *testing.T) {
+	exec := New()
+	pctx := &PlayContext{
+		Vars: testScope(map[string]any{
+			"name":  "world",
+			"empty": "",
+			"facts": map[string]any{"os": "linux"},
+		}, nil),
+		Strict: true,
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr bool
+	}{
+		{"defined", "{{ name }}", "world", false},
+		{"empty is defined", "{{ empty }}", "", false},
+		{"undefined", "{{ missing }}", nil, true},
+		{"undefined in text", "Hello, {{ missing }}!", nil, true},
+		{"undefined dotted", "{{ facts.arch }}", nil, true},
+		{"undefined with filter", "{{ missing | upper }}", nil, true},
+		{"undefined with default", "{{ missing | default('x') }}", "x", false},
+		{"default in text", "a-{{ missing | default('b') }}", "a-b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := exec.interpolateString(tt.input, pctx)
+			if tt.wantErr {
+				var undef *UndefinedError
+				if !errors.As(err, &undef) {
+					t.Fatalf("expected UndefinedError, got %v (%v)", err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateConditionStrict(t *testing.T) {
+	exec := New()
+	pctx := &PlayContext{
+		Vars:   testScope(map[string]any{"os_family": "Debian"}, nil),
+		Strict: true,
+	}
+
+	for _, cond := range []string{"missing", "not missing", "missing == 'x'", "os_family == Debian", "result.changed"} {
+		if _, err := exec.evaluateCondition(cond, pctx); err == nil {
+			t.Errorf("%q: expected undefined variable error", cond)
+		}
+	}
+	if ok, err := exec.evaluateCondition("os_family == 'Debian'", pctx); err != nil || !ok {
+		t.Errorf("quoted literal: got %v, %v", ok, err)
+	}
+}
+
+func TestInterpolateLazy(t *testing.T) {
+	exec := New()
+	scope := testScope(map[string]any{
+		"url":   "http://{{ host }}:{{ port }}",
+		"host":  "{{ name }}.local",
+		"port":  8080,
+		"ports": []any{"{{ port }}"},
+		"a":     "{{ b }}",
+		"b":     "{{ a }}",
+	}, map[string]any{
+		"out": map[string]any{"data": map[string]any{"stdout": "{{ name }}"}},
+	})
+	scope.Set(LayerRoleDefaults, "name", "default")
+	scope.Set(LayerExtraVars, "name", "web")
+	pctx := &PlayContext{Vars: scope}
+
+	got, err := exec.interpolateString("{{ url }}", pctx)
+	if err != nil || got != "http://web.local:8080" {
+		t.Errorf("url = %v, %v; want http://web.local:8080", got, err)
+	}
+
+	got, err = exec.interpolateString("{{ ports | first }}", pctx)
+	if err != nil || got != 8080 {
+		t.Errorf("ports | first = %v, %v; want 8080", got, err)
+	}
+
+	// Registered output is data and is never expanded
+	got, err = exec.interpolateString("{{ out.data.stdout }}", pctx)
+	if err != nil || got != "{{ name }}" {
+		t.Errorf("registered stdout = %v, %v; want it verbatim", got, err)
+	}
+
+	if _, err := exec.interpolateString("{{ a }}", pctx); err == nil {
+		t.Error("expected error for reference cycle")
+	}
+}
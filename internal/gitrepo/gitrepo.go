@@ -0,0 +1,90 @@
+// Package gitrepo keeps local checkouts of playbook repositories up to
+// date using the git command.
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Pull fast-forwards the checkout containing dir to its upstream branch.
+// Local changes that conflict, or a branch that diverged, are errors
+// rather than merges, so a host never runs a playbook nobody committed.
+func Pull(ctx context.Context, dir string) error {
+	_, err := git(ctx, dir, "pull", "--ff-only", "--quiet")
+	return err
+}
+
+// Head returns the commit checked out in dir.
+func Head(ctx context.Context, dir string) (string, error) {
+	return git(ctx, dir, "rev-parse", "HEAD")
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Sync makes dir a checkout of ref (a branch or tag; "" for the default
+// branch) of the repository at url, cloning it if dir does not exist.
+// An existing checkout is reset to the fetched commit, discarding local
+// changes, so dir must be used only by Sync. It reports whether the
+// checked out commit changed.
+func Sync(ctx context.Context, url, dir, ref string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return false, err
+		}
+		args := []string{"clone", "--quiet"}
+		if ref != "" {
+			args = append(args, "--branch", ref)
+		}
+		if _, err := git(ctx, ".", append(args, "--", url, dir)...); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	origin, err := git(ctx, dir, "remote", "get-url", "origin")
+	if err != nil {
+		return false, err
+	}
+	if origin != url {
+		return false, fmt.Errorf("%s is a checkout of %s, not %s", dir, origin, url)
+	}
+
+	before, err := Head(ctx, dir)
+	if err != nil {
+		return false, err
+	}
+	fetch := []string{"fetch", "--quiet", "--force", "origin"}
+	if ref != "" {
+		fetch = append(fetch, ref)
+	}
+	if _, err := git(ctx, dir, fetch...); err != nil {
+		return false, err
+	}
+	if _, err := git(ctx, dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+		return false, err
+	}
+	after, err := Head(ctx, dir)
+	if err != nil {
+		return false, err
+	}
+	return after != before, nil
+}
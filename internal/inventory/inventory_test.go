@@ -0,0 +1,332 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testInventory = `
+all:
+  vars:
+    ntp_server: time.example.com
+    role: generic
+  hosts:
+    bastion:
+  children:
+    webservers:
+      hosts:
+        web1:
+          ansible_host: web1-container
+        web2:
+      vars:
+        ansible_connection: docker
+        role: web
+      children:
+        canary:
+          hosts:
+            web2:
+              role: canary-host
+          vars:
+            role: canary
+    databases:
+      hosts:
+        db1:
+          connection: docker
+`
+
+func parseTest(t *testing.T) *Inventory {
+	t.Helper()
+	inv, err := Parse([]byte(testInventory))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return inv
+}
+
+func names(hosts []*Host) string {
+	var s []string
+	for _, h := range hosts {
+		s = append(s, h.Name)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestParse(t *testing.T) {
+	inv := parseTest(t)
+
+	if got := names(inv.Hosts()); got != "bastion,web1,web2,db1" {
+		t.Errorf("Hosts() = %s", got)
+	}
+
+	web1, ok := inv.Host("web1")
+	if !ok {
+		t.Fatal("web1 not found")
+	}
+	if web1.Address != "web1-container" {
+		t.Errorf("web1 Address = %q, want web1-container", web1.Address)
+	}
+	if web1.Connection != "docker" {
+		t.Errorf("web1 Connection = %q, want docker (from group vars)", web1.Connection)
+	}
+	if web1.Vars["ntp_server"] != "time.example.com" {
+		t.Errorf("web1 ntp_server = %v, want value from all", web1.Vars["ntp_server"])
+	}
+	if web1.Vars["role"] != "web" {
+		t.Errorf("web1 role = %v, want web", web1.Vars["role"])
+	}
+
+	bastion, _ := inv.Host("bastion")
+	if bastion.Address != "bastion" || bastion.Connection != "" {
+		t.Errorf("bastion = %+v, want address defaulting to name and no connection", bastion)
+	}
+	if len(bastion.Groups) != 0 {
+		t.Errorf("bastion Groups = %v, want none", bastion.Groups)
+	}
+
+	db1, _ := inv.Host("db1")
+	if db1.Connection != "docker" {
+		t.Errorf("db1 Connection = %q, want docker", db1.Connection)
+	}
+}
+
+func TestParseVarPrecedence(t *testing.T) {
+	inv := parseTest(t)
+
+	// Host vars beat the deepest group, which beats its parent
+	web2, _ := inv.Host("web2")
+	if web2.Vars["role"] != "canary-host" {
+		t.Errorf("web2 role = %v, want canary-host", web2.Vars["role"])
+	}
+	if got := strings.Join(web2.Groups, ","); got != "webservers,canary" {
+		t.Errorf("web2 Groups = %s, want webservers,canary", got)
+	}
+
+	inv, err := Parse([]byte(`
+webservers:
+  hosts:
+    web1:
+  vars:
+    role: web
+canary:
+  hosts:
+    web1:
+  vars:
+    role: canary
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	// Groups at the same depth apply in name order
+	web1, _ := inv.Host("web1")
+	if web1.Vars["role"] != "web" {
+		t.Errorf("web1 role = %v, want web", web1.Vars["role"])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "not a mapping",
+			yaml:    "- web1\n",
+			wantErr: "mapping of groups",
+		},
+		{
+			name:    "unknown key",
+			yaml:    "web:\n  host: web1\n",
+			wantErr: `unknown key "host"`,
+		},
+		{
+			name:    "hosts list",
+			yaml:    "web:\n  hosts:\n    - web1\n",
+			wantErr: "hosts must be a mapping",
+		},
+		{
+			name:    "host vars not a mapping",
+			yaml:    "web:\n  hosts:\n    web1: 10.0.0.1\n",
+			wantErr: `host "web1"`,
+		},
+		{
+			name:    "cycle",
+			yaml:    "a:\n  children:\n    b:\n      children:\n        a:\n",
+			wantErr: "group cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	inv := parseTest(t)
+
+	tests := []struct {
+		patterns []string
+		want     string
+		wantErr  bool
+	}{
+		{patterns: []string{"all"}, want: "bastion,web1,web2,db1"},
+		{patterns: []string{"*"}, want: "bastion,web1,web2,db1"},
+		{patterns: []string{"webservers"}, want: "web1,web2"},
+		{patterns: []string{"db1", "web1"}, want: "web1,db1"},
+		{patterns: []string{"web*"}, want: "web1,web2"},
+		{patterns: []string{"webservers", "!canary"}, want: "web1"},
+		{patterns: []string{"all", "&webservers", "!web1"}, want: "web2"},
+		{patterns: []string{"webservers", "databases"}, want: "web1,web2,db1"},
+		{patterns: []string{"localhost"}, want: "localhost"},
+		{patterns: []string{"nope*"}, want: ""},
+		{patterns: []string{"missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.patterns, ","), func(t *testing.T) {
+			hosts, err := inv.Resolve(tt.patterns)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolve() = %s, want error", names(hosts))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if got := names(hosts); got != tt.want {
+				t.Errorf("Resolve() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveLocalhost(t *testing.T) {
+	hosts, err := parseTest(t).Resolve([]string{"localhost"})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].Connection != "local" {
+		t.Errorf("Resolve(localhost) = %+v, want implicit local host", hosts)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	hosts := parseTest(t).Hosts()
+
+	tests := []struct {
+		patterns []string
+		want     string
+	}{
+		{patterns: []string{"webservers"}, want: "web1,web2"},
+		{patterns: []string{"!webservers"}, want: "bastion,db1"},
+		{patterns: []string{"db1", "bastion"}, want: "bastion,db1"},
+		{patterns: []string{"missing"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.patterns, ","), func(t *testing.T) {
+			if got := names(Limit(hosts, tt.patterns)); got != tt.want {
+				t.Errorf("Limit() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	hosts, err := Literal([]string{"web1", "web2", "!web2"})
+	if err != nil {
+		t.Fatalf("Literal() error = %v", err)
+	}
+	if got := names(hosts); got != "web1" {
+		t.Errorf("Literal() = %s, want web1", got)
+	}
+	if hosts[0].Address != "web1" || hosts[0].Connection != "" {
+		t.Errorf("Literal() host = %+v, want address web1 and no connection", hosts[0])
+	}
+
+	if _, err := Literal([]string{"webservers*"}); err == nil {
+		t.Error("Literal() with a glob: want error")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	if err := os.WriteFile(path, []byte(testInventory), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(inv.Hosts()) != 4 {
+		t.Errorf("Load() hosts = %d, want 4", len(inv.Hosts()))
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load() of a missing file: want error")
+	}
+}
+
+func TestList(t *testing.T) {
+	var b strings.Builder
+	if err := parseTest(t).List(&b); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var out map[string]any
+	if err := json.Unmarshal([]byte(b.String()), &out); err != nil {
+		t.Fatalf("List() wrote invalid JSON: %v", err)
+	}
+
+	web, _ := out["webservers"].(map[string]any)
+	if fmt.Sprint(web["hosts"]) != "[web1 web2]" || fmt.Sprint(web["children"]) != "[canary]" {
+		t.Errorf("webservers = %v", web)
+	}
+	meta, _ := out["_meta"].(map[string]any)
+	hostvars, _ := meta["hostvars"].(map[string]any)
+	web2, _ := hostvars["web2"].(map[string]any)
+	if web2["role"] != "canary-host" {
+		t.Errorf("hostvars.web2 = %v, want merged vars", web2)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	inv := parseTest(t)
+
+	var b strings.Builder
+	if err := inv.Graph(&b, "webservers", false); err != nil {
+		t.Fatalf("Graph() error = %v", err)
+	}
+	want := `@webservers:
+  |--@canary:
+  |  |--web2
+  |--web1
+  |--web2
+`
+	if b.String() != want {
+		t.Errorf("Graph() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := inv.Graph(&b, "databases", true); err != nil {
+		t.Fatalf("Graph() error = %v", err)
+	}
+	if !strings.Contains(b.String(), "  |  |--{connection = docker}\n") {
+		t.Errorf("Graph() with vars =\n%s\nwant host vars", b.String())
+	}
+
+	if err := inv.Graph(&b, "missing", false); err == nil {
+		t.Error("Graph() of an unknown group: want error")
+	}
+}
@@ -0,0 +1,87 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+func init() {
+	Register(&AWSSecret{})
+	Register(&AWSSSM{})
+}
+
+// AWSSecret reads a secret from AWS Secrets Manager with the AWS CLI,
+// using its usual credential and region configuration.
+//
+// Arguments:
+//   - secret_id: The name or ARN of the secret
+//   - key (optional): For JSON secrets, the key to return
+type AWSSecret struct{}
+
+// Name returns "aws_secret".
+func (l *AWSSecret) Name() string { return "aws_secret" }
+
+// Secret reports that Secrets Manager values are masked.
+func (l *AWSSecret) Secret() bool { return true }
+
+// Lookup reads the secret string, or one key of a JSON secret.
+func (l *AWSSecret) Lookup(ctx context.Context, args []string) (string, error) {
+	if err := checkArgs(l.Name(), args, 1, 2); err != nil {
+		return "", err
+	}
+
+	val, err := runCommand(ctx, "aws", "secretsmanager", "get-secret-value",
+		"--secret-id", args[0],
+		"--query", "SecretString",
+		"--output", "text")
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 1 {
+		return val, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(val), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object", args[0])
+	}
+	field, ok := fields[args[1]]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %q", args[0], args[1])
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprintf("%v", field), nil
+}
+
+// AWSSSM reads a parameter from AWS Systems Manager Parameter Store with
+// the AWS CLI. SecureString parameters are decrypted.
+//
+// Arguments:
+//   - name: The parameter name (e.g., /app/db/password)
+type AWSSSM struct{}
+
+// Name returns "aws_ssm".
+func (l *AWSSSM) Name() string { return "aws_ssm" }
+
+// Secret reports that parameter values are masked.
+func (l *AWSSSM) Secret() bool { return true }
+
+// Lookup reads the parameter value.
+func (l *AWSSSM) Lookup(ctx context.Context, args []string) (string, error) {
+	if err := checkArgs(l.Name(), args, 1, 1); err != nil {
+		return "", err
+	}
+	return runCommand(ctx, "aws", "ssm", "get-parameter",
+		"--name", args[0],
+		"--with-decryption",
+		"--query", "Parameter.Value",
+		"--output", "text")
+}
+
+var (
+	_ Lookup = (*AWSSecret)(nil)
+	_ Lookup = (*AWSSSM)(nil)
+)
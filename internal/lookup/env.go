@@ -0,0 +1,39 @@
+package lookup
+
+import (
+	"context"
+	"os"
+)
+
+func init() {
+	Register(&Env{})
+}
+
+// Env reads an environment variable of the machine running bolt.
+//
+// Arguments:
+//   - name: The variable to read
+//   - default (optional): Value used if the variable is unset
+type Env struct{}
+
+// Name returns "env".
+func (l *Env) Name() string { return "env" }
+
+// Secret reports that environment values are not masked.
+func (l *Env) Secret() bool { return false }
+
+// Lookup returns the value of the environment variable.
+func (l *Env) Lookup(ctx context.Context, args []string) (string, error) {
+	if err := checkArgs(l.Name(), args, 1, 2); err != nil {
+		return "", err
+	}
+	if val, ok := os.LookupEnv(args[0]); ok {
+		return val, nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return "", nil
+}
+
+var _ Lookup = (*Env)(nil)
@@ -0,0 +1,81 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	Register(&FirstFound{})
+}
+
+// ErrNotFound is returned when none of the candidate files exists.
+var ErrNotFound = errors.New("no file found")
+
+// FirstFound picks the first existing file among candidates, typically
+// platform-specific variants of a file followed by a generic fallback:
+//
+//	src: "{{ lookup('first_found', 'nginx.conf.debian', 'nginx.conf') }}"
+//
+// Relative candidates are looked for in the search path of ctx (the role's
+// files and templates directories), then relative to the working
+// directory.
+//
+// Arguments:
+//   - candidates: One or more file paths, in order of preference
+type FirstFound struct{}
+
+// Name returns "first_found".
+func (l *FirstFound) Name() string { return "first_found" }
+
+// Secret reports that paths are not masked.
+func (l *FirstFound) Secret() bool { return false }
+
+// Lookup returns the path of the first candidate that exists.
+func (l *FirstFound) Lookup(ctx context.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("%s lookup takes at least 1 argument, got 0", l.Name())
+	}
+	return FindFirst(args, SearchPath(ctx))
+}
+
+// FindFirst returns the path of the first candidate that exists. Relative
+// candidates are looked for in each of dirs, then as they are.
+func FindFirst(candidates, dirs []string) (string, error) {
+	for _, candidate := range candidates {
+		paths := []string{candidate}
+		if !filepath.IsAbs(candidate) {
+			paths = nil
+			for _, dir := range dirs {
+				paths = append(paths, filepath.Join(dir, candidate))
+			}
+			paths = append(paths, candidate)
+		}
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w among %s", ErrNotFound, strings.Join(candidates, ", "))
+}
+
+type searchPathKey struct{}
+
+// WithSearchPath returns a copy of ctx in which lookups find relative
+// files in dirs.
+func WithSearchPath(ctx context.Context, dirs []string) context.Context {
+	return context.WithValue(ctx, searchPathKey{}, dirs)
+}
+
+// SearchPath returns the directories set by WithSearchPath.
+func SearchPath(ctx context.Context) []string {
+	dirs, _ := ctx.Value(searchPathKey{}).([]string)
+	return dirs
+}
+
+var _ Lookup = (*FirstFound)(nil)
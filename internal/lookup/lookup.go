@@ -0,0 +1,110 @@
+// Package lookup fetches values from outside the playbook at runtime, such
+// as secrets from a password manager.
+//
+// Lookups run on the machine running bolt, not on the target, and are
+// called from templates:
+//
+//	password: "{{ lookup('op', 'op://Infra/db/password') }}"
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout bounds how long a single lookup may take.
+const Timeout = 30 * time.Second
+
+// Lookup is the interface that all lookups must implement.
+type Lookup interface {
+	// Name returns the lookup's unique identifier.
+	Name() string
+
+	// Secret reports whether values are sensitive and must be masked in
+	// output.
+	Secret() bool
+
+	// Lookup returns the value for the given arguments.
+	Lookup(ctx context.Context, args []string) (string, error)
+}
+
+// registry holds all registered lookups.
+var (
+	registry   = make(map[string]Lookup)
+	registryMu sync.RWMutex
+)
+
+// Register adds a lookup to the registry.
+// It panics if a lookup with the same name is already registered.
+func Register(l Lookup) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name := l.Name()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("lookup %q is already registered", name))
+	}
+	registry[name] = l
+}
+
+// Get retrieves a lookup from the registry by name.
+// Returns nil if the lookup is not found.
+func Get(name string) Lookup {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[name]
+}
+
+// List returns the names of all registered lookups, sorted.
+func List() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runCommand runs a CLI on the local machine and returns its trimmed
+// stdout. It is a variable so tests can replace it.
+var runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("%s CLI not found in PATH", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %s", name, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// checkArgs verifies that between min and max arguments were given.
+func checkArgs(name string, args []string, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("%s lookup takes %d argument(s), got %d", name, min, len(args))
+		}
+		return fmt.Errorf("%s lookup takes %d to %d arguments, got %d", name, min, max, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCommands replaces runCommand with a function returning outputs keyed
+// by the full command line, and restores it when the test ends.
+func fakeCommands(t *testing.T, outputs map[string]string) *[]string {
+	t.Helper()
+
+	var calls []string
+	orig := runCommand
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		line := strings.Join(append([]string{name}, args...), " ")
+		calls = append(calls, line)
+		if out, ok := outputs[line]; ok {
+			return out, nil
+		}
+		t.Fatalf("unexpected command: %s", line)
+		return "", nil
+	}
+	t.Cleanup(func() { runCommand = orig })
+	return &calls
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"env", "op", "aws_secret", "aws_ssm", "vault", "first_found"} {
+		if Get(name) == nil {
+			t.Errorf("lookup %q is not registered", name)
+		}
+	}
+	if Get("env").Secret() {
+		t.Error("env lookup should not be secret")
+	}
+	if !Get("vault").Secret() {
+		t.Error("vault lookup should be secret")
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("BOLT_LOOKUP_TEST", "value")
+	l := Get("env")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"BOLT_LOOKUP_TEST"}, "value"},
+		{[]string{"BOLT_LOOKUP_UNSET"}, ""},
+		{[]string{"BOLT_LOOKUP_UNSET", "fallback"}, "fallback"},
+	}
+	for _, tt := range tests {
+		got, err := l.Lookup(context.Background(), tt.args)
+		if err != nil || got != tt.want {
+			t.Errorf("env%v = %q, %v; want %q", tt.args, got, err, tt.want)
+		}
+	}
+
+	if _, err := l.Lookup(context.Background(), nil); err == nil {
+		t.Error("expected error without arguments")
+	}
+}
+
+func TestSecretLookups(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"op read op://Infra/db/password": "op-secret",
+		"aws secretsmanager get-secret-value --secret-id app --query SecretString --output text":          `{"user":"admin","port":5432}`,
+		"aws secretsmanager get-secret-value --secret-id plain --query SecretString --output text":        "plain-secret",
+		"aws ssm get-parameter --name /app/token --with-decryption --query Parameter.Value --output text": "ssm-secret",
+		"vault kv get -field=password secret/app":                                                         "vault-secret",
+	})
+
+	tests := []struct {
+		lookup string
+		args   []string
+		want   string
+	}{
+		{"op", []string{"op://Infra/db/password"}, "op-secret"},
+		{"aws_secret", []string{"plain"}, "plain-secret"},
+		{"aws_secret", []string{"app", "user"}, "admin"},
+		{"aws_secret", []string{"app", "port"}, "5432"},
+		{"aws_ssm", []string{"/app/token"}, "ssm-secret"},
+		{"vault", []string{"secret/app", "password"}, "vault-secret"},
+	}
+	for _, tt := range tests {
+		got, err := Get(tt.lookup).Lookup(context.Background(), tt.args)
+		if err != nil || got != tt.want {
+			t.Errorf("%s%v = %q, %v; want %q", tt.lookup, tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestFirstFound(t *testing.T) {
+	dir := t.TempDir()
+	files := filepath.Join(dir, "files")
+	if err := os.MkdirAll(filepath.Join(files, "app.conf.darwin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(files, "app.conf"), filepath.Join(dir, "app.conf.debian")} {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := WithSearchPath(context.Background(), []string{files})
+	l := Get("first_found")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"app.conf.debian", "app.conf"}, filepath.Join(files, "app.conf")},
+		// Directories do not count
+		{[]string{"app.conf.darwin", "app.conf"}, filepath.Join(files, "app.conf")},
+		{[]string{filepath.Join(dir, "app.conf.debian"), "app.conf"}, filepath.Join(dir, "app.conf.debian")},
+	}
+	for _, tt := range tests {
+		got, err := l.Lookup(ctx, tt.args)
+		if err != nil || got != tt.want {
+			t.Errorf("first_found%v = %q, %v; want %q", tt.args, got, err, tt.want)
+		}
+	}
+
+	if _, err := l.Lookup(context.Background(), []string{"app.conf"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("error = %v, want ErrNotFound", err)
+	}
+	if _, err := l.Lookup(ctx, nil); err == nil {
+		t.Error("expected error without arguments")
+	}
+}
+
+func TestSecretLookupErrors(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"aws secretsmanager get-secret-value --secret-id app --query SecretString --output text": `{"user":"admin"}`,
+	})
+
+	tests := []struct {
+		lookup string
+		args   []string
+	}{
+		{"op", []string{"Infra/db/password"}},
+		{"aws_secret", []string{"app", "missing"}},
+		{"vault", []string{"secret/app"}},
+	}
+	for _, tt := range tests {
+		if _, err := Get(tt.lookup).Lookup(context.Background(), tt.args); err == nil {
+			t.Errorf("%s%v: expected error", tt.lookup, tt.args)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	Register(&OnePassword{})
+}
+
+// OnePassword reads a secret with the 1Password CLI (op). The CLI must be
+// signed in, or OP_SERVICE_ACCOUNT_TOKEN must be set.
+//
+// Arguments:
+//   - reference: A secret reference (op://vault/item/field)
+type OnePassword struct{}
+
+// Name returns "op".
+func (l *OnePassword) Name() string { return "op" }
+
+// Secret reports that 1Password values are masked.
+func (l *OnePassword) Secret() bool { return true }
+
+// Lookup reads the referenced secret.
+func (l *OnePassword) Lookup(ctx context.Context, args []string) (string, error) {
+	if err := checkArgs(l.Name(), args, 1, 1); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(args[0], "op://") {
+		return "", fmt.Errorf("op lookup expects a secret reference (op://vault/item/field), got %q", args[0])
+	}
+	return runCommand(ctx, "op", "read", args[0])
+}
+
+var _ Lookup = (*OnePassword)(nil)
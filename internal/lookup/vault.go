@@ -0,0 +1,33 @@
+package lookup
+
+import (
+	"context"
+)
+
+func init() {
+	Register(&Vault{})
+}
+
+// Vault reads a field of a secret from a HashiCorp Vault KV store with the
+// vault CLI, using VAULT_ADDR and VAULT_TOKEN (or an existing login).
+//
+// Arguments:
+//   - path: The secret path (e.g., secret/app/db)
+//   - field: The field of the secret to return
+type Vault struct{}
+
+// Name returns "vault".
+func (l *Vault) Name() string { return "vault" }
+
+// Secret reports that Vault values are masked.
+func (l *Vault) Secret() bool { return true }
+
+// Lookup reads the field of the secret.
+func (l *Vault) Lookup(ctx context.Context, args []string) (string, error) {
+	if err := checkArgs(l.Name(), args, 2, 2); err != nil {
+		return "", err
+	}
+	return runCommand(ctx, "vault", "kv", "get", "-field="+args[1], args[0])
+}
+
+var _ Lookup = (*Vault)(nil)
@@ -0,0 +1,270 @@
+// Package alternatives provides a module for managing the alternatives system on Linux.
+package alternatives
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of an alternative.
+type State string
+
+const (
+	StateSelected State = "selected" // Ensure path is installed and selected as the current alternative
+	StatePresent  State = "present"  // Ensure path is installed as an alternative, without selecting it
+	StateAuto     State = "auto"     // Ensure the group is in automatic (priority-based) mode
+	StateAbsent   State = "absent"   // Ensure path is not an alternative
+)
+
+// Module manages update-alternatives (Debian) and alternatives (RedHat) links.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "alternatives"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "Alternative group name (e.g., `editor`, `java`)"},
+		{Name: "path", Type: "string", Description: "Path of the alternative"},
+		{Name: "link", Type: "string", Description: "Generic link; needed when the group does not exist yet"},
+		{Name: "priority", Type: "int", Default: 50, Description: "Priority of the alternative; changed when it differs"},
+		{Name: "state", Type: "string", Default: "selected", Choices: []string{"selected", "present", "auto", "absent"}, Description: "Desired state"},
+	}
+}
+
+// Run executes the alternatives module.
+//
+// Parameters:
+//   - name (string, required): Alternative group name (e.g., "editor", "java")
+//   - path (string): Path of the alternative (required unless state=auto)
+//   - link (string): Generic link path (e.g., /usr/bin/editor); needed when the group does not exist yet
+//   - priority (int): Priority of the alternative, changed when it differs (default: 50)
+//   - state (string): Desired state - selected, present, auto, absent (default: selected)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	path := param.String(params, "path", "")
+	link := param.String(params, "link", "")
+	priority := param.Int(params, "priority", 50)
+	stateStr := param.String(params, "state", "selected")
+	state := State(stateStr)
+
+	switch state {
+	case StateSelected, StatePresent, StateAbsent:
+		if path == "" {
+			return nil, module.ParamErrorf("path", "'path' parameter is required when state=%s", state)
+		}
+	case StateAuto:
+		// path is optional
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be selected, present, auto, or absent", state)
+	}
+
+	bin, err := findBinary(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := queryGroup(ctx, conn, bin, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed bool
+	var messages []string
+
+	switch state {
+	case StateAbsent:
+		if !current.has(path) {
+			return module.Unchanged(fmt.Sprintf("%s is not an alternative for %s", path, name)), nil
+		}
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("%s --remove %s %s", bin, shellutil.Quote(name), shellutil.Quote(path))); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed %s from %s", path, name)), nil
+
+	case StateAuto:
+		if current.Exists && current.Mode == "auto" {
+			return module.Unchanged(fmt.Sprintf("%s already in auto mode", name)), nil
+		}
+		if !current.Exists && path == "" {
+			return nil, fmt.Errorf("alternative group '%s' does not exist", name)
+		}
+	}
+
+	// Install the alternative if it is not registered yet, or register it
+	// again to change its priority
+	registered, installed := current.Paths[path]
+	if path != "" && (!installed || registered != priority) {
+		if link == "" {
+			link = current.Link
+		}
+		if link == "" {
+			return nil, module.ParamErrorf("link", "'link' parameter is required to create alternative group '%s'", name)
+		}
+		cmd := fmt.Sprintf("%s --install %s %s %s %d", bin, shellutil.Quote(link), shellutil.Quote(name), shellutil.Quote(path), priority)
+		if err := module.RunCommand(ctx, conn, cmd); err != nil {
+			return nil, err
+		}
+		changed = true
+		if installed {
+			messages = append(messages, fmt.Sprintf("changed priority of %s for %s from %d to %d", path, name, registered, priority))
+		} else {
+			messages = append(messages, fmt.Sprintf("installed %s as alternative for %s", path, name))
+		}
+	}
+
+	switch state {
+	case StateSelected:
+		if current.Value != path || current.Mode != "manual" {
+			if err := module.RunCommand(ctx, conn, fmt.Sprintf("%s --set %s %s", bin, shellutil.Quote(name), shellutil.Quote(path))); err != nil {
+				return nil, err
+			}
+			changed = true
+			messages = append(messages, fmt.Sprintf("selected %s for %s", path, name))
+		}
+
+	case StateAuto:
+		if current.Mode != "auto" {
+			if err := module.RunCommand(ctx, conn, fmt.Sprintf("%s --auto %s", bin, shellutil.Quote(name))); err != nil {
+				return nil, err
+			}
+			changed = true
+			messages = append(messages, fmt.Sprintf("set %s to auto mode", name))
+		}
+	}
+
+	if !changed {
+		return module.Unchanged("alternative already in desired state"), nil
+	}
+
+	return module.ChangedWithData(strings.Join(messages, ", "), map[string]any{
+		"name": name,
+		"path": path,
+	}), nil
+}
+
+// groupState holds the current state of an alternatives group.
+type groupState struct {
+	Exists bool
+	Link   string
+	Mode   string         // auto or manual
+	Value  string         // currently selected path
+	Paths  map[string]int // registered paths and their priorities
+}
+
+// has reports whether path is a registered alternative.
+func (g *groupState) has(path string) bool {
+	_, ok := g.Paths[path]
+	return ok
+}
+
+// findBinary returns the alternatives command available on the target.
+func findBinary(ctx context.Context, conn connector.Connector) (string, error) {
+	result, err := conn.Execute(ctx, "command -v update-alternatives || command -v alternatives")
+	if err != nil {
+		return "", fmt.Errorf("failed to check for update-alternatives: %w", err)
+	}
+	bin := strings.TrimSpace(result.Stdout)
+	if result.ExitCode != 0 || bin == "" {
+		return "", fmt.Errorf("update-alternatives is not available on this system")
+	}
+	return bin, nil
+}
+
+// queryGroup reads the current state of an alternatives group.
+// It uses --query (Debian) and falls back to --display (RedHat).
+func queryGroup(ctx context.Context, conn connector.Connector, bin, name string) (*groupState, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("%s --query %s 2>/dev/null", bin, shellutil.Quote(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alternatives: %w", err)
+	}
+	if result.ExitCode == 0 && strings.Contains(result.Stdout, "Name:") {
+		return parseQuery(result.Stdout), nil
+	}
+
+	result, err = conn.Execute(ctx, fmt.Sprintf("%s --display %s 2>/dev/null", bin, shellutil.Quote(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alternatives: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return &groupState{}, nil
+	}
+	return parseDisplay(result.Stdout), nil
+}
+
+// parseQuery parses `update-alternatives --query` output.
+func parseQuery(out string) *groupState {
+	g := &groupState{Exists: true, Paths: map[string]int{}}
+	var alternative string
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Link":
+			g.Link = value
+		case "Status":
+			g.Mode = value
+		case "Value":
+			g.Value = value
+		case "Alternative":
+			alternative = value
+			g.Paths[alternative] = 0
+		case "Priority":
+			if alternative != "" {
+				g.Paths[alternative], _ = strconv.Atoi(value)
+			}
+		}
+	}
+	return g
+}
+
+// parseDisplay parses `alternatives --display` output.
+func parseDisplay(out string) *groupState {
+	g := &groupState{Exists: true, Paths: map[string]int{}}
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.Contains(trimmed, "status is "):
+			mode := trimmed[strings.Index(trimmed, "status is ")+len("status is "):]
+			g.Mode = strings.TrimSuffix(strings.TrimSpace(mode), ".")
+		case strings.HasPrefix(trimmed, "link currently points to "):
+			g.Value = strings.TrimPrefix(trimmed, "link currently points to ")
+		case strings.HasPrefix(trimmed, "link ") && strings.Contains(trimmed, " is "):
+			g.Link = strings.TrimSpace(trimmed[strings.LastIndex(trimmed, " is ")+len(" is "):])
+		case strings.HasPrefix(trimmed, "/") && strings.Contains(trimmed, " - "):
+			// "/usr/bin/vim - priority 30", or on RedHat
+			// "/usr/lib/jvm/java-17/bin/java - family java-17 priority 1700"
+			path, rest, _ := strings.Cut(trimmed, " - ")
+			var priority int
+			if i := strings.LastIndex(rest, "priority "); i >= 0 {
+				priority, _ = strconv.Atoi(strings.TrimSpace(rest[i+len("priority "):]))
+			}
+			g.Paths[strings.TrimSpace(path)] = priority
+		}
+	}
+	return g
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
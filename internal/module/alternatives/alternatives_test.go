@@ -0,0 +1,126 @@
+package alternatives
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+const (
+	bin = "/usr/bin/update-alternatives"
+
+	// queryOut is the --query output of an editor group with two
+	// alternatives, nano selected manually.
+	queryOut = `Name: editor
+Link: /usr/bin/editor
+Slaves:
+ editor.1.gz /usr/share/man/man1/editor.1.gz
+Status: manual
+Best: /bin/nano
+Value: /bin/nano
+
+Alternative: /bin/nano
+Priority: 40
+Slaves:
+ editor.1.gz /usr/share/man/man1/nano.1.gz
+
+Alternative: /usr/bin/vim.basic
+Priority: 30
+Slaves:
+ editor.1.gz /usr/share/man/man1/vim.1.gz
+`
+)
+
+func TestParseQuery(t *testing.T) {
+	want := &groupState{
+		Exists: true,
+		Link:   "/usr/bin/editor",
+		Mode:   "manual",
+		Value:  "/bin/nano",
+		Paths:  map[string]int{"/bin/nano": 40, "/usr/bin/vim.basic": 30},
+	}
+	if got := parseQuery(queryOut); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseQuery() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseDisplay(t *testing.T) {
+	out := `java - status is auto.
+ link currently points to /usr/lib/jvm/java-17-openjdk/bin/java
+/usr/lib/jvm/java-11-openjdk/bin/java - family java-11-openjdk.x86_64 priority 1100
+ slave keytool: /usr/lib/jvm/java-11-openjdk/bin/keytool
+/usr/lib/jvm/java-17-openjdk/bin/java - family java-17-openjdk.x86_64 priority 1700
+ slave keytool: /usr/lib/jvm/java-17-openjdk/bin/keytool
+Current ` + "`best'" + ` version is /usr/lib/jvm/java-17-openjdk/bin/java.
+`
+	want := &groupState{
+		Exists: true,
+		Mode:   "auto",
+		Value:  "/usr/lib/jvm/java-17-openjdk/bin/java",
+		Paths: map[string]int{
+			"/usr/lib/jvm/java-11-openjdk/bin/java": 1100,
+			"/usr/lib/jvm/java-17-openjdk/bin/java": 1700,
+		},
+	}
+	if got := parseDisplay(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDisplay() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRunPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		priority int
+		install  string // expected --install command, empty if none
+		msg      string
+	}{
+		{
+			name:     "unchanged",
+			path:     "/bin/nano",
+			priority: 40,
+			msg:      "alternative already in desired state",
+		},
+		{
+			name:     "priority changed",
+			path:     "/bin/nano",
+			priority: 60,
+			install:  bin + " --install '/usr/bin/editor' 'editor' '/bin/nano' 60",
+			msg:      "changed priority of /bin/nano for editor from 40 to 60",
+		},
+		{
+			name:     "new alternative",
+			path:     "/usr/bin/nvim",
+			priority: 50,
+			install:  bin + " --install '/usr/bin/editor' 'editor' '/usr/bin/nvim' 50",
+			msg:      "installed /usr/bin/nvim as alternative for editor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			conn.On("command -v update-alternatives || command -v alternatives").Return(bin + "\n")
+			conn.On(bin + " --query 'editor' 2>/dev/null").Return(queryOut)
+			if tt.install != "" {
+				conn.On(tt.install).Once()
+			}
+
+			result, err := (&Module{}).Run(context.Background(), conn, map[string]any{
+				"name":     "editor",
+				"path":     tt.path,
+				"priority": tt.priority,
+				"state":    "present",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed != (tt.install != "") || result.Message != tt.msg {
+				t.Errorf("result = %v %q, want %q", result.Changed, result.Message, tt.msg)
+			}
+			conn.AssertExpectations(t)
+		})
+	}
+}
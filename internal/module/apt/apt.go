@@ -3,11 +3,17 @@ package apt
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/eugenetaranov/bolt/internal/artifact"
 	"github.com/eugenetaranov/bolt/internal/connector"
 	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+	"github.com/eugenetaranov/bolt/pkg/facts"
 )
 
 func init() {
@@ -32,6 +38,25 @@ func (m *Module) Name() string {
 	return "apt"
 }
 
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string/list", Description: "Package name(s)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent", "latest", "purged"}, Description: "Desired state"},
+		{Name: "update_cache", Type: "bool", Default: false, Description: "Run `apt-get update` first"},
+		{Name: "cache_valid_time", Type: "int", Default: 0, Description: "Skip update if cache newer than N seconds"},
+		{Name: "upgrade", Type: "string", Default: "none", Choices: []string{"none", "yes", "safe", "full", "dist"}, Description: "Upgrade installed packages"},
+		{Name: "install_recommends", Type: "bool", Default: true, Description: "Install recommended packages"},
+		{Name: "autoremove", Type: "bool", Default: false, Description: "Remove unused dependencies"},
+		{Name: "deb", Type: "string", Description: "Path or URL to .deb file"},
+		{Name: "deb_checksum", Type: "string", Description: "SHA256 checksum of the .deb (`sha256:<hex>`), verified after download"},
+		{Name: "deb_download", Type: "string", Default: "target", Choices: []string{"target", "controller"}, Description: "Where a `deb` URL is downloaded; `controller` caches it on the machine running bolt and uploads it"},
+		{Name: "mirror", Type: "string", Description: "Base URL of a package mirror the sources of the official archives are pointed at"},
+		{Name: "mirror_hosts", Type: "list", Description: "Hosts of the sources replaced by `mirror` (default: the Debian and Ubuntu archives; `*` wildcards)"},
+		{Name: "lock_timeout", Type: "int", Default: 600, Description: "Seconds to wait for the dpkg lock held by another process; 0 fails at once"},
+	}
+}
+
 // Run executes the apt module.
 //
 // Parameters:
@@ -43,27 +68,41 @@ func (m *Module) Name() string {
 //   - install_recommends (bool): Install recommended packages (default: true)
 //   - autoremove (bool): Remove unused dependency packages (default: false)
 //   - deb (string): Path or URL to .deb file to install
+//   - deb_checksum (string): SHA256 checksum of the .deb ("sha256:<hex>"), verified after download
+//   - deb_download (string): Where a deb URL is downloaded - target, controller (default: target)
+//   - mirror (string): Base URL of a package mirror; sources of mirror_hosts are pointed at it
+//     before anything else, keeping their paths
+//   - mirror_hosts ([]string): Hosts replaced by mirror, with * wildcards (default: *.debian.org,
+//     archive.ubuntu.com, *.archive.ubuntu.com, security.ubuntu.com, ports.ubuntu.com)
+//   - lock_timeout (int): Seconds to wait for the dpkg lock held by another process, 0 to fail at once (default: 600)
 func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
 	// Check if apt is available
 	if err := checkApt(ctx, conn); err != nil {
 		return nil, err
 	}
 
-	stateStr := getString(params, "state", "present")
+	stateStr := param.String(params, "state", "present")
 	state := State(stateStr)
-	updateCache := getBool(params, "update_cache", false)
-	upgrade := getString(params, "upgrade", "none")
-	cacheValidTime := getInt(params, "cache_valid_time", 0)
-	installRecommends := getBool(params, "install_recommends", true)
-	autoremove := getBool(params, "autoremove", false)
-	debFile := getString(params, "deb", "")
+	updateCache := param.Bool(params, "update_cache", false)
+	upgrade := param.String(params, "upgrade", "none")
+	cacheValidTime := param.Int(params, "cache_valid_time", 0)
+	installRecommends := param.Bool(params, "install_recommends", true)
+	autoremove := param.Bool(params, "autoremove", false)
+	debFile := param.String(params, "deb", "")
+	debChecksum := param.String(params, "deb_checksum", "")
+	debDownload := param.String(params, "deb_download", "target")
+	lockTimeout := param.Int(params, "lock_timeout", int(module.DpkgMutex.Wait.Seconds()))
+	mirror := param.String(params, "mirror", "")
+	mirrorHosts := param.StringList(params, "mirror_hosts")
+	check := module.IsCheckMode(params)
+	offline := module.IsOffline(params)
 
 	// Validate state
 	switch state {
 	case StatePresent, StateAbsent, StateLatest, StatePurged:
 		// Valid
 	default:
-		return nil, fmt.Errorf("invalid state '%s': must be present, absent, latest, or purged", state)
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present, absent, latest, or purged", state)
 	}
 
 	// Validate upgrade mode
@@ -74,12 +113,84 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		return nil, fmt.Errorf("invalid upgrade mode '%s': must be none, yes, safe, full, or dist", upgrade)
 	}
 
+	if debDownload != "target" && debDownload != "controller" {
+		return nil, module.ParamErrorf("deb_download", "invalid deb_download '%s': must be target or controller", debDownload)
+	}
+	if _, err := artifact.ParseChecksum(debChecksum); err != nil {
+		return nil, module.ParamErrorf("deb_checksum", "%v", err)
+	}
+	if lockTimeout < 0 {
+		return nil, module.ParamErrorf("lock_timeout", "invalid lock_timeout %d: must not be negative", lockTimeout)
+	}
+	if mirror != "" {
+		var err error
+		if mirror, err = module.ParseMirror(mirror); err != nil {
+			return nil, module.ParamErrorf("mirror", "%v", err)
+		}
+	}
+	if len(mirrorHosts) == 0 {
+		mirrorHosts = defaultMirrorHosts
+	}
+	lock := module.DpkgMutex
+	lock.Wait = time.Duration(lockTimeout) * time.Second
+
+	// Fail before changing anything if the .deb must be downloaded
+	if offline && isURL(debFile) {
+		if err := checkOfflineDeb(debFile, debChecksum, debDownload == "controller"); err != nil {
+			return nil, err
+		}
+	}
+
 	var changed bool
 	var messages []string
 
-	// Update cache if requested
-	if updateCache {
-		updated, err := runAptUpdate(ctx, conn, cacheValidTime)
+	// Point the sources at the mirror before anything reads them
+	var mirrored []string
+	if mirror != "" {
+		var err error
+		if mirrored, err = applyMirror(ctx, conn, mirror, mirrorHosts, check); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check mode only covers the mirror and the named packages: cache
+	// updates, upgrades, .deb installs, and autoremove cannot be predicted
+	// without running them
+	if check {
+		result := module.Unchanged("update_cache, upgrade, deb, and autoremove are not checked in check mode")
+		if names := param.StringList(params, "name"); len(names) > 0 {
+			pkgStates, err := getPackageStates(ctx, conn, names)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get package states: %w", err)
+			}
+			toInstall, toRemove, toPurge, toUpgrade := planPackages(names, pkgStates, state)
+			result = planResult(toInstall, toRemove, toPurge, toUpgrade)
+		}
+		if len(mirrored) > 0 {
+			msg := fmt.Sprintf("would point %s at %s", strings.Join(mirrored, ", "), mirror)
+			if result.Changed {
+				msg += "; " + result.Message
+			}
+			result = module.Changed(msg)
+		}
+		return result, nil
+	}
+
+	if len(mirrored) > 0 {
+		messages = append(messages, fmt.Sprintf("pointed %s at %s", strings.Join(mirrored, ", "), mirror))
+		changed = true
+	}
+
+	// Update cache if requested, or if the sources changed, since the
+	// lists of the old ones are useless. Offline, the lists on the target
+	// are used as they are, but a mirror is inside the network.
+	if updateCache && offline && len(mirrored) == 0 {
+		messages = append(messages, "cache update skipped (offline)")
+	} else if updateCache || len(mirrored) > 0 {
+		if len(mirrored) > 0 {
+			cacheValidTime = 0
+		}
+		updated, err := runAptUpdate(ctx, conn, lock, cacheValidTime)
 		if err != nil {
 			return nil, fmt.Errorf("failed to update cache: %w", err)
 		}
@@ -91,7 +202,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 
 	// Run upgrade if requested
 	if upgrade != "none" {
-		upgraded, err := runAptUpgrade(ctx, conn, upgrade)
+		upgraded, err := runAptUpgrade(ctx, conn, lock, upgrade)
 		if err != nil {
 			return nil, fmt.Errorf("failed to upgrade: %w", err)
 		}
@@ -103,7 +214,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 
 	// Install .deb file if specified
 	if debFile != "" {
-		installed, err := installDebFile(ctx, conn, debFile)
+		installed, err := installDebFile(ctx, conn, lock, debFile, debChecksum, debDownload == "controller")
 		if err != nil {
 			return nil, err
 		}
@@ -114,14 +225,14 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	}
 
 	// Get package names
-	names := getPackageNames(params)
+	names := param.StringList(params, "name")
 	if len(names) == 0 {
-		if !updateCache && upgrade == "none" && debFile == "" {
-			return nil, fmt.Errorf("'name' parameter is required when not using update_cache, upgrade, or deb")
+		if !updateCache && upgrade == "none" && debFile == "" && mirror == "" {
+			return nil, module.ParamErrorf("name", "'name' parameter is required when not using update_cache, upgrade, deb, or mirror")
 		}
 		// Handle autoremove
 		if autoremove {
-			removed, err := runAutoremove(ctx, conn)
+			removed, err := runAutoremove(ctx, conn, lock)
 			if err != nil {
 				return nil, err
 			}
@@ -131,9 +242,12 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 			}
 		}
 		if changed {
-			return module.Changed(strings.Join(messages, ", ")), nil
+			return withRestarts(ctx, conn, module.Changed(strings.Join(messages, ", "))), nil
 		}
-		return module.Unchanged("no changes needed"), nil
+		if len(messages) > 0 {
+			return withRestarts(ctx, conn, module.Unchanged(strings.Join(messages, ", "))), nil
+		}
+		return withRestarts(ctx, conn, module.Unchanged("no changes needed")), nil
 	}
 
 	// Get package states
@@ -143,36 +257,11 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	}
 
 	// Determine actions needed
-	var toInstall, toRemove, toUpgrade, toPurge []string
-
-	for _, name := range names {
-		pkgState := pkgStates[name]
-
-		switch state {
-		case StatePresent:
-			if !pkgState.Installed {
-				toInstall = append(toInstall, name)
-			}
-		case StateAbsent:
-			if pkgState.Installed {
-				toRemove = append(toRemove, name)
-			}
-		case StatePurged:
-			if pkgState.Installed || pkgState.ConfigFiles {
-				toPurge = append(toPurge, name)
-			}
-		case StateLatest:
-			if !pkgState.Installed {
-				toInstall = append(toInstall, name)
-			} else if pkgState.Upgradable {
-				toUpgrade = append(toUpgrade, name)
-			}
-		}
-	}
+	toInstall, toRemove, toPurge, toUpgrade := planPackages(names, pkgStates, state)
 
 	// Install packages
 	if len(toInstall) > 0 {
-		if err := installPackages(ctx, conn, toInstall, installRecommends); err != nil {
+		if err := installPackages(ctx, conn, lock, toInstall, installRecommends); err != nil {
 			return nil, err
 		}
 		messages = append(messages, fmt.Sprintf("installed: %s", strings.Join(toInstall, ", ")))
@@ -181,7 +270,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 
 	// Remove packages
 	if len(toRemove) > 0 {
-		if err := removePackages(ctx, conn, toRemove, false); err != nil {
+		if err := removePackages(ctx, conn, lock, toRemove, false); err != nil {
 			return nil, err
 		}
 		messages = append(messages, fmt.Sprintf("removed: %s", strings.Join(toRemove, ", ")))
@@ -190,7 +279,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 
 	// Purge packages
 	if len(toPurge) > 0 {
-		if err := removePackages(ctx, conn, toPurge, true); err != nil {
+		if err := removePackages(ctx, conn, lock, toPurge, true); err != nil {
 			return nil, err
 		}
 		messages = append(messages, fmt.Sprintf("purged: %s", strings.Join(toPurge, ", ")))
@@ -199,7 +288,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 
 	// Upgrade packages
 	if len(toUpgrade) > 0 {
-		if err := installPackages(ctx, conn, toUpgrade, installRecommends); err != nil {
+		if err := installPackages(ctx, conn, lock, toUpgrade, installRecommends); err != nil {
 			return nil, err
 		}
 		messages = append(messages, fmt.Sprintf("upgraded: %s", strings.Join(toUpgrade, ", ")))
@@ -208,7 +297,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 
 	// Handle autoremove
 	if autoremove {
-		removed, err := runAutoremove(ctx, conn)
+		removed, err := runAutoremove(ctx, conn, lock)
 		if err != nil {
 			return nil, err
 		}
@@ -219,10 +308,82 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	}
 
 	if !changed {
-		return module.Unchanged("packages already in desired state"), nil
+		return withRestarts(ctx, conn, module.Unchanged("packages already in desired state")), nil
+	}
+
+	return withRestarts(ctx, conn, module.Changed(strings.Join(messages, "; "))), nil
+}
+
+// withRestarts adds whether the host needs a reboot or service restarts to
+// the data and facts of result. Unchanged results get them too, since an
+// earlier run may have updated packages without rebooting. A failed check
+// leaves them out rather than failing a task that may have installed
+// packages already.
+func withRestarts(ctx context.Context, conn connector.Connector, result *module.Result) *module.Result {
+	status, err := facts.Restarts(ctx, conn)
+	if err != nil {
+		return result
+	}
+	if result.Data == nil {
+		result.Data = make(map[string]any)
+	}
+	for k, v := range status.Facts() {
+		result.Data[k] = v
+	}
+	return result.WithFacts(status.Facts())
+}
+
+// planPackages determines which packages must be installed, removed,
+// purged, or upgraded to reach the desired state.
+func planPackages(names []string, pkgStates map[string]*packageState, state State) (toInstall, toRemove, toPurge, toUpgrade []string) {
+	for _, name := range names {
+		pkgState := pkgStates[name]
+
+		switch state {
+		case StatePresent:
+			if !pkgState.Installed {
+				toInstall = append(toInstall, name)
+			}
+		case StateAbsent:
+			if pkgState.Installed {
+				toRemove = append(toRemove, name)
+			}
+		case StatePurged:
+			if pkgState.Installed || pkgState.ConfigFiles {
+				toPurge = append(toPurge, name)
+			}
+		case StateLatest:
+			if !pkgState.Installed {
+				toInstall = append(toInstall, name)
+			} else if pkgState.Upgradable {
+				toUpgrade = append(toUpgrade, name)
+			}
+		}
 	}
+	return toInstall, toRemove, toPurge, toUpgrade
+}
 
-	return module.Changed(strings.Join(messages, "; ")), nil
+// planResult describes planned package changes for check mode.
+func planResult(toInstall, toRemove, toPurge, toUpgrade []string) *module.Result {
+	var messages []string
+	for _, action := range []struct {
+		verb  string
+		names []string
+	}{
+		{"would install", toInstall},
+		{"would remove", toRemove},
+		{"would purge", toPurge},
+		{"would upgrade", toUpgrade},
+	} {
+		if len(action.names) > 0 {
+			messages = append(messages, fmt.Sprintf("%s: %s", action.verb, strings.Join(action.names, ", ")))
+		}
+	}
+
+	if len(messages) == 0 {
+		return module.Unchanged("packages already in desired state")
+	}
+	return module.Changed(strings.Join(messages, "; "))
 }
 
 // packageState holds the state of a package.
@@ -245,7 +406,7 @@ func checkApt(ctx context.Context, conn connector.Connector) error {
 }
 
 // runAptUpdate runs apt-get update.
-func runAptUpdate(ctx context.Context, conn connector.Connector, cacheValidTime int) (bool, error) {
+func runAptUpdate(ctx context.Context, conn connector.Connector, lock module.Mutex, cacheValidTime int) (bool, error) {
 	// Check cache age if cacheValidTime is set
 	if cacheValidTime > 0 {
 		cmd := fmt.Sprintf(`find /var/lib/apt/lists -maxdepth 0 -mmin +%d 2>/dev/null | grep -q . && echo "stale" || echo "fresh"`,
@@ -256,36 +417,36 @@ func runAptUpdate(ctx context.Context, conn connector.Connector, cacheValidTime
 		}
 	}
 
-	result, err := conn.Execute(ctx, "DEBIAN_FRONTEND=noninteractive apt-get update -qq")
+	result, err := lock.Execute(ctx, conn, aptGet(lock)+" update -qq")
 	if err != nil {
 		return false, err
 	}
 	if result.ExitCode != 0 {
-		return false, fmt.Errorf("apt-get update failed: %s", result.Stderr)
+		return false, module.CommandFailedf(result, "apt-get update failed")
 	}
 	return true, nil
 }
 
 // runAptUpgrade runs apt-get upgrade with the specified mode.
-func runAptUpgrade(ctx context.Context, conn connector.Connector, mode string) (bool, error) {
+func runAptUpgrade(ctx context.Context, conn connector.Connector, lock module.Mutex, mode string) (bool, error) {
 	var cmd string
 	switch mode {
 	case "yes", "safe":
-		cmd = "DEBIAN_FRONTEND=noninteractive apt-get upgrade -y -qq"
+		cmd = aptGet(lock) + " upgrade -y -qq"
 	case "full":
-		cmd = "DEBIAN_FRONTEND=noninteractive apt-get full-upgrade -y -qq"
+		cmd = aptGet(lock) + " full-upgrade -y -qq"
 	case "dist":
-		cmd = "DEBIAN_FRONTEND=noninteractive apt-get dist-upgrade -y -qq"
+		cmd = aptGet(lock) + " dist-upgrade -y -qq"
 	default:
 		return false, nil
 	}
 
-	result, err := conn.Execute(ctx, cmd)
+	result, err := lock.Execute(ctx, conn, cmd)
 	if err != nil {
 		return false, err
 	}
 	if result.ExitCode != 0 {
-		return false, fmt.Errorf("apt-get upgrade failed: %s", result.Stderr)
+		return false, module.CommandFailedf(result, "apt-get upgrade failed")
 	}
 
 	// Check if anything was upgraded
@@ -302,7 +463,7 @@ func getPackageStates(ctx context.Context, conn connector.Connector, names []str
 	// Query dpkg for installed packages
 	// Status can be: installed, config-files, not-installed
 	cmd := fmt.Sprintf("dpkg-query -W -f='${Package}|${Status}\\n' %s 2>/dev/null || true",
-		strings.Join(names, " "))
+		shellutil.Join(names...))
 	result, err := conn.Execute(ctx, cmd)
 	if err != nil {
 		return nil, err
@@ -349,170 +510,151 @@ func getPackageStates(ctx context.Context, conn connector.Connector, names []str
 }
 
 // installPackages installs the specified packages.
-func installPackages(ctx context.Context, conn connector.Connector, names []string, installRecommends bool) error {
+func installPackages(ctx context.Context, conn connector.Connector, lock module.Mutex, names []string, installRecommends bool) error {
 	recommends := "--no-install-recommends"
 	if installRecommends {
 		recommends = "--install-recommends"
 	}
 
-	cmd := fmt.Sprintf("DEBIAN_FRONTEND=noninteractive apt-get install -y -qq %s %s",
-		recommends, strings.Join(names, " "))
+	cmd := fmt.Sprintf("%s install -y -qq %s %s",
+		aptGet(lock), recommends, shellutil.Join(names...))
 
-	result, err := conn.Execute(ctx, cmd)
+	result, err := lock.Execute(ctx, conn, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to install packages: %w", err)
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("apt-get install failed: %s", result.Stderr)
+		return module.CommandFailedf(result, "apt-get install failed")
 	}
 
 	return nil
 }
 
 // removePackages removes the specified packages.
-func removePackages(ctx context.Context, conn connector.Connector, names []string, purge bool) error {
+func removePackages(ctx context.Context, conn connector.Connector, lock module.Mutex, names []string, purge bool) error {
 	action := "remove"
 	if purge {
 		action = "purge"
 	}
 
-	cmd := fmt.Sprintf("DEBIAN_FRONTEND=noninteractive apt-get %s -y -qq %s",
-		action, strings.Join(names, " "))
+	cmd := fmt.Sprintf("%s %s -y -qq %s",
+		aptGet(lock), action, shellutil.Join(names...))
 
-	result, err := conn.Execute(ctx, cmd)
+	result, err := lock.Execute(ctx, conn, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to remove packages: %w", err)
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("apt-get %s failed: %s", action, result.Stderr)
+		return module.CommandFailedf(result, "apt-get %s failed", action)
 	}
 
 	return nil
 }
 
-// installDebFile installs a .deb file.
-func installDebFile(ctx context.Context, conn connector.Connector, path string) (bool, error) {
-	// Download if it's a URL
+// installDebFile installs a .deb file. A URL is downloaded on the target,
+// or through the artifact cache of the controller if viaController is set.
+func installDebFile(ctx context.Context, conn connector.Connector, lock module.Mutex, path, checksum string, viaController bool) (bool, error) {
 	localPath := path
-	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+	if isURL(path) {
 		localPath = "/tmp/bolt-pkg.deb"
-		cmd := fmt.Sprintf("curl -fsSL -o %s %s", shellQuote(localPath), shellQuote(path))
-		result, err := conn.Execute(ctx, cmd)
-		if err != nil {
-			return false, fmt.Errorf("failed to download deb file: %w", err)
-		}
-		if result.ExitCode != 0 {
-			return false, fmt.Errorf("failed to download deb file: %s", result.Stderr)
+		if err := downloadDeb(ctx, conn, path, checksum, localPath, viaController); err != nil {
+			return false, err
 		}
 	}
 
 	// Install the .deb file
-	cmd := fmt.Sprintf("DEBIAN_FRONTEND=noninteractive dpkg -i %s || apt-get install -f -y -qq",
-		shellQuote(localPath))
-	result, err := conn.Execute(ctx, cmd)
+	cmd := fmt.Sprintf("DEBIAN_FRONTEND=noninteractive dpkg -i %s || %s install -f -y -qq",
+		shellutil.Quote(localPath), aptGet(lock))
+	result, err := lock.Execute(ctx, conn, cmd)
 	if err != nil {
 		return false, fmt.Errorf("failed to install deb file: %w", err)
 	}
 	if result.ExitCode != 0 {
-		return false, fmt.Errorf("dpkg install failed: %s", result.Stderr)
+		return false, module.CommandFailedf(result, "dpkg install failed")
 	}
 
 	return true, nil
 }
 
-// runAutoremove removes unused dependency packages.
-func runAutoremove(ctx context.Context, conn connector.Connector) (bool, error) {
-	result, err := conn.Execute(ctx, "DEBIAN_FRONTEND=noninteractive apt-get autoremove -y -qq")
-	if err != nil {
-		return false, fmt.Errorf("failed to autoremove: %w", err)
+// checkOfflineDeb fails unless the .deb at url can be installed without
+// the network: only from the artifact cache of the controller.
+func checkOfflineDeb(url, checksum string, viaController bool) error {
+	op := "downloading " + url
+	if !viaController {
+		return module.OfflineErrorf(op, "set deb_download: controller to install it from the artifact cache, or copy the file to the target and pass its path in 'deb'")
 	}
-	if result.ExitCode != 0 {
-		return false, fmt.Errorf("apt-get autoremove failed: %s", result.Stderr)
+	if _, _, err := artifact.Lookup(url, checksum); err != nil {
+		if errors.Is(err, artifact.ErrNotCached) {
+			return module.OfflineErrorf(op, "it is not in the artifact cache; run the task once with network access and a deb_checksum, or copy the file to the target and pass its path in 'deb'")
+		}
+		return err
 	}
-
-	return strings.Contains(result.Stdout, "Removing") || strings.Contains(result.Stderr, "Removing"), nil
+	return nil
 }
 
-// getPackageNames extracts package names from params.
-func getPackageNames(params map[string]any) []string {
-	v, ok := params["name"]
-	if !ok {
+// downloadDeb downloads the .deb at url to dst on the target and verifies
+// its checksum, if given.
+func downloadDeb(ctx context.Context, conn connector.Connector, url, checksum, dst string, viaController bool) error {
+	if viaController {
+		if _, err := artifact.Upload(ctx, conn, url, checksum, dst, 0644); err != nil {
+			return fmt.Errorf("failed to download deb file: %w", err)
+		}
 		return nil
 	}
 
-	// Single string
-	if s, ok := v.(string); ok {
-		if s == "" {
-			return nil
-		}
-		return []string{s}
+	cmd := fmt.Sprintf("curl -fsSL -o %s %s", shellutil.Quote(dst), shellutil.Quote(url))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to download deb file: %w", err)
 	}
-
-	// Slice of any
-	if slice, ok := v.([]any); ok {
-		var names []string
-		for _, item := range slice {
-			if s, ok := item.(string); ok && s != "" {
-				names = append(names, s)
-			}
-		}
-		return names
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "failed to download deb file")
 	}
 
-	// String slice
-	if slice, ok := v.([]string); ok {
-		return slice
+	want, _ := artifact.ParseChecksum(checksum)
+	if want == "" {
+		return nil
+	}
+	got, err := module.RemoteChecksum(ctx, conn, dst)
+	if err != nil {
+		return fmt.Errorf("failed to verify deb file: %w", err)
+	}
+	if got != "" && got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, want, got)
 	}
-
 	return nil
 }
 
-// shellQuote quotes a string for safe use in shell commands.
-func shellQuote(s string) string {
-	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
-}
-
-// Helper functions for parameter extraction
-
-func getString(params map[string]any, key, defaultValue string) string {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
+// runAutoremove removes unused dependency packages.
+func runAutoremove(ctx context.Context, conn connector.Connector, lock module.Mutex) (bool, error) {
+	result, err := lock.Execute(ctx, conn, aptGet(lock)+" autoremove -y -qq")
+	if err != nil {
+		return false, fmt.Errorf("failed to autoremove: %w", err)
 	}
-	s, ok := v.(string)
-	if !ok {
-		return defaultValue
+	if result.ExitCode != 0 {
+		return false, module.CommandFailedf(result, "apt-get autoremove failed")
 	}
-	return s
+
+	return strings.Contains(result.Stdout, "Removing") || strings.Contains(result.Stderr, "Removing"), nil
 }
 
-func getBool(params map[string]any, key string, defaultValue bool) bool {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	b, ok := v.(bool)
-	if !ok {
-		return defaultValue
-	}
-	return b
+// aptGet returns the apt-get command line, waiting as long as lock for
+// the dpkg lock (apt 1.9.11 and later; older versions ignore the option).
+func aptGet(lock module.Mutex) string {
+	return fmt.Sprintf("DEBIAN_FRONTEND=noninteractive apt-get -o DPkg::Lock::Timeout=%d", int(lock.Wait.Seconds()))
 }
 
-func getInt(params map[string]any, key string, defaultValue int) int {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	switch n := v.(type) {
-	case int:
-		return n
-	case int64:
-		return int(n)
-	case float64:
-		return int(n)
-	}
-	return defaultValue
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// SupportsCheckMode reports that apt can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
 }
 
 // Ensure Module implements the module.Module interface.
-var _ module.Module = (*Module)(nil)
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
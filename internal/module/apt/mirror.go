@@ -0,0 +1,107 @@
+package apt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// defaultMirrorHosts are the hosts of the official Debian and Ubuntu
+// archives, which a mirror replaces unless mirror_hosts is set.
+var defaultMirrorHosts = []string{
+	"*.debian.org",
+	"archive.ubuntu.com",
+	"*.archive.ubuntu.com",
+	"security.ubuntu.com",
+	"ports.ubuntu.com",
+}
+
+// sourceFiles lists the APT source files of the target, in the one-line
+// (.list) and deb822 (.sources) formats.
+const sourceFiles = "ls -1 /etc/apt/sources.list /etc/apt/sources.list.d/*.list /etc/apt/sources.list.d/*.sources 2>/dev/null"
+
+// applyMirror points the sources of the target that use one of hosts at
+// mirror and returns the files it rewrote. In check mode it only returns
+// the files it would rewrite.
+func applyMirror(ctx context.Context, conn connector.Connector, mirror string, hosts []string, check bool) ([]string, error) {
+	result, err := conn.Execute(ctx, sourceFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list APT sources: %w", err)
+	}
+
+	var rewritten []string
+	for _, file := range strings.Fields(result.Stdout) {
+		result, err := conn.Execute(ctx, "cat "+shellutil.Quote(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if result.ExitCode != 0 {
+			return nil, module.CommandFailedf(result, "failed to read %s", file)
+		}
+
+		content, n := rewriteSources(result.Stdout, mirror, hosts)
+		if n == 0 {
+			continue
+		}
+		if !check {
+			if err := module.UploadVerified(ctx, conn, []byte(content), file, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", file, err)
+			}
+		}
+		rewritten = append(rewritten, file)
+	}
+	return rewritten, nil
+}
+
+// rewriteSources points the URIs of APT sources that use one of hosts at
+// mirror and returns the new content and the number of URIs changed.
+// It understands the one-line format ("deb [options] URI suite
+// components") and the URIs field of the deb822 format.
+func rewriteSources(content, mirror string, hosts []string) (string, int) {
+	lines := strings.Split(content, "\n")
+	count := 0
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case fields[0] == "deb" || fields[0] == "deb-src":
+			// Skip the options, which may contain spaces
+			j := 1
+			if j < len(fields) && strings.HasPrefix(fields[j], "[") {
+				for j < len(fields) && !strings.HasSuffix(fields[j], "]") {
+					j++
+				}
+				j++
+			}
+			if j >= len(fields) {
+				continue
+			}
+			if uri, ok := module.MirrorURL(fields[j], mirror, hosts); ok {
+				fields[j] = uri
+				lines[i] = strings.Join(fields, " ")
+				count++
+			}
+
+		case strings.EqualFold(fields[0], "URIs:"):
+			changed := false
+			for j, field := range fields[1:] {
+				if uri, ok := module.MirrorURL(field, mirror, hosts); ok {
+					fields[j+1] = uri
+					changed = true
+					count++
+				}
+			}
+			if changed {
+				lines[i] = strings.Join(fields, " ")
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), count
+}
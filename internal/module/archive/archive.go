@@ -0,0 +1,302 @@
+// Package archive provides a module for creating archives on target
+// systems.
+package archive
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// compressors maps the tar formats to the command compressing a tar file
+// to stdout. gzip -n leaves out the timestamp, so archives of unchanged
+// files are identical.
+var compressors = map[string]string{
+	"gz":  "gzip -n -c",
+	"bz2": "bzip2 -c",
+	"xz":  "xz -c",
+	"zst": "zstd -q -c",
+	"tar": "",
+}
+
+// Module creates tar or zip archives from paths on the target.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "archive"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "path", Type: "string/list", Required: true, Description: "Files and directories to archive"},
+		{Name: "dest", Type: "string", Required: true, Description: "Archive to create"},
+		{Name: "format", Type: "string", Default: "gz", Choices: []string{"gz", "bz2", "xz", "zst", "tar", "zip"}, Description: "Archive format"},
+		{Name: "exclude", Type: "list", Description: "Patterns of files to leave out"},
+		{Name: "remove", Type: "bool", Default: false, Description: "Remove the archived paths afterwards"},
+		{Name: "mode", Type: "string/int", Default: "0644", Description: "Archive permissions, octal or symbolic"},
+		{Name: "owner", Type: "string", Description: "Archive owner"},
+		{Name: "group", Type: "string", Description: "Archive group"},
+	}
+}
+
+// Run executes the archive module.
+//
+// Parameters:
+//   - path (string|[]string, required): Files and directories to archive; each is
+//     stored under its base name
+//   - dest (string, required): Archive to create
+//   - format (string): gz, bz2, xz, zst, tar, zip (default: gz)
+//   - exclude ([]string): Patterns of files to leave out (tar or zip wildcards)
+//   - remove (bool): Remove the archived paths after archiving (default: false)
+//   - mode (string|int): Archive permissions, octal or symbolic (default: 0644)
+//   - owner (string): Archive owner
+//   - group (string): Archive group
+//
+// The archive is built next to dest and only replaces it if its content
+// differs, so a task archiving unchanged files reports no change. In check
+// mode the archive is still built to compare it, then discarded.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	paths := param.StringList(params, "path")
+	dest := param.String(params, "dest", "")
+	format := param.String(params, "format", "gz")
+	exclude := param.StringList(params, "exclude")
+	remove := param.Bool(params, "remove", false)
+	mode, err := param.Mode(params, "mode", "0644")
+	if err != nil {
+		return nil, err
+	}
+	owner := param.String(params, "owner", "")
+	group := param.String(params, "group", "")
+	check := module.IsCheckMode(params)
+
+	if len(paths) == 0 {
+		return nil, module.ParamErrorf("path", "'path' parameter is required")
+	}
+	if dest == "" {
+		return nil, module.ParamErrorf("dest", "'dest' parameter is required")
+	}
+	if _, ok := compressors[format]; !ok && format != "zip" {
+		return nil, module.ParamErrorf("format", "invalid format '%s': must be gz, bz2, xz, zst, tar, or zip", format)
+	}
+	if !path.IsAbs(dest) {
+		return nil, module.ParamErrorf("dest", "dest '%s' must be absolute", dest)
+	}
+	for _, p := range paths {
+		if !path.IsAbs(p) {
+			return nil, module.ParamErrorf("path", "path '%s' must be absolute", p)
+		}
+	}
+
+	missing, err := missingPaths(ctx, conn, paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) == len(paths) {
+		// The paths of an earlier run with remove are gone
+		if exists, err := fileExists(ctx, conn, dest); err != nil || exists {
+			return module.Unchanged(fmt.Sprintf("%s exists and there is nothing to archive", dest)), err
+		}
+		return nil, fmt.Errorf("nothing to archive: %s not found", strings.Join(missing, ", "))
+	}
+
+	tmp := fmt.Sprintf("%s.bolt-%d", dest, time.Now().UnixNano())
+	if err := module.RunCommand(ctx, conn, createCommand(paths, tmp, format, exclude)); err != nil {
+		_, _ = conn.Execute(ctx, fmt.Sprintf("rm -f %s %s", shellutil.Quote(tmp), shellutil.Quote(tmp+".tar")))
+		return nil, err
+	}
+
+	same, err := sameContent(ctx, conn, tmp, dest)
+	if err != nil {
+		_, _ = conn.Execute(ctx, "rm -f "+shellutil.Quote(tmp))
+		return nil, err
+	}
+
+	data := map[string]any{
+		"dest":   dest,
+		"format": format,
+	}
+	if len(missing) > 0 {
+		data["missing"] = missing
+	}
+
+	if same || check {
+		_, _ = conn.Execute(ctx, "rm -f "+shellutil.Quote(tmp))
+	} else if err := module.RunCommand(ctx, conn, fmt.Sprintf("mv -f %s %s", shellutil.Quote(tmp), shellutil.Quote(dest))); err != nil {
+		return nil, err
+	}
+
+	var attrChanged bool
+	if !check || same {
+		if attrChanged, err = ensureAttributes(ctx, conn, dest, mode, owner, group, check); err != nil {
+			return nil, err
+		}
+	}
+
+	removed := false
+	if remove {
+		if !check {
+			if err := module.RunCommand(ctx, conn, "rm -rf "+shellutil.Join(paths...)); err != nil {
+				return nil, err
+			}
+		}
+		removed = len(missing) < len(paths)
+	}
+
+	switch {
+	case !same && check:
+		return module.ChangedWithData(fmt.Sprintf("%s would be written", dest), data), nil
+	case !same:
+		return module.ChangedWithData(fmt.Sprintf("created %s", dest), data), nil
+	case removed:
+		return module.ChangedWithData(fmt.Sprintf("%s up to date, archived paths removed", dest), data), nil
+	case attrChanged:
+		return module.ChangedWithData(fmt.Sprintf("%s attributes updated", dest), data), nil
+	}
+	result := module.Unchanged(fmt.Sprintf("%s up to date", dest))
+	result.Data = data
+	return result, nil
+}
+
+// createCommand returns the command archiving the existing paths into
+// file. Each path is stored under its base name, as with tar -C.
+func createCommand(paths []string, file, format string, exclude []string) string {
+	var b strings.Builder
+	if format != "zip" {
+		b.WriteString("set --\n")
+	}
+	fmt.Fprintf(&b, "for p in %s; do\n\t[ -e \"$p\" ] || [ -L \"$p\" ] || continue\n", shellutil.Join(paths...))
+
+	if format == "zip" {
+		fmt.Fprintf(&b, "\t(cd \"$(dirname \"$p\")\" && zip -q -r -X -y %s \"$(basename \"$p\")\"", shellutil.Quote(file))
+		for _, pattern := range exclude {
+			b.WriteString(" -x " + shellutil.Quote(pattern))
+		}
+		b.WriteString(") || exit 1\ndone")
+		return b.String()
+	}
+
+	b.WriteString("\tset -- \"$@\" -C \"$(dirname \"$p\")\" \"$(basename \"$p\")\"\ndone\n")
+	tarFile := shellutil.Quote(file + ".tar")
+	b.WriteString("tar -cf " + tarFile)
+	for _, pattern := range exclude {
+		b.WriteString(" --exclude=" + shellutil.Quote(pattern))
+	}
+	b.WriteString(` "$@"`)
+
+	if compressor := compressors[format]; compressor != "" {
+		fmt.Fprintf(&b, " && %s %s > %s; rc=$?; rm -f %s; exit $rc", compressor, tarFile, shellutil.Quote(file), tarFile)
+	} else {
+		fmt.Fprintf(&b, " && mv -f %s %s", tarFile, shellutil.Quote(file))
+	}
+	return b.String()
+}
+
+// missingPaths returns the paths that do not exist on the target.
+func missingPaths(ctx context.Context, conn connector.Connector, paths []string) ([]string, error) {
+	cmd := fmt.Sprintf(`for p in %s; do [ -e "$p" ] || [ -L "$p" ] || echo "$p"; done`, shellutil.Join(paths...))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check paths: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "failed to check paths")
+	}
+	var missing []string
+	for _, line := range strings.Split(strings.TrimRight(result.Stdout, "\n"), "\n") {
+		if line != "" {
+			missing = append(missing, line)
+		}
+	}
+	return missing, nil
+}
+
+// fileExists reports whether path is a file on the target.
+func fileExists(ctx context.Context, conn connector.Connector, path string) (bool, error) {
+	result, err := conn.Execute(ctx, "test -f "+shellutil.Quote(path))
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", path, err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+// sameContent reports whether the archive at dest has the same content as
+// the one just built at tmp.
+func sameContent(ctx context.Context, conn connector.Connector, tmp, dest string) (bool, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("[ -f %[2]s ] && cmp -s %[1]s %[2]s", shellutil.Quote(tmp), shellutil.Quote(dest)))
+	if err != nil {
+		return false, fmt.Errorf("failed to compare archives: %w", err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+// ensureAttributes sets the mode and ownership of path if they differ.
+func ensureAttributes(ctx context.Context, conn connector.Connector, path, mode, owner, group string, check bool) (bool, error) {
+	cmd := fmt.Sprintf(`stat -c '%%a %%U %%G' %[1]s 2>/dev/null || stat -f '%%Lp %%Su %%Sg' %[1]s`, shellutil.Quote(path))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to get file attributes: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, module.CommandFailedf(result, "stat failed")
+	}
+	var currentMode, currentOwner, currentGroup string
+	if parts := strings.Fields(result.Stdout); len(parts) >= 3 {
+		currentMode, currentOwner, currentGroup = parts[0], parts[1], parts[2]
+		if len(currentMode) < 4 {
+			currentMode = strings.Repeat("0", 4-len(currentMode)) + currentMode
+		}
+	}
+
+	// A symbolic mode applies to the current one
+	want, err := param.ResolveMode(mode, currentMode, false)
+	if err != nil {
+		return false, err
+	}
+
+	var changed bool
+	if want != currentMode {
+		changed = true
+		if !check {
+			if err := module.RunArgv(ctx, conn, "chmod", want, path); err != nil {
+				return false, err
+			}
+		}
+	}
+	if (owner != "" && owner != currentOwner) || (group != "" && group != currentGroup) {
+		changed = true
+		if !check {
+			ownership := owner
+			if group != "" {
+				ownership += ":" + group
+			}
+			if err := module.RunArgv(ctx, conn, "chown", ownership, path); err != nil {
+				return false, err
+			}
+		}
+	}
+	return changed, nil
+}
+
+// SupportsCheckMode reports that archive can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
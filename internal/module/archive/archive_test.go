@@ -0,0 +1,232 @@
+package archive
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const (
+	dest = "/backup/app.tar.gz"
+
+	missingCmd = `for p in '/etc/app' '/var/lib/app'; do [ -e "$p" ] || [ -L "$p" ] || echo "$p"; done`
+	statCmd    = `stat -c '%a %U %G' '/backup/app.tar.gz' 2>/dev/null || stat -f '%Lp %Su %Sg' '/backup/app.tar.gz'`
+)
+
+// newConn returns a fake target on which both paths exist, creating the
+// archive succeeds, and dest has mode 0644. same sets whether the new
+// archive is identical to dest. Commands not scripted by the test succeed.
+func newConn(same bool) *connectortest.Connector {
+	conn := connectortest.New()
+	conn.On(missingCmd).Return("")
+	conn.OnPrefix("set --\n").Return("")
+	if same {
+		conn.OnPrefix("[ -f '/backup/app.tar.gz' ] && cmp -s").Return("")
+	} else {
+		conn.OnPrefix("[ -f '/backup/app.tar.gz' ] && cmp -s").Fail(1, "")
+	}
+	conn.On(statCmd).Return("644 root root\n")
+	conn.Default(connector.Result{})
+	return conn
+}
+
+func newParams(extra map[string]any) map[string]any {
+	params := map[string]any{"path": []any{"/etc/app", "/var/lib/app"}, "dest": dest}
+	maps.Copy(params, extra)
+	return params
+}
+
+// executedPrefix reports whether a command starting with prefix ran.
+func executedPrefix(conn *connectortest.Connector, prefix string) bool {
+	for _, cmd := range conn.Commands() {
+		if strings.HasPrefix(cmd, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunCreate(t *testing.T) {
+	conn := newConn(false)
+
+	result, err := (&Module{}).Run(context.Background(), conn, newParams(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("Changed = false, want true (%s)", result.Message)
+	}
+	if !executedPrefix(conn, "mv -f '/backup/app.tar.gz.bolt-") {
+		t.Error("archive was not moved into place")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunUpToDate(t *testing.T) {
+	conn := newConn(true)
+
+	result, err := (&Module{}).Run(context.Background(), conn, newParams(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("Changed = true, want false (%s)", result.Message)
+	}
+	if executedPrefix(conn, "mv -f") {
+		t.Error("identical archive replaced dest")
+	}
+	if !executedPrefix(conn, "rm -f '/backup/app.tar.gz.bolt-") {
+		t.Error("new archive was not removed")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunAttributes(t *testing.T) {
+	conn := newConn(true)
+	conn.On("'chmod' '0640' '" + dest + "'").Once()
+	conn.On("'chown' 'backup:adm' '" + dest + "'").Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, newParams(map[string]any{"mode": "0640", "owner": "backup", "group": "adm"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("Changed = false, want true (%s)", result.Message)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunRemove(t *testing.T) {
+	conn := newConn(true)
+	conn.On("rm -rf '/etc/app' '/var/lib/app'").Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, newParams(map[string]any{"remove": true}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("Changed = false, want true (%s)", result.Message)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunAlreadyRemoved(t *testing.T) {
+	conn := connectortest.New()
+	conn.On(missingCmd).Return("/etc/app\n/var/lib/app\n")
+	conn.On("test -f '" + dest + "'")
+
+	result, err := (&Module{}).Run(context.Background(), conn, newParams(map[string]any{"remove": true}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("Changed = true, want false (%s)", result.Message)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunNothingToArchive(t *testing.T) {
+	conn := connectortest.New()
+	conn.On(missingCmd).Return("/etc/app\n/var/lib/app\n")
+	conn.On("test -f '"+dest+"'").Fail(1, "")
+
+	if _, err := (&Module{}).Run(context.Background(), conn, newParams(nil)); err == nil {
+		t.Error("Run() succeeded, want an error")
+	}
+}
+
+func TestRunPartlyMissing(t *testing.T) {
+	conn := connectortest.New()
+	conn.On(missingCmd).Return("/var/lib/app\n")
+	conn.OnPrefix("set --\n").Return("")
+	conn.OnPrefix("[ -f '/backup/app.tar.gz' ] && cmp -s").Fail(1, "")
+	conn.On(statCmd).Return("644 root root\n")
+	conn.Default(connector.Result{})
+
+	result, err := (&Module{}).Run(context.Background(), conn, newParams(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	missing, _ := result.Data["missing"].([]string)
+	if len(missing) != 1 || missing[0] != "/var/lib/app" {
+		t.Errorf("missing = %v, want [/var/lib/app]", result.Data["missing"])
+	}
+}
+
+func TestRunCreateFails(t *testing.T) {
+	conn := connectortest.New()
+	conn.On(missingCmd).Return("")
+	conn.OnPrefix("set --\n").Fail(2, "tar: write error\n")
+	conn.OnPrefix("rm -f '/backup/app.tar.gz.bolt-").Once()
+
+	if _, err := (&Module{}).Run(context.Background(), conn, newParams(nil)); err == nil {
+		t.Fatal("Run() succeeded, want an error")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunCheckMode(t *testing.T) {
+	conn := newConn(false)
+
+	result, err := (&Module{}).Run(context.Background(), conn, newParams(map[string]any{"remove": true, module.CheckModeParam: true}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("Changed = false, want true (%s)", result.Message)
+	}
+	if executedPrefix(conn, "mv -f") || executedPrefix(conn, "rm -rf") {
+		t.Errorf("check mode changed the target: %q", conn.Commands())
+	}
+}
+
+func TestRunInvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]any
+	}{
+		{"no path", map[string]any{"dest": dest}},
+		{"no dest", map[string]any{"path": "/etc/app"}},
+		{"relative dest", map[string]any{"path": "/etc/app", "dest": "app.tar.gz"}},
+		{"relative path", map[string]any{"path": "etc/app", "dest": dest}},
+		{"bad format", map[string]any{"path": "/etc/app", "dest": dest, "format": "rar"}},
+		{"bad mode", map[string]any{"path": "/etc/app", "dest": dest, "mode": "rwx"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&Module{}).Run(context.Background(), connectortest.New(), tt.params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestCreateCommand(t *testing.T) {
+	tests := []struct {
+		format  string
+		exclude []string
+		want    []string
+	}{
+		{"gz", nil, []string{"tar -cf '/tmp/a.tar' \"$@\"", "gzip -n -c '/tmp/a.tar' > '/tmp/a'"}},
+		{"tar", []string{"*.log"}, []string{"--exclude='*.log'", "mv -f '/tmp/a.tar' '/tmp/a'"}},
+		{"zst", nil, []string{"zstd -q -c '/tmp/a.tar' > '/tmp/a'"}},
+		{"zip", []string{"*.log"}, []string{"zip -q -r -X -y '/tmp/a'", "-x '*.log'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			cmd := createCommand([]string{"/etc/app"}, "/tmp/a", tt.format, tt.exclude)
+			for _, want := range tt.want {
+				if !strings.Contains(cmd, want) {
+					t.Errorf("command %q does not contain %q", cmd, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+// Package assert provides a module for verifying the state of the target.
+package assert
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+	"github.com/eugenetaranov/bolt/pkg/facts"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// typeTests maps a path type to its test(1) flag.
+var typeTests = map[string]string{
+	"file":      "-f",
+	"directory": "-d",
+	"link":      "-L",
+}
+
+// Module checks files and command results on the target and fails the
+// task if any check does not hold. It never changes the system.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "assert"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "path", Type: "string", Description: "Path to check"},
+		{Name: "exists", Type: "bool", Default: true, Description: "Whether the path must exist"},
+		{Name: "type", Type: "string", Choices: []string{"file", "directory", "link"}, Description: "Required file type"},
+		{Name: "mode", Type: "string/int", Description: "Required permission mode (e.g., `\"0644\"`), or symbolic permissions it must have (e.g., `u+x`)"},
+		{Name: "contains", Type: "string", Description: "Text the file must contain"},
+		{Name: "command", Type: "string", Description: "Command to run"},
+		{Name: "rc", Type: "int", Default: 0, Description: "Expected exit code of `command`"},
+		{Name: "stdout", Type: "string", Description: "Text the command output must contain"},
+		{Name: "port", Type: "int", Description: "Port to check"},
+		{Name: "protocol", Type: "string", Default: "tcp", Choices: []string{"tcp", "udp"}, Description: "Protocol of `port`"},
+		{Name: "listening", Type: "bool", Default: true, Description: "Whether something must listen on `port`"},
+		{Name: "process", Type: "string", Description: "Process that must listen on `port`; with `listening: false`, the only process allowed to"},
+		{Name: "msg", Type: "string", Description: "Message to report when an assertion fails"},
+	}
+}
+
+// Run executes the assert module. Every given check must pass.
+//
+// Parameters:
+//   - path (string): Path to check
+//   - exists (bool): Whether the path must exist (default: true)
+//   - type (string): Required path type - file, directory, link
+//   - mode (string|int): Required permission mode (e.g., "0644"), or symbolic
+//     permissions the path must have (e.g., "u+x")
+//   - contains (string): Text the file must contain
+//   - command (string): Command to run
+//   - rc (int): Expected exit code of command (default: 0)
+//   - stdout (string): Text the command output must contain
+//   - port (int): Port to check
+//   - protocol (string): Protocol of port - tcp, udp (default: tcp)
+//   - listening (bool): Whether something must listen on port (default: true)
+//   - process (string): Process that must listen on port; with listening false, the only process allowed to
+//   - msg (string): Message to report when an assertion fails
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	path := param.String(params, "path", "")
+	command := param.String(params, "command", "")
+	port := param.Int(params, "port", 0)
+	msg := param.String(params, "msg", "")
+
+	if path == "" && command == "" && port == 0 {
+		return nil, module.ParamErrorf("path", "one of 'path', 'command', or 'port' parameters is required")
+	}
+
+	var failures []string
+
+	if path != "" {
+		f, err := checkPath(ctx, conn, path, params)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, f...)
+	}
+
+	if command != "" {
+		f, err := checkCommand(ctx, conn, command, params)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, f...)
+	}
+
+	if port != 0 {
+		f, err := checkPort(ctx, conn, port, params)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, f...)
+	}
+
+	if len(failures) > 0 {
+		if msg != "" {
+			return nil, fmt.Errorf("assertion failed: %s (%s)", msg, strings.Join(failures, "; "))
+		}
+		return nil, fmt.Errorf("assertion failed: %s", strings.Join(failures, "; "))
+	}
+
+	return module.Unchanged("all assertions passed"), nil
+}
+
+// checkPath verifies the path checks and returns a description of each failure.
+func checkPath(ctx context.Context, conn connector.Connector, path string, params map[string]any) ([]string, error) {
+	exists, err := test(ctx, conn, "-e", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !param.Bool(params, "exists", true) {
+		if exists {
+			return []string{fmt.Sprintf("%s exists", path)}, nil
+		}
+		return nil, nil
+	}
+	if !exists {
+		return []string{fmt.Sprintf("%s does not exist", path)}, nil
+	}
+
+	var failures []string
+
+	if typ := param.String(params, "type", ""); typ != "" {
+		flag, ok := typeTests[typ]
+		if !ok {
+			return nil, module.ParamErrorf("type", "invalid type '%s': must be file, directory, or link", typ)
+		}
+		ok, err := test(ctx, conn, flag, path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s is not a %s", path, typ))
+		}
+	}
+
+	mode, err := param.Mode(params, "mode", "")
+	if err != nil {
+		return nil, err
+	}
+	if mode != "" {
+		cmd := fmt.Sprintf("stat -c '%%a' %[1]s 2>/dev/null || stat -f '%%Lp' %[1]s", shellutil.Quote(path))
+		result, err := conn.Execute(ctx, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		current := strings.TrimSpace(result.Stdout)
+		// A symbolic mode only requires the permissions it names
+		want, err := param.ResolveMode(mode, current, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if normalizeMode(current) != normalizeMode(want) {
+			failures = append(failures, fmt.Sprintf("%s has mode %s, expected %s", path, current, mode))
+		}
+	}
+
+	if contains, ok := params["contains"].(string); ok {
+		result, err := conn.Execute(ctx, fmt.Sprintf("grep -qF -- %s %s", shellutil.Quote(contains), shellutil.Quote(path)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if result.ExitCode != 0 {
+			failures = append(failures, fmt.Sprintf("%s does not contain %q", path, contains))
+		}
+	}
+
+	return failures, nil
+}
+
+// checkCommand runs command and compares its exit code and output.
+func checkCommand(ctx context.Context, conn connector.Connector, command string, params map[string]any) ([]string, error) {
+	result, err := conn.Execute(ctx, command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command: %w", err)
+	}
+
+	var failures []string
+
+	if rc := param.Int(params, "rc", 0); result.ExitCode != rc {
+		failures = append(failures, fmt.Sprintf("%q exited with %d, expected %d", command, result.ExitCode, rc))
+	}
+
+	if stdout, ok := params["stdout"].(string); ok && !strings.Contains(result.Stdout, stdout) {
+		failures = append(failures, fmt.Sprintf("output of %q does not contain %q", command, stdout))
+	}
+
+	return failures, nil
+}
+
+// checkPort verifies which process, if any, listens on port.
+func checkPort(ctx context.Context, conn connector.Connector, port int, params map[string]any) ([]string, error) {
+	protocol := param.String(params, "protocol", "tcp")
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, module.ParamErrorf("protocol", "invalid protocol '%s': must be tcp or udp", protocol)
+	}
+	process := param.String(params, "process", "")
+
+	ports, err := facts.ListeningPorts(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var holders []facts.Port
+	for _, p := range ports {
+		if p.Protocol == protocol && p.Port == port {
+			holders = append(holders, p)
+		}
+	}
+
+	name := fmt.Sprintf("port %d/%s", port, protocol)
+	var failures []string
+
+	if !param.Bool(params, "listening", true) {
+		for _, h := range holders {
+			if process == "" || h.Process != process {
+				failures = append(failures, fmt.Sprintf("%s is in use by %s", name, h))
+				break
+			}
+		}
+		return failures, nil
+	}
+
+	if len(holders) == 0 {
+		return []string{fmt.Sprintf("nothing listens on %s", name)}, nil
+	}
+	if process != "" {
+		for _, h := range holders {
+			if h.Process != process {
+				failures = append(failures, fmt.Sprintf("%s is in use by %s, expected %s", name, h, process))
+				break
+			}
+		}
+	}
+	return failures, nil
+}
+
+// test runs test(1) with a single flag against path.
+func test(ctx context.Context, conn connector.Connector, flag, path string) (bool, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("test %s %s", flag, shellutil.Quote(path)))
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", path, err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+// normalizeMode strips leading zeros so "0644" and "644" compare equal.
+func normalizeMode(mode string) string {
+	n, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return mode
+	}
+	return strconv.FormatUint(n, 8)
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -0,0 +1,90 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// BackupOptions controls where backups are written and how many are kept.
+type BackupOptions struct {
+	// Dir is the directory backups are written to (default: the directory
+	// of the file). It is created if missing.
+	Dir string
+
+	// Keep is the number of backups of the file to keep; older ones are
+	// removed after each backup. Zero keeps all of them.
+	Keep int
+}
+
+// BackupParams reads the backup_dir and backup_keep parameters.
+func BackupParams(params map[string]any) (BackupOptions, error) {
+	opts := BackupOptions{}
+	if v, ok := params["backup_dir"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return opts, ParamErrorf("backup_dir", "parameter 'backup_dir' must be a string")
+		}
+		opts.Dir = s
+	}
+	if v, ok := params["backup_keep"]; ok {
+		n, ok := v.(int)
+		if !ok || n < 0 {
+			return opts, ParamErrorf("backup_keep", "parameter 'backup_keep' must be a non-negative integer")
+		}
+		opts.Keep = n
+	}
+	return opts, nil
+}
+
+// Backup copies file to a timestamped backup (<name>.<YYYYMMDDhhmmss>.bak)
+// and prunes old backups according to opts. It returns the backup's path.
+func Backup(ctx context.Context, conn connector.Connector, file string, opts BackupOptions) (string, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = path.Dir(file)
+	}
+	name := path.Base(file)
+	backupPath := path.Join(dir, fmt.Sprintf("%s.%s.bak", name, time.Now().Format("20060102150405")))
+
+	cmd := fmt.Sprintf("cp -p %s %s", shellutil.Quote(file), shellutil.Quote(backupPath))
+	if opts.Dir != "" {
+		cmd = fmt.Sprintf("mkdir -p %s && %s", shellutil.Quote(dir), cmd)
+	}
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", CommandFailedf(result, "backup failed")
+	}
+
+	if opts.Keep > 0 {
+		if err := pruneBackups(ctx, conn, dir, name, opts.Keep); err != nil {
+			return "", err
+		}
+	}
+	return backupPath, nil
+}
+
+// pruneBackups removes all but the newest keep backups of name in dir.
+// The timestamp in backup names sorts chronologically.
+func pruneBackups(ctx context.Context, conn connector.Connector, dir, name string, keep int) error {
+	pattern := "^" + regexp.QuoteMeta(name) + `\.[0-9]{14}\.bak$`
+	cmd := fmt.Sprintf(`cd %s && ls -1 | grep -E %s | sort -r | tail -n +%d | while IFS= read -r f; do rm -f -- "$f"; done`,
+		shellutil.Quote(dir), shellutil.Quote(pattern), keep+1)
+
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return CommandFailedf(result, "failed to remove old backups")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package module
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestBackup(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      BackupOptions
+		wantPath  string // pattern of the backup path
+		wantMkdir bool
+		wantPrune string // expected "tail -n +N" of the prune command, empty if none
+	}{
+		{"next to file", BackupOptions{}, `^/etc/nginx/nginx\.conf\.[0-9]{14}\.bak$`, false, ""},
+		{"backup dir", BackupOptions{Dir: "/var/backups/bolt"}, `^/var/backups/bolt/nginx\.conf\.[0-9]{14}\.bak$`, true, ""},
+		{"keep", BackupOptions{Keep: 3}, `^/etc/nginx/nginx\.conf\.`, false, "tail -n +4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			conn.Default(connector.Result{})
+
+			got, err := Backup(context.Background(), conn, "/etc/nginx/nginx.conf", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !regexp.MustCompile(tt.wantPath).MatchString(got) {
+				t.Errorf("backup path = %q, want match for %s", got, tt.wantPath)
+			}
+
+			cmds := conn.Commands()
+			if strings.HasPrefix(cmds[0], "mkdir -p") != tt.wantMkdir {
+				t.Errorf("backup command = %q, want mkdir %v", cmds[0], tt.wantMkdir)
+			}
+			if tt.wantPrune == "" {
+				if len(cmds) != 1 {
+					t.Errorf("ran %q, want only the backup", cmds)
+				}
+			} else if len(cmds) != 2 || !strings.Contains(cmds[1], tt.wantPrune) {
+				t.Errorf("ran %q, want a prune with %q", cmds, tt.wantPrune)
+			}
+		})
+	}
+}
+
+func TestBackupParams(t *testing.T) {
+	opts, err := BackupParams(map[string]any{"backup_dir": "/var/backups", "backup_keep": 5})
+	if err != nil || opts.Dir != "/var/backups" || opts.Keep != 5 {
+		t.Errorf("BackupParams() = %+v, %v", opts, err)
+	}
+
+	if _, err := BackupParams(map[string]any{"backup_keep": -1}); err == nil {
+		t.Error("expected error for negative backup_keep")
+	}
+}
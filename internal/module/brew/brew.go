@@ -8,6 +8,8 @@ import (
 
 	"github.com/eugenetaranov/bolt/internal/connector"
 	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
 )
 
 func init() {
@@ -31,6 +33,18 @@ func (m *Module) Name() string {
 	return "brew"
 }
 
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string/list", Description: "Package name(s)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent", "latest"}, Description: "Desired state"},
+		{Name: "cask", Type: "bool", Default: false, Description: "Install as cask (GUI application)"},
+		{Name: "update_homebrew", Type: "bool", Default: false, Description: "Run `brew update` first"},
+		{Name: "upgrade_all", Type: "bool", Default: false, Description: "Upgrade all packages"},
+		{Name: "options", Type: "list", Description: "Additional install options"},
+	}
+}
+
 // Run executes the brew module.
 //
 // Parameters:
@@ -41,31 +55,50 @@ func (m *Module) Name() string {
 //   - update_homebrew (bool): Run brew update before operations (default: false)
 //   - options ([]string): Additional options to pass to brew install
 func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
-	// Check if Homebrew is available
-	if err := checkHomebrew(ctx, conn); err != nil {
-		return nil, err
-	}
-
-	stateStr := getString(params, "state", "present")
+	stateStr := param.String(params, "state", "present")
 	state := State(stateStr)
-	cask := getBool(params, "cask", false)
-	upgradeAll := getBool(params, "upgrade_all", false)
-	updateHomebrew := getBool(params, "update_homebrew", false)
-	options := getStringSlice(params, "options")
+	cask := param.Bool(params, "cask", false)
+	upgradeAll := param.Bool(params, "upgrade_all", false)
+	updateHomebrew := param.Bool(params, "update_homebrew", false)
+	options := param.StringList(params, "options")
+	check := module.IsCheckMode(params)
+	offline := module.IsOffline(params)
 
 	// Validate state
 	switch state {
 	case StatePresent, StateAbsent, StateLatest:
 		// Valid
 	default:
-		return nil, fmt.Errorf("invalid state '%s': must be present, absent, or latest", state)
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present, absent, or latest", state)
 	}
 
+	// Check if Homebrew is available
+	homebrew, err := module.FindHomebrew(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if homebrew == nil {
+		// A dry run of a bootstrap has not installed Homebrew yet
+		names := param.StringList(params, "name")
+		if check && state != StateAbsent && len(names) > 0 {
+			return module.Changed(fmt.Sprintf("would install: %s (homebrew is not installed)", strings.Join(names, ", "))), nil
+		}
+		return nil, fmt.Errorf("homebrew is not installed (install it with the homebrew_install module)")
+	}
+	conn = homebrew.Connector(conn)
+
 	var changed bool
 	var messages []string
 
-	// Update Homebrew if requested
-	if updateHomebrew {
+	// Offline, brew must not update itself before installing either
+	if offline {
+		conn = connector.WithEnv(conn, map[string]string{"HOMEBREW_NO_AUTO_UPDATE": "1"})
+	}
+
+	// Update Homebrew if requested (not checked in check mode)
+	if updateHomebrew && offline {
+		messages = append(messages, "homebrew update skipped (offline)")
+	} else if updateHomebrew && !check {
 		if err := runBrewUpdate(ctx, conn); err != nil {
 			return nil, fmt.Errorf("failed to update homebrew: %w", err)
 		}
@@ -73,8 +106,8 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		changed = true
 	}
 
-	// Upgrade all packages if requested
-	if upgradeAll {
+	// Upgrade all packages if requested (not checked in check mode)
+	if upgradeAll && !check {
 		upgraded, err := runBrewUpgradeAll(ctx, conn, cask)
 		if err != nil {
 			return nil, fmt.Errorf("failed to upgrade packages: %w", err)
@@ -86,14 +119,17 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	}
 
 	// Get package names
-	names := getPackageNames(params)
+	names := param.StringList(params, "name")
 	if len(names) == 0 {
 		if !upgradeAll && !updateHomebrew {
-			return nil, fmt.Errorf("'name' parameter is required when not using upgrade_all or update_homebrew")
+			return nil, module.ParamErrorf("name", "'name' parameter is required when not using upgrade_all or update_homebrew")
 		}
 		if changed {
 			return module.Changed(strings.Join(messages, ", ")), nil
 		}
+		if len(messages) > 0 {
+			return module.Unchanged(strings.Join(messages, ", ")), nil
+		}
 		return module.Unchanged("no changes needed"), nil
 	}
 
@@ -127,6 +163,11 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		}
 	}
 
+	// In check mode, report the planned changes without applying them
+	if check {
+		return checkPlan(ctx, conn, toInstall, toRemove, toUpgrade, cask)
+	}
+
 	// Install packages
 	if len(toInstall) > 0 {
 		if err := installPackages(ctx, conn, toInstall, cask, options); err != nil {
@@ -164,26 +205,47 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	return module.Changed(strings.Join(messages, "; ")), nil
 }
 
-// checkHomebrew verifies that Homebrew is installed.
-func checkHomebrew(ctx context.Context, conn connector.Connector) error {
-	result, err := conn.Execute(ctx, "command -v brew")
-	if err != nil {
-		return fmt.Errorf("failed to check for homebrew: %w", err)
+// checkPlan describes the planned package changes for check mode. Installed
+// packages requested at state=latest count only if they are outdated.
+func checkPlan(ctx context.Context, conn connector.Connector, toInstall, toRemove, toUpgrade []string, cask bool) (*module.Result, error) {
+	var messages []string
+	if len(toInstall) > 0 {
+		messages = append(messages, fmt.Sprintf("would install: %s", strings.Join(toInstall, ", ")))
 	}
-	if result.ExitCode != 0 {
-		return fmt.Errorf("homebrew is not installed")
+	if len(toRemove) > 0 {
+		messages = append(messages, fmt.Sprintf("would remove: %s", strings.Join(toRemove, ", ")))
 	}
-	return nil
+
+	if len(toUpgrade) > 0 {
+		outdated, err := getOutdatedPackages(ctx, conn, cask)
+		if err != nil {
+			return nil, err
+		}
+		var upgradable []string
+		for _, name := range toUpgrade {
+			if outdated[name] {
+				upgradable = append(upgradable, name)
+			}
+		}
+		if len(upgradable) > 0 {
+			messages = append(messages, fmt.Sprintf("would upgrade: %s", strings.Join(upgradable, ", ")))
+		}
+	}
+
+	if len(messages) == 0 {
+		return module.Unchanged("packages already in desired state"), nil
+	}
+	return module.Changed(strings.Join(messages, "; ")), nil
 }
 
 // runBrewUpdate runs brew update.
 func runBrewUpdate(ctx context.Context, conn connector.Connector) error {
-	result, err := conn.Execute(ctx, "brew update")
+	result, err := module.BrewMutex.Execute(ctx, conn, "brew update")
 	if err != nil {
 		return err
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("brew update failed: %s", result.Stderr)
+		return module.CommandFailedf(result, "brew update failed")
 	}
 	return nil
 }
@@ -195,12 +257,12 @@ func runBrewUpgradeAll(ctx context.Context, conn connector.Connector, cask bool)
 		cmd = "brew upgrade --cask"
 	}
 
-	result, err := conn.Execute(ctx, cmd)
+	result, err := module.BrewMutex.Execute(ctx, conn, cmd)
 	if err != nil {
 		return false, err
 	}
 	if result.ExitCode != 0 {
-		return false, fmt.Errorf("brew upgrade failed: %s", result.Stderr)
+		return false, module.CommandFailedf(result, "brew upgrade failed")
 	}
 
 	// Check if anything was upgraded (output contains package names)
@@ -238,19 +300,17 @@ func installPackages(ctx context.Context, conn connector.Connector, names []stri
 	}
 
 	if len(options) > 0 {
-		cmd += " " + strings.Join(options, " ")
+		cmd += " " + shellutil.Join(options...)
 	}
 
-	for _, name := range names {
-		cmd += " " + shellQuote(name)
-	}
+	cmd += " " + shellutil.Join(names...)
 
-	result, err := conn.Execute(ctx, cmd)
+	result, err := module.BrewMutex.Execute(ctx, conn, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to install packages: %w", err)
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("brew install failed: %s", result.Stderr)
+		return module.CommandFailedf(result, "brew install failed")
 	}
 
 	return nil
@@ -263,16 +323,14 @@ func removePackages(ctx context.Context, conn connector.Connector, names []strin
 		cmd = "brew uninstall --cask"
 	}
 
-	for _, name := range names {
-		cmd += " " + shellQuote(name)
-	}
+	cmd += " " + shellutil.Join(names...)
 
-	result, err := conn.Execute(ctx, cmd)
+	result, err := module.BrewMutex.Execute(ctx, conn, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to remove packages: %w", err)
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("brew uninstall failed: %s", result.Stderr)
+		return module.CommandFailedf(result, "brew uninstall failed")
 	}
 
 	return nil
@@ -303,16 +361,14 @@ func upgradePackages(ctx context.Context, conn connector.Connector, names []stri
 		cmd = "brew upgrade --cask"
 	}
 
-	for _, name := range toUpgrade {
-		cmd += " " + shellQuote(name)
-	}
+	cmd += " " + shellutil.Join(toUpgrade...)
 
-	result, err := conn.Execute(ctx, cmd)
+	result, err := module.BrewMutex.Execute(ctx, conn, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upgrade packages: %w", err)
 	}
 	if result.ExitCode != 0 {
-		return nil, fmt.Errorf("brew upgrade failed: %s", result.Stderr)
+		return nil, module.CommandFailedf(result, "brew upgrade failed")
 	}
 
 	return toUpgrade, nil
@@ -341,94 +397,13 @@ func getOutdatedPackages(ctx context.Context, conn connector.Connector, cask boo
 	return outdated, nil
 }
 
-// getPackageNames extracts package names from params.
-// Supports both single string and string slice.
-func getPackageNames(params map[string]any) []string {
-	v, ok := params["name"]
-	if !ok {
-		return nil
-	}
-
-	// Single string
-	if s, ok := v.(string); ok {
-		if s == "" {
-			return nil
-		}
-		return []string{s}
-	}
-
-	// String slice
-	if slice, ok := v.([]any); ok {
-		var names []string
-		for _, item := range slice {
-			if s, ok := item.(string); ok && s != "" {
-				names = append(names, s)
-			}
-		}
-		return names
-	}
-
-	// Already a string slice
-	if slice, ok := v.([]string); ok {
-		return slice
-	}
-
-	return nil
-}
-
-// shellQuote quotes a string for safe use in shell commands.
-func shellQuote(s string) string {
-	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
-}
-
-// Helper functions for parameter extraction
-
-func getString(params map[string]any, key, defaultValue string) string {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	s, ok := v.(string)
-	if !ok {
-		return defaultValue
-	}
-	return s
-}
-
-func getBool(params map[string]any, key string, defaultValue bool) bool {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	b, ok := v.(bool)
-	if !ok {
-		return defaultValue
-	}
-	return b
-}
-
-func getStringSlice(params map[string]any, key string) []string {
-	v, ok := params[key]
-	if !ok {
-		return nil
-	}
-
-	if slice, ok := v.([]any); ok {
-		var result []string
-		for _, item := range slice {
-			if s, ok := item.(string); ok {
-				result = append(result, s)
-			}
-		}
-		return result
-	}
-
-	if slice, ok := v.([]string); ok {
-		return slice
-	}
-
-	return nil
+// SupportsCheckMode reports that brew can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
 }
 
 // Ensure Module implements the module.Module interface.
-var _ module.Module = (*Module)(nil)
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,312 @@
+// Package capabilities provides a module for managing Linux file
+// capabilities.
+package capabilities
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a capability.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the file has the capability with the given flags
+	StateAbsent  State = "absent"  // Ensure the file does not have the capability
+)
+
+// Module sets and removes file capabilities with setcap.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "capabilities"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "path", Type: "string", Required: true, Description: "File to set the capability on"},
+		{Name: "capability", Type: "string", Required: true, Description: "Capability and flags, e.g. `cap_net_bind_service+ep`"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+	}
+}
+
+// Run executes the capabilities module.
+//
+// Parameters:
+//   - path (string, required): File to set the capability on
+//   - capability (string, required): Capabilities and flags in the form of
+//     setcap(8), e.g. "cap_net_bind_service+ep" or "cap_net_admin,cap_net_raw=ep";
+//     flags may be omitted with state absent
+//   - state (string): Desired state - present, absent (default: present)
+//
+// Other capabilities of the file are kept. With state present, the listed
+// capabilities get exactly the given flags.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	path, err := param.Required(params, "path")
+	if err != nil {
+		return nil, err
+	}
+	capability, err := param.Required(params, "capability")
+	if err != nil {
+		return nil, err
+	}
+	state := State(param.String(params, "state", "present"))
+	check := module.IsCheckMode(params)
+
+	if state != StatePresent && state != StateAbsent {
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	names, flags, err := parseClause(strings.ToLower(capability))
+	if err != nil {
+		return nil, module.ParamErrorf("capability", "invalid capability '%s': %v", capability, err)
+	}
+	if state == StatePresent && flags == "" {
+		return nil, module.ParamErrorf("capability", "capability '%s' has no flags (e.g., +ep)", capability)
+	}
+
+	current, err := getcap(ctx, conn, path)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(capSet, len(current))
+	for name, f := range current {
+		want[name] = f
+	}
+	for _, name := range names {
+		if state == StateAbsent {
+			delete(want, name)
+		} else {
+			want[name] = flags
+		}
+	}
+
+	data := map[string]any{
+		"path":         path,
+		"capabilities": want.String(),
+	}
+	if want.String() == current.String() {
+		res := module.Unchanged("capabilities already in desired state")
+		res.Data = data
+		return res, nil
+	}
+
+	if !want.consistent() {
+		return nil, fmt.Errorf("capabilities '%s' mix effective and non-effective flags: on Linux, the effective flag (e) must be set for all capabilities of a file or none", want)
+	}
+	if check {
+		return module.ChangedWithData(fmt.Sprintf("would set capabilities of %s to '%s'", path, want), data), nil
+	}
+
+	cmd := fmt.Sprintf("setcap %s %s", shellutil.Quote(want.String()), shellutil.Quote(path))
+	if len(want) == 0 {
+		cmd = "setcap -r " + shellutil.Quote(path)
+	}
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set capabilities: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "setcap failed")
+	}
+	if len(want) == 0 {
+		return module.ChangedWithData(fmt.Sprintf("capabilities of %s removed", path), data), nil
+	}
+	return module.ChangedWithData(fmt.Sprintf("capabilities of %s set to '%s'", path, want), data), nil
+}
+
+// capSet maps capability names to their flags, a sorted subset of "eip".
+type capSet map[string]string
+
+// String renders the set as setcap(8) text, grouping capabilities with the
+// same flags: "cap_net_admin,cap_net_raw=ep cap_sys_nice=p".
+func (s capSet) String() string {
+	byFlags := map[string][]string{}
+	for name, flags := range s {
+		byFlags[flags] = append(byFlags[flags], name)
+	}
+	var clauses []string
+	for flags, names := range byFlags {
+		sort.Strings(names)
+		clauses = append(clauses, strings.Join(names, ",")+"="+flags)
+	}
+	sort.Strings(clauses)
+	return strings.Join(clauses, " ")
+}
+
+// consistent reports whether the effective flag is set for all
+// capabilities or none, which Linux requires.
+func (s capSet) consistent() bool {
+	effective := 0
+	for _, flags := range s {
+		if strings.ContainsRune(flags, 'e') {
+			effective++
+		}
+	}
+	return effective == 0 || effective == len(s)
+}
+
+// getcap returns the capabilities of path.
+func getcap(ctx context.Context, conn connector.Connector, path string) (capSet, error) {
+	result, err := conn.Execute(ctx, "command -v getcap && command -v setcap")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for getcap: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("getcap and setcap are not installed (libcap2-bin or libcap)")
+	}
+
+	result, err = conn.Execute(ctx, "test -e "+shellutil.Quote(path)+" && getcap "+shellutil.Quote(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capabilities: %w", err)
+	}
+	if result.ExitCode != 0 {
+		if strings.TrimSpace(result.Stderr) == "" {
+			return nil, fmt.Errorf("file not found: %s", path)
+		}
+		return nil, module.CommandFailedf(result, "getcap failed")
+	}
+
+	caps, err := parseGetcap(result.Stdout, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse getcap output: %w", err)
+	}
+	return caps, nil
+}
+
+// parseGetcap parses the output of getcap for path. libcap before 2.41
+// prints "path = cap_net_raw+ep", later versions "path cap_net_raw=ep";
+// a file without capabilities prints nothing.
+func parseGetcap(out, path string) (capSet, error) {
+	text := strings.TrimSpace(out)
+	text = strings.TrimSpace(strings.TrimPrefix(text, path))
+	text = strings.TrimSpace(strings.TrimPrefix(text, "="))
+
+	caps := capSet{}
+	for _, clause := range strings.Fields(text) {
+		names, ops, err := parseOps(clause)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			flags := caps[name]
+			for _, op := range ops {
+				switch op.op {
+				case '=':
+					flags = op.flags
+				case '+':
+					flags = mergeFlags(flags, op.flags, true)
+				case '-':
+					flags = mergeFlags(flags, op.flags, false)
+				}
+			}
+			if flags == "" {
+				delete(caps, name)
+			} else {
+				caps[name] = flags
+			}
+		}
+	}
+	return caps, nil
+}
+
+// parseClause parses a single capability clause such as
+// "cap_net_bind_service+ep" into its names and the flags it sets. A
+// clause without an operator has no flags.
+func parseClause(clause string) ([]string, string, error) {
+	if strings.ContainsAny(clause, " \t") {
+		return nil, "", fmt.Errorf("only one clause is supported")
+	}
+	names, ops, err := parseOps(clause)
+	if err != nil {
+		return nil, "", err
+	}
+	flags := ""
+	for _, op := range ops {
+		if op.op == '-' {
+			return nil, "", fmt.Errorf("use state absent to remove a capability")
+		}
+		flags = mergeFlags(flags, op.flags, true)
+	}
+	return names, flags, nil
+}
+
+// capOp is an operator of a capability clause with its flags.
+type capOp struct {
+	op    byte
+	flags string
+}
+
+// parseOps splits a clause into capability names and operators.
+func parseOps(clause string) ([]string, []capOp, error) {
+	idx := strings.IndexAny(clause, "=+-")
+	namePart := clause
+	if idx >= 0 {
+		namePart = clause[:idx]
+	}
+	var names []string
+	for _, name := range strings.Split(namePart, ",") {
+		if !strings.HasPrefix(name, "cap_") {
+			return nil, nil, fmt.Errorf("invalid capability name '%s'", name)
+		}
+		names = append(names, name)
+	}
+
+	var ops []capOp
+	for idx >= 0 && idx < len(clause) {
+		op := capOp{op: clause[idx]}
+		end := idx + 1
+		for end < len(clause) && strings.IndexByte("=+-", clause[end]) < 0 {
+			end++
+		}
+		for _, f := range clause[idx+1 : end] {
+			if !strings.ContainsRune("eip", f) {
+				return nil, nil, fmt.Errorf("invalid flag '%c'", f)
+			}
+		}
+		op.flags = mergeFlags("", clause[idx+1:end], true)
+		ops = append(ops, op)
+		idx = end
+	}
+	return names, ops, nil
+}
+
+// mergeFlags adds flags to or removes them from have, returning the result
+// in "eip" order.
+func mergeFlags(have, flags string, add bool) string {
+	var out strings.Builder
+	for _, f := range "eip" {
+		in := strings.ContainsRune(have, f)
+		if strings.ContainsRune(flags, f) {
+			in = add
+		}
+		if in {
+			out.WriteRune(f)
+		}
+	}
+	return out.String()
+}
+
+// SupportsCheckMode reports that capabilities can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
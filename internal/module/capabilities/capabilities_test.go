@@ -0,0 +1,165 @@
+package capabilities
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const testPath = "/usr/bin/app"
+
+func TestParseGetcap(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{"none", "", ""},
+		{"old format", testPath + " = cap_net_raw+ep\n", "cap_net_raw=ep"},
+		{"new format", testPath + " cap_net_admin,cap_net_raw=ep\n", "cap_net_admin,cap_net_raw=ep"},
+		{"mixed flags", testPath + " cap_net_raw=ep cap_sys_nice+p\n", "cap_net_raw=ep cap_sys_nice=p"},
+		{"removed flags", testPath + " cap_net_raw=eip cap_net_raw-i\n", "cap_net_raw=ep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps, err := parseGetcap(tt.out, testPath)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := caps.String(); got != tt.want {
+				t.Errorf("parseGetcap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		params  map[string]any
+		wantCmd string
+	}{
+		{
+			name:    "add",
+			params:  map[string]any{"capability": "cap_net_bind_service+ep"},
+			wantCmd: "setcap 'cap_net_bind_service=ep' '/usr/bin/app'",
+		},
+		{
+			name:    "keep others",
+			current: "cap_net_raw=ep",
+			params:  map[string]any{"capability": "CAP_NET_ADMIN=ep"},
+			wantCmd: "setcap 'cap_net_admin,cap_net_raw=ep' '/usr/bin/app'",
+		},
+		{
+			name:    "change flags",
+			current: "cap_net_raw=p",
+			params:  map[string]any{"capability": "cap_net_raw+ep"},
+			wantCmd: "setcap 'cap_net_raw=ep' '/usr/bin/app'",
+		},
+		{
+			name:    "remove one",
+			current: "cap_net_admin,cap_net_raw=ep",
+			params:  map[string]any{"capability": "cap_net_raw", "state": "absent"},
+			wantCmd: "setcap 'cap_net_admin=ep' '/usr/bin/app'",
+		},
+		{
+			name:    "remove last",
+			current: "cap_net_raw=ep",
+			params:  map[string]any{"capability": "cap_net_raw", "state": "absent"},
+			wantCmd: "setcap -r '/usr/bin/app'",
+		},
+		{
+			name:    "already set",
+			current: "cap_net_raw=ep",
+			params:  map[string]any{"capability": "cap_net_raw+ep"},
+		},
+		{
+			name:   "already absent",
+			params: map[string]any{"capability": "cap_net_raw", "state": "absent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConn(tt.current)
+			if tt.wantCmd != "" {
+				conn.On(tt.wantCmd).Once()
+			}
+			tt.params["path"] = testPath
+
+			result, err := (&Module{}).Run(context.Background(), conn, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed != (tt.wantCmd != "") {
+				t.Errorf("Changed = %v, want %v (%s)", result.Changed, tt.wantCmd != "", result.Message)
+			}
+			conn.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	conn := newConn("")
+	params := map[string]any{"path": testPath, "capability": "cap_net_raw+ep", module.CheckModeParam: true}
+
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		params  map[string]any
+	}{
+		{"no flags", "", map[string]any{"capability": "cap_net_raw"}},
+		{"bad name", "", map[string]any{"capability": "net_raw+ep"}},
+		{"bad flag", "", map[string]any{"capability": "cap_net_raw+x"}},
+		{"minus", "", map[string]any{"capability": "cap_net_raw-e"}},
+		{"two clauses", "", map[string]any{"capability": "cap_net_raw+ep cap_sys_nice+p"}},
+		{"mixed effective", "cap_net_raw=ep", map[string]any{"capability": "cap_sys_nice+p"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params["path"] = testPath
+			if _, err := (&Module{}).Run(context.Background(), newConn(tt.current), tt.params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestRunNotInstalled(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("command -v getcap && command -v setcap").Fail(1, "")
+	params := map[string]any{"path": testPath, "capability": "cap_net_raw+ep"}
+
+	if _, err := (&Module{}).Run(context.Background(), conn, params); err == nil {
+		t.Error("Run() succeeded, want an error without getcap")
+	}
+}
+
+// newConn returns a fake target where testPath has the capabilities
+// current, in setcap(8) text. Any other command fails.
+func newConn(current string) *connectortest.Connector {
+	conn := connectortest.New()
+	conn.On("command -v getcap && command -v setcap").Return("/usr/sbin/getcap\n/usr/sbin/setcap\n")
+	out := ""
+	if current != "" {
+		out = testPath + " " + current + "\n"
+	}
+	conn.On("test -e '" + testPath + "' && getcap '" + testPath + "'").Return(out)
+	return conn
+}
@@ -0,0 +1,363 @@
+// Package certificate provides a module for managing private keys and TLS certificates.
+package certificate
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a certificate.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure a valid certificate and key exist
+	StateAbsent  State = "absent"  // Ensure the certificate and key are removed
+)
+
+// Provider selects how certificates are issued.
+type Provider string
+
+const (
+	ProviderSelfSigned Provider = "selfsigned" // Sign the certificate with its own key using openssl
+	ProviderACME       Provider = "acme"       // Request the certificate from an ACME CA using certbot
+)
+
+// Module generates private keys and self-signed or ACME certificates on the target.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "certificate"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "path", Type: "string", Required: true, Description: "Certificate path (full chain for ACME)"},
+		{Name: "privatekey_path", Type: "string", Required: true, Description: "Private key path"},
+		{Name: "common_name", Type: "string", Description: "Subject common name"},
+		{Name: "subject_alt_names", Type: "list", Description: "Additional DNS names"},
+		{Name: "provider", Type: "string", Default: "selfsigned", Choices: []string{"selfsigned", "acme"}, Description: "Certificate provider"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "days", Type: "int", Default: 365, Description: "Validity of self-signed certificates"},
+		{Name: "remaining_days", Type: "int", Default: 30, Description: "Renew when expiring within this many days"},
+		{Name: "key_type", Type: "string", Default: "rsa", Choices: []string{"rsa", "ec"}, Description: "Private key type"},
+		{Name: "key_size", Type: "int", Default: 2048, Description: "RSA key size"},
+		{Name: "acme_email", Type: "string", Description: "ACME account email"},
+		{Name: "acme_challenge", Type: "string", Default: "http-01", Choices: []string{"http-01", "dns-01"}, Description: "ACME challenge type"},
+		{Name: "acme_webroot", Type: "string", Description: "Webroot for `http-01`; certbot runs standalone if unset"},
+		{Name: "acme_dns_plugin", Type: "string", Description: "certbot DNS plugin for `dns-01` (e.g., `cloudflare`)"},
+		{Name: "acme_dns_credentials", Type: "string", Description: "Credentials file for the DNS plugin"},
+		{Name: "acme_directory", Type: "string", Description: "ACME directory URL (e.g., Let's Encrypt staging)"},
+	}
+}
+
+// Run executes the certificate module.
+//
+// Parameters:
+//   - path (string, required): Certificate path (full chain for ACME)
+//   - privatekey_path (string, required): Private key path
+//   - common_name (string): Subject common name (required when state=present)
+//   - subject_alt_names ([]string): Additional DNS names
+//   - provider (string): selfsigned, acme (default: selfsigned)
+//   - state (string): Desired state - present, absent (default: present)
+//   - days (int): Validity of self-signed certificates (default: 365)
+//   - remaining_days (int): Renew when the certificate expires within this many days (default: 30)
+//   - key_type (string): rsa, ec (default: rsa)
+//   - key_size (int): RSA key size in bits (default: 2048)
+//   - acme_email (string): Account email for the ACME CA (required for acme)
+//   - acme_challenge (string): http-01, dns-01 (default: http-01)
+//   - acme_webroot (string): Webroot for http-01; certbot runs standalone if unset
+//   - acme_dns_plugin (string): certbot DNS plugin for dns-01 (e.g., "cloudflare")
+//   - acme_dns_credentials (string): Credentials file for the DNS plugin
+//   - acme_directory (string): ACME directory URL (default: Let's Encrypt production)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	path, err := param.Required(params, "path")
+	if err != nil {
+		return nil, err
+	}
+	keyPath, err := param.Required(params, "privatekey_path")
+	if err != nil {
+		return nil, err
+	}
+
+	commonName := param.String(params, "common_name", "")
+	altNames := param.StringList(params, "subject_alt_names")
+	providerStr := param.String(params, "provider", "selfsigned")
+	provider := Provider(providerStr)
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	remainingDays := param.Int(params, "remaining_days", 30)
+
+	switch state {
+	case StatePresent:
+		if commonName == "" {
+			return nil, module.ParamErrorf("common_name", "'common_name' parameter is required when state=present")
+		}
+	case StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	switch provider {
+	case ProviderSelfSigned, ProviderACME:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("provider", "invalid provider '%s': must be selfsigned or acme", provider)
+	}
+
+	if state == StateAbsent {
+		result, err := conn.Execute(ctx, fmt.Sprintf("test -e %s -o -e %s", shellutil.Quote(path), shellutil.Quote(keyPath)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to check certificate: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return module.Unchanged("certificate already absent"), nil
+		}
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("rm -f %s %s", shellutil.Quote(path), shellutil.Quote(keyPath))); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed %s and %s", path, keyPath)), nil
+	}
+
+	if err := checkOpenSSL(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	names := append([]string{commonName}, altNames...)
+
+	valid, err := certificateValid(ctx, conn, path, keyPath, commonName, remainingDays)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg string
+	if !valid {
+		switch provider {
+		case ProviderSelfSigned:
+			keyCreated, err := ensurePrivateKey(ctx, conn, keyPath, params)
+			if err != nil {
+				return nil, err
+			}
+			if err := selfSign(ctx, conn, path, keyPath, names, param.Int(params, "days", 365)); err != nil {
+				return nil, err
+			}
+			msg = fmt.Sprintf("generated self-signed certificate for %s", commonName)
+			if keyCreated {
+				msg += " with new private key"
+			}
+		case ProviderACME:
+			if module.IsOffline(params) && param.String(params, "acme_directory", "") == "" {
+				return nil, module.OfflineErrorf("requesting a certificate from a public ACME CA", "set acme_directory to a CA inside your network, or use provider: selfsigned")
+			}
+			if err := issueACME(ctx, conn, path, keyPath, names, params); err != nil {
+				return nil, err
+			}
+			msg = fmt.Sprintf("issued ACME certificate for %s", commonName)
+		}
+	}
+
+	notAfter, err := expiryDate(ctx, conn, path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]any{
+		"path":            path,
+		"privatekey_path": keyPath,
+		"common_name":     commonName,
+		"not_after":       notAfter,
+	}
+
+	if valid {
+		return &module.Result{
+			Changed: false,
+			Message: fmt.Sprintf("certificate for %s is valid until %s", commonName, notAfter),
+			Data:    data,
+		}, nil
+	}
+
+	return module.ChangedWithData(msg, data), nil
+}
+
+// checkOpenSSL verifies that openssl is available.
+func checkOpenSSL(ctx context.Context, conn connector.Connector) error {
+	result, err := conn.Execute(ctx, "command -v openssl")
+	if err != nil {
+		return fmt.Errorf("failed to check for openssl: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("openssl is not installed")
+	}
+	return nil
+}
+
+// certificateValid reports whether the certificate exists, matches the key and
+// common name, and does not expire within remainingDays.
+func certificateValid(ctx context.Context, conn connector.Connector, path, keyPath, commonName string, remainingDays int) (bool, error) {
+	cmd := fmt.Sprintf("test -f %[1]s && test -f %[2]s && openssl x509 -checkend %[3]d -noout -in %[1]s >/dev/null",
+		shellutil.Quote(path), shellutil.Quote(keyPath), remainingDays*86400)
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to check certificate: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, nil
+	}
+
+	// The certificate must belong to the private key
+	cmd = fmt.Sprintf("[ \"$(openssl x509 -noout -pubkey -in %s)\" = \"$(openssl pkey -pubout -in %s)\" ]",
+		shellutil.Quote(path), shellutil.Quote(keyPath))
+	result, err = conn.Execute(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to compare certificate and key: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, nil
+	}
+
+	result, err = conn.Execute(ctx, fmt.Sprintf("openssl x509 -noout -subject -nameopt RFC2253 -in %s", shellutil.Quote(path)))
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate subject: %w", err)
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(result.Stdout), "subject="), ",") {
+		if strings.TrimSpace(part) == "CN="+commonName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ensurePrivateKey generates the private key if it does not exist.
+// It returns whether a key was created.
+func ensurePrivateKey(ctx context.Context, conn connector.Connector, keyPath string, params map[string]any) (bool, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("test -f %s", shellutil.Quote(keyPath)))
+	if err != nil {
+		return false, fmt.Errorf("failed to check private key: %w", err)
+	}
+	if result.ExitCode == 0 {
+		return false, nil
+	}
+
+	var algorithm string
+	switch keyType := param.String(params, "key_type", "rsa"); keyType {
+	case "rsa":
+		algorithm = fmt.Sprintf("-algorithm RSA -pkeyopt rsa_keygen_bits:%d", param.Int(params, "key_size", 2048))
+	case "ec":
+		algorithm = "-algorithm EC -pkeyopt ec_paramgen_curve:P-256"
+	default:
+		return false, module.ParamErrorf("key_type", "invalid key_type '%s': must be rsa or ec", keyType)
+	}
+
+	cmd := fmt.Sprintf("mkdir -p %s && (umask 077; openssl genpkey %s -out %s)",
+		shellutil.Quote(path.Dir(keyPath)), algorithm, shellutil.Quote(keyPath))
+	if err := module.RunCommand(ctx, conn, cmd); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// selfSign creates a self-signed certificate for names using the private key.
+func selfSign(ctx context.Context, conn connector.Connector, certPath, keyPath string, names []string, days int) error {
+	san := make([]string, len(names))
+	for i, name := range names {
+		san[i] = "DNS:" + name
+	}
+
+	cmd := fmt.Sprintf("mkdir -p %s && openssl req -x509 -new -key %s -out %s -days %d -subj %s -addext %s",
+		shellutil.Quote(path.Dir(certPath)), shellutil.Quote(keyPath), shellutil.Quote(certPath), days,
+		shellutil.Quote("/CN="+names[0]), shellutil.Quote("subjectAltName="+strings.Join(san, ",")))
+	return module.RunCommand(ctx, conn, cmd)
+}
+
+// issueACME requests a certificate with certbot and installs it at certPath and keyPath.
+func issueACME(ctx context.Context, conn connector.Connector, certPath, keyPath string, names []string, params map[string]any) error {
+	email, err := param.Required(params, "acme_email")
+	if err != nil {
+		return err
+	}
+
+	result, err := conn.Execute(ctx, "command -v certbot")
+	if err != nil {
+		return fmt.Errorf("failed to check for certbot: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("certbot is required for provider=acme but is not installed")
+	}
+
+	args := []string{
+		"certbot", "certonly", "--non-interactive", "--agree-tos", "--force-renewal",
+		"-m", email,
+		"--cert-name", names[0],
+	}
+	if directory := param.String(params, "acme_directory", ""); directory != "" {
+		args = append(args, "--server", directory)
+	}
+	if param.String(params, "key_type", "rsa") == "ec" {
+		args = append(args, "--key-type", "ecdsa")
+	} else {
+		args = append(args, "--key-type", "rsa", "--rsa-key-size", fmt.Sprintf("%d", param.Int(params, "key_size", 2048)))
+	}
+
+	switch challenge := param.String(params, "acme_challenge", "http-01"); challenge {
+	case "http-01":
+		if webroot := param.String(params, "acme_webroot", ""); webroot != "" {
+			args = append(args, "--webroot", "-w", webroot)
+		} else {
+			args = append(args, "--standalone")
+		}
+	case "dns-01":
+		plugin, err := param.Required(params, "acme_dns_plugin")
+		if err != nil {
+			return err
+		}
+		args = append(args, "--dns-"+plugin)
+		if creds := param.String(params, "acme_dns_credentials", ""); creds != "" {
+			args = append(args, fmt.Sprintf("--dns-%s-credentials", plugin), creds)
+		}
+	default:
+		return module.ParamErrorf("acme_challenge", "invalid acme_challenge '%s': must be http-01 or dns-01", challenge)
+	}
+
+	for _, name := range names {
+		args = append(args, "-d", name)
+	}
+
+	if err := module.RunArgv(ctx, conn, args...); err != nil {
+		return err
+	}
+
+	// Install the issued files at the requested locations
+	live := "/etc/letsencrypt/live/" + names[0]
+	cmd := fmt.Sprintf("mkdir -p %[5]s %[6]s && install -m 0644 %[3]s %[1]s && install -m 0600 %[4]s %[2]s",
+		shellutil.Quote(certPath), shellutil.Quote(keyPath), shellutil.Quote(live+"/fullchain.pem"), shellutil.Quote(live+"/privkey.pem"),
+		shellutil.Quote(path.Dir(certPath)), shellutil.Quote(path.Dir(keyPath)))
+	return module.RunCommand(ctx, conn, cmd)
+}
+
+// expiryDate returns the notAfter date of the certificate.
+func expiryDate(ctx context.Context, conn connector.Connector, path string) (string, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("openssl x509 -noout -enddate -in %s", shellutil.Quote(path)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read certificate expiry: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", module.CommandFailedf(result, "failed to read certificate expiry")
+	}
+	return strings.TrimPrefix(strings.TrimSpace(result.Stdout), "notAfter="), nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
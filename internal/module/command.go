@@ -0,0 +1,77 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// RunCommand executes cmd on the target, for commands whose output does
+// not matter, and returns a CommandFailed naming cmd if it exits non-zero.
+// Commands that contain secrets need their own error message instead.
+func RunCommand(ctx context.Context, conn connector.Connector, cmd string) error {
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return CommandFailedf(result, "command failed: %s", cmd)
+	}
+	return nil
+}
+
+// RunArgv is RunCommand for a program and its arguments, which it runs
+// without a shell on connectors that support it (see
+// connector.ArgvExecutor).
+func RunArgv(ctx context.Context, conn connector.Connector, argv ...string) error {
+	result, err := connector.ExecuteArgv(ctx, conn, argv...)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return CommandFailedf(result, "command failed: %s", shellutil.Join(argv...))
+	}
+	return nil
+}
+
+// TargetOS returns the kernel name of the target, e.g. "Linux" or "Darwin".
+func TargetOS(ctx context.Context, conn connector.Connector) (string, error) {
+	result, err := conn.Execute(ctx, "uname -s")
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the OS: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", CommandFailedf(result, "failed to detect the OS")
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// HomeDir returns the home directory of the connecting user.
+func HomeDir(ctx context.Context, conn connector.Connector) (string, error) {
+	result, err := conn.Execute(ctx, `printf '%s' "$HOME"`)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the home directory: %w", err)
+	}
+	home := strings.TrimSpace(result.Stdout)
+	if result.ExitCode != 0 || home == "" {
+		return "", fmt.Errorf("failed to get the home directory of %s", conn)
+	}
+	return home, nil
+}
+
+// ExpandHome replaces a leading ~ in p with the home directory of the
+// connecting user. The home directory is only looked up if p starts
+// with ~.
+func ExpandHome(ctx context.Context, conn connector.Connector, p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+	home, err := HomeDir(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+	return home + strings.TrimPrefix(p, "~"), nil
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/eugenetaranov/bolt/internal/connector"
 	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
 )
 
 func init() {
@@ -22,6 +24,16 @@ func (m *Module) Name() string {
 	return "command"
 }
 
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "cmd", Type: "string", Required: true, Description: "Command to execute"},
+		{Name: "chdir", Type: "string", Description: "Change to directory before running"},
+		{Name: "creates", Type: "string", Description: "Skip if this path exists"},
+		{Name: "removes", Type: "string", Description: "Only run if this path exists"},
+	}
+}
+
 // Run executes the command module.
 //
 // Parameters:
@@ -32,14 +44,14 @@ func (m *Module) Name() string {
 //   - warn (bool): Whether to warn about common issues (default: true)
 func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
 	// Extract parameters
-	cmd, err := requireString(params, "cmd")
+	cmd, err := param.Required(params, "cmd")
 	if err != nil {
 		return nil, err
 	}
 
-	chdir := getString(params, "chdir", "")
-	creates := getString(params, "creates", "")
-	removes := getString(params, "removes", "")
+	chdir := param.String(params, "chdir", "")
+	creates := param.String(params, "creates", "")
+	removes := param.String(params, "removes", "")
 
 	// Check 'creates' condition - skip if file exists
 	if creates != "" {
@@ -66,7 +78,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	// Build the command with chdir if specified
 	fullCmd := cmd
 	if chdir != "" {
-		fullCmd = fmt.Sprintf("cd %s && %s", shellQuote(chdir), cmd)
+		fullCmd = fmt.Sprintf("cd %s && %s", shellutil.Quote(chdir), cmd)
 	}
 
 	// Execute the command
@@ -77,12 +89,9 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 
 	// Check for non-zero exit code
 	if result.ExitCode != 0 {
-		return nil, &CommandError{
-			Cmd:      cmd,
-			ExitCode: result.ExitCode,
-			Stdout:   result.Stdout,
-			Stderr:   result.Stderr,
-		}
+		failed := module.CommandFailedf(result, "command failed with exit code %d: %s", result.ExitCode, cmd)
+		failed.Cmd = cmd
+		return nil, failed
 	}
 
 	return module.ChangedWithData("command executed successfully", map[string]any{
@@ -93,65 +102,14 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	}), nil
 }
 
-// CommandError represents a command execution failure.
-type CommandError struct {
-	Cmd      string
-	ExitCode int
-	Stdout   string
-	Stderr   string
-}
-
-func (e *CommandError) Error() string {
-	msg := fmt.Sprintf("command failed with exit code %d: %s", e.ExitCode, e.Cmd)
-	if e.Stderr != "" {
-		msg += fmt.Sprintf("\nstderr: %s", strings.TrimSpace(e.Stderr))
-	}
-	return msg
-}
-
 // fileExists checks if a file or directory exists on the target.
 func fileExists(ctx context.Context, conn connector.Connector, path string) (bool, error) {
-	result, err := conn.Execute(ctx, fmt.Sprintf("test -e %s", shellQuote(path)))
+	result, err := conn.Execute(ctx, fmt.Sprintf("test -e %s", shellutil.Quote(path)))
 	if err != nil {
 		return false, err
 	}
 	return result.ExitCode == 0, nil
 }
 
-// shellQuote quotes a string for safe use in shell commands.
-func shellQuote(s string) string {
-	// Use single quotes and escape any single quotes in the string
-	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
-}
-
-// Helper functions for parameter extraction
-
-func requireString(params map[string]any, key string) (string, error) {
-	v, ok := params[key]
-	if !ok {
-		return "", fmt.Errorf("required parameter '%s' is missing", key)
-	}
-	s, ok := v.(string)
-	if !ok {
-		return "", fmt.Errorf("parameter '%s' must be a string", key)
-	}
-	if s == "" {
-		return "", fmt.Errorf("parameter '%s' cannot be empty", key)
-	}
-	return s, nil
-}
-
-func getString(params map[string]any, key, defaultValue string) string {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	s, ok := v.(string)
-	if !ok {
-		return defaultValue
-	}
-	return s
-}
-
 // Ensure Module implements the module.Module interface.
 var _ module.Module = (*Module)(nil)
@@ -0,0 +1,103 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestRunCommand(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("true")
+	conn.On("false").Fail(1, "boom\n")
+
+	if err := RunCommand(context.Background(), conn, "true"); err != nil {
+		t.Fatalf("RunCommand(true) = %v", err)
+	}
+
+	err := RunCommand(context.Background(), conn, "false")
+	var cmdErr *CommandFailed
+	if !errors.As(err, &cmdErr) || cmdErr.RC != 1 {
+		t.Fatalf("RunCommand(false) = %v, want a CommandFailed with rc 1", err)
+	}
+	if !strings.Contains(err.Error(), "command failed: false") {
+		t.Errorf("error = %q, want the command in it", err)
+	}
+}
+
+func TestRunArgv(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("'chmod' '0644' '/etc/a b'")
+	conn.On("'chmod' 'u+x' '/etc/c'").Fail(1, "chmod: No such file or directory\n")
+
+	if err := RunArgv(context.Background(), conn, "chmod", "0644", "/etc/a b"); err != nil {
+		t.Fatalf("RunArgv() = %v", err)
+	}
+
+	err := RunArgv(context.Background(), conn, "chmod", "u+x", "/etc/c")
+	var cmdErr *CommandFailed
+	if !errors.As(err, &cmdErr) || cmdErr.RC != 1 {
+		t.Fatalf("RunArgv() = %v, want a CommandFailed with rc 1", err)
+	}
+	if !strings.Contains(err.Error(), "command failed: 'chmod' 'u+x' '/etc/c'") {
+		t.Errorf("error = %q, want the command in it", err)
+	}
+}
+
+func TestTargetOS(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("uname -s").Return("Darwin\n").Once()
+	conn.On("uname -s").Fail(127, "uname: not found\n")
+
+	got, err := TargetOS(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("TargetOS() = %v", err)
+	}
+	if got != "Darwin" {
+		t.Errorf("TargetOS() = %q, want %q", got, "Darwin")
+	}
+
+	if _, err := TargetOS(context.Background(), conn); err == nil {
+		t.Error("TargetOS() succeeded when uname failed")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestExpandHome(t *testing.T) {
+	conn := connectortest.New()
+	conn.On(`printf '%s' "$HOME"`).Return("/home/alice")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", "/home/alice"},
+		{"~/.ssh/config", "/home/alice/.ssh/config"},
+		{"/etc/ssh/ssh_config", "/etc/ssh/ssh_config"},
+		{"~bob/.ssh/config", "~bob/.ssh/config"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandHome(context.Background(), conn, tt.path)
+		if err != nil {
+			t.Fatalf("ExpandHome(%q) = %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("ExpandHome(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	if n := len(conn.Commands()); n != 2 {
+		t.Errorf("%d commands executed, want the home directory looked up twice", n)
+	}
+}
+
+func TestHomeDirEmpty(t *testing.T) {
+	conn := connectortest.New()
+	conn.On(`printf '%s' "$HOME"`).Return("")
+
+	if _, err := HomeDir(context.Background(), conn); err == nil {
+		t.Error("HomeDir() succeeded with an empty $HOME")
+	}
+}
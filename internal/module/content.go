@@ -0,0 +1,108 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Line endings the newline parameter converts file content to.
+const (
+	NewlineLF   = "lf"
+	NewlineCRLF = "crlf"
+)
+
+// ContentOptions controls how the modules that write whole files (copy,
+// template) normalize content, so files authored on different platforms
+// do not report a change on every run.
+type ContentOptions struct {
+	// Newline converts the line endings of the content before it is
+	// compared and written: NewlineLF, NewlineCRLF, or empty to keep them.
+	Newline string
+
+	// IgnoreWhitespace leaves a file that only differs from the content
+	// in trailing whitespace and line endings as it is.
+	IgnoreWhitespace bool
+}
+
+// ContentParams reads the newline and ignore_whitespace parameters.
+func ContentParams(params map[string]any) (ContentOptions, error) {
+	opts := ContentOptions{}
+	if v, ok := params["newline"]; ok {
+		s, ok := v.(string)
+		if !ok || (s != NewlineLF && s != NewlineCRLF) {
+			return opts, ParamErrorf("newline", "parameter 'newline' must be %q or %q", NewlineLF, NewlineCRLF)
+		}
+		opts.Newline = s
+	}
+	if v, ok := params["ignore_whitespace"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			return opts, ParamErrorf("ignore_whitespace", "parameter 'ignore_whitespace' must be a bool")
+		}
+		opts.IgnoreWhitespace = b
+	}
+	return opts, nil
+}
+
+// Convert returns content with its line endings converted as opts.Newline
+// requests. Content is returned as it is if Newline is empty.
+func (o ContentOptions) Convert(content []byte) []byte {
+	switch o.Newline {
+	case NewlineLF:
+		return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	case NewlineCRLF:
+		lf := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		return bytes.ReplaceAll(lf, []byte("\n"), []byte("\r\n"))
+	}
+	return content
+}
+
+// SameText reports whether a and b only differ in trailing whitespace on
+// their lines, in line endings (LF or CRLF), or in empty lines at the end.
+func SameText(a, b []byte) bool {
+	return bytes.Equal(trimText(a), trimText(b))
+}
+
+// trimText strips trailing spaces, tabs, and carriage returns from each
+// line of content, and the empty lines at its end.
+func trimText(content []byte) []byte {
+	lines := bytes.Split(content, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// SameTextAs downloads the file at path and reports whether it only
+// differs from content as SameText allows.
+func SameTextAs(ctx context.Context, conn connector.Connector, path string, content []byte) (bool, error) {
+	var buf bytes.Buffer
+	if err := conn.Download(ctx, path, &buf); err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return SameText(buf.Bytes(), content), nil
+}
+
+// SplitLines splits file content into lines, without the line break that
+// ends the last one.
+func SplitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// JoinLines joins lines into file content, ending each with a line break.
+func JoinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,117 @@
+package module
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestContentParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]any
+		want    ContentOptions
+		wantErr bool
+	}{
+		{"defaults", map[string]any{}, ContentOptions{}, false},
+		{"lf", map[string]any{"newline": "lf"}, ContentOptions{Newline: NewlineLF}, false},
+		{"crlf and ignore", map[string]any{"newline": "crlf", "ignore_whitespace": true}, ContentOptions{Newline: NewlineCRLF, IgnoreWhitespace: true}, false},
+		{"unknown newline", map[string]any{"newline": "cr"}, ContentOptions{}, true},
+		{"ignore not bool", map[string]any{"ignore_whitespace": "maybe"}, ContentOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ContentParams(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ContentParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentConvert(t *testing.T) {
+	tests := []struct {
+		newline string
+		content string
+		want    string
+	}{
+		{"", "a\r\nb\n", "a\r\nb\n"},
+		{NewlineLF, "a\r\nb\n", "a\nb\n"},
+		{NewlineCRLF, "a\nb\n", "a\r\nb\r\n"},
+		{NewlineCRLF, "a\r\nb\n", "a\r\nb\r\n"},
+	}
+
+	for _, tt := range tests {
+		got := ContentOptions{Newline: tt.newline}.Convert([]byte(tt.content))
+		if string(got) != tt.want {
+			t.Errorf("Convert(%q) with newline %q = %q, want %q", tt.content, tt.newline, got, tt.want)
+		}
+	}
+}
+
+func TestSameText(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"a\nb\n", "a\nb\n", true},
+		{"a\nb\n", "a\r\nb\r\n", true},
+		{"a  \nb\t\n", "a\nb\n", true},
+		{"a\nb", "a\nb\n\n", true},
+		{"a\nb\n", "a\n b\n", false},
+		{"a\n\nb\n", "a\nb\n", false},
+		{"a\nb\n", "a\nc\n", false},
+	}
+
+	for _, tt := range tests {
+		if got := SameText([]byte(tt.a), []byte(tt.b)); got != tt.want {
+			t.Errorf("SameText(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSameTextAs(t *testing.T) {
+	conn := connectortest.New()
+	conn.SetFile("/etc/app.conf", []byte("key = value\r\n"), 0o644)
+
+	same, err := SameTextAs(context.Background(), conn, "/etc/app.conf", []byte("key = value\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !same {
+		t.Error("SameTextAs() = false, want true for a line ending difference")
+	}
+
+	if _, err := SameTextAs(context.Background(), conn, "/etc/missing.conf", nil); err == nil {
+		t.Error("SameTextAs() of a missing file succeeded, want an error")
+	}
+}
+
+func TestSplitJoinLines(t *testing.T) {
+	tests := []struct {
+		content string
+		lines   []string
+		joined  string
+	}{
+		{"", nil, ""},
+		{"a\n", []string{"a"}, "a\n"},
+		{"a\nb", []string{"a", "b"}, "a\nb\n"},
+		{"a\n\nb\n", []string{"a", "", "b"}, "a\n\nb\n"},
+	}
+
+	for _, tt := range tests {
+		lines := SplitLines(tt.content)
+		if !slices.Equal(lines, tt.lines) {
+			t.Errorf("SplitLines(%q) = %q, want %q", tt.content, lines, tt.lines)
+		}
+		if got := JoinLines(lines); got != tt.joined {
+			t.Errorf("JoinLines(%q) = %q, want %q", lines, got, tt.joined)
+		}
+	}
+}
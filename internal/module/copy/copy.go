@@ -2,7 +2,6 @@
 package copy
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -14,6 +13,8 @@ import (
 
 	"github.com/eugenetaranov/bolt/internal/connector"
 	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
 )
 
 func init() {
@@ -28,52 +29,111 @@ func (m *Module) Name() string {
 	return "copy"
 }
 
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	params := []module.ParamSpec{
+		{Name: "dest", Type: "string", Required: true, Description: "Destination path"},
+		{Name: "src", Type: "string", Description: "Source file path"},
+		{Name: "content", Type: "string", Description: "Inline content to write"},
+		{Name: "mode", Type: "string/int", Default: "0644", Description: "File permissions: octal, symbolic, or `preserve` to keep the mode of `src`"},
+		{Name: "owner", Type: "string", Description: "Owner username"},
+		{Name: "group", Type: "string", Description: "Group name"},
+		{Name: "backup", Type: "bool", Default: false, Description: "Create backup before overwriting"},
+		{Name: "force", Type: "bool", Default: true, Description: "Overwrite if exists"},
+		{Name: "create_dirs", Type: "bool", Default: false, Description: "Create parent directories"},
+		{Name: "validate", Type: "string", Description: "Validation command (`%s` = temp path)"},
+		{Name: "preserve_xattrs", Type: "bool", Default: true, Description: "Keep the extended attributes of a replaced file"},
+		{Name: "compress", Type: "bool", Default: false, Description: "Compress the file in transfer"},
+		{Name: "delta", Type: "bool", Default: false, Description: "Only transfer the changes to an existing file, with rsync"},
+	}
+	params = append(params, module.ContentParamSpecs()...)
+	params = append(params, module.BackupParamSpecs()...)
+	params = append(params, module.SELinuxParamSpecs()...)
+	return params
+}
+
 // Run executes the copy module.
 //
 // Parameters:
 //   - dest (string, required): Destination path on the target
 //   - src (string): Source file path on the controller (mutually exclusive with content)
 //   - content (string): Inline content to write (mutually exclusive with src)
-//   - mode (string): File permissions in octal (e.g., "0644")
+//   - mode (string|int): File permissions, octal (e.g., "0644") or symbolic (e.g., "u+x,g-w"),
+//     or "preserve" to keep the mode of src (default: 0644)
 //   - owner (string): Owner username
 //   - group (string): Group name
 //   - backup (bool): Create backup before overwriting (default: false)
+//   - backup_dir (string): Directory to write backups to (default: next to dest)
+//   - backup_keep (int): Number of backups to keep, 0 for all (default: 0)
 //   - force (bool): Overwrite even if destination exists (default: true)
 //   - create_dirs (bool): Create parent directories if needed (default: false)
 //   - validate (string): Command to validate file before finalizing (%s = temp file path)
+//   - seuser, serole, setype, selevel (string): SELinux context of the file
+//   - preserve_xattrs (bool): Keep the extended attributes of a replaced file (default: true)
+//   - compress (bool): Compress the file in transfer (default: false)
+//   - delta (bool): Send only the changes to an existing dest with rsync, if
+//     the connection supports it and rsync is installed on both ends (default: false)
+//   - newline (string): Convert line endings to "lf" or "crlf" before writing;
+//     a src file is then read into memory instead of streamed
+//   - ignore_whitespace (bool): Leave dest unchanged if it only differs in
+//     trailing whitespace and line endings (default: false)
 func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
 	// Extract parameters
-	dest, err := requireString(params, "dest")
+	dest, err := param.Required(params, "dest")
 	if err != nil {
 		return nil, err
 	}
 
-	src := getString(params, "src", "")
-	content := getString(params, "content", "")
-	mode := getString(params, "mode", "0644")
-	owner := getString(params, "owner", "")
-	group := getString(params, "group", "")
-	backup := getBool(params, "backup", false)
-	force := getBool(params, "force", true)
-	createDirs := getBool(params, "create_dirs", false)
-	validate := getString(params, "validate", "")
+	src := param.String(params, "src", "")
+	content := param.String(params, "content", "")
+	preserve := param.String(params, "mode", "") == param.ModePreserve
+	var mode string
+	if !preserve {
+		if mode, err = param.Mode(params, "mode", "0644"); err != nil {
+			return nil, err
+		}
+	}
+	owner := param.String(params, "owner", "")
+	group := param.String(params, "group", "")
+	backup := param.Bool(params, "backup", false)
+	backupOpts, err := module.BackupParams(params)
+	if err != nil {
+		return nil, err
+	}
+	force := param.Bool(params, "force", true)
+	createDirs := param.Bool(params, "create_dirs", false)
+	validate := param.String(params, "validate", "")
+	secontext := module.SELinuxParams(params)
+	preserveXattrs := param.Bool(params, "preserve_xattrs", true)
+	compress := param.Bool(params, "compress", false)
+	delta := param.Bool(params, "delta", false)
+	contentOpts, err := module.ContentParams(params)
+	if err != nil {
+		return nil, err
+	}
+	check := module.IsCheckMode(params)
 
 	// Validate parameters
 	if src == "" && content == "" {
-		return nil, fmt.Errorf("either 'src' or 'content' parameter is required")
+		return nil, module.ParamErrorf("src", "either 'src' or 'content' parameter is required")
 	}
 	if src != "" && content != "" {
 		return nil, fmt.Errorf("'src' and 'content' are mutually exclusive")
 	}
+	if preserve && src == "" {
+		return nil, module.ParamErrorf("mode", "mode 'preserve' requires 'src'")
+	}
 
-	// Get source content
+	// Get the checksum of the source. A source file is streamed from disk
+	// when it is uploaded, so large files are never held in memory.
 	var srcContent []byte
+	var srcPath, srcChecksum string
 	if src != "" {
 		// Resolve source path - check if it's relative and we have a role path
-		srcPath := src
+		srcPath = src
 		if !filepath.IsAbs(src) {
 			// Check for role path (injected by executor for role tasks)
-			if rolePath := getString(params, "_role_path", ""); rolePath != "" {
+			if rolePath := param.String(params, "_role_path", ""); rolePath != "" {
 				// Look in role's files directory
 				roleFilePath := filepath.Join(rolePath, "files", src)
 				if _, err := os.Stat(roleFilePath); err == nil {
@@ -82,18 +142,30 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 			}
 		}
 
-		// Read from local file
-		data, err := os.ReadFile(srcPath)
-		if err != nil {
+		if preserve {
+			info, err := os.Stat(srcPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read source file '%s': %w", srcPath, err)
+			}
+			mode = octalMode(info.Mode())
+		}
+
+		if contentOpts.Newline != "" {
+			// Converting line endings needs the content in memory
+			if srcContent, err = os.ReadFile(srcPath); err != nil {
+				return nil, fmt.Errorf("failed to read source file '%s': %w", srcPath, err)
+			}
+			srcPath = ""
+		} else if srcChecksum, err = module.FileChecksum(srcPath); err != nil {
 			return nil, fmt.Errorf("failed to read source file '%s': %w", srcPath, err)
 		}
-		srcContent = data
 	} else {
 		srcContent = []byte(content)
 	}
-
-	// Calculate checksum of source
-	srcChecksum := checksum(srcContent)
+	if srcPath == "" {
+		srcContent = contentOpts.Convert(srcContent)
+		srcChecksum = checksum(srcContent)
+	}
 
 	// Check if destination exists and compare checksums
 	destExists, destChecksum, err := getRemoteChecksum(ctx, conn, dest)
@@ -101,15 +173,31 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		return nil, fmt.Errorf("failed to check destination: %w", err)
 	}
 
+	// With ignore_whitespace, a destination that only differs in
+	// whitespace counts as having the same content
+	same := destExists && srcChecksum == destChecksum
+	if destExists && !same && contentOpts.IgnoreWhitespace {
+		if same, err = sameText(ctx, conn, dest, srcContent, srcPath); err != nil {
+			return nil, fmt.Errorf("failed to compare destination: %w", err)
+		}
+	}
+
 	// If destination exists with same content, check if we need to update mode/owner
-	if destExists && srcChecksum == destChecksum {
+	if same {
 		// File content matches, check attributes
-		attrChanged, err := ensureAttributes(ctx, conn, dest, mode, owner, group)
+		before, after, err := ensureAttributes(ctx, conn, dest, mode, owner, group, check)
 		if err != nil {
 			return nil, err
 		}
-		if attrChanged {
-			return module.Changed("attributes updated"), nil
+		contextChanged, err := module.EnsureSELinuxContext(ctx, conn, dest, secontext, false, false, check)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set SELinux context: %w", err)
+		}
+		if len(after) > 0 || contextChanged {
+			if check {
+				return module.Changed("attributes would be updated").WithDiff(before, after), nil
+			}
+			return module.Changed("attributes updated").WithDiff(before, after), nil
 		}
 		return module.Unchanged("file already exists with correct content and attributes"), nil
 	}
@@ -119,6 +207,42 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		return module.Unchanged("destination exists and force=false"), nil
 	}
 
+	// The mode the file is written with
+	newMode, err := uploadMode(ctx, conn, dest, mode, destExists)
+	if err != nil {
+		return nil, err
+	}
+
+	// Describe the change by checksum, and in diff mode by content
+	var before map[string]any
+	after := map[string]any{"checksum": srcChecksum}
+	if destExists {
+		before = map[string]any{"checksum": destChecksum}
+	} else {
+		after["mode"] = newMode
+
+		// A new file goes to the connecting user with
+		// become_keep_ownership, not the become user
+		if owner == "" {
+			owner = module.DefaultOwner(params)
+		}
+		if owner != "" {
+			after["owner"] = owner
+		}
+	}
+	if module.IsDiff(params) {
+		if err := addContent(ctx, conn, dest, srcContent, srcPath, before, after); err != nil {
+			return nil, err
+		}
+	}
+
+	if check {
+		if destExists {
+			return module.Changed("file would be updated").WithDiff(before, after), nil
+		}
+		return module.Changed("file would be created").WithDiff(before, after), nil
+	}
+
 	// Create parent directories if needed
 	if createDirs {
 		if err := createParentDirs(ctx, conn, dest); err != nil {
@@ -127,12 +251,22 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	}
 
 	// Create backup if needed
+	var backupFile string
 	if destExists && backup {
-		if err := createBackup(ctx, conn, dest); err != nil {
+		if backupFile, err = module.Backup(ctx, conn, dest, backupOpts); err != nil {
 			return nil, fmt.Errorf("failed to create backup: %w", err)
 		}
 	}
 
+	// Save the extended attributes (including the SELinux label) of the
+	// file being replaced, since the upload writes a new file
+	var xattrs string
+	if destExists && preserveXattrs {
+		if xattrs, err = module.SaveXattrs(ctx, conn, dest); err != nil {
+			return nil, fmt.Errorf("failed to read extended attributes: %w", err)
+		}
+	}
+
 	// Upload to temp file first if validation is needed
 	targetPath := dest
 	if validate != "" {
@@ -140,44 +274,51 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	}
 
 	// Upload the file
-	modeInt, err := parseMode(mode)
+	modeInt, err := parseMode(newMode)
 	if err != nil {
 		return nil, fmt.Errorf("invalid mode: %w", err)
 	}
 
-	if err := conn.Upload(ctx, bytes.NewReader(srcContent), targetPath, modeInt); err != nil {
+	transfer, err := upload(ctx, conn, srcContent, srcPath, srcChecksum, dest, targetPath, modeInt, compress, delta && destExists)
+	if err != nil {
 		return nil, fmt.Errorf("failed to upload file: %w", err)
 	}
 
 	// Run validation if specified
 	if validate != "" {
-		validateCmd := strings.ReplaceAll(validate, "%s", shellQuote(targetPath))
+		validateCmd := strings.ReplaceAll(validate, "%s", shellutil.Quote(targetPath))
 		result, err := conn.Execute(ctx, validateCmd)
 		if err != nil {
 			// Clean up temp file (ignore error)
-			_, _ = conn.Execute(ctx, fmt.Sprintf("rm -f %s", shellQuote(targetPath)))
+			_, _ = conn.Execute(ctx, fmt.Sprintf("rm -f %s", shellutil.Quote(targetPath)))
 			return nil, fmt.Errorf("validation command failed: %w", err)
 		}
 		if result.ExitCode != 0 {
 			// Clean up temp file (ignore error)
-			_, _ = conn.Execute(ctx, fmt.Sprintf("rm -f %s", shellQuote(targetPath)))
-			return nil, fmt.Errorf("validation failed: %s", result.Stderr)
+			_, _ = conn.Execute(ctx, fmt.Sprintf("rm -f %s", shellutil.Quote(targetPath)))
+			return nil, module.CommandFailedf(result, "validation failed")
 		}
 
 		// Move temp file to destination
-		result, err = conn.Execute(ctx, fmt.Sprintf("mv %s %s", shellQuote(targetPath), shellQuote(dest)))
+		result, err = conn.Execute(ctx, fmt.Sprintf("mv %s %s", shellutil.Quote(targetPath), shellutil.Quote(dest)))
 		if err != nil {
 			return nil, fmt.Errorf("failed to move validated file: %w", err)
 		}
 		if result.ExitCode != 0 {
-			return nil, fmt.Errorf("failed to move validated file: %s", result.Stderr)
+			return nil, module.CommandFailedf(result, "failed to move validated file")
 		}
 	}
 
 	// Set attributes
-	if _, err := ensureAttributes(ctx, conn, dest, mode, owner, group); err != nil {
+	if err := module.RestoreXattrs(ctx, conn, xattrs); err != nil {
 		return nil, err
 	}
+	if _, _, err := ensureAttributes(ctx, conn, dest, mode, owner, group, false); err != nil {
+		return nil, err
+	}
+	if _, err := module.EnsureSELinuxContext(ctx, conn, dest, secontext, !destExists, false, false); err != nil {
+		return nil, fmt.Errorf("failed to set SELinux context: %w", err)
+	}
 
 	var msg string
 	if destExists {
@@ -186,10 +327,113 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		msg = "file created"
 	}
 
-	return module.ChangedWithData(msg, map[string]any{
+	data := map[string]any{
 		"dest":     dest,
 		"checksum": srcChecksum,
-	}), nil
+		"transfer": transfer,
+	}
+	if backupFile != "" {
+		data["backup_file"] = backupFile
+	}
+	return module.ChangedWithData(msg, data).WithDiff(before, after), nil
+}
+
+// addContent adds the content of dest to before, if it exists, and that
+// of the source to after, for a diff.
+func addContent(ctx context.Context, conn connector.Connector, dest string, content []byte, srcPath string, before, after map[string]any) error {
+	var err error
+	if before != nil {
+		if before["content"], err = module.ReadForDiff(ctx, conn, dest); err != nil {
+			return err
+		}
+	}
+	if srcPath != "" {
+		after["content"], err = module.ReadFileForDiff(srcPath)
+		return err
+	}
+	after["content"] = module.DiffText(content)
+	return nil
+}
+
+// sameText reports whether dest only differs from the source in trailing
+// whitespace and line endings. The source is the local file srcPath, or
+// else the inline content.
+func sameText(ctx context.Context, conn connector.Connector, dest string, content []byte, srcPath string) (bool, error) {
+	if srcPath != "" {
+		var err error
+		if content, err = os.ReadFile(srcPath); err != nil {
+			return false, err
+		}
+	}
+	return module.SameTextAs(ctx, conn, dest, content)
+}
+
+// upload transfers the source to targetPath, which is dest or a temp file
+// for validation, and returns how: "delta", "compressed", or "full". The
+// source is the local file srcPath, streamed from disk, or else the inline
+// content; sum is its checksum.
+func upload(ctx context.Context, conn connector.Connector, content []byte, srcPath, sum, dest, targetPath string, mode uint32, compress, delta bool) (string, error) {
+	if delta {
+		ok, err := uploadDelta(ctx, conn, content, srcPath, sum, dest, targetPath, mode, compress)
+		if ok || err != nil {
+			return "delta", err
+		}
+	}
+	if compress {
+		var ok bool
+		var err error
+		if srcPath != "" {
+			ok, err = module.UploadFileCompressed(ctx, conn, srcPath, sum, targetPath, mode)
+		} else {
+			ok, err = module.UploadCompressed(ctx, conn, content, targetPath, mode)
+		}
+		if ok || err != nil {
+			return "compressed", err
+		}
+		return "full", nil
+	}
+	if srcPath != "" {
+		return "full", module.UploadFileVerified(ctx, conn, srcPath, sum, targetPath, mode)
+	}
+	return "full", module.UploadVerified(ctx, conn, content, targetPath, mode)
+}
+
+// uploadDelta transfers the source with rsync as a delta against dest.
+// Inline content is written to a local temp file first, and a temp
+// targetPath starts as a copy of dest so rsync has something to compare
+// against.
+func uploadDelta(ctx context.Context, conn connector.Connector, content []byte, srcPath, sum, dest, targetPath string, mode uint32, compress bool) (bool, error) {
+	if _, ok := connector.As[connector.Rsyncer](conn); !ok {
+		return false, nil
+	}
+
+	if srcPath == "" {
+		f, err := os.CreateTemp("", "bolt-copy-*")
+		if err != nil {
+			return false, err
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.Write(content); err != nil {
+			f.Close()
+			return false, err
+		}
+		if err := f.Close(); err != nil {
+			return false, err
+		}
+		srcPath = f.Name()
+	}
+
+	if targetPath != dest {
+		result, err := conn.Execute(ctx, fmt.Sprintf("cp %s %s", shellutil.Quote(dest), shellutil.Quote(targetPath)))
+		if err != nil {
+			return false, err
+		}
+		if result.ExitCode != 0 {
+			return false, module.CommandFailedf(result, "failed to copy %s", dest)
+		}
+	}
+
+	return module.UploadDelta(ctx, conn, srcPath, sum, targetPath, mode, compress)
 }
 
 // checksum calculates SHA256 checksum of data.
@@ -211,7 +455,7 @@ func getRemoteChecksum(ctx context.Context, conn connector.Connector, path strin
 		fi
 	else
 		echo "NO_FILE"
-	fi`, shellQuote(path))
+	fi`, shellutil.Quote(path))
 
 	result, err := conn.Execute(ctx, cmd)
 	if err != nil {
@@ -230,33 +474,47 @@ func getRemoteChecksum(ctx context.Context, conn connector.Connector, path strin
 	}
 }
 
-// ensureAttributes sets mode and ownership on a file, only if they differ from desired.
-func ensureAttributes(ctx context.Context, conn connector.Connector, path, mode, owner, group string) (bool, error) {
-	var changed bool
-
+// ensureAttributes sets mode and ownership on a file, only if they differ
+// from desired, and returns the attributes that differed, as they were and
+// as they are now. With check set it only reports them.
+func ensureAttributes(ctx context.Context, conn connector.Connector, path, mode, owner, group string, check bool) (before, after map[string]any, err error) {
 	// Get current attributes
 	currentMode, currentOwner, currentGroup, err := getFileAttributes(ctx, conn, path)
 	if err != nil {
-		return false, fmt.Errorf("failed to get file attributes: %w", err)
+		return nil, nil, fmt.Errorf("failed to get file attributes: %w", err)
 	}
+	before, after = map[string]any{}, map[string]any{}
 
-	// Set mode only if different
-	if mode != "" && currentMode != mode {
-		result, err := conn.Execute(ctx, fmt.Sprintf("chmod %s %s", mode, shellQuote(path)))
-		if err != nil {
-			return false, fmt.Errorf("failed to set mode: %w", err)
-		}
-		if result.ExitCode != 0 {
-			return false, fmt.Errorf("chmod failed: %s", result.Stderr)
+	// Set mode only if different; a symbolic mode applies to the
+	// current one
+	want, err := param.ResolveMode(mode, currentMode, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if want != currentMode {
+		before["mode"], after["mode"] = currentMode, want
+		if !check {
+			result, err := connector.ExecuteArgv(ctx, conn, "chmod", want, path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to set mode: %w", err)
+			}
+			if result.ExitCode != 0 {
+				return nil, nil, module.CommandFailedf(result, "chmod failed")
+			}
 		}
-		changed = true
 	}
 
 	// Set ownership only if different
 	needOwnerChange := owner != "" && currentOwner != owner
 	needGroupChange := group != "" && currentGroup != group
+	if needOwnerChange {
+		before["owner"], after["owner"] = currentOwner, owner
+	}
+	if needGroupChange {
+		before["group"], after["group"] = currentGroup, group
+	}
 
-	if needOwnerChange || needGroupChange {
+	if (needOwnerChange || needGroupChange) && !check {
 		var ownership string
 		if owner != "" && group != "" {
 			ownership = fmt.Sprintf("%s:%s", owner, group)
@@ -266,31 +524,45 @@ func ensureAttributes(ctx context.Context, conn connector.Connector, path, mode,
 			ownership = fmt.Sprintf(":%s", group)
 		}
 
-		result, err := conn.Execute(ctx, fmt.Sprintf("chown %s %s", ownership, shellQuote(path)))
+		result, err := connector.ExecuteArgv(ctx, conn, "chown", ownership, path)
 		if err != nil {
-			return false, fmt.Errorf("failed to set ownership: %w", err)
+			return nil, nil, fmt.Errorf("failed to set ownership: %w", err)
 		}
 		if result.ExitCode != 0 {
-			return false, fmt.Errorf("chown failed: %s", result.Stderr)
+			return nil, nil, module.CommandFailedf(result, "chown failed")
 		}
-		changed = true
 	}
 
-	return changed, nil
+	return before, after, nil
+}
+
+// uploadMode returns the octal mode dest is written with: mode, or for a
+// symbolic mode, mode applied to the mode of dest if it exists and to 0644
+// otherwise.
+func uploadMode(ctx context.Context, conn connector.Connector, dest, mode string, destExists bool) (string, error) {
+	base := "0644"
+	if destExists && param.IsSymbolicMode(mode) {
+		current, _, _, err := getFileAttributes(ctx, conn, dest)
+		if err != nil {
+			return "", fmt.Errorf("failed to get file attributes: %w", err)
+		}
+		base = current
+	}
+	return param.ResolveMode(mode, base, false)
 }
 
 // getFileAttributes returns the mode, owner, and group of a file.
 func getFileAttributes(ctx context.Context, conn connector.Connector, path string) (mode, owner, group string, err error) {
 	// Use stat to get file attributes in a portable way
 	// Format: mode owner group (e.g., "0644 root wheel")
-	cmd := fmt.Sprintf(`stat -c '%%a %%U %%G' %[1]s 2>/dev/null || stat -f '%%Lp %%Su %%Sg' %[1]s`, shellQuote(path))
+	cmd := fmt.Sprintf(`stat -c '%%a %%U %%G' %[1]s 2>/dev/null || stat -f '%%Lp %%Su %%Sg' %[1]s`, shellutil.Quote(path))
 
 	result, err := conn.Execute(ctx, cmd)
 	if err != nil {
 		return "", "", "", err
 	}
 	if result.ExitCode != 0 {
-		return "", "", "", fmt.Errorf("stat failed: %s", result.Stderr)
+		return "", "", "", module.CommandFailedf(result, "stat failed")
 	}
 
 	parts := strings.Fields(strings.TrimSpace(result.Stdout))
@@ -310,30 +582,31 @@ func getFileAttributes(ctx context.Context, conn connector.Connector, path strin
 // createParentDirs creates parent directories for a path.
 func createParentDirs(ctx context.Context, conn connector.Connector, path string) error {
 	// Extract directory from path
-	cmd := fmt.Sprintf("mkdir -p \"$(dirname %s)\"", shellQuote(path))
+	cmd := fmt.Sprintf("mkdir -p \"$(dirname %s)\"", shellutil.Quote(path))
 	result, err := conn.Execute(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("failed to create parent directories: %w", err)
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("mkdir failed: %s", result.Stderr)
+		return module.CommandFailedf(result, "mkdir failed")
 	}
 	return nil
 }
 
-// createBackup creates a timestamped backup of a file.
-func createBackup(ctx context.Context, conn connector.Connector, path string) error {
-	timestamp := time.Now().Format("20060102150405")
-	backupPath := fmt.Sprintf("%s.%s.bak", path, timestamp)
-
-	result, err := conn.Execute(ctx, fmt.Sprintf("cp -p %s %s", shellQuote(path), shellQuote(backupPath)))
-	if err != nil {
-		return err
+// octalMode returns the permissions of a file mode, special bits included,
+// in the form getFileAttributes reads them (e.g., "0755").
+func octalMode(m os.FileMode) string {
+	perm := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		perm |= 0o4000
 	}
-	if result.ExitCode != 0 {
-		return fmt.Errorf("backup failed: %s", result.Stderr)
+	if m&os.ModeSetgid != 0 {
+		perm |= 0o2000
 	}
-	return nil
+	if m&os.ModeSticky != 0 {
+		perm |= 0o1000
+	}
+	return fmt.Sprintf("%04o", perm)
 }
 
 // parseMode converts an octal mode string to uint32.
@@ -352,51 +625,13 @@ func parseMode(mode string) (uint32, error) {
 	return m, nil
 }
 
-// shellQuote quotes a string for safe use in shell commands.
-func shellQuote(s string) string {
-	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
-}
-
-// Helper functions for parameter extraction
-
-func requireString(params map[string]any, key string) (string, error) {
-	v, ok := params[key]
-	if !ok {
-		return "", fmt.Errorf("required parameter '%s' is missing", key)
-	}
-	s, ok := v.(string)
-	if !ok {
-		return "", fmt.Errorf("parameter '%s' must be a string", key)
-	}
-	if s == "" {
-		return "", fmt.Errorf("parameter '%s' cannot be empty", key)
-	}
-	return s, nil
-}
-
-func getString(params map[string]any, key, defaultValue string) string {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	s, ok := v.(string)
-	if !ok {
-		return defaultValue
-	}
-	return s
-}
-
-func getBool(params map[string]any, key string, defaultValue bool) bool {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	b, ok := v.(bool)
-	if !ok {
-		return defaultValue
-	}
-	return b
+// SupportsCheckMode reports that copy can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
 }
 
 // Ensure Module implements the module.Module interface.
-var _ module.Module = (*Module)(nil)
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
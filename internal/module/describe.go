@@ -0,0 +1,69 @@
+package module
+
+// ParamSpec describes a module parameter.
+type ParamSpec struct {
+	// Name is the parameter name.
+	Name string
+
+	// Type is the YAML type of the value: string, bool, int, list, or map.
+	// Parameters that accept several types list them separated by "/"
+	// (e.g., string/list).
+	Type string
+
+	// Required marks parameters a task cannot omit. Parameters that are
+	// only required in combination with others are not marked.
+	Required bool
+
+	// Default is the value used when the parameter is omitted, if any.
+	Default any
+
+	// Choices are the accepted values of a string parameter.
+	Choices []string
+
+	// Description is a one-line description of the parameter.
+	Description string
+}
+
+// Describer is implemented by modules that describe their parameters. The
+// descriptions are used for editor tooling (bolt schema); they do not
+// change how parameters are parsed.
+type Describer interface {
+	Params() []ParamSpec
+}
+
+// Params returns the parameters of m, or nil if m does not describe them.
+func Params(m Module) []ParamSpec {
+	if d, ok := m.(Describer); ok {
+		return d.Params()
+	}
+	return nil
+}
+
+// BackupParamSpecs describes the backup_dir and backup_keep parameters
+// read by BackupParams.
+func BackupParamSpecs() []ParamSpec {
+	return []ParamSpec{
+		{Name: "backup_dir", Type: "string", Description: "Directory for backups (default: next to `dest`)"},
+		{Name: "backup_keep", Type: "int", Default: 0, Description: "Number of backups to keep; older ones are removed (`0` keeps all)"},
+	}
+}
+
+// ContentParamSpecs describes the newline and ignore_whitespace parameters
+// read by ContentParams.
+func ContentParamSpecs() []ParamSpec {
+	return []ParamSpec{
+		{Name: "newline", Type: "string", Choices: []string{NewlineLF, NewlineCRLF}, Description: "Convert line endings before writing the file"},
+		{Name: "ignore_whitespace", Type: "bool", Default: false, Description: "Leave a file that only differs in trailing whitespace and line endings unchanged"},
+	}
+}
+
+// SELinuxParamSpecs describes the seuser, serole, setype, and selevel
+// parameters read by SELinuxParams.
+func SELinuxParamSpecs() []ParamSpec {
+	return []ParamSpec{
+		{Name: "seuser", Type: "string", Description: "SELinux user (e.g., `system_u`)"},
+		{Name: "serole", Type: "string", Description: "SELinux role (e.g., `object_r`)"},
+		{Name: "setype", Type: "string", Description: "SELinux type (e.g., `httpd_sys_content_t`)"},
+		{Name: "selevel", Type: "string", Description: "SELinux level (e.g., `s0`)"},
+	}
+}
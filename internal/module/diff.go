@@ -0,0 +1,91 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// DiffParam is set to true in the parameters when a task runs in diff mode
+// (bolt run --diff). Modules always report the attributes they change in
+// Result.Before and Result.After, but only read the content of the files
+// they replace in diff mode, since that takes an extra download.
+const DiffParam = "_diff"
+
+// IsDiff reports whether params request diff mode.
+func IsDiff(params map[string]any) bool {
+	diff, _ := params[DiffParam].(bool)
+	return diff
+}
+
+// MaxDiffSize is the largest file content shown in a diff. Larger files,
+// like binary ones, are shown as a placeholder.
+const MaxDiffSize = 64 * 1024
+
+// DiffText returns content as text for Result.Before or Result.After, or a
+// placeholder if it is binary or larger than MaxDiffSize.
+func DiffText(content []byte) string {
+	if len(content) > MaxDiffSize {
+		return fmt.Sprintf("(%d bytes, too large to diff)", len(content))
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		return fmt.Sprintf("(binary, %d bytes)", len(content))
+	}
+	return string(content)
+}
+
+// errDiffTooLarge stops a download that exceeds MaxDiffSize.
+var errDiffTooLarge = errors.New("file too large to diff")
+
+// limitWriter is a bytes.Buffer that fails writes past MaxDiffSize.
+// Connectors do not all wrap the errors of the writer they download to,
+// so it also records that it is full.
+type limitWriter struct {
+	bytes.Buffer
+	full bool
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > MaxDiffSize {
+		w.full = true
+		return 0, errDiffTooLarge
+	}
+	return w.Buffer.Write(p)
+}
+
+// ReadForDiff downloads the file at path for a diff and returns it as
+// DiffText does. A file larger than MaxDiffSize is not downloaded in full.
+func ReadForDiff(ctx context.Context, conn connector.Connector, path string) (string, error) {
+	var w limitWriter
+	if err := conn.Download(ctx, path, &w); err != nil {
+		if w.full {
+			return fmt.Sprintf("(larger than %d bytes, too large to diff)", MaxDiffSize), nil
+		}
+		return "", fmt.Errorf("failed to read %s for diff: %w", path, err)
+	}
+	return DiffText(w.Bytes()), nil
+}
+
+// ReadFileForDiff reads the local file at path for a diff and returns it
+// as DiffText does. A file larger than MaxDiffSize is not read in full.
+func ReadFileForDiff(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var w limitWriter
+	if _, err := io.Copy(&w, f); err != nil {
+		if w.full {
+			return fmt.Sprintf("(larger than %d bytes, too large to diff)", MaxDiffSize), nil
+		}
+		return "", err
+	}
+	return DiffText(w.Bytes()), nil
+}
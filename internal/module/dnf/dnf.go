@@ -0,0 +1,414 @@
+// Package dnf provides a module for managing packages on RedHat-family
+// systems (Fedora, RHEL, CentOS, Rocky, AlmaLinux) with dnf or yum.
+package dnf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired package state.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure package is installed
+	StateAbsent  State = "absent"  // Ensure package is not installed
+	StateLatest  State = "latest"  // Ensure package is installed and up-to-date
+)
+
+// checkUpdateAvailable is the exit code of dnf check-update when updates
+// are available.
+const checkUpdateAvailable = 100
+
+// Module manages packages with dnf, or yum where dnf is not installed.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "dnf"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string/list", Description: "Package name(s)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent", "latest"}, Description: "Desired state"},
+		{Name: "update_cache", Type: "bool", Default: false, Description: "Run `dnf makecache` first"},
+		{Name: "enablerepo", Type: "string/list", Description: "Repositories to enable for this task"},
+		{Name: "disablerepo", Type: "string/list", Description: "Repositories to disable for this task"},
+		{Name: "autoremove", Type: "bool", Default: false, Description: "Remove unused dependencies"},
+		{Name: "mirror", Type: "string", Description: "Base URL of a package mirror the official repositories are pointed at"},
+		{Name: "mirror_hosts", Type: "list", Description: "Hosts of the repositories replaced by `mirror` (default: the Fedora, CentOS, Rocky Linux, and AlmaLinux repositories; `*` wildcards)"},
+	}
+}
+
+// Run executes the dnf module.
+//
+// Parameters:
+//   - name (string|[]string): Package name(s) to manage
+//   - state (string): Desired state - present, absent, latest (default: present)
+//   - update_cache (bool): Run dnf makecache before operations (default: false)
+//   - enablerepo (string|[]string): Repositories to enable, globs allowed
+//   - disablerepo (string|[]string): Repositories to disable, globs allowed
+//   - autoremove (bool): Remove packages installed as unused dependencies (default: false)
+//   - mirror (string): Base URL of a package mirror; repositories of mirror_hosts are pointed at it
+//   - mirror_hosts ([]string): Hosts replaced by mirror, with * wildcards (default: the
+//     official Fedora, CentOS, Rocky Linux, and AlmaLinux repositories)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	dnf, err := findDnf(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	updateCache := param.Bool(params, "update_cache", false)
+	autoremove := param.Bool(params, "autoremove", false)
+	mirror := param.String(params, "mirror", "")
+	mirrorHosts := param.StringList(params, "mirror_hosts")
+	checkMode := module.IsCheckMode(params)
+	offline := module.IsOffline(params)
+
+	// Validate state
+	switch state {
+	case StatePresent, StateAbsent, StateLatest:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present, absent, or latest", state)
+	}
+	if mirror != "" {
+		if mirror, err = module.ParseMirror(mirror); err != nil {
+			return nil, module.ParamErrorf("mirror", "%v", err)
+		}
+	}
+	if len(mirrorHosts) == 0 {
+		mirrorHosts = defaultMirrorHosts
+	}
+
+	// Repository options apply to every dnf command of the task
+	var opts []string
+	for _, repo := range param.StringList(params, "enablerepo") {
+		opts = append(opts, "--enablerepo="+shellutil.Quote(repo))
+	}
+	for _, repo := range param.StringList(params, "disablerepo") {
+		opts = append(opts, "--disablerepo="+shellutil.Quote(repo))
+	}
+	dnf = strings.Join(append([]string{dnf}, opts...), " ")
+	online := dnf
+	if offline {
+		// Offline, dnf must only use the metadata already on the host
+		dnf += " -C"
+	}
+
+	var changed bool
+	var messages []string
+
+	// Point the repositories at the mirror before anything reads them
+	var mirrored []string
+	if mirror != "" {
+		if mirrored, err = applyMirror(ctx, conn, mirror, mirrorHosts, checkMode); err != nil {
+			return nil, err
+		}
+	}
+	if len(mirrored) > 0 {
+		verb := "pointed"
+		if checkMode {
+			verb = "would point"
+		}
+		messages = append(messages, fmt.Sprintf("%s %s at %s", verb, strings.Join(mirrored, ", "), mirror))
+		changed = true
+	}
+
+	// Update metadata cache if requested, or if the repositories changed,
+	// since the metadata of the old ones is useless. Offline, the metadata
+	// on the host is used as it is, but a mirror is inside the network.
+	if updateCache && offline && len(mirrored) == 0 {
+		messages = append(messages, "cache update skipped (offline)")
+	} else if (updateCache || len(mirrored) > 0) && checkMode {
+		messages = append(messages, "would update cache")
+		changed = true
+	} else if updateCache || len(mirrored) > 0 {
+		if err := runMakecache(ctx, conn, online); err != nil {
+			return nil, fmt.Errorf("failed to update cache: %w", err)
+		}
+		messages = append(messages, "cache updated")
+		changed = true
+	}
+
+	names := param.StringList(params, "name")
+	if len(names) == 0 {
+		if !updateCache && !autoremove && mirror == "" {
+			return nil, module.ParamErrorf("name", "'name' parameter is required when not using update_cache, autoremove, or mirror")
+		}
+		if autoremove {
+			removed, err := runAutoremove(ctx, conn, dnf, checkMode)
+			if err != nil {
+				return nil, err
+			}
+			if removed {
+				messages = append(messages, autoremoveMessage(checkMode))
+				changed = true
+			}
+		}
+		if changed {
+			return module.Changed(strings.Join(messages, ", ")), nil
+		}
+		if len(messages) > 0 {
+			return module.Unchanged(strings.Join(messages, ", ")), nil
+		}
+		return module.Unchanged("no changes needed"), nil
+	}
+
+	// Get currently installed packages
+	installed, err := getInstalledPackages(ctx, conn, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installed packages: %w", err)
+	}
+
+	var toInstall, toRemove, toUpgrade []string
+
+	for _, name := range names {
+		isInstalled := installed[name]
+
+		switch state {
+		case StatePresent:
+			if !isInstalled {
+				toInstall = append(toInstall, name)
+			}
+		case StateAbsent:
+			if isInstalled {
+				toRemove = append(toRemove, name)
+			}
+		case StateLatest:
+			if !isInstalled {
+				toInstall = append(toInstall, name)
+			} else {
+				toUpgrade = append(toUpgrade, name)
+			}
+		}
+	}
+
+	// Only installed packages with a newer version are upgraded
+	var upgradable []string
+	if len(toUpgrade) > 0 {
+		upgradable, err = getUpgradablePackages(ctx, conn, dnf, toUpgrade)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if checkMode {
+		if len(toInstall) > 0 {
+			messages = append(messages, fmt.Sprintf("would install: %s", strings.Join(toInstall, ", ")))
+		}
+		if len(toRemove) > 0 {
+			messages = append(messages, fmt.Sprintf("would remove: %s", strings.Join(toRemove, ", ")))
+		}
+		if len(upgradable) > 0 {
+			messages = append(messages, fmt.Sprintf("would upgrade: %s", strings.Join(upgradable, ", ")))
+		}
+		if len(toInstall)+len(toRemove)+len(upgradable) > 0 {
+			changed = true
+		}
+	} else {
+		// Install packages
+		if len(toInstall) > 0 {
+			if err := runDnf(ctx, conn, dnf, "install", toInstall); err != nil {
+				return nil, err
+			}
+			messages = append(messages, fmt.Sprintf("installed: %s", strings.Join(toInstall, ", ")))
+			changed = true
+		}
+
+		// Remove packages
+		if len(toRemove) > 0 {
+			if err := runDnf(ctx, conn, dnf, "remove", toRemove); err != nil {
+				return nil, err
+			}
+			messages = append(messages, fmt.Sprintf("removed: %s", strings.Join(toRemove, ", ")))
+			changed = true
+		}
+
+		// Upgrade packages that have newer versions available
+		if len(upgradable) > 0 {
+			if err := runDnf(ctx, conn, dnf, "upgrade", upgradable); err != nil {
+				return nil, err
+			}
+			messages = append(messages, fmt.Sprintf("upgraded: %s", strings.Join(upgradable, ", ")))
+			changed = true
+		}
+	}
+
+	// Handle autoremove
+	if autoremove {
+		removed, err := runAutoremove(ctx, conn, dnf, checkMode)
+		if err != nil {
+			return nil, err
+		}
+		if removed {
+			messages = append(messages, autoremoveMessage(checkMode))
+			changed = true
+		}
+	}
+
+	if !changed {
+		return module.Unchanged("packages already in desired state"), nil
+	}
+
+	return module.Changed(strings.Join(messages, "; ")), nil
+}
+
+// findDnf returns the package manager command of the target: dnf, or yum
+// on older releases that do not have dnf.
+func findDnf(ctx context.Context, conn connector.Connector) (string, error) {
+	result, err := conn.Execute(ctx, "command -v dnf || command -v yum")
+	if err != nil {
+		return "", fmt.Errorf("failed to check for dnf: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("neither dnf nor yum is available (not a RedHat-family system?)")
+	}
+	if strings.HasSuffix(strings.TrimSpace(result.Stdout), "/yum") {
+		return "yum", nil
+	}
+	return "dnf", nil
+}
+
+// runMakecache refreshes the repository metadata.
+func runMakecache(ctx context.Context, conn connector.Connector, dnf string) error {
+	result, err := module.RpmMutex.Execute(ctx, conn, dnf+" -q -y makecache")
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "%s makecache failed", dnf)
+	}
+	return nil
+}
+
+// getInstalledPackages returns which of the given packages are installed.
+func getInstalledPackages(ctx context.Context, conn connector.Connector, names []string) (map[string]bool, error) {
+	// rpm -q prints "package x is not installed" for missing packages and
+	// exits non-zero, so only keep lines that are names we asked for
+	cmd := fmt.Sprintf(`rpm -q --qf '%%{NAME}\n' %s 2>/dev/null || true`, shellutil.Join(names...))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := make(map[string]bool)
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		name := strings.TrimSpace(line)
+		if name != "" && !strings.Contains(name, " ") {
+			installed[name] = true
+		}
+	}
+
+	return installed, nil
+}
+
+// getUpgradablePackages returns the given installed packages that have a
+// newer version in the enabled repositories.
+func getUpgradablePackages(ctx context.Context, conn connector.Connector, dnf string, names []string) ([]string, error) {
+	cmd := fmt.Sprintf("%s -q check-update %s", dnf, shellutil.Join(names...))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	switch result.ExitCode {
+	case 0:
+		return nil, nil
+	case checkUpdateAvailable:
+	default:
+		return nil, module.CommandFailedf(result, "%s check-update failed", dnf)
+	}
+
+	// Format: name.arch  version  repository
+	outdated := make(map[string]bool)
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		if idx := strings.LastIndex(fields[0], "."); idx > 0 {
+			outdated[fields[0][:idx]] = true
+		}
+	}
+
+	var upgradable []string
+	for _, name := range names {
+		if outdated[name] {
+			upgradable = append(upgradable, name)
+		}
+	}
+	return upgradable, nil
+}
+
+// runDnf runs a dnf subcommand non-interactively for the given packages.
+func runDnf(ctx context.Context, conn connector.Connector, dnf, action string, names []string) error {
+	cmd := fmt.Sprintf("%s -y %s %s", dnf, action, shellutil.Join(names...))
+
+	result, err := module.RpmMutex.Execute(ctx, conn, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to %s packages: %w", action, err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "%s %s failed", dnf, action)
+	}
+
+	return nil
+}
+
+// runAutoremove removes packages installed as dependencies that nothing
+// needs anymore. In check mode it only reports whether there are any.
+func runAutoremove(ctx context.Context, conn connector.Connector, dnf string, checkMode bool) (bool, error) {
+	cmd := dnf + " -y autoremove"
+	if checkMode {
+		// --assumeno lists the transaction and answers no
+		cmd = dnf + " --assumeno autoremove"
+		result, err := conn.Execute(ctx, cmd)
+		if err != nil {
+			return false, fmt.Errorf("failed to check autoremove: %w", err)
+		}
+		return strings.Contains(result.Stdout, "Removing"), nil
+	}
+
+	result, err := module.RpmMutex.Execute(ctx, conn, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to autoremove: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, module.CommandFailedf(result, "%s autoremove failed", dnf)
+	}
+
+	return strings.Contains(result.Stdout, "Removed") || strings.Contains(result.Stdout, "Removing"), nil
+}
+
+// autoremoveMessage describes a removal of unused dependencies.
+func autoremoveMessage(checkMode bool) string {
+	if checkMode {
+		return "would autoremove unused dependencies"
+	}
+	return "autoremove completed"
+}
+
+// SupportsCheckMode reports that dnf can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module and module.CheckModer interfaces.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
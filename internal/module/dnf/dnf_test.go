@@ -0,0 +1,180 @@
+package dnf
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const rpmQuery = `rpm -q --qf '%{NAME}\n' 'nginx' 'git' 'tmux' 2>/dev/null || true`
+
+// newConn returns a fake target with dnf on which git and tmux are
+// installed.
+func newConn() *connectortest.Connector {
+	conn := connectortest.New()
+	conn.On("command -v dnf || command -v yum").Return("/usr/bin/dnf\n")
+	conn.On(rpmQuery).Return("package nginx is not installed\ngit\ntmux\n")
+	return conn
+}
+
+func TestGetInstalledPackages(t *testing.T) {
+	conn := newConn()
+
+	installed, err := getInstalledPackages(context.Background(), conn, []string{"nginx", "git", "tmux"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]bool{"git": true, "tmux": true}
+	if !reflect.DeepEqual(installed, want) {
+		t.Errorf("getInstalledPackages() = %v, want %v", installed, want)
+	}
+}
+
+func TestGetUpgradablePackages(t *testing.T) {
+	tests := []struct {
+		name   string
+		exit   int
+		stdout string
+		want   []string
+	}{
+		{"up to date", 0, "", nil},
+		{
+			name: "updates",
+			exit: checkUpdateAvailable,
+			stdout: "\ngit.x86_64                2.43.5-1.el9       appstream\n" +
+				"git-core.x86_64           2.43.5-1.el9       appstream\n" +
+				"Obsoleting Packages\n",
+			want: []string{"git"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			conn.On("dnf -q check-update 'git' 'tmux'").ReturnResult(connector.Result{ExitCode: tt.exit, Stdout: tt.stdout})
+
+			got, err := getUpgradablePackages(context.Background(), conn, "dnf", []string{"git", "tmux"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getUpgradablePackages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	conn := connectortest.New()
+	conn.On("dnf -q check-update 'git'").Fail(1, "Error: Failed to download metadata")
+	if _, err := getUpgradablePackages(context.Background(), conn, "dnf", []string{"git"}); err == nil {
+		t.Error("getUpgradablePackages() succeeded when check-update failed")
+	}
+}
+
+func TestRunInstall(t *testing.T) {
+	conn := newConn()
+	conn.OnMatch(`dnf -y install .*nginx`).Return("").Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"name": []any{"nginx", "git", "tmux"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed || result.Message != "installed: nginx" {
+		t.Errorf("result = %v %q, want nginx installed", result.Changed, result.Message)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunAlreadyInstalled(t *testing.T) {
+	conn := newConn()
+	conn.On(`rpm -q --qf '%{NAME}\n' 'git' 2>/dev/null || true`).Return("git\n")
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"name": "git"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("Changed = true, want false (%s)", result.Message)
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   string
+	}{
+		{"install", map[string]any{"name": []any{"nginx", "git", "tmux"}}, "would install: nginx"},
+		{"remove", map[string]any{"name": []any{"nginx", "git", "tmux"}, "state": "absent"}, "would remove: git, tmux"},
+		{"update cache", map[string]any{"update_cache": true, "name": []any{"nginx", "git", "tmux"}}, "would update cache; would install: nginx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Commands that change the host are not scripted and fail
+			conn := newConn()
+			tt.params[module.CheckModeParam] = true
+
+			result, err := (&Module{}).Run(context.Background(), conn, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Changed || result.Message != tt.want {
+				t.Errorf("result = %v %q, want %q", result.Changed, result.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCheckModeLatest(t *testing.T) {
+	conn := newConn()
+	conn.On(`rpm -q --qf '%{NAME}\n' 'git' 'tmux' 2>/dev/null || true`).Return("git\ntmux\n")
+	conn.On("dnf -q check-update 'git' 'tmux'").ReturnResult(connector.Result{ExitCode: checkUpdateAvailable, Stdout: "tmux.x86_64  3.2a-5.el9  baseos\n"})
+
+	params := map[string]any{"name": []any{"git", "tmux"}, "state": "latest", module.CheckModeParam: true}
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed || result.Message != "would upgrade: tmux" {
+		t.Errorf("result = %v %q, want tmux upgraded", result.Changed, result.Message)
+	}
+}
+
+func TestRunOffline(t *testing.T) {
+	conn := newConn()
+	conn.OnMatch(`dnf -C -y install .*nginx`).Return("").Once()
+
+	params := map[string]any{"name": []any{"nginx", "git", "tmux"}, "update_cache": true, module.OfflineParam: true}
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result.Message, "cache update skipped (offline)") {
+		t.Errorf("message = %q, want the cache update skipped", result.Message)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunInvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]any
+	}{
+		{"bad state", map[string]any{"name": "git", "state": "purged"}},
+		{"no name", map[string]any{}},
+		{"bad mirror", map[string]any{"mirror": "mirror.corp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&Module{}).Run(context.Background(), newConn(), tt.params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
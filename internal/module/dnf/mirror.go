@@ -0,0 +1,138 @@
+package dnf
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// defaultMirrorHosts are the hosts of the official Fedora, CentOS, Rocky
+// Linux, and AlmaLinux repositories, which a mirror replaces unless
+// mirror_hosts is set. Fedora's repository files use download.example as
+// the placeholder host of their commented baseurl.
+var defaultMirrorHosts = []string{
+	"download.example",
+	"download.fedoraproject.org",
+	"dl.fedoraproject.org",
+	"mirror.centos.org",
+	"mirror.stream.centos.org",
+	"vault.centos.org",
+	"dl.rockylinux.org",
+	"repo.almalinux.org",
+}
+
+// repoFiles lists the repository files of the target.
+const repoFiles = "ls -1 /etc/yum.repos.d/*.repo 2>/dev/null"
+
+// baseurlPattern matches a baseurl option, commented out or not, and
+// captures its URLs.
+var baseurlPattern = regexp.MustCompile(`^#?\s*baseurl\s*=\s*(.*)$`)
+
+// applyMirror points the repositories of the target that use one of hosts
+// at mirror and returns the files it rewrote. In check mode it only
+// returns the files it would rewrite.
+func applyMirror(ctx context.Context, conn connector.Connector, mirror string, hosts []string, check bool) ([]string, error) {
+	result, err := conn.Execute(ctx, repoFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository files: %w", err)
+	}
+
+	var rewritten []string
+	for _, file := range strings.Fields(result.Stdout) {
+		result, err := conn.Execute(ctx, "cat "+shellutil.Quote(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		if result.ExitCode != 0 {
+			return nil, module.CommandFailedf(result, "failed to read %s", file)
+		}
+
+		content, n := rewriteRepos(result.Stdout, mirror, hosts)
+		if n == 0 {
+			continue
+		}
+		if !check {
+			if err := module.UploadVerified(ctx, conn, []byte(content), file, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", file, err)
+			}
+		}
+		rewritten = append(rewritten, file)
+	}
+	return rewritten, nil
+}
+
+// rewriteRepos points the repositories of a .repo file whose baseurl uses
+// one of hosts at mirror and returns the new content and the number of
+// repositories changed. The baseurl is enabled if it is commented out, as
+// it is in the files of most distributions, and the metalink and
+// mirrorlist options are commented out, since dnf prefers them.
+func rewriteRepos(content, mirror string, hosts []string) (string, int) {
+	lines := strings.Split(content, "\n")
+	count := 0
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "[") {
+			continue
+		}
+		if mirrorRepo(lines[start:i], mirror, hosts) {
+			count++
+		}
+		start = i
+	}
+	return strings.Join(lines, "\n"), count
+}
+
+// mirrorRepo points the repository section lines at mirror in place and
+// reports whether it changed them.
+func mirrorRepo(lines []string, mirror string, hosts []string) bool {
+	// An active baseurl wins over one that is commented out
+	base := -1
+	for i, line := range lines {
+		if baseurlPattern.MatchString(strings.TrimSpace(line)) && (base < 0 || commented(lines[base]) && !commented(line)) {
+			base = i
+		}
+	}
+	if base < 0 {
+		return false
+	}
+
+	m := baseurlPattern.FindStringSubmatch(strings.TrimSpace(lines[base]))
+	urls := strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	matched := false
+	for i, u := range urls {
+		if mirrored, _ := module.MirrorURL(u, mirror, hosts); mirrored != "" {
+			urls[i] = mirrored
+			matched = true
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	changed := false
+	if line := "baseurl=" + strings.Join(urls, " "); line != lines[base] {
+		lines[base] = line
+		changed = true
+	}
+	for i, line := range lines {
+		key, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		switch strings.TrimSpace(key) {
+		case "metalink", "mirrorlist":
+			if ok {
+				lines[i] = "#" + line
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// commented reports whether line is a comment.
+func commented(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
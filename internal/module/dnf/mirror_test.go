@@ -0,0 +1,139 @@
+package dnf
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+// rockyBaseOS is a repository as Rocky Linux ships it.
+const rockyBaseOS = `[baseos]
+name=Rocky Linux $releasever - BaseOS
+mirrorlist=https://mirrors.rockylinux.org/mirrorlist?arch=$basearch&repo=BaseOS-$releasever$rltype
+#baseurl=http://dl.rockylinux.org/$contentdir/$releasever/BaseOS/$basearch/os/
+gpgcheck=1
+enabled=1
+
+[baseos-debuginfo]
+name=Rocky Linux $releasever - BaseOS - Debug
+mirrorlist=https://mirrors.rockylinux.org/mirrorlist?arch=$basearch&repo=BaseOS-$releasever-debug$rltype
+#baseurl=http://dl.rockylinux.org/$contentdir/$releasever/BaseOS/$basearch/debug/tree/
+enabled=0
+`
+
+func TestRewriteRepos(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		count   int
+	}{
+		{
+			name:    "commented baseurl",
+			content: rockyBaseOS,
+			want: `[baseos]
+name=Rocky Linux $releasever - BaseOS
+#mirrorlist=https://mirrors.rockylinux.org/mirrorlist?arch=$basearch&repo=BaseOS-$releasever$rltype
+baseurl=http://mirror.corp/rpm/$contentdir/$releasever/BaseOS/$basearch/os/
+gpgcheck=1
+enabled=1
+
+[baseos-debuginfo]
+name=Rocky Linux $releasever - BaseOS - Debug
+#mirrorlist=https://mirrors.rockylinux.org/mirrorlist?arch=$basearch&repo=BaseOS-$releasever-debug$rltype
+baseurl=http://mirror.corp/rpm/$contentdir/$releasever/BaseOS/$basearch/debug/tree/
+enabled=0
+`,
+			count: 2,
+		},
+		{
+			name: "fedora metalink",
+			content: "[fedora]\nmetalink=https://mirrors.fedoraproject.org/metalink?repo=fedora-$releasever&arch=$basearch\n" +
+				"#baseurl=http://download.example/pub/fedora/linux/releases/$releasever/Everything/$basearch/os/\n",
+			want: "[fedora]\n#metalink=https://mirrors.fedoraproject.org/metalink?repo=fedora-$releasever&arch=$basearch\n" +
+				"baseurl=http://mirror.corp/rpm/pub/fedora/linux/releases/$releasever/Everything/$basearch/os/\n",
+			count: 1,
+		},
+		{
+			name:    "active baseurl wins",
+			content: "[appstream]\n#baseurl=http://dl.rockylinux.org/old/\nbaseurl = https://repo.almalinux.org/almalinux/9/AppStream/\n",
+			want:    "[appstream]\n#baseurl=http://dl.rockylinux.org/old/\nbaseurl=http://mirror.corp/rpm/almalinux/9/AppStream/\n",
+			count:   1,
+		},
+		{
+			name:    "third-party repository",
+			content: "[docker-ce-stable]\nbaseurl=https://download.docker.com/linux/centos/$releasever/$basearch/stable\n",
+			want:    "[docker-ce-stable]\nbaseurl=https://download.docker.com/linux/centos/$releasever/$basearch/stable\n",
+		},
+		{
+			name:    "no baseurl",
+			content: "[epel]\nmetalink=https://mirrors.fedoraproject.org/metalink?repo=epel-9&arch=$basearch\n",
+			want:    "[epel]\nmetalink=https://mirrors.fedoraproject.org/metalink?repo=epel-9&arch=$basearch\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := rewriteRepos(tt.content, "http://mirror.corp/rpm", defaultMirrorHosts)
+			if got != tt.want {
+				t.Errorf("rewriteRepos() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if count != tt.count {
+				t.Errorf("count = %d, want %d", count, tt.count)
+			}
+
+			// Rewriting the result again changes nothing
+			if again, count := rewriteRepos(got, "http://mirror.corp/rpm", defaultMirrorHosts); again != got || count != 0 {
+				t.Errorf("second rewrite changed %d repositories:\n%s", count, again)
+			}
+		})
+	}
+}
+
+func TestRunMirror(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("command -v dnf || command -v yum").Return("/usr/bin/dnf\n")
+	conn.On(repoFiles).Return("/etc/yum.repos.d/rocky.repo\n/etc/yum.repos.d/docker-ce.repo\n")
+	conn.On("cat '/etc/yum.repos.d/rocky.repo'").Return(rockyBaseOS)
+	conn.On("cat '/etc/yum.repos.d/docker-ce.repo'").Return("[docker-ce-stable]\nbaseurl=https://download.docker.com/linux/centos/9/x86_64/stable\n")
+	conn.OnPrefix("if command -v sha256sum").Return("")
+	conn.OnMatch(`dnf -q -y makecache`).Return("").Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"mirror": "http://mirror.corp/rpm/", module.OfflineParam: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed || !strings.HasPrefix(result.Message, "pointed /etc/yum.repos.d/rocky.repo at http://mirror.corp/rpm") {
+		t.Errorf("result = %v %q, want the Rocky repositories pointed at the mirror", result.Changed, result.Message)
+	}
+	written, _ := conn.File("/etc/yum.repos.d/rocky.repo")
+	if !strings.Contains(string(written), "baseurl=http://mirror.corp/rpm/$contentdir/") {
+		t.Errorf("rocky.repo = %q, want the mirror baseurl", written)
+	}
+	if _, ok := conn.File("/etc/yum.repos.d/docker-ce.repo"); ok {
+		t.Error("third-party repository was rewritten")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunMirrorCheckMode(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("command -v dnf || command -v yum").Return("/usr/bin/dnf\n")
+	conn.On(repoFiles).Return("/etc/yum.repos.d/rocky.repo\n")
+	conn.On("cat '/etc/yum.repos.d/rocky.repo'").Return(rockyBaseOS)
+
+	params := map[string]any{"mirror": "http://mirror.corp/rpm", module.CheckModeParam: true}
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed || result.Message != "would point /etc/yum.repos.d/rocky.repo at http://mirror.corp/rpm, would update cache" {
+		t.Errorf("result = %v %q", result.Changed, result.Message)
+	}
+	if _, ok := conn.File("/etc/yum.repos.d/rocky.repo"); ok {
+		t.Error("check mode rewrote the repository file")
+	}
+}
@@ -0,0 +1,171 @@
+// Package dock provides a module for managing the macOS Dock with dockutil.
+package dock
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a Dock item.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the item is in the Dock
+	StateAbsent  State = "absent"  // Ensure the item is not in the Dock
+)
+
+// slotPattern extracts the slot from `dockutil --find` output, e.g.
+// "Safari was found in persistent-apps at slot 3 in /Users/me/Library/Preferences/com.apple.dock.plist".
+var slotPattern = regexp.MustCompile(`at slot (\d+)`)
+
+// Module manages macOS Dock items.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "dock"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "path", Type: "string", Description: "Application or folder to add"},
+		{Name: "label", Type: "string", Description: "Label of the Dock item"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "position", Type: "string/int", Description: "Slot number, `beginning`, or `end`"},
+		{Name: "section", Type: "string", Default: "apps", Choices: []string{"apps", "others"}, Description: "Dock section"},
+		{Name: "restart", Type: "bool", Default: true, Description: "Restart the Dock after changes"},
+	}
+}
+
+// Run executes the dock module.
+//
+// Parameters:
+//   - path (string): Application or folder to add (required when state=present)
+//   - label (string): Label of the Dock item (default: path basename without extension)
+//   - state (string): Desired state - present, absent (default: present)
+//   - position (string|int): Slot number, or beginning/end (default: end for new items)
+//   - section (string): apps, others (default: apps)
+//   - restart (bool): Restart the Dock after changes (default: true)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	path := param.String(params, "path", "")
+	label := param.String(params, "label", "")
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	position := param.String(params, "position", "")
+	section := param.String(params, "section", "apps")
+	restart := param.Bool(params, "restart", true)
+
+	switch state {
+	case StatePresent:
+		if path == "" {
+			return nil, module.ParamErrorf("path", "'path' parameter is required when state=present")
+		}
+	case StateAbsent:
+		if path == "" && label == "" {
+			return nil, module.ParamErrorf("label", "'label' or 'path' parameter is required when state=absent")
+		}
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	if section != "apps" && section != "others" {
+		return nil, module.ParamErrorf("section", "invalid section '%s': must be apps or others", section)
+	}
+
+	if label == "" {
+		base := filepath.Base(path)
+		label = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	if err := checkDockutil(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	found, slot, err := findItem(ctx, conn, label)
+	if err != nil {
+		return nil, err
+	}
+
+	noRestart := ""
+	if !restart {
+		noRestart = " --no-restart"
+	}
+
+	if state == StateAbsent {
+		if !found {
+			return module.Unchanged(fmt.Sprintf("%s not in Dock", label)), nil
+		}
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("dockutil --remove %s%s", shellutil.Quote(label), noRestart)); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed %s from Dock", label)), nil
+	}
+
+	if !found {
+		cmd := fmt.Sprintf("dockutil --add %s --label %s --section %s", shellutil.Quote(path), shellutil.Quote(label), section)
+		if position != "" {
+			cmd += " --position " + shellutil.Quote(position)
+		}
+		if err := module.RunCommand(ctx, conn, cmd+noRestart); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("added %s to Dock", label)), nil
+	}
+
+	// Only numeric positions can be compared with the current slot
+	if want, err := strconv.Atoi(position); err == nil && want != slot {
+		cmd := fmt.Sprintf("dockutil --move %s --position %d", shellutil.Quote(label), want)
+		if err := module.RunCommand(ctx, conn, cmd+noRestart); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("moved %s to slot %d", label, want)), nil
+	}
+
+	return module.Unchanged(fmt.Sprintf("%s already in Dock", label)), nil
+}
+
+// checkDockutil verifies that dockutil is installed.
+func checkDockutil(ctx context.Context, conn connector.Connector) error {
+	result, err := conn.Execute(ctx, "command -v dockutil")
+	if err != nil {
+		return fmt.Errorf("failed to check for dockutil: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("dockutil is not installed (brew install dockutil)")
+	}
+	return nil
+}
+
+// findItem looks up a Dock item by label and returns its slot.
+func findItem(ctx context.Context, conn connector.Connector, label string) (bool, int, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("dockutil --find %s", shellutil.Quote(label)))
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to query Dock: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, 0, nil
+	}
+
+	var slot int
+	if m := slotPattern.FindStringSubmatch(result.Stdout); m != nil {
+		slot, _ = strconv.Atoi(m[1])
+	}
+	return true, slot, nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
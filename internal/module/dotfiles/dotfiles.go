@@ -0,0 +1,627 @@
+// Package dotfiles provides a module for cloning a dotfiles repository and
+// linking its files into the home directory.
+package dotfiles
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module clones a dotfiles repository and links its files into the home
+// directory the way GNU stow does.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "dotfiles"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "repo", Type: "string", Required: true, Description: "URL of the dotfiles repository"},
+		{Name: "dest", Type: "string", Default: "~/.dotfiles", Description: "Directory to clone the repository into"},
+		{Name: "version", Type: "string", Description: "Branch, tag, or commit to check out (default: the default branch)"},
+		{Name: "update", Type: "bool", Default: true, Description: "Pull new commits into an existing clone"},
+		{Name: "packages", Type: "list", Description: "Packages (top-level directories) to link, or . for the whole repository (default: all)"},
+		{Name: "target", Type: "string", Default: "~", Description: "Directory to link the files into"},
+		{Name: "dot_prefix", Type: "bool", Default: false, Description: "Link files named dot-name as .name, like stow --dotfiles"},
+		{Name: "conflict", Type: "string", Default: "fail", Choices: []string{"fail", "backup", "overwrite", "skip"}, Description: "What to do with files in the way of a link"},
+		{Name: "backup_dir", Type: "string", Description: "Directory to move files in the way to (default: next to the file)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state of the links"},
+	}
+}
+
+// Run executes the dotfiles module.
+//
+// The repository uses the layout of GNU stow: every top-level directory is
+// a package whose tree mirrors the home directory, so bash/.bashrc links
+// to ~/.bashrc and nvim/.config/nvim/init.lua to ~/.config/nvim/init.lua.
+// Every file gets its own relative link, like stow --no-folding, so
+// programs writing into ~/.config do not write into the repository, and
+// stow can take over the links later. Files that stow ignores by default,
+// such as README.* and .git, are not linked. git must be installed on
+// the target.
+//
+// Parameters:
+//   - repo (string, required): URL of the dotfiles repository
+//   - dest (string): Directory to clone the repository into (default: ~/.dotfiles)
+//   - version (string): Branch, tag, or commit to check out (default: the default branch)
+//   - update (bool): Pull new commits into an existing clone (default: true)
+//   - packages ([]string): Packages to link, or . for the whole repository (default: all)
+//   - target (string): Directory to link the files into (default: ~)
+//   - dot_prefix (bool): Link files named dot-name as .name (default: false)
+//   - conflict (string): fail, backup, overwrite, or skip (default: fail)
+//   - backup_dir (string): Directory to move files in the way to (default: next to the file)
+//   - state (string): present links the files, absent removes the links (default: present)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	repo, err := param.Required(params, "repo")
+	if err != nil {
+		return nil, err
+	}
+	dest := param.String(params, "dest", "~/.dotfiles")
+	version := param.String(params, "version", "")
+	update := param.Bool(params, "update", true)
+	packages := param.StringList(params, "packages")
+	target := param.String(params, "target", "~")
+	dotPrefix := param.Bool(params, "dot_prefix", false)
+	conflict := param.String(params, "conflict", "fail")
+	backupDir := param.String(params, "backup_dir", "")
+	state := param.String(params, "state", "present")
+	check := module.IsCheckMode(params)
+	offline := module.IsOffline(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	switch conflict {
+	case "fail", "backup", "overwrite", "skip":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("conflict", "invalid conflict '%s': must be fail, backup, overwrite, or skip", conflict)
+	}
+	for _, pkg := range packages {
+		if pkg != "." && (pkg == "" || strings.Contains(pkg, "/") || strings.HasPrefix(pkg, ".")) {
+			return nil, module.ParamErrorf("packages", "invalid package '%s': must be a top-level directory of the repository, or .", pkg)
+		}
+	}
+
+	home, err := module.HomeDir(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	dest = expandHome(dest, home)
+	target = expandHome(target, home)
+	if backupDir != "" {
+		backupDir = expandHome(backupDir, home)
+	}
+	if !path.IsAbs(dest) || !path.IsAbs(target) {
+		return nil, fmt.Errorf("dest and target must be absolute paths or start with ~/")
+	}
+
+	g := &git{conn: conn, dir: dest}
+	head, err := g.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == "absent" {
+		if head == "" {
+			return module.Unchanged(fmt.Sprintf("%s is not cloned", dest)), nil
+		}
+		return unlink(ctx, conn, dest, target, packages, dotPrefix, check)
+	}
+
+	var messages []string
+	before := map[string]any{}
+	after := map[string]any{}
+	if head == "" {
+		if check {
+			return module.Changed(fmt.Sprintf("would clone %s to %s and link its packages", repo, dest)).
+				WithDiff(nil, map[string]any{"repo": repo}), nil
+		}
+		if offline {
+			return nil, module.OfflineErrorf("cloning "+repo, "run the task once with network access")
+		}
+		if head, err = g.clone(ctx, repo, version); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("cloned %s", repo))
+		after["commit"] = head
+	} else {
+		if err := g.checkOrigin(ctx, repo); err != nil {
+			return nil, err
+		}
+		switch {
+		case update && offline:
+			messages = append(messages, "update skipped (offline)")
+		case update && check:
+			remote, err := g.remoteCommit(ctx, version)
+			if err != nil {
+				return nil, err
+			}
+			if remote != "" && !strings.HasPrefix(head, remote) {
+				messages = append(messages, fmt.Sprintf("would update %s to %s", dest, short(remote)))
+				before["commit"], after["commit"] = head, remote
+			}
+		case update:
+			current, err := g.pull(ctx, version)
+			if err != nil {
+				return nil, err
+			}
+			if current != head {
+				messages = append(messages, fmt.Sprintf("updated %s to %s", dest, short(current)))
+				before["commit"], after["commit"] = head, current
+				head = current
+			}
+		}
+	}
+
+	links, err := findLinks(ctx, conn, dest, target, packages, dotPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := readStates(ctx, conn, links); err != nil {
+		return nil, err
+	}
+
+	var script []string
+	var linked, conflicts, skipped []string
+	beforeLinks := map[string]any{}
+	afterLinks := map[string]any{}
+	stamp := time.Now().Format("20060102150405")
+	for _, l := range links {
+		if l.linked() {
+			continue
+		}
+		if l.state != "absent" {
+			switch conflict {
+			case "fail":
+				conflicts = append(conflicts, l.dst)
+				continue
+			case "skip":
+				skipped = append(skipped, l.dst)
+				continue
+			case "backup":
+				dir := backupDir
+				if dir == "" {
+					dir = path.Dir(l.dst)
+				}
+				backup := path.Join(dir, fmt.Sprintf("%s.%s.bak", path.Base(l.dst), stamp))
+				script = append(script, fmt.Sprintf("mkdir -p %s && mv %s %s",
+					shellutil.Quote(dir), shellutil.Quote(l.dst), shellutil.Quote(backup)))
+			case "overwrite":
+				script = append(script, "rm -rf "+shellutil.Quote(l.dst))
+			}
+		}
+		script = append(script, fmt.Sprintf("mkdir -p %s && ln -s %s %s",
+			shellutil.Quote(path.Dir(l.dst)), shellutil.Quote(l.rel()), shellutil.Quote(l.dst)))
+		linked = append(linked, l.dst)
+		beforeLinks[l.dst] = l.state
+		afterLinks[l.dst] = "link to " + l.src
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("files in the way of links (set conflict to backup, overwrite, or skip): %s", strings.Join(conflicts, ", "))
+	}
+
+	if len(linked) > 0 {
+		before["links"], after["links"] = beforeLinks, afterLinks
+		if check {
+			messages = append(messages, fmt.Sprintf("would link %d file(s)", len(linked)))
+		} else {
+			result, err := conn.Execute(ctx, "set -e\n"+strings.Join(script, "\n"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to link dotfiles: %w", err)
+			}
+			if result.ExitCode != 0 {
+				return nil, module.CommandFailedf(result, "failed to link dotfiles")
+			}
+			messages = append(messages, fmt.Sprintf("linked %d file(s)", len(linked)))
+		}
+	}
+	if len(skipped) > 0 {
+		messages = append(messages, fmt.Sprintf("skipped %d file(s) in the way: %s", len(skipped), strings.Join(skipped, ", ")))
+	}
+
+	data := map[string]any{"dest": dest, "commit": head, "linked": linked, "skipped": skipped}
+	if len(before) == 0 && len(after) == 0 {
+		msg := "dotfiles are linked"
+		if len(messages) > 0 {
+			msg = strings.Join(messages, "; ")
+		}
+		return &module.Result{Message: msg, Data: data}, nil
+	}
+	result := module.ChangedWithData(strings.Join(messages, "; "), data)
+	return result.WithDiff(before, after), nil
+}
+
+// unlink removes the links to the files of packages.
+func unlink(ctx context.Context, conn connector.Connector, dest, target string, packages []string, dotPrefix, check bool) (*module.Result, error) {
+	links, err := findLinks(ctx, conn, dest, target, packages, dotPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := readStates(ctx, conn, links); err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	before := map[string]any{}
+	for _, l := range links {
+		// Links through a folded directory are left to stow
+		if strings.HasPrefix(l.state, "link ") && l.linked() {
+			removed = append(removed, l.dst)
+			before[l.dst] = "link to " + l.src
+		}
+	}
+	if len(removed) == 0 {
+		return module.Unchanged("dotfiles are not linked"), nil
+	}
+	diffBefore := map[string]any{"links": before}
+	if check {
+		return module.Changed(fmt.Sprintf("would remove %d link(s)", len(removed))).WithDiff(diffBefore, nil), nil
+	}
+
+	var quoted []string
+	for _, dst := range removed {
+		quoted = append(quoted, shellutil.Quote(dst))
+	}
+	result, err := conn.Execute(ctx, "rm -f "+strings.Join(quoted, " "))
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove links: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "failed to remove links")
+	}
+	return module.ChangedWithData(fmt.Sprintf("removed %d link(s)", len(removed)), map[string]any{"removed": removed}).
+		WithDiff(diffBefore, nil), nil
+}
+
+// link is a file of the repository and the link to it.
+type link struct {
+	// src is the path of the file in the clone.
+	src string
+
+	// dst is the path of the link.
+	dst string
+
+	// state is what is at dst: absent, file, directory, folded (dst is the
+	// file itself, through a directory linked into the clone), or
+	// "link <target>".
+	state string
+}
+
+// rel returns the target of the link: src relative to the directory of
+// dst, as stow writes it.
+func (l *link) rel() string {
+	return relPath(path.Dir(l.dst), l.src)
+}
+
+// linked reports whether dst already leads to src.
+func (l *link) linked() bool {
+	if l.state == "folded" {
+		return true
+	}
+	target, ok := strings.CutPrefix(l.state, "link ")
+	if !ok {
+		return false
+	}
+	return target == l.src || target == l.rel()
+}
+
+// findLinks returns the links to the files of packages in the clone at
+// dest, or of all packages if there are none.
+func findLinks(ctx context.Context, conn connector.Connector, dest, target string, packages []string, dotPrefix bool) ([]*link, error) {
+	if len(packages) == 0 {
+		result, err := conn.Execute(ctx, fmt.Sprintf(`find %s -mindepth 1 -maxdepth 1 -type d ! -name '.*'`, shellutil.Quote(dest)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list packages: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return nil, module.CommandFailedf(result, "failed to list packages")
+		}
+		for _, line := range strings.Split(result.Stdout, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				packages = append(packages, path.Base(line))
+			}
+		}
+		sort.Strings(packages)
+	}
+
+	var links []*link
+	owner := map[string]string{}
+	for _, pkg := range packages {
+		dir := path.Join(dest, pkg)
+		result, err := conn.Execute(ctx, fmt.Sprintf(`cd %s && find . -name .git -prune -o \( -type f -o -type l \) -print`, shellutil.Quote(dir)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the files of %s: %w", pkg, err)
+		}
+		if result.ExitCode != 0 {
+			return nil, module.CommandFailedf(result, "package %s not found in %s", pkg, dest)
+		}
+		var files []string
+		for _, line := range strings.Split(result.Stdout, "\n") {
+			file := strings.TrimPrefix(strings.TrimSpace(line), "./")
+			if file != "" && !ignored(file) {
+				files = append(files, file)
+			}
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			dst := path.Join(target, targetName(file, dotPrefix))
+			if other, ok := owner[dst]; ok {
+				return nil, fmt.Errorf("packages %s and %s both have %s", other, pkg, dst)
+			}
+			owner[dst] = pkg
+			links = append(links, &link{src: path.Join(dir, file), dst: dst})
+		}
+	}
+	return links, nil
+}
+
+// readStates reads what is at the destination of every link.
+func readStates(ctx context.Context, conn connector.Connector, links []*link) error {
+	if len(links) == 0 {
+		return nil
+	}
+	lines := []string{`s() { if [ -L "$1" ]; then printf 'link %s\n' "$(readlink "$1")"; elif [ "$1" -ef "$2" ]; then echo folded; elif [ -d "$1" ]; then echo directory; elif [ -e "$1" ]; then echo file; else echo absent; fi; }`}
+	for _, l := range links {
+		lines = append(lines, "s "+shellutil.Join(l.dst, l.src))
+	}
+	result, err := conn.Execute(ctx, strings.Join(lines, "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to check links: %w", err)
+	}
+	states := strings.Split(strings.TrimSuffix(result.Stdout, "\n"), "\n")
+	if result.ExitCode != 0 || len(states) != len(links) {
+		return module.CommandFailedf(result, "failed to check links")
+	}
+	for i, l := range links {
+		l.state = states[i]
+	}
+	return nil
+}
+
+// Files that stow ignores by default: version control files anywhere, and
+// documentation at the top of a package.
+var (
+	ignoredNames = regexp.MustCompile(`^(RCS|.+,v|CVS|\.#.+|\.cvsignore|\.svn|_darcs|\.hg|\.git|\.gitignore|\.gitmodules|.+~|#.*#)$`)
+	ignoredTop   = regexp.MustCompile(`^(README.*|LICENSE.*|COPYING)$`)
+)
+
+// ignored reports whether file, relative to its package, is not linked.
+func ignored(file string) bool {
+	parts := strings.Split(file, "/")
+	if len(parts) == 1 && ignoredTop.MatchString(file) {
+		return true
+	}
+	return slices.ContainsFunc(parts, ignoredNames.MatchString)
+}
+
+// targetName returns the path of the link to file relative to the target
+// directory. With dotPrefix, dot-name becomes .name in every element.
+func targetName(file string, dotPrefix bool) string {
+	if !dotPrefix {
+		return file
+	}
+	parts := strings.Split(file, "/")
+	for i, p := range parts {
+		if name, ok := strings.CutPrefix(p, "dot-"); ok && name != "" {
+			parts[i] = "." + name
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// relPath returns the relative path from the directory dir to the path
+// target. Both must be absolute and clean.
+func relPath(dir, target string) string {
+	from := strings.Split(strings.Trim(dir, "/"), "/")
+	to := strings.Split(strings.Trim(target, "/"), "/")
+	if dir == "/" {
+		from = nil
+	}
+	i := 0
+	for i < len(from) && i < len(to)-1 && from[i] == to[i] {
+		i++
+	}
+	var parts []string
+	for range from[i:] {
+		parts = append(parts, "..")
+	}
+	return path.Join(append(parts, to[i:]...)...)
+}
+
+// expandHome replaces a leading ~ in p with home.
+func expandHome(p, home string) string {
+	if p == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		return path.Join(home, rest)
+	}
+	return path.Clean(p)
+}
+
+// short abbreviates a commit hash.
+func short(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// git runs git in the clone on the target.
+type git struct {
+	conn connector.Connector
+	dir  string
+}
+
+// run runs git with args in the clone and returns its trimmed output.
+func (g *git) run(ctx context.Context, args ...string) (string, error) {
+	result, err := connector.ExecuteArgv(ctx, g.conn, append([]string{"git", "-C", g.dir}, args...)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to run git: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", module.CommandFailedf(result, "git %s failed", args[0])
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// head returns the commit checked out in the clone, or "" if dir does not
+// exist. A directory that is not a clone is an error.
+func (g *git) head(ctx context.Context) (string, error) {
+	result, err := g.conn.Execute(ctx, "test -e "+shellutil.Quote(g.dir))
+	if err != nil {
+		return "", fmt.Errorf("failed to check %s: %w", g.dir, err)
+	}
+	if result.ExitCode != 0 {
+		return "", nil
+	}
+	result, err = g.conn.Execute(ctx, "test -e "+shellutil.Quote(path.Join(g.dir, ".git")))
+	if err != nil {
+		return "", fmt.Errorf("failed to check %s: %w", g.dir, err)
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("%s exists and is not a git clone", g.dir)
+	}
+	return g.run(ctx, "rev-parse", "HEAD")
+}
+
+// clone clones repo into the directory, checks out version, if given,
+// and returns the commit checked out.
+func (g *git) clone(ctx context.Context, repo, version string) (string, error) {
+	result, err := g.conn.Execute(ctx, "command -v git")
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("git is required on the target to clone %s", repo)
+	}
+
+	cmd := shellutil.Join("git", "clone", "--quiet", repo, g.dir)
+	if version != "" {
+		cmd += " && " + shellutil.Join("git", "-C", g.dir, "checkout", "--quiet", version)
+	}
+	result, err = g.conn.Execute(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to clone %s: %w", repo, err)
+	}
+	if result.ExitCode != 0 {
+		return "", module.CommandFailedf(result, "failed to clone %s", repo)
+	}
+	return g.run(ctx, "rev-parse", "HEAD")
+}
+
+// checkOrigin fails if the clone is not a clone of repo.
+func (g *git) checkOrigin(ctx context.Context, repo string) error {
+	origin, err := g.run(ctx, "remote", "get-url", "origin")
+	if err != nil {
+		return err
+	}
+	if origin != repo {
+		return fmt.Errorf("%s is a clone of %s, not %s", g.dir, origin, repo)
+	}
+	return nil
+}
+
+// branch returns the branch version names on the remote, or the branch
+// the clone tracks if version is empty.
+func (g *git) branch(ctx context.Context, version string) (string, error) {
+	if version != "" {
+		return version, nil
+	}
+	upstream, err := g.run(ctx, "rev-parse", "--abbrev-ref", "@{upstream}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(upstream, "origin/"), nil
+}
+
+// remoteCommit returns the commit version names on the remote without
+// fetching it, or "" if version is not a branch or tag there, such as a
+// commit.
+func (g *git) remoteCommit(ctx context.Context, version string) (string, error) {
+	ref, err := g.branch(ctx, version)
+	if err != nil {
+		return "", err
+	}
+	out, err := g.run(ctx, "ls-remote", "origin", ref)
+	if err != nil {
+		return "", err
+	}
+	// An annotated tag is listed twice, the second time with the commit
+	// it points to: refs/tags/v1^{}
+	var commit string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[1] {
+		case "refs/heads/" + ref, "refs/tags/" + ref + "^{}":
+			return fields[0], nil
+		case "refs/tags/" + ref:
+			commit = fields[0]
+		}
+	}
+	if commit == "" && version != "" {
+		// A commit: up to date if it is checked out
+		return version, nil
+	}
+	return commit, nil
+}
+
+// pull fetches the remote, fast-forwards the clone to version, and
+// returns the commit checked out. Local changes that conflict are errors
+// rather than merges.
+func (g *git) pull(ctx context.Context, version string) (string, error) {
+	ref, err := g.branch(ctx, version)
+	if err != nil {
+		return "", err
+	}
+	script := fmt.Sprintf(`cd %[1]s && git fetch --quiet --tags origin && if git rev-parse --verify --quiet %[2]s >/dev/null; then
+  git checkout --quiet %[3]s && git merge --ff-only --quiet %[2]s
+else
+  git checkout --quiet %[3]s
+fi`, shellutil.Quote(g.dir), shellutil.Quote("refs/remotes/origin/"+ref), shellutil.Quote(ref))
+	result, err := g.conn.Execute(ctx, script)
+	if err != nil {
+		return "", fmt.Errorf("failed to update %s: %w", g.dir, err)
+	}
+	if result.ExitCode != 0 {
+		return "", module.CommandFailedf(result, "failed to update %s", g.dir)
+	}
+	return g.run(ctx, "rev-parse", "HEAD")
+}
+
+// SupportsCheckMode reports that dotfiles can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
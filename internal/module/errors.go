@@ -0,0 +1,102 @@
+package module
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Typed errors
+//
+// Modules return these instead of plain errors where the kind of failure
+// matters to the executor: a ParamError or OfflineError is never retried,
+// and a failed task's registered result exposes a CommandFailed's exit
+// code and output.
+// Connection failures are connector.ConnectivityError.
+
+// ParamError reports an invalid or missing module parameter.
+type ParamError struct {
+	// Param is the name of the offending parameter.
+	Param string
+
+	// Err describes the problem.
+	Err error
+}
+
+// ParamErrorf returns a ParamError for param with a formatted message.
+func ParamErrorf(param, format string, args ...any) *ParamError {
+	return &ParamError{Param: param, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *ParamError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// CommandFailed reports a command on the target that exited non-zero.
+type CommandFailed struct {
+	// Msg describes what failed (e.g., "apt-get install failed").
+	Msg string
+
+	// Cmd is the command that ran, if known.
+	Cmd string
+
+	// RC is the command's exit code.
+	RC int
+
+	// Stdout and Stderr are the command's output.
+	Stdout string
+	Stderr string
+}
+
+// CommandFailedf returns a CommandFailed for result with a formatted
+// message.
+func CommandFailedf(result *connector.Result, format string, args ...any) *CommandFailed {
+	return &CommandFailed{
+		Msg:    fmt.Sprintf(format, args...),
+		RC:     result.ExitCode,
+		Stdout: result.Stdout,
+		Stderr: result.Stderr,
+	}
+}
+
+// Error returns the message followed by the command's stderr, or its
+// stdout if stderr is empty.
+func (e *CommandFailed) Error() string {
+	output := strings.TrimSpace(e.Stderr)
+	if output == "" {
+		output = strings.TrimSpace(e.Stdout)
+	}
+	if output == "" {
+		return e.Msg
+	}
+	return e.Msg + ": " + output
+}
+
+// OfflineError reports a task that needs the network in offline mode.
+type OfflineError struct {
+	// Op describes what needs the network (e.g., "downloading
+	// https://example.com/pkg.deb").
+	Op string
+
+	// Hint tells how to do without the network (e.g., "download the file
+	// to the controller and pass its path").
+	Hint string
+}
+
+// OfflineErrorf returns an OfflineError for op with a formatted hint.
+func OfflineErrorf(op, format string, args ...any) *OfflineError {
+	return &OfflineError{Op: op, Hint: fmt.Sprintf(format, args...)}
+}
+
+func (e *OfflineError) Error() string {
+	msg := e.Op + " needs network access, which --offline forbids"
+	if e.Hint == "" {
+		return msg
+	}
+	return msg + "; " + e.Hint
+}
@@ -0,0 +1,91 @@
+package module
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// ReadFile returns the content of the file at file on the target, and
+// whether it exists.
+func ReadFile(ctx context.Context, conn connector.Connector, file string) ([]byte, bool, error) {
+	result, err := conn.Execute(ctx, "test -f "+shellutil.Quote(file))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check %s: %w", file, err)
+	}
+	if result.ExitCode != 0 {
+		return nil, false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := conn.Download(ctx, file, &buf); err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// WriteFile replaces the content of file on the target. The content is
+// uploaded to a new temp file first and, if validate is set, checked with
+// that command, in which %s is the temp file's path. It is then copied
+// over file, so an existing file keeps its mode, owner, and other
+// attributes; a new one and its missing parent directories get the
+// target's default permissions.
+func WriteFile(ctx context.Context, conn connector.Connector, file string, content []byte, validate string) error {
+	result, err := conn.Execute(ctx, "mktemp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return CommandFailedf(result, "failed to create temp file")
+	}
+	tmp := strings.TrimSpace(result.Stdout)
+	defer func() {
+		// Clean up temp file (ignore error)
+		_, _ = conn.Execute(ctx, "rm -f "+shellutil.Quote(tmp))
+	}()
+
+	if err := UploadVerified(ctx, conn, content, tmp, 0600); err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if validate != "" {
+		result, err := conn.Execute(ctx, strings.ReplaceAll(validate, "%s", shellutil.Quote(tmp)))
+		if err != nil {
+			return fmt.Errorf("validation command failed: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return CommandFailedf(result, "validation failed")
+		}
+	}
+
+	cmd := fmt.Sprintf("mkdir -p %s && cat %s > %s", shellutil.Quote(path.Dir(file)), shellutil.Quote(tmp), shellutil.Quote(file))
+	result, err = conn.Execute(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if result.ExitCode != 0 {
+		return CommandFailedf(result, "failed to write %s", file)
+	}
+	return nil
+}
+
+// EnsureDir creates dir on the target with mode if it does not exist. An
+// existing directory is left as it is; missing parents of a new one get
+// the target's default permissions.
+func EnsureDir(ctx context.Context, conn connector.Connector, dir string, mode os.FileMode) error {
+	cmd := fmt.Sprintf("test -d %[1]s || mkdir -p -m %[2]o %[1]s", shellutil.Quote(dir), mode.Perm())
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if result.ExitCode != 0 {
+		return CommandFailedf(result, "failed to create %s", dir)
+	}
+	return nil
+}
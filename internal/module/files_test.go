@@ -0,0 +1,82 @@
+package module
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestReadFile(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("test -f '/etc/app.conf'")
+	conn.On("test -f '/etc/missing.conf'").Fail(1, "")
+	conn.SetFile("/etc/app.conf", []byte("key = value\n"), 0o644)
+
+	content, exists, err := ReadFile(context.Background(), conn, "/etc/app.conf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists || string(content) != "key = value\n" {
+		t.Errorf("ReadFile() = %q, %v, want the file content", content, exists)
+	}
+
+	content, exists, err = ReadFile(context.Background(), conn, "/etc/missing.conf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists || content != nil {
+		t.Errorf("ReadFile() of a missing file = %q, %v, want nil, false", content, exists)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestWriteFile(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("mktemp").Return("/tmp/tmp.abc\n")
+	conn.On("check '/tmp/tmp.abc'")
+	conn.On("mkdir -p '/etc/app' && cat '/tmp/tmp.abc' > '/etc/app/app.conf'")
+	conn.On("rm -f '/tmp/tmp.abc'")
+	conn.Default(connector.Result{})
+
+	err := WriteFile(context.Background(), conn, "/etc/app/app.conf", []byte("key = value\n"), "check %s")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := conn.File("/tmp/tmp.abc"); string(got) != "key = value\n" {
+		t.Errorf("uploaded content = %q", got)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestWriteFileValidationFails(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("mktemp").Return("/tmp/tmp.abc\n")
+	conn.On("check '/tmp/tmp.abc'").Fail(1, "syntax error\n")
+	conn.On("rm -f '/tmp/tmp.abc'")
+	conn.Default(connector.Result{})
+
+	err := WriteFile(context.Background(), conn, "/etc/app/app.conf", []byte("bad\n"), "check %s")
+	if err == nil {
+		t.Fatal("WriteFile() succeeded, want a validation error")
+	}
+	if conn.Executed("mkdir -p '/etc/app' && cat '/tmp/tmp.abc' > '/etc/app/app.conf'") {
+		t.Error("file was written after validation failed")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestEnsureDir(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("test -d '/home/alice/.ssh' || mkdir -p -m 700 '/home/alice/.ssh'")
+	conn.On("test -d '/etc/app' || mkdir -p -m 755 '/etc/app'").Fail(1, "mkdir: permission denied\n")
+
+	if err := EnsureDir(context.Background(), conn, "/home/alice/.ssh", 0o700); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := EnsureDir(context.Background(), conn, "/etc/app", 0o755); err == nil {
+		t.Error("EnsureDir() succeeded when mkdir failed")
+	}
+	conn.AssertExpectations(t)
+}
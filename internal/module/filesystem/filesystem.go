@@ -0,0 +1,247 @@
+// Package filesystem provides a module for creating filesystems on block devices.
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a filesystem.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the device holds a filesystem of the given type
+	StateAbsent  State = "absent"  // Ensure the device holds no filesystem signature
+)
+
+// Module creates, grows, and wipes filesystems on the target system.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "filesystem"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "dev", Type: "string", Required: true, Description: "Block device or image file"},
+		{Name: "fstype", Type: "string", Choices: []string{"ext2", "ext3", "ext4", "xfs", "btrfs", "vfat", "swap"}, Description: "Filesystem type"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "force", Type: "bool", Default: false, Description: "Replace an existing filesystem of a different type"},
+		{Name: "resizefs", Type: "bool", Default: false, Description: "Grow the filesystem to fill the device"},
+		{Name: "opts", Type: "string", Description: "Extra options passed to mkfs"},
+	}
+}
+
+// Run executes the filesystem module.
+//
+// Parameters:
+//   - dev (string, required): Block device or image file (e.g., /dev/vg0/data)
+//   - fstype (string): Filesystem type - ext2, ext3, ext4, xfs, btrfs, vfat, swap (required when state=present)
+//   - state (string): Desired state - present, absent (default: present)
+//   - force (bool): Create the filesystem even if the device already has a different one (default: false)
+//   - resizefs (bool): Grow the filesystem to fill the device if it is larger (default: false)
+//   - opts (string): Extra options passed to mkfs
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	dev, err := param.Required(params, "dev")
+	if err != nil {
+		return nil, err
+	}
+
+	fstype := param.String(params, "fstype", "")
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	force := param.Bool(params, "force", false)
+	resizefs := param.Bool(params, "resizefs", false)
+	opts := param.String(params, "opts", "")
+
+	switch state {
+	case StatePresent:
+		if fstype == "" {
+			return nil, module.ParamErrorf("fstype", "'fstype' parameter is required when state=present")
+		}
+	case StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	// Verify the device exists
+	result, err := conn.Execute(ctx, fmt.Sprintf("test -e %s", shellutil.Quote(dev)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check device: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("device %s does not exist", dev)
+	}
+
+	current, err := getFilesystemType(ctx, conn, dev)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == StateAbsent {
+		if current == "" {
+			return module.Unchanged("no filesystem on device"), nil
+		}
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("wipefs -a %s", shellutil.Quote(dev))); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("wiped %s filesystem from %s", current, dev)), nil
+	}
+
+	if current == fstype {
+		if !resizefs {
+			return module.Unchanged(fmt.Sprintf("%s already has a %s filesystem", dev, fstype)), nil
+		}
+		grown, err := growFilesystem(ctx, conn, dev, fstype)
+		if err != nil {
+			return nil, err
+		}
+		if !grown {
+			return module.Unchanged(fmt.Sprintf("%s filesystem already fills %s", fstype, dev)), nil
+		}
+		return module.Changed(fmt.Sprintf("grew %s filesystem on %s", fstype, dev)), nil
+	}
+
+	if current != "" && !force {
+		return nil, fmt.Errorf("%s already contains a %s filesystem; set force=true to replace it with %s", dev, current, fstype)
+	}
+
+	if err := makeFilesystem(ctx, conn, dev, fstype, opts, current != ""); err != nil {
+		return nil, err
+	}
+
+	return module.ChangedWithData(fmt.Sprintf("created %s filesystem on %s", fstype, dev), map[string]any{
+		"dev":    dev,
+		"fstype": fstype,
+	}), nil
+}
+
+// getFilesystemType returns the filesystem type on dev, or "" if none.
+func getFilesystemType(ctx context.Context, conn connector.Connector, dev string) (string, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("blkid -c /dev/null -o value -s TYPE %s 2>/dev/null || true", shellutil.Quote(dev)))
+	if err != nil {
+		return "", fmt.Errorf("failed to detect filesystem: %w", err)
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// makeFilesystem runs the mkfs command for fstype.
+func makeFilesystem(ctx context.Context, conn connector.Connector, dev, fstype, opts string, force bool) error {
+	var cmd string
+	switch fstype {
+	case "swap":
+		cmd = "mkswap"
+		if force {
+			cmd += " -f"
+		}
+	case "ext2", "ext3", "ext4":
+		cmd = "mkfs." + fstype
+		if force {
+			cmd += " -F"
+		}
+	case "xfs", "btrfs":
+		cmd = "mkfs." + fstype
+		if force {
+			cmd += " -f"
+		}
+	case "vfat":
+		cmd = "mkfs.vfat"
+	default:
+		cmd = "mkfs -t " + shellutil.Quote(fstype)
+	}
+
+	// Each whitespace-separated word of opts is one argument
+	if fields := strings.Fields(opts); len(fields) > 0 {
+		cmd += " " + shellutil.Join(fields...)
+	}
+	cmd += " " + shellutil.Quote(dev)
+
+	return module.RunCommand(ctx, conn, cmd)
+}
+
+// growFilesystem grows the filesystem to the device size.
+// It returns whether the filesystem size changed.
+func growFilesystem(ctx context.Context, conn connector.Connector, dev, fstype string) (bool, error) {
+	switch fstype {
+	case "ext2", "ext3", "ext4":
+		result, err := conn.Execute(ctx, fmt.Sprintf("resize2fs %s 2>&1", shellutil.Quote(dev)))
+		if err != nil {
+			return false, fmt.Errorf("failed to resize filesystem: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return false, module.CommandFailedf(result, "resize2fs failed")
+		}
+		return !strings.Contains(result.Stdout, "Nothing to do"), nil
+
+	case "xfs", "btrfs":
+		// Both can only be grown while mounted
+		mountpoint, err := findMountpoint(ctx, conn, dev)
+		if err != nil {
+			return false, err
+		}
+		if fstype == "xfs" {
+			result, err := conn.Execute(ctx, fmt.Sprintf("xfs_growfs -d %s 2>&1", shellutil.Quote(mountpoint)))
+			if err != nil {
+				return false, fmt.Errorf("failed to resize filesystem: %w", err)
+			}
+			if result.ExitCode != 0 {
+				return false, module.CommandFailedf(result, "xfs_growfs failed")
+			}
+			return strings.Contains(result.Stdout, "data blocks changed"), nil
+		}
+
+		before, err := filesystemSize(ctx, conn, mountpoint)
+		if err != nil {
+			return false, err
+		}
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("btrfs filesystem resize max %s", shellutil.Quote(mountpoint))); err != nil {
+			return false, err
+		}
+		after, err := filesystemSize(ctx, conn, mountpoint)
+		if err != nil {
+			return false, err
+		}
+		return before != after, nil
+
+	default:
+		return false, fmt.Errorf("resizefs is not supported for %s", fstype)
+	}
+}
+
+// findMountpoint returns where dev is mounted.
+func findMountpoint(ctx context.Context, conn connector.Connector, dev string) (string, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("findmnt -n -o TARGET --source %s | head -n 1", shellutil.Quote(dev)))
+	if err != nil {
+		return "", fmt.Errorf("failed to find mountpoint: %w", err)
+	}
+	mountpoint := strings.TrimSpace(result.Stdout)
+	if mountpoint == "" {
+		return "", fmt.Errorf("%s must be mounted to be resized", dev)
+	}
+	return mountpoint, nil
+}
+
+// filesystemSize returns the size in KiB reported by df for a mountpoint.
+func filesystemSize(ctx context.Context, conn connector.Connector, mountpoint string) (string, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("df -P -k %s | tail -n 1 | awk '{print $2}'", shellutil.Quote(mountpoint)))
+	if err != nil {
+		return "", fmt.Errorf("failed to get filesystem size: %w", err)
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
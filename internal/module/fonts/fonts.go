@@ -0,0 +1,272 @@
+// Package fonts provides a module for installing font files.
+package fonts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/artifact"
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of the fonts.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the fonts are installed
+	StateAbsent  State = "absent"  // Ensure the fonts are removed
+)
+
+// Module installs font files into the font directory of the target.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "fonts"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "src", Type: "string/list", Required: true, Description: "Font files: local paths or http(s) URLs"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "system", Type: "bool", Default: false, Description: "Install for all users instead of the connecting user"},
+		{Name: "dest", Type: "string", Description: "Font directory (default: per OS)"},
+		{Name: "checksum", Type: "string", Description: "SHA256 checksum of a single URL `src` (`sha256:<hex>`)"},
+	}
+}
+
+// Run executes the fonts module.
+//
+// Parameters:
+//   - src (string|[]string, required): Font files, as paths on the controller or http(s) URLs
+//   - state (string): Desired state - present, absent (default: present)
+//   - system (bool): Install into the system font directory (default: false)
+//   - dest (string): Font directory (default: ~/Library/Fonts or /Library/Fonts on macOS,
+//     ~/.local/share/fonts or /usr/local/share/fonts elsewhere)
+//   - checksum (string): SHA256 checksum of a single URL src ("sha256:<hex>")
+//
+// URLs are downloaded once into the artifact cache of the controller. On
+// Linux and BSD the font cache is refreshed with fc-cache when a font was
+// added or removed.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	srcs := param.StringList(params, "src")
+	state := State(param.String(params, "state", "present"))
+	system := param.Bool(params, "system", false)
+	dest := param.String(params, "dest", "")
+	checksum := param.String(params, "checksum", "")
+	check := module.IsCheckMode(params)
+
+	if len(srcs) == 0 {
+		return nil, module.ParamErrorf("src", "'src' parameter is required")
+	}
+	if state != StatePresent && state != StateAbsent {
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if checksum != "" && (len(srcs) != 1 || !isURL(srcs[0])) {
+		return nil, module.ParamErrorf("checksum", "'checksum' requires a single URL in 'src'")
+	}
+	if _, err := artifact.ParseChecksum(checksum); err != nil {
+		return nil, module.ParamErrorf("checksum", "%v", err)
+	}
+
+	osName, err := module.TargetOS(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if dest == "" {
+		if dest, err = fontDir(ctx, conn, osName, system); err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []string
+	for _, src := range srcs {
+		file := path.Join(dest, fontName(src))
+		exists, remoteSum, err := remoteChecksum(ctx, conn, file)
+		if err != nil {
+			return nil, err
+		}
+
+		if state == StateAbsent {
+			if !exists {
+				continue
+			}
+			if !check {
+				if err := module.RunCommand(ctx, conn, "rm -f "+shellutil.Quote(file)); err != nil {
+					return nil, fmt.Errorf("failed to remove %s: %w", file, err)
+				}
+			}
+			changed = append(changed, file)
+			continue
+		}
+
+		content, err := readFont(ctx, src, checksum, param.String(params, "_role_path", ""), module.IsOffline(params))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		if exists && remoteSum == hex.EncodeToString(sum[:]) {
+			continue
+		}
+		if !check {
+			if err := module.RunCommand(ctx, conn, "mkdir -p "+shellutil.Quote(dest)); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", dest, err)
+			}
+			if err := module.UploadVerified(ctx, conn, content, file, 0644); err != nil {
+				return nil, fmt.Errorf("failed to upload %s: %w", file, err)
+			}
+		}
+		changed = append(changed, file)
+	}
+
+	data := map[string]any{"dest": dest}
+	if len(changed) == 0 {
+		if state == StateAbsent {
+			return module.Unchanged("fonts not installed"), nil
+		}
+		return module.Unchanged("fonts already installed"), nil
+	}
+	data["changed_files"] = changed
+
+	verb := "installed"
+	if state == StateAbsent {
+		verb = "removed"
+	}
+	if check {
+		return module.ChangedWithData(fmt.Sprintf("would have %s %d font(s)", verb, len(changed)), data), nil
+	}
+
+	// macOS picks up new fonts by itself
+	if osName != "Darwin" {
+		if err := refreshCache(ctx, conn, dest); err != nil {
+			return nil, err
+		}
+	}
+	return module.ChangedWithData(fmt.Sprintf("%s %d font(s) in %s", verb, len(changed), dest), data), nil
+}
+
+// fontDir returns the default font directory for the OS of the target.
+func fontDir(ctx context.Context, conn connector.Connector, osName string, system bool) (string, error) {
+	if system {
+		if osName == "Darwin" {
+			return "/Library/Fonts", nil
+		}
+		return "/usr/local/share/fonts", nil
+	}
+
+	home, err := module.HomeDir(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+	if osName == "Darwin" {
+		return path.Join(home, "Library", "Fonts"), nil
+	}
+	return path.Join(home, ".local", "share", "fonts"), nil
+}
+
+// fontName returns the file name of a font source.
+func fontName(src string) string {
+	if isURL(src) {
+		return path.Base(strings.SplitN(src, "?", 2)[0])
+	}
+	return filepath.Base(src)
+}
+
+// readFont returns the content of a font file on the controller, or of a
+// URL through the artifact cache. Relative paths are looked up in the
+// files directory of the role first. Offline, URLs must be in the cache
+// already.
+func readFont(ctx context.Context, src, checksum, rolePath string, offline bool) ([]byte, error) {
+	file := src
+	var err error
+	switch {
+	case isURL(src) && offline:
+		file, _, err = artifact.Lookup(src, checksum)
+		if errors.Is(err, artifact.ErrNotCached) {
+			return nil, module.OfflineErrorf("downloading "+src, "run the task once with network access and a checksum, or copy the font to the controller and pass its path in 'src'")
+		}
+	case isURL(src):
+		file, _, err = artifact.Fetch(ctx, src, checksum)
+	case !filepath.IsAbs(src) && rolePath != "":
+		if roleFile := filepath.Join(rolePath, "files", src); fileExists(roleFile) {
+			file = roleFile
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font file '%s': %w", file, err)
+	}
+	return content, nil
+}
+
+// remoteChecksum reports whether file exists on the target and returns its
+// SHA256 checksum.
+func remoteChecksum(ctx context.Context, conn connector.Connector, file string) (bool, string, error) {
+	result, err := conn.Execute(ctx, "test -f "+shellutil.Quote(file))
+	if err != nil {
+		return false, "", fmt.Errorf("failed to check %s: %w", file, err)
+	}
+	if result.ExitCode != 0 {
+		return false, "", nil
+	}
+	sum, err := module.RemoteChecksum(ctx, conn, file)
+	if err != nil {
+		return false, "", err
+	}
+	return true, sum, nil
+}
+
+// refreshCache rebuilds the fontconfig cache of dir, if fc-cache is
+// installed.
+func refreshCache(ctx context.Context, conn connector.Connector, dir string) error {
+	result, err := conn.Execute(ctx, "command -v fc-cache")
+	if err != nil {
+		return fmt.Errorf("failed to check for fc-cache: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil
+	}
+	if err := module.RunCommand(ctx, conn, "fc-cache -f "+shellutil.Quote(dir)); err != nil {
+		return fmt.Errorf("failed to refresh the font cache: %w", err)
+	}
+	return nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// SupportsCheckMode reports that fonts can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,213 @@
+package fonts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const (
+	userDir  = "/home/alice/.local/share/fonts"
+	userFont = userDir + "/Test.ttf"
+)
+
+var fontContent = []byte("not really a font")
+
+// writeFont writes a font file on the controller and returns its path.
+func writeFont(t *testing.T) string {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "Test.ttf")
+	if err := os.WriteFile(src, fontContent, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+// newConn returns a fake target running osName, on which commands not
+// scripted by the test succeed without output.
+func newConn(osName string) *connectortest.Connector {
+	conn := connectortest.New()
+	conn.On("uname -s").Return(osName + "\n")
+	conn.On(`printf '%s' "$HOME"`).Return("/home/alice")
+	conn.Default(connector.Result{})
+	return conn
+}
+
+// onChecksum makes the checksum of file on the target sum, once.
+func onChecksum(conn *connectortest.Connector, file, sum string) {
+	conn.OnMatch(`^if command -v sha256sum[\s\S]*'` + file + `'`).Return(sum + "\n").Once()
+}
+
+func TestRunInstall(t *testing.T) {
+	src := writeFont(t)
+	conn := newConn("Linux")
+	conn.On("test -f '"+userFont+"'").Fail(1, "").Once()
+	conn.On("command -v fc-cache").Return("/usr/bin/fc-cache\n")
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"src": src})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Fatalf("Changed = false, want true (%s)", result.Message)
+	}
+	if got, _ := conn.File(userFont); string(got) != string(fontContent) {
+		t.Errorf("uploaded %q, want the font", got)
+	}
+	if mode, _ := conn.FileMode(userFont); mode != 0o644 {
+		t.Errorf("mode = %o, want 644", mode)
+	}
+	for _, cmd := range []string{"mkdir -p '" + userDir + "'", "fc-cache -f '" + userDir + "'"} {
+		if !conn.Executed(cmd) {
+			t.Errorf("%q was not executed", cmd)
+		}
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunAlreadyInstalled(t *testing.T) {
+	src := writeFont(t)
+	sum := sha256.Sum256(fontContent)
+	conn := newConn("Linux")
+	onChecksum(conn, userFont, hex.EncodeToString(sum[:]))
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"src": src})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("Changed = true, want false (%s)", result.Message)
+	}
+	if _, ok := conn.File(userFont); ok {
+		t.Error("font was uploaded again")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunReplace(t *testing.T) {
+	src := writeFont(t)
+	conn := newConn("Linux")
+	onChecksum(conn, userFont, "0000")
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"src": src})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("Changed = false, want true (%s)", result.Message)
+	}
+	if got, _ := conn.File(userFont); string(got) != string(fontContent) {
+		t.Errorf("uploaded %q, want the font", got)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunSystemDarwin(t *testing.T) {
+	src := writeFont(t)
+	conn := newConn("Darwin")
+	conn.On("test -f '/Library/Fonts/Test.ttf'").Fail(1, "").Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"src": src, "system": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := result.Data["dest"]; got != "/Library/Fonts" {
+		t.Errorf("dest = %v, want /Library/Fonts", got)
+	}
+	if _, ok := conn.File("/Library/Fonts/Test.ttf"); !ok {
+		t.Error("font was not uploaded")
+	}
+	if conn.Executed("command -v fc-cache") {
+		t.Error("font cache refreshed on macOS")
+	}
+}
+
+func TestRunRemove(t *testing.T) {
+	conn := newConn("Linux")
+	onChecksum(conn, userFont, "0000")
+	conn.On("rm -f '" + userFont + "'").Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"src": "Test.ttf", "state": "absent"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("Changed = false, want true (%s)", result.Message)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunRemoveMissing(t *testing.T) {
+	conn := newConn("Linux")
+	conn.On("test -f '"+userFont+"'").Fail(1, "")
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"src": "Test.ttf", "state": "absent"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("Changed = true, want false (%s)", result.Message)
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	src := writeFont(t)
+	conn := newConn("Linux")
+	conn.On("test -f '"+userFont+"'").Fail(1, "")
+
+	params := map[string]any{"src": src, module.CheckModeParam: true}
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	if _, ok := conn.File(userFont); ok || conn.Executed("mkdir -p '"+userDir+"'") {
+		t.Error("check mode installed the font")
+	}
+}
+
+func TestRunInvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]any
+	}{
+		{"no src", map[string]any{}},
+		{"bad state", map[string]any{"src": "Test.ttf", "state": "latest"}},
+		{"checksum for a local file", map[string]any{"src": "Test.ttf", "checksum": "sha256:00"}},
+		{"checksum for several URLs", map[string]any{"src": []any{"https://example.com/a.ttf", "https://example.com/b.ttf"}, "checksum": "sha256:00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&Module{}).Run(context.Background(), newConn("Linux"), tt.params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestFontName(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"fonts/Test.ttf", "Test.ttf"},
+		{"/usr/share/fonts/Test.otf", "Test.otf"},
+		{"https://example.com/dl/Test.ttf?raw=true", "Test.ttf"},
+	}
+
+	for _, tt := range tests {
+		if got := fontName(tt.src); got != tt.want {
+			t.Errorf("fontName(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
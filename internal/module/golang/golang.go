@@ -0,0 +1,259 @@
+// Package golang provides a module for installing Go from the official
+// release tarballs.
+package golang
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/artifact"
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// DefaultMirror is where the release tarballs are downloaded from.
+const DefaultMirror = "https://go.dev/dl"
+
+// Module installs a pinned version of Go.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "golang"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "version", Type: "string", Description: "Go version, e.g. 1.22.4 (required for state=present)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "install_dir", Type: "string", Default: "/usr/local/go", Description: "Directory to install Go into (GOROOT)"},
+		{Name: "checksum", Type: "string", Description: "SHA256 checksum of the release tarball"},
+		{Name: "mirror", Type: "string", Default: DefaultMirror, Description: "Base URL of the release tarballs"},
+		{Name: "download", Type: "string", Default: "target", Choices: []string{"target", "controller"}, Description: "Where to download the tarball: on the target, or on the controller through the artifact cache"},
+	}
+}
+
+// Run executes the golang module.
+//
+// The tarball for the target's OS and architecture is unpacked into
+// install_dir, replacing an installation of another version. Go is not
+// added to the PATH; use <install_dir>/bin/go or add it to the profile.
+//
+// Parameters:
+//   - version (string): Go version to install, e.g. 1.22.4 (required for state=present)
+//   - state (string): Desired state - present, absent (default: present)
+//   - install_dir (string): Directory to install Go into (default: /usr/local/go)
+//   - checksum (string): SHA256 checksum of the release tarball
+//   - mirror (string): Base URL of the release tarballs (default: https://go.dev/dl)
+//   - download (string): target or controller (default: target)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	version := strings.TrimPrefix(param.String(params, "version", ""), "go")
+	state := param.String(params, "state", "present")
+	installDir := param.String(params, "install_dir", "/usr/local/go")
+	checksum := param.String(params, "checksum", "")
+	mirror := strings.TrimSuffix(param.String(params, "mirror", DefaultMirror), "/")
+	download := param.String(params, "download", "target")
+	check := module.IsCheckMode(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if download != "target" && download != "controller" {
+		return nil, module.ParamErrorf("download", "invalid download '%s': must be target or controller", download)
+	}
+	if state == "present" && version == "" {
+		return nil, module.ParamErrorf("version", "'version' parameter is required for state=present")
+	}
+	if installDir == "" || installDir == "/" {
+		return nil, module.ParamErrorf("install_dir", "invalid install_dir '%s'", installDir)
+	}
+	if _, err := artifact.ParseChecksum(checksum); err != nil {
+		return nil, module.ParamErrorf("checksum", "%v", err)
+	}
+
+	current, err := installedVersion(ctx, conn, installDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == "absent" {
+		if current == "" {
+			return module.Unchanged("go is not installed"), nil
+		}
+		before := map[string]any{"version": current}
+		if check {
+			return module.Changed(fmt.Sprintf("would remove go %s", current)).WithDiff(before, nil), nil
+		}
+		if err := module.RunCommand(ctx, conn, "rm -rf "+shellutil.Quote(installDir)); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed go %s", current)).WithDiff(before, nil), nil
+	}
+
+	data := map[string]any{"version": version, "goroot": installDir}
+	if current == version {
+		return &module.Result{Message: fmt.Sprintf("go %s is installed", version), Data: data}, nil
+	}
+
+	var before map[string]any
+	verb, what := "install", "go "+version
+	if current != "" {
+		before = map[string]any{"version": current}
+		verb, what = "upgrade", fmt.Sprintf("go from %s to %s", current, version)
+	}
+	after := map[string]any{"version": version}
+	if check {
+		return module.Changed(fmt.Sprintf("would %s %s", verb, what)).WithDiff(before, after), nil
+	}
+
+	platform, err := targetPlatform(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/go%s.%s.tar.gz", mirror, version, platform)
+	if err := install(ctx, conn, url, checksum, installDir, download == "controller", module.IsOffline(params)); err != nil {
+		return nil, err
+	}
+
+	result := module.ChangedWithData(fmt.Sprintf("%sd %s", verb, what), data)
+	return result.WithDiff(before, after), nil
+}
+
+// installedVersion returns the version of the Go installation in dir, or
+// "" if there is none.
+func installedVersion(ctx context.Context, conn connector.Connector, dir string) (string, error) {
+	goBin := shellutil.Quote(path.Join(dir, "bin", "go"))
+	result, err := conn.Execute(ctx, fmt.Sprintf("test -x %s && %s version", goBin, goBin))
+	if err != nil {
+		return "", fmt.Errorf("failed to check go version: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", nil
+	}
+	// go version go1.22.4 linux/amd64
+	fields := strings.Fields(result.Stdout)
+	if len(fields) < 3 {
+		return "", fmt.Errorf("unexpected output of go version: %s", strings.TrimSpace(result.Stdout))
+	}
+	return strings.TrimPrefix(fields[2], "go"), nil
+}
+
+// goOS and goArch map uname -s and uname -m to the names of the release
+// tarballs.
+var (
+	goOS = map[string]string{"Linux": "linux", "Darwin": "darwin", "FreeBSD": "freebsd"}
+
+	goArch = map[string]string{
+		"x86_64":  "amd64",
+		"amd64":   "amd64",
+		"aarch64": "arm64",
+		"arm64":   "arm64",
+		"armv6l":  "armv6l",
+		"armv7l":  "armv6l",
+		"i386":    "386",
+		"i686":    "386",
+	}
+)
+
+// targetPlatform returns the <os>-<arch> of the target's release tarball.
+func targetPlatform(ctx context.Context, conn connector.Connector) (string, error) {
+	result, err := conn.Execute(ctx, "uname -s; uname -m")
+	if err != nil {
+		return "", fmt.Errorf("failed to detect platform: %w", err)
+	}
+	fields := strings.Fields(result.Stdout)
+	if result.ExitCode != 0 || len(fields) != 2 {
+		return "", module.CommandFailedf(result, "failed to detect platform")
+	}
+	goos, ok := goOS[fields[0]]
+	if !ok {
+		return "", fmt.Errorf("go releases are not available for %s", fields[0])
+	}
+	arch, ok := goArch[fields[1]]
+	if !ok {
+		return "", fmt.Errorf("go releases are not available for %s", fields[1])
+	}
+	return goos + "-" + arch, nil
+}
+
+// install downloads the tarball at url and unpacks it into dir, replacing
+// what is there. The old installation is only removed once the tarball
+// is unpacked.
+func install(ctx context.Context, conn connector.Connector, url, checksum, dir string, viaController, offline bool) error {
+	result, err := conn.Execute(ctx, "mktemp -d")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "failed to create temp directory")
+	}
+	tmp := strings.TrimSpace(result.Stdout)
+	defer conn.Execute(ctx, "rm -rf "+shellutil.Quote(tmp))
+
+	tarball := path.Join(tmp, path.Base(url))
+	if err := fetch(ctx, conn, url, checksum, tarball, viaController, offline); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("tar -C %[1]s -xzf %[2]s && rm -rf %[3]s && mkdir -p %[4]s && mv %[1]s/go %[3]s",
+		shellutil.Quote(tmp), shellutil.Quote(tarball), shellutil.Quote(dir), shellutil.Quote(path.Dir(dir)))
+	return module.RunCommand(ctx, conn, cmd)
+}
+
+// fetch downloads the tarball at url to dst on the target and verifies
+// its checksum, if given. Offline, it must come from the artifact cache.
+func fetch(ctx context.Context, conn connector.Connector, url, checksum, dst string, viaController, offline bool) error {
+	if offline {
+		if !viaController {
+			return module.OfflineErrorf("downloading "+url, "set download: controller to install it from the artifact cache")
+		}
+		if _, _, err := artifact.Lookup(url, checksum); err != nil {
+			return module.OfflineErrorf("downloading "+url, "it is not in the artifact cache; run the task once with network access and a checksum")
+		}
+	}
+	if viaController {
+		if _, err := artifact.Upload(ctx, conn, url, checksum, dst, 0644); err != nil {
+			return fmt.Errorf("failed to download %s: %w", url, err)
+		}
+		return nil
+	}
+
+	if err := module.RunCommand(ctx, conn, fmt.Sprintf("curl -fsSL -o %s %s", shellutil.Quote(dst), shellutil.Quote(url))); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	want, _ := artifact.ParseChecksum(checksum)
+	if want == "" {
+		return nil
+	}
+	got, err := module.RemoteChecksum(ctx, conn, dst)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", url, err)
+	}
+	if got != "" && got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, want, got)
+	}
+	return nil
+}
+
+// SupportsCheckMode reports that golang can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,319 @@
+// Package group provides a module for managing system groups.
+package group
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module creates, changes, and removes groups, with groupadd and groupmod
+// on Linux and dscl on macOS.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "group"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "Group name"},
+		{Name: "gid", Type: "int", Description: "Group ID"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "system", Type: "bool", Default: false, Description: "Create a system group, with an ID from the system range"},
+	}
+}
+
+// Run executes the group module.
+//
+// The gid of an existing group is only changed if gid is given; files
+// owned by the old gid keep it. system only applies when the group is
+// created.
+//
+// Parameters:
+//   - name (string, required): Group name
+//   - gid (int): Group ID (default: the next free one)
+//   - state (string): Desired state - present, absent (default: present)
+//   - system (bool): Create a system group (default: false)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+	state := param.String(params, "state", "present")
+	system := param.Bool(params, "system", false)
+	check := module.IsCheckMode(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if strings.HasPrefix(name, "-") || strings.ContainsAny(name, ": \t\n/") {
+		return nil, module.ParamErrorf("name", "invalid group name '%s'", name)
+	}
+	gid := -1
+	if _, ok := params["gid"]; ok {
+		gid = param.Int(params, "gid", -1)
+		if gid < 0 {
+			return nil, module.ParamErrorf("gid", "invalid gid: must be a non-negative integer")
+		}
+	}
+
+	osName, err := module.TargetOS(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var sys groups
+	switch osName {
+	case "Linux":
+		sys = &linux{conn: conn}
+	case "Darwin":
+		sys = &darwin{conn: conn}
+	default:
+		return nil, fmt.Errorf("groups cannot be managed on %s", osName)
+	}
+
+	current, exists, err := sys.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == "absent" {
+		if !exists {
+			return module.Unchanged(fmt.Sprintf("group %s already absent", name)), nil
+		}
+		before := map[string]any{"gid": current}
+		if check {
+			return module.Changed(fmt.Sprintf("would remove group %s", name)).WithDiff(before, nil), nil
+		}
+		if err := sys.remove(ctx, name); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed group %s", name)).WithDiff(before, nil), nil
+	}
+
+	switch {
+	case !exists:
+		after := map[string]any{}
+		if gid >= 0 {
+			after["gid"] = gid
+		}
+		if check {
+			return module.Changed(fmt.Sprintf("would create group %s", name)).WithDiff(nil, after), nil
+		}
+		if err := sys.create(ctx, name, gid, system); err != nil {
+			return nil, err
+		}
+		return changed(ctx, sys, name, fmt.Sprintf("created group %s", name), nil, after)
+
+	case gid >= 0 && gid != current:
+		before, after := map[string]any{"gid": current}, map[string]any{"gid": gid}
+		if check {
+			return module.Changed(fmt.Sprintf("would change gid of group %s", name)).WithDiff(before, after), nil
+		}
+		if err := sys.modify(ctx, name, gid); err != nil {
+			return nil, err
+		}
+		return changed(ctx, sys, name, fmt.Sprintf("changed gid of group %s", name), before, after)
+	}
+
+	result := module.Unchanged(fmt.Sprintf("group %s already in desired state", name))
+	result.Data = map[string]any{"gid": current}
+	return result, nil
+}
+
+// changed returns a changed result with the gid the group has now.
+func changed(ctx context.Context, sys groups, name, msg string, before, after any) (*module.Result, error) {
+	gid, exists, err := sys.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("group %s is missing after the change", name)
+	}
+	return module.ChangedWithData(msg, map[string]any{"gid": gid}).WithDiff(before, after), nil
+}
+
+// groups manages the groups of an OS.
+type groups interface {
+	// lookup returns the gid of the group name, if it exists.
+	lookup(ctx context.Context, name string) (int, bool, error)
+
+	// create creates the group name with gid, or the next free one if gid
+	// is -1.
+	create(ctx context.Context, name string, gid int, system bool) error
+
+	// modify changes the gid of the group name.
+	modify(ctx context.Context, name string, gid int) error
+
+	// remove removes the group name.
+	remove(ctx context.Context, name string) error
+}
+
+// linux manages groups with the shadow utilities.
+type linux struct {
+	conn connector.Connector
+}
+
+func (l *linux) lookup(ctx context.Context, name string) (int, bool, error) {
+	result, err := l.conn.Execute(ctx, "getent group "+shellutil.Quote(name))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up group %s: %w", name, err)
+	}
+	// getent exits 2 for a missing key
+	if result.ExitCode == 2 {
+		return 0, false, nil
+	}
+	if result.ExitCode != 0 {
+		return 0, false, module.CommandFailedf(result, "failed to look up group %s", name)
+	}
+
+	// docker:x:999:alice,bob
+	fields := strings.Split(strings.TrimSpace(result.Stdout), ":")
+	if len(fields) < 3 {
+		return 0, false, fmt.Errorf("unexpected output looking up group %s: %q", name, result.Stdout)
+	}
+	gid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid gid of group %s: %q", name, fields[2])
+	}
+	return gid, true, nil
+}
+
+func (l *linux) create(ctx context.Context, name string, gid int, system bool) error {
+	args := []string{"groupadd"}
+	if gid >= 0 {
+		args = append(args, "-g", strconv.Itoa(gid))
+	}
+	if system {
+		args = append(args, "-r")
+	}
+	return module.RunCommand(ctx, l.conn, sbin(shellutil.Join(append(args, name)...)))
+}
+
+func (l *linux) modify(ctx context.Context, name string, gid int) error {
+	return module.RunCommand(ctx, l.conn, sbin(shellutil.Join("groupmod", "-g", strconv.Itoa(gid), name)))
+}
+
+func (l *linux) remove(ctx context.Context, name string) error {
+	return module.RunCommand(ctx, l.conn, sbin(shellutil.Join("groupdel", name)))
+}
+
+// darwin manages groups in the local directory of macOS.
+type darwin struct {
+	conn connector.Connector
+}
+
+// First IDs of the ranges that groups are created in when no gid is given.
+// IDs below 500 are the system's, and Apple's own groups start at the
+// bottom of that range.
+const (
+	darwinSystemGID = 300
+	darwinGID       = 501
+)
+
+func (d *darwin) lookup(ctx context.Context, name string) (int, bool, error) {
+	result, err := d.conn.Execute(ctx, "dscl . -read "+shellutil.Quote("/Groups/"+name)+" PrimaryGroupID")
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up group %s: %w", name, err)
+	}
+	if result.ExitCode != 0 {
+		// <dscl_cmd> DS Error: -14136 (eDSRecordNotFound)
+		if strings.Contains(result.Stdout+result.Stderr, "eDSRecordNotFound") {
+			return 0, false, nil
+		}
+		return 0, false, module.CommandFailedf(result, "failed to look up group %s", name)
+	}
+
+	// PrimaryGroupID: 20
+	_, v, _ := strings.Cut(strings.TrimSpace(result.Stdout), ":")
+	gid, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, false, fmt.Errorf("unexpected output looking up group %s: %q", name, result.Stdout)
+	}
+	return gid, true, nil
+}
+
+func (d *darwin) create(ctx context.Context, name string, gid int, system bool) error {
+	if gid < 0 {
+		var err error
+		if gid, err = d.freeGID(ctx, system); err != nil {
+			return err
+		}
+	}
+	record := shellutil.Quote("/Groups/" + name)
+	cmd := fmt.Sprintf("dscl . -create %[1]s && dscl . -create %[1]s PrimaryGroupID %[2]d && dscl . -create %[1]s Password '*'", record, gid)
+	return module.RunCommand(ctx, d.conn, cmd)
+}
+
+func (d *darwin) modify(ctx context.Context, name string, gid int) error {
+	return module.RunCommand(ctx, d.conn, fmt.Sprintf("dscl . -create %s PrimaryGroupID %d", shellutil.Quote("/Groups/"+name), gid))
+}
+
+func (d *darwin) remove(ctx context.Context, name string) error {
+	return module.RunCommand(ctx, d.conn, "dscl . -delete "+shellutil.Quote("/Groups/"+name))
+}
+
+// freeGID returns the lowest unused gid of the system or the user range,
+// as dscl does not pick one.
+func (d *darwin) freeGID(ctx context.Context, system bool) (int, error) {
+	result, err := d.conn.Execute(ctx, "dscl . -list /Groups PrimaryGroupID")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list groups: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return 0, module.CommandFailedf(result, "failed to list groups")
+	}
+
+	// admin  80
+	used := map[int]bool{}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			if gid, err := strconv.Atoi(fields[1]); err == nil {
+				used[gid] = true
+			}
+		}
+	}
+	start, end := darwinGID, 1<<31-1
+	if system {
+		start, end = darwinSystemGID, darwinGID-1
+	}
+	for gid := start; gid <= end; gid++ {
+		if !used[gid] {
+			return gid, nil
+		}
+	}
+	return 0, fmt.Errorf("no free gid between %d and %d", start, end)
+}
+
+// sbin adds the sbin directories, where the account tools are, to the PATH
+// of cmd, as they may be missing for non-root users.
+func sbin(cmd string) string {
+	return `PATH="$PATH:/usr/sbin:/sbin" ` + cmd
+}
+
+// SupportsCheckMode reports that group can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,75 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// HomebrewBinDirs are the bin directories of the prefixes the Homebrew
+// installer uses: Apple Silicon macOS, Intel macOS, and Linux.
+var HomebrewBinDirs = []string{"/opt/homebrew/bin", "/usr/local/bin", "/home/linuxbrew/.linuxbrew/bin"}
+
+// Homebrew is the Homebrew installation of a target.
+type Homebrew struct {
+	// Brew is the path of the brew executable.
+	Brew string
+
+	// Path is the PATH to run brew with: the target's PATH, with the
+	// directory of brew in front if it was not on it.
+	Path string
+
+	// OnPath is true if brew is on the target's PATH.
+	OnPath bool
+}
+
+// Prefix returns the Homebrew prefix, e.g. /opt/homebrew.
+func (h *Homebrew) Prefix() string {
+	return path.Dir(path.Dir(h.Brew))
+}
+
+// Connector returns conn set up to run brew. A fresh install is not on
+// the PATH of non-login shells until the user's profile adds it, so
+// commands then run with Path.
+func (h *Homebrew) Connector(conn connector.Connector) connector.Connector {
+	if h.OnPath {
+		return conn
+	}
+	return connector.WithEnv(conn, map[string]string{"PATH": h.Path})
+}
+
+// FindHomebrew returns the Homebrew installation of the target, looking
+// for brew on the PATH and then in HomebrewBinDirs, or nil if Homebrew is
+// not installed.
+func FindHomebrew(ctx context.Context, conn connector.Connector) (*Homebrew, error) {
+	cmd := fmt.Sprintf(`brew=$(command -v brew) || for dir in %s; do
+  if [ -x "$dir/brew" ]; then brew=$dir/brew; break; fi
+done
+[ -n "$brew" ] || exit 1
+echo "$brew"
+echo "$PATH"`, strings.Join(HomebrewBinDirs, " "))
+
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for homebrew: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, nil
+	}
+
+	lines := strings.SplitN(strings.TrimSpace(result.Stdout), "\n", 2)
+	h := &Homebrew{Brew: strings.TrimSpace(lines[0])}
+	if len(lines) == 2 {
+		h.Path = strings.TrimSpace(lines[1])
+	}
+	dir := path.Dir(h.Brew)
+	h.OnPath = slices.Contains(strings.Split(h.Path, ":"), dir)
+	if !h.OnPath {
+		h.Path = dir + ":" + h.Path
+	}
+	return h, nil
+}
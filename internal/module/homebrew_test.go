@@ -0,0 +1,76 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestFindHomebrew(t *testing.T) {
+	tests := []struct {
+		name       string
+		stdout     string
+		exit       int
+		wantBrew   string
+		wantPath   string
+		wantOnPath bool
+	}{
+		{
+			name:       "on path",
+			stdout:     "/opt/homebrew/bin/brew\n/opt/homebrew/bin:/usr/bin:/bin\n",
+			wantBrew:   "/opt/homebrew/bin/brew",
+			wantPath:   "/opt/homebrew/bin:/usr/bin:/bin",
+			wantOnPath: true,
+		},
+		{
+			name:     "fresh install",
+			stdout:   "/home/linuxbrew/.linuxbrew/bin/brew\n/usr/bin:/bin\n",
+			wantBrew: "/home/linuxbrew/.linuxbrew/bin/brew",
+			wantPath: "/home/linuxbrew/.linuxbrew/bin:/usr/bin:/bin",
+		},
+		{
+			name: "not installed",
+			exit: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			if tt.exit != 0 {
+				conn.OnPrefix("brew=$(command -v brew)").Fail(tt.exit, "")
+			} else {
+				conn.OnPrefix("brew=$(command -v brew)").Return(tt.stdout)
+			}
+
+			h, err := FindHomebrew(context.Background(), conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantBrew == "" {
+				if h != nil {
+					t.Errorf("FindHomebrew() = %+v, want nil", h)
+				}
+				return
+			}
+			if h == nil || h.Brew != tt.wantBrew || h.Path != tt.wantPath || h.OnPath != tt.wantOnPath {
+				t.Fatalf("FindHomebrew() = %+v", h)
+			}
+
+			// Off the PATH, brew runs with the prefix added to it
+			if _, err := h.Connector(conn).Execute(context.Background(), "brew list"); err == nil {
+				t.Fatal("expected the unscripted command to fail")
+			}
+			cmds := conn.Commands()
+			last := cmds[len(cmds)-1]
+			if got := strings.Contains(last, "PATH="); got == tt.wantOnPath {
+				t.Errorf("command = %q, want PATH set: %v", last, !tt.wantOnPath)
+			}
+			if h.Prefix() != strings.TrimSuffix(tt.wantBrew, "/bin/brew") {
+				t.Errorf("Prefix() = %q", h.Prefix())
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Package homebrewinstall provides a module for installing Homebrew itself.
+package homebrewinstall
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Default URLs of the official install and uninstall scripts.
+const (
+	DefaultInstallURL   = "https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh"
+	DefaultUninstallURL = "https://raw.githubusercontent.com/Homebrew/install/HEAD/uninstall.sh"
+)
+
+// Module installs or uninstalls Homebrew on macOS and Linux.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "homebrew_install"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "install_url", Type: "string", Default: DefaultInstallURL, Description: "URL of the install script"},
+		{Name: "uninstall_url", Type: "string", Default: DefaultUninstallURL, Description: "URL of the uninstall script"},
+	}
+}
+
+// Run executes the homebrew_install module.
+//
+// Homebrew is installed with its official script, non-interactively. The
+// script refuses to run as root, so the task must not use become; the
+// user needs passwordless sudo, which the script uses to create the
+// prefix (on Linux without sudo, it installs into the user's home). On
+// macOS it also installs the Xcode Command Line Tools.
+//
+// Parameters:
+//   - state (string): Desired state - present, absent (default: present)
+//   - install_url (string): URL of the install script (default: the official script)
+//   - uninstall_url (string): URL of the uninstall script (default: the official script)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	state := param.String(params, "state", "present")
+	installURL := param.String(params, "install_url", DefaultInstallURL)
+	uninstallURL := param.String(params, "uninstall_url", DefaultUninstallURL)
+	check := module.IsCheckMode(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	homebrew, err := module.FindHomebrew(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == "absent" {
+		if homebrew == nil {
+			return module.Unchanged("homebrew is not installed"), nil
+		}
+		before := map[string]any{"brew": homebrew.Brew}
+		if check {
+			return module.Changed("would uninstall homebrew").WithDiff(before, nil), nil
+		}
+		if module.IsOffline(params) {
+			return nil, module.OfflineErrorf("uninstalling homebrew", "set uninstall_url to a copy of the script inside your network")
+		}
+		if err := runScript(ctx, conn, uninstallURL, "--force"); err != nil {
+			return nil, fmt.Errorf("failed to uninstall homebrew: %w", err)
+		}
+		return module.Changed("uninstalled homebrew").WithDiff(before, nil), nil
+	}
+
+	if homebrew != nil {
+		return module.Unchanged(fmt.Sprintf("homebrew is installed at %s", homebrew.Prefix())).
+			WithFacts(facts(homebrew)), nil
+	}
+	if check {
+		return module.Changed("would install homebrew").WithDiff(nil, map[string]any{"state": "present"}), nil
+	}
+	if module.IsOffline(params) {
+		return nil, module.OfflineErrorf("installing homebrew", "set install_url to a copy of the script inside your network")
+	}
+
+	if err := runScript(ctx, conn, installURL); err != nil {
+		return nil, fmt.Errorf("failed to install homebrew: %w", err)
+	}
+	homebrew, err = module.FindHomebrew(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if homebrew == nil {
+		return nil, fmt.Errorf("homebrew was installed, but brew was not found on the PATH or in %v", module.HomebrewBinDirs)
+	}
+
+	return module.Changed(fmt.Sprintf("installed homebrew at %s", homebrew.Prefix())).
+		WithDiff(nil, map[string]any{"brew": homebrew.Brew}).
+		WithFacts(facts(homebrew)), nil
+}
+
+// runScript downloads the script at url and runs it with bash
+// non-interactively, holding the brew mutex. The official scripts need
+// bash and curl.
+func runScript(ctx context.Context, conn connector.Connector, url string, args ...string) error {
+	result, err := conn.Execute(ctx, "command -v curl && command -v bash")
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("curl and bash are required to run %s", url)
+	}
+
+	cmd := fmt.Sprintf(`NONINTERACTIVE=1 bash -c "$(curl -fsSL %s)" homebrew`, shellutil.Quote(url))
+	if len(args) > 0 {
+		cmd += " " + shellutil.Join(args...)
+	}
+	result, err = module.BrewMutex.Execute(ctx, conn, cmd)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "%s failed", url)
+	}
+	return nil
+}
+
+// facts returns the facts of a Homebrew installation, so later tasks can
+// find brew before the user's profile adds it to the PATH.
+func facts(h *module.Homebrew) map[string]any {
+	return map[string]any{
+		"homebrew_prefix": h.Prefix(),
+		"homebrew_brew":   h.Brew,
+	}
+}
+
+// SupportsCheckMode reports that homebrew_install can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,278 @@
+// Package journald provides a module for managing journald settings.
+package journald
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/pkg/facts"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// keys are the settings of the [Journal] section, from journald.conf(5).
+var keys = []string{
+	"Audit", "Compress", "ForwardToConsole", "ForwardToKMsg", "ForwardToSocket",
+	"ForwardToSyslog", "ForwardToWall", "LineMax", "MaxFileSec", "MaxLevelConsole",
+	"MaxLevelKMsg", "MaxLevelSocket", "MaxLevelStore", "MaxLevelSyslog", "MaxLevelWall",
+	"MaxRetentionSec", "RateLimitBurst", "RateLimitIntervalSec", "ReadKMsg",
+	"RuntimeKeepFree", "RuntimeMaxFileSize", "RuntimeMaxFiles", "RuntimeMaxUse",
+	"Seal", "SplitMode", "Storage", "SyncIntervalSec", "SystemKeepFree",
+	"SystemMaxFileSize", "SystemMaxFiles", "SystemMaxUse", "TTYPath",
+}
+
+// Module sets keys in the [Journal] section of journald.conf and restarts
+// journald.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "journald"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "settings", Type: "map", Required: true, Description: "Keys of the [Journal] section and their values; null removes a key"},
+		{Name: "path", Type: "string", Default: "/etc/systemd/journald.conf", Description: "Config file path, or a drop-in in journald.conf.d"},
+		{Name: "restart", Type: "bool", Default: true, Description: "Restart systemd-journald after changes"},
+	}
+}
+
+// Run executes the journald module.
+//
+// A key already set is changed where it is; a new key goes after its
+// commented-out default, or at the end of the section. The file is
+// created if it is missing, as drop-ins usually are.
+//
+// Parameters:
+//   - settings (map, required): Keys and values; bools become yes/no, null removes a key
+//   - path (string): Config file path (default: /etc/systemd/journald.conf)
+//   - restart (bool): Restart systemd-journald after changes (default: true)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	raw := param.Map(params, "settings")
+	file := param.String(params, "path", "/etc/systemd/journald.conf")
+	restart := param.Bool(params, "restart", true)
+	check := module.IsCheckMode(params)
+
+	if len(raw) == 0 {
+		return nil, module.ParamErrorf("settings", "'settings' parameter is required")
+	}
+	names := make([]string, 0, len(raw))
+	for k := range raw {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if !isKey(k) {
+			return nil, module.ParamErrorf("settings", "unknown journald setting '%s'", k)
+		}
+	}
+
+	content, exists, err := module.ReadFile(ctx, conn, file)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := module.SplitLines(string(content))
+	var changed []string
+	before := map[string]any{}
+	after := map[string]any{}
+	for _, k := range names {
+		var value *string
+		if v := raw[k]; v != nil {
+			s := formatValue(v)
+			value = &s
+		}
+		var old *string
+		lines, old = set(lines, k, value)
+		if !equal(old, value) {
+			changed = append(changed, k)
+			before[k], after[k] = diffValue(old), diffValue(value)
+		}
+	}
+
+	newContent := []byte(module.JoinLines(lines))
+	if exists && bytes.Equal(newContent, content) {
+		return module.Unchanged("settings already in desired state"), nil
+	}
+	if !exists && len(changed) == 0 {
+		return module.Unchanged("settings already absent"), nil
+	}
+
+	msg := fmt.Sprintf("set %s in %s", strings.Join(changed, ", "), file)
+	data := map[string]any{"path": file}
+	if check {
+		return module.ChangedWithData("would "+msg, data).WithDiff(before, after), nil
+	}
+
+	if err := module.WriteFile(ctx, conn, file, newContent, ""); err != nil {
+		return nil, err
+	}
+
+	if restart {
+		mgr, err := facts.ServiceManager(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if mgr == facts.ServiceSystemd {
+			if err := module.RunCommand(ctx, conn, "systemctl restart systemd-journald"); err != nil {
+				return nil, err
+			}
+			data["restarted"] = true
+			msg += "; restarted systemd-journald"
+		}
+	}
+	return module.ChangedWithData(msg, data).WithDiff(before, after), nil
+}
+
+// isKey reports whether k is a key of the [Journal] section.
+func isKey(k string) bool {
+	return slices.Contains(keys, k)
+}
+
+// set sets key to value in the [Journal] section of lines, adding the
+// section if it is missing, or removes it if value is nil. It returns the
+// new lines and the value key had.
+func set(lines []string, key string, value *string) ([]string, *string) {
+	start, end := section(lines)
+	if start < 0 {
+		if value == nil {
+			return lines, nil
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "[Journal]")
+		start, end = len(lines), len(lines)
+	}
+
+	// journald uses the last assignment
+	var old *string
+	var found []int
+	pos := -1
+	for i := start; i < end; i++ {
+		if trimmed := strings.TrimSpace(lines[i]); strings.HasPrefix(trimmed, "#") {
+			// The commented-out default: #SystemMaxUse=
+			if k, _, ok := splitKey(strings.TrimLeft(trimmed, "#")); ok && k == key {
+				pos = i + 1
+			}
+			continue
+		}
+		if k, v, ok := splitKey(lines[i]); ok && k == key {
+			found = append(found, i)
+			old = &v
+		}
+	}
+	if len(found) <= 1 && equal(old, value) {
+		return lines, old
+	}
+
+	switch {
+	case len(found) > 0:
+		pos = found[0]
+	case pos < 0:
+		// At the end of the section, before trailing blank lines
+		pos = end
+		for pos > start && strings.TrimSpace(lines[pos-1]) == "" {
+			pos--
+		}
+	}
+
+	var result []string
+	for i, line := range lines {
+		if i == pos && value != nil {
+			result = append(result, key+"="+*value)
+		}
+		if !slices.Contains(found, i) {
+			result = append(result, line)
+		}
+	}
+	if pos == len(lines) && value != nil {
+		result = append(result, key+"="+*value)
+	}
+	return result, old
+}
+
+// section returns the lines [start, end) of the [Journal] section, or -1
+// if there is none.
+func section(lines []string) (int, int) {
+	start := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		if start >= 0 {
+			return start, i
+		}
+		if trimmed == "[Journal]" {
+			start = i + 1
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(lines)
+}
+
+// splitKey splits a Key=value line. Comments and other lines are not
+// assignments.
+func splitKey(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+		return "", "", false
+	}
+	k, v, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(k), strings.TrimSpace(v), true
+}
+
+// formatValue renders a value the way systemd expects it.
+func formatValue(v any) string {
+	if b, ok := v.(bool); ok {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// equal reports whether two optional values are the same.
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// diffValue returns an optional value for Result.Before or Result.After.
+func diffValue(v *string) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// SupportsCheckMode reports that journald can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,261 @@
+// Package knownhosts provides a module for managing SSH known_hosts entries.
+package knownhosts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a known_hosts entry.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the host keys are known
+	StateAbsent  State = "absent"  // Ensure no keys are known for the host
+)
+
+// Module manages host keys in an OpenSSH known_hosts file.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "known_hosts"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "Hostname or IP address"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "key", Type: "string", Description: "Host key line(s); scanned with `ssh-keyscan` when omitted"},
+		{Name: "port", Type: "int", Default: 22, Description: "SSH port"},
+		{Name: "key_types", Type: "string", Description: "Key types to scan (e.g., `ed25519,rsa`)"},
+		{Name: "path", Type: "string", Default: "~/.ssh/known_hosts", Description: "known_hosts file path"},
+	}
+}
+
+// Run executes the known_hosts module.
+//
+// Parameters:
+//   - name (string, required): Hostname or IP address
+//   - state (string): Desired state - present, absent (default: present)
+//   - key (string): Host key line(s) ("[host] keytype base64"); scanned with ssh-keyscan when omitted
+//   - port (int): SSH port (default: 22)
+//   - key_types (string): Comma-separated key types for ssh-keyscan (e.g., "ed25519,rsa")
+//   - path (string): known_hosts file path (default: ~/.ssh/known_hosts)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	switch state {
+	case StatePresent, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	port := param.Int(params, "port", 22)
+	keyTypes := param.String(params, "key_types", "")
+	keyParam := param.String(params, "key", "")
+
+	path, err := module.ExpandHome(ctx, conn, param.String(params, "path", "~/.ssh/known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Hosts on non-standard ports are recorded as [host]:port
+	entryHost := name
+	if port != 22 {
+		entryHost = fmt.Sprintf("[%s]:%d", name, port)
+	}
+
+	existing, err := findKeys(ctx, conn, path, entryHost)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == StateAbsent {
+		if len(existing) == 0 {
+			return module.Unchanged("host already absent"), nil
+		}
+		if err := removeHost(ctx, conn, path, entryHost); err != nil {
+			return nil, err
+		}
+		return module.ChangedWithData(fmt.Sprintf("removed keys for %s", entryHost), map[string]any{
+			"path": path,
+			"name": entryHost,
+		}), nil
+	}
+
+	// Determine desired keys
+	var desired []string
+	if keyParam != "" {
+		desired = parseKeys(keyParam)
+	} else {
+		desired, err = scanKeys(ctx, conn, name, port, keyTypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(desired) == 0 {
+		return nil, fmt.Errorf("no host keys found for %s", entryHost)
+	}
+
+	if sameKeys(existing, desired) {
+		return module.Unchanged("host keys already known"), nil
+	}
+
+	// Replace any stale entries for the host with the desired keys
+	if len(existing) > 0 {
+		if err := removeHost(ctx, conn, path, entryHost); err != nil {
+			return nil, err
+		}
+	}
+	if err := appendKeys(ctx, conn, path, entryHost, desired); err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("added keys for %s", entryHost)
+	if len(existing) > 0 {
+		msg = fmt.Sprintf("updated keys for %s", entryHost)
+	}
+
+	return module.ChangedWithData(msg, map[string]any{
+		"path": path,
+		"name": entryHost,
+		"keys": desired,
+	}), nil
+}
+
+// findKeys returns the "keytype base64" pairs recorded for host.
+// ssh-keygen -F also matches hashed entries.
+func findKeys(ctx context.Context, conn connector.Connector, path, host string) ([]string, error) {
+	cmd := fmt.Sprintf("test -f %[1]s && ssh-keygen -F %[2]s -f %[1]s 2>/dev/null || true", shellutil.Quote(path), shellutil.Quote(host))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up known hosts: %w", err)
+	}
+	return parseKeys(result.Stdout), nil
+}
+
+// scanKeys fetches the host's public keys with ssh-keyscan on the target.
+func scanKeys(ctx context.Context, conn connector.Connector, name string, port int, keyTypes string) ([]string, error) {
+	cmd := fmt.Sprintf("ssh-keyscan -T 10 -p %d", port)
+	if keyTypes != "" {
+		cmd += " -t " + shellutil.Quote(keyTypes)
+	}
+	cmd += " " + shellutil.Quote(name) + " 2>/dev/null"
+
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan host keys: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "ssh-keyscan failed for %s", name)
+	}
+	return parseKeys(result.Stdout), nil
+}
+
+// parseKeys extracts "keytype base64" pairs from known_hosts formatted lines.
+// Lines may or may not start with a host field.
+func parseKeys(content string) []string {
+	var keys []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		// Skip marker fields such as @cert-authority
+		if strings.HasPrefix(fields[0], "@") {
+			fields = fields[1:]
+		}
+		if len(fields) >= 2 && isKeyType(fields[0]) {
+			keys = append(keys, fields[0]+" "+fields[1])
+		} else if len(fields) >= 3 && isKeyType(fields[1]) {
+			keys = append(keys, fields[1]+" "+fields[2])
+		}
+	}
+	return keys
+}
+
+// isKeyType reports whether s looks like an SSH public key algorithm name.
+func isKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-") || strings.HasPrefix(s, "sk-")
+}
+
+// sameKeys reports whether both lists contain the same set of keys.
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// removeHost deletes all entries for host, discarding ssh-keygen's backup file.
+func removeHost(ctx context.Context, conn connector.Connector, path, host string) error {
+	cmd := fmt.Sprintf("ssh-keygen -R %s -f %s >/dev/null 2>&1; rm -f %s", shellutil.Quote(host), shellutil.Quote(path), shellutil.Quote(path+".old"))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to remove host keys: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "failed to remove host keys")
+	}
+	return nil
+}
+
+// appendKeys adds keys for host to the end of the known_hosts file. A
+// missing ~/.ssh is created private; an existing directory and file keep
+// their permissions.
+func appendKeys(ctx context.Context, conn connector.Connector, file, host string, keys []string) error {
+	content, _, err := module.ReadFile(ctx, conn, file)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(content)
+	if buf.Len() > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	for _, key := range keys {
+		fmt.Fprintf(buf, "%s %s\n", host, key)
+	}
+
+	if err := module.EnsureDir(ctx, conn, path.Dir(file), 0o700); err != nil {
+		return err
+	}
+	if err := module.WriteFile(ctx, conn, file, buf.Bytes(), ""); err != nil {
+		return fmt.Errorf("failed to add host keys: %w", err)
+	}
+	return nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -0,0 +1,96 @@
+package knownhosts
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+const testTmp = "/tmp/tmp.knownhosts"
+
+func TestRunAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string // empty if the file does not exist
+		want    string
+	}{
+		{
+			name:    "system file",
+			file:    "/etc/ssh/ssh_known_hosts",
+			content: "other.example ssh-ed25519 AAAAother\n",
+			want:    "other.example ssh-ed25519 AAAAother\ngit.example.com ssh-ed25519 AAAAgit\n",
+		},
+		{
+			name:    "no trailing newline",
+			file:    "/etc/ssh/ssh_known_hosts",
+			content: "other.example ssh-ed25519 AAAAother",
+			want:    "other.example ssh-ed25519 AAAAother\ngit.example.com ssh-ed25519 AAAAgit\n",
+		},
+		{
+			name: "new file",
+			file: "/home/alice/.ssh/known_hosts",
+			want: "git.example.com ssh-ed25519 AAAAgit\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			if tt.content != "" {
+				conn.SetFile(tt.file, []byte(tt.content), 0o600)
+			} else {
+				conn.On("test -f '"+tt.file+"'").Fail(1, "")
+			}
+			dir := path.Dir(tt.file)
+			conn.OnPrefix("test -f '" + tt.file + "' && ssh-keygen -F").Return("")
+			conn.On("mktemp").Return(testTmp + "\n")
+			conn.On("test -d '" + dir + "' || mkdir -p -m 700 '" + dir + "'")
+			conn.On("mkdir -p '" + dir + "' && cat '" + testTmp + "' > '" + tt.file + "'")
+			conn.Default(connector.Result{})
+
+			result, err := (&Module{}).Run(context.Background(), conn, map[string]any{
+				"name": "git.example.com",
+				"key":  "ssh-ed25519 AAAAgit",
+				"path": tt.file,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Changed {
+				t.Error("result not changed")
+			}
+			if got, _ := conn.File(testTmp); string(got) != tt.want {
+				t.Errorf("written content = %q, want %q", got, tt.want)
+			}
+			for _, cmd := range conn.Commands() {
+				if strings.Contains(cmd, "chmod") {
+					t.Errorf("unexpected command %q, want existing permissions kept", cmd)
+				}
+			}
+			conn.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunKnown(t *testing.T) {
+	conn := connectortest.New()
+	conn.OnPrefix("test -f '/etc/ssh/ssh_known_hosts' && ssh-keygen -F 'git.example.com'").
+		Return("# Host git.example.com found: line 1\ngit.example.com ssh-ed25519 AAAAgit\n")
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{
+		"name": "git.example.com",
+		"key":  "ssh-ed25519 AAAAgit",
+		"path": "/etc/ssh/ssh_known_hosts",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("result changed: %s", result.Message)
+	}
+}
@@ -0,0 +1,268 @@
+// Package lineinfile provides a module for managing single lines in text
+// files.
+package lineinfile
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module makes sure a line is in a file, replacing the line a regular
+// expression matches, or removes the lines matching it.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "lineinfile"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	params := []module.ParamSpec{
+		{Name: "path", Type: "string", Required: true, Description: "File to edit"},
+		{Name: "line", Type: "string", Description: "Line to put in the file (required with state=present)"},
+		{Name: "regexp", Type: "string", Description: "Regular expression of the line to replace or remove (Go RE2 syntax)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Whether the line should be in the file"},
+		{Name: "insertafter", Type: "string", Default: "EOF", Description: "Insert a new line after the last line matching this expression, or at the end (`EOF`)"},
+		{Name: "insertbefore", Type: "string", Description: "Insert a new line before the last line matching this expression, or at the start (`BOF`)"},
+		{Name: "create", Type: "bool", Default: false, Description: "Create the file if it does not exist"},
+		{Name: "backup", Type: "bool", Default: false, Description: "Create backup before changing the file"},
+	}
+	return append(params, module.BackupParamSpecs()...)
+}
+
+// Run executes the lineinfile module.
+//
+// With state=present and regexp, the last line matching regexp is replaced
+// with line. If no line matches and line is not in the file yet, it is
+// inserted after the last line matching insertafter, or before the last
+// line matching insertbefore; if that expression does not match either,
+// it is appended. With state=absent, every line matching regexp, or equal
+// to line, is removed.
+//
+// Parameters:
+//   - path (string, required): File to edit on the target
+//   - line (string): Line to put in the file (required with state=present)
+//   - regexp (string): Regular expression of the line to replace or remove
+//   - state (string): Desired state - present, absent (default: present)
+//   - insertafter (string): Expression or EOF to insert after (default: EOF)
+//   - insertbefore (string): Expression or BOF to insert before
+//   - create (bool): Create the file if it does not exist (default: false)
+//   - backup (bool): Create backup before changing the file (default: false)
+//   - backup_dir (string): Directory to write backups to (default: next to path)
+//   - backup_keep (int): Number of backups to keep, 0 for all (default: 0)
+//
+// The file is rewritten in place, so it keeps its owner, mode, and other
+// attributes.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	file, err := param.Required(params, "path")
+	if err != nil {
+		return nil, err
+	}
+	state := param.String(params, "state", "present")
+	_, hasLine := params["line"]
+	line := param.String(params, "line", "")
+	create := param.Bool(params, "create", false)
+	backup := param.Bool(params, "backup", false)
+	backupOpts, err := module.BackupParams(params)
+	if err != nil {
+		return nil, err
+	}
+	check := module.IsCheckMode(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if strings.ContainsAny(line, "\r\n") {
+		return nil, module.ParamErrorf("line", "'line' must be a single line")
+	}
+
+	var re *regexp.Regexp
+	if s := param.String(params, "regexp", ""); s != "" {
+		if re, err = compile("regexp", s); err != nil {
+			return nil, err
+		}
+	}
+	if state == "present" && !hasLine {
+		return nil, module.ParamErrorf("line", "'line' parameter is required with state=present")
+	}
+	if state == "absent" && re == nil && !hasLine {
+		return nil, module.ParamErrorf("line", "'line' or 'regexp' is required with state=absent")
+	}
+
+	insertAfter := param.String(params, "insertafter", "")
+	insertBefore := param.String(params, "insertbefore", "")
+	if insertAfter != "" && insertBefore != "" {
+		return nil, module.ParamErrorf("insertafter", "'insertafter' and 'insertbefore' are mutually exclusive")
+	}
+	anchor := &insertion{}
+	switch {
+	case insertBefore == "BOF":
+		anchor.bof = true
+	case insertBefore != "":
+		if anchor.re, err = compile("insertbefore", insertBefore); err != nil {
+			return nil, err
+		}
+		anchor.before = true
+	case insertAfter != "" && insertAfter != "EOF":
+		if anchor.re, err = compile("insertafter", insertAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	content, exists, err := module.ReadFile(ctx, conn, file)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if state == "absent" {
+			return module.Unchanged(fmt.Sprintf("%s does not exist", file)), nil
+		}
+		if !create {
+			return nil, fmt.Errorf("file not found: %s (set create: true to create it)", file)
+		}
+	}
+
+	lines := module.SplitLines(string(content))
+	var msg string
+	if state == "absent" {
+		var removed int
+		lines, removed = remove(lines, re, line)
+		if removed == 0 {
+			return module.Unchanged("line already absent"), nil
+		}
+		msg = fmt.Sprintf("removed %d line(s) from %s", removed, file)
+	} else {
+		var action string
+		lines, action = ensure(lines, re, line, anchor)
+		if action == "" {
+			return module.Unchanged("line already present"), nil
+		}
+		msg = fmt.Sprintf("%s line in %s", action, file)
+	}
+
+	newContent := []byte(module.JoinLines(lines))
+	var before map[string]any
+	if exists {
+		before = map[string]any{"content": module.DiffText(content)}
+	}
+	after := map[string]any{"content": module.DiffText(newContent)}
+	data := map[string]any{"path": file}
+	if check {
+		return module.ChangedWithData("would "+msg, data).WithDiff(before, after), nil
+	}
+
+	if exists && backup {
+		backupFile, err := module.Backup(ctx, conn, file, backupOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create backup: %w", err)
+		}
+		data["backup_file"] = backupFile
+	}
+	if err := module.WriteFile(ctx, conn, file, newContent, ""); err != nil {
+		return nil, err
+	}
+	return module.ChangedWithData(msg, data).WithDiff(before, after), nil
+}
+
+// insertion is where a new line goes: after (or before) the last line
+// matching re, at the start of the file, or by default at the end.
+type insertion struct {
+	re     *regexp.Regexp
+	before bool
+	bof    bool
+}
+
+// index returns the index of lines to insert a new line at.
+func (a *insertion) index(lines []string) int {
+	if a.bof {
+		return 0
+	}
+	if a.re != nil {
+		if i := lastMatch(lines, a.re); i >= 0 {
+			if a.before {
+				return i
+			}
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+// ensure puts line into lines: in place of the last line matching re, or
+// where anchor says if line is missing. It returns the new lines and what
+// it did ("replaced" or "inserted"), or "" if line was in place.
+func ensure(lines []string, re *regexp.Regexp, line string, anchor *insertion) ([]string, string) {
+	if re != nil {
+		if i := lastMatch(lines, re); i >= 0 {
+			if lines[i] == line {
+				return lines, ""
+			}
+			lines = slices.Clone(lines)
+			lines[i] = line
+			return lines, "replaced"
+		}
+	}
+	if slices.Contains(lines, line) {
+		return lines, ""
+	}
+	return slices.Insert(slices.Clone(lines), anchor.index(lines), line), "inserted"
+}
+
+// remove drops the lines matching re, or equal to line if re is nil. It
+// returns the remaining lines and how many were removed.
+func remove(lines []string, re *regexp.Regexp, line string) ([]string, int) {
+	kept := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if (re != nil && re.MatchString(l)) || (re == nil && l == line) {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	return kept, len(lines) - len(kept)
+}
+
+// lastMatch returns the index of the last line matching re, or -1.
+func lastMatch(lines []string, re *regexp.Regexp) int {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if re.MatchString(lines[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// compile compiles the expression of param, which is matched against one
+// line at a time.
+func compile(param, expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, module.ParamErrorf(param, "invalid regular expression in '%s': %v", param, err)
+	}
+	return re, nil
+}
+
+// SupportsCheckMode reports that lineinfile can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
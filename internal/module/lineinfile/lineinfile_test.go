@@ -0,0 +1,208 @@
+package lineinfile
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const (
+	testFile = "/etc/app.conf"
+	testTmp  = "/tmp/tmp.lineinfile"
+)
+
+// runModule runs lineinfile against testFile with the given content, or
+// without the file if content is nil, and returns the result and the
+// content written, if any.
+func runModule(t *testing.T, content *string, params map[string]any) (*module.Result, *string, error) {
+	t.Helper()
+
+	conn := connectortest.New()
+	if content == nil {
+		conn.On("test -f '"+testFile+"'").Fail(1, "")
+	} else {
+		conn.SetFile(testFile, []byte(*content), 0o644)
+	}
+	conn.On("mktemp").Return(testTmp + "\n")
+	conn.Default(connector.Result{})
+
+	params["path"] = testFile
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	written, ok := conn.File(testTmp)
+	if !ok {
+		return result, nil, err
+	}
+	s := string(written)
+	return result, &s, err
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string
+		params  map[string]any
+		want    string
+	}{
+		{
+			name:    "append",
+			content: ptr("a=1\n"),
+			params:  map[string]any{"line": "b=2"},
+			want:    "a=1\nb=2\n",
+		},
+		{
+			name:    "regexp replaces the last match",
+			content: ptr("port=1\nx=0\nport=2\n"),
+			params:  map[string]any{"regexp": `^port=`, "line": "port=22"},
+			want:    "port=1\nx=0\nport=22\n",
+		},
+		{
+			name:    "regexp without match appends",
+			content: ptr("a=1\n"),
+			params:  map[string]any{"regexp": `^port=`, "line": "port=22"},
+			want:    "a=1\nport=22\n",
+		},
+		{
+			name:    "insertafter",
+			content: ptr("[main]\na=1\n[other]\n"),
+			params:  map[string]any{"line": "b=2", "insertafter": `^\[main\]`},
+			want:    "[main]\nb=2\na=1\n[other]\n",
+		},
+		{
+			name:    "insertbefore",
+			content: ptr("a=1\n[other]\n"),
+			params:  map[string]any{"line": "b=2", "insertbefore": `^\[other\]`},
+			want:    "a=1\nb=2\n[other]\n",
+		},
+		{
+			name:    "insertbefore BOF",
+			content: ptr("a=1\n"),
+			params:  map[string]any{"line": "# managed", "insertbefore": "BOF"},
+			want:    "# managed\na=1\n",
+		},
+		{
+			name:    "insertafter without match appends",
+			content: ptr("a=1\n"),
+			params:  map[string]any{"line": "b=2", "insertafter": `^\[main\]`},
+			want:    "a=1\nb=2\n",
+		},
+		{
+			name:    "absent by regexp",
+			content: ptr("a=1\n#x\nb=2\n#y\n"),
+			params:  map[string]any{"regexp": `^#`, "state": "absent"},
+			want:    "a=1\nb=2\n",
+		},
+		{
+			name:    "absent by line",
+			content: ptr("a=1\nb=2\n"),
+			params:  map[string]any{"line": "a=1", "state": "absent"},
+			want:    "b=2\n",
+		},
+		{
+			name:    "create",
+			content: nil,
+			params:  map[string]any{"line": "a=1", "create": true},
+			want:    "a=1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, written, err := runModule(t, tt.content, maps.Clone(tt.params))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Changed {
+				t.Fatalf("Changed = false, want true (%s)", result.Message)
+			}
+			if written == nil || *written != tt.want {
+				t.Fatalf("written = %q, want %q", deref(written), tt.want)
+			}
+
+			// Running again on the result changes nothing
+			result, written, err = runModule(t, written, maps.Clone(tt.params))
+			if err != nil {
+				t.Fatalf("unexpected error on second run: %v", err)
+			}
+			if result.Changed || written != nil {
+				t.Errorf("second run changed the file: %s", result.Message)
+			}
+		})
+	}
+}
+
+func TestRunUnchanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string
+		params  map[string]any
+	}{
+		{"line present", ptr("a=1\nb=2\n"), map[string]any{"line": "a=1"}},
+		{"regexp line present", ptr("port=22\n"), map[string]any{"regexp": `^port=`, "line": "port=22"}},
+		{"already absent", ptr("a=1\n"), map[string]any{"regexp": `^#`, "state": "absent"}},
+		{"absent missing file", nil, map[string]any{"line": "a=1", "state": "absent"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, written, err := runModule(t, tt.content, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed || written != nil {
+				t.Errorf("Changed = %v, written = %q, want no change", result.Changed, deref(written))
+			}
+		})
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	params := map[string]any{"line": "b=2", module.CheckModeParam: true}
+	result, written, err := runModule(t, ptr("a=1\n"), params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	if written != nil {
+		t.Errorf("check mode wrote %q", *written)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string
+		params  map[string]any
+	}{
+		{"missing file", nil, map[string]any{"line": "a=1"}},
+		{"no line", ptr(""), map[string]any{"regexp": "a"}},
+		{"multiline line", ptr(""), map[string]any{"line": "a\nb"}},
+		{"bad regexp", ptr(""), map[string]any{"line": "a", "regexp": "("}},
+		{"both anchors", ptr(""), map[string]any{"line": "a", "insertafter": "x", "insertbefore": "y"}},
+		{"bad state", ptr(""), map[string]any{"line": "a", "state": "latest"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := runModule(t, tt.content, tt.params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
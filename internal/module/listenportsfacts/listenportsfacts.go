@@ -0,0 +1,80 @@
+// Package listenportsfacts provides a module that gathers listening ports
+// and the processes holding them as facts.
+package listenportsfacts
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/pkg/facts"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module lists listening ports into facts.tcp_listen and facts.udp_listen.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "listen_ports_facts"
+}
+
+// Params describes the module parameters: there are none.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{}
+}
+
+// Run executes the listen_ports_facts module. It takes no parameters.
+//
+// The ports are returned as the facts "tcp_listen" and "udp_listen": maps
+// from port number to the addresses it is bound to and the process holding
+// it.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	ports, err := facts.ListeningPorts(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	listen := map[string]map[string]any{
+		"tcp": make(map[string]any),
+		"udp": make(map[string]any),
+	}
+	for _, p := range ports {
+		byPort := listen[p.Protocol]
+		key := strconv.Itoa(p.Port)
+		// IPv4 and IPv6 sockets of a port are normally the same process
+		if entry, ok := byPort[key].(map[string]any); ok {
+			entry["addresses"] = append(entry["addresses"].([]any), p.Address)
+			continue
+		}
+		byPort[key] = map[string]any{
+			"port":      p.Port,
+			"addresses": []any{p.Address},
+			"pid":       p.PID,
+			"name":      p.Process,
+		}
+	}
+
+	msg := fmt.Sprintf("%d TCP and %d UDP ports in use", len(listen["tcp"]), len(listen["udp"]))
+	return module.Unchanged(msg).WithFacts(map[string]any{
+		"tcp_listen": listen["tcp"],
+		"udp_listen": listen["udp"],
+	}), nil
+}
+
+// SupportsCheckMode reports that listen_ports_facts can run in check mode;
+// it only lists sockets.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
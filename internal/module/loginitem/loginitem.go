@@ -0,0 +1,174 @@
+// Package loginitem provides a module for managing macOS login items.
+package loginitem
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a login item.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the item opens at login
+	StateAbsent  State = "absent"  // Ensure the item does not open at login
+)
+
+// Module manages applications that open at login, using System Events.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "login_item"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "path", Type: "string", Description: "Application to open at login"},
+		{Name: "name", Type: "string", Description: "Login item name"},
+		{Name: "hidden", Type: "bool", Default: false, Description: "Hide the application after launch"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+	}
+}
+
+// Run executes the login_item module.
+//
+// Parameters:
+//   - path (string): Application to open at login (required when state=present)
+//   - name (string): Login item name (default: path basename without extension)
+//   - hidden (bool): Hide the application after launch (default: false)
+//   - state (string): Desired state - present, absent (default: present)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	path := param.String(params, "path", "")
+	name := param.String(params, "name", "")
+	hidden := param.Bool(params, "hidden", false)
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+
+	switch state {
+	case StatePresent:
+		if path == "" {
+			return nil, module.ParamErrorf("path", "'path' parameter is required when state=present")
+		}
+	case StateAbsent:
+		if path == "" && name == "" {
+			return nil, module.ParamErrorf("name", "'name' or 'path' parameter is required when state=absent")
+		}
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	if name == "" {
+		base := filepath.Base(path)
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	items, err := listItems(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	_, exists := items[name]
+
+	if state == StateAbsent {
+		if !exists {
+			return module.Unchanged(fmt.Sprintf("%s is not a login item", name)), nil
+		}
+		if err := systemEvents(ctx, conn, fmt.Sprintf("delete login item %s", appleScriptString(name))); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed login item %s", name)), nil
+	}
+
+	if exists {
+		current := items[name]
+		if strings.TrimSuffix(current.path, "/") == strings.TrimSuffix(path, "/") && current.hidden == hidden {
+			return module.Unchanged(fmt.Sprintf("%s already a login item", name)), nil
+		}
+		// Login item properties are read-only, so replace the item
+		if err := systemEvents(ctx, conn, fmt.Sprintf("delete login item %s", appleScriptString(name))); err != nil {
+			return nil, err
+		}
+	}
+
+	script := fmt.Sprintf("make login item at end with properties {name:%s, path:%s, hidden:%t}",
+		appleScriptString(name), appleScriptString(path), hidden)
+	if err := systemEvents(ctx, conn, script); err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return module.Changed(fmt.Sprintf("updated login item %s", name)), nil
+	}
+	return module.Changed(fmt.Sprintf("added login item %s", name)), nil
+}
+
+// loginItem holds the properties of an existing login item.
+type loginItem struct {
+	path   string
+	hidden bool
+}
+
+// listItems returns the current login items keyed by name.
+func listItems(ctx context.Context, conn connector.Connector) (map[string]loginItem, error) {
+	// One line per item: name<TAB>path<TAB>hidden
+	script := `tell application "System Events"
+set out to ""
+repeat with i in login items
+set out to out & (name of i) & tab & (path of i) & tab & (hidden of i) & linefeed
+end repeat
+return out
+end tell`
+
+	result, err := conn.Execute(ctx, "osascript -e "+shellutil.Quote(script))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login items: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "failed to list login items")
+	}
+
+	items := make(map[string]loginItem)
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			continue
+		}
+		items[parts[0]] = loginItem{path: parts[1], hidden: parts[2] == "true"}
+	}
+	return items, nil
+}
+
+// systemEvents runs an AppleScript statement inside a System Events tell block.
+func systemEvents(ctx context.Context, conn connector.Connector, statement string) error {
+	script := fmt.Sprintf("tell application \"System Events\" to %s", statement)
+	result, err := conn.Execute(ctx, "osascript -e "+shellutil.Quote(script))
+	if err != nil {
+		return fmt.Errorf("failed to run osascript: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "osascript failed")
+	}
+	return nil
+}
+
+// appleScriptString quotes a string as an AppleScript literal.
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
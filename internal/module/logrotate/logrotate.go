@@ -0,0 +1,238 @@
+// Package logrotate provides a module for managing logrotate snippets.
+package logrotate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module writes logrotate snippets into /etc/logrotate.d.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "logrotate"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "Name of the snippet in the config directory"},
+		{Name: "path", Type: "list", Description: "Log files or globs to rotate (required for state=present)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "frequency", Type: "string", Choices: []string{"hourly", "daily", "weekly", "monthly", "yearly"}, Description: "How often to rotate"},
+		{Name: "rotate", Type: "int", Description: "Number of rotated logs to keep"},
+		{Name: "size", Type: "string", Description: "Rotate when the log grows past this size, e.g. 100M"},
+		{Name: "maxsize", Type: "string", Description: "Also rotate before the next period past this size"},
+		{Name: "maxage", Type: "int", Description: "Remove rotated logs older than this many days"},
+		{Name: "compress", Type: "bool", Description: "Compress rotated logs"},
+		{Name: "delaycompress", Type: "bool", Description: "Compress a rotated log at the next rotation"},
+		{Name: "missingok", Type: "bool", Description: "Do not fail on missing logs"},
+		{Name: "notifempty", Type: "bool", Description: "Do not rotate empty logs"},
+		{Name: "copytruncate", Type: "bool", Description: "Copy the log and truncate it instead of moving it"},
+		{Name: "dateext", Type: "bool", Description: "Name rotated logs by date"},
+		{Name: "sharedscripts", Type: "bool", Description: "Run the scripts once for all logs"},
+		{Name: "create", Type: "string", Description: "Mode, owner, and group of the new log, e.g. `0640 www-data adm`"},
+		{Name: "su", Type: "string", Description: "User and group to rotate as, e.g. `www-data adm`"},
+		{Name: "prerotate", Type: "string", Description: "Script to run before rotating"},
+		{Name: "postrotate", Type: "string", Description: "Script to run after rotating"},
+		{Name: "options", Type: "list", Description: "Other directives, one per item"},
+		{Name: "dir", Type: "string", Default: "/etc/logrotate.d", Description: "Config directory"},
+		{Name: "validate", Type: "bool", Default: true, Description: "Check the snippet with `logrotate -d` before writing it"},
+	}
+}
+
+// Run executes the logrotate module.
+//
+// The snippet is written from the parameters, so directives not given are
+// removed from it and fall back to the defaults of logrotate.conf. A bool
+// set to false writes the negated directive, e.g. nocompress.
+//
+// Parameters:
+//   - name (string, required): Name of the snippet in the config directory
+//   - path ([]string): Log files or globs (required for state=present)
+//   - state (string): Desired state - present, absent (default: present)
+//   - frequency (string): hourly, daily, weekly, monthly, or yearly
+//   - rotate (int): Number of rotated logs to keep
+//   - size, maxsize (string): Size thresholds, e.g. 100M
+//   - maxage (int): Remove rotated logs older than this many days
+//   - compress, delaycompress, missingok, notifempty, copytruncate, dateext,
+//     sharedscripts (bool): Directives and, when false, their negations
+//   - create (string): Mode, owner, and group of the new log
+//   - su (string): User and group to rotate as
+//   - prerotate, postrotate (string): Scripts to run around rotation
+//   - options ([]string): Other directives
+//   - dir (string): Config directory (default: /etc/logrotate.d)
+//   - validate (bool): Check the snippet with logrotate -d first (default: true)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+	state := param.String(params, "state", "present")
+	dir := param.String(params, "dir", "/etc/logrotate.d")
+	validate := param.Bool(params, "validate", true)
+	check := module.IsCheckMode(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		return nil, module.ParamErrorf("name", "invalid name '%s': must be a file name", name)
+	}
+	file := path.Join(dir, name)
+
+	current, exists, err := module.ReadFile(ctx, conn, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == "absent" {
+		if !exists {
+			return module.Unchanged("snippet already absent"), nil
+		}
+		before := map[string]any{"content": module.DiffText(current)}
+		if check {
+			return module.Changed(fmt.Sprintf("would remove %s", file)).WithDiff(before, nil), nil
+		}
+		if err := module.RunCommand(ctx, conn, "rm -f "+shellutil.Quote(file)); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed %s", file)).WithDiff(before, nil), nil
+	}
+
+	content, err := render(params)
+	if err != nil {
+		return nil, err
+	}
+	if exists && bytes.Equal(content, current) {
+		return module.Unchanged("snippet already in desired state"), nil
+	}
+
+	var before map[string]any
+	if exists {
+		before = map[string]any{"content": module.DiffText(current)}
+	}
+	after := map[string]any{"content": module.DiffText(content)}
+	msg := fmt.Sprintf("wrote %s", file)
+	if check {
+		return module.ChangedWithData(fmt.Sprintf("would write %s", file), map[string]any{"path": file}).WithDiff(before, after), nil
+	}
+
+	// -d only reports what would be rotated, and sbin may not be on the
+	// PATH of non-root users
+	var validateCmd string
+	if validate {
+		validateCmd = `PATH="$PATH:/usr/sbin:/sbin" logrotate -d %s`
+	}
+	if err := module.WriteFile(ctx, conn, file, content, validateCmd); err != nil {
+		return nil, err
+	}
+	if !exists {
+		// logrotate ignores snippets that others can write to
+		if err := module.RunCommand(ctx, conn, "chmod 0644 "+shellutil.Quote(file)); err != nil {
+			return nil, err
+		}
+	}
+	return module.ChangedWithData(msg, map[string]any{"path": file}).WithDiff(before, after), nil
+}
+
+// flags are the bool directives and their negations.
+var flags = []struct{ name, negation string }{
+	{"compress", "nocompress"},
+	{"delaycompress", "nodelaycompress"},
+	{"missingok", "nomissingok"},
+	{"notifempty", "ifempty"},
+	{"copytruncate", "nocopytruncate"},
+	{"dateext", "nodateext"},
+	{"sharedscripts", "nosharedscripts"},
+}
+
+// render returns the snippet for params.
+func render(params map[string]any) ([]byte, error) {
+	paths := param.StringList(params, "path")
+	if len(paths) == 0 {
+		return nil, module.ParamErrorf("path", "'path' parameter is required for state=present")
+	}
+
+	var directives []string
+	if frequency := param.String(params, "frequency", ""); frequency != "" {
+		switch frequency {
+		case "hourly", "daily", "weekly", "monthly", "yearly":
+			directives = append(directives, frequency)
+		default:
+			return nil, module.ParamErrorf("frequency", "invalid frequency '%s': must be hourly, daily, weekly, monthly, or yearly", frequency)
+		}
+	}
+	for _, key := range []string{"rotate", "maxage"} {
+		if _, ok := params[key]; ok {
+			directives = append(directives, fmt.Sprintf("%s %d", key, param.Int(params, key, 0)))
+		}
+	}
+	for _, key := range []string{"size", "maxsize", "create", "su"} {
+		if v := param.String(params, key, ""); v != "" {
+			directives = append(directives, key+" "+v)
+		}
+	}
+	for _, f := range flags {
+		if v, ok := param.LookupBool(params, f.name); ok {
+			if v {
+				directives = append(directives, f.name)
+			} else {
+				directives = append(directives, f.negation)
+			}
+		}
+	}
+	directives = append(directives, param.StringList(params, "options")...)
+
+	var b strings.Builder
+	for _, p := range paths {
+		if strings.ContainsAny(p, " \t") {
+			p = `"` + p + `"`
+		}
+		fmt.Fprintf(&b, "%s ", p)
+	}
+	b.WriteString("{\n")
+	for _, d := range directives {
+		fmt.Fprintf(&b, "    %s\n", d)
+	}
+	for _, script := range []string{"prerotate", "postrotate"} {
+		body := strings.TrimRight(param.String(params, script, ""), " \t\n")
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "    %s\n", script)
+		for _, line := range strings.Split(body, "\n") {
+			fmt.Fprintf(&b, "        %s\n", strings.TrimRight(line, " \t"))
+		}
+		b.WriteString("    endscript\n")
+	}
+	b.WriteString("}\n")
+	return []byte(b.String()), nil
+}
+
+// SupportsCheckMode reports that logrotate can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
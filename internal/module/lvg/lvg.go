@@ -0,0 +1,215 @@
+// Package lvg provides a module for managing LVM volume groups.
+package lvg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a volume group.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the volume group exists with the given physical volumes
+	StateAbsent  State = "absent"  // Ensure the volume group does not exist
+)
+
+// Module manages LVM volume groups on the target system.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "lvg"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "vg", Type: "string", Required: true, Description: "Volume group name"},
+		{Name: "pvs", Type: "string/list", Description: "Physical volumes; PVs not listed are removed from the group"},
+		{Name: "pesize", Type: "string", Description: "Physical extent size for new groups (e.g., `4M`)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "force", Type: "bool", Default: false, Description: "Remove the group even if it contains logical volumes"},
+	}
+}
+
+// Run executes the lvg module.
+//
+// Parameters:
+//   - vg (string, required): Volume group name
+//   - pvs (string|[]string): Physical volumes (required to create the group); extra PVs are removed from an existing group
+//   - pesize (string): Physical extent size for new groups (e.g., "4M")
+//   - state (string): Desired state - present, absent (default: present)
+//   - force (bool): Allow removing a volume group that still contains logical volumes (default: false)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	vg, err := param.Required(params, "vg")
+	if err != nil {
+		return nil, err
+	}
+
+	pvs := getPVs(params)
+	pesize := param.String(params, "pesize", "")
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	force := param.Bool(params, "force", false)
+
+	switch state {
+	case StatePresent, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	if err := checkLVM(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	exists, currentPVs, lvCount, err := getVolumeGroup(ctx, conn, vg)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == StateAbsent {
+		if !exists {
+			return module.Unchanged(fmt.Sprintf("volume group %s already absent", vg)), nil
+		}
+		if lvCount > 0 && !force {
+			return nil, fmt.Errorf("volume group %s contains %d logical volume(s); set force=true to remove it", vg, lvCount)
+		}
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("vgremove -f %s", shellutil.Quote(vg))); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed volume group %s", vg)), nil
+	}
+
+	if !exists {
+		if len(pvs) == 0 {
+			return nil, module.ParamErrorf("pvs", "'pvs' parameter is required to create volume group %s", vg)
+		}
+		cmd := "vgcreate -y"
+		if pesize != "" {
+			cmd += " -s " + shellutil.Quote(pesize)
+		}
+		cmd += " " + shellutil.Quote(vg) + " " + shellutil.Join(pvs...)
+		if err := module.RunCommand(ctx, conn, cmd); err != nil {
+			return nil, err
+		}
+		return module.ChangedWithData(fmt.Sprintf("created volume group %s", vg), map[string]any{
+			"vg":  vg,
+			"pvs": pvs,
+		}), nil
+	}
+
+	if len(pvs) == 0 {
+		return module.Unchanged(fmt.Sprintf("volume group %s exists", vg)), nil
+	}
+
+	toAdd := difference(pvs, currentPVs)
+	toRemove := difference(currentPVs, pvs)
+
+	var messages []string
+	if len(toAdd) > 0 {
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("vgextend -y %s %s", shellutil.Quote(vg), shellutil.Join(toAdd...))); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("added: %s", strings.Join(toAdd, ", ")))
+	}
+	if len(toRemove) > 0 {
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("vgreduce %s %s", shellutil.Quote(vg), shellutil.Join(toRemove...))); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("removed: %s", strings.Join(toRemove, ", ")))
+	}
+
+	if len(messages) == 0 {
+		return module.Unchanged(fmt.Sprintf("volume group %s already in desired state", vg)), nil
+	}
+
+	return module.ChangedWithData(strings.Join(messages, "; "), map[string]any{
+		"vg":  vg,
+		"pvs": pvs,
+	}), nil
+}
+
+// checkLVM verifies that the LVM tools are available.
+func checkLVM(ctx context.Context, conn connector.Connector) error {
+	result, err := conn.Execute(ctx, "command -v vgs")
+	if err != nil {
+		return fmt.Errorf("failed to check for lvm: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("lvm2 tools are not installed")
+	}
+	return nil
+}
+
+// getVolumeGroup returns whether vg exists, its physical volumes, and its logical volume count.
+func getVolumeGroup(ctx context.Context, conn connector.Connector, vg string) (bool, []string, int, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("vgs --noheadings -o lv_count %s 2>/dev/null", shellutil.Quote(vg)))
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("failed to query volume group: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, nil, 0, nil
+	}
+
+	var lvCount int
+	_, _ = fmt.Sscanf(strings.TrimSpace(result.Stdout), "%d", &lvCount)
+
+	result, err = conn.Execute(ctx, "pvs --noheadings --separator '|' -o pv_name,vg_name")
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("failed to query physical volumes: %w", err)
+	}
+
+	var pvs []string
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "|", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[1]) == vg {
+			pvs = append(pvs, strings.TrimSpace(parts[0]))
+		}
+	}
+
+	return true, pvs, lvCount, nil
+}
+
+// difference returns the items in a that are not in b.
+func difference(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, item := range b {
+		seen[item] = true
+	}
+	var diff []string
+	for _, item := range a {
+		if !seen[item] {
+			diff = append(diff, item)
+		}
+	}
+	return diff
+}
+
+// getPVs returns the pvs parameter, which may also be given as a
+// comma-separated string.
+func getPVs(params map[string]any) []string {
+	var pvs []string
+	for _, item := range param.StringList(params, "pvs") {
+		for _, pv := range strings.Split(item, ",") {
+			if pv = strings.TrimSpace(pv); pv != "" {
+				pvs = append(pvs, pv)
+			}
+		}
+	}
+	return pvs
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
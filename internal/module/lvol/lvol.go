@@ -0,0 +1,254 @@
+// Package lvol provides a module for managing LVM logical volumes.
+package lvol
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a logical volume.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the logical volume exists with the given size
+	StateAbsent  State = "absent"  // Ensure the logical volume does not exist
+)
+
+// Module manages LVM logical volumes on the target system.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "lvol"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "vg", Type: "string", Required: true, Description: "Volume group name"},
+		{Name: "lv", Type: "string", Required: true, Description: "Logical volume name"},
+		{Name: "size", Type: "string", Description: "Size with unit (`512M`, `10G`) or extents (`100%FREE`)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "resizefs", Type: "bool", Default: false, Description: "Resize the filesystem together with the volume"},
+		{Name: "force", Type: "bool", Default: false, Description: "Allow shrinking or removing the volume"},
+	}
+}
+
+// Run executes the lvol module.
+//
+// Parameters:
+//   - vg (string, required): Volume group name
+//   - lv (string, required): Logical volume name
+//   - size (string): Size with unit (e.g., "512M", "10G") or extents percentage (e.g., "100%FREE"); required to create
+//   - state (string): Desired state - present, absent (default: present)
+//   - resizefs (bool): Resize the filesystem together with the volume (default: false)
+//   - force (bool): Allow shrinking or removing the volume (default: false)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	vg, err := param.Required(params, "vg")
+	if err != nil {
+		return nil, err
+	}
+	lv, err := param.Required(params, "lv")
+	if err != nil {
+		return nil, err
+	}
+
+	size := param.String(params, "size", "")
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	resizefs := param.Bool(params, "resizefs", false)
+	force := param.Bool(params, "force", false)
+
+	switch state {
+	case StatePresent, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	if err := checkLVM(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	path := vg + "/" + lv
+	exists, currentSize, err := getVolumeSize(ctx, conn, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == StateAbsent {
+		if !exists {
+			return module.Unchanged(fmt.Sprintf("logical volume %s already absent", path)), nil
+		}
+		if !force {
+			return nil, fmt.Errorf("removing logical volume %s requires force=true", path)
+		}
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("lvremove -f %s", shellutil.Quote(path))); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed logical volume %s", path)), nil
+	}
+
+	isPercent := strings.Contains(size, "%")
+
+	if !exists {
+		if size == "" {
+			return nil, module.ParamErrorf("size", "'size' parameter is required to create logical volume %s", path)
+		}
+		sizeFlag := "-L"
+		if isPercent {
+			sizeFlag = "-l"
+		}
+		cmd := fmt.Sprintf("lvcreate -y -n %s %s %s %s", shellutil.Quote(lv), sizeFlag, shellutil.Quote(size), shellutil.Quote(vg))
+		if err := module.RunCommand(ctx, conn, cmd); err != nil {
+			return nil, err
+		}
+		return module.ChangedWithData(fmt.Sprintf("created logical volume %s", path), map[string]any{
+			"vg":   vg,
+			"lv":   lv,
+			"size": size,
+		}), nil
+	}
+
+	// Percentage sizes are only applied at creation time
+	if size == "" || isPercent {
+		return module.Unchanged(fmt.Sprintf("logical volume %s exists", path)), nil
+	}
+
+	desired, err := parseSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	extent, err := getExtentSize(ctx, conn, vg)
+	if err != nil {
+		return nil, err
+	}
+
+	// LVM rounds sizes up to whole extents
+	if extent > 0 && desired%extent != 0 {
+		desired += extent - desired%extent
+	}
+
+	if desired == currentSize {
+		return module.Unchanged(fmt.Sprintf("logical volume %s already has size %s", path, size)), nil
+	}
+
+	resizeFlag := ""
+	if resizefs {
+		resizeFlag = " -r"
+	}
+
+	if desired < currentSize {
+		if !force {
+			return nil, fmt.Errorf("shrinking logical volume %s requires force=true", path)
+		}
+		cmd := fmt.Sprintf("lvreduce -f%s -L %db %s", resizeFlag, desired, shellutil.Quote(path))
+		if err := module.RunCommand(ctx, conn, cmd); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("reduced logical volume %s to %s", path, size)), nil
+	}
+
+	cmd := fmt.Sprintf("lvextend%s -L %db %s", resizeFlag, desired, shellutil.Quote(path))
+	if err := module.RunCommand(ctx, conn, cmd); err != nil {
+		return nil, err
+	}
+	return module.Changed(fmt.Sprintf("extended logical volume %s to %s", path, size)), nil
+}
+
+// checkLVM verifies that the LVM tools are available.
+func checkLVM(ctx context.Context, conn connector.Connector) error {
+	result, err := conn.Execute(ctx, "command -v lvs")
+	if err != nil {
+		return fmt.Errorf("failed to check for lvm: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("lvm2 tools are not installed")
+	}
+	return nil
+}
+
+// getVolumeSize returns whether the volume exists and its size in bytes.
+func getVolumeSize(ctx context.Context, conn connector.Connector, path string) (bool, int64, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("lvs --noheadings --units b --nosuffix -o lv_size %s 2>/dev/null", shellutil.Quote(path)))
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to query logical volume: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, 0, nil
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(result.Stdout), 10, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to parse logical volume size %q: %w", result.Stdout, err)
+	}
+	return true, size, nil
+}
+
+// getExtentSize returns the physical extent size of the volume group in bytes.
+func getExtentSize(ctx context.Context, conn connector.Connector, vg string) (int64, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("vgs --noheadings --units b --nosuffix -o vg_extent_size %s", shellutil.Quote(vg)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query volume group: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return 0, module.CommandFailedf(result, "volume group %s not found", vg)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(result.Stdout), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse extent size %q: %w", result.Stdout, err)
+	}
+	return size, nil
+}
+
+// parseSize converts an LVM size string (e.g., "512M", "1.5g") to bytes.
+// Units are powers of 1024; a bare number means megabytes, as in lvcreate.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := int64(1 << 20)
+	unit := strings.ToLower(s[len(s)-1:])
+	number := s
+	switch unit {
+	case "b":
+		multiplier = 1
+	case "k":
+		multiplier = 1 << 10
+	case "m":
+		multiplier = 1 << 20
+	case "g":
+		multiplier = 1 << 30
+	case "t":
+		multiplier = 1 << 40
+	case "p":
+		multiplier = 1 << 50
+	default:
+		unit = ""
+	}
+	if unit != "" {
+		number = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -0,0 +1,236 @@
+// Package macosfirewall provides a module for managing the macOS application firewall.
+package macosfirewall
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// socketfilterfw is the application firewall CLI shipped with macOS.
+const socketfilterfw = "/usr/libexec/ApplicationFirewall/socketfilterfw"
+
+// setting describes a global firewall toggle.
+type setting struct {
+	param string // module parameter name
+	get   string // socketfilterfw flag that reports the current value
+	set   string // socketfilterfw flag that changes the value
+}
+
+// settings lists the toggles in the order they are applied.
+// The firewall must be enabled before the other settings take effect.
+var settings = []setting{
+	{param: "enabled", get: "--getglobalstate", set: "--setglobalstate"},
+	{param: "block_all", get: "--getblockall", set: "--setblockall"},
+	{param: "stealth", get: "--getstealthmode", set: "--setstealthmode"},
+	{param: "allow_signed", get: "--getallowsigned", set: "--setallowsigned"},
+	{param: "logging", get: "--getloggingmode", set: "--setloggingmode"},
+}
+
+// Module manages the macOS application firewall (socketfilterfw).
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "macos_firewall"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "enabled", Type: "bool", Description: "Turn the firewall on or off"},
+		{Name: "block_all", Type: "bool", Description: "Block all incoming connections"},
+		{Name: "stealth", Type: "bool", Description: "Enable stealth mode"},
+		{Name: "allow_signed", Type: "bool", Description: "Automatically allow signed software"},
+		{Name: "logging", Type: "bool", Description: "Enable firewall logging"},
+		{Name: "apps", Type: "list", Description: "Application rules with `path` and `blocked` (default `false`)"},
+	}
+}
+
+// Run executes the macos_firewall module. Only parameters that are set are managed.
+//
+// Parameters:
+//   - enabled (bool): Turn the firewall on or off
+//   - block_all (bool): Block all incoming connections
+//   - stealth (bool): Do not respond to ICMP or connection attempts from closed ports
+//   - allow_signed (bool): Automatically allow signed software to receive connections
+//   - logging (bool): Enable firewall logging
+//   - apps ([]map): Applications with path (required) and blocked (bool, default: false)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	if err := checkFirewall(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	var messages []string
+
+	for _, s := range settings {
+		want, ok := param.LookupBool(params, s.param)
+		if !ok {
+			continue
+		}
+
+		out, err := firewall(ctx, conn, s.get)
+		if err != nil {
+			return nil, err
+		}
+		if parseToggle(out) == want {
+			continue
+		}
+
+		if _, err := firewall(ctx, conn, s.set, onOff(want)); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("%s=%s", s.param, onOff(want)))
+	}
+
+	apps, err := getApps(params)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, app := range apps {
+		changed, err := ensureApp(ctx, conn, app)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			state := "allowed"
+			if app.blocked {
+				state = "blocked"
+			}
+			messages = append(messages, fmt.Sprintf("%s %s", app.path, state))
+		}
+	}
+
+	if len(messages) == 0 {
+		return module.Unchanged("firewall already in desired state"), nil
+	}
+
+	return module.Changed("firewall updated: " + strings.Join(messages, ", ")), nil
+}
+
+// app is an application rule from the apps parameter.
+type app struct {
+	path    string
+	blocked bool
+}
+
+// getApps parses the apps parameter.
+func getApps(params map[string]any) ([]app, error) {
+	v, ok := params["apps"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, module.ParamErrorf("apps", "parameter 'apps' must be a list")
+	}
+
+	apps := make([]app, 0, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("app %d must be a map", i+1)
+		}
+		path, err := param.Required(m, "path")
+		if err != nil {
+			return nil, fmt.Errorf("app %d: %w", i+1, err)
+		}
+		blocked, _ := param.LookupBool(m, "blocked")
+		apps = append(apps, app{path: path, blocked: blocked})
+	}
+	return apps, nil
+}
+
+// ensureApp registers an application with the firewall and sets whether it is blocked.
+func ensureApp(ctx context.Context, conn connector.Connector, a app) (bool, error) {
+	out, err := firewall(ctx, conn, "--getappblocked", a.path)
+	if err != nil {
+		return false, err
+	}
+
+	lower := strings.ToLower(out)
+	registered := !strings.Contains(lower, "not part of the firewall")
+	blocked := strings.Contains(lower, "is blocked")
+
+	if registered && blocked == a.blocked {
+		return false, nil
+	}
+
+	if !registered {
+		if _, err := firewall(ctx, conn, "--add", a.path); err != nil {
+			return false, err
+		}
+	}
+
+	flag := "--unblockapp"
+	if a.blocked {
+		flag = "--blockapp"
+	}
+	if _, err := firewall(ctx, conn, flag, a.path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parseToggle interprets socketfilterfw output for on/off settings. The wording
+// differs between macOS releases ("enabled", "is on", "State = 1", ...).
+func parseToggle(out string) bool {
+	lower := strings.ToLower(out)
+	for _, off := range []string{"disabled", "is off", "state = 0", " off"} {
+		if strings.Contains(lower, off) {
+			return false
+		}
+	}
+	for _, on := range []string{"enabled", "is on", "state = 1", "state = 2", " on", "set to block all"} {
+		if strings.Contains(lower, on) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFirewall verifies that socketfilterfw exists, i.e. the target runs macOS.
+func checkFirewall(ctx context.Context, conn connector.Connector) error {
+	result, err := conn.Execute(ctx, "test -x "+socketfilterfw)
+	if err != nil {
+		return fmt.Errorf("failed to check for socketfilterfw: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("socketfilterfw not found: macos_firewall requires macOS")
+	}
+	return nil
+}
+
+// firewall runs socketfilterfw with the given arguments and returns its output.
+func firewall(ctx context.Context, conn connector.Connector, args ...string) (string, error) {
+	cmd := socketfilterfw + " " + shellutil.Join(args...)
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to run socketfilterfw: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", module.CommandFailedf(result, "socketfilterfw %s failed", args[0])
+	}
+	return result.Stdout, nil
+}
+
+// onOff converts a bool to the socketfilterfw argument.
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
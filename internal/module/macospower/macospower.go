@@ -0,0 +1,229 @@
+// Package macospower provides a module for managing macOS power settings with pmset.
+package macospower
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Source selects which power source the settings apply to.
+type Source string
+
+const (
+	SourceAll     Source = "all"     // Apply to every power source
+	SourceAC      Source = "ac"      // Apply when on charger
+	SourceBattery Source = "battery" // Apply when on battery
+)
+
+// sourceFlags maps a source to its pmset flag.
+var sourceFlags = map[Source]string{
+	SourceAll:     "-a",
+	SourceAC:      "-c",
+	SourceBattery: "-b",
+}
+
+// sectionSources maps `pmset -g custom` section headers to sources.
+var sectionSources = map[string]Source{
+	"AC Power:":      SourceAC,
+	"Battery Power:": SourceBattery,
+}
+
+// namedSettings are the pmset settings exposed as first-class parameters.
+var namedSettings = []string{"sleep", "displaysleep", "disksleep", "womp", "powernap"}
+
+// Module manages macOS power management settings.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "macos_power"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "source", Type: "string", Default: "all", Choices: []string{"all", "ac", "battery"}, Description: "Power source the settings apply to"},
+		{Name: "sleep", Type: "int", Description: "Minutes until system sleep (`0` = never)"},
+		{Name: "displaysleep", Type: "int", Description: "Minutes until display sleep (`0` = never)"},
+		{Name: "disksleep", Type: "int", Description: "Minutes until disk sleep (`0` = never)"},
+		{Name: "womp", Type: "bool", Description: "Wake on network access"},
+		{Name: "powernap", Type: "bool", Description: "Enable Power Nap"},
+		{Name: "settings", Type: "map", Description: "Other `pmset` settings (e.g., `lidwake: 1`)"},
+	}
+}
+
+// Run executes the macos_power module. Only settings that are given are managed.
+//
+// Parameters:
+//   - source (string): Power source - all, ac, battery (default: all)
+//   - sleep (int): Minutes until system sleep (0 = never)
+//   - displaysleep (int): Minutes until display sleep (0 = never)
+//   - disksleep (int): Minutes until disk sleep (0 = never)
+//   - womp (bool): Wake on network access
+//   - powernap (bool): Enable Power Nap
+//   - settings (map): Any other pmset setting (e.g., {"lidwake": 1})
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	sourceStr := param.String(params, "source", "all")
+	source := Source(sourceStr)
+
+	flag, ok := sourceFlags[source]
+	if !ok {
+		return nil, module.ParamErrorf("source", "invalid source '%s': must be all, ac, or battery", source)
+	}
+
+	desired, err := desiredSettings(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(desired) == 0 {
+		return nil, fmt.Errorf("at least one power setting must be specified")
+	}
+
+	result, err := conn.Execute(ctx, "pmset -g custom")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read power settings: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("pmset not available: macos_power requires macOS")
+	}
+	current := parseCustom(result.Stdout)
+	if len(current) == 0 {
+		return nil, fmt.Errorf("failed to parse pmset output")
+	}
+	if _, ok := current[source]; !ok && source != SourceAll {
+		return nil, fmt.Errorf("this system has no %s power source", source)
+	}
+
+	// Collect settings that differ on any of the targeted sources
+	var keys []string
+	for key, want := range desired {
+		for src, values := range current {
+			if source != SourceAll && src != source {
+				continue
+			}
+			if values[key] != want {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return module.Unchanged("power settings already in desired state"), nil
+	}
+	sort.Strings(keys)
+
+	args := []string{"pmset", flag}
+	var changes []string
+	for _, key := range keys {
+		args = append(args, key, desired[key])
+		changes = append(changes, fmt.Sprintf("%s=%s", key, desired[key]))
+	}
+
+	result, err = connector.ExecuteArgv(ctx, conn, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run pmset: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "pmset failed")
+	}
+
+	return module.ChangedWithData(fmt.Sprintf("updated power settings (%s): %s", source, strings.Join(changes, ", ")), map[string]any{
+		"source":  string(source),
+		"changed": keys,
+	}), nil
+}
+
+// desiredSettings collects the requested settings as pmset string values.
+func desiredSettings(params map[string]any) (map[string]string, error) {
+	desired := make(map[string]string)
+
+	if extra, ok := params["settings"]; ok {
+		m, ok := extra.(map[string]any)
+		if !ok {
+			return nil, module.ParamErrorf("settings", "parameter 'settings' must be a map")
+		}
+		for key, v := range m {
+			value, err := formatValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("setting '%s': %w", key, err)
+			}
+			desired[key] = value
+		}
+	}
+
+	for _, key := range namedSettings {
+		v, ok := params[key]
+		if !ok {
+			continue
+		}
+		value, err := formatValue(v)
+		if err != nil {
+			return nil, module.ParamErrorf(key, "parameter '%s': %w", key, err)
+		}
+		desired[key] = value
+	}
+
+	return desired, nil
+}
+
+// formatValue converts a parameter value to pmset's numeric form.
+func formatValue(v any) (string, error) {
+	switch val := v.(type) {
+	case bool:
+		if val {
+			return "1", nil
+		}
+		return "0", nil
+	case int:
+		return strconv.Itoa(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.Itoa(int(val)), nil
+	case string:
+		if _, err := strconv.Atoi(val); err != nil {
+			return "", fmt.Errorf("value '%s' must be a number", val)
+		}
+		return val, nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+// parseCustom parses `pmset -g custom` output into settings per power source.
+func parseCustom(out string) map[Source]map[string]string {
+	settings := make(map[Source]map[string]string)
+	var current map[string]string
+
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if src, ok := sectionSources[trimmed]; ok {
+			current = make(map[string]string)
+			settings[src] = current
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		if len(fields) >= 2 {
+			current[fields[0]] = fields[1]
+		}
+	}
+
+	return settings
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -0,0 +1,36 @@
+package module
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ParseMirror validates the base URL of a package mirror and returns it
+// without a trailing slash.
+func ParseMirror(mirror string) (string, error) {
+	u, err := url.Parse(mirror)
+	if err != nil || u.Scheme == "" || (u.Host == "" && u.Scheme != "file") {
+		return "", fmt.Errorf("invalid mirror '%s': must be a URL such as http://mirror.example.com", mirror)
+	}
+	return strings.TrimSuffix(mirror, "/"), nil
+}
+
+// MirrorURL returns uri on mirror and whether that differs from uri, if
+// the host of uri matches one of hosts (path.Match patterns): the scheme
+// and host are replaced by mirror and the path is kept, so
+// http://deb.debian.org/debian becomes http://mirror.example.com/debian.
+func MirrorURL(uri, mirror string, hosts []string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	for _, pattern := range hosts {
+		if ok, _ := path.Match(pattern, u.Hostname()); ok {
+			mirrored := mirror + "/" + strings.TrimPrefix(u.Path, "/")
+			return mirrored, mirrored != uri
+		}
+	}
+	return "", false
+}
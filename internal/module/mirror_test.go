@@ -0,0 +1,50 @@
+package module
+
+import "testing"
+
+func TestParseMirror(t *testing.T) {
+	tests := []struct {
+		mirror  string
+		want    string
+		wantErr bool
+	}{
+		{"http://mirror.example.com", "http://mirror.example.com", false},
+		{"https://mirror.example.com/apt/", "https://mirror.example.com/apt", false},
+		{"file:///srv/mirror", "file:///srv/mirror", false},
+		{"mirror.example.com", "", true},
+		{"http://", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMirror(tt.mirror)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMirror(%q) error = %v, wantErr %v", tt.mirror, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMirror(%q) = %q, want %q", tt.mirror, got, tt.want)
+		}
+	}
+}
+
+func TestMirrorURL(t *testing.T) {
+	hosts := []string{"*.debian.org", "dl.rockylinux.org"}
+	tests := []struct {
+		uri     string
+		want    string
+		changed bool
+	}{
+		{"http://deb.debian.org/debian", "http://mirror.corp/pkg/debian", true},
+		{"https://dl.rockylinux.org/$contentdir/$releasever/BaseOS/", "http://mirror.corp/pkg/$contentdir/$releasever/BaseOS/", true},
+		{"http://mirror.corp/pkg/debian", "", false},
+		{"http://download.docker.com/linux/debian", "", false},
+		{"cdrom:[Debian]/", "", false},
+	}
+
+	for _, tt := range tests {
+		got, changed := MirrorURL(tt.uri, "http://mirror.corp/pkg", hosts)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("MirrorURL(%q) = %q, %v, want %q, %v", tt.uri, got, changed, tt.want, tt.changed)
+		}
+	}
+}
@@ -19,6 +19,36 @@ type Result struct {
 
 	// Data holds any additional output data from the module.
 	Data map[string]any
+
+	// Before and After describe what the module changed, as it was and as
+	// it is now (in check mode: as it would be). Either is a string, such
+	// as the content of a file, or a map[string]any of attributes, such as
+	// {"mode": "0644", "owner": "root"}, with the attributes that changed.
+	// A nil Before means the resource did not exist, a nil After that it
+	// was removed. Both are nil if the module does not report them.
+	Before any
+	After  any
+}
+
+// FactsKey is the key of Result.Data under which a module returns facts: a
+// map[string]any that the executor merges into the host's facts, so later
+// tasks and plays can use them (as facts.<name>) without register.
+const FactsKey = "facts"
+
+// WithFacts adds facts to the result under FactsKey and returns it.
+func (r *Result) WithFacts(facts map[string]any) *Result {
+	if r.Data == nil {
+		r.Data = make(map[string]any)
+	}
+	r.Data[FactsKey] = facts
+	return r
+}
+
+// WithDiff sets the Before and After of the result and returns it.
+func (r *Result) WithDiff(before, after any) *Result {
+	r.Before = before
+	r.After = after
+	return r
 }
 
 // Module is the interface that all modules must implement.
@@ -32,6 +62,55 @@ type Module interface {
 	Run(ctx context.Context, conn connector.Connector, params map[string]any) (*Result, error)
 }
 
+// CheckModeParam is set to true in the parameters when a task runs in check
+// mode (bolt run --dry-run).
+const CheckModeParam = "_check_mode"
+
+// CheckModer is implemented by modules that support check mode. In check
+// mode such a module must not change the target; it inspects the current
+// state and reports whether Run would have made changes.
+type CheckModer interface {
+	SupportsCheckMode() bool
+}
+
+// SupportsCheckMode reports whether m can run in check mode.
+func SupportsCheckMode(m Module) bool {
+	c, ok := m.(CheckModer)
+	return ok && c.SupportsCheckMode()
+}
+
+// IsCheckMode reports whether params request check mode.
+func IsCheckMode(params map[string]any) bool {
+	check, _ := params[CheckModeParam].(bool)
+	return check
+}
+
+// OfflineParam is set to true in the parameters when a task runs in offline
+// mode (bolt run --offline). In offline mode a module must not reach the
+// internet: it skips optional network operations, such as refreshing a
+// package index, and fails with an OfflineError when the task cannot be
+// done without the network.
+const OfflineParam = "_offline"
+
+// IsOffline reports whether params request offline mode.
+func IsOffline(params map[string]any) bool {
+	offline, _ := params[OfflineParam].(bool)
+	return offline
+}
+
+// DefaultOwnerParam is set in the parameters of copy and template to the
+// user bolt connects as, when the play has become and
+// become_keep_ownership. Files the module creates without an owner
+// parameter get that owner instead of the become user.
+const DefaultOwnerParam = "_default_owner"
+
+// DefaultOwner returns the owner of files created without an owner
+// parameter, or "" to leave them to the user the module runs as.
+func DefaultOwner(params map[string]any) string {
+	owner, _ := params[DefaultOwnerParam].(string)
+	return owner
+}
+
 // registry holds all registered modules.
 var (
 	registry   = make(map[string]Module)
@@ -39,12 +118,16 @@ var (
 )
 
 // Register adds a module to the registry.
-// It panics if a module with the same name is already registered.
+// It panics if a module with the same name is already registered, or if
+// the name is not valid (see CoreCollection).
 func Register(m Module) {
 	registryMu.Lock()
 	defer registryMu.Unlock()
 
 	name := m.Name()
+	if err := checkName(name); err != nil {
+		panic(err.Error())
+	}
 	if _, exists := registry[name]; exists {
 		panic(fmt.Sprintf("module %q is already registered", name))
 	}
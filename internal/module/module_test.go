@@ -2,6 +2,8 @@ package module
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/eugenetaranov/bolt/internal/connector"
@@ -96,3 +98,42 @@ func TestResultHelpers(t *testing.T) {
 		}
 	})
 }
+
+func TestCommandFailedError(t *testing.T) {
+	tests := []struct {
+		name   string
+		result connector.Result
+		want   string
+	}{
+		{"stderr", connector.Result{ExitCode: 1, Stdout: "out", Stderr: "lock held\n"}, "apt-get install failed: lock held"},
+		{"stdout fallback", connector.Result{ExitCode: 1, Stdout: " bad superblock \n"}, "apt-get install failed: bad superblock"},
+		{"no output", connector.Result{ExitCode: 2}, "apt-get install failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CommandFailedf(&tt.result, "apt-get %s failed", "install")
+			if err.Error() != tt.want {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.want)
+			}
+			if err.RC != tt.result.ExitCode {
+				t.Errorf("RC = %d, want %d", err.RC, tt.result.ExitCode)
+			}
+		})
+	}
+}
+
+func TestParamError(t *testing.T) {
+	err := fmt.Errorf("task failed: %w", ParamErrorf("state", "invalid state '%s'", "gone"))
+
+	var paramErr *ParamError
+	if !errors.As(err, &paramErr) {
+		t.Fatal("expected errors.As to find the ParamError")
+	}
+	if paramErr.Param != "state" {
+		t.Errorf("Param = %q, want state", paramErr.Param)
+	}
+	if err.Error() != "task failed: invalid state 'gone'" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
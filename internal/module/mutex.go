@@ -0,0 +1,118 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// mutexRetryInterval is how long Mutex.Execute waits before running a
+// command again that failed because another program held the lock.
+var mutexRetryInterval = 5 * time.Second
+
+// mutexTimeoutExit is the exit code of the lock wrapper when the lock was
+// not acquired in time (EX_TEMPFAIL, as used by lockf).
+const mutexTimeoutExit = 75
+
+// Mutex serializes commands of a kind on the target, such as runs of a
+// package manager, so tasks of parallel plays or loops do not fail on
+// each other's locks. Commands holding the mutex run under flock (Linux)
+// or lockf (BSD) on a lock file in /tmp; targets with neither run them
+// unlocked.
+//
+// Programs outside bolt (e.g., unattended-upgrades) do not take the
+// mutex, so a command whose stderr shows that the tool's own lock is held
+// is run again until Wait has passed.
+type Mutex struct {
+	// Name identifies the mutex in the lock file name, e.g. "dpkg".
+	Name string
+
+	// Wait is how long a command waits for the mutex and for the tool's
+	// own lock.
+	Wait time.Duration
+
+	// Busy lists stderr messages of the tool when another program holds
+	// its lock.
+	Busy []string
+}
+
+// Package manager mutexes used by the package modules.
+var (
+	DpkgMutex = Mutex{
+		Name: "dpkg",
+		Wait: 10 * time.Minute,
+		Busy: []string{"Could not get lock", "Unable to acquire the dpkg frontend lock", "Unable to lock directory", "locked by another process"},
+	}
+	BrewMutex = Mutex{
+		Name: "brew",
+		Wait: 10 * time.Minute,
+		Busy: []string{"Another active Homebrew", "has already locked"},
+	}
+	PkgMutex = Mutex{
+		Name: "pkg",
+		Wait: 10 * time.Minute,
+		Busy: []string{"Cannot get an exclusive lock", "database is locked"},
+	}
+	RpmMutex = Mutex{
+		Name: "rpm",
+		Wait: 10 * time.Minute,
+		Busy: []string{"Waiting for process with pid", "Existing lock", "another copy is running", "Failed to obtain the transaction lock"},
+	}
+)
+
+// Path returns the lock file of the mutex on the target.
+func (m Mutex) Path() string {
+	return fmt.Sprintf("/tmp/bolt-%s.lock", m.Name)
+}
+
+// Execute runs cmd on conn holding the mutex. A command that fails because
+// another program holds the tool's lock is retried until Wait has passed;
+// its last result is returned.
+func (m Mutex) Execute(ctx context.Context, conn connector.Connector, cmd string) (*connector.Result, error) {
+	deadline := time.Now().Add(m.Wait)
+	for {
+		wait := max(time.Until(deadline), time.Second)
+		result, err := conn.Execute(ctx, m.command(cmd, wait))
+		if err != nil {
+			return nil, err
+		}
+		if result.ExitCode == mutexTimeoutExit && strings.TrimSpace(result.Stderr) == "" {
+			result.Stderr = fmt.Sprintf("timed out after %s waiting for %s", m.Wait, m.Path())
+			return result, nil
+		}
+		if result.ExitCode == 0 || !m.busy(result.Stderr) || time.Now().Add(mutexRetryInterval).After(deadline) {
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(mutexRetryInterval):
+		}
+	}
+}
+
+// command wraps cmd to run under the lock, waiting at most wait for it.
+func (m Mutex) command(cmd string, wait time.Duration) string {
+	secs := int(wait.Round(time.Second).Seconds())
+	return fmt.Sprintf(`if command -v flock >/dev/null 2>&1; then
+	exec flock -w %[1]d -E %[2]d %[3]s /bin/sh -c %[4]s
+elif command -v lockf >/dev/null 2>&1; then
+	exec lockf -t %[1]d %[3]s /bin/sh -c %[4]s
+fi
+exec /bin/sh -c %[4]s`, secs, mutexTimeoutExit, shellutil.Quote(m.Path()), shellutil.Quote(cmd))
+}
+
+// busy reports whether stderr shows that another program holds the lock.
+func (m Mutex) busy(stderr string) bool {
+	for _, msg := range m.Busy {
+		if strings.Contains(stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestMutexExecute(t *testing.T) {
+	orig := mutexRetryInterval
+	mutexRetryInterval = time.Millisecond
+	t.Cleanup(func() { mutexRetryInterval = orig })
+
+	busy := connector.Result{ExitCode: 100, Stderr: "E: Could not get lock /var/lib/dpkg/lock-frontend. It is held by process 812 (unattended-upgr)"}
+
+	tests := []struct {
+		name      string
+		setup     func(c *connectortest.Connector)
+		wantExit  int
+		wantErr   string
+		wantCalls int
+	}{
+		{
+			name: "busy then success",
+			setup: func(c *connectortest.Connector) {
+				c.OnPrefix("if command -v flock").ReturnResult(busy).Times(2)
+				c.OnPrefix("if command -v flock").Return("")
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "other failure",
+			setup: func(c *connectortest.Connector) {
+				c.OnPrefix("if command -v flock").Fail(100, "E: Unable to locate package nginxx")
+			},
+			wantExit:  100,
+			wantErr:   "Unable to locate package",
+			wantCalls: 1,
+		},
+		{
+			name: "lock timeout",
+			setup: func(c *connectortest.Connector) {
+				c.OnPrefix("if command -v flock").Fail(mutexTimeoutExit, "")
+			},
+			wantExit:  mutexTimeoutExit,
+			wantErr:   "waiting for /tmp/bolt-dpkg.lock",
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			tt.setup(conn)
+
+			m := DpkgMutex
+			m.Wait = time.Minute
+			result, err := m.Execute(context.Background(), conn, "apt-get install -y nginx")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.ExitCode != tt.wantExit || !strings.Contains(result.Stderr, tt.wantErr) {
+				t.Errorf("Execute() = %+v", result)
+			}
+			if got := len(conn.Commands()); got != tt.wantCalls {
+				t.Errorf("ran %d commands, want %d", got, tt.wantCalls)
+			}
+			if cmd := conn.Commands()[0]; !strings.Contains(cmd, "flock -w 60 -E 75 '/tmp/bolt-dpkg.lock' /bin/sh -c 'apt-get install -y nginx'") {
+				t.Errorf("command = %s", cmd)
+			}
+		})
+	}
+
+	// Busy commands are not retried past Wait
+	conn := connectortest.New()
+	conn.OnPrefix("if command -v flock").ReturnResult(busy)
+	m := DpkgMutex
+	m.Wait = 0
+	if result, err := m.Execute(context.Background(), conn, "apt-get update"); err != nil || result.ExitCode != 100 || len(conn.Commands()) != 1 {
+		t.Errorf("Execute() = %+v, %v after %d commands", result, err, len(conn.Commands()))
+	}
+}
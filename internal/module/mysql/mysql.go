@@ -0,0 +1,125 @@
+// Package mysql provides a MySQL/MariaDB client shared by the mysql_db and
+// mysql_user modules. Statements are run through the mysql CLI on the target.
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// ConnParams holds the connection options common to all MySQL modules.
+type ConnParams struct {
+	User       string // login_user
+	Password   string // login_password
+	Host       string // login_host
+	Port       int    // login_port
+	UnixSocket string // login_unix_socket
+	ConfigFile string // config_file (passed as --defaults-file)
+}
+
+// ParseConnParams reads the login_* and config_file parameters.
+func ParseConnParams(params map[string]any) ConnParams {
+	return ConnParams{
+		User:       param.String(params, "login_user", ""),
+		Password:   param.String(params, "login_password", ""),
+		Host:       param.String(params, "login_host", ""),
+		Port:       param.Int(params, "login_port", 0),
+		UnixSocket: param.String(params, "login_unix_socket", ""),
+		ConfigFile: param.String(params, "config_file", ""),
+	}
+}
+
+// Client runs SQL statements on the target via the mysql CLI.
+type Client struct {
+	conn   connector.Connector
+	params ConnParams
+}
+
+// NewClient creates a client and verifies that the mysql CLI is available.
+func NewClient(ctx context.Context, conn connector.Connector, params ConnParams) (*Client, error) {
+	result, err := conn.Execute(ctx, "command -v mysql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for mysql: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("mysql client is not installed")
+	}
+	return &Client{conn: conn, params: params}, nil
+}
+
+// command builds the mysql invocation for a statement.
+// The password is passed through MYSQL_PWD so it does not show up in ps.
+func (c *Client) command(sql string) string {
+	var b strings.Builder
+	if c.params.Password != "" {
+		b.WriteString("MYSQL_PWD=" + shellutil.Quote(c.params.Password) + " ")
+	}
+	b.WriteString("mysql")
+	// --defaults-file must be the first option
+	if c.params.ConfigFile != "" {
+		b.WriteString(" --defaults-file=" + shellutil.Quote(c.params.ConfigFile))
+	}
+	if c.params.User != "" {
+		b.WriteString(" -u " + shellutil.Quote(c.params.User))
+	}
+	if c.params.UnixSocket != "" {
+		b.WriteString(" -S " + shellutil.Quote(c.params.UnixSocket))
+	} else if c.params.Host != "" {
+		b.WriteString(" -h " + shellutil.Quote(c.params.Host))
+	}
+	if c.params.Port != 0 {
+		b.WriteString(fmt.Sprintf(" -P %d", c.params.Port))
+	}
+	// The statement goes to stdin, as it may hold account passwords; the
+	// here-document delimiter is made unique so the statement cannot end it
+	delim := "BOLT_SQL"
+	for strings.Contains(sql, delim) {
+		delim += "_"
+	}
+	b.WriteString(" -N -B <<'" + delim + "'\n" + sql + "\n" + delim)
+	return b.String()
+}
+
+// Query runs a statement and returns its rows as tab-separated columns.
+func (c *Client) Query(ctx context.Context, sql string) ([][]string, error) {
+	result, err := c.conn.Execute(ctx, c.command(sql))
+	if err != nil {
+		return nil, fmt.Errorf("failed to run mysql: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "mysql query failed")
+	}
+
+	var rows [][]string
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if line == "" {
+			continue
+		}
+		rows = append(rows, strings.Split(line, "\t"))
+	}
+	return rows, nil
+}
+
+// Exec runs a statement that returns no rows.
+func (c *Client) Exec(ctx context.Context, sql string) error {
+	_, err := c.Query(ctx, sql)
+	return err
+}
+
+// QuoteIdent quotes a MySQL identifier (database, table) with backticks.
+func QuoteIdent(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// QuoteString quotes a MySQL string literal.
+func QuoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "'", `\'`)
+	return "'" + s + "'"
+}
@@ -0,0 +1,38 @@
+package mysql
+
+import "testing"
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		params ConnParams
+		sql    string
+		want   string
+	}{
+		{
+			name: "defaults",
+			sql:  "SELECT 1",
+			want: "mysql -N -B <<'BOLT_SQL'\nSELECT 1\nBOLT_SQL",
+		},
+		{
+			name:   "login options",
+			params: ConnParams{User: "root", Password: "pw", Host: "db", Port: 3307},
+			sql:    "SELECT 1",
+			want:   "MYSQL_PWD='pw' mysql -u 'root' -h 'db' -P 3307 -N -B <<'BOLT_SQL'\nSELECT 1\nBOLT_SQL",
+		},
+		{
+			name: "statement holding the delimiter",
+			sql:  "ALTER USER 'a'@'%' IDENTIFIED BY '\nBOLT_SQL\n'",
+			want: "mysql -N -B <<'BOLT_SQL_'\nALTER USER 'a'@'%' IDENTIFIED BY '\nBOLT_SQL\n'\nBOLT_SQL_",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{params: tt.params}
+			if got := c.command(tt.sql); got != tt.want {
+				t.Errorf("command() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
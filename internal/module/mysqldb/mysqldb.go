@@ -0,0 +1,143 @@
+// Package mysqldb provides a module for managing MySQL/MariaDB databases.
+package mysqldb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/module/mysql"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a database.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the database exists
+	StateAbsent  State = "absent"  // Ensure the database does not exist
+)
+
+// Module manages MySQL and MariaDB databases.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "mysql_db"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "Database name"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "encoding", Type: "string", Description: "Character set (e.g., `utf8mb4`)"},
+		{Name: "collation", Type: "string", Description: "Collation (e.g., `utf8mb4_unicode_ci`)"},
+		{Name: "login_user", Type: "string", Description: "User to connect as"},
+		{Name: "login_password", Type: "string", Description: "Password to connect with (passed via `MYSQL_PWD`)"},
+		{Name: "login_host", Type: "string", Description: "Host to connect to"},
+		{Name: "login_port", Type: "int", Description: "Port to connect to"},
+		{Name: "login_unix_socket", Type: "string", Description: "Unix socket to connect through; takes precedence over `login_host`"},
+		{Name: "config_file", Type: "string", Description: "Client option file, passed as `--defaults-file`"},
+	}
+}
+
+// Run executes the mysql_db module.
+//
+// Parameters:
+//   - name (string, required): Database name
+//   - state (string): Desired state - present, absent (default: present)
+//   - encoding (string): Character set for new databases (e.g., "utf8mb4")
+//   - collation (string): Collation for new databases (e.g., "utf8mb4_unicode_ci")
+//   - login_user (string): User to connect as
+//   - login_password (string): Password to connect with
+//   - login_host (string): Host to connect to
+//   - login_port (int): Port to connect to
+//   - login_unix_socket (string): Unix socket to connect through (takes precedence over login_host)
+//   - config_file (string): Client option file passed as --defaults-file
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	encoding := param.String(params, "encoding", "")
+	collation := param.String(params, "collation", "")
+
+	switch state {
+	case StatePresent, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	client, err := mysql.NewClient(ctx, conn, mysql.ParseConnParams(params))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := client.Query(ctx, fmt.Sprintf(
+		"SELECT DEFAULT_CHARACTER_SET_NAME, DEFAULT_COLLATION_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = %s",
+		mysql.QuoteString(name)))
+	if err != nil {
+		return nil, err
+	}
+	exists := len(rows) > 0
+
+	if state == StateAbsent {
+		if !exists {
+			return module.Unchanged(fmt.Sprintf("database %s already absent", name)), nil
+		}
+		if err := client.Exec(ctx, "DROP DATABASE "+mysql.QuoteIdent(name)); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("dropped database %s", name)), nil
+	}
+
+	if !exists {
+		sql := "CREATE DATABASE " + mysql.QuoteIdent(name)
+		sql += charsetClause(encoding, collation)
+		if err := client.Exec(ctx, sql); err != nil {
+			return nil, err
+		}
+		return module.ChangedWithData(fmt.Sprintf("created database %s", name), map[string]any{
+			"name": name,
+		}), nil
+	}
+
+	// Align the defaults of an existing database with the requested ones
+	currentEncoding, currentCollation := rows[0][0], ""
+	if len(rows[0]) > 1 {
+		currentCollation = rows[0][1]
+	}
+	if (encoding == "" || encoding == currentEncoding) && (collation == "" || collation == currentCollation) {
+		return module.Unchanged(fmt.Sprintf("database %s exists", name)), nil
+	}
+
+	if err := client.Exec(ctx, "ALTER DATABASE "+mysql.QuoteIdent(name)+charsetClause(encoding, collation)); err != nil {
+		return nil, err
+	}
+	return module.Changed(fmt.Sprintf("updated character set of database %s", name)), nil
+}
+
+// charsetClause returns the CHARACTER SET / COLLATE suffix for CREATE and ALTER DATABASE.
+func charsetClause(encoding, collation string) string {
+	var clause string
+	if encoding != "" {
+		clause += " CHARACTER SET " + mysql.QuoteString(encoding)
+	}
+	if collation != "" {
+		clause += " COLLATE " + mysql.QuoteString(collation)
+	}
+	return clause
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
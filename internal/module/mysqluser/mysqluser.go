@@ -0,0 +1,418 @@
+// Package mysqluser provides a module for managing MySQL/MariaDB users and grants.
+package mysqluser
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/module/mysql"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a user.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the user exists
+	StateAbsent  State = "absent"  // Ensure the user does not exist
+)
+
+// Module manages MySQL and MariaDB user accounts and their privileges.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "mysql_user"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "User name"},
+		{Name: "host", Type: "string", Default: "localhost", Description: "Host part of the account"},
+		{Name: "password", Type: "string", Description: "Account password"},
+		{Name: "update_password", Type: "string", Default: "always", Choices: []string{"always", "on_create"}, Description: "When to update the password"},
+		{Name: "priv", Type: "string/map", Description: "Privileges (see below)"},
+		{Name: "append_privs", Type: "bool", Default: false, Description: "Add privileges instead of replacing them"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+	}
+}
+
+// Run executes the mysql_user module.
+//
+// Parameters:
+//   - name (string, required): User name
+//   - host (string): Host part of the account (default: localhost)
+//   - password (string): Password for the account
+//   - update_password (string): always, on_create (default: always)
+//   - priv (string|map): Privileges, e.g. "db.*:ALL/other.*:SELECT,INSERT" or {"db.*": "ALL,GRANT"}
+//   - append_privs (bool): Add privileges instead of replacing existing ones (default: false)
+//   - state (string): Desired state - present, absent (default: present)
+//   - login_user, login_password, login_host, login_port, login_unix_socket, config_file: Connection options
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	host := param.String(params, "host", "localhost")
+	password, hasPassword := param.LookupString(params, "password")
+	if params["password"] != nil && !hasPassword {
+		return nil, module.ParamErrorf("password", "parameter 'password' must be a string")
+	}
+	updatePassword := param.String(params, "update_password", "always")
+	appendPrivs := param.Bool(params, "append_privs", false)
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+
+	switch state {
+	case StatePresent, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	if updatePassword != "always" && updatePassword != "on_create" {
+		return nil, module.ParamErrorf("update_password", "invalid update_password '%s': must be always or on_create", updatePassword)
+	}
+
+	desired, hasPriv, err := parsePrivParam(params["priv"])
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := mysql.NewClient(ctx, conn, mysql.ParseConnParams(params))
+	if err != nil {
+		return nil, err
+	}
+
+	account := mysql.QuoteString(name) + "@" + mysql.QuoteString(host)
+
+	rows, err := client.Query(ctx, fmt.Sprintf(
+		"SELECT plugin, HEX(authentication_string) FROM mysql.user WHERE User = %s AND Host = %s",
+		mysql.QuoteString(name), mysql.QuoteString(host)))
+	if err != nil {
+		return nil, err
+	}
+	exists := len(rows) > 0
+
+	if state == StateAbsent {
+		if !exists {
+			return module.Unchanged(fmt.Sprintf("user %s@%s already absent", name, host)), nil
+		}
+		if err := client.Exec(ctx, "DROP USER "+account); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed user %s@%s", name, host)), nil
+	}
+
+	var messages []string
+
+	if !exists {
+		sql := "CREATE USER " + account
+		if hasPassword {
+			sql += " IDENTIFIED BY " + mysql.QuoteString(password)
+		}
+		if err := client.Exec(ctx, sql); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("created user %s@%s", name, host))
+	} else if hasPassword && updatePassword == "always" {
+		plugin := rows[0][0]
+		var authString []byte
+		if len(rows[0]) > 1 {
+			authString, _ = hex.DecodeString(rows[0][1])
+		}
+
+		match, known := passwordMatches(plugin, authString, password)
+		if !known {
+			return nil, fmt.Errorf("cannot compare password for user %s@%s: unsupported authentication plugin '%s'; set update_password=on_create", name, host, plugin)
+		}
+		if !match {
+			if err := client.Exec(ctx, "ALTER USER "+account+" IDENTIFIED BY "+mysql.QuoteString(password)); err != nil {
+				return nil, err
+			}
+			messages = append(messages, "updated password")
+		}
+	}
+
+	if hasPriv {
+		current, err := currentGrants(ctx, client, account)
+		if err != nil {
+			return nil, err
+		}
+		statements := grantStatements(current, desired, account, appendPrivs)
+		for _, sql := range statements {
+			if err := client.Exec(ctx, sql); err != nil {
+				return nil, err
+			}
+		}
+		if len(statements) > 0 {
+			messages = append(messages, "updated privileges")
+		}
+	}
+
+	if len(messages) == 0 {
+		return module.Unchanged(fmt.Sprintf("user %s@%s already in desired state", name, host)), nil
+	}
+
+	return module.ChangedWithData(strings.Join(messages, ", "), map[string]any{
+		"user": name,
+		"host": host,
+	}), nil
+}
+
+// privileges maps a grant object (e.g., "db.*") to its set of privileges.
+// The pseudo-privilege GRANT stands for WITH GRANT OPTION.
+type privileges map[string]map[string]bool
+
+var privNamePattern = regexp.MustCompile(`^[A-Z][A-Z_ ]*$`)
+
+// parsePrivParam parses the priv parameter from its string or map form.
+func parsePrivParam(v any) (privileges, bool, error) {
+	if v == nil {
+		return nil, false, nil
+	}
+
+	privs := make(privileges)
+	add := func(object, list string) error {
+		object = normalizeObject(object)
+		if !strings.Contains(object, ".") {
+			return fmt.Errorf("invalid privilege object '%s': must be db.table", object)
+		}
+		set := make(map[string]bool)
+		for _, p := range strings.Split(list, ",") {
+			p = normalizePriv(p)
+			if p == "" || p == "USAGE" {
+				continue
+			}
+			if !privNamePattern.MatchString(p) {
+				return fmt.Errorf("invalid privilege '%s' on %s", p, object)
+			}
+			set[p] = true
+		}
+		privs[object] = set
+		return nil
+	}
+
+	switch val := v.(type) {
+	case string:
+		for _, part := range strings.Split(val, "/") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			idx := strings.LastIndex(part, ":")
+			if idx < 0 {
+				return nil, false, module.ParamErrorf("priv", "invalid priv '%s': must be db.table:PRIV1,PRIV2", part)
+			}
+			if err := add(strings.TrimSpace(part[:idx]), part[idx+1:]); err != nil {
+				return nil, false, err
+			}
+		}
+	case map[string]any:
+		for object, list := range val {
+			s, ok := list.(string)
+			if !ok {
+				return nil, false, fmt.Errorf("privileges for '%s' must be a string", object)
+			}
+			if err := add(object, s); err != nil {
+				return nil, false, err
+			}
+		}
+	default:
+		return nil, false, module.ParamErrorf("priv", "parameter 'priv' must be a string or a map")
+	}
+
+	return privs, true, nil
+}
+
+// currentGrants parses SHOW GRANTS output into privileges.
+func currentGrants(ctx context.Context, client *mysql.Client, account string) (privileges, error) {
+	rows, err := client.Query(ctx, "SHOW GRANTS FOR "+account)
+	if err != nil {
+		return nil, err
+	}
+
+	privs := make(privileges)
+	for _, row := range rows {
+		object, set, ok := parseGrant(row[0])
+		if !ok {
+			continue
+		}
+		if privs[object] == nil {
+			privs[object] = make(map[string]bool)
+		}
+		for p := range set {
+			privs[object][p] = true
+		}
+	}
+	return privs, nil
+}
+
+// parseGrant parses one SHOW GRANTS line such as
+// "GRANT SELECT, INSERT ON `db`.* TO `user`@`localhost` WITH GRANT OPTION".
+// Role grants (without ON) are ignored.
+func parseGrant(line string) (string, map[string]bool, bool) {
+	if !strings.HasPrefix(line, "GRANT ") {
+		return "", nil, false
+	}
+	rest := strings.TrimPrefix(line, "GRANT ")
+	onIdx := strings.Index(rest, " ON ")
+	if onIdx < 0 {
+		return "", nil, false
+	}
+	list := rest[:onIdx]
+	rest = rest[onIdx+len(" ON "):]
+	toIdx := strings.Index(rest, " TO ")
+	if toIdx < 0 {
+		return "", nil, false
+	}
+	object := normalizeObject(rest[:toIdx])
+
+	set := make(map[string]bool)
+	for _, p := range strings.Split(list, ",") {
+		if p = normalizePriv(p); p != "" && p != "USAGE" {
+			set[p] = true
+		}
+	}
+	if strings.HasSuffix(line, " WITH GRANT OPTION") {
+		set["GRANT"] = true
+	}
+	return object, set, true
+}
+
+// grantStatements returns the REVOKE/GRANT statements that turn current into desired.
+func grantStatements(current, desired privileges, account string, appendPrivs bool) []string {
+	var statements []string
+
+	objects := make([]string, 0, len(desired))
+	for object := range desired {
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+
+	for _, object := range objects {
+		want := desired[object]
+		have := current[object]
+
+		if appendPrivs {
+			missing := make(map[string]bool)
+			for p := range want {
+				if !have[p] && !(p != "GRANT" && have["ALL"]) {
+					missing[p] = true
+				}
+			}
+			if len(missing) > 0 {
+				statements = append(statements, grantSQL(object, missing, account))
+			}
+			continue
+		}
+
+		if equalSets(want, have) {
+			continue
+		}
+		statements = append(statements, revokeSQL(object, have, account)...)
+		statements = append(statements, grantSQL(object, want, account))
+	}
+
+	if !appendPrivs {
+		var stale []string
+		for object, have := range current {
+			if _, ok := desired[object]; !ok && len(have) > 0 {
+				stale = append(stale, object)
+			}
+		}
+		sort.Strings(stale)
+		for _, object := range stale {
+			statements = append(statements, revokeSQL(object, current[object], account)...)
+		}
+	}
+
+	return statements
+}
+
+// grantSQL builds a GRANT statement for a set of privileges.
+func grantSQL(object string, set map[string]bool, account string) string {
+	var names []string
+	for p := range set {
+		if p != "GRANT" {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		names = []string{"USAGE"}
+	}
+
+	sql := fmt.Sprintf("GRANT %s ON %s TO %s", strings.Join(names, ", "), quoteObject(object), account)
+	if set["GRANT"] {
+		sql += " WITH GRANT OPTION"
+	}
+	return sql
+}
+
+// revokeSQL builds the statements removing all privileges on object.
+func revokeSQL(object string, have map[string]bool, account string) []string {
+	var statements []string
+	if len(have) > 1 || (len(have) == 1 && !have["GRANT"]) {
+		statements = append(statements, fmt.Sprintf("REVOKE ALL PRIVILEGES ON %s FROM %s", quoteObject(object), account))
+	}
+	if have["GRANT"] {
+		statements = append(statements, fmt.Sprintf("REVOKE GRANT OPTION ON %s FROM %s", quoteObject(object), account))
+	}
+	return statements
+}
+
+// normalizeObject strips identifier quotes from a grant object.
+func normalizeObject(object string) string {
+	object = strings.TrimSpace(object)
+	object = strings.ReplaceAll(object, "`", "")
+	return strings.ReplaceAll(object, "'", "")
+}
+
+// normalizePriv uppercases a privilege name and maps ALL PRIVILEGES to ALL.
+func normalizePriv(p string) string {
+	p = strings.ToUpper(strings.Join(strings.Fields(p), " "))
+	if p == "ALL PRIVILEGES" {
+		return "ALL"
+	}
+	return p
+}
+
+// quoteObject quotes a db.table grant object, leaving wildcards bare.
+func quoteObject(object string) string {
+	db, table, _ := strings.Cut(object, ".")
+	quote := func(s string) string {
+		if s == "*" {
+			return s
+		}
+		return mysql.QuoteIdent(s)
+	}
+	return quote(db) + "." + quote(table)
+}
+
+// equalSets reports whether two privilege sets contain the same entries.
+func equalSets(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -0,0 +1,85 @@
+package mysqluser
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+// query returns the mysql invocation running sql.
+func query(sql string) string {
+	return "mysql -N -B <<'BOLT_SQL'\n" + sql + "\nBOLT_SQL"
+}
+
+const lookupSQL = "SELECT plugin, HEX(authentication_string) FROM mysql.user WHERE User = 'app' AND Host = 'localhost'"
+
+func TestRunCreate(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("command -v mysql").Return("/usr/bin/mysql\n")
+	conn.On(query(lookupSQL)).Return("")
+	conn.On(query("CREATE USER 'app'@'localhost' IDENTIFIED BY '1234'")).Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{
+		"name":     "app",
+		"password": 1234,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  string
+		changed bool
+	}{
+		// HEX() of the mysql_native_password hash of "secret"
+		{"unchanged", "mysql_native_password\t2A31344536353536374142444235313335443043464439413730423330333243313739413439454537", false},
+		{"changed", "mysql_native_password\t2A32343730433043303644454534324644313631384242393930303541444341324543394431453139", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			conn.On("command -v mysql").Return("/usr/bin/mysql\n")
+			conn.On(query(lookupSQL)).Return(tt.stored + "\n")
+			conn.Default(connector.Result{})
+
+			result, err := (&Module{}).Run(context.Background(), conn, map[string]any{
+				"name":     "app",
+				"password": "secret",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed != tt.changed {
+				t.Errorf("Changed = %v, want %v", result.Changed, tt.changed)
+			}
+			if alter := query("ALTER USER 'app'@'localhost' IDENTIFIED BY 'secret'"); conn.Executed(alter) != tt.changed {
+				t.Errorf("ALTER USER executed = %v, want %v", !tt.changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestRunPasswordNotString(t *testing.T) {
+	conn := connectortest.New()
+
+	_, err := (&Module{}).Run(context.Background(), conn, map[string]any{
+		"name":     "app",
+		"password": []any{"secret"},
+	})
+	var paramErr *module.ParamError
+	if !errors.As(err, &paramErr) || paramErr.Param != "password" {
+		t.Errorf("error = %v, want a password parameter error", err)
+	}
+}
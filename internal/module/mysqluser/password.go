@@ -0,0 +1,144 @@
+package mysqluser
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// passwordMatches reports whether password produces the stored authentication
+// string for the given plugin. The second return value is false when the
+// plugin's hash format is not understood and no comparison was possible.
+func passwordMatches(plugin string, authString []byte, password string) (match bool, known bool) {
+	switch plugin {
+	case "", "mysql_native_password":
+		return nativePasswordHash(password) == strings.ToUpper(string(authString)), true
+	case "caching_sha2_password", "sha256_password":
+		return cachingSHA2Matches(authString, password)
+	}
+	return false, false
+}
+
+// nativePasswordHash returns the mysql_native_password hash:
+// "*" followed by the uppercase hex of SHA1(SHA1(password)).
+func nativePasswordHash(password string) string {
+	first := sha1.Sum([]byte(password))
+	second := sha1.Sum(first[:])
+	return "*" + strings.ToUpper(hex.EncodeToString(second[:]))
+}
+
+// cachingSHA2Matches checks a caching_sha2_password authentication string,
+// formatted as "$A$<rounds/1000 in hex>$<20-byte salt><43-char digest>".
+func cachingSHA2Matches(authString []byte, password string) (bool, bool) {
+	const saltLen, digestLen = 20, 43
+
+	if len(authString) != 7+saltLen+digestLen || string(authString[:3]) != "$A$" || authString[6] != '$' {
+		return false, false
+	}
+	rounds, err := strconv.ParseInt(string(authString[3:6]), 16, 32)
+	if err != nil {
+		return false, false
+	}
+	salt := authString[7 : 7+saltLen]
+	digest := string(authString[7+saltLen:])
+
+	return sha256Crypt([]byte(password), salt, int(rounds)*1000) == digest, true
+}
+
+// sha256Crypt implements the SHA-256 based crypt(3) digest (without the
+// "$5$" prefix and salt), as used by MySQL's caching_sha2_password.
+func sha256Crypt(key, salt []byte, rounds int) string {
+	// Digest B: key + salt + key
+	b := sha256.New()
+	b.Write(key)
+	b.Write(salt)
+	b.Write(key)
+	digestB := b.Sum(nil)
+
+	// Digest A
+	a := sha256.New()
+	a.Write(key)
+	a.Write(salt)
+	n := len(key)
+	for ; n > 32; n -= 32 {
+		a.Write(digestB)
+	}
+	a.Write(digestB[:n])
+	for n = len(key); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			a.Write(digestB)
+		} else {
+			a.Write(key)
+		}
+	}
+	digestA := a.Sum(nil)
+
+	// Byte sequence P
+	dp := sha256.New()
+	for i := 0; i < len(key); i++ {
+		dp.Write(key)
+	}
+	p := repeatTo(dp.Sum(nil), len(key))
+
+	// Byte sequence S
+	ds := sha256.New()
+	for i := 0; i < 16+int(digestA[0]); i++ {
+		ds.Write(salt)
+	}
+	s := repeatTo(ds.Sum(nil), len(salt))
+
+	c := digestA
+	for i := 0; i < rounds; i++ {
+		h := sha256.New()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(nil)
+	}
+
+	var out strings.Builder
+	order := [][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	}
+	for _, o := range order {
+		encode24(&out, c[o[0]], c[o[1]], c[o[2]], 4)
+	}
+	encode24(&out, 0, c[31], c[30], 3)
+	return out.String()
+}
+
+// repeatTo repeats src until it is n bytes long.
+func repeatTo(src []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		out = append(out, src[:min(len(src), n-len(out))]...)
+	}
+	return out
+}
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encode24 writes n characters of the crypt base64 encoding of three bytes.
+func encode24(out *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		out.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
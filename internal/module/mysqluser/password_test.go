@@ -0,0 +1,79 @@
+package mysqluser
+
+import "testing"
+
+// The expected digests are those of crypt(3) SHA-256 (openssl passwd -5).
+// MySQL uses a 20-byte salt, which crypt(3) would truncate to 16 bytes, so
+// that digest comes from the same algorithm without the truncation.
+func TestSHA256Crypt(t *testing.T) {
+	tests := []struct {
+		key    string
+		salt   string
+		rounds int
+		want   string
+	}{
+		{"Hello world!", "saltstring", 5000, "5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+		{"Hello world!", "saltstring", 1000, "z/y8l95GSjij6uHx2xAJer7YCODLtrhIxItWC13D4g5"},
+		{"password", "saltstring", 1000, "S2dlplsLzofRuJ/frAjtaYev58CdqEN6fUby2laILG1"},
+		{
+			"a very much longer text to encrypt.  This one even stretches over morethan one line.",
+			"saltstring", 5000, "GyTEZtFuSijnDwgZAdeQeFYSkAzF3f3QpZGhkJUrWWD",
+		},
+		{"secret", "abcdefghijklmnopqrst", 5000, "Yy1cVJ5jT.fk4HyAGlowRhOkI55As4SAesbspXHQvFD"},
+	}
+
+	for _, tt := range tests {
+		if got := sha256Crypt([]byte(tt.key), []byte(tt.salt), tt.rounds); got != tt.want {
+			t.Errorf("sha256Crypt(%q, %q, %d) = %q, want %q", tt.key, tt.salt, tt.rounds, got, tt.want)
+		}
+	}
+}
+
+func TestNativePasswordHash(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"password", "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19"},
+		{"secret", "*14E65567ABDB5135D0CFD9A70B3032C179A49EE7"},
+	}
+
+	for _, tt := range tests {
+		if got := nativePasswordHash(tt.password); got != tt.want {
+			t.Errorf("nativePasswordHash(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordMatches(t *testing.T) {
+	const sha2 = "$A$005$abcdefghijklmnopqrstYy1cVJ5jT.fk4HyAGlowRhOkI55As4SAesbspXHQvFD"
+
+	tests := []struct {
+		name       string
+		plugin     string
+		authString string
+		password   string
+		match      bool
+		known      bool
+	}{
+		{"native", "mysql_native_password", "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19", "password", true, true},
+		{"native lowercase", "mysql_native_password", "*2470c0c06dee42fd1618bb99005adca2ec9d1e19", "password", true, true},
+		{"native mismatch", "mysql_native_password", "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19", "Password", false, true},
+		{"no plugin", "", "*14E65567ABDB5135D0CFD9A70B3032C179A49EE7", "secret", true, true},
+		{"caching_sha2", "caching_sha2_password", sha2, "secret", true, true},
+		{"caching_sha2 mismatch", "caching_sha2_password", sha2, "secret!", false, true},
+		{"sha256_password", "sha256_password", sha2, "secret", true, true},
+		{"caching_sha2 truncated", "caching_sha2_password", sha2[:40], "secret", false, false},
+		{"caching_sha2 bad rounds", "caching_sha2_password", "$A$0x5" + sha2[6:], "secret", false, false},
+		{"unknown plugin", "auth_socket", "", "secret", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, known := passwordMatches(tt.plugin, []byte(tt.authString), tt.password)
+			if match != tt.match || known != tt.known {
+				t.Errorf("passwordMatches() = %v, %v, want %v, %v", match, known, tt.match, tt.known)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package module
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Module names
+//
+// Built-in modules have short names (apt, copy) and are also known by
+// their fully qualified name in the core collection (bolt.core.apt).
+// Third-party module packs register their modules under a fully qualified
+// name, <namespace>.<collection>.<module> (acme.cloud.deploy), so they
+// cannot collide with the built-ins or with each other. A play finds them
+// by their short name through its collections, a search path of
+// collections tried before the built-ins.
+
+// CoreCollection is the collection of the built-in modules.
+const CoreCollection = "bolt.core"
+
+// checkName returns an error if name is not a valid name to register a
+// module under: a short name, or a fully qualified name outside the
+// reserved bolt namespace.
+func checkName(name string) error {
+	if !strings.Contains(name, ".") {
+		return nil
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) != 3 || slices.Contains(parts, "") {
+		return fmt.Errorf("module name %q must be a short name or <namespace>.<collection>.<module>", name)
+	}
+	if parts[0] == "bolt" {
+		return fmt.Errorf("module name %q uses the reserved bolt namespace", name)
+	}
+	return nil
+}
+
+// Resolve returns the registered name of the module that name refers to,
+// or "" if there is none. A fully qualified name refers to the module of
+// that collection; a short name to the first module of that name in
+// collections, in order, and otherwise to the built-in. Listing
+// CoreCollection in collections puts the built-ins ahead of the
+// collections after it.
+func Resolve(name string, collections []string) string {
+	if short, ok := strings.CutPrefix(name, CoreCollection+"."); ok {
+		if strings.Contains(short, ".") || Get(short) == nil {
+			return ""
+		}
+		return short
+	}
+
+	if !strings.Contains(name, ".") {
+		for _, collection := range collections {
+			qualified := collection + "." + name
+			if collection == CoreCollection {
+				qualified = name
+			}
+			if Get(qualified) != nil {
+				return qualified
+			}
+		}
+	}
+	if Get(name) == nil {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,49 @@
+package module
+
+import (
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	Register(&mockModule{name: "test_ns_pkg"})
+	Register(&mockModule{name: "acme.tools.test_ns_pkg"})
+	Register(&mockModule{name: "acme.tools.test_ns_deploy"})
+
+	tests := []struct {
+		name        string
+		collections []string
+		want        string
+	}{
+		{"test_ns_pkg", nil, "test_ns_pkg"},
+		{"bolt.core.test_ns_pkg", []string{"acme.tools"}, "test_ns_pkg"},
+		{"test_ns_pkg", []string{"acme.tools"}, "acme.tools.test_ns_pkg"},
+		{"test_ns_pkg", []string{"bolt.core", "acme.tools"}, "test_ns_pkg"},
+		{"test_ns_pkg", []string{"other.pack", "acme.tools"}, "acme.tools.test_ns_pkg"},
+		{"test_ns_deploy", []string{"acme.tools"}, "acme.tools.test_ns_deploy"},
+		{"acme.tools.test_ns_deploy", nil, "acme.tools.test_ns_deploy"},
+		{"test_ns_deploy", nil, ""},
+		{"bolt.core.acme.tools.test_ns_deploy", nil, ""},
+		{"bolt.core.missing", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Resolve(tt.name, tt.collections); got != tt.want {
+			t.Errorf("Resolve(%q, %q) = %q, want %q", tt.name, tt.collections, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterInvalidName(t *testing.T) {
+	for _, name := range []string{"bolt.core.test_ns", "bolt.extra.test_ns", "acme.test_ns", "acme..test_ns", "a.b.c.d"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			Register(&mockModule{name: name})
+		}()
+		if Get(name) != nil {
+			t.Errorf("Register(%q) registered the module", name)
+		}
+	}
+}
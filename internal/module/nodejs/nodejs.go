@@ -0,0 +1,330 @@
+// Package nodejs provides a module for installing Node.js versions with nvm
+// or volta.
+package nodejs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// DefaultNvmVersion is the release of nvm installed when it is missing.
+const DefaultNvmVersion = "v0.40.1"
+
+// Module installs Node.js versions for the connecting user.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "nodejs"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "version", Type: "string", Required: true, Description: "Node.js version, e.g. 20, 20.11.1, or lts/iron (nvm only)"},
+		{Name: "manager", Type: "string", Default: "nvm", Choices: []string{"nvm", "volta"}, Description: "Version manager"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state (absent needs nvm)"},
+		{Name: "default", Type: "bool", Default: true, Description: "Make the version the user's default"},
+		{Name: "nvm_version", Type: "string", Default: DefaultNvmVersion, Description: "Release of nvm to install if missing"},
+	}
+}
+
+// Run executes the nodejs module.
+//
+// The version manager is installed for the connecting user if missing,
+// without changing the user's profile; run the task without become. A
+// version such as 20 is satisfied by any installed 20.x release.
+//
+// Parameters:
+//   - version (string, required): Node.js version to install
+//   - manager (string): Version manager - nvm, volta (default: nvm)
+//   - state (string): Desired state - present, absent (default: present)
+//   - default (bool): Make the version the user's default (default: true)
+//   - nvm_version (string): Release of nvm to install if missing (default: v0.40.1)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	version, err := param.Required(params, "version")
+	if err != nil {
+		return nil, err
+	}
+	version = strings.TrimPrefix(version, "v")
+	managerName := param.String(params, "manager", "nvm")
+	state := param.String(params, "state", "present")
+	makeDefault := param.Bool(params, "default", true)
+	nvmVersion := param.String(params, "nvm_version", DefaultNvmVersion)
+	check := module.IsCheckMode(params)
+	offline := module.IsOffline(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	var mgr manager
+	switch managerName {
+	case "nvm":
+		mgr = &nvm{conn: conn}
+	case "volta":
+		if state == "absent" {
+			return nil, module.ParamErrorf("state", "volta cannot uninstall Node.js versions; use manager: nvm")
+		}
+		mgr = &volta{conn: conn}
+	default:
+		return nil, module.ParamErrorf("manager", "invalid manager '%s': must be nvm or volta", managerName)
+	}
+
+	found, err := mgr.find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if state == "absent" {
+			return module.Unchanged(fmt.Sprintf("%s is not installed", managerName)), nil
+		}
+		if check {
+			return module.Changed(fmt.Sprintf("would install %s and node %s", managerName, version)), nil
+		}
+		if offline {
+			return nil, module.OfflineErrorf("installing "+managerName, "install it from a local mirror first")
+		}
+		if err := mgr.setup(ctx, nvmVersion); err != nil {
+			return nil, fmt.Errorf("failed to install %s: %w", managerName, err)
+		}
+	}
+
+	installed, isDefault, err := mgr.status(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == "absent" {
+		if installed == "" {
+			return module.Unchanged(fmt.Sprintf("node %s is not installed", version)), nil
+		}
+		before := map[string]any{"version": installed}
+		if check {
+			return module.Changed(fmt.Sprintf("would remove node %s", installed)).WithDiff(before, nil), nil
+		}
+		if err := mgr.uninstall(ctx, installed); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed node %s", installed)).WithDiff(before, nil), nil
+	}
+
+	switch {
+	case installed == "":
+		if check {
+			return module.Changed(fmt.Sprintf("would install node %s", version)), nil
+		}
+		if offline {
+			return nil, module.OfflineErrorf("installing node "+version, "run the task once with network access")
+		}
+		if err := mgr.install(ctx, version, makeDefault); err != nil {
+			return nil, err
+		}
+		if installed, _, err = mgr.status(ctx, version); err != nil {
+			return nil, err
+		}
+		return module.ChangedWithData(fmt.Sprintf("installed node %s", installed), map[string]any{"version": installed}), nil
+
+	case makeDefault && !isDefault:
+		if check {
+			return module.Changed(fmt.Sprintf("would make node %s the default", installed)), nil
+		}
+		if err := mgr.setDefault(ctx, installed); err != nil {
+			return nil, err
+		}
+		return module.ChangedWithData(fmt.Sprintf("made node %s the default", installed), map[string]any{"version": installed}), nil
+	}
+
+	return &module.Result{Message: fmt.Sprintf("node %s is installed", installed), Data: map[string]any{"version": installed}}, nil
+}
+
+// manager is a Node.js version manager.
+type manager interface {
+	// find reports whether the manager is installed.
+	find(ctx context.Context) (bool, error)
+
+	// setup installs the manager.
+	setup(ctx context.Context, nvmVersion string) error
+
+	// status returns the installed release that satisfies version, or ""
+	// if there is none, and whether it is the default.
+	status(ctx context.Context, version string) (string, bool, error)
+
+	install(ctx context.Context, version string, makeDefault bool) error
+	setDefault(ctx context.Context, version string) error
+	uninstall(ctx context.Context, version string) error
+}
+
+// nvm manages versions with nvm, a shell function loaded from nvm.sh.
+type nvm struct {
+	conn connector.Connector
+}
+
+// command returns the shell command that runs nvm with args.
+func (n *nvm) command(args ...string) string {
+	script := `export NVM_DIR="${NVM_DIR:-$HOME/.nvm}"; . "$NVM_DIR/nvm.sh" && nvm ` + shellutil.Join(args...)
+	return "bash -c " + shellutil.Quote(script)
+}
+
+func (n *nvm) find(ctx context.Context) (bool, error) {
+	result, err := n.conn.Execute(ctx, `test -s "${NVM_DIR:-$HOME/.nvm}/nvm.sh"`)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for nvm: %w", err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+func (n *nvm) setup(ctx context.Context, nvmVersion string) error {
+	url := fmt.Sprintf("https://raw.githubusercontent.com/nvm-sh/nvm/%s/install.sh", nvmVersion)
+	return module.RunCommand(ctx, n.conn, fmt.Sprintf("curl -fsSL %s | PROFILE=/dev/null bash", shellutil.Quote(url)))
+}
+
+func (n *nvm) status(ctx context.Context, version string) (string, bool, error) {
+	installed, err := n.version(ctx, version)
+	if err != nil || installed == "" {
+		return "", false, err
+	}
+	def, err := n.version(ctx, "default")
+	if err != nil {
+		return "", false, err
+	}
+	return installed, def == installed, nil
+}
+
+// version resolves version, or an alias, to an installed release, or ""
+// if none is installed.
+func (n *nvm) version(ctx context.Context, version string) (string, error) {
+	result, err := n.conn.Execute(ctx, n.command("version", version))
+	if err != nil {
+		return "", fmt.Errorf("failed to run nvm: %w", err)
+	}
+	out := strings.TrimSpace(result.Stdout)
+	if out == "" || out == "N/A" || strings.HasPrefix(out, "system") {
+		return "", nil
+	}
+	return strings.TrimPrefix(out, "v"), nil
+}
+
+func (n *nvm) install(ctx context.Context, version string, makeDefault bool) error {
+	if err := module.RunCommand(ctx, n.conn, n.command("install", "--no-progress", version)); err != nil {
+		return err
+	}
+	if makeDefault {
+		return n.setDefault(ctx, version)
+	}
+	return nil
+}
+
+func (n *nvm) setDefault(ctx context.Context, version string) error {
+	return module.RunCommand(ctx, n.conn, n.command("alias", "default", version))
+}
+
+func (n *nvm) uninstall(ctx context.Context, version string) error {
+	// nvm refuses to remove the active version, which is the default
+	script := `export NVM_DIR="${NVM_DIR:-$HOME/.nvm}"; . "$NVM_DIR/nvm.sh" && { nvm deactivate >/dev/null 2>&1; nvm uninstall ` + shellutil.Quote(version) + `; }`
+	return module.RunCommand(ctx, n.conn, "bash -c "+shellutil.Quote(script))
+}
+
+// volta manages versions with volta.
+type volta struct {
+	conn connector.Connector
+	path string
+}
+
+func (v *volta) find(ctx context.Context) (bool, error) {
+	result, err := v.conn.Execute(ctx, `command -v volta || { test -x "$HOME/.volta/bin/volta" && echo "$HOME/.volta/bin/volta"; }`)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for volta: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, nil
+	}
+	v.path = strings.TrimSpace(result.Stdout)
+	return true, nil
+}
+
+func (v *volta) setup(ctx context.Context, _ string) error {
+	if err := module.RunCommand(ctx, v.conn, "curl -fsSL https://get.volta.sh | bash -s -- --skip-setup"); err != nil {
+		return err
+	}
+	found, err := v.find(ctx)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("volta was installed, but was not found on the PATH or in ~/.volta/bin")
+	}
+	return nil
+}
+
+func (v *volta) status(ctx context.Context, version string) (string, bool, error) {
+	result, err := connector.ExecuteArgv(ctx, v.conn, v.path, "list", "node", "--format", "plain")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to run volta: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", false, module.CommandFailedf(result, "volta list failed")
+	}
+
+	// runtime node@20.11.1 (default)
+	var installed string
+	var isDefault bool
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "node@") {
+			continue
+		}
+		release := strings.TrimPrefix(fields[1], "node@")
+		if release != version && !strings.HasPrefix(release, version+".") {
+			continue
+		}
+		def := strings.Contains(line, "(default")
+		if installed == "" || def {
+			installed, isDefault = release, def
+		}
+	}
+	return installed, isDefault, nil
+}
+
+func (v *volta) install(ctx context.Context, version string, makeDefault bool) error {
+	// volta install also makes the version the default; fetch does not
+	cmd := "fetch"
+	if makeDefault {
+		cmd = "install"
+	}
+	return module.RunArgv(ctx, v.conn, v.path, cmd, "node@"+version)
+}
+
+func (v *volta) setDefault(ctx context.Context, version string) error {
+	return module.RunArgv(ctx, v.conn, v.path, "install", "node@"+version)
+}
+
+func (v *volta) uninstall(ctx context.Context, version string) error {
+	return fmt.Errorf("volta cannot uninstall Node.js versions")
+}
+
+// SupportsCheckMode reports that nodejs can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+	_ manager           = (*nvm)(nil)
+	_ manager           = (*volta)(nil)
+)
@@ -0,0 +1,196 @@
+// Package packagefacts provides a module that gathers installed packages
+// as facts.
+package packagefacts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// managers are the supported package managers in the order auto detection
+// tries them, with the command that must exist for each.
+var managers = []struct {
+	name    string
+	command string
+}{
+	{"apt", "dpkg-query"},
+	{"rpm", "rpm"},
+	{"pkgng", "pkg"},
+	{"brew", "brew"},
+}
+
+// Module lists installed packages into facts.packages.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "package_facts"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "manager", Type: "string", Default: "auto", Choices: []string{"auto", "apt", "rpm", "pkgng", "brew"}, Description: "Package manager to query"},
+	}
+}
+
+// Run executes the package_facts module.
+//
+// Parameters:
+//   - manager (string): Package manager to query - auto, apt, rpm, pkgng, brew (default: auto)
+//
+// The packages are returned as the fact "packages": a map from package name
+// to its version, architecture (where known), and the manager it came from.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	manager := param.String(params, "manager", "auto")
+
+	switch manager {
+	case "auto":
+		var err error
+		if manager, err = detectManager(ctx, conn); err != nil {
+			return nil, err
+		}
+	case "apt", "rpm", "pkgng", "brew":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("manager", "invalid manager '%s': must be auto, apt, rpm, pkgng, or brew", manager)
+	}
+
+	packages, err := listPackages(ctx, conn, manager)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("%d packages installed (%s)", len(packages), manager)
+	return module.Unchanged(msg).WithFacts(map[string]any{"packages": packages}), nil
+}
+
+// detectManager returns the first supported package manager found on the
+// target.
+func detectManager(ctx context.Context, conn connector.Connector) (string, error) {
+	for _, m := range managers {
+		result, err := conn.Execute(ctx, "command -v "+m.command)
+		if err != nil {
+			return "", fmt.Errorf("failed to detect package manager: %w", err)
+		}
+		if result.ExitCode == 0 {
+			return m.name, nil
+		}
+	}
+	return "", fmt.Errorf("no supported package manager found (apt, rpm, pkgng, brew)")
+}
+
+// listPackages queries manager for the installed packages.
+func listPackages(ctx context.Context, conn connector.Connector, manager string) (map[string]any, error) {
+	packages := make(map[string]any)
+
+	switch manager {
+	case "apt":
+		out, err := query(ctx, conn, `dpkg-query -W -f='${Status}\t${Package}\t${Version}\t${Architecture}\n'`)
+		if err != nil {
+			return nil, err
+		}
+		for _, fields := range splitLines(out) {
+			// Removed packages keep their config files and stay listed
+			if len(fields) == 4 && strings.HasSuffix(fields[0], " installed") {
+				addPackage(packages, manager, fields[1], fields[2], fields[3])
+			}
+		}
+
+	case "rpm":
+		out, err := query(ctx, conn, `rpm -qa --qf '%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\n'`)
+		if err != nil {
+			return nil, err
+		}
+		for _, fields := range splitLines(out) {
+			if len(fields) == 3 {
+				addPackage(packages, manager, fields[0], fields[1], fields[2])
+			}
+		}
+
+	case "pkgng":
+		out, err := query(ctx, conn, `pkg query '%n %v'`)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(out, "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 {
+				addPackage(packages, manager, fields[0], fields[1], "")
+			}
+		}
+
+	case "brew":
+		for _, cmd := range []string{"brew list --formula --versions", "brew list --cask --versions"} {
+			out, err := query(ctx, conn, cmd)
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range strings.Split(out, "\n") {
+				// name version [version...]; the last is the newest
+				fields := strings.Fields(line)
+				if len(fields) >= 2 {
+					addPackage(packages, manager, fields[0], fields[len(fields)-1], "")
+				}
+			}
+		}
+	}
+
+	return packages, nil
+}
+
+// addPackage records an installed package. A package installed in several
+// versions (e.g., kernels) keeps the last one listed.
+func addPackage(packages map[string]any, manager, name, version, arch string) {
+	pkg := map[string]any{
+		"version": version,
+		"source":  manager,
+	}
+	if arch != "" {
+		pkg["arch"] = arch
+	}
+	packages[name] = pkg
+}
+
+// query runs a package listing command and returns its output.
+func query(ctx context.Context, conn connector.Connector, cmd string) (string, error) {
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", module.CommandFailedf(result, "failed to list packages")
+	}
+	return result.Stdout, nil
+}
+
+// splitLines splits tab-separated output into the fields of each line.
+func splitLines(out string) [][]string {
+	var lines [][]string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, strings.Split(line, "\t"))
+		}
+	}
+	return lines
+}
+
+// SupportsCheckMode reports that package_facts can run in check mode; it
+// only reads the package database.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,214 @@
+// Package pamlimits provides a module for managing resource limits in
+// pam_limits configuration files.
+package pamlimits
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a limit.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the limit is set to the value
+	StateAbsent  State = "absent"  // Ensure the limit is not set
+)
+
+// defaultDest is the file limits are written to unless dest is given.
+const defaultDest = "/etc/security/limits.d/90-bolt.conf"
+
+// limitTypes are the types of limits.conf(5); "-" sets both.
+var limitTypes = []string{"soft", "hard", "-"}
+
+// limitItems are the items of limits.conf(5).
+var limitItems = []string{
+	"core", "data", "fsize", "memlock", "nofile", "rss", "stack", "cpu",
+	"nproc", "as", "maxlogins", "maxsyslogins", "nonewprivs", "priority",
+	"locks", "sigpending", "msgqueue", "nice", "rtprio", "chroot",
+}
+
+// Module manages limit entries in files read by pam_limits.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "pam_limits"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "domain", Type: "string", Required: true, Description: "User, `@group`, or `*`"},
+		{Name: "limit_type", Type: "string", Required: true, Choices: limitTypes, Description: "Limit type; `-` sets both"},
+		{Name: "limit_item", Type: "string", Required: true, Choices: limitItems, Description: "Resource to limit"},
+		{Name: "value", Type: "string/int", Description: "Limit value, a number, `unlimited`, or `infinity`"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "dest", Type: "string", Default: defaultDest, Description: "Limits file"},
+		{Name: "comment", Type: "string", Description: "Comment at the end of the line"},
+	}
+}
+
+// Run executes the pam_limits module.
+//
+// Parameters:
+//   - domain (string, required): User name, @group, or * for everyone
+//   - limit_type (string, required): soft, hard, or - for both
+//   - limit_item (string, required): Resource to limit, e.g. nofile, nproc, memlock
+//   - value (string|int): Limit value - a number, unlimited, or infinity (required for state present)
+//   - state (string): Desired state - present, absent (default: present)
+//   - dest (string): Limits file (default: /etc/security/limits.d/90-bolt.conf)
+//   - comment (string): Comment at the end of the line
+//
+// An entry is identified by its domain, type, and item. Other lines of the
+// file are kept as they are.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	domain, err := param.Required(params, "domain")
+	if err != nil {
+		return nil, err
+	}
+	limitType, err := param.Required(params, "limit_type")
+	if err != nil {
+		return nil, err
+	}
+	item, err := param.Required(params, "limit_item")
+	if err != nil {
+		return nil, err
+	}
+	value := param.String(params, "value", "")
+	state := State(param.String(params, "state", "present"))
+	dest := param.String(params, "dest", defaultDest)
+	comment := param.String(params, "comment", "")
+	check := module.IsCheckMode(params)
+
+	if strings.ContainsAny(domain, " \t") {
+		return nil, module.ParamErrorf("domain", "invalid domain '%s'", domain)
+	}
+	if !slices.Contains(limitTypes, limitType) {
+		return nil, module.ParamErrorf("limit_type", "invalid limit_type '%s': must be soft, hard, or -", limitType)
+	}
+	if !slices.Contains(limitItems, item) {
+		return nil, module.ParamErrorf("limit_item", "invalid limit_item '%s'", item)
+	}
+	if state != StatePresent && state != StateAbsent {
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if state == StatePresent {
+		if value == "" {
+			return nil, module.ParamErrorf("value", "'value' parameter is required with state present")
+		}
+		if err := validateValue(value); err != nil {
+			return nil, module.ParamErrorf("value", "%v", err)
+		}
+	}
+
+	content, _, err := module.ReadFile(ctx, conn, dest)
+	if err != nil {
+		return nil, err
+	}
+
+	line := fmt.Sprintf("%s\t%s\t%s\t%s", domain, limitType, item, value)
+	if comment != "" {
+		line += "\t# " + comment
+	}
+
+	var newLines []string
+	found := false
+	for _, l := range module.SplitLines(string(content)) {
+		if !matches(l, domain, limitType, item) {
+			newLines = append(newLines, l)
+			continue
+		}
+		if state == StatePresent && !found {
+			if inSync(l, value, comment) {
+				newLines = append(newLines, l)
+			} else {
+				newLines = append(newLines, line)
+			}
+		}
+		found = true
+	}
+	if state == StatePresent && !found {
+		newLines = append(newLines, line)
+	}
+
+	newContent := module.JoinLines(newLines)
+	if newContent == string(content) {
+		if state == StateAbsent {
+			return module.Unchanged("limit already absent"), nil
+		}
+		return module.Unchanged("limit already set"), nil
+	}
+
+	entry := fmt.Sprintf("%s %s %s", domain, limitType, item)
+	msg := fmt.Sprintf("limit '%s' set to %s", entry, value)
+	if state == StateAbsent {
+		msg = fmt.Sprintf("limit '%s' removed", entry)
+	}
+	data := map[string]any{"dest": dest}
+	if check {
+		return module.ChangedWithData(msg+" (check mode)", data), nil
+	}
+
+	if err := module.WriteFile(ctx, conn, dest, []byte(newContent), ""); err != nil {
+		return nil, err
+	}
+	return module.ChangedWithData(msg, data), nil
+}
+
+// matches reports whether line sets the limit of domain, type, and item.
+func matches(line, domain, limitType, item string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+		return false
+	}
+	return fields[0] == domain && fields[1] == limitType && fields[2] == item
+}
+
+// inSync reports whether an existing line has the value and, if one is
+// given, the comment.
+func inSync(line, value, comment string) bool {
+	fields := strings.Fields(line)
+	if fields[3] != value {
+		return false
+	}
+	if comment == "" {
+		return true
+	}
+	_, have, _ := strings.Cut(line, "#")
+	return strings.TrimSpace(have) == comment
+}
+
+// validateValue checks that value is a number or unlimited.
+func validateValue(value string) error {
+	switch value {
+	case "unlimited", "infinity":
+		return nil
+	}
+	if _, err := strconv.Atoi(value); err != nil {
+		return fmt.Errorf("invalid value '%s': must be a number, unlimited, or infinity", value)
+	}
+	return nil
+}
+
+// SupportsCheckMode reports that pam_limits can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
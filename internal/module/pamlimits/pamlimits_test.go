@@ -0,0 +1,148 @@
+package pamlimits
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const testTmp = "/tmp/tmp.limits"
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     *string
+		params      map[string]any
+		wantChanged bool
+		want        string
+	}{
+		{
+			name:        "new file",
+			params:      map[string]any{"value": 65536},
+			wantChanged: true,
+			want:        "app\tsoft\tnofile\t65536\n",
+		},
+		{
+			name:        "append",
+			content:     ptr("* hard core 0\n"),
+			params:      map[string]any{"value": "65536"},
+			wantChanged: true,
+			want:        "* hard core 0\napp\tsoft\tnofile\t65536\n",
+		},
+		{
+			name:        "update value",
+			content:     ptr("# limits\napp soft nofile 1024\n"),
+			params:      map[string]any{"value": "65536", "comment": "raised"},
+			wantChanged: true,
+			want:        "# limits\napp\tsoft\tnofile\t65536\t# raised\n",
+		},
+		{
+			name:    "already set",
+			content: ptr("app   soft   nofile   65536\n"),
+			params:  map[string]any{"value": "65536"},
+		},
+		{
+			name:        "remove",
+			content:     ptr("app soft nofile 1024\n* hard core 0\n"),
+			params:      map[string]any{"state": "absent"},
+			wantChanged: true,
+			want:        "* hard core 0\n",
+		},
+		{
+			name:    "already absent",
+			content: ptr("# app soft nofile 1024\n"),
+			params:  map[string]any{"state": "absent"},
+		},
+		{
+			name:   "absent without file",
+			params: map[string]any{"state": "absent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			if tt.content == nil {
+				conn.On("test -f '"+defaultDest+"'").Fail(1, "")
+			} else {
+				conn.SetFile(defaultDest, []byte(*tt.content), 0o644)
+			}
+			conn.On("mktemp").Return(testTmp + "\n")
+			conn.Default(connector.Result{})
+
+			tt.params["domain"] = "app"
+			tt.params["limit_type"] = "soft"
+			tt.params["limit_item"] = "nofile"
+			result, err := (&Module{}).Run(context.Background(), conn, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed != tt.wantChanged {
+				t.Fatalf("Changed = %v, want %v (%s)", result.Changed, tt.wantChanged, result.Message)
+			}
+			written, ok := conn.File(testTmp)
+			if !tt.wantChanged {
+				if ok {
+					t.Errorf("unchanged limit wrote %q", written)
+				}
+				return
+			}
+			if string(written) != tt.want {
+				t.Errorf("written = %q, want %q", written, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	conn := connectortest.New()
+	conn.SetFile(defaultDest, []byte("app soft nofile 1024\n"), 0o644)
+	conn.Default(connector.Result{})
+
+	params := map[string]any{
+		"domain": "app", "limit_type": "soft", "limit_item": "nofile", "value": "65536",
+		module.CheckModeParam: true,
+	}
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	if conn.Executed("mktemp") {
+		t.Error("check mode wrote the file")
+	}
+}
+
+func TestRunInvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]any
+	}{
+		{"bad type", map[string]any{"limit_type": "medium", "value": "1"}},
+		{"bad item", map[string]any{"limit_item": "files", "value": "1"}},
+		{"bad value", map[string]any{"value": "lots"}},
+		{"no value", map[string]any{}},
+		{"bad state", map[string]any{"state": "latest", "value": "1"}},
+		{"domain with space", map[string]any{"domain": "a b", "value": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]any{"domain": "app", "limit_type": "soft", "limit_item": "nofile"}
+			maps.Copy(params, tt.params)
+			if _, err := (&Module{}).Run(context.Background(), connectortest.New(), params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,241 @@
+package params
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+// ModePreserve is the mode value that keeps the mode of the source file,
+// in the modules that support it (copy). Mode rejects it, so such modules
+// check for it first.
+const ModePreserve = "preserve"
+
+// Mode returns the file mode value of key, or def if it is missing. The
+// value is either an octal mode, padded to four digits ("644" is "0644")
+// as modules read it back from stat, or a symbolic mode such as
+// "u+x,g-w", returned as written. YAML reads an unquoted 0644 as the
+// number 420, so an integer is taken as the numeric mode and returned in
+// octal. An empty string, such as a templated mode that renders empty, is
+// returned as is and leaves the mode of the file unchanged.
+func Mode(params map[string]any, key, def string) (string, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		n, ok := toInt(v)
+		if !ok || n < 0 || n > 0o7777 {
+			return "", module.ParamErrorf(key, "parameter '%s' must be a mode like \"0644\" or \"u+x\", got %s", key, describe(v))
+		}
+		return fmt.Sprintf("%04o", n), nil
+	}
+
+	switch {
+	case s == "":
+		return "", nil
+	case s == ModePreserve:
+		return "", module.ParamErrorf(key, "parameter '%s' cannot be '%s' here: only copy keeps the mode of its source", key, s)
+	case isOctalMode(s):
+		if len(s) < 4 {
+			s = strings.Repeat("0", 4-len(s)) + s
+		}
+		return s, nil
+	}
+	if _, err := parseSymbolicMode(s); err != nil {
+		return "", module.ParamErrorf(key, "invalid mode '%s': %v", s, err)
+	}
+	return s, nil
+}
+
+// IsSymbolicMode reports whether mode, as returned by Mode, is a symbolic
+// mode rather than an octal one.
+func IsSymbolicMode(mode string) bool {
+	return mode != "" && !isOctalMode(mode)
+}
+
+// ExplicitMode returns mode with "a" added to the clauses of a symbolic
+// mode that name no classes, so chmod(1) applies them to all classes like
+// ResolveMode does, instead of leaving out the bits of the umask.
+func ExplicitMode(mode string) string {
+	if !IsSymbolicMode(mode) {
+		return mode
+	}
+	clauses := strings.Split(mode, ",")
+	for i, clause := range clauses {
+		if clause != "" && strings.IndexByte("+-=", clause[0]) >= 0 {
+			clauses[i] = "a" + clause
+		}
+	}
+	return strings.Join(clauses, ",")
+}
+
+// ResolveMode returns the octal mode a path with the octal mode current
+// gets from chmod with mode, as returned by Mode: mode itself if it is
+// octal, or mode applied to current if it is symbolic. An empty mode
+// leaves current as it is.
+func ResolveMode(mode, current string, dir bool) (string, error) {
+	if mode == "" {
+		return current, nil
+	}
+	if isOctalMode(mode) {
+		return mode, nil
+	}
+
+	actions, err := parseSymbolicMode(mode)
+	if err != nil {
+		return "", err
+	}
+	perm := uint64(0)
+	if current != "" {
+		if perm, err = strconv.ParseUint(current, 8, 32); err != nil {
+			return "", fmt.Errorf("invalid mode '%s'", current)
+		}
+	}
+	for _, a := range actions {
+		perm = a.apply(perm, dir)
+	}
+	return fmt.Sprintf("%04o", perm), nil
+}
+
+// ModeBits returns the permission bits a symbolic mode sets and clears on
+// a directory or another file, whatever its mode was. X counts as x for
+// directories only. For an octal mode, every bit is set or cleared.
+func ModeBits(mode string, dir bool) (set, clear uint32, err error) {
+	if isOctalMode(mode) {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return 0, 0, err
+		}
+		return uint32(perm), 0o7777 &^ uint32(perm), nil
+	}
+
+	actions, err := parseSymbolicMode(mode)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, a := range actions {
+		bits := uint32(a.bits(0, dir))
+		switch a.op {
+		case '+':
+			set, clear = set|bits, clear&^bits
+		case '-':
+			set, clear = set&^bits, clear|bits
+		case '=':
+			mask := uint32(a.mask())
+			set, clear = set&^mask|bits, clear|mask&^bits
+		}
+	}
+	return set, clear, nil
+}
+
+// isOctalMode reports whether s is an octal mode of one to four digits.
+func isOctalMode(s string) bool {
+	return s != "" && len(s) <= 4 && strings.Trim(s, "01234567") == ""
+}
+
+// modeAction is one operation of a symbolic mode, such as the +x of u+x.
+type modeAction struct {
+	// who is the classes the action applies to: u, g, and o.
+	who string
+
+	// op is '+', '-', or '='.
+	op byte
+
+	// perm is the permissions: r, w, x, X, s, and t.
+	perm string
+}
+
+// parseSymbolicMode parses a chmod(1) symbolic mode: comma-separated
+// clauses of classes (ugoa) and actions (+, -, or = with permissions
+// rwxXst), such as "u+x,go-w" or "a=rX". Without classes, an action
+// applies to all of them, regardless of the umask.
+func parseSymbolicMode(s string) ([]modeAction, error) {
+	var actions []modeAction
+	for _, clause := range strings.Split(s, ",") {
+		i := 0
+		for i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0 {
+			i++
+		}
+		who := clause[:i]
+		if who == "" || strings.Contains(who, "a") {
+			who = "ugo"
+		}
+		if i == len(clause) {
+			return nil, fmt.Errorf("clause '%s' has no +, -, or =", clause)
+		}
+
+		for i < len(clause) {
+			op := clause[i]
+			if op != '+' && op != '-' && op != '=' {
+				return nil, fmt.Errorf("unexpected '%c' in clause '%s'", op, clause)
+			}
+			i++
+			j := i
+			for j < len(clause) && strings.IndexByte("rwxXst", clause[j]) >= 0 {
+				j++
+			}
+			actions = append(actions, modeAction{who: who, op: op, perm: clause[i:j]})
+			i = j
+		}
+	}
+	return actions, nil
+}
+
+// mask returns the bits of the action's classes, special bits included.
+func (a modeAction) mask() uint64 {
+	var mask uint64
+	for _, c := range a.who {
+		switch c {
+		case 'u':
+			mask |= 0o4700
+		case 'g':
+			mask |= 0o2070
+		case 'o':
+			mask |= 0o1007
+		}
+	}
+	return mask
+}
+
+// bits returns the bits the action's permissions stand for in its
+// classes, for a path with mode perm.
+func (a modeAction) bits(perm uint64, dir bool) uint64 {
+	var bits uint64
+	for _, c := range a.perm {
+		switch c {
+		case 'r':
+			bits |= 0o444
+		case 'w':
+			bits |= 0o222
+		case 'x':
+			bits |= 0o111
+		case 'X':
+			// Execute for directories and files someone may execute
+			if dir || perm&0o111 != 0 {
+				bits |= 0o111
+			}
+		case 's':
+			bits |= 0o6000
+		case 't':
+			bits |= 0o1000
+		}
+	}
+	return bits & a.mask()
+}
+
+// apply returns perm with the action applied.
+func (a modeAction) apply(perm uint64, dir bool) uint64 {
+	bits := a.bits(perm, dir)
+	switch a.op {
+	case '+':
+		return perm | bits
+	case '-':
+		return perm &^ bits
+	}
+	return perm&^a.mask() | bits
+}
@@ -0,0 +1,251 @@
+// Package params extracts typed values from the parameters of a task.
+//
+// Parameters come from YAML or from templated strings, so a value often
+// has a different type than the module expects: "{{ enabled }}" renders
+// to the string "true", and "port: 8080" is an int. The getters coerce
+// between these representations:
+//
+//   - strings accept numbers and bools, formatted as text
+//   - bools accept "true"/"false", "yes"/"no", "on"/"off", and "1"/"0"
+//     (case-insensitive), and the ints 1 and 0
+//   - ints accept whole floats and numeric strings
+//
+// A value that cannot be coerced is treated like a missing one, so the
+// getters return their default. Validate reports such values as errors
+// instead, naming the parameter.
+//
+// Modules name their parameters map "params", so they import this package
+// as "param":
+//
+//	import param "github.com/eugenetaranov/bolt/internal/module/params"
+package params
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+// String returns the string value of key, or def if it is missing or not
+// a scalar.
+func String(params map[string]any, key, def string) string {
+	if s, ok := toString(params[key]); ok {
+		return s
+	}
+	return def
+}
+
+// LookupString returns the string value of key and whether it is set to
+// a scalar, for parameters whose absence means "leave unchanged".
+func LookupString(params map[string]any, key string) (string, bool) {
+	return toString(params[key])
+}
+
+// Required returns the string value of key, which must be set and not
+// empty.
+func Required(params map[string]any, key string) (string, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return "", module.ParamErrorf(key, "required parameter '%s' is missing", key)
+	}
+	s, ok := toString(v)
+	if !ok {
+		return "", module.ParamErrorf(key, "parameter '%s' must be a string", key)
+	}
+	if s == "" {
+		return "", module.ParamErrorf(key, "parameter '%s' cannot be empty", key)
+	}
+	return s, nil
+}
+
+// Bool returns the bool value of key, or def if it is missing or not a
+// bool.
+func Bool(params map[string]any, key string, def bool) bool {
+	if b, ok := LookupBool(params, key); ok {
+		return b
+	}
+	return def
+}
+
+// LookupBool returns the bool value of key and whether it is set to a
+// bool, for parameters whose absence means "leave unchanged".
+func LookupBool(params map[string]any, key string) (value, ok bool) {
+	return toBool(params[key])
+}
+
+// Int returns the int value of key, or def if it is missing or not a
+// whole number.
+func Int(params map[string]any, key string, def int) int {
+	if n, ok := toInt(params[key]); ok {
+		return n
+	}
+	return def
+}
+
+// StringList returns the list value of key. A single string is a list of
+// one item, so "name: nginx" and "name: [nginx]" are the same; empty items
+// are dropped.
+func StringList(params map[string]any, key string) []string {
+	switch v := params[key].(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		var list []string
+		for _, item := range v {
+			if s, ok := toString(item); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// Map returns the map value of key, or nil if it is missing or not a map.
+func Map(params map[string]any, key string) map[string]any {
+	m, _ := params[key].(map[string]any)
+	return m
+}
+
+// Validate checks the parameters described by specs (see
+// module.Describer) that are set in params, and reports the first one
+// whose value cannot be coerced to its type.
+func Validate(params map[string]any, specs []module.ParamSpec) error {
+	for _, spec := range specs {
+		v, ok := params[spec.Name]
+		if !ok || v == nil {
+			continue
+		}
+		if !valid(v, spec.Type) {
+			return module.ParamErrorf(spec.Name, "parameter '%s' must be %s, got %s", spec.Name, typeName(spec.Type), describe(v))
+		}
+	}
+	return nil
+}
+
+// valid reports whether v can be coerced to one of the "/"-separated
+// types of a ParamSpec.
+func valid(v any, types string) bool {
+	for _, t := range strings.Split(types, "/") {
+		var ok bool
+		switch t {
+		case "string":
+			_, ok = toString(v)
+		case "bool":
+			_, ok = toBool(v)
+		case "int":
+			_, ok = toInt(v)
+		case "list":
+			switch v.(type) {
+			case string, []string, []any:
+				ok = true
+			}
+		case "map":
+			_, ok = v.(map[string]any)
+		default:
+			// Types Validate does not know are not checked
+			ok = true
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName describes the types of a ParamSpec for error messages.
+func typeName(types string) string {
+	names := strings.Split(types, "/")
+	for i, t := range names {
+		switch t {
+		case "int":
+			names[i] = "an int"
+		case "list", "map", "bool", "string":
+			names[i] = "a " + t
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// describe names the type of v for error messages.
+func describe(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("'%s'", v)
+	case []any, []string:
+		return "a list"
+	case map[string]any:
+		return "a map"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// toString converts a scalar to its text.
+func toString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// toBool converts a bool, a bool word, or 1 and 0 to a bool.
+func toBool(v any) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "on", "1":
+			return true, true
+		case "false", "no", "off", "0":
+			return false, true
+		}
+	case int:
+		switch v {
+		case 1:
+			return true, true
+		case 0:
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// toInt converts a number or a numeric string to an int.
+func toInt(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,239 @@
+package params
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+func TestGetters(t *testing.T) {
+	params := map[string]any{
+		"name":    "nginx",
+		"version": 8,
+		"ratio":   1.5,
+		"enabled": "yes",
+		"force":   "Off",
+		"flag":    1,
+		"port":    "8080",
+		"count":   3.0,
+		"list":    []any{"a", "", 2},
+		"env":     map[string]any{"A": "1"},
+		"bad":     []any{"x"},
+	}
+
+	if got := String(params, "name", ""); got != "nginx" {
+		t.Errorf("String(name) = %q", got)
+	}
+	if got := String(params, "version", ""); got != "8" {
+		t.Errorf("String(version) = %q, want 8", got)
+	}
+	if got := String(params, "ratio", ""); got != "1.5" {
+		t.Errorf("String(ratio) = %q, want 1.5", got)
+	}
+	if got := String(params, "bad", "def"); got != "def" {
+		t.Errorf("String(bad) = %q, want the default", got)
+	}
+	if got, ok := LookupString(params, "version"); !ok || got != "8" {
+		t.Errorf("LookupString(version) = %q, %v, want 8", got, ok)
+	}
+	if _, ok := LookupString(params, "missing"); ok {
+		t.Errorf("LookupString(missing) reported a value")
+	}
+
+	if !Bool(params, "enabled", false) || Bool(params, "force", true) || !Bool(params, "flag", false) {
+		t.Errorf("Bool() did not coerce yes, Off, and 1")
+	}
+	if !Bool(params, "name", true) {
+		t.Errorf("Bool(name) = false, want the default")
+	}
+	if _, ok := LookupBool(params, "missing"); ok {
+		t.Errorf("LookupBool(missing) reported a value")
+	}
+
+	if got := Int(params, "port", 0); got != 8080 {
+		t.Errorf("Int(port) = %d, want 8080", got)
+	}
+	if got := Int(params, "count", 0); got != 3 {
+		t.Errorf("Int(count) = %d, want 3", got)
+	}
+	if got := Int(params, "ratio", 7); got != 7 {
+		t.Errorf("Int(ratio) = %d, want the default", got)
+	}
+
+	if got := StringList(params, "list"); !reflect.DeepEqual(got, []string{"a", "2"}) {
+		t.Errorf("StringList(list) = %q", got)
+	}
+	if got := StringList(params, "name"); !reflect.DeepEqual(got, []string{"nginx"}) {
+		t.Errorf("StringList(name) = %q", got)
+	}
+	if got := Map(params, "env"); got["A"] != "1" {
+		t.Errorf("Map(env) = %v", got)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	params := map[string]any{"dest": "/etc/motd", "empty": "", "list": []any{}}
+	if got, err := Required(params, "dest"); err != nil || got != "/etc/motd" {
+		t.Errorf("Required(dest) = %q, %v", got, err)
+	}
+	for key, want := range map[string]string{
+		"missing": "required parameter 'missing' is missing",
+		"empty":   "parameter 'empty' cannot be empty",
+		"list":    "parameter 'list' must be a string",
+	} {
+		_, err := Required(params, key)
+		var perr *module.ParamError
+		if !errors.As(err, &perr) || perr.Param != key || err.Error() != want {
+			t.Errorf("Required(%s) error = %v, want %q", key, err, want)
+		}
+	}
+}
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr string
+	}{
+		{name: "default", value: nil, want: "0644"},
+		{name: "empty", value: "", want: ""},
+		{name: "padded", value: "644", want: "0644"},
+		{name: "special bits", value: "2755", want: "2755"},
+		{name: "symbolic", value: "u+x,g-w", want: "u+x,g-w"},
+		{name: "symbolic without classes", value: "+X", want: "+X"},
+		// YAML reads an unquoted 0644 as 420
+		{name: "int", value: 420, want: "0644"},
+		{name: "sticky int", value: 0o1777, want: "1777"},
+		{name: "int out of range", value: 0o10000, wantErr: `like "0644"`},
+		{name: "float", value: 6.5, wantErr: `like "0644"`},
+		{name: "preserve", value: "preserve", wantErr: "only copy"},
+		{name: "too many digits", value: "06440", wantErr: "invalid mode"},
+		{name: "unknown permission", value: "u+q", wantErr: "unexpected 'q'"},
+		{name: "no operator", value: "ug", wantErr: "no +, -, or ="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]any{}
+			if tt.value != nil {
+				params["mode"] = tt.value
+			}
+			got, err := Mode(params, "mode", "0644")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Mode(%v) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Mode(%v) = %q, %v, want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		current string
+		dir     bool
+		want    string
+	}{
+		{"0600", "0755", false, "0600"},
+		{"", "0755", false, "0755"},
+		{"u+x", "0644", false, "0744"},
+		{"u+x", "0744", false, "0744"},
+		{"g-w,o-rwx", "0775", false, "0750"},
+		{"go=rx", "0700", false, "0755"},
+		{"u=rw,g=r,o=", "0777", false, "0640"},
+		{"+x", "0600", false, "0711"},
+		{"a+X", "0644", false, "0644"},
+		{"a+X", "0744", false, "0755"},
+		{"a+X", "0644", true, "0755"},
+		{"g+s", "0755", true, "2755"},
+		{"+t", "0777", true, "1777"},
+		{"u+x-w", "0644", false, "0544"},
+		{"u+x", "", false, "0100"},
+	}
+
+	for _, tt := range tests {
+		got, err := ResolveMode(tt.mode, tt.current, tt.dir)
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveMode(%q, %q, %v) = %q, %v, want %q", tt.mode, tt.current, tt.dir, got, err, tt.want)
+		}
+	}
+}
+
+func TestExplicitMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"0644":     "0644",
+		"u+x":      "u+x",
+		"+x":       "a+x",
+		"=r,u+w":   "a=r,u+w",
+		"go-w,+X":  "go-w,a+X",
+		"preserve": "preserve",
+	} {
+		if got := ExplicitMode(mode); got != want {
+			t.Errorf("ExplicitMode(%q) = %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestModeBits(t *testing.T) {
+	tests := []struct {
+		mode      string
+		dir       bool
+		wantSet   uint32
+		wantClear uint32
+	}{
+		{"0644", false, 0o644, 0o7133},
+		{"u+x", false, 0o100, 0},
+		{"go-w", false, 0, 0o022},
+		{"u+x,u-x", false, 0, 0o100},
+		{"go=r", false, 0o044, 0o3033},
+		{"a+X", true, 0o111, 0},
+		{"a+X", false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		set, clear, err := ModeBits(tt.mode, tt.dir)
+		if err != nil || set != tt.wantSet || clear != tt.wantClear {
+			t.Errorf("ModeBits(%q, %v) = %04o, %04o, %v; want %04o, %04o", tt.mode, tt.dir, set, clear, err, tt.wantSet, tt.wantClear)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	specs := []module.ParamSpec{
+		{Name: "name", Type: "string/list"},
+		{Name: "update_cache", Type: "bool"},
+		{Name: "cache_valid_time", Type: "int"},
+		{Name: "env", Type: "map"},
+	}
+
+	valid := map[string]any{"name": []any{"curl"}, "update_cache": "yes", "cache_valid_time": "3600", "env": map[string]any{}}
+	if err := Validate(valid, specs); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		params map[string]any
+		want   string
+	}{
+		{map[string]any{"update_cache": "sometimes"}, "parameter 'update_cache' must be a bool, got 'sometimes'"},
+		{map[string]any{"cache_valid_time": "1h"}, "parameter 'cache_valid_time' must be an int, got '1h'"},
+		{map[string]any{"name": map[string]any{}}, "parameter 'name' must be a string or a list, got a map"},
+		{map[string]any{"env": "A=1"}, "parameter 'env' must be a map, got 'A=1'"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.params, specs)
+		var perr *module.ParamError
+		if !errors.As(err, &perr) || err.Error() != tt.want {
+			t.Errorf("Validate(%v) error = %v, want %q", tt.params, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Package pkgng provides a module for managing packages on FreeBSD systems.
+package pkgng
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired package state.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure package is installed
+	StateAbsent  State = "absent"  // Ensure package is not installed
+	StateLatest  State = "latest"  // Ensure package is installed and up-to-date
+)
+
+// Module manages packages with pkg(8) on FreeBSD.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "pkgng"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string/list", Description: "Package name(s)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent", "latest"}, Description: "Desired state"},
+		{Name: "update_cache", Type: "bool", Default: false, Description: "Run `pkg update` first"},
+		{Name: "autoremove", Type: "bool", Default: false, Description: "Remove orphaned dependencies"},
+	}
+}
+
+// Run executes the pkgng module.
+//
+// Parameters:
+//   - name (string|[]string): Package name(s) to manage
+//   - state (string): Desired state - present, absent, latest (default: present)
+//   - update_cache (bool): Run pkg update before operations (default: false)
+//   - autoremove (bool): Remove orphaned dependency packages (default: false)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	// Check if pkg is available
+	if err := checkPkg(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	updateCache := param.Bool(params, "update_cache", false)
+	autoremove := param.Bool(params, "autoremove", false)
+	offline := module.IsOffline(params)
+
+	// Validate state
+	switch state {
+	case StatePresent, StateAbsent, StateLatest:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present, absent, or latest", state)
+	}
+
+	var changed bool
+	var messages []string
+
+	// Offline, pkg must not refresh the catalogue before installing either
+	if offline {
+		conn = connector.WithEnv(conn, map[string]string{"REPO_AUTOUPDATE": "false"})
+	}
+
+	// Update repository catalogue if requested
+	if updateCache && offline {
+		messages = append(messages, "catalogue update skipped (offline)")
+	} else if updateCache {
+		if err := runPkgUpdate(ctx, conn); err != nil {
+			return nil, fmt.Errorf("failed to update catalogue: %w", err)
+		}
+		messages = append(messages, "catalogue updated")
+		changed = true
+	}
+
+	names := param.StringList(params, "name")
+	if len(names) == 0 {
+		if !updateCache && !autoremove {
+			return nil, module.ParamErrorf("name", "'name' parameter is required when not using update_cache or autoremove")
+		}
+		if autoremove {
+			removed, err := runAutoremove(ctx, conn)
+			if err != nil {
+				return nil, err
+			}
+			if removed {
+				messages = append(messages, "autoremove completed")
+				changed = true
+			}
+		}
+		if changed {
+			return module.Changed(strings.Join(messages, ", ")), nil
+		}
+		if len(messages) > 0 {
+			return module.Unchanged(strings.Join(messages, ", ")), nil
+		}
+		return module.Unchanged("no changes needed"), nil
+	}
+
+	// Get currently installed packages
+	installed, err := getInstalledPackages(ctx, conn, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installed packages: %w", err)
+	}
+
+	var toInstall, toRemove, toUpgrade []string
+
+	for _, name := range names {
+		isInstalled := installed[name]
+
+		switch state {
+		case StatePresent:
+			if !isInstalled {
+				toInstall = append(toInstall, name)
+			}
+		case StateAbsent:
+			if isInstalled {
+				toRemove = append(toRemove, name)
+			}
+		case StateLatest:
+			if !isInstalled {
+				toInstall = append(toInstall, name)
+			} else {
+				toUpgrade = append(toUpgrade, name)
+			}
+		}
+	}
+
+	// Install packages
+	if len(toInstall) > 0 {
+		if err := runPkg(ctx, conn, "install", toInstall); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("installed: %s", strings.Join(toInstall, ", ")))
+		changed = true
+	}
+
+	// Remove packages
+	if len(toRemove) > 0 {
+		if err := runPkg(ctx, conn, "delete", toRemove); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("removed: %s", strings.Join(toRemove, ", ")))
+		changed = true
+	}
+
+	// Upgrade packages that have newer versions available
+	if len(toUpgrade) > 0 {
+		outdated, err := getOutdatedPackages(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+
+		var upgradable []string
+		for _, name := range toUpgrade {
+			if outdated[name] {
+				upgradable = append(upgradable, name)
+			}
+		}
+
+		if len(upgradable) > 0 {
+			if err := runPkg(ctx, conn, "upgrade", upgradable); err != nil {
+				return nil, err
+			}
+			messages = append(messages, fmt.Sprintf("upgraded: %s", strings.Join(upgradable, ", ")))
+			changed = true
+		}
+	}
+
+	// Handle autoremove
+	if autoremove {
+		removed, err := runAutoremove(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if removed {
+			messages = append(messages, "autoremove completed")
+			changed = true
+		}
+	}
+
+	if !changed {
+		return module.Unchanged("packages already in desired state"), nil
+	}
+
+	return module.Changed(strings.Join(messages, "; ")), nil
+}
+
+// checkPkg verifies that pkg is available.
+func checkPkg(ctx context.Context, conn connector.Connector) error {
+	result, err := conn.Execute(ctx, "command -v pkg")
+	if err != nil {
+		return fmt.Errorf("failed to check for pkg: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("pkg is not available (not a FreeBSD system?)")
+	}
+	return nil
+}
+
+// runPkgUpdate refreshes the repository catalogue.
+func runPkgUpdate(ctx context.Context, conn connector.Connector) error {
+	result, err := module.PkgMutex.Execute(ctx, conn, "ASSUME_ALWAYS_YES=yes pkg update -q")
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "pkg update failed")
+	}
+	return nil
+}
+
+// getInstalledPackages returns which of the given packages are installed.
+func getInstalledPackages(ctx context.Context, conn connector.Connector, names []string) (map[string]bool, error) {
+	// pkg query prints the names of installed packages and exits non-zero
+	// if any of them is missing, so ignore the exit code
+	cmd := fmt.Sprintf("pkg query '%%n' %s 2>/dev/null || true", shellutil.Join(names...))
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := make(map[string]bool)
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		name := strings.TrimSpace(line)
+		if name != "" {
+			installed[name] = true
+		}
+	}
+
+	return installed, nil
+}
+
+// getOutdatedPackages returns the installed packages with newer remote versions.
+func getOutdatedPackages(ctx context.Context, conn connector.Connector) (map[string]bool, error) {
+	result, err := conn.Execute(ctx, "pkg version -qRl '<' 2>/dev/null || true")
+	if err != nil {
+		return nil, err
+	}
+
+	outdated := make(map[string]bool)
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		// Format: name-version
+		line = strings.TrimSpace(line)
+		if idx := strings.LastIndex(line, "-"); idx > 0 {
+			outdated[line[:idx]] = true
+		}
+	}
+
+	return outdated, nil
+}
+
+// runPkg runs a pkg subcommand non-interactively for the given packages.
+func runPkg(ctx context.Context, conn connector.Connector, action string, names []string) error {
+	cmd := fmt.Sprintf("pkg %s -y %s", action, shellutil.Join(names...))
+
+	result, err := module.PkgMutex.Execute(ctx, conn, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to %s packages: %w", action, err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "pkg %s failed", action)
+	}
+
+	return nil
+}
+
+// runAutoremove removes orphaned dependency packages.
+func runAutoremove(ctx context.Context, conn connector.Connector) (bool, error) {
+	result, err := module.PkgMutex.Execute(ctx, conn, "pkg autoremove -y")
+	if err != nil {
+		return false, fmt.Errorf("failed to autoremove: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, module.CommandFailedf(result, "pkg autoremove failed")
+	}
+
+	return strings.Contains(result.Stdout, "Deinstalling"), nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -0,0 +1,176 @@
+// Package replace provides a module for replacing text in files with
+// regular expressions.
+package replace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module replaces all matches of a regular expression in a file on the
+// target.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "replace"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	params := []module.ParamSpec{
+		{Name: "path", Type: "string", Required: true, Description: "File to edit"},
+		{Name: "regexp", Type: "string", Required: true, Description: "Regular expression to replace (Go RE2 syntax)"},
+		{Name: "replace", Type: "string", Default: "", Description: "Replacement text; `$1` and `${name}` refer to groups"},
+		{Name: "after", Type: "string", Description: "Only replace after the first match of this expression"},
+		{Name: "before", Type: "string", Description: "Only replace before the first match of this expression"},
+		{Name: "backup", Type: "bool", Default: false, Description: "Create backup before changing the file"},
+		{Name: "validate", Type: "string", Description: "Validation command (`%s` = temp path)"},
+	}
+	return append(params, module.BackupParamSpecs()...)
+}
+
+// Run executes the replace module.
+//
+// Parameters:
+//   - path (string, required): File to edit on the target
+//   - regexp (string, required): Regular expression to replace; ^ and $ match
+//     at line boundaries, and (?s) lets . match newlines
+//   - replace (string): Replacement text, with $1 or ${name} for groups (default: "")
+//   - after (string): Only replace after the first match of this expression
+//   - before (string): Only replace before the first match of this expression
+//   - backup (bool): Create backup before changing the file (default: false)
+//   - backup_dir (string): Directory to write backups to (default: next to path)
+//   - backup_keep (int): Number of backups to keep, 0 for all (default: 0)
+//   - validate (string): Command to validate the new content before it is
+//     written (%s = temp file path)
+//
+// The file is rewritten in place, so it keeps its owner, mode, and other
+// attributes.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	path, err := param.Required(params, "path")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := param.Required(params, "regexp")
+	if err != nil {
+		return nil, err
+	}
+	replacement := param.String(params, "replace", "")
+	backup := param.Bool(params, "backup", false)
+	backupOpts, err := module.BackupParams(params)
+	if err != nil {
+		return nil, err
+	}
+	validate := param.String(params, "validate", "")
+	check := module.IsCheckMode(params)
+
+	re, err := compile("regexp", pattern)
+	if err != nil {
+		return nil, err
+	}
+	var after, before *regexp.Regexp
+	if s := param.String(params, "after", ""); s != "" {
+		if after, err = compile("after", s); err != nil {
+			return nil, err
+		}
+	}
+	if s := param.String(params, "before", ""); s != "" {
+		if before, err = compile("before", s); err != nil {
+			return nil, err
+		}
+	}
+
+	content, exists, err := module.ReadFile(ctx, conn, path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("file not found: %s", path)
+	}
+
+	start, end := section(content, after, before)
+	matches := len(re.FindAllIndex(content[start:end], -1))
+	if matches == 0 {
+		return module.Unchanged("no matches"), nil
+	}
+	replaced := re.ReplaceAll(content[start:end], []byte(replacement))
+	newContent := make([]byte, 0, start+len(replaced)+len(content)-end)
+	newContent = append(newContent, content[:start]...)
+	newContent = append(newContent, replaced...)
+	newContent = append(newContent, content[end:]...)
+	if bytes.Equal(newContent, content) {
+		return module.Unchanged("file already in desired state"), nil
+	}
+
+	data := map[string]any{
+		"path":    path,
+		"matches": matches,
+	}
+	msg := fmt.Sprintf("%d replacement(s) in %s", matches, path)
+	if check {
+		return module.ChangedWithData("would make "+msg, data), nil
+	}
+
+	if backup {
+		backupFile, err := module.Backup(ctx, conn, path, backupOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create backup: %w", err)
+		}
+		data["backup_file"] = backupFile
+	}
+
+	if err := module.WriteFile(ctx, conn, path, newContent, validate); err != nil {
+		return nil, err
+	}
+	return module.ChangedWithData(msg, data), nil
+}
+
+// compile compiles the expression of param. ^ and $ match at the start and
+// end of lines.
+func compile(param, expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?m)" + expr)
+	if err != nil {
+		return nil, module.ParamErrorf(param, "invalid regular expression in '%s': %v", param, err)
+	}
+	return re, nil
+}
+
+// section returns the range of content to replace in: from the end of the
+// first match of after to the start of the first match of before that
+// follows it. An expression that does not match leaves that side open.
+func section(content []byte, after, before *regexp.Regexp) (int, int) {
+	start, end := 0, len(content)
+	if after != nil {
+		if loc := after.FindIndex(content); loc != nil {
+			start = loc[1]
+		}
+	}
+	if before != nil {
+		if loc := before.FindIndex(content[start:]); loc != nil {
+			end = start + loc[0]
+		}
+	}
+	return start, end
+}
+
+// SupportsCheckMode reports that replace can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
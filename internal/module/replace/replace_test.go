@@ -0,0 +1,152 @@
+package replace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const (
+	testFile = "/etc/app.conf"
+	testTmp  = "/tmp/tmp.replace"
+
+	// writeCmd copies the uploaded temp file over testFile.
+	writeCmd = "mkdir -p '/etc' && cat '" + testTmp + "' > '" + testFile + "'"
+)
+
+// newConn returns a fake target with testFile holding content.
+func newConn(content string) *connectortest.Connector {
+	conn := connectortest.New()
+	conn.SetFile(testFile, []byte(content), 0o644)
+	conn.On("mktemp").Return(testTmp + "\n")
+	conn.Default(connector.Result{})
+	return conn
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		params  map[string]any
+		want    string
+		matches int
+	}{
+		{
+			name:    "all matches",
+			content: "host=a\nport=1\nhost=b\n",
+			params:  map[string]any{"regexp": `^host=.*$`, "replace": "host=c"},
+			want:    "host=c\nport=1\nhost=c\n",
+			matches: 2,
+		},
+		{
+			name:    "groups",
+			content: "listen 80;\n",
+			params:  map[string]any{"regexp": `listen (\d+);`, "replace": "listen 127.0.0.1:$1;"},
+			want:    "listen 127.0.0.1:80;\n",
+			matches: 1,
+		},
+		{
+			name:    "after and before",
+			content: "x=1\n[main]\nx=1\n[other]\nx=1\n",
+			params:  map[string]any{"regexp": `x=1`, "replace": "x=2", "after": `\[main\]`, "before": `\[other\]`},
+			want:    "x=1\n[main]\nx=2\n[other]\nx=1\n",
+			matches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConn(tt.content)
+			tt.params["path"] = testFile
+
+			result, err := (&Module{}).Run(context.Background(), conn, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Changed {
+				t.Fatalf("Changed = false, want true (%s)", result.Message)
+			}
+			if got := result.Data["matches"]; got != tt.matches {
+				t.Errorf("matches = %v, want %d", got, tt.matches)
+			}
+			written, _ := conn.File(testTmp)
+			if string(written) != tt.want {
+				t.Errorf("written = %q, want %q", written, tt.want)
+			}
+			if !conn.Executed(writeCmd) {
+				t.Error("temp file was not copied over the file")
+			}
+		})
+	}
+}
+
+func TestRunUnchanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]any
+	}{
+		{"no matches", map[string]any{"regexp": `^port=`, "replace": "port=2"}},
+		{"already replaced", map[string]any{"regexp": `^host=.*$`, "replace": "host=a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConn("host=a\n")
+			tt.params["path"] = testFile
+
+			result, err := (&Module{}).Run(context.Background(), conn, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed {
+				t.Errorf("Changed = true, want false (%s)", result.Message)
+			}
+			if conn.Executed("mktemp") {
+				t.Error("file was written")
+			}
+		})
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	conn := newConn("host=a\n")
+	params := map[string]any{"path": testFile, "regexp": "a", "replace": "b", module.CheckModeParam: true}
+
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	if conn.Executed("mktemp") {
+		t.Error("check mode wrote the file")
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	conn := newConn("host=a\n")
+	conn.On("check '"+testTmp+"'").Fail(1, "bad host\n")
+	params := map[string]any{"path": testFile, "regexp": "a", "replace": "b", "validate": "check %s"}
+
+	if _, err := (&Module{}).Run(context.Background(), conn, params); err == nil {
+		t.Fatal("Run() succeeded, want a validation error")
+	}
+	if conn.Executed(writeCmd) {
+		t.Error("file was written after validation failed")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunMissingFile(t *testing.T) {
+	conn := connectortest.New()
+	conn.On("test -f '/etc/missing.conf'").Fail(1, "")
+	params := map[string]any{"path": "/etc/missing.conf", "regexp": "a"}
+
+	if _, err := (&Module{}).Run(context.Background(), conn, params); err == nil {
+		t.Error("Run() succeeded, want an error for a missing file")
+	}
+}
@@ -0,0 +1,321 @@
+// Package rustup provides a module for managing Rust toolchains with rustup.
+package rustup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// DefaultInstallURL is the URL of the official rustup installer.
+const DefaultInstallURL = "https://sh.rustup.rs"
+
+// Module installs rustup and Rust toolchains with their components and
+// targets.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "rustup"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "toolchain", Type: "string/list", Description: "Toolchain(s), e.g. stable, 1.78.0, nightly-2024-05-01"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state of the toolchains"},
+		{Name: "default", Type: "string", Description: "Default toolchain (default: the first toolchain)"},
+		{Name: "components", Type: "list", Description: "Components to add to every toolchain, e.g. clippy, rustfmt"},
+		{Name: "targets", Type: "list", Description: "Compilation targets to add to every toolchain"},
+		{Name: "profile", Type: "string", Default: "minimal", Choices: []string{"minimal", "default", "complete"}, Description: "Profile of newly installed toolchains"},
+		{Name: "install_url", Type: "string", Default: DefaultInstallURL, Description: "URL of the rustup installer"},
+	}
+}
+
+// Run executes the rustup module.
+//
+// rustup is installed for the connecting user if missing, into ~/.cargo,
+// without changing the user's profile; run the task without become.
+//
+// Parameters:
+//   - toolchain (string|[]string): Toolchain(s) to manage
+//   - state (string): Desired state - present, absent (default: present)
+//   - default (string): Default toolchain (default: the first toolchain, for state=present)
+//   - components ([]string): Components to add to every toolchain
+//   - targets ([]string): Compilation targets to add to every toolchain
+//   - profile (string): Profile of newly installed toolchains (default: minimal)
+//   - install_url (string): URL of the rustup installer (default: https://sh.rustup.rs)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	toolchains := param.StringList(params, "toolchain")
+	state := param.String(params, "state", "present")
+	defaultToolchain := param.String(params, "default", "")
+	components := param.StringList(params, "components")
+	targets := param.StringList(params, "targets")
+	profile := param.String(params, "profile", "minimal")
+	installURL := param.String(params, "install_url", DefaultInstallURL)
+	check := module.IsCheckMode(params)
+	offline := module.IsOffline(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	switch profile {
+	case "minimal", "default", "complete":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("profile", "invalid profile '%s': must be minimal, default, or complete", profile)
+	}
+	if len(toolchains) == 0 && defaultToolchain == "" {
+		return nil, module.ParamErrorf("toolchain", "'toolchain' parameter is required")
+	}
+	if state == "absent" && (defaultToolchain != "" || len(components) > 0 || len(targets) > 0) {
+		return nil, fmt.Errorf("default, components, and targets cannot be used with state=absent")
+	}
+	if state == "present" && defaultToolchain == "" {
+		defaultToolchain = toolchains[0]
+	}
+
+	rustup, err := findRustup(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if rustup == "" {
+		if state == "absent" {
+			return module.Unchanged("rustup is not installed"), nil
+		}
+		msg := fmt.Sprintf("install rustup and toolchain(s) %s", strings.Join(toolchainsWith(toolchains, defaultToolchain), ", "))
+		if check {
+			return module.Changed("would " + msg), nil
+		}
+		if offline {
+			return nil, module.OfflineErrorf("installing rustup", "install rustup from a local mirror first")
+		}
+		cmd := fmt.Sprintf("curl --proto '=https' --tlsv1.2 -sSf %s | sh -s -- -y --no-modify-path --default-toolchain none --profile %s",
+			shellutil.Quote(installURL), profile)
+		if err := module.RunCommand(ctx, conn, cmd); err != nil {
+			return nil, fmt.Errorf("failed to install rustup: %w", err)
+		}
+		if rustup, err = findRustup(ctx, conn); err != nil {
+			return nil, err
+		}
+		if rustup == "" {
+			return nil, fmt.Errorf("rustup was installed, but was not found on the PATH or in ~/.cargo/bin")
+		}
+	}
+
+	r := &runner{conn: conn, rustup: rustup}
+	if r.host, err = r.defaultHost(ctx); err != nil {
+		return nil, err
+	}
+	installed, err := r.list(ctx, "toolchain", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []change
+	if state == "absent" {
+		for _, t := range toolchains {
+			if r.has(installed, t) {
+				plan = append(plan, change{"remove toolchain " + t, []string{"toolchain", "uninstall", t}, false})
+			}
+		}
+		return r.apply(ctx, plan, check, offline)
+	}
+
+	for _, t := range toolchainsWith(toolchains, defaultToolchain) {
+		missingComponents, missingTargets := components, targets
+		if !r.has(installed, t) {
+			plan = append(plan, change{"install toolchain " + t, []string{"toolchain", "install", t, "--profile", profile}, true})
+		} else {
+			if missingComponents, err = r.missing(ctx, t, "component", components); err != nil {
+				return nil, err
+			}
+			if missingTargets, err = r.missing(ctx, t, "target", targets); err != nil {
+				return nil, err
+			}
+		}
+		if len(missingComponents) > 0 {
+			what := fmt.Sprintf("add %s to %s", strings.Join(missingComponents, ", "), t)
+			plan = append(plan, change{what, append([]string{"component", "add", "--toolchain", t}, missingComponents...), true})
+		}
+		if len(missingTargets) > 0 {
+			what := fmt.Sprintf("add target %s to %s", strings.Join(missingTargets, ", "), t)
+			plan = append(plan, change{what, append([]string{"target", "add", "--toolchain", t}, missingTargets...), true})
+		}
+	}
+
+	current, err := r.list(ctx, "default")
+	if err != nil {
+		return nil, err
+	}
+	if !r.has(current, defaultToolchain) {
+		plan = append(plan, change{"set the default toolchain to " + defaultToolchain, []string{"default", defaultToolchain}, false})
+	}
+
+	return r.apply(ctx, plan, check, offline)
+}
+
+// toolchainsWith returns toolchains with t added if it is not among them.
+func toolchainsWith(toolchains []string, t string) []string {
+	if slices.Contains(toolchains, t) {
+		return toolchains
+	}
+	return append(append([]string{}, toolchains...), t)
+}
+
+// findRustup returns the path of rustup on the target, on the PATH or in
+// ~/.cargo/bin, where the installer puts it, or "" if it is not installed.
+func findRustup(ctx context.Context, conn connector.Connector) (string, error) {
+	result, err := conn.Execute(ctx, `command -v rustup || { test -x "$HOME/.cargo/bin/rustup" && echo "$HOME/.cargo/bin/rustup"; }`)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for rustup: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", nil
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// runner runs rustup on a target.
+type runner struct {
+	conn   connector.Connector
+	rustup string
+
+	// host is the target triple of the host, which rustup appends to the
+	// names of toolchains and components.
+	host string
+}
+
+// list runs rustup with args and returns the first word of every line of
+// its output: the names of toolchains, components, or targets.
+func (r *runner) list(ctx context.Context, args ...string) ([]string, error) {
+	result, err := r.conn.Execute(ctx, r.command(args))
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rustup: %w", err)
+	}
+	if result.ExitCode != 0 {
+		// rustup default fails when there is no default toolchain yet
+		if len(args) == 1 && args[0] == "default" {
+			return nil, nil
+		}
+		return nil, module.CommandFailedf(result, "rustup %s failed", strings.Join(args, " "))
+	}
+
+	var names []string
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names, nil
+}
+
+// defaultHost returns the target triple of the host.
+func (r *runner) defaultHost(ctx context.Context) (string, error) {
+	result, err := r.conn.Execute(ctx, r.command([]string{"show"}))
+	if err != nil {
+		return "", fmt.Errorf("failed to run rustup: %w", err)
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if host, ok := strings.CutPrefix(strings.TrimSpace(line), "Default host:"); ok {
+			return strings.TrimSpace(host), nil
+		}
+	}
+	return "", module.CommandFailedf(result, "rustup show did not report the default host")
+}
+
+// has reports whether names, as listed by rustup, include name, which
+// rustup lists with the host's target triple appended (stable is listed
+// as stable-x86_64-unknown-linux-gnu).
+func (r *runner) has(names []string, name string) bool {
+	for _, n := range names {
+		if n == name || n == name+"-"+r.host {
+			return true
+		}
+	}
+	return false
+}
+
+// missing returns the components or targets (kind) of wanted that are
+// not installed for toolchain.
+func (r *runner) missing(ctx context.Context, toolchain, kind string, wanted []string) ([]string, error) {
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+	installed, err := r.list(ctx, kind, "list", "--installed", "--toolchain", toolchain)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, name := range wanted {
+		if !r.has(installed, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// change is a rustup command that changes the target.
+type change struct {
+	// what describes the change, e.g. "install toolchain stable".
+	what string
+	args []string
+
+	// download is true if the command downloads from the network.
+	download bool
+}
+
+// apply makes the changes of plan.
+func (r *runner) apply(ctx context.Context, plan []change, check, offline bool) (*module.Result, error) {
+	if len(plan) == 0 {
+		return module.Unchanged("toolchains already in desired state"), nil
+	}
+	var messages []string
+	for _, c := range plan {
+		messages = append(messages, c.what)
+	}
+	msg := strings.Join(messages, "; ")
+	if check {
+		return module.Changed("would " + msg), nil
+	}
+
+	for _, c := range plan {
+		if offline && c.download {
+			return nil, module.OfflineErrorf("rustup "+strings.Join(c.args, " "), "run the task once with network access")
+		}
+		if err := module.RunCommand(ctx, r.conn, r.command(c.args)); err != nil {
+			return nil, err
+		}
+	}
+	return module.Changed("ran rustup to " + msg), nil
+}
+
+// command returns the rustup command line for args.
+func (r *runner) command(args []string) string {
+	return shellutil.Join(append([]string{r.rustup}, args...)...)
+}
+
+// SupportsCheckMode reports that rustup can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,155 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// SELinuxContext is a desired SELinux security context. Empty fields are
+// left as they are.
+type SELinuxContext struct {
+	User  string // seuser (e.g., system_u)
+	Role  string // serole (e.g., object_r)
+	Type  string // setype (e.g., httpd_sys_content_t)
+	Level string // selevel (e.g., s0)
+}
+
+// SELinuxParams reads the seuser, serole, setype, and selevel parameters.
+func SELinuxParams(params map[string]any) SELinuxContext {
+	get := func(key string) string {
+		s, _ := params[key].(string)
+		return s
+	}
+	return SELinuxContext{
+		User:  get("seuser"),
+		Role:  get("serole"),
+		Type:  get("setype"),
+		Level: get("selevel"),
+	}
+}
+
+// IsZero reports whether no field of the context is set.
+func (c SELinuxContext) IsZero() bool {
+	return c == SELinuxContext{}
+}
+
+// String returns the context in user:role:type:level form.
+func (c SELinuxContext) String() string {
+	return strings.Join([]string{c.User, c.Role, c.Type, c.Level}, ":")
+}
+
+// parseSELinuxContext parses a user:role:type:level label. The level may
+// itself contain colons (e.g., s0:c0.c1023).
+func parseSELinuxContext(label string) SELinuxContext {
+	parts := strings.SplitN(strings.TrimSpace(label), ":", 4)
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+	return SELinuxContext{User: parts[0], Role: parts[1], Type: parts[2], Level: parts[3]}
+}
+
+// EnsureSELinuxContext sets the SELinux context of path. A file that was
+// just created first gets the default context of its location from the
+// policy (restorecon), so it does not keep the label of wherever it was
+// staged; fields set in want are then applied on top. Targets without
+// SELinux enabled are left alone. With check set it only reports whether
+// the context differs.
+func EnsureSELinuxContext(ctx context.Context, conn connector.Connector, path string, want SELinuxContext, created, recurse, check bool) (bool, error) {
+	result, err := conn.Execute(ctx, "command -v selinuxenabled >/dev/null 2>&1 && selinuxenabled")
+	if err != nil {
+		return false, err
+	}
+	if result.ExitCode != 0 {
+		return false, nil
+	}
+
+	quoted := shellutil.Quote(path)
+	flags := ""
+	if recurse {
+		flags = "-R "
+	}
+
+	if created && !check {
+		result, err := conn.Execute(ctx, fmt.Sprintf("command -v restorecon >/dev/null 2>&1 && restorecon %s%s || true", flags, quoted))
+		if err != nil {
+			return false, err
+		}
+		if result.ExitCode != 0 {
+			return false, CommandFailedf(result, "restorecon failed")
+		}
+	}
+	if want.IsZero() {
+		return false, nil
+	}
+
+	result, err = conn.Execute(ctx, fmt.Sprintf("stat -c %%C %s", quoted))
+	if err != nil {
+		return false, err
+	}
+	if result.ExitCode != 0 {
+		return false, CommandFailedf(result, "failed to read SELinux context")
+	}
+	current := parseSELinuxContext(result.Stdout)
+
+	var args []string
+	for _, f := range []struct{ flag, want, current string }{
+		{"-u", want.User, current.User},
+		{"-r", want.Role, current.Role},
+		{"-t", want.Type, current.Type},
+		{"-l", want.Level, current.Level},
+	} {
+		if f.want != "" && f.want != f.current {
+			args = append(args, f.flag, shellutil.Quote(f.want))
+		}
+	}
+	if len(args) == 0 {
+		return false, nil
+	}
+	if check {
+		return true, nil
+	}
+
+	result, err = conn.Execute(ctx, fmt.Sprintf("chcon %s%s %s", flags, strings.Join(args, " "), quoted))
+	if err != nil {
+		return false, err
+	}
+	if result.ExitCode != 0 {
+		return false, CommandFailedf(result, "chcon failed")
+	}
+	return true, nil
+}
+
+// SaveXattrs returns a dump of the extended attributes of path, including
+// its SELinux label, to be restored with RestoreXattrs once the file has
+// been replaced. It returns an empty dump if path does not exist or the
+// target has no getfattr.
+func SaveXattrs(ctx context.Context, conn connector.Connector, path string) (string, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf(
+		"command -v getfattr >/dev/null 2>&1 && [ -e %[1]s ] && getfattr --absolute-names -d -m - %[1]s 2>/dev/null || true",
+		shellutil.Quote(path)))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// RestoreXattrs restores extended attributes saved by SaveXattrs. An empty
+// dump is a no-op.
+func RestoreXattrs(ctx context.Context, conn connector.Connector, dump string) error {
+	if dump == "" {
+		return nil
+	}
+
+	result, err := conn.Execute(ctx, fmt.Sprintf("printf '%%s\\n' %s | setfattr --restore=-", shellutil.Quote(dump)))
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return CommandFailedf(result, "failed to restore extended attributes")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestEnsureSELinuxContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		want        SELinuxContext
+		created     bool
+		check       bool
+		wantChanged bool
+		wantCmd     string // prefix of a command that must run
+		wantNoCmd   string // prefix of a command that must not run
+	}{
+		{
+			name:      "disabled",
+			want:      SELinuxContext{Type: "httpd_sys_content_t"},
+			wantNoCmd: "chcon",
+		},
+		{
+			name:        "type differs",
+			enabled:     true,
+			want:        SELinuxContext{Type: "httpd_sys_content_t"},
+			wantChanged: true,
+			wantCmd:     "chcon -t 'httpd_sys_content_t' '/var/www/index.html'",
+		},
+		{
+			name:      "already set",
+			enabled:   true,
+			want:      SELinuxContext{User: "system_u", Level: "s0:c0.c1023"},
+			wantNoCmd: "chcon",
+		},
+		{
+			name:        "check mode",
+			enabled:     true,
+			want:        SELinuxContext{Type: "httpd_sys_content_t"},
+			check:       true,
+			wantChanged: true,
+			wantNoCmd:   "chcon",
+		},
+		{
+			name:    "created gets default context",
+			enabled: true,
+			created: true,
+			wantCmd: "command -v restorecon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			if tt.enabled {
+				conn.OnMatch(`selinuxenabled$`).Return("")
+			} else {
+				conn.OnMatch(`selinuxenabled$`).Fail(1, "")
+			}
+			conn.OnPrefix("stat -c %C").Return("system_u:object_r:var_t:s0:c0.c1023\n")
+			conn.OnPrefix("command -v restorecon").Return("")
+			conn.OnPrefix("chcon").Return("")
+
+			changed, err := EnsureSELinuxContext(context.Background(), conn, "/var/www/index.html", tt.want, tt.created, false, tt.check)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if tt.wantCmd != "" && !ran(conn, tt.wantCmd) {
+				t.Errorf("expected %q to run, ran %q", tt.wantCmd, conn.Commands())
+			}
+			if tt.wantNoCmd != "" && ran(conn, tt.wantNoCmd) {
+				t.Errorf("expected no %q, ran %q", tt.wantNoCmd, conn.Commands())
+			}
+		})
+	}
+}
+
+func TestXattrsRoundTrip(t *testing.T) {
+	dump := "# file: /etc/motd\nsecurity.selinux=\"system_u:object_r:etc_t:s0\""
+
+	conn := connectortest.New()
+	conn.OnMatch(`getfattr`).Return(dump + "\n")
+	conn.OnMatch(`setfattr --restore=-$`).Return("")
+
+	saved, err := SaveXattrs(context.Background(), conn, "/etc/motd")
+	if err != nil {
+		t.Fatalf("SaveXattrs() error: %v", err)
+	}
+	if saved != dump {
+		t.Errorf("SaveXattrs() = %q, want %q", saved, dump)
+	}
+	if err := RestoreXattrs(context.Background(), conn, saved); err != nil {
+		t.Fatalf("RestoreXattrs() error: %v", err)
+	}
+	if !ran(conn, "printf") {
+		t.Errorf("expected attributes to be restored, ran %q", conn.Commands())
+	}
+
+	// Nothing saved, nothing to restore
+	before := len(conn.Commands())
+	if err := RestoreXattrs(context.Background(), conn, ""); err != nil || len(conn.Commands()) != before {
+		t.Errorf("RestoreXattrs(\"\") ran commands or failed: %v", err)
+	}
+}
+
+// ran reports whether a command starting with prefix was executed.
+func ran(conn *connectortest.Connector, prefix string) bool {
+	for _, cmd := range conn.Commands() {
+		if strings.HasPrefix(cmd, prefix) {
+			return true
+		}
+	}
+	return false
+}
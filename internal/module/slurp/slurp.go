@@ -0,0 +1,94 @@
+// Package slurp provides a module for reading files from the target.
+package slurp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"unicode/utf8"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module reads a file on the target into the registered result, so later
+// tasks and templates can use values generated on the host. It never
+// changes the system.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "slurp"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "src", Type: "string", Required: true, Description: "Absolute path of the file to read"},
+	}
+}
+
+// Run executes the slurp module.
+//
+// Parameters:
+//   - src (string, required): Absolute path of the file to read
+//
+// The content is returned base64-encoded as data.content and, if it is
+// valid UTF-8, as-is in data.decoded.
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	src := param.String(params, "src", "")
+	if src == "" {
+		return nil, module.ParamErrorf("src", "'src' parameter is required")
+	}
+	if !path.IsAbs(src) {
+		return nil, module.ParamErrorf("src", "'src' must be an absolute path: %s", src)
+	}
+
+	result, err := conn.Execute(ctx, "test -f "+shellutil.Quote(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %s: %w", src, err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("file not found: %s", src)
+	}
+
+	var buf bytes.Buffer
+	if err := conn.Download(ctx, src, &buf); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	content := buf.Bytes()
+
+	data := map[string]any{
+		"source":   src,
+		"content":  base64.StdEncoding.EncodeToString(content),
+		"encoding": "base64",
+		"size":     len(content),
+	}
+	if utf8.Valid(content) {
+		data["decoded"] = string(content)
+	}
+
+	res := module.Unchanged(fmt.Sprintf("read %d bytes from %s", len(content), src))
+	res.Data = data
+	return res, nil
+}
+
+// SupportsCheckMode reports that slurp can run in check mode. It only
+// reads, so it runs the same as without check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,243 @@
+// Package sshconfig provides a module for managing Host blocks in ssh_config files.
+package sshconfig
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a Host block.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the Host block exists with the given options
+	StateAbsent  State = "absent"  // Ensure the Host block does not exist
+)
+
+// Module manages Host blocks in an OpenSSH client config file.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "ssh_config"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "host", Type: "string", Required: true, Description: "Host pattern of the block"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "path", Type: "string", Default: "~/.ssh/config", Description: "Config file path"},
+		{Name: "hostname", Type: "string", Description: "`HostName` option"},
+		{Name: "user", Type: "string", Description: "`User` option"},
+		{Name: "port", Type: "int", Description: "`Port` option"},
+		{Name: "identity_file", Type: "string", Description: "`IdentityFile` option"},
+		{Name: "forward_agent", Type: "bool", Description: "`ForwardAgent` option"},
+		{Name: "proxy_jump", Type: "string", Description: "`ProxyJump` option"},
+		{Name: "options", Type: "map", Description: "Additional options"},
+	}
+}
+
+// Run executes the ssh_config module.
+//
+// Parameters:
+//   - host (string, required): Host pattern of the block (e.g., "github.com", "*.internal")
+//   - state (string): Desired state - present, absent (default: present)
+//   - path (string): Config file path (default: ~/.ssh/config)
+//   - hostname (string): HostName option
+//   - user (string): User option
+//   - port (int|string): Port option
+//   - identity_file (string): IdentityFile option
+//   - forward_agent (bool): ForwardAgent option
+//   - proxy_jump (string): ProxyJump option
+//   - options (map): Additional options as key/value pairs
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	host, err := param.Required(params, "host")
+	if err != nil {
+		return nil, err
+	}
+
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	switch state {
+	case StatePresent, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	file, err := module.ExpandHome(ctx, conn, param.String(params, "path", "~/.ssh/config"))
+	if err != nil {
+		return nil, err
+	}
+
+	data, exists, err := module.ReadFile(ctx, conn, file)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+
+	lines := module.SplitLines(content)
+	start, end := findHostBlock(lines, host)
+
+	var newLines []string
+	switch state {
+	case StateAbsent:
+		if start < 0 {
+			return module.Unchanged("host block already absent"), nil
+		}
+		newLines = append(append([]string{}, lines[:start]...), lines[end:]...)
+
+	case StatePresent:
+		block := renderHostBlock(host, buildOptions(params))
+		if start >= 0 {
+			newLines = append(append(append([]string{}, lines[:start]...), block...), lines[end:]...)
+		} else {
+			newLines = append([]string{}, lines...)
+			if len(newLines) > 0 && strings.TrimSpace(newLines[len(newLines)-1]) != "" {
+				newLines = append(newLines, "")
+			}
+			newLines = append(newLines, block...)
+		}
+	}
+
+	// Drop the blank lines a removed block leaves at the end of the file
+	for len(newLines) > 0 && strings.TrimSpace(newLines[len(newLines)-1]) == "" {
+		newLines = newLines[:len(newLines)-1]
+	}
+	newContent := module.JoinLines(newLines)
+	if exists && newContent == content {
+		return module.Unchanged("host block already in desired state"), nil
+	}
+
+	// A missing ~/.ssh is created private; an existing directory and file
+	// keep their permissions
+	if err := module.EnsureDir(ctx, conn, path.Dir(file), 0o700); err != nil {
+		return nil, err
+	}
+	if err := module.WriteFile(ctx, conn, file, []byte(newContent), ""); err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf("host block '%s' updated", host)
+	switch {
+	case state == StateAbsent:
+		msg = fmt.Sprintf("host block '%s' removed", host)
+	case start < 0:
+		msg = fmt.Sprintf("host block '%s' added", host)
+	}
+
+	return module.ChangedWithData(msg, map[string]any{
+		"path": file,
+		"host": host,
+	}), nil
+}
+
+// option is a single ssh_config keyword and value.
+type option struct {
+	Key   string
+	Value string
+}
+
+// buildOptions collects the Host block options from params in a stable order.
+func buildOptions(params map[string]any) []option {
+	var opts []option
+
+	named := []struct{ param, key string }{
+		{"hostname", "HostName"},
+		{"user", "User"},
+		{"port", "Port"},
+		{"identity_file", "IdentityFile"},
+		{"forward_agent", "ForwardAgent"},
+		{"proxy_jump", "ProxyJump"},
+	}
+	for _, n := range named {
+		if v, ok := params[n.param]; ok && v != nil {
+			opts = append(opts, option{Key: n.key, Value: formatValue(v)})
+		}
+	}
+
+	if extra, ok := params["options"].(map[string]any); ok {
+		keys := make([]string, 0, len(extra))
+		for k := range extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			opts = append(opts, option{Key: k, Value: formatValue(extra[k])})
+		}
+	}
+
+	return opts
+}
+
+// formatValue renders a parameter value the way ssh_config expects it.
+func formatValue(v any) string {
+	if b, ok := v.(bool); ok {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// renderHostBlock renders a Host block followed by a blank separator line.
+func renderHostBlock(host string, opts []option) []string {
+	lines := []string{"Host " + host}
+	for _, o := range opts {
+		lines = append(lines, fmt.Sprintf("    %s %s", o.Key, o.Value))
+	}
+	return append(lines, "")
+}
+
+// findHostBlock returns the [start, end) line range of the block for host,
+// including trailing blank lines. start is -1 if the block does not exist.
+func findHostBlock(lines []string, host string) (int, int) {
+	start := -1
+	for i, line := range lines {
+		keyword, value := splitKeyword(line)
+		if start < 0 {
+			if keyword == "host" && value == host {
+				start = i
+			}
+			continue
+		}
+		if keyword == "host" || keyword == "match" {
+			return start, i
+		}
+	}
+	if start < 0 {
+		return -1, -1
+	}
+	return start, len(lines)
+}
+
+// splitKeyword splits a config line into a lowercased keyword and its value.
+func splitKeyword(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '='
+	})
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return strings.ToLower(fields[0]), strings.Join(fields[1:], " ")
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -0,0 +1,113 @@
+package sshconfig
+
+import (
+	"context"
+	"maps"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+const testTmp = "/tmp/tmp.sshconfig"
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string // empty if the file does not exist
+		params  map[string]any
+		want    string
+		msg     string
+	}{
+		{
+			name:    "add to system config",
+			file:    "/etc/ssh/ssh_config",
+			content: "Host *\n    SendEnv LANG\n",
+			params:  map[string]any{"host": "git", "hostname": "git.example.com", "user": "git"},
+			want:    "Host *\n    SendEnv LANG\n\nHost git\n    HostName git.example.com\n    User git\n",
+			msg:     "host block 'git' added",
+		},
+		{
+			name:    "replace block",
+			file:    "/etc/ssh/ssh_config",
+			content: "Host git\n    User root\n\nHost *\n    SendEnv LANG\n",
+			params:  map[string]any{"host": "git", "user": "git", "forward_agent": true},
+			want:    "Host git\n    User git\n    ForwardAgent yes\n\nHost *\n    SendEnv LANG\n",
+			msg:     "host block 'git' updated",
+		},
+		{
+			name:    "remove last block",
+			file:    "/etc/ssh/ssh_config",
+			content: "Host *\n    SendEnv LANG\n\nHost git\n    User git\n",
+			params:  map[string]any{"host": "git", "state": "absent"},
+			want:    "Host *\n    SendEnv LANG\n",
+			msg:     "host block 'git' removed",
+		},
+		{
+			name:   "new file",
+			file:   "/home/alice/.ssh/config",
+			params: map[string]any{"host": "git", "port": 2222},
+			want:   "Host git\n    Port 2222\n",
+			msg:    "host block 'git' added",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			if tt.content != "" {
+				conn.SetFile(tt.file, []byte(tt.content), 0o644)
+			} else {
+				conn.On("test -f '"+tt.file+"'").Fail(1, "")
+			}
+			dir := path.Dir(tt.file)
+			conn.On("mktemp").Return(testTmp + "\n")
+			conn.On("test -d '" + dir + "' || mkdir -p -m 700 '" + dir + "'")
+			conn.On("mkdir -p '" + dir + "' && cat '" + testTmp + "' > '" + tt.file + "'")
+			conn.Default(connector.Result{})
+
+			params := map[string]any{"path": tt.file}
+			maps.Copy(params, tt.params)
+			result, err := (&Module{}).Run(context.Background(), conn, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Changed || result.Message != tt.msg {
+				t.Errorf("result = %v %q, want changed %q", result.Changed, result.Message, tt.msg)
+			}
+			if got, _ := conn.File(testTmp); string(got) != tt.want {
+				t.Errorf("written content = %q, want %q", got, tt.want)
+			}
+			for _, cmd := range conn.Commands() {
+				if strings.Contains(cmd, "chmod") {
+					t.Errorf("unexpected command %q, want existing permissions kept", cmd)
+				}
+			}
+			conn.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunUnchanged(t *testing.T) {
+	conn := connectortest.New()
+	conn.SetFile("/etc/ssh/ssh_config", []byte("Host git\n    User git\n"), 0o644)
+	conn.Default(connector.Result{})
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{
+		"host": "git",
+		"user": "git",
+		"path": "/etc/ssh/ssh_config",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("result changed: %s", result.Message)
+	}
+	if conn.Executed("mktemp") {
+		t.Error("file written although the block is up to date")
+	}
+}
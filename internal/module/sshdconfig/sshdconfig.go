@@ -0,0 +1,423 @@
+// Package sshdconfig provides a module for managing settings of the
+// OpenSSH server.
+package sshdconfig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/pkg/facts"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module sets keywords in sshd_config, validates the result with sshd -t,
+// and reloads sshd.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "sshd_config"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	params := []module.ParamSpec{
+		{Name: "settings", Type: "map", Required: true, Description: "Keywords and their values; a list repeats the keyword, null removes it"},
+		{Name: "match", Type: "string", Description: "Criteria of the Match block to set the keywords in, e.g. `Group sftp`"},
+		{Name: "path", Type: "string", Default: "/etc/ssh/sshd_config", Description: "Config file path"},
+		{Name: "validate", Type: "bool", Default: true, Description: "Check the new config with `sshd -t` before writing it"},
+		{Name: "reload", Type: "bool", Default: true, Description: "Reload sshd after changes"},
+		{Name: "backup", Type: "bool", Default: false, Description: "Create backup before changing the file"},
+	}
+	return append(params, module.BackupParamSpecs()...)
+}
+
+// Run executes the sshd_config module.
+//
+// sshd uses the first value it reads for most keywords, and reads an
+// Include where it stands, so keywords are set before the first Include
+// or Match line, where drop-ins in sshd_config.d cannot override them.
+// A keyword already set there is changed in place; other occurrences of
+// it in the same section are removed. Reloading does not end open
+// sessions.
+//
+// Parameters:
+//   - settings (map, required): Keywords and values; bools become yes/no, a list
+//     repeats the keyword, and null removes it
+//   - match (string): Criteria of the Match block to set the keywords in
+//   - path (string): Config file path (default: /etc/ssh/sshd_config)
+//   - validate (bool): Check the new config with sshd -t first (default: true)
+//   - reload (bool): Reload sshd after changes (default: true)
+//   - backup (bool): Create backup before changing the file (default: false)
+//   - backup_dir (string): Directory to write backups to (default: next to the file)
+//   - backup_keep (int): Number of backups to keep, 0 for all (default: 0)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	raw := param.Map(params, "settings")
+	match := strings.Join(strings.Fields(param.String(params, "match", "")), " ")
+	path := param.String(params, "path", "/etc/ssh/sshd_config")
+	validate := param.Bool(params, "validate", true)
+	reload := param.Bool(params, "reload", true)
+	backup := param.Bool(params, "backup", false)
+	backupOpts, err := module.BackupParams(params)
+	if err != nil {
+		return nil, err
+	}
+	check := module.IsCheckMode(params)
+
+	if len(raw) == 0 {
+		return nil, module.ParamErrorf("settings", "'settings' parameter is required")
+	}
+	settings, err := parseSettings(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	content, exists, err := module.ReadFile(ctx, conn, path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("file not found: %s", path)
+	}
+
+	cfg := &config{lines: module.SplitLines(string(content))}
+	var keys []string
+	before := map[string]any{}
+	after := map[string]any{}
+	for _, s := range settings {
+		old, changed := cfg.set(match, s.key, s.values)
+		if changed {
+			keys = append(keys, s.key)
+			before[s.key], after[s.key] = diffValue(old), diffValue(s.values)
+		}
+	}
+	if match != "" {
+		before, after = map[string]any{"Match " + match: before}, map[string]any{"Match " + match: after}
+	}
+
+	newContent := []byte(module.JoinLines(cfg.lines))
+	if bytes.Equal(newContent, content) {
+		return module.Unchanged("settings already in desired state"), nil
+	}
+
+	msg := fmt.Sprintf("set %s in %s", strings.Join(keys, ", "), path)
+	if len(keys) == 0 {
+		// A keyword moved before an Include, or a duplicate removed
+		msg = fmt.Sprintf("reordered settings in %s", path)
+	}
+	data := map[string]any{"path": path}
+	if check {
+		return module.ChangedWithData("would "+msg, data).WithDiff(before, after), nil
+	}
+
+	if backup {
+		backupFile, err := module.Backup(ctx, conn, path, backupOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create backup: %w", err)
+		}
+		data["backup_file"] = backupFile
+	}
+	var validateCmd string
+	if validate {
+		validateCmd = sshd + " -t -f %s"
+	}
+	if err := module.WriteFile(ctx, conn, path, newContent, validateCmd); err != nil {
+		return nil, err
+	}
+
+	if reload {
+		reloaded, err := reloadSSHD(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		data["reloaded"] = reloaded
+		if reloaded {
+			msg += "; reloaded sshd"
+		}
+	}
+	return module.ChangedWithData(msg, data).WithDiff(before, after), nil
+}
+
+// setting is a keyword and the values to set it to, one line each, or
+// nil to remove it.
+type setting struct {
+	key    string
+	values []string
+}
+
+// keywordPattern matches sshd_config keywords.
+var keywordPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// parseSettings converts the settings parameter into settings ordered by
+// keyword.
+func parseSettings(raw map[string]any) ([]setting, error) {
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var settings []setting
+	for _, k := range keys {
+		if !keywordPattern.MatchString(k) {
+			return nil, module.ParamErrorf("settings", "invalid keyword '%s'", k)
+		}
+		switch strings.ToLower(k) {
+		case "match", "include":
+			return nil, module.ParamErrorf("settings", "'%s' cannot be set; use the match parameter for Match blocks", k)
+		}
+
+		s := setting{key: k}
+		switch v := raw[k].(type) {
+		case nil:
+			// Remove the keyword
+		case []any:
+			if len(v) == 0 {
+				return nil, module.ParamErrorf("settings", "'%s' must not be an empty list; use null to remove it", k)
+			}
+			for _, item := range v {
+				s.values = append(s.values, formatValue(item))
+			}
+		default:
+			s.values = []string{formatValue(v)}
+		}
+		settings = append(settings, s)
+	}
+	return settings, nil
+}
+
+// formatValue renders a value the way sshd_config expects it.
+func formatValue(v any) string {
+	if b, ok := v.(bool); ok {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// diffValue returns values for Result.Before or Result.After: nil for a
+// keyword that is not set, the value of a keyword set once, or the list.
+func diffValue(values []string) any {
+	switch len(values) {
+	case 0:
+		return nil
+	case 1:
+		return values[0]
+	}
+	return values
+}
+
+// config is the lines of an sshd_config file.
+type config struct {
+	lines []string
+}
+
+// set sets key to values in the global section, or in the Match block
+// with the criteria match, which is added at the end if it is missing.
+// It returns the values key had, and whether they changed.
+func (c *config) set(match, key string, values []string) ([]string, bool) {
+	start, end, limit := c.section(match)
+	if start < 0 {
+		if values == nil {
+			return nil, false
+		}
+		c.addMatch(match)
+		start, end, limit = c.section(match)
+	}
+
+	var found []int
+	var old []string
+	for i := start; i < end; i++ {
+		keyword, value := splitKeyword(c.lines[i])
+		if strings.EqualFold(keyword, key) {
+			found = append(found, i)
+			old = append(old, value)
+		}
+	}
+	if slices.Equal(old, values) && (len(found) == 0 || found[0] < limit) {
+		return old, false
+	}
+
+	// The new lines replace the first occurrence if sshd reads it first,
+	// and are inserted before the limit otherwise
+	pos := limit
+	if len(found) > 0 && found[0] < limit {
+		pos = found[0]
+	}
+	indent := c.indent(start, end, match)
+	var newLines []string
+	for _, v := range values {
+		newLines = append(newLines, indent+key+" "+v)
+	}
+
+	var lines []string
+	for i, line := range c.lines {
+		if i == pos {
+			lines = append(lines, newLines...)
+		}
+		if !slices.Contains(found, i) {
+			lines = append(lines, line)
+		}
+	}
+	if pos == len(c.lines) {
+		lines = append(lines, newLines...)
+	}
+	c.lines = lines
+	return old, !slices.Equal(old, values)
+}
+
+// section returns the lines [start, end) of the global section or of the
+// Match block with the criteria match, and the line before which new
+// keywords go: the first Include or Match line of the global section, or
+// the line after the last keyword of the block. start is -1 if the block
+// is missing.
+func (c *config) section(match string) (start, end, limit int) {
+	start, end = -1, len(c.lines)
+	if match == "" {
+		start = 0
+	}
+	for i, line := range c.lines {
+		keyword, value := splitKeyword(line)
+		if !strings.EqualFold(keyword, "match") {
+			continue
+		}
+		if start >= 0 && i >= start {
+			end = i
+			break
+		}
+		if match != "" && strings.EqualFold(strings.Join(strings.Fields(value), " "), match) {
+			start = i + 1
+		}
+	}
+	if start < 0 {
+		return -1, -1, -1
+	}
+
+	if match != "" {
+		limit = start
+		for i := start; i < end; i++ {
+			if keyword, _ := splitKeyword(c.lines[i]); keyword != "" {
+				limit = i + 1
+			}
+		}
+		return start, end, limit
+	}
+
+	limit = end
+	for i := 0; i < end; i++ {
+		if keyword, _ := splitKeyword(c.lines[i]); strings.EqualFold(keyword, "include") {
+			limit = i
+			break
+		}
+	}
+	// Keep the blank line that separates the Include or Match
+	for limit > 0 && limit < len(c.lines) && strings.TrimSpace(c.lines[limit-1]) == "" {
+		limit--
+	}
+	return start, end, limit
+}
+
+// addMatch adds a Match block with the criteria match at the end.
+func (c *config) addMatch(match string) {
+	for len(c.lines) > 0 && strings.TrimSpace(c.lines[len(c.lines)-1]) == "" {
+		c.lines = c.lines[:len(c.lines)-1]
+	}
+	if len(c.lines) > 0 {
+		c.lines = append(c.lines, "")
+	}
+	c.lines = append(c.lines, "Match "+match)
+}
+
+// indent returns the indentation of keywords in the section: none in the
+// global section, and that of the block's first keyword in a Match block.
+func (c *config) indent(start, end int, match string) string {
+	if match == "" {
+		return ""
+	}
+	for i := start; i < end; i++ {
+		if keyword, _ := splitKeyword(c.lines[i]); keyword != "" {
+			line := c.lines[i]
+			return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+	}
+	return "    "
+}
+
+// splitKeyword splits a config line into its keyword and value. Comments
+// and blank lines have no keyword.
+func splitKeyword(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(strings.TrimLeft(line[i:], " \t="))
+}
+
+// sshd finds sshd, which is usually in an sbin directory that is not on
+// the PATH of non-root users.
+const sshd = `PATH="$PATH:/usr/sbin:/usr/local/sbin:/sbin" sshd`
+
+// reloadSSHD reloads sshd if it is running, so new connections use the new
+// config. It returns false where there is no service to reload: launchd
+// starts sshd for every connection, and containers often run none.
+func reloadSSHD(ctx context.Context, conn connector.Connector) (bool, error) {
+	mgr, err := facts.ServiceManager(ctx, conn)
+	if err != nil {
+		return false, err
+	}
+
+	var cmd string
+	switch mgr {
+	case facts.ServiceSystemd:
+		// Debian names the unit ssh, other distributions sshd
+		cmd = `for unit in ssh.service sshd.service; do
+  if systemctl cat "$unit" >/dev/null 2>&1; then exec systemctl try-reload-or-restart "$unit"; fi
+done
+echo "no ssh or sshd unit found" >&2; exit 1`
+	case facts.ServiceOpenRC:
+		cmd = "rc-service sshd --ifstarted reload"
+	case facts.ServiceSysVinit:
+		cmd = "service ssh reload || service sshd reload"
+	case facts.ServiceRC:
+		cmd = "service sshd onereload"
+	default:
+		return false, nil
+	}
+
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to reload sshd: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return false, module.CommandFailedf(result, "failed to reload sshd")
+	}
+	return true, nil
+}
+
+// SupportsCheckMode reports that sshd_config can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
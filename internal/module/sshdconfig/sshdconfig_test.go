@@ -0,0 +1,247 @@
+package sshdconfig
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const (
+	testFile = "/etc/ssh/sshd_config"
+	testTmp  = "/tmp/tmp.sshd"
+
+	// validateCmd and writeCmd check the uploaded temp file and copy it
+	// over testFile.
+	validateCmd = sshd + " -t -f '" + testTmp + "'"
+	writeCmd    = "mkdir -p '/etc/ssh' && cat '" + testTmp + "' > '" + testFile + "'"
+)
+
+// newConn returns a fake target with testFile holding content, on which
+// every other command succeeds.
+func newConn(content string) *connectortest.Connector {
+	conn := connectortest.New()
+	conn.SetFile(testFile, []byte(content), 0o600)
+	conn.On("mktemp").Return(testTmp + "\n")
+	conn.Default(connector.Result{})
+	return conn
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		match    string
+		settings map[string]any
+		want     string
+	}{
+		{
+			name:     "replace directive",
+			content:  "Port 22\nPermitRootLogin yes\n",
+			settings: map[string]any{"PermitRootLogin": false},
+			want:     "Port 22\nPermitRootLogin no\n",
+		},
+		{
+			name:     "replace first and drop duplicates",
+			content:  "permitrootlogin yes\nPort 22\nPermitRootLogin prohibit-password\n",
+			settings: map[string]any{"PermitRootLogin": "no"},
+			want:     "PermitRootLogin no\nPort 22\n",
+		},
+		{
+			name:     "remove directive",
+			content:  "Port 22\nX11Forwarding yes\n",
+			settings: map[string]any{"X11Forwarding": nil},
+			want:     "Port 22\n",
+		},
+		{
+			name:     "list",
+			content:  "AcceptEnv LANG\n",
+			settings: map[string]any{"AcceptEnv": []any{"LANG", "LC_*"}},
+			want:     "AcceptEnv LANG\nAcceptEnv LC_*\n",
+		},
+		{
+			name:     "new directive goes before Match",
+			content:  "Port 22\n\nMatch User git\n    X11Forwarding no\n",
+			settings: map[string]any{"PasswordAuthentication": false},
+			want:     "Port 22\nPasswordAuthentication no\n\nMatch User git\n    X11Forwarding no\n",
+		},
+		{
+			name:     "directive after Include moves before it",
+			content:  "Include /etc/ssh/sshd_config.d/*.conf\nPort 2222\n",
+			settings: map[string]any{"Port": 2222},
+			want:     "Port 2222\nInclude /etc/ssh/sshd_config.d/*.conf\n",
+		},
+		{
+			name:     "global directive ignores Match blocks",
+			content:  "X11Forwarding yes\n\nMatch User git\n    X11Forwarding yes\n",
+			settings: map[string]any{"X11Forwarding": false},
+			want:     "X11Forwarding no\n\nMatch User git\n    X11Forwarding yes\n",
+		},
+		{
+			name:     "replace in Match block",
+			content:  "X11Forwarding yes\n\nMatch User git\n    X11Forwarding yes\n",
+			match:    "User  git",
+			settings: map[string]any{"X11Forwarding": false},
+			want:     "X11Forwarding yes\n\nMatch User git\n    X11Forwarding no\n",
+		},
+		{
+			name:     "add to Match block",
+			content:  "Match User git\n\tX11Forwarding no\n\nMatch Group sftp\n\tChrootDirectory %h\n",
+			match:    "User git",
+			settings: map[string]any{"AllowTcpForwarding": false},
+			want:     "Match User git\n\tX11Forwarding no\n\tAllowTcpForwarding no\n\nMatch Group sftp\n\tChrootDirectory %h\n",
+		},
+		{
+			name:     "add Match block",
+			content:  "Port 22\n\n",
+			match:    "Group sftp",
+			settings: map[string]any{"ForceCommand": "internal-sftp"},
+			want:     "Port 22\n\nMatch Group sftp\n    ForceCommand internal-sftp\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConn(tt.content)
+			params := map[string]any{"settings": tt.settings, "match": tt.match, "reload": false}
+
+			result, err := (&Module{}).Run(context.Background(), conn, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Changed {
+				t.Fatalf("Changed = false, want true (%s)", result.Message)
+			}
+			written, _ := conn.File(testTmp)
+			if string(written) != tt.want {
+				t.Fatalf("written = %q, want %q", written, tt.want)
+			}
+
+			// Running again on the result changes nothing
+			conn = newConn(tt.want)
+			result, err = (&Module{}).Run(context.Background(), conn, params)
+			if err != nil {
+				t.Fatalf("unexpected error on second run: %v", err)
+			}
+			if result.Changed {
+				t.Errorf("second run changed the file: %s", result.Message)
+			}
+		})
+	}
+}
+
+func TestRunUnchanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		match    string
+		settings map[string]any
+	}{
+		{"same value", "", map[string]any{"permitrootlogin": "no"}},
+		{"bool value", "", map[string]any{"PasswordAuthentication": false}},
+		{"remove missing", "", map[string]any{"X11Forwarding": nil}},
+		{"remove from missing Match block", "User nobody", map[string]any{"X11Forwarding": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConn("PermitRootLogin no\nPasswordAuthentication no\n")
+			params := map[string]any{"settings": tt.settings, "match": tt.match}
+
+			result, err := (&Module{}).Run(context.Background(), conn, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed {
+				t.Errorf("Changed = true, want false (%s)", result.Message)
+			}
+			if conn.Executed("mktemp") {
+				t.Error("file was written")
+			}
+		})
+	}
+}
+
+func TestRunValidatesBeforeWriting(t *testing.T) {
+	conn := newConn("PermitRootLogin yes\n")
+	params := map[string]any{"settings": map[string]any{"PermitRootLogin": "no"}, "reload": false}
+
+	if _, err := (&Module{}).Run(context.Background(), conn, params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	commands := conn.Commands()
+	validated := slices.Index(commands, validateCmd)
+	written := slices.Index(commands, writeCmd)
+	if validated < 0 || written < 0 || validated > written {
+		t.Errorf("commands = %q, want %q before %q", commands, validateCmd, writeCmd)
+	}
+}
+
+func TestRunValidationFails(t *testing.T) {
+	conn := newConn("PermitRootLogin yes\n")
+	conn.On(validateCmd).Fail(255, "Bad configuration option\n")
+	params := map[string]any{"settings": map[string]any{"PermitRootLogin": "maybe"}}
+
+	if _, err := (&Module{}).Run(context.Background(), conn, params); err == nil {
+		t.Fatal("Run() succeeded, want a validation error")
+	}
+	if conn.Executed(writeCmd) {
+		t.Error("config was written after sshd -t rejected it")
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunWithoutValidation(t *testing.T) {
+	conn := newConn("PermitRootLogin yes\n")
+	params := map[string]any{"settings": map[string]any{"PermitRootLogin": "no"}, "validate": false, "reload": false}
+
+	if _, err := (&Module{}).Run(context.Background(), conn, params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conn.Executed(validateCmd) {
+		t.Error("sshd -t ran with validate: false")
+	}
+	if !conn.Executed(writeCmd) {
+		t.Error("config was not written")
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	conn := newConn("PermitRootLogin yes\n")
+	params := map[string]any{"settings": map[string]any{"PermitRootLogin": "no"}, module.CheckModeParam: true}
+
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	if conn.Executed("mktemp") {
+		t.Error("check mode wrote the file")
+	}
+}
+
+func TestRunInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+	}{
+		{"none", map[string]any{}},
+		{"bad keyword", map[string]any{"Permit Root": "no"}},
+		{"match keyword", map[string]any{"Match": "User git"}},
+		{"include keyword", map[string]any{"Include": "/etc/ssh/extra.conf"}},
+		{"empty list", map[string]any{"AcceptEnv": []any{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]any{"settings": tt.settings}
+			if _, err := (&Module{}).Run(context.Background(), newConn(""), params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Package tailscale provides a module for joining hosts to a Tailscale tailnet.
+package tailscale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of the Tailscale node.
+type State string
+
+const (
+	StateUp     State = "up"     // Ensure the node is logged in and connected
+	StateDown   State = "down"   // Ensure the node is disconnected but stays logged in
+	StateAbsent State = "absent" // Ensure the node is logged out of the tailnet
+)
+
+// exitNodeRoutes are the routes advertised by an exit node.
+var exitNodeRoutes = []string{"0.0.0.0/0", "::/0"}
+
+// Module installs Tailscale and manages the node's connection to a tailnet.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "tailscale"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "state", Type: "string", Default: "up", Choices: []string{"up", "down", "absent"}, Description: "Desired state"},
+		{Name: "auth_key", Type: "string", Description: "Auth key for logging in"},
+		{Name: "hostname", Type: "string", Description: "Hostname to register in the tailnet"},
+		{Name: "advertise_exit_node", Type: "bool", Default: false, Description: "Offer this node as an exit node"},
+		{Name: "exit_node", Type: "string", Description: "IP of the exit node to use"},
+		{Name: "accept_routes", Type: "bool", Default: false, Description: "Accept subnet routes from other nodes"},
+		{Name: "advertise_routes", Type: "list", Description: "Subnet routes to advertise"},
+		{Name: "install", Type: "bool", Default: true, Description: "Install Tailscale with the official script if missing"},
+	}
+}
+
+// Run executes the tailscale module.
+//
+// Parameters:
+//   - state (string): Desired state - up, down, absent (default: up)
+//   - auth_key (string): Auth key used when the node is not logged in yet
+//   - hostname (string): Hostname to register in the tailnet
+//   - advertise_exit_node (bool): Offer this node as an exit node (default: false)
+//   - exit_node (string): IP of the exit node to route traffic through
+//   - accept_routes (bool): Accept subnet routes advertised by other nodes (default: false)
+//   - advertise_routes ([]string): Subnet routes to advertise
+//   - install (bool): Install Tailscale with the official script if missing (default: true)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	stateStr := param.String(params, "state", "up")
+	state := State(stateStr)
+	authKey := param.String(params, "auth_key", "")
+	hostname := param.String(params, "hostname", "")
+	advertiseExitNode := param.Bool(params, "advertise_exit_node", false)
+	exitNode := param.String(params, "exit_node", "")
+	acceptRoutes := param.Bool(params, "accept_routes", false)
+	advertiseRoutes := param.StringList(params, "advertise_routes")
+	install := param.Bool(params, "install", true)
+
+	switch state {
+	case StateUp, StateDown, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be up, down, or absent", state)
+	}
+
+	if advertiseExitNode && exitNode != "" {
+		return nil, fmt.Errorf("advertise_exit_node and exit_node are mutually exclusive")
+	}
+
+	installed, err := isInstalled(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+
+	if !installed {
+		if state != StateUp {
+			return module.Unchanged("tailscale is not installed"), nil
+		}
+		if !install {
+			return nil, fmt.Errorf("tailscale is not installed and install=false")
+		}
+		if module.IsOffline(params) {
+			return nil, module.OfflineErrorf("installing tailscale", "install the tailscale package from a local mirror first (e.g., with the apt module)")
+		}
+		if err := run(ctx, conn, "curl -fsSL https://tailscale.com/install.sh | sh"); err != nil {
+			return nil, fmt.Errorf("failed to install tailscale: %w", err)
+		}
+		messages = append(messages, "installed tailscale")
+	}
+
+	status, err := getStatus(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	loggedIn := status.BackendState != "NeedsLogin" && status.BackendState != "NoState"
+
+	switch state {
+	case StateAbsent:
+		if !loggedIn {
+			return module.Unchanged("node is not logged in"), nil
+		}
+		if err := run(ctx, conn, "tailscale logout"); err != nil {
+			return nil, err
+		}
+		return module.Changed("logged out of tailnet"), nil
+
+	case StateDown:
+		if status.BackendState != "Running" {
+			return module.Unchanged("node is already down"), nil
+		}
+		if err := run(ctx, conn, "tailscale down"); err != nil {
+			return nil, err
+		}
+		return module.Changed("disconnected from tailnet"), nil
+	}
+
+	if !loggedIn && authKey == "" {
+		return nil, fmt.Errorf("node is not logged in; 'auth_key' parameter is required")
+	}
+
+	routes := append([]string{}, advertiseRoutes...)
+	if advertiseExitNode {
+		routes = append(routes, exitNodeRoutes...)
+	}
+
+	if status.BackendState == "Running" {
+		prefs, err := getPrefs(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if prefs.matches(hostname, exitNode, acceptRoutes, routes) {
+			return module.Unchanged("node already connected with desired settings"), nil
+		}
+	}
+
+	args := []string{"tailscale", "up", "--reset"}
+	if !loggedIn {
+		args = append(args, "--auth-key="+authKey)
+	}
+	if hostname != "" {
+		args = append(args, "--hostname="+hostname)
+	}
+	if advertiseExitNode {
+		args = append(args, "--advertise-exit-node")
+	}
+	if exitNode != "" {
+		args = append(args, "--exit-node="+exitNode)
+	}
+	if acceptRoutes {
+		args = append(args, "--accept-routes")
+	}
+	if len(advertiseRoutes) > 0 {
+		args = append(args, "--advertise-routes="+strings.Join(advertiseRoutes, ","))
+	}
+
+	if err := run(ctx, conn, shellutil.Join(args...)); err != nil {
+		return nil, err
+	}
+
+	if loggedIn {
+		messages = append(messages, "updated tailscale settings")
+	} else {
+		messages = append(messages, "joined tailnet")
+	}
+
+	return module.ChangedWithData(strings.Join(messages, ", "), map[string]any{
+		"hostname": hostname,
+	}), nil
+}
+
+// status is the subset of `tailscale status --json` used by the module.
+type status struct {
+	BackendState string `json:"BackendState"`
+}
+
+// prefs is the subset of `tailscale debug prefs` used by the module.
+type prefs struct {
+	Hostname        string   `json:"Hostname"`
+	ExitNodeIP      string   `json:"ExitNodeIP"`
+	RouteAll        bool     `json:"RouteAll"`
+	AdvertiseRoutes []string `json:"AdvertiseRoutes"`
+}
+
+// matches reports whether the current preferences equal the desired ones.
+func (p *prefs) matches(hostname, exitNode string, acceptRoutes bool, routes []string) bool {
+	if hostname != "" && p.Hostname != hostname {
+		return false
+	}
+	if p.ExitNodeIP != exitNode || p.RouteAll != acceptRoutes {
+		return false
+	}
+
+	have := append([]string{}, p.AdvertiseRoutes...)
+	want := append([]string{}, routes...)
+	sort.Strings(have)
+	sort.Strings(want)
+	return strings.Join(have, ",") == strings.Join(want, ",")
+}
+
+// isInstalled checks whether the tailscale CLI is available.
+func isInstalled(ctx context.Context, conn connector.Connector) (bool, error) {
+	result, err := conn.Execute(ctx, "command -v tailscale")
+	if err != nil {
+		return false, fmt.Errorf("failed to check for tailscale: %w", err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+// getStatus reads the backend state of the node.
+func getStatus(ctx context.Context, conn connector.Connector) (*status, error) {
+	result, err := conn.Execute(ctx, "tailscale status --json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tailscale status: %w", err)
+	}
+	// status exits non-zero when logged out but still prints JSON
+	var s status
+	if err := json.Unmarshal([]byte(result.Stdout), &s); err != nil {
+		return nil, module.CommandFailedf(result, "failed to parse tailscale status")
+	}
+	return &s, nil
+}
+
+// getPrefs reads the node's current preferences.
+func getPrefs(ctx context.Context, conn connector.Connector) (*prefs, error) {
+	result, err := conn.Execute(ctx, "tailscale debug prefs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tailscale prefs: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "failed to get tailscale prefs")
+	}
+	var p prefs
+	if err := json.Unmarshal([]byte(result.Stdout), &p); err != nil {
+		return nil, fmt.Errorf("failed to parse tailscale prefs: %w", err)
+	}
+	return &p, nil
+}
+
+// run executes a command and converts a non-zero exit code into an error.
+// The command is left out of the error since it may contain the auth key.
+func run(ctx context.Context, conn connector.Connector, cmd string) error {
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to run tailscale: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "tailscale command failed")
+	}
+	return nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
@@ -11,10 +11,11 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
-	"time"
 
 	"github.com/eugenetaranov/bolt/internal/connector"
 	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
 )
 
 func init() {
@@ -29,40 +30,72 @@ func (m *Module) Name() string {
 	return "template"
 }
 
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	params := []module.ParamSpec{
+		{Name: "src", Type: "string", Required: true, Description: "Template file path (relative to role's templates/)"},
+		{Name: "dest", Type: "string", Required: true, Description: "Destination path on target"},
+		{Name: "mode", Type: "string/int", Default: "0644", Description: "File permissions, octal or symbolic"},
+		{Name: "owner", Type: "string", Description: "Owner username"},
+		{Name: "group", Type: "string", Description: "Group name"},
+		{Name: "backup", Type: "bool", Default: false, Description: "Create backup before overwriting"},
+	}
+	params = append(params, module.ContentParamSpecs()...)
+	params = append(params, module.BackupParamSpecs()...)
+	return params
+}
+
 // Run executes the template module.
 //
 // Parameters:
 //   - src (string, required): Template file path (relative paths resolve to role's templates/ dir)
 //   - dest (string, required): Destination path on the target
-//   - mode (string): File permissions in octal (e.g., "0644")
+//   - mode (string|int): File permissions, octal (e.g., "0644") or symbolic (e.g., "u+x")
 //   - owner (string): Owner username
 //   - group (string): Group name
 //   - backup (bool): Create backup before overwriting (default: false)
+//   - backup_dir (string): Directory to write backups to (default: next to dest)
+//   - backup_keep (int): Number of backups to keep, 0 for all (default: 0)
+//   - newline (string): Convert line endings of the rendered template to "lf" or "crlf"
+//   - ignore_whitespace (bool): Leave dest unchanged if it only differs in
+//     trailing whitespace and line endings (default: false)
 func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
 	// Extract parameters
-	src, err := requireString(params, "src")
+	src, err := param.Required(params, "src")
 	if err != nil {
 		return nil, err
 	}
 
-	dest, err := requireString(params, "dest")
+	dest, err := param.Required(params, "dest")
 	if err != nil {
 		return nil, err
 	}
 
-	mode := getString(params, "mode", "0644")
-	owner := getString(params, "owner", "")
-	group := getString(params, "group", "")
-	backup := getBool(params, "backup", false)
+	mode, err := param.Mode(params, "mode", "0644")
+	if err != nil {
+		return nil, err
+	}
+	owner := param.String(params, "owner", "")
+	group := param.String(params, "group", "")
+	backup := param.Bool(params, "backup", false)
+	backupOpts, err := module.BackupParams(params)
+	if err != nil {
+		return nil, err
+	}
+	contentOpts, err := module.ContentParams(params)
+	if err != nil {
+		return nil, err
+	}
+	check := module.IsCheckMode(params)
 
 	// Get template variables (injected by executor)
-	templateVars := getMap(params, "_template_vars")
+	templateVars := param.Map(params, "_template_vars")
 
 	// Resolve template path - check if it's relative and we have a role path
 	templatePath := src
 	if !filepath.IsAbs(src) {
 		// Check for role path (injected by executor for role tasks)
-		if rolePath := getString(params, "_role_path", ""); rolePath != "" {
+		if rolePath := param.String(params, "_role_path", ""); rolePath != "" {
 			// Look in role's templates directory
 			roleTemplatePath := filepath.Join(rolePath, "templates", src)
 			if _, err := os.Stat(roleTemplatePath); err == nil {
@@ -82,6 +115,7 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 	if err != nil {
 		return nil, fmt.Errorf("failed to render template: %w", err)
 	}
+	renderedContent = contentOpts.Convert(renderedContent)
 
 	// Calculate checksum of rendered content
 	srcChecksum := checksum(renderedContent)
@@ -92,38 +126,90 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		return nil, fmt.Errorf("failed to check destination: %w", err)
 	}
 
+	// With ignore_whitespace, a destination that only differs in
+	// whitespace counts as having the same content
+	same := destExists && srcChecksum == destChecksum
+	if destExists && !same && contentOpts.IgnoreWhitespace {
+		if same, err = module.SameTextAs(ctx, conn, dest, renderedContent); err != nil {
+			return nil, fmt.Errorf("failed to compare destination: %w", err)
+		}
+	}
+
 	// If destination exists with same content, check if we need to update mode/owner
-	if destExists && srcChecksum == destChecksum {
+	if same {
 		// File content matches, check attributes
-		attrChanged, err := ensureAttributes(ctx, conn, dest, mode, owner, group)
+		before, after, err := ensureAttributes(ctx, conn, dest, mode, owner, group, check)
 		if err != nil {
 			return nil, err
 		}
-		if attrChanged {
-			return module.Changed("attributes updated"), nil
+		if len(after) > 0 {
+			if check {
+				return module.Changed("attributes would be updated").WithDiff(before, after), nil
+			}
+			return module.Changed("attributes updated").WithDiff(before, after), nil
 		}
 		return module.Unchanged("template already rendered with correct content and attributes"), nil
 	}
 
+	// The mode the file is written with
+	newMode, err := uploadMode(ctx, conn, dest, mode, destExists)
+	if err != nil {
+		return nil, err
+	}
+
+	// Describe the change by checksum, and in diff mode by content
+	var before map[string]any
+	after := map[string]any{"checksum": srcChecksum}
+	if destExists {
+		before = map[string]any{"checksum": destChecksum}
+	} else {
+		after["mode"] = newMode
+
+		// A new file goes to the connecting user with
+		// become_keep_ownership, not the become user
+		if owner == "" {
+			owner = module.DefaultOwner(params)
+		}
+		if owner != "" {
+			after["owner"] = owner
+		}
+	}
+	if module.IsDiff(params) {
+		if destExists {
+			if before["content"], err = module.ReadForDiff(ctx, conn, dest); err != nil {
+				return nil, err
+			}
+		}
+		after["content"] = module.DiffText(renderedContent)
+	}
+
+	if check {
+		if destExists {
+			return module.Changed("template would be updated").WithDiff(before, after), nil
+		}
+		return module.Changed("template would be rendered").WithDiff(before, after), nil
+	}
+
 	// Create backup if needed
+	var backupFile string
 	if destExists && backup {
-		if err := createBackup(ctx, conn, dest); err != nil {
+		if backupFile, err = module.Backup(ctx, conn, dest, backupOpts); err != nil {
 			return nil, fmt.Errorf("failed to create backup: %w", err)
 		}
 	}
 
 	// Upload the rendered content
-	modeInt, err := parseMode(mode)
+	modeInt, err := parseMode(newMode)
 	if err != nil {
 		return nil, fmt.Errorf("invalid mode: %w", err)
 	}
 
-	if err := conn.Upload(ctx, bytes.NewReader(renderedContent), dest, modeInt); err != nil {
+	if err := module.UploadVerified(ctx, conn, renderedContent, dest, modeInt); err != nil {
 		return nil, fmt.Errorf("failed to upload rendered template: %w", err)
 	}
 
 	// Set attributes
-	if _, err := ensureAttributes(ctx, conn, dest, mode, owner, group); err != nil {
+	if _, _, err := ensureAttributes(ctx, conn, dest, mode, owner, group, false); err != nil {
 		return nil, err
 	}
 
@@ -134,10 +220,14 @@ func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[s
 		msg = "template rendered"
 	}
 
-	return module.ChangedWithData(msg, map[string]any{
+	data := map[string]any{
 		"dest":     dest,
 		"checksum": srcChecksum,
-	}), nil
+	}
+	if backupFile != "" {
+		data["backup_file"] = backupFile
+	}
+	return module.ChangedWithData(msg, data).WithDiff(before, after), nil
 }
 
 // renderTemplate renders a Go template with the given variables.
@@ -196,7 +286,7 @@ func getRemoteChecksum(ctx context.Context, conn connector.Connector, path strin
 		fi
 	else
 		echo "NO_FILE"
-	fi`, shellQuote(path))
+	fi`, shellutil.Quote(path))
 
 	result, err := conn.Execute(ctx, cmd)
 	if err != nil {
@@ -214,24 +304,47 @@ func getRemoteChecksum(ctx context.Context, conn connector.Connector, path strin
 	}
 }
 
-// ensureAttributes sets mode and ownership on a file.
-func ensureAttributes(ctx context.Context, conn connector.Connector, path, mode, owner, group string) (bool, error) {
-	var changed bool
+// ensureAttributes sets mode and ownership on a file, only if they differ
+// from desired, and returns the attributes that differed, as they were and
+// as they are now. With check set it only reports them.
+func ensureAttributes(ctx context.Context, conn connector.Connector, path, mode, owner, group string, check bool) (before, after map[string]any, err error) {
+	// Get current attributes
+	currentMode, currentOwner, currentGroup, err := getFileAttributes(ctx, conn, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get file attributes: %w", err)
+	}
+	before, after = map[string]any{}, map[string]any{}
 
-	// Set mode
-	if mode != "" {
-		result, err := conn.Execute(ctx, fmt.Sprintf("chmod %s %s", mode, shellQuote(path)))
-		if err != nil {
-			return false, fmt.Errorf("failed to set mode: %w", err)
-		}
-		if result.ExitCode != 0 {
-			return false, fmt.Errorf("chmod failed: %s", result.Stderr)
+	// Set mode only if different; a symbolic mode applies to the
+	// current one
+	want, err := param.ResolveMode(mode, currentMode, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if want != currentMode {
+		before["mode"], after["mode"] = currentMode, want
+		if !check {
+			result, err := connector.ExecuteArgv(ctx, conn, "chmod", want, path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to set mode: %w", err)
+			}
+			if result.ExitCode != 0 {
+				return nil, nil, module.CommandFailedf(result, "chmod failed")
+			}
 		}
-		changed = true
 	}
 
-	// Set ownership
-	if owner != "" || group != "" {
+	// Set ownership only if different
+	needOwnerChange := owner != "" && currentOwner != owner
+	needGroupChange := group != "" && currentGroup != group
+	if needOwnerChange {
+		before["owner"], after["owner"] = currentOwner, owner
+	}
+	if needGroupChange {
+		before["group"], after["group"] = currentGroup, group
+	}
+
+	if (needOwnerChange || needGroupChange) && !check {
 		var ownership string
 		if owner != "" && group != "" {
 			ownership = fmt.Sprintf("%s:%s", owner, group)
@@ -241,32 +354,59 @@ func ensureAttributes(ctx context.Context, conn connector.Connector, path, mode,
 			ownership = fmt.Sprintf(":%s", group)
 		}
 
-		result, err := conn.Execute(ctx, fmt.Sprintf("chown %s %s", ownership, shellQuote(path)))
+		result, err := connector.ExecuteArgv(ctx, conn, "chown", ownership, path)
 		if err != nil {
-			return false, fmt.Errorf("failed to set ownership: %w", err)
+			return nil, nil, fmt.Errorf("failed to set ownership: %w", err)
 		}
 		if result.ExitCode != 0 {
-			return false, fmt.Errorf("chown failed: %s", result.Stderr)
+			return nil, nil, module.CommandFailedf(result, "chown failed")
 		}
-		changed = true
 	}
 
-	return changed, nil
+	return before, after, nil
 }
 
-// createBackup creates a timestamped backup of a file.
-func createBackup(ctx context.Context, conn connector.Connector, path string) error {
-	timestamp := time.Now().Format("20060102150405")
-	backupPath := fmt.Sprintf("%s.%s.bak", path, timestamp)
+// uploadMode returns the octal mode dest is written with: mode, or for a
+// symbolic mode, mode applied to the mode of dest if it exists and to 0644
+// otherwise.
+func uploadMode(ctx context.Context, conn connector.Connector, dest, mode string, destExists bool) (string, error) {
+	base := "0644"
+	if destExists && param.IsSymbolicMode(mode) {
+		current, _, _, err := getFileAttributes(ctx, conn, dest)
+		if err != nil {
+			return "", fmt.Errorf("failed to get file attributes: %w", err)
+		}
+		base = current
+	}
+	return param.ResolveMode(mode, base, false)
+}
 
-	result, err := conn.Execute(ctx, fmt.Sprintf("cp -p %s %s", shellQuote(path), shellQuote(backupPath)))
+// getFileAttributes returns the mode, owner, and group of a file.
+func getFileAttributes(ctx context.Context, conn connector.Connector, path string) (mode, owner, group string, err error) {
+	// Use stat to get file attributes in a portable way
+	// Format: mode owner group (e.g., "0644 root wheel")
+	cmd := fmt.Sprintf(`stat -c '%%a %%U %%G' %[1]s 2>/dev/null || stat -f '%%Lp %%Su %%Sg' %[1]s`, shellutil.Quote(path))
+
+	result, err := conn.Execute(ctx, cmd)
 	if err != nil {
-		return err
+		return "", "", "", err
 	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("backup failed: %s", result.Stderr)
+		return "", "", "", module.CommandFailedf(result, "stat failed")
 	}
-	return nil
+
+	parts := strings.Fields(strings.TrimSpace(result.Stdout))
+	if len(parts) >= 3 {
+		// Normalize mode to 4 digits with leading zero
+		mode = parts[0]
+		if len(mode) < 4 {
+			mode = strings.Repeat("0", 4-len(mode)) + mode
+		}
+		owner = parts[1]
+		group = parts[2]
+	}
+
+	return mode, owner, group, nil
 }
 
 // parseMode converts an octal mode string to uint32.
@@ -284,63 +424,13 @@ func parseMode(mode string) (uint32, error) {
 	return m, nil
 }
 
-// shellQuote quotes a string for safe use in shell commands.
-func shellQuote(s string) string {
-	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
-}
-
-// Helper functions for parameter extraction
-
-func requireString(params map[string]any, key string) (string, error) {
-	v, ok := params[key]
-	if !ok {
-		return "", fmt.Errorf("required parameter '%s' is missing", key)
-	}
-	s, ok := v.(string)
-	if !ok {
-		return "", fmt.Errorf("parameter '%s' must be a string", key)
-	}
-	if s == "" {
-		return "", fmt.Errorf("parameter '%s' cannot be empty", key)
-	}
-	return s, nil
-}
-
-func getString(params map[string]any, key, defaultValue string) string {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	s, ok := v.(string)
-	if !ok {
-		return defaultValue
-	}
-	return s
-}
-
-func getBool(params map[string]any, key string, defaultValue bool) bool {
-	v, ok := params[key]
-	if !ok {
-		return defaultValue
-	}
-	b, ok := v.(bool)
-	if !ok {
-		return defaultValue
-	}
-	return b
-}
-
-func getMap(params map[string]any, key string) map[string]any {
-	v, ok := params[key]
-	if !ok {
-		return make(map[string]any)
-	}
-	m, ok := v.(map[string]any)
-	if !ok {
-		return make(map[string]any)
-	}
-	return m
+// SupportsCheckMode reports that template can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
 }
 
 // Ensure Module implements the module.Module interface.
-var _ module.Module = (*Module)(nil)
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,430 @@
+// Package timesync provides a module for configuring time synchronization
+// with chrony or systemd-timesyncd.
+package timesync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+	"github.com/eugenetaranov/bolt/pkg/facts"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// TimesyncdDropIn is the drop-in the servers of systemd-timesyncd are
+// written to.
+const TimesyncdDropIn = "/etc/systemd/timesyncd.conf.d/bolt.conf"
+
+// Module configures the NTP servers of chrony or systemd-timesyncd, enables
+// the service, and reports whether the clock is synchronized.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "timesync"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "servers", Type: "list", Description: "NTP servers; empty leaves the configured ones"},
+		{Name: "pool", Type: "bool", Default: false, Description: "Treat the servers as pools of servers (chrony)"},
+		{Name: "implementation", Type: "string", Default: "auto", Choices: []string{"auto", "chrony", "timesyncd"}, Description: "Time sync daemon to configure"},
+		{Name: "config", Type: "string", Description: "Config file to write the servers to (default: chrony.conf, or a timesyncd drop-in)"},
+		{Name: "enabled", Type: "bool", Default: true, Description: "Enable and start the service"},
+	}
+}
+
+// Run executes the timesync module.
+//
+// With implementation=auto, chrony is used if it is installed, and
+// systemd-timesyncd otherwise; neither is installed by the module. The
+// servers replace the server and pool lines of chrony.conf, or are set as
+// NTP= in a drop-in of timesyncd.conf, and the service is restarted when
+// they change. The status is reported in the timesync fact.
+//
+// Parameters:
+//   - servers ([]string): NTP servers; empty leaves the configured ones
+//   - pool (bool): Treat the servers as pools (chrony only, default: false)
+//   - implementation (string): auto, chrony, timesyncd (default: auto)
+//   - config (string): Config file (default: chrony.conf, or /etc/systemd/timesyncd.conf.d/bolt.conf)
+//   - enabled (bool): Enable and start the service (default: true)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	servers := param.StringList(params, "servers")
+	pool := param.Bool(params, "pool", false)
+	impl := param.String(params, "implementation", "auto")
+	config := param.String(params, "config", "")
+	enabled := param.Bool(params, "enabled", true)
+	check := module.IsCheckMode(params)
+
+	switch impl {
+	case "auto", "chrony", "timesyncd":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("implementation", "invalid implementation '%s': must be auto, chrony, or timesyncd", impl)
+	}
+	for _, s := range servers {
+		if s == "" || strings.ContainsAny(s, " \t\n") {
+			return nil, module.ParamErrorf("servers", "invalid server '%s'", s)
+		}
+	}
+
+	d, err := detect(ctx, conn, impl)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+	var before, after map[string]any
+	configChanged := false
+	if len(servers) > 0 {
+		if config == "" {
+			config = d.config
+		}
+		content, exists, err := module.ReadFile(ctx, conn, config)
+		if err != nil {
+			return nil, err
+		}
+		newContent := d.render(content, servers, pool)
+		if !exists || !bytes.Equal(newContent, content) {
+			configChanged = true
+			before = map[string]any{"servers": d.servers(content)}
+			after = map[string]any{"servers": servers}
+			if !check {
+				if err := module.WriteFile(ctx, conn, config, newContent, ""); err != nil {
+					return nil, err
+				}
+				if !exists {
+					// systemd-timesyncd reads its config as its own user
+					if err := module.RunCommand(ctx, conn, "chmod 0644 "+shellutil.Quote(config)); err != nil {
+						return nil, err
+					}
+				}
+			}
+			messages = append(messages, fmt.Sprintf("set servers in %s", config))
+		}
+	}
+
+	svc, err := newService(ctx, conn, d.name)
+	if err != nil {
+		return nil, err
+	}
+	active, err := svc.active(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case enabled && !active:
+		if !check {
+			if err := svc.enable(ctx); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, fmt.Sprintf("enabled %s", d.name))
+	case configChanged && active:
+		if !check {
+			if err := svc.restart(ctx); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, fmt.Sprintf("restarted %s", d.name))
+	}
+
+	status := map[string]any{"implementation": d.name}
+	if !check || active {
+		if status, err = d.status(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+	result := module.Unchanged(fmt.Sprintf("%s is configured", d.name))
+	if len(messages) > 0 {
+		msg := strings.Join(messages, "; ")
+		if check {
+			msg = "would " + strings.Join(messages, "; would ")
+		}
+		result = module.Changed(msg)
+	}
+	if before != nil || after != nil {
+		result = result.WithDiff(before, after)
+	}
+	result.Data = status
+	return result.WithFacts(map[string]any{"timesync": status}), nil
+}
+
+// daemon is a time sync daemon.
+type daemon struct {
+	// name is chrony or timesyncd.
+	name string
+
+	// config is the default config file for the servers.
+	config string
+}
+
+// detect returns the daemon to configure: impl, or with auto, chrony if
+// it is installed and timesyncd otherwise.
+func detect(ctx context.Context, conn connector.Connector, impl string) (*daemon, error) {
+	result, err := conn.Execute(ctx, `PATH="$PATH:/usr/sbin:/sbin"
+if command -v chronyd >/dev/null; then
+  if [ -f /etc/chrony/chrony.conf ]; then echo chrony /etc/chrony/chrony.conf; else echo chrony /etc/chrony.conf; fi
+fi
+for f in /lib/systemd/systemd-timesyncd /usr/lib/systemd/systemd-timesyncd; do
+  if [ -x "$f" ]; then echo timesyncd; break; fi
+done`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect the time sync daemon: %w", err)
+	}
+
+	found := map[string]*daemon{}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "chrony":
+			found["chrony"] = &daemon{name: "chrony", config: fields[1]}
+		case len(fields) == 1 && fields[0] == "timesyncd":
+			found["timesyncd"] = &daemon{name: "timesyncd", config: TimesyncdDropIn}
+		}
+	}
+
+	if impl == "auto" {
+		if d, ok := found["chrony"]; ok {
+			return d, nil
+		}
+		if d, ok := found["timesyncd"]; ok {
+			return d, nil
+		}
+		return nil, fmt.Errorf("neither chrony nor systemd-timesyncd is installed (install chrony with the package manager)")
+	}
+	d, ok := found[impl]
+	if !ok {
+		return nil, fmt.Errorf("%s is not installed", impl)
+	}
+	return d, nil
+}
+
+// isServerLine reports whether a chrony.conf line is a server or pool
+// directive.
+func isServerLine(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 1 && (fields[0] == "server" || fields[0] == "pool")
+}
+
+// servers returns the servers configured in content.
+func (d *daemon) servers(content []byte) []string {
+	var servers []string
+	for _, line := range strings.Split(string(content), "\n") {
+		switch d.name {
+		case "chrony":
+			if isServerLine(line) {
+				servers = append(servers, strings.Fields(line)[1])
+			}
+		case "timesyncd":
+			if v, ok := strings.CutPrefix(strings.TrimSpace(line), "NTP="); ok {
+				servers = strings.Fields(v)
+			}
+		}
+	}
+	return servers
+}
+
+// render returns content with servers configured. chrony.conf keeps its
+// other lines, with the servers where the first server or pool line was;
+// the timesyncd drop-in is written whole.
+func (d *daemon) render(content []byte, servers []string, pool bool) []byte {
+	if d.name == "timesyncd" {
+		return []byte(fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " ")))
+	}
+
+	directive := "server"
+	if pool {
+		directive = "pool"
+	}
+	var serverLines []string
+	for _, s := range servers {
+		serverLines = append(serverLines, fmt.Sprintf("%s %s iburst", directive, s))
+	}
+
+	var lines []string
+	inserted := false
+	if len(content) > 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			if !isServerLine(line) {
+				lines = append(lines, line)
+				continue
+			}
+			if !inserted {
+				lines = append(lines, serverLines...)
+				inserted = true
+			}
+		}
+	}
+	if !inserted {
+		lines = append(lines, serverLines...)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// status returns whether the clock is synchronized, and the source, as
+// the daemon reports them.
+func (d *daemon) status(ctx context.Context, conn connector.Connector) (map[string]any, error) {
+	status := map[string]any{"implementation": d.name, "synchronized": false}
+	if d.name == "timesyncd" {
+		result, err := conn.Execute(ctx, "timedatectl show -p NTPSynchronized --value; timedatectl show-timesync -p ServerName --value")
+		if err != nil {
+			return nil, fmt.Errorf("failed to run timedatectl: %w", err)
+		}
+		lines := strings.Split(result.Stdout, "\n")
+		status["synchronized"] = strings.TrimSpace(lines[0]) == "yes"
+		if len(lines) > 1 {
+			status["server"] = strings.TrimSpace(lines[1])
+		}
+		return status, nil
+	}
+
+	result, err := conn.Execute(ctx, `PATH="$PATH:/usr/sbin:/sbin" chronyc tracking`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run chronyc: %w", err)
+	}
+	if result.ExitCode != 0 {
+		// chronyd is not running
+		return status, nil
+	}
+	for k, v := range parseTracking(result.Stdout) {
+		status[k] = v
+	}
+	return status, nil
+}
+
+// parseTracking parses the output of chronyc tracking:
+//
+//	Reference ID    : A29FC87B (time.cloudflare.com)
+//	Stratum         : 4
+//	System time     : 0.000012085 seconds slow of NTP time
+//	Leap status     : Normal
+func parseTracking(out string) map[string]any {
+	status := map[string]any{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Reference ID":
+			if i := strings.Index(value, "("); i >= 0 {
+				status["server"] = strings.TrimSuffix(value[i+1:], ")")
+			}
+		case "Stratum":
+			if n, err := strconv.Atoi(value); err == nil {
+				status["stratum"] = n
+			}
+		case "System time":
+			fields := strings.Fields(value)
+			if len(fields) >= 3 {
+				if offset, err := strconv.ParseFloat(fields[0], 64); err == nil {
+					// Positive when the clock is ahead
+					if fields[2] == "slow" {
+						offset = -offset
+					}
+					status["offset"] = offset
+				}
+			}
+		case "Leap status":
+			status["synchronized"] = value != "Not synchronised"
+		}
+	}
+	return status
+}
+
+// service manages the service of a daemon.
+type service struct {
+	conn connector.Connector
+	mgr  string
+
+	// unit is the name of the service: chrony on Debian, chronyd
+	// elsewhere, or systemd-timesyncd.
+	unit string
+}
+
+// newService returns the service of the daemon name. Only systemd, and
+// OpenRC for chrony, are supported.
+func newService(ctx context.Context, conn connector.Connector, name string) (*service, error) {
+	mgr, err := facts.ServiceManager(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	s := &service{conn: conn, mgr: mgr}
+	switch {
+	case name == "timesyncd" && mgr == facts.ServiceSystemd:
+		s.unit = "systemd-timesyncd"
+	case name == "chrony" && mgr == facts.ServiceSystemd:
+		result, err := conn.Execute(ctx, "systemctl cat chrony.service >/dev/null 2>&1")
+		if err != nil {
+			return nil, err
+		}
+		s.unit = "chronyd"
+		if result.ExitCode == 0 {
+			s.unit = "chrony"
+		}
+	case name == "chrony" && mgr == facts.ServiceOpenRC:
+		s.unit = "chronyd"
+	default:
+		return nil, fmt.Errorf("%s cannot be managed with the service manager %s", name, mgr)
+	}
+	return s, nil
+}
+
+// active reports whether the service is enabled and running.
+func (s *service) active(ctx context.Context) (bool, error) {
+	unit := shellutil.Quote(s.unit)
+	cmd := fmt.Sprintf("systemctl is-enabled --quiet %[1]s && systemctl is-active --quiet %[1]s", unit)
+	if s.mgr == facts.ServiceOpenRC {
+		cmd = fmt.Sprintf("rc-update show default | grep -qw %[1]s && rc-service %[1]s status >/dev/null", unit)
+	}
+	result, err := s.conn.Execute(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", s.unit, err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+// enable enables and starts the service.
+func (s *service) enable(ctx context.Context) error {
+	unit := shellutil.Quote(s.unit)
+	cmd := "systemctl enable --now " + unit
+	switch {
+	case s.mgr == facts.ServiceOpenRC:
+		cmd = fmt.Sprintf("rc-update add %[1]s default && rc-service %[1]s start", unit)
+	case s.unit == "systemd-timesyncd":
+		// Also tells timedated, which refuses while chrony is enabled
+		cmd = "timedatectl set-ntp true && " + cmd
+	}
+	return module.RunCommand(ctx, s.conn, cmd)
+}
+
+// restart restarts the service to read the new servers.
+func (s *service) restart(ctx context.Context) error {
+	if s.mgr == facts.ServiceOpenRC {
+		return module.RunCommand(ctx, s.conn, "rc-service "+shellutil.Quote(s.unit)+" restart")
+	}
+	return module.RunCommand(ctx, s.conn, "systemctl restart "+shellutil.Quote(s.unit))
+}
+
+// SupportsCheckMode reports that timesync can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,244 @@
+package module
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// uploadAttempts is how often UploadVerified uploads a file whose remote
+// checksum does not match before giving up.
+const uploadAttempts = 2
+
+// UploadVerified uploads content to dst and verifies that the SHA256
+// checksum of the remote file matches, so a transfer truncated by a flaky
+// connection is caught instead of leaving a corrupt file behind. A
+// mismatching upload is repeated once. Targets without sha256sum or shasum
+// are not verified.
+func UploadVerified(ctx context.Context, conn connector.Connector, content []byte, dst string, mode uint32) error {
+	return verified(ctx, conn, checksum(content), dst, func() error {
+		return conn.Upload(ctx, bytes.NewReader(content), dst, mode)
+	})
+}
+
+// UploadFileVerified is UploadVerified for the local file src, which is
+// streamed from disk instead of read into memory, so files of any size
+// can be uploaded. sum is the SHA256 checksum of src (see FileChecksum).
+func UploadFileVerified(ctx context.Context, conn connector.Connector, src, sum, dst string, mode uint32) error {
+	return verified(ctx, conn, sum, dst, func() error {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return conn.Upload(ctx, f, dst, mode)
+	})
+}
+
+// FileChecksum returns the SHA256 checksum of the local file path, read
+// in chunks.
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksum returns the SHA256 checksum of content.
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// verified runs upload until the SHA256 checksum of dst is want, at most
+// uploadAttempts times.
+func verified(ctx context.Context, conn connector.Connector, want, dst string, upload func() error) error {
+	var got string
+	for attempt := 1; attempt <= uploadAttempts; attempt++ {
+		if err := upload(); err != nil {
+			return err
+		}
+
+		var err error
+		got, err = RemoteChecksum(ctx, conn, dst)
+		if err != nil {
+			return fmt.Errorf("failed to verify upload: %w", err)
+		}
+		if got == "" || got == want {
+			return nil
+		}
+	}
+
+	return &connector.ConnectivityError{
+		Target: conn.String(),
+		Err:    fmt.Errorf("checksum mismatch after uploading %s: expected %s, got %s", dst, want, got),
+	}
+}
+
+// UploadCompressed is UploadVerified with the content gzip-compressed on
+// the way: it is uploaded next to dst and decompressed there with gzip.
+// It reports false, and uploads uncompressed, if the target has no gzip
+// or the content does not compress.
+func UploadCompressed(ctx context.Context, conn connector.Connector, content []byte, dst string, mode uint32) (bool, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return false, err
+	}
+	if err := zw.Close(); err != nil {
+		return false, err
+	}
+	if buf.Len() >= len(content) || !hasCommand(ctx, conn, "gzip") {
+		return false, UploadVerified(ctx, conn, content, dst, mode)
+	}
+
+	err := uploadGzip(ctx, conn, checksum(content), dst, mode, func(gz string) error {
+		return conn.Upload(ctx, bytes.NewReader(buf.Bytes()), gz, 0600)
+	})
+	return err == nil, err
+}
+
+// UploadFileCompressed is UploadCompressed for the local file src, with
+// sum its SHA256 checksum. The file is compressed while it is streamed, so
+// unlike UploadCompressed it does not find out first whether the content
+// compresses. It reports false, and uploads uncompressed, if the target
+// has no gzip.
+func UploadFileCompressed(ctx context.Context, conn connector.Connector, src, sum, dst string, mode uint32) (bool, error) {
+	if !hasCommand(ctx, conn, "gzip") {
+		return false, UploadFileVerified(ctx, conn, src, sum, dst, mode)
+	}
+
+	err := uploadGzip(ctx, conn, sum, dst, mode, func(gz string) error {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		pr, pw := io.Pipe()
+		go func() {
+			zw := gzip.NewWriter(pw)
+			_, err := io.Copy(zw, f)
+			if err == nil {
+				err = zw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		err = conn.Upload(ctx, pr, gz, 0600)
+		// Stop the compressor if the upload ended early
+		pr.CloseWithError(io.ErrClosedPipe)
+		return err
+	})
+	return err == nil, err
+}
+
+// uploadGzip runs send to upload gzip-compressed content to a temp file
+// next to dst, decompresses it into dst, and verifies the result against
+// the checksum of the uncompressed content.
+func uploadGzip(ctx context.Context, conn connector.Connector, sum, dst string, mode uint32, send func(gz string) error) error {
+	gz := fmt.Sprintf("%s.bolt-%d.gz", dst, time.Now().UnixNano())
+	part := strings.TrimSuffix(gz, ".gz")
+	cmd := fmt.Sprintf("gzip -dc %[1]s > %[2]s && chmod %[3]o %[2]s && mv -f %[2]s %[4]s; rc=$?; rm -f %[1]s %[2]s; exit $rc",
+		shellutil.Quote(gz), shellutil.Quote(part), mode, shellutil.Quote(dst))
+
+	return verified(ctx, conn, sum, dst, func() error {
+		if err := send(gz); err != nil {
+			return err
+		}
+		result, err := conn.Execute(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		if result.ExitCode != 0 {
+			return CommandFailedf(result, "failed to decompress %s", dst)
+		}
+		return nil
+	})
+}
+
+// UploadDelta transfers the local file src to dst with rsync, which only
+// sends the parts that differ from the file already at dst, and verifies
+// the result against sum, the SHA256 checksum of src. With compress, rsync
+// compresses what it sends.
+//
+// It reports false without transferring anything if the connector cannot
+// run rsync to the target (see connector.Rsyncer) or rsync is missing on
+// either end.
+func UploadDelta(ctx context.Context, conn connector.Connector, src, sum, dst string, mode uint32, compress bool) (bool, error) {
+	rsyncer, ok := connector.As[connector.Rsyncer](conn)
+	if !ok {
+		return false, nil
+	}
+	if _, err := exec.LookPath("rsync"); err != nil || !hasCommand(ctx, conn, "rsync") {
+		return false, nil
+	}
+
+	rsh, host := rsyncer.RsyncShell()
+	args := []string{"--no-whole-file", "--protect-args", "--blocking-io", "-e", rsh}
+	if compress {
+		args = append(args, "--compress")
+	}
+	args = append(args, "--", src, host+":"+dst)
+
+	err := verified(ctx, conn, sum, dst, func() error {
+		if output, err := exec.CommandContext(ctx, "rsync", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("rsync to %s failed: %s: %w", conn, strings.TrimSpace(string(output)), err)
+		}
+		result, err := conn.Execute(ctx, fmt.Sprintf("chmod %o %s", mode, shellutil.Quote(dst)))
+		if err != nil {
+			return err
+		}
+		if result.ExitCode != 0 {
+			return CommandFailedf(result, "failed to set mode of %s", dst)
+		}
+		return nil
+	})
+	return err == nil, err
+}
+
+// hasCommand reports whether name is installed on the target.
+func hasCommand(ctx context.Context, conn connector.Connector, name string) bool {
+	result, err := conn.Execute(ctx, "command -v "+name)
+	return err == nil && result.ExitCode == 0
+}
+
+// RemoteChecksum returns the SHA256 checksum of a file on the target, or
+// an empty string if the target has no tool to compute it.
+func RemoteChecksum(ctx context.Context, conn connector.Connector, path string) (string, error) {
+	quoted := shellutil.Quote(path)
+	cmd := fmt.Sprintf(`if command -v sha256sum >/dev/null 2>&1; then
+	sum=$(sha256sum %[1]s) || exit 1
+elif command -v shasum >/dev/null 2>&1; then
+	sum=$(shasum -a 256 %[1]s) || exit 1
+else
+	exit 0
+fi
+echo "${sum%%%% *}"`, quoted)
+
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", CommandFailedf(result, "failed to checksum %s", path)
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
@@ -0,0 +1,159 @@
+package module
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestUploadVerified(t *testing.T) {
+	content := []byte("hello\n")
+	// sha256 of "hello\n"
+	sum := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+	tests := []struct {
+		name        string
+		remote      []string // checksums reported after each upload
+		wantErr     bool
+		wantUploads int
+	}{
+		{"match", []string{sum}, false, 1},
+		{"no checksum tool", []string{""}, false, 1},
+		{"mismatch then match", []string{"truncated", sum}, false, 2},
+		{"mismatch", []string{"truncated", "truncated"}, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			for _, remote := range tt.remote {
+				conn.OnPrefix("if command -v sha256sum").Return(remote + "\n").Once()
+			}
+
+			err := UploadVerified(context.Background(), conn, content, "/etc/motd", 0644)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UploadVerified() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var connErr *connector.ConnectivityError
+			if tt.wantErr && !errors.As(err, &connErr) {
+				t.Errorf("error = %T, want *connector.ConnectivityError", err)
+			}
+			if got := len(conn.Commands()); got != tt.wantUploads {
+				t.Errorf("verified %d uploads, want %d", got, tt.wantUploads)
+			}
+			if data, _ := conn.File("/etc/motd"); string(data) != string(content) {
+				t.Errorf("uploaded %q", data)
+			}
+		})
+	}
+}
+
+func TestUploadCompressed(t *testing.T) {
+	content := []byte(strings.Repeat("listen 80;\n", 100))
+
+	t.Run("compressed", func(t *testing.T) {
+		conn := connectortest.New()
+		conn.On("command -v gzip").Return("/usr/bin/gzip\n")
+		conn.OnPrefix("if command -v sha256sum").Return("")
+		conn.OnPrefix("gzip -dc ").Return("")
+
+		ok, err := UploadCompressed(context.Background(), conn, content, "/etc/nginx.conf", 0644)
+		if err != nil || !ok {
+			t.Fatalf("UploadCompressed() = %v, %v", ok, err)
+		}
+		var gz string
+		for _, cmd := range conn.Commands() {
+			if strings.HasPrefix(cmd, "gzip -dc ") {
+				gz = strings.Trim(strings.Fields(cmd)[2], "'")
+			}
+		}
+		if !strings.HasPrefix(gz, "/etc/nginx.conf.bolt-") {
+			t.Fatalf("decompressed %q, commands: %v", gz, conn.Commands())
+		}
+		data, _ := conn.File(gz)
+		if len(data) >= len(content) {
+			t.Errorf("uploaded %d bytes for %d bytes of content", len(data), len(content))
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(zr); !bytes.Equal(got, content) {
+			t.Errorf("uploaded %q", got)
+		}
+	})
+
+	t.Run("no gzip", func(t *testing.T) {
+		conn := connectortest.New()
+		conn.On("command -v gzip").Fail(1, "")
+		conn.OnPrefix("if command -v sha256sum").Return("")
+
+		ok, err := UploadCompressed(context.Background(), conn, content, "/etc/nginx.conf", 0644)
+		if err != nil || ok {
+			t.Fatalf("UploadCompressed() = %v, %v, want an uncompressed upload", ok, err)
+		}
+		if data, _ := conn.File("/etc/nginx.conf"); !bytes.Equal(data, content) {
+			t.Errorf("uploaded %q", data)
+		}
+	})
+}
+
+func TestUploadFile(t *testing.T) {
+	content := []byte(strings.Repeat("listen 80;\n", 100))
+	src := filepath.Join(t.TempDir(), "nginx.conf")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := FileChecksum(src)
+	if err != nil || sum != checksum(content) {
+		t.Fatalf("FileChecksum() = %q, %v", sum, err)
+	}
+
+	t.Run("verified", func(t *testing.T) {
+		conn := connectortest.New()
+		conn.OnPrefix("if command -v sha256sum").Return("truncated\n").Once()
+		conn.OnPrefix("if command -v sha256sum").Return(sum + "\n")
+
+		if err := UploadFileVerified(context.Background(), conn, src, sum, "/etc/nginx.conf", 0644); err != nil {
+			t.Fatalf("UploadFileVerified() error = %v", err)
+		}
+		if data, _ := conn.File("/etc/nginx.conf"); !bytes.Equal(data, content) {
+			t.Errorf("uploaded %q", data)
+		}
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		conn := connectortest.New()
+		conn.On("command -v gzip").Return("/usr/bin/gzip\n")
+		conn.OnPrefix("if command -v sha256sum").Return("")
+		conn.OnPrefix("gzip -dc ").Return("")
+
+		ok, err := UploadFileCompressed(context.Background(), conn, src, sum, "/etc/nginx.conf", 0644)
+		if err != nil || !ok {
+			t.Fatalf("UploadFileCompressed() = %v, %v", ok, err)
+		}
+		var gz string
+		for _, cmd := range conn.Commands() {
+			if strings.HasPrefix(cmd, "gzip -dc ") {
+				gz = strings.Trim(strings.Fields(cmd)[2], "'")
+			}
+		}
+		data, _ := conn.File(gz)
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("uploaded %q: %v", data, err)
+		}
+		if got, _ := io.ReadAll(zr); !bytes.Equal(got, content) {
+			t.Errorf("uploaded %q", got)
+		}
+	})
+}
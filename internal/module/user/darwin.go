@@ -0,0 +1,175 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// darwin manages accounts in the local directory of macOS. sysadminctl
+// creates and deletes them, as it also sets up the password and the
+// secure token; dscl and dseditgroup change them.
+type darwin struct {
+	conn connector.Connector
+}
+
+func (d *darwin) lookup(ctx context.Context, name string, _ bool) (*account, error) {
+	q := shellutil.Quote(name)
+	// Supplementary groups are the groups that list the user as a member;
+	// id -Gn also reports groups like everyone that nobody is added to
+	cmd := fmt.Sprintf(`dscl . -read /Users/%[1]s UniqueID PrimaryGroupID NFSHomeDirectory UserShell 2>/dev/null || exit 2
+echo "Group: $(id -gn %[1]s)"
+dscl . -list /Groups GroupMembership | awk -v u=%[1]s '{ for (i = 2; i <= NF; i++) if ($i == u) print "Member: " $1 }'`, q)
+	result, err := d.conn.Execute(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user %s: %w", name, err)
+	}
+	if result.ExitCode == 2 {
+		return nil, nil
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "failed to look up user %s", name)
+	}
+
+	a := &account{groups: []string{}}
+	for k, v := range parseRecord(result.Stdout) {
+		switch k {
+		case "UniqueID":
+			a.uid, _ = strconv.Atoi(v[0])
+		case "PrimaryGroupID":
+			a.gid, _ = strconv.Atoi(v[0])
+		case "NFSHomeDirectory":
+			a.home = v[0]
+		case "UserShell":
+			a.shell = v[0]
+		case "Group":
+			a.group = v[0]
+		case "Member":
+			a.groups = v
+		}
+	}
+	a.groups = supplementary(a.groups, a.group)
+	return a, nil
+}
+
+func (d *darwin) create(ctx context.Context, name string, want *spec) error {
+	args := []string{"sysadminctl", "-addUser", name}
+	if want.uid >= 0 {
+		args = append(args, "-UID", strconv.Itoa(want.uid))
+	}
+	if want.shell != "" {
+		args = append(args, "-shell", want.shell)
+	}
+	if want.home != "" {
+		args = append(args, "-home", want.home)
+	}
+	if want.system {
+		args = append(args, "-roleAccount")
+	}
+	if err := module.RunArgv(ctx, d.conn, args...); err != nil {
+		return err
+	}
+
+	c := &changes{uid: -1, group: want.group}
+	if want.setGroups {
+		c.addGroups = want.groups
+	}
+	if err := d.modify(ctx, name, c); err != nil {
+		return err
+	}
+	if want.createHome {
+		// sysadminctl leaves the home directory to the first login
+		return module.RunCommand(ctx, d.conn, "createhomedir -c -u "+shellutil.Quote(name)+" >/dev/null")
+	}
+	return nil
+}
+
+func (d *darwin) modify(ctx context.Context, name string, c *changes) error {
+	record := shellutil.Quote("/Users/" + name)
+	var cmds []string
+	if c.uid >= 0 {
+		cmds = append(cmds, fmt.Sprintf("dscl . -create %s UniqueID %d", record, c.uid))
+	}
+	if c.group != "" {
+		gid, err := d.gid(ctx, c.group)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, fmt.Sprintf("dscl . -create %s PrimaryGroupID %d", record, gid))
+	}
+	if c.shell != "" {
+		cmds = append(cmds, fmt.Sprintf("dscl . -create %s UserShell %s", record, shellutil.Quote(c.shell)))
+	}
+	if c.home != "" {
+		cmds = append(cmds, fmt.Sprintf("dscl . -create %s NFSHomeDirectory %s", record, shellutil.Quote(c.home)))
+	}
+	for _, g := range c.addGroups {
+		cmds = append(cmds, shellutil.Join("dseditgroup", "-o", "edit", "-a", name, "-t", "user", g))
+	}
+	for _, g := range c.removeGroups {
+		cmds = append(cmds, shellutil.Join("dseditgroup", "-o", "edit", "-d", name, "-t", "user", g))
+	}
+	for _, cmd := range cmds {
+		if err := module.RunCommand(ctx, d.conn, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *darwin) remove(ctx context.Context, name string) error {
+	return module.RunArgv(ctx, d.conn, "sysadminctl", "-deleteUser", name, "-keepHome")
+}
+
+// gid returns the ID of group, given by name or ID.
+func (d *darwin) gid(ctx context.Context, group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	result, err := d.conn.Execute(ctx, "dscl . -read "+shellutil.Quote("/Groups/"+group)+" PrimaryGroupID")
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up group %s: %w", group, err)
+	}
+	if v := parseRecord(result.Stdout)["PrimaryGroupID"]; result.ExitCode == 0 && len(v) > 0 {
+		if gid, err := strconv.Atoi(v[0]); err == nil {
+			return gid, nil
+		}
+	}
+	return 0, fmt.Errorf("group %s does not exist", group)
+}
+
+// parseRecord parses the output of dscl -read. A value that does not fit
+// on the line of its key follows on the next line, indented:
+//
+//	NFSHomeDirectory: /Users/alice
+//	UserShell:
+//	 /bin/zsh
+//
+// Keys that appear more than once, like the Member lines of lookup, keep
+// all their values.
+func parseRecord(out string) map[string][]string {
+	record := map[string][]string{}
+	key := ""
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, " ") {
+			if key != "" {
+				record[key] = append(record[key], strings.TrimSpace(line))
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = k
+		if v = strings.TrimSpace(v); v != "" {
+			record[key] = append(record[key], v)
+		}
+	}
+	return record
+}
@@ -0,0 +1,498 @@
+// Package user provides a module for managing system accounts.
+package user
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// Module creates, changes, and removes user accounts, with useradd and
+// usermod on Linux and dscl and sysadminctl on macOS.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "user"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "User name"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "uid", Type: "int", Description: "User ID"},
+		{Name: "group", Type: "string", Description: "Primary group, by name or ID"},
+		{Name: "groups", Type: "list", Description: "Supplementary groups"},
+		{Name: "append", Type: "bool", Default: false, Description: "Add the groups instead of replacing the supplementary groups"},
+		{Name: "shell", Type: "string", Description: "Login shell"},
+		{Name: "home", Type: "string", Description: "Home directory"},
+		{Name: "create_home", Type: "bool", Default: true, Description: "Create the home directory when creating the user"},
+		{Name: "password", Type: "string", Description: "Password hash, as in /etc/shadow (Linux)"},
+		{Name: "system", Type: "bool", Default: false, Description: "Create a system account"},
+	}
+}
+
+// Run executes the user module.
+//
+// Only the parameters given are managed, so an existing account keeps its
+// other settings; create_home and system only apply when the account is
+// created. The home directory is not moved when home changes, and it is
+// kept when the account is removed.
+//
+// Parameters:
+//   - name (string, required): User name
+//   - state (string): Desired state - present, absent (default: present)
+//   - uid (int): User ID
+//   - group (string): Primary group, by name or ID
+//   - groups ([]string): Supplementary groups; an empty list removes them all unless append is set
+//   - append (bool): Add the groups instead of replacing them (default: false)
+//   - shell (string): Login shell
+//   - home (string): Home directory
+//   - create_home (bool): Create the home directory with the user (default: true)
+//   - password (string): Password hash, e.g. from mkpasswd -m sha-512 (Linux only)
+//   - system (bool): Create a system account (default: false)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+	state := param.String(params, "state", "present")
+	check := module.IsCheckMode(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if strings.HasPrefix(name, "-") || strings.ContainsAny(name, ": \t\n/") {
+		return nil, module.ParamErrorf("name", "invalid user name '%s'", name)
+	}
+
+	want := spec{
+		uid:        -1,
+		group:      param.String(params, "group", ""),
+		groups:     param.StringList(params, "groups"),
+		append:     param.Bool(params, "append", false),
+		shell:      param.String(params, "shell", ""),
+		home:       param.String(params, "home", ""),
+		createHome: param.Bool(params, "create_home", true),
+		password:   param.String(params, "password", ""),
+		system:     param.Bool(params, "system", false),
+	}
+	if _, ok := params["uid"]; ok {
+		want.uid = param.Int(params, "uid", -1)
+		if want.uid < 0 {
+			return nil, module.ParamErrorf("uid", "invalid uid: must be a non-negative integer")
+		}
+	}
+	_, want.setGroups = params["groups"]
+
+	osName, err := module.TargetOS(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var sys accounts
+	switch osName {
+	case "Linux":
+		sys = &linux{conn: conn}
+	case "Darwin":
+		if want.password != "" {
+			return nil, module.ParamErrorf("password", "password hashes cannot be set on macOS")
+		}
+		sys = &darwin{conn: conn}
+	default:
+		return nil, fmt.Errorf("user accounts cannot be managed on %s", osName)
+	}
+
+	current, err := sys.lookup(ctx, name, want.password != "")
+	if err != nil {
+		return nil, err
+	}
+
+	if state == "absent" {
+		if current == nil {
+			return module.Unchanged(fmt.Sprintf("user %s already absent", name)), nil
+		}
+		before := current.diffMap()
+		if check {
+			return module.Changed(fmt.Sprintf("would remove user %s", name)).WithDiff(before, nil), nil
+		}
+		if err := sys.remove(ctx, name); err != nil {
+			return nil, err
+		}
+		return module.Changed(fmt.Sprintf("removed user %s", name)).WithDiff(before, nil), nil
+	}
+
+	if current == nil {
+		after := want.diffMap()
+		if check {
+			return module.Changed(fmt.Sprintf("would create user %s", name)).WithDiff(nil, after), nil
+		}
+		if err := sys.create(ctx, name, &want); err != nil {
+			return nil, err
+		}
+		return changed(ctx, sys, name, fmt.Sprintf("created user %s", name), nil, after)
+	}
+
+	c := current.changes(&want)
+	if c.empty() {
+		result := module.Unchanged(fmt.Sprintf("user %s already in desired state", name))
+		result.Data = current.data()
+		return result, nil
+	}
+	if check {
+		return module.Changed(fmt.Sprintf("would change %s of user %s", strings.Join(c.fields, ", "), name)).WithDiff(c.before, c.after), nil
+	}
+	if err := sys.modify(ctx, name, c); err != nil {
+		return nil, err
+	}
+	return changed(ctx, sys, name, fmt.Sprintf("changed %s of user %s", strings.Join(c.fields, ", "), name), c.before, c.after)
+}
+
+// changed returns a changed result with the account as it is now.
+func changed(ctx context.Context, sys accounts, name, msg string, before, after any) (*module.Result, error) {
+	a, err := sys.lookup(ctx, name, false)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, fmt.Errorf("user %s is missing after the change", name)
+	}
+	return module.ChangedWithData(msg, a.data()).WithDiff(before, after), nil
+}
+
+// accounts manages the user accounts of an OS.
+type accounts interface {
+	// lookup returns the account name, or nil if there is none. The
+	// password hash is read if password is set.
+	lookup(ctx context.Context, name string, password bool) (*account, error)
+
+	// create creates the account name.
+	create(ctx context.Context, name string, want *spec) error
+
+	// modify applies changes to the account name.
+	modify(ctx context.Context, name string, c *changes) error
+
+	// remove removes the account name, keeping its home directory.
+	remove(ctx context.Context, name string) error
+}
+
+// account is an existing user account.
+type account struct {
+	uid      int
+	gid      int
+	group    string
+	groups   []string
+	home     string
+	shell    string
+	password string
+}
+
+// data returns the account as registered data.
+func (a *account) data() map[string]any {
+	return map[string]any{
+		"uid":    a.uid,
+		"gid":    a.gid,
+		"group":  a.group,
+		"groups": a.groups,
+		"home":   a.home,
+		"shell":  a.shell,
+	}
+}
+
+// diffMap returns the account for Result.Before.
+func (a *account) diffMap() map[string]any {
+	m := a.data()
+	delete(m, "gid")
+	return m
+}
+
+// hasGroup reports whether group, a name or ID, is the primary group.
+func (a *account) hasGroup(group string) bool {
+	return group == a.group || group == strconv.Itoa(a.gid)
+}
+
+// changes returns the changes that make the account match want.
+func (a *account) changes(want *spec) *changes {
+	c := &changes{uid: -1, before: map[string]any{}, after: map[string]any{}}
+	if want.uid >= 0 && want.uid != a.uid {
+		c.uid = want.uid
+		c.add("uid", a.uid, want.uid)
+	}
+	if want.group != "" && !a.hasGroup(want.group) {
+		c.group = want.group
+		c.add("group", a.group, want.group)
+	}
+	if want.shell != "" && want.shell != a.shell {
+		c.shell = want.shell
+		c.add("shell", a.shell, want.shell)
+	}
+	if want.home != "" && want.home != a.home {
+		c.home = want.home
+		c.add("home", a.home, want.home)
+	}
+	if want.password != "" && want.password != a.password {
+		c.password = want.password
+		c.add("password", "(old hash)", "(new hash)")
+	}
+
+	if want.setGroups {
+		for _, g := range want.groups {
+			if !slices.Contains(a.groups, g) && !slices.Contains(c.addGroups, g) {
+				c.addGroups = append(c.addGroups, g)
+			}
+		}
+		if !want.append {
+			for _, g := range a.groups {
+				if !slices.Contains(want.groups, g) {
+					c.removeGroups = append(c.removeGroups, g)
+				}
+			}
+		}
+		if len(c.addGroups) > 0 || len(c.removeGroups) > 0 {
+			var groups []string
+			for _, g := range a.groups {
+				if !slices.Contains(c.removeGroups, g) {
+					groups = append(groups, g)
+				}
+			}
+			c.groups = append(groups, c.addGroups...)
+			sort.Strings(c.groups)
+			c.add("groups", a.groups, c.groups)
+		}
+	}
+	return c
+}
+
+// spec is the desired account. Empty strings and a uid of -1 are not
+// managed.
+type spec struct {
+	uid        int
+	group      string
+	groups     []string
+	setGroups  bool
+	append     bool
+	shell      string
+	home       string
+	createHome bool
+	password   string
+	system     bool
+}
+
+// diffMap returns the managed settings for Result.After.
+func (s *spec) diffMap() map[string]any {
+	m := map[string]any{}
+	if s.uid >= 0 {
+		m["uid"] = s.uid
+	}
+	for k, v := range map[string]string{"group": s.group, "shell": s.shell, "home": s.home} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	if len(s.groups) > 0 {
+		m["groups"] = s.groups
+	}
+	if s.password != "" {
+		m["password"] = "(new hash)"
+	}
+	if s.system {
+		m["system"] = true
+	}
+	return m
+}
+
+// changes are the settings of an account to change. Empty strings and a
+// uid of -1 are left as they are.
+type changes struct {
+	uid      int
+	group    string
+	shell    string
+	home     string
+	password string
+
+	// groups are the new supplementary groups, made by adding addGroups
+	// and removing removeGroups.
+	groups       []string
+	addGroups    []string
+	removeGroups []string
+
+	fields        []string
+	before, after map[string]any
+}
+
+// add records a changed field for the message and the diff.
+func (c *changes) add(field string, before, after any) {
+	c.fields = append(c.fields, field)
+	c.before[field], c.after[field] = before, after
+}
+
+// empty reports whether nothing changes.
+func (c *changes) empty() bool {
+	return len(c.fields) == 0
+}
+
+// linux manages accounts with the shadow utilities.
+type linux struct {
+	conn connector.Connector
+}
+
+func (l *linux) lookup(ctx context.Context, name string, password bool) (*account, error) {
+	q := shellutil.Quote(name)
+	result, err := l.conn.Execute(ctx, fmt.Sprintf("getent passwd %[1]s || exit 2; id -gn %[1]s; id -Gn %[1]s", q))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user %s: %w", name, err)
+	}
+	if result.ExitCode == 2 {
+		return nil, nil
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "failed to look up user %s", name)
+	}
+
+	// alice:x:1000:1000:Alice:/home/alice:/bin/bash
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	fields := strings.Split(lines[0], ":")
+	if len(lines) < 3 || len(fields) < 7 {
+		return nil, fmt.Errorf("unexpected output looking up user %s: %q", name, result.Stdout)
+	}
+	a := &account{home: fields[5], shell: fields[6], group: strings.TrimSpace(lines[1])}
+	a.uid, _ = strconv.Atoi(fields[2])
+	a.gid, _ = strconv.Atoi(fields[3])
+	a.groups = supplementary(strings.Fields(lines[2]), a.group)
+
+	if password {
+		result, err := l.conn.Execute(ctx, "getent shadow "+q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the password of %s: %w", name, err)
+		}
+		fields := strings.Split(strings.TrimSpace(result.Stdout), ":")
+		if result.ExitCode != 0 || len(fields) < 2 {
+			return nil, fmt.Errorf("failed to read the password of %s from /etc/shadow (this requires become)", name)
+		}
+		a.password = fields[1]
+	}
+	return a, nil
+}
+
+func (l *linux) create(ctx context.Context, name string, want *spec) error {
+	args := []string{"useradd"}
+	if want.uid >= 0 {
+		args = append(args, "-u", strconv.Itoa(want.uid))
+	}
+	if want.group != "" {
+		args = append(args, "-g", want.group)
+	}
+	if len(want.groups) > 0 {
+		args = append(args, "-G", strings.Join(want.groups, ","))
+	}
+	if want.shell != "" {
+		args = append(args, "-s", want.shell)
+	}
+	if want.home != "" {
+		args = append(args, "-d", want.home)
+	}
+	if want.createHome {
+		args = append(args, "-m")
+	} else {
+		args = append(args, "-M")
+	}
+	if want.password != "" {
+		args = append(args, "-p", want.password)
+	}
+	if want.system {
+		args = append(args, "-r")
+	}
+
+	// The command holds the password hash, which must not end up in the error
+	result, err := l.conn.Execute(ctx, sbin(shellutil.Join(append(args, name)...)))
+	if err != nil {
+		return fmt.Errorf("failed to create user %s: %w", name, err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "failed to create user %s", name)
+	}
+	return nil
+}
+
+func (l *linux) modify(ctx context.Context, name string, c *changes) error {
+	args := []string{"usermod"}
+	if c.uid >= 0 {
+		args = append(args, "-u", strconv.Itoa(c.uid))
+	}
+	if c.group != "" {
+		args = append(args, "-g", c.group)
+	}
+	switch {
+	case len(c.removeGroups) > 0:
+		args = append(args, "-G", strings.Join(c.groups, ","))
+	case len(c.addGroups) > 0:
+		args = append(args, "-a", "-G", strings.Join(c.addGroups, ","))
+	}
+	if c.shell != "" {
+		args = append(args, "-s", c.shell)
+	}
+	if c.home != "" {
+		args = append(args, "-d", c.home)
+	}
+	if c.password != "" {
+		args = append(args, "-p", c.password)
+	}
+
+	// The command holds the password hash, which must not end up in the error
+	result, err := l.conn.Execute(ctx, sbin(shellutil.Join(append(args, name)...)))
+	if err != nil {
+		return fmt.Errorf("failed to modify user %s: %w", name, err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "failed to modify user %s", name)
+	}
+	return nil
+}
+
+func (l *linux) remove(ctx context.Context, name string) error {
+	return module.RunCommand(ctx, l.conn, sbin("userdel "+shellutil.Quote(name)))
+}
+
+// supplementary returns groups without the primary group, sorted.
+func supplementary(groups []string, primary string) []string {
+	result := []string{}
+	for _, g := range groups {
+		if g != primary && !slices.Contains(result, g) {
+			result = append(result, g)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// sbin adds the sbin directories, where the account tools are, to the PATH
+// of cmd, as they may be missing for non-root users.
+func sbin(cmd string) string {
+	return `PATH="$PATH:/usr/sbin:/sbin" ` + cmd
+}
+
+// SupportsCheckMode reports that user can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,261 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+const (
+	lookupCmd = "getent passwd 'alice' || exit 2; id -gn 'alice'; id -Gn 'alice'"
+
+	// aliceOut is the lookup output of an existing alice.
+	aliceOut = "alice:x:1001:1001::/home/alice:/bin/bash\nalice\nalice docker wheel\n"
+)
+
+// newConn returns a fake Linux target.
+func newConn() *connectortest.Connector {
+	conn := connectortest.New()
+	conn.On("uname -s").Return("Linux\n")
+	return conn
+}
+
+func TestRunCreate(t *testing.T) {
+	conn := newConn()
+	conn.On(lookupCmd).Fail(2, "").Once()
+	conn.On(lookupCmd).Return(aliceOut)
+	conn.On(`PATH="$PATH:/usr/sbin:/sbin" 'useradd' '-u' '1001' '-G' 'docker,wheel' '-s' '/bin/bash' '-m' 'alice'`).Once()
+
+	params := map[string]any{"name": "alice", "uid": 1001, "groups": []any{"docker", "wheel"}, "shell": "/bin/bash"}
+	result, err := (&Module{}).Run(context.Background(), conn, params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Errorf("Changed = false, want true (%s)", result.Message)
+	}
+	if result.Before != nil {
+		t.Errorf("Before = %v, want nil for a new user", result.Before)
+	}
+	if got := result.Data["home"]; got != "/home/alice" {
+		t.Errorf("home = %v, want the home of the created account", got)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunCreateSystem(t *testing.T) {
+	conn := newConn()
+	conn.On(lookupCmd).Fail(2, "").Once()
+	conn.On(lookupCmd).Return(aliceOut)
+	conn.On(`PATH="$PATH:/usr/sbin:/sbin" 'useradd' '-M' '-r' 'alice'`).Once()
+
+	params := map[string]any{"name": "alice", "create_home": false, "system": true}
+	if _, err := (&Module{}).Run(context.Background(), conn, params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunModify(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]any
+		wantCmd string
+		before  map[string]any
+		after   map[string]any
+	}{
+		{
+			name:   "no change",
+			params: map[string]any{"uid": 1001, "group": "alice", "shell": "/bin/bash", "groups": []any{"wheel", "docker"}},
+		},
+		{
+			name:   "primary group by ID",
+			params: map[string]any{"group": "1001"},
+		},
+		{
+			name:   "groups appended that are already there",
+			params: map[string]any{"groups": []any{"docker"}, "append": true},
+		},
+		{
+			name:    "shell",
+			params:  map[string]any{"shell": "/bin/zsh"},
+			wantCmd: `PATH="$PATH:/usr/sbin:/sbin" 'usermod' '-s' '/bin/zsh' 'alice'`,
+			before:  map[string]any{"shell": "/bin/bash"},
+			after:   map[string]any{"shell": "/bin/zsh"},
+		},
+		{
+			name:    "append groups",
+			params:  map[string]any{"groups": []any{"audio"}, "append": true},
+			wantCmd: `PATH="$PATH:/usr/sbin:/sbin" 'usermod' '-a' '-G' 'audio' 'alice'`,
+			before:  map[string]any{"groups": []string{"docker", "wheel"}},
+			after:   map[string]any{"groups": []string{"audio", "docker", "wheel"}},
+		},
+		{
+			name:    "replace groups",
+			params:  map[string]any{"groups": []any{"wheel", "audio"}},
+			wantCmd: `PATH="$PATH:/usr/sbin:/sbin" 'usermod' '-G' 'audio,wheel' 'alice'`,
+			before:  map[string]any{"groups": []string{"docker", "wheel"}},
+			after:   map[string]any{"groups": []string{"audio", "wheel"}},
+		},
+		{
+			name:    "uid and home",
+			params:  map[string]any{"uid": 2000, "home": "/srv/alice"},
+			wantCmd: `PATH="$PATH:/usr/sbin:/sbin" 'usermod' '-u' '2000' '-d' '/srv/alice' 'alice'`,
+			before:  map[string]any{"uid": 1001, "home": "/home/alice"},
+			after:   map[string]any{"uid": 2000, "home": "/srv/alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConn()
+			conn.On(lookupCmd).Return(aliceOut)
+			if tt.wantCmd != "" {
+				conn.On(tt.wantCmd).Once()
+			}
+
+			tt.params["name"] = "alice"
+			result, err := (&Module{}).Run(context.Background(), conn, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Changed != (tt.wantCmd != "") {
+				t.Fatalf("Changed = %v, want %v (%s)", result.Changed, tt.wantCmd != "", result.Message)
+			}
+			if tt.wantCmd != "" {
+				if !reflect.DeepEqual(result.Before, tt.before) || !reflect.DeepEqual(result.After, tt.after) {
+					t.Errorf("diff = %v -> %v, want %v -> %v", result.Before, result.After, tt.before, tt.after)
+				}
+			}
+			conn.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunPassword(t *testing.T) {
+	conn := newConn()
+	conn.On(lookupCmd).Return(aliceOut)
+	conn.On("getent shadow 'alice'").Return("alice:$6$old:19000:0:99999:7:::\n")
+	conn.On(`PATH="$PATH:/usr/sbin:/sbin" 'usermod' '-p' '$6$new' 'alice'`).Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"name": "alice", "password": "$6$new"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed {
+		t.Error("Changed = false, want true")
+	}
+	if after, _ := result.After.(map[string]any); after["password"] != "(new hash)" {
+		t.Errorf("After = %v, want the hash hidden", result.After)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunPasswordFailureHidesHash(t *testing.T) {
+	conn := newConn()
+	conn.On(lookupCmd).Return(aliceOut)
+	conn.On("getent shadow 'alice'").Return("alice:$6$old:19000:0:99999:7:::\n")
+	conn.On(`PATH="$PATH:/usr/sbin:/sbin" 'usermod' '-p' '$6$new' 'alice'`).Fail(1, "usermod: user alice is currently used by process 1\n")
+
+	_, err := (&Module{}).Run(context.Background(), conn, map[string]any{"name": "alice", "password": "$6$new"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), "$6$new") {
+		t.Errorf("error = %q, want the hash left out", err)
+	}
+	var cmdErr *module.CommandFailed
+	if !errors.As(err, &cmdErr) || cmdErr.RC != 1 {
+		t.Errorf("error = %v, want a CommandFailed with rc 1", err)
+	}
+}
+
+func TestRunRemove(t *testing.T) {
+	conn := newConn()
+	conn.On(lookupCmd).Return(aliceOut)
+	conn.On(`PATH="$PATH:/usr/sbin:/sbin" userdel 'alice'`).Once()
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"name": "alice", "state": "absent"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Changed || result.After != nil {
+		t.Errorf("Changed = %v, After = %v, want a removal", result.Changed, result.After)
+	}
+	conn.AssertExpectations(t)
+}
+
+func TestRunRemoveAbsent(t *testing.T) {
+	conn := newConn()
+	conn.On(lookupCmd).Fail(2, "")
+
+	result, err := (&Module{}).Run(context.Background(), conn, map[string]any{"name": "alice", "state": "absent"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Changed {
+		t.Errorf("Changed = true, want false (%s)", result.Message)
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		exists bool
+		params map[string]any
+	}{
+		{"create", false, map[string]any{}},
+		{"modify", true, map[string]any{"shell": "/bin/zsh"}},
+		{"remove", true, map[string]any{"state": "absent"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Any command but the lookup fails the test
+			conn := newConn()
+			if tt.exists {
+				conn.On(lookupCmd).Return(aliceOut)
+			} else {
+				conn.On(lookupCmd).Fail(2, "")
+			}
+
+			tt.params["name"] = "alice"
+			tt.params[module.CheckModeParam] = true
+			result, err := (&Module{}).Run(context.Background(), conn, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !result.Changed {
+				t.Errorf("Changed = false, want true (%s)", result.Message)
+			}
+			if n := len(conn.Commands()); n != 2 {
+				t.Errorf("ran %d commands, want only uname and the lookup: %q", n, conn.Commands())
+			}
+		})
+	}
+}
+
+func TestRunInvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]any
+	}{
+		{"bad name", map[string]any{"name": "-alice"}},
+		{"name with colon", map[string]any{"name": "al:ice"}},
+		{"bad state", map[string]any{"name": "alice", "state": "locked"}},
+		{"negative uid", map[string]any{"name": "alice", "uid": -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&Module{}).Run(context.Background(), newConn(), tt.params); err == nil {
+				t.Error("Run() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+// Package vscodeextension provides a module for managing VS Code
+// extensions.
+package vscodeextension
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// appBundles are where the macOS app bundles of VS Code and its forks keep
+// their command line launcher, which is only on the PATH once the user
+// installs it from the command palette.
+var appBundles = map[string]string{
+	"code":          "/Applications/Visual Studio Code.app/Contents/Resources/app/bin/code",
+	"code-insiders": "/Applications/Visual Studio Code - Insiders.app/Contents/Resources/app/bin/code",
+	"codium":        "/Applications/VSCodium.app/Contents/Resources/app/bin/codium",
+	"cursor":        "/Applications/Cursor.app/Contents/Resources/app/bin/cursor",
+}
+
+// Module installs and removes VS Code extensions.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "vscode_extension"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "list", Required: true, Description: "Extension IDs, e.g. golang.go, optionally pinned: golang.go@0.41.4"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "executable", Type: "string", Default: "code", Description: "Editor command: code, code-insiders, codium, cursor, or a path"},
+		{Name: "extensions_dir", Type: "string", Description: "Extensions directory (default: the editor's)"},
+	}
+}
+
+// Run executes the vscode_extension module.
+//
+// Extensions are installed for the connecting user; the editor refuses to
+// run as root, so run the task without become, or with become_user. An
+// extension pinned to a version is reinstalled when another version is
+// installed; an unpinned one is left at the version it has.
+//
+// Parameters:
+//   - name ([]string, required): Extension IDs, optionally with @version
+//   - state (string): Desired state - present, absent (default: present)
+//   - executable (string): Editor command or path (default: code)
+//   - extensions_dir (string): Extensions directory (default: the editor's)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	names := param.StringList(params, "name")
+	state := param.String(params, "state", "present")
+	executable := param.String(params, "executable", "code")
+	extensionsDir := param.String(params, "extensions_dir", "")
+	check := module.IsCheckMode(params)
+
+	switch state {
+	case "present", "absent":
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+	if len(names) == 0 {
+		return nil, module.ParamErrorf("name", "'name' parameter is required")
+	}
+	for _, name := range names {
+		id, _, _ := strings.Cut(name, "@")
+		if !strings.Contains(id, ".") {
+			return nil, module.ParamErrorf("name", "invalid extension '%s': must be publisher.extension", name)
+		}
+	}
+
+	editor, err := findEditor(ctx, conn, executable)
+	if err != nil {
+		return nil, err
+	}
+	if editor == "" {
+		// A dry run of a bootstrap has not installed the editor yet
+		if check && state == "present" {
+			return module.Changed(fmt.Sprintf("would install: %s (%s is not installed)", strings.Join(names, ", "), executable)), nil
+		}
+		if state == "absent" {
+			return module.Unchanged(fmt.Sprintf("%s is not installed", executable)), nil
+		}
+		return nil, fmt.Errorf("%s is not installed", executable)
+	}
+
+	e := &editorCLI{conn: conn, path: editor, extensionsDir: extensionsDir}
+	installed, err := e.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var toInstall, toRemove []string
+	before := map[string]any{}
+	after := map[string]any{}
+	for _, name := range names {
+		id, version, _ := strings.Cut(name, "@")
+		id = strings.ToLower(id)
+		current, ok := installed[id]
+		switch {
+		case state == "absent":
+			if ok {
+				toRemove = append(toRemove, id)
+				before[id] = current
+			}
+		case !ok:
+			toInstall = append(toInstall, name)
+			after[id] = versionOrPresent(version)
+		case version != "" && version != current:
+			toInstall = append(toInstall, name)
+			before[id], after[id] = current, version
+		}
+	}
+
+	if len(toInstall) == 0 && len(toRemove) == 0 {
+		return module.Unchanged("extensions already in desired state"), nil
+	}
+
+	if check {
+		msg := summary(toInstall, toRemove, "would install", "would remove")
+		return module.Changed(msg).WithDiff(before, after), nil
+	}
+
+	if len(toInstall) > 0 && module.IsOffline(params) {
+		return nil, module.OfflineErrorf("installing "+strings.Join(toInstall, ", "), "the extensions come from the marketplace")
+	}
+	for _, name := range toInstall {
+		// --force replaces an installed extension with the pinned version
+		if err := e.run(ctx, "--install-extension", name, "--force"); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range toRemove {
+		if err := e.run(ctx, "--uninstall-extension", id); err != nil {
+			return nil, err
+		}
+	}
+
+	msg := summary(toInstall, toRemove, "installed", "removed")
+	return module.Changed(msg).WithDiff(before, after), nil
+}
+
+// summary describes the extensions installed and removed.
+func summary(toInstall, toRemove []string, installed, removed string) string {
+	var messages []string
+	if len(toInstall) > 0 {
+		messages = append(messages, fmt.Sprintf("%s: %s", installed, strings.Join(toInstall, ", ")))
+	}
+	if len(toRemove) > 0 {
+		messages = append(messages, fmt.Sprintf("%s: %s", removed, strings.Join(toRemove, ", ")))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// versionOrPresent returns version, or "present" for an unpinned
+// extension whose version is not known before it is installed.
+func versionOrPresent(version string) string {
+	if version == "" {
+		return "present"
+	}
+	return version
+}
+
+// findEditor returns the path of the editor's command line launcher, on
+// the PATH or in its macOS app bundle, or "" if it is not installed.
+func findEditor(ctx context.Context, conn connector.Connector, executable string) (string, error) {
+	cmd := "command -v " + shellutil.Quote(executable)
+	if bundle, ok := appBundles[executable]; ok {
+		cmd = fmt.Sprintf("%s || { test -x %[2]s && echo %[2]s; }", cmd, shellutil.Quote(bundle))
+	}
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for %s: %w", executable, err)
+	}
+	if result.ExitCode != 0 {
+		return "", nil
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// editorCLI runs the command line of an editor.
+type editorCLI struct {
+	conn          connector.Connector
+	path          string
+	extensionsDir string
+}
+
+// command returns the command line that runs the editor with args.
+func (e *editorCLI) command(args ...string) string {
+	cmd := []string{e.path}
+	if e.extensionsDir != "" {
+		cmd = append(cmd, "--extensions-dir", e.extensionsDir)
+	}
+	return shellutil.Join(append(cmd, args...)...)
+}
+
+// list returns the versions of the installed extensions by their
+// lowercased IDs.
+func (e *editorCLI) list(ctx context.Context) (map[string]string, error) {
+	result, err := e.conn.Execute(ctx, e.command("--list-extensions", "--show-versions"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extensions: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, module.CommandFailedf(result, "failed to list extensions")
+	}
+
+	// golang.go@0.41.4
+	installed := map[string]string{}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		id, version, ok := strings.Cut(strings.TrimSpace(line), "@")
+		if ok && strings.Contains(id, ".") && !strings.ContainsAny(id, " \t") {
+			installed[strings.ToLower(id)] = version
+		}
+	}
+	return installed, nil
+}
+
+// run runs the editor with args and fails if it exits non-zero.
+func (e *editorCLI) run(ctx context.Context, args ...string) error {
+	result, err := e.conn.Execute(ctx, e.command(args...))
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", e.path, err)
+	}
+	if result.ExitCode != 0 {
+		return module.CommandFailedf(result, "%s %s failed", args[0], args[1])
+	}
+	return nil
+}
+
+// SupportsCheckMode reports that vscode_extension can run in check mode.
+func (m *Module) SupportsCheckMode() bool {
+	return true
+}
+
+// Ensure Module implements the module.Module interface.
+var (
+	_ module.Module     = (*Module)(nil)
+	_ module.CheckModer = (*Module)(nil)
+)
@@ -0,0 +1,279 @@
+// Package wireguard provides a module for configuring WireGuard interfaces.
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+	"github.com/eugenetaranov/bolt/pkg/facts"
+)
+
+func init() {
+	module.Register(&Module{})
+}
+
+// State represents the desired state of a WireGuard interface.
+type State string
+
+const (
+	StatePresent State = "present" // Ensure the interface is configured and up
+	StateAbsent  State = "absent"  // Ensure the interface is down and its config removed
+)
+
+// Module manages WireGuard interfaces through wg-quick configuration files.
+type Module struct{}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "wireguard"
+}
+
+// Params describes the module parameters.
+func (m *Module) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "name", Type: "string", Required: true, Description: "Interface name (e.g., `wg0`)"},
+		{Name: "private_key", Type: "string", Description: "Interface private key"},
+		{Name: "address", Type: "string/list", Description: "Interface addresses in CIDR notation"},
+		{Name: "listen_port", Type: "int", Description: "UDP listen port"},
+		{Name: "dns", Type: "string/list", Description: "DNS servers"},
+		{Name: "peers", Type: "list", Description: "Peers (see below)"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}, Description: "Desired state"},
+		{Name: "enabled", Type: "bool", Default: true, Description: "Enable `wg-quick@<name>` at boot (systemd only)"},
+		{Name: "path", Type: "string", Description: "Config file path; its basename must match `name`"},
+	}
+}
+
+// Run executes the wireguard module.
+//
+// Parameters:
+//   - name (string, required): Interface name (e.g., "wg0")
+//   - private_key (string): Private key of the interface (required when state=present)
+//   - address (string|[]string): Interface addresses in CIDR notation
+//   - listen_port (int): UDP port to listen on
+//   - dns (string|[]string): DNS servers to use while the interface is up
+//   - peers ([]map): Peers with public_key (required), allowed_ips, endpoint, persistent_keepalive, preshared_key
+//   - state (string): Desired state - present, absent (default: present)
+//   - enabled (bool): Enable the wg-quick@<name> service at boot (default: true)
+//   - path (string): Config file path (default: /etc/wireguard/<name>.conf)
+func (m *Module) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	name, err := param.Required(params, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	stateStr := param.String(params, "state", "present")
+	state := State(stateStr)
+	enabled := param.Bool(params, "enabled", true)
+	confPath := param.String(params, "path", "/etc/wireguard/"+name+".conf")
+
+	switch state {
+	case StatePresent, StateAbsent:
+		// Valid
+	default:
+		return nil, module.ParamErrorf("state", "invalid state '%s': must be present or absent", state)
+	}
+
+	data, exists, err := module.ReadFile(ctx, conn, confPath)
+	if err != nil {
+		return nil, err
+	}
+	current := string(data)
+
+	up, err := isUp(ctx, conn, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == StateAbsent {
+		var messages []string
+		if up {
+			if err := module.RunCommand(ctx, conn, fmt.Sprintf("wg-quick down %s", shellutil.Quote(confPath))); err != nil {
+				return nil, err
+			}
+			messages = append(messages, fmt.Sprintf("stopped %s", name))
+		}
+		if exists {
+			if err := module.RunCommand(ctx, conn, fmt.Sprintf("rm -f %s", shellutil.Quote(confPath))); err != nil {
+				return nil, err
+			}
+			messages = append(messages, fmt.Sprintf("removed %s", confPath))
+		}
+		if len(messages) == 0 {
+			return module.Unchanged(fmt.Sprintf("interface %s already absent", name)), nil
+		}
+		return module.Changed(strings.Join(messages, ", ")), nil
+	}
+
+	config, err := renderConfig(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkInstalled(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	var messages []string
+
+	configChanged := !exists || current != config
+	if configChanged {
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("mkdir -p %s", shellutil.Quote(path.Dir(confPath)))); err != nil {
+			return nil, err
+		}
+		if err := module.UploadVerified(ctx, conn, []byte(config), confPath, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", confPath, err)
+		}
+		messages = append(messages, fmt.Sprintf("updated %s", confPath))
+	}
+
+	switch {
+	case !up:
+		if err := module.RunCommand(ctx, conn, fmt.Sprintf("wg-quick up %s", shellutil.Quote(confPath))); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("started %s", name))
+	case configChanged:
+		// Apply peer changes without tearing down the interface. The
+		// stripped config holds the private key, so it goes to a new
+		// file only its owner can read (mktemp), never a fixed path
+		cmd := fmt.Sprintf(`tmp=$(mktemp) && wg-quick strip %s > "$tmp" && wg syncconf %s "$tmp"; rc=$?; [ -n "$tmp" ] && rm -f "$tmp"; exit $rc`,
+			shellutil.Quote(confPath), shellutil.Quote(name))
+		if err := module.RunCommand(ctx, conn, cmd); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("reloaded %s", name))
+	}
+
+	if enabled {
+		changed, err := enableService(ctx, conn, name)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			messages = append(messages, fmt.Sprintf("enabled wg-quick@%s", name))
+		}
+	}
+
+	if len(messages) == 0 {
+		return module.Unchanged(fmt.Sprintf("interface %s already configured", name)), nil
+	}
+
+	return module.ChangedWithData(strings.Join(messages, ", "), map[string]any{
+		"name": name,
+		"path": confPath,
+	}), nil
+}
+
+// renderConfig builds the wg-quick configuration file from parameters.
+func renderConfig(params map[string]any) (string, error) {
+	privateKey, err := param.Required(params, "private_key")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("# Managed by bolt\n[Interface]\n")
+	b.WriteString("PrivateKey = " + privateKey + "\n")
+	if addrs := param.StringList(params, "address"); len(addrs) > 0 {
+		b.WriteString("Address = " + strings.Join(addrs, ", ") + "\n")
+	}
+	if port := param.Int(params, "listen_port", 0); port != 0 {
+		b.WriteString(fmt.Sprintf("ListenPort = %d\n", port))
+	}
+	if dns := param.StringList(params, "dns"); len(dns) > 0 {
+		b.WriteString("DNS = " + strings.Join(dns, ", ") + "\n")
+	}
+
+	peers, ok := params["peers"]
+	if !ok {
+		return b.String(), nil
+	}
+	list, ok := peers.([]any)
+	if !ok {
+		return "", module.ParamErrorf("peers", "parameter 'peers' must be a list")
+	}
+
+	for i, item := range list {
+		peer, ok := item.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("peer %d must be a map", i+1)
+		}
+		publicKey, err := param.Required(peer, "public_key")
+		if err != nil {
+			return "", fmt.Errorf("peer %d: %w", i+1, err)
+		}
+
+		b.WriteString("\n[Peer]\n")
+		b.WriteString("PublicKey = " + publicKey + "\n")
+		if psk := param.String(peer, "preshared_key", ""); psk != "" {
+			b.WriteString("PresharedKey = " + psk + "\n")
+		}
+		if ips := param.StringList(peer, "allowed_ips"); len(ips) > 0 {
+			b.WriteString("AllowedIPs = " + strings.Join(ips, ", ") + "\n")
+		}
+		if endpoint := param.String(peer, "endpoint", ""); endpoint != "" {
+			b.WriteString("Endpoint = " + endpoint + "\n")
+		}
+		if keepalive := param.Int(peer, "persistent_keepalive", 0); keepalive != 0 {
+			b.WriteString(fmt.Sprintf("PersistentKeepalive = %d\n", keepalive))
+		}
+	}
+
+	return b.String(), nil
+}
+
+// checkInstalled verifies that wireguard-tools are available.
+func checkInstalled(ctx context.Context, conn connector.Connector) error {
+	result, err := conn.Execute(ctx, "command -v wg-quick")
+	if err != nil {
+		return fmt.Errorf("failed to check for wg-quick: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("wireguard-tools are not installed")
+	}
+	return nil
+}
+
+// isUp reports whether the interface is currently active.
+func isUp(ctx context.Context, conn connector.Connector, name string) (bool, error) {
+	result, err := conn.Execute(ctx, fmt.Sprintf("wg show %s >/dev/null 2>&1", shellutil.Quote(name)))
+	if err != nil {
+		return false, fmt.Errorf("failed to check interface %s: %w", name, err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+// enableService enables wg-quick@name on hosts running systemd; others,
+// such as WSL1 and most containers, are skipped. It returns whether the
+// service was not enabled before.
+func enableService(ctx context.Context, conn connector.Connector, name string) (bool, error) {
+	mgr, err := facts.ServiceManager(ctx, conn)
+	if err != nil {
+		return false, err
+	}
+	if mgr != facts.ServiceSystemd {
+		return false, nil
+	}
+
+	unit := shellutil.Quote("wg-quick@" + name)
+	result, err := conn.Execute(ctx, "systemctl is-enabled "+unit)
+	if err != nil {
+		return false, fmt.Errorf("failed to check service: %w", err)
+	}
+	if result.ExitCode == 0 {
+		return false, nil
+	}
+	if err := module.RunCommand(ctx, conn, "systemctl enable "+unit); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Ensure Module implements the module.Module interface.
+var _ module.Module = (*Module)(nil)
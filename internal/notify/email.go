@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email sends the summary as a plain-text mail through an SMTP server.
+type Email struct {
+	// Addr is the mail server as host:port.
+	Addr string
+
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Send mails the summary. Servers that support STARTTLS are used
+// encrypted.
+func (e *Email) Send(ctx context.Context, sum *Summary) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address %s: %w", e.Addr, err)
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	msg := emailMessage(e.From, e.To, sum, time.Now())
+
+	// smtp.SendMail takes no context; run it aside so the timeout still
+	// applies
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.Addr, auth, e.From, e.To, msg)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// emailMessage formats the summary as an RFC 5322 message.
+func emailMessage(from string, to []string, sum *Summary, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", sum.Title())
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(sum.Text(), "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Slack posts the summary to a Slack incoming webhook.
+type Slack struct {
+	URL string
+}
+
+// Send posts the summary as a message with the failures in a code block.
+func (s *Slack) Send(ctx context.Context, sum *Summary) error {
+	icon := map[string]string{"ok": ":white_check_mark:", "changed": ":large_yellow_circle:", "failed": ":x:"}[sum.Status()]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s *%s*\n`%s`", icon, sum.Title(), sum.Recap())
+	if len(sum.Failures) > 0 {
+		b.WriteString("\n```\n")
+		for _, f := range sum.Failures {
+			fmt.Fprintf(&b, "%s\n", f)
+		}
+		b.WriteString("```")
+	}
+
+	return postJSON(ctx, s.URL, nil, map[string]any{"text": b.String()})
+}
+
+// Webhook posts the summary as JSON to an HTTP endpoint.
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+}
+
+// Send posts the summary, with a status and a plain-text rendering added
+// for receivers that just want to forward a message.
+func (w *Webhook) Send(ctx context.Context, sum *Summary) error {
+	payload := struct {
+		*Summary
+		Status string `json:"status"`
+		Text   string `json:"text"`
+	}{sum, sum.Status(), sum.Text()}
+	return postJSON(ctx, w.URL, w.Headers, payload)
+}
+
+// postJSON posts body as JSON and fails on non-2xx responses.
+func postJSON(ctx context.Context, url string, headers map[string]string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
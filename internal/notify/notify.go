@@ -0,0 +1,256 @@
+// Package notify sends the summary of a run to chat, HTTP endpoints, or
+// email when the run ends, so unattended runs report their status.
+//
+// Sinks are configured in bolt.yaml:
+//
+//	notify:
+//	  - type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	    on: failure
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Timeout bounds how long sending to a single sink may take.
+const Timeout = 30 * time.Second
+
+// Summary is the outcome of a run.
+type Summary struct {
+	// Playbook is the path of the playbook.
+	Playbook string `json:"playbook"`
+
+	// Controller is the name of the machine that ran bolt.
+	Controller string `json:"controller"`
+
+	// Success is true if the run completed without failures.
+	Success bool `json:"success"`
+
+	// DryRun is true for dry runs and drift detection.
+	DryRun bool `json:"dry_run"`
+
+	OK      int `json:"ok"`
+	Changed int `json:"changed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+
+	// Duration is how long the run took.
+	Duration time.Duration `json:"duration_ns"`
+
+	// Failures are the tasks that failed the run.
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// Failure is a task that failed a run.
+type Failure struct {
+	Play  string `json:"play,omitempty"`
+	Host  string `json:"host,omitempty"`
+	Task  string `json:"task,omitempty"`
+	Error string `json:"error"`
+}
+
+// Status returns "ok", "changed", or "failed".
+func (s *Summary) Status() string {
+	switch {
+	case !s.Success:
+		return "failed"
+	case s.Changed > 0:
+		return "changed"
+	}
+	return "ok"
+}
+
+// Title returns a one-line description of the run.
+func (s *Summary) Title() string {
+	title := fmt.Sprintf("bolt: %s %s", s.Playbook, s.Status())
+	if s.Controller != "" {
+		title += " on " + s.Controller
+	}
+	if s.DryRun {
+		title += " (dry run)"
+	}
+	return title
+}
+
+// Recap returns the task counts in the form of the run's recap line.
+func (s *Summary) Recap() string {
+	return fmt.Sprintf("ok=%d changed=%d failed=%d skipped=%d (%.2fs)",
+		s.OK, s.Changed, s.Failed, s.Skipped, s.Duration.Seconds())
+}
+
+// Text returns the summary as plain text: the title, the recap, and the
+// failed tasks.
+func (s *Summary) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", s.Title(), s.Recap())
+	if len(s.Failures) > 0 {
+		b.WriteString("\nFailed tasks:\n")
+		for _, f := range s.Failures {
+			fmt.Fprintf(&b, "- %s\n", f)
+		}
+	}
+	return b.String()
+}
+
+// String returns the failure as host: task: error.
+func (f Failure) String() string {
+	var parts []string
+	for _, p := range []string{f.Host, f.Task} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(append(parts, firstLine(f.Error)), ": ")
+}
+
+// Config configures a sink.
+type Config struct {
+	// Type is the kind of sink: slack, webhook, or email.
+	Type string `yaml:"type"`
+
+	// On selects the runs to report: always (default), failure, or
+	// changed (runs that changed something or failed).
+	On string `yaml:"on"`
+
+	// URL is the Slack incoming webhook or HTTP endpoint.
+	URL string `yaml:"url"`
+
+	// Headers are added to webhook requests (e.g., Authorization).
+	Headers map[string]string `yaml:"headers"`
+
+	// SMTP is the mail server as host:port.
+	SMTP string `yaml:"smtp"`
+
+	// Username and Password authenticate to the mail server. Without a
+	// username, mail is sent unauthenticated.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	// From is the sender address.
+	From string `yaml:"from"`
+
+	// To are the recipient addresses.
+	To []string `yaml:"to"`
+}
+
+// Sink delivers run summaries.
+type Sink interface {
+	// Send delivers the summary.
+	Send(ctx context.Context, s *Summary) error
+}
+
+// Notifier sends summaries to the sinks whose On setting matches the run.
+type Notifier struct {
+	sinks   []Sink
+	configs []Config
+}
+
+// New creates a notifier for the given sink configurations. ${VAR}
+// references to environment variables in URLs, headers, and credentials
+// are expanded, so secrets can stay out of bolt.yaml.
+func New(configs []Config) (*Notifier, error) {
+	n := &Notifier{}
+	for i, cfg := range configs {
+		cfg = cfg.expand()
+		sink, err := newSink(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notify[%d]: %w", i, err)
+		}
+		n.sinks = append(n.sinks, sink)
+		n.configs = append(n.configs, cfg)
+	}
+	return n, nil
+}
+
+// newSink creates the sink for cfg.
+func newSink(cfg Config) (Sink, error) {
+	switch cfg.On {
+	case "", "always", "failure", "changed":
+	default:
+		return nil, fmt.Errorf("invalid on: %s (must be always, failure, or changed)", cfg.On)
+	}
+
+	switch cfg.Type {
+	case "slack":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("slack requires url")
+		}
+		return &Slack{URL: cfg.URL}, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook requires url")
+		}
+		return &Webhook{URL: cfg.URL, Headers: cfg.Headers}, nil
+	case "email":
+		if cfg.SMTP == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("email requires smtp, from, and to")
+		}
+		return &Email{
+			Addr:     cfg.SMTP,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+			To:       cfg.To,
+		}, nil
+	case "":
+		return nil, fmt.Errorf("missing type")
+	}
+	return nil, fmt.Errorf("unknown type: %s (must be slack, webhook, or email)", cfg.Type)
+}
+
+// expand replaces ${VAR} references in the values that usually hold
+// secrets.
+func (c Config) expand() Config {
+	c.URL = os.ExpandEnv(c.URL)
+	c.Username = os.ExpandEnv(c.Username)
+	c.Password = os.ExpandEnv(c.Password)
+	if c.Headers != nil {
+		headers := make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			headers[k] = os.ExpandEnv(v)
+		}
+		c.Headers = headers
+	}
+	return c
+}
+
+// wants reports whether a sink configured with on reports the run.
+func wants(on string, s *Summary) bool {
+	switch on {
+	case "failure":
+		return !s.Success
+	case "changed":
+		return !s.Success || s.Changed > 0
+	}
+	return true
+}
+
+// Send delivers the summary to every sink that wants it. All sinks are
+// tried; the errors of those that failed are returned together.
+func (n *Notifier) Send(ctx context.Context, s *Summary) error {
+	var errs []error
+	for i, sink := range n.sinks {
+		cfg := n.configs[i]
+		if !wants(cfg.On, s) {
+			continue
+		}
+		sctx, cancel := context.WithTimeout(ctx, Timeout)
+		if err := sink.Send(sctx, s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Type, err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSummary() *Summary {
+	return &Summary{
+		Playbook:   "site.yaml",
+		Controller: "ci",
+		Success:    false,
+		OK:         3,
+		Changed:    1,
+		Failed:     1,
+		Duration:   1500 * time.Millisecond,
+		Failures: []Failure{
+			{Play: "web", Host: "web1", Task: "Install nginx", Error: "exit status 100\nE: Unable to locate package"},
+		},
+	}
+}
+
+func TestSummaryText(t *testing.T) {
+	want := "bolt: site.yaml failed on ci\n" +
+		"ok=3 changed=1 failed=1 skipped=0 (1.50s)\n" +
+		"\nFailed tasks:\n" +
+		"- web1: Install nginx: exit status 100\n"
+	if got := testSummary().Text(); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("TEST_HOOK_TOKEN", "secret")
+
+	n, err := New([]Config{{Type: "webhook", URL: "http://example.com", Headers: map[string]string{"Authorization": "Bearer ${TEST_HOOK_TOKEN}"}}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := n.sinks[0].(*Webhook).Headers["Authorization"]; got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want expanded token", got)
+	}
+
+	for _, cfg := range []Config{
+		{},
+		{Type: "pager"},
+		{Type: "slack"},
+		{Type: "email", SMTP: "mail:25"},
+		{Type: "webhook", URL: "http://example.com", On: "sometimes"},
+	} {
+		if _, err := New([]Config{cfg}); err == nil {
+			t.Errorf("New(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestSendOn(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	n, err := New([]Config{
+		{Type: "webhook", URL: srv.URL + "/always"},
+		{Type: "webhook", URL: srv.URL + "/failure", On: "failure"},
+		{Type: "webhook", URL: srv.URL + "/changed", On: "changed"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sum := range []*Summary{
+		{Success: true},
+		{Success: true, Changed: 1},
+		{Success: false},
+	} {
+		if err := n.Send(context.Background(), sum); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	want := "/always /always /changed /always /failure /changed"
+	if strings.Join(got, " ") != want {
+		t.Errorf("requests = %v, want %s", got, want)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	if err := (&Slack{URL: srv.URL}).Send(context.Background(), testSummary()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	for _, want := range []string{":x:", "*bolt: site.yaml failed on ci*", "web1: Install nginx"} {
+		if !strings.Contains(body["text"], want) {
+			t.Errorf("text = %q, missing %q", body["text"], want)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var body map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	hook := &Webhook{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer x"}}
+	if err := hook.Send(context.Background(), testSummary()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if auth != "Bearer x" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if body["status"] != "failed" || body["playbook"] != "site.yaml" || body["failed"] != float64(1) {
+		t.Errorf("body = %v", body)
+	}
+	if failures, _ := body["failures"].([]any); len(failures) != 1 {
+		t.Errorf("failures = %v", body["failures"])
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := (&Webhook{URL: srv.URL}).Send(context.Background(), testSummary())
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "no such hook") {
+		t.Errorf("Send() error = %v, want 404 with body", err)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := string(emailMessage("bolt@example.com", []string{"a@example.com", "b@example.com"}, testSummary(), date))
+
+	for _, want := range []string{
+		"From: bolt@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: bolt: site.yaml failed on ci\r\n",
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+		"\r\n\r\nbolt: site.yaml failed on ci\r\nok=3",
+		"- web1: Install nginx: exit status 100\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Diff prints what a task with the given status changed, from the Before
+// and After of its module result (see module.Result), below the task:
+//
+//	--- before
+//	+++ after
+//	@@ -1,2 +1,2 @@
+//	 listen 80;
+//	-worker_processes 2;
+//	+worker_processes 4;
+//	- mode: 0600
+//	+ mode: 0644
+//
+// Text is shown as a unified diff, attributes one per line. The mode
+// decides whether it is shown, as for the task.
+func (o *Output) Diff(status string, before, after any) {
+	if (before == nil && after == nil) || !o.shows(status) {
+		return
+	}
+
+	lines := diffLines(before, after)
+	if len(lines) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			line = o.color(colorBold, line)
+		case strings.HasPrefix(line, "@@"):
+			line = o.color(colorCyan, line)
+		case strings.HasPrefix(line, "-"):
+			line = o.color(colorRed, line)
+		case strings.HasPrefix(line, "+"):
+			line = o.color(colorGreen, line)
+		}
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	o.printf("%s", b.String())
+}
+
+// diffLines returns the lines of the diff of before and after, each a
+// string, a map[string]any, or nil.
+func diffLines(before, after any) []string {
+	beforeText, beforeIsText := before.(string)
+	afterText, afterIsText := after.(string)
+	if (beforeIsText || before == nil) && (afterIsText || after == nil) {
+		return textDiff(beforeText, afterText)
+	}
+
+	beforeAttrs, _ := before.(map[string]any)
+	afterAttrs, _ := after.(map[string]any)
+	keys := make(map[string]bool)
+	for k := range beforeAttrs {
+		keys[k] = true
+	}
+	for k := range afterAttrs {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	// Multi-line values, such as the content of a file, are diffed after
+	// the attributes
+	var lines, texts []string
+	for _, k := range sorted {
+		old, hadOld := beforeAttrs[k]
+		cur, hasCur := afterAttrs[k]
+		oldText, _ := old.(string)
+		curText, _ := cur.(string)
+		if strings.Contains(oldText, "\n") || strings.Contains(curText, "\n") {
+			texts = append(texts, textDiff(oldText, curText)...)
+			continue
+		}
+		if hadOld && hasCur && fmt.Sprint(old) == fmt.Sprint(cur) {
+			continue
+		}
+		if hadOld {
+			lines = append(lines, fmt.Sprintf("- %s: %v", k, old))
+		}
+		if hasCur {
+			lines = append(lines, fmt.Sprintf("+ %s: %v", k, cur))
+		}
+	}
+	return append(lines, texts...)
+}
+
+// textDiff returns the lines of the unified diff of two texts.
+func textDiff(before, after string) []string {
+	if before == after {
+		return nil
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: "before",
+		ToFile:   "after",
+		Context:  3,
+	})
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(diff, "\n"), "\n")
+}
+
+// splitLines splits text into lines for a diff, each ending in a newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
@@ -0,0 +1,71 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after any
+		want          string
+	}{
+		{
+			name:   "text",
+			before: "a\nb\n",
+			after:  "a\nc\n",
+			want: "    --- before\n" +
+				"    +++ after\n" +
+				"    @@ -1,2 +1,2 @@\n" +
+				"     a\n" +
+				"    -b\n" +
+				"    +c\n",
+		},
+		{
+			name:   "attributes",
+			before: map[string]any{"mode": "0600", "owner": "root", "checksum": "ab"},
+			after:  map[string]any{"mode": "0644", "owner": "root", "checksum": "cd", "content": "x\n"},
+			want: "    - checksum: ab\n" +
+				"    + checksum: cd\n" +
+				"    - mode: 0600\n" +
+				"    + mode: 0644\n" +
+				"    --- before\n" +
+				"    +++ after\n" +
+				"    @@ -0,0 +1 @@\n" +
+				"    +x\n",
+		},
+		{
+			name:  "created",
+			after: map[string]any{"state": "directory"},
+			want:  "    + state: directory\n",
+		},
+		{
+			name:   "unchanged",
+			before: map[string]any{"mode": "0644"},
+			after:  map[string]any{"mode": "0644"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			o := New(&buf)
+			o.SetColor(false)
+			o.Diff("changed", tt.before, tt.after)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffMode(t *testing.T) {
+	var buf bytes.Buffer
+	o := New(&buf)
+	o.SetMode(ModeQuiet)
+	o.Diff("changed", "a\n", "b\n")
+	if buf.Len() != 0 {
+		t.Errorf("quiet mode printed a diff:\n%s", buf.String())
+	}
+}
@@ -2,9 +2,12 @@
 package output
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/eugenetaranov/bolt/internal/playbook"
@@ -12,14 +15,42 @@ import (
 
 // Colors for terminal output.
 const (
-	colorReset   = "\033[0m"
-	colorRed     = "\033[31m"
-	colorGreen   = "\033[32m"
-	colorYellow  = "\033[33m"
-	colorBlue    = "\033[34m"
-	colorCyan    = "\033[36m"
-	colorGray    = "\033[90m"
-	colorBold    = "\033[1m"
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorCyan   = "\033[36m"
+	colorGray   = "\033[90m"
+	colorBold   = "\033[1m"
+)
+
+// asciiGlyphs replaces the Unicode glyphs in output when Unicode is
+// disabled.
+var asciiGlyphs = map[string]string{
+	"✓": "+",
+	"○": "-",
+	"✗": "x",
+	"→": "->",
+}
+
+// maskedSecret replaces secret values in output.
+const maskedSecret = "********"
+
+// Mode selects which task results are printed.
+type Mode int
+
+const (
+	// ModeNormal prints every task result.
+	ModeNormal Mode = iota
+
+	// ModeQuiet prints only failed tasks and the recap.
+	ModeQuiet
+
+	// ModeChangedOnly prints only changed and failed tasks, and nothing at
+	// all if no task changed or failed, so scheduled runs stay silent
+	// unless there is something to report.
+	ModeChangedOnly
 )
 
 // Stats holds execution statistics for output.
@@ -27,15 +58,26 @@ type Stats interface {
 	GetOK() int
 	GetChanged() int
 	GetFailed() int
+	GetUnreachable() int
 	GetSkipped() int
 	GetDuration() time.Duration
 }
 
 // Output handles formatted output.
 type Output struct {
+	mu       sync.Mutex
 	w        io.Writer
 	useColor bool
+	unicode  bool
+	verbose  bool
 	debug    bool
+	mode     Mode
+	secrets  []string
+
+	// In quiet and changed-only mode, banners are held back until the
+	// first task result below them is printed.
+	pendingPlaybook string
+	pendingPlay     string
 }
 
 // New creates a new output handler.
@@ -43,6 +85,7 @@ func New(w io.Writer) *Output {
 	return &Output{
 		w:        w,
 		useColor: true,
+		unicode:  true,
 	}
 }
 
@@ -51,14 +94,36 @@ func (o *Output) SetColor(enabled bool) {
 	o.useColor = enabled
 }
 
+// SetUnicode enables or disables Unicode glyphs. When disabled, status
+// indicators are printed as ASCII (+, -, x, ->).
+func (o *Output) SetUnicode(enabled bool) {
+	o.unicode = enabled
+}
+
 // SetDebug enables or disables debug output.
 func (o *Output) SetDebug(enabled bool) {
 	o.debug = enabled
 }
 
-// SetVerbose is an alias for SetDebug for backward compatibility.
+// SetMode selects which task results are printed.
+func (o *Output) SetMode(mode Mode) {
+	o.mode = mode
+}
+
+// Mask hides secret in everything printed from now on.
+func (o *Output) Mask(secret string) {
+	if secret == "" {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.secrets = append(o.secrets, secret)
+}
+
+// SetVerbose enables or disables verbose output, which adds the module,
+// host, and duration to every task line.
 func (o *Output) SetVerbose(enabled bool) {
-	o.debug = enabled
+	o.verbose = enabled
 }
 
 // color returns the string wrapped in color codes if enabled.
@@ -69,16 +134,38 @@ func (o *Output) color(c, s string) string {
 	return c + s + colorReset
 }
 
+// glyph returns g, or its ASCII replacement if Unicode is disabled.
+func (o *Output) glyph(g string) string {
+	if o.unicode {
+		return g
+	}
+	return asciiGlyphs[g]
+}
+
 // PlaybookStart prints the playbook start banner.
 func (o *Output) PlaybookStart(path string) {
-	o.printf("\n%s %s\n", o.color(colorBold, "PLAYBOOK"), path)
+	banner := fmt.Sprintf("\n%s %s\n", o.color(colorBold, "PLAYBOOK"), path)
 	if o.debug {
-		o.printf("%s\n", strings.Repeat("-", 60))
+		banner += fmt.Sprintf("%s\n", strings.Repeat("-", 60))
 	}
+
+	if o.mode != ModeNormal {
+		o.mu.Lock()
+		o.pendingPlaybook, o.pendingPlay = banner, ""
+		o.mu.Unlock()
+		return
+	}
+	o.printf("%s", banner)
 }
 
-// PlaybookEnd prints the playbook summary.
+// PlaybookEnd prints the playbook summary. Unreachable tasks are only
+// counted if there are any. In changed-only mode it is omitted if no task
+// changed or failed.
 func (o *Output) PlaybookEnd(stats Stats) {
+	if o.mode == ModeChangedOnly && stats.GetChanged() == 0 && stats.GetFailed() == 0 && stats.GetUnreachable() == 0 {
+		return
+	}
+
 	o.printf("\n%s ", o.color(colorBold, "RECAP"))
 
 	ok := o.color(colorGreen, fmt.Sprintf("ok=%d", stats.GetOK()))
@@ -87,16 +174,30 @@ func (o *Output) PlaybookEnd(stats Stats) {
 	skipped := o.color(colorCyan, fmt.Sprintf("skipped=%d", stats.GetSkipped()))
 
 	o.printf("%s %s %s %s", ok, changed, failed, skipped)
+	if n := stats.GetUnreachable(); n > 0 {
+		o.printf(" %s", o.color(colorRed, fmt.Sprintf("unreachable=%d", n)))
+	}
 	o.printf(" %s\n", o.color(colorGray, fmt.Sprintf("(%.2fs)", stats.GetDuration().Seconds())))
 }
 
+// Warnings prints the warnings of a run below its summary.
+func (o *Output) Warnings(warnings []playbook.Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+	o.printf("\n%s\n", o.color(colorYellow+colorBold, fmt.Sprintf("WARNINGS (%d)", len(warnings))))
+	for _, w := range warnings {
+		o.printf("%s %s\n", o.color(colorYellow, "WARN"), w)
+	}
+}
+
 // PlayStart prints the play start banner.
 func (o *Output) PlayStart(play *playbook.Play) {
 	name := play.Name
 	if name == "" {
 		name = play.Hosts
 	}
-	o.printf("\n%s %s\n", o.color(colorBold, "PLAY"), name)
+	o.banner(fmt.Sprintf("\n%s %s\n", o.color(colorBold, "PLAY"), name), true)
 }
 
 // TaskStart is called when a task begins (no output in compact mode).
@@ -105,121 +206,204 @@ func (o *Output) TaskStart(name, moduleName string) {
 	// Output is printed in TaskResult
 }
 
-// TaskResult prints the task result in a single line.
-// Format: [status] module | host | task name
-func (o *Output) TaskResult(name, status string, changed bool, message string) {
-	// Determine status indicator and color
-	var indicator string
-	var statusColor string
-
+// statusStyle returns the indicator and color for a task status.
+func (o *Output) statusStyle(status string) (indicator, statusColor string) {
 	switch {
 	case strings.HasPrefix(status, "ok"):
-		indicator = "✓"
-		statusColor = colorGreen
+		return o.glyph("✓"), colorGreen
 	case strings.HasPrefix(status, "changed"):
-		indicator = "✓"
-		statusColor = colorYellow
+		return o.glyph("✓"), colorYellow
 	case strings.HasPrefix(status, "skipped"):
-		indicator = "○"
-		statusColor = colorCyan
-	case strings.HasPrefix(status, "failed"):
-		indicator = "✗"
-		statusColor = colorRed
-	default:
-		indicator = "?"
-		statusColor = colorGray
+		return o.glyph("○"), colorCyan
+	case isFailure(status):
+		return o.glyph("✗"), colorRed
 	}
+	return "?", colorGray
+}
 
-	// Print compact single line
-	o.printf("  %s %s\n", o.color(statusColor, indicator), name)
+// isFailure reports whether status is that of a task that failed or could
+// not reach its host, including ignored ones.
+func isFailure(status string) bool {
+	return strings.HasPrefix(status, "failed") || strings.HasPrefix(status, "unreachable")
+}
 
-	// In debug mode, print additional details
-	if o.debug && message != "" {
-		o.printf("    %s %s\n", o.color(colorGray, "→"), message)
-	}
+// TaskResult prints the task result in a single line.
+// Format: [status] task name
+func (o *Output) TaskResult(name, status string, changed bool, message string) {
+	indicator, statusColor := o.statusStyle(status)
+
+	// Print compact single line, with details in debug mode.
+	// Written in one call so lines from parallel loop items do not interleave.
+	line := fmt.Sprintf("  %s %s\n", o.color(statusColor, indicator), name)
+	line += o.message(status, message, "    ")
+	o.printTask(status, line)
 }
 
-// TaskResultDetailed prints detailed task result (for debug mode).
-func (o *Output) TaskResultDetailed(name, module, host, status, message string, data map[string]any) {
-	// Determine status indicator and color
-	var indicator string
-	var statusColor string
-	var statusText string
+// TaskResultDetailed prints a task result. In verbose and debug mode the
+// line also shows the module, host, and duration:
+//
+//	✓ [apt] Install nginx (web1) changed 1.32s
+//
+// Otherwise it prints the same compact line as TaskResult. Debug mode adds
+// the stdout and stderr from data.
+func (o *Output) TaskResultDetailed(name, module, host, status, message string, duration time.Duration, data map[string]any) {
+	if !o.verbose && !o.debug {
+		o.TaskResult(name, status, false, message)
+		return
+	}
+	if !o.shows(status) {
+		return
+	}
 
-	switch {
-	case strings.HasPrefix(status, "ok"):
-		indicator = "✓"
-		statusColor = colorGreen
-		statusText = "ok"
-	case strings.HasPrefix(status, "changed"):
-		indicator = "✓"
-		statusColor = colorYellow
-		statusText = "changed"
-	case strings.HasPrefix(status, "skipped"):
-		indicator = "○"
-		statusColor = colorCyan
-		statusText = "skipped"
-	case strings.HasPrefix(status, "failed"):
-		indicator = "✗"
-		statusColor = colorRed
-		statusText = "FAILED"
-	default:
-		indicator = "?"
-		statusColor = colorGray
-		statusText = status
-	}
-
-	// Print compact line: [indicator] [module] name (host) - status
-	moduleStr := ""
+	indicator, statusColor := o.statusStyle(status)
+	statusText := status
+	if strings.HasPrefix(status, "failed") {
+		statusText = "FAILED" + strings.TrimPrefix(status, "failed")
+	} else if strings.HasPrefix(status, "unreachable") {
+		statusText = "UNREACHABLE" + strings.TrimPrefix(status, "unreachable")
+	}
+
+	// Print line: [indicator] [module] name (host) status duration
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %s ", o.color(statusColor, indicator))
 	if module != "" {
-		moduleStr = o.color(colorGray, fmt.Sprintf("[%s] ", module))
+		b.WriteString(o.color(colorGray, fmt.Sprintf("[%s] ", module)))
+	}
+	b.WriteString(name)
+	if host != "" {
+		b.WriteString(" " + o.color(colorGray, fmt.Sprintf("(%s)", host)))
 	}
-	hostStr := o.color(colorGray, fmt.Sprintf("(%s)", host))
+	b.WriteString(" " + o.color(statusColor, statusText))
+	if duration > 0 {
+		b.WriteString(" " + o.color(colorGray, fmt.Sprintf("%.2fs", duration.Seconds())))
+	}
+	b.WriteString("\n")
 
-	o.printf("  %s %s%s %s %s\n",
-		o.color(statusColor, indicator),
-		moduleStr,
-		name,
-		hostStr,
-		o.color(statusColor, statusText))
+	b.WriteString(o.message(status, message, "      "))
 
-	// In debug mode, print additional details
+	// In debug mode, print command output
 	if o.debug {
-		if message != "" {
-			o.printf("      %s %s\n", o.color(colorGray, "msg:"), message)
-		}
-		for k, v := range data {
-			if k == "stdout" || k == "stderr" {
-				if s, ok := v.(string); ok && s != "" {
-					lines := strings.Split(strings.TrimSpace(s), "\n")
-					o.printf("      %s\n", o.color(colorGray, k+":"))
-					for _, line := range lines {
-						o.printf("        %s\n", line)
-					}
+		for _, k := range []string{"stdout", "stderr"} {
+			if s, ok := data[k].(string); ok && strings.TrimSpace(s) != "" {
+				fmt.Fprintf(&b, "      %s\n", o.color(colorGray, k+":"))
+				for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+					fmt.Fprintf(&b, "        %s\n", line)
 				}
 			}
 		}
 	}
+
+	o.printTask(status, b.String())
+}
+
+// shows reports whether a task result with the given status is printed in
+// the current mode.
+func (o *Output) shows(status string) bool {
+	switch o.mode {
+	case ModeQuiet:
+		return isFailure(status)
+	case ModeChangedOnly:
+		return isFailure(status) || strings.HasPrefix(status, "changed")
+	}
+	return true
+}
+
+// printTask prints a task result if the mode shows it, preceded by any
+// banners held back for it.
+func (o *Output) printTask(status, s string) {
+	if !o.shows(status) {
+		return
+	}
+	o.printf("%s%s", o.takePending(), s)
+}
+
+// banner prints a play or section banner, or holds it back in quiet and
+// changed-only mode. A play banner replaces held back banners of the
+// previous play, which had nothing to show.
+func (o *Output) banner(s string, play bool) {
+	if o.mode == ModeNormal {
+		o.printf("%s", s)
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if play {
+		o.pendingPlay = s
+	} else {
+		o.pendingPlay += s
+	}
+}
+
+// takePending returns and clears the held back banners.
+func (o *Output) takePending() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s := o.pendingPlaybook + o.pendingPlay
+	o.pendingPlaybook, o.pendingPlay = "", ""
+	return s
+}
+
+// message formats a task's message below its result line. Failed tasks
+// always show it, since it usually holds the error and the command's
+// stderr; other messages are only shown in debug mode.
+func (o *Output) message(status, message, indent string) string {
+	if message == "" || (!o.debug && !isFailure(status)) {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	s := fmt.Sprintf("%s%s %s\n", indent, o.color(colorGray, o.glyph("→")), lines[0])
+	for _, line := range lines[1:] {
+		s += fmt.Sprintf("%s  %s\n", indent, line)
+	}
+	return s
 }
 
 // Section prints a section header.
 func (o *Output) Section(name string) {
-	o.printf("\n%s\n", o.color(colorBold, name))
+	o.banner(fmt.Sprintf("\n%s\n", o.color(colorBold, name)), false)
 }
 
-// Info prints an informational message.
+// Info prints an informational message. Quiet and changed-only mode omit
+// it.
 func (o *Output) Info(format string, args ...any) {
+	if o.mode != ModeNormal {
+		return
+	}
 	o.printf("%s %s\n", o.color(colorBlue, "INFO"), fmt.Sprintf(format, args...))
 }
 
 // Warn prints a warning message.
 func (o *Output) Warn(format string, args ...any) {
-	o.printf("%s %s\n", o.color(colorYellow, "WARN"), fmt.Sprintf(format, args...))
+	o.printf("%s%s %s\n", o.takePending(), o.color(colorYellow, "WARN"), fmt.Sprintf(format, args...))
 }
 
 // Error prints an error message.
 func (o *Output) Error(format string, args ...any) {
-	o.printf("%s %s\n", o.color(colorRed, "ERROR"), fmt.Sprintf(format, args...))
+	o.printf("%s%s %s\n", o.takePending(), o.color(colorRed, "ERROR"), fmt.Sprintf(format, args...))
+}
+
+// Confirm prints question and reads the answer from in: y or yes
+// approves, anything else declines. The end of the input before an answer
+// is io.EOF. It prints in every mode, since the run waits for the answer.
+func (o *Output) Confirm(in *bufio.Reader, question string) (bool, error) {
+	o.printf("%s%s %s [y/N] ", o.takePending(), o.color(colorYellow, "CONFIRM"), question)
+	answer, err := in.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		// Put the next output on a line of its own
+		o.printf("\n")
+		if answer == "" {
+			return false, io.EOF
+		}
+	} else if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
 }
 
 // Debug prints a debug message (only in debug mode).
@@ -230,5 +414,11 @@ func (o *Output) Debug(format string, args ...any) {
 }
 
 func (o *Output) printf(format string, args ...any) {
-	fmt.Fprintf(o.w, format, args...)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s := fmt.Sprintf(format, args...)
+	for _, secret := range o.secrets {
+		s = strings.ReplaceAll(s, secret, maskedSecret)
+	}
+	fmt.Fprint(o.w, s)
 }
@@ -1,10 +1,14 @@
 package output
 
 import (
+	"bufio"
 	"bytes"
+	"io"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
 )
 
 func TestNewOutput(t *testing.T) {
@@ -197,6 +201,39 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"Yes\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"y", true},
+	}
+	if _, err := New(io.Discard).Confirm(bufio.NewReader(strings.NewReader("")), "Sure?"); err != io.EOF {
+		t.Errorf("no answer = %v, want io.EOF", err)
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		o := New(&buf)
+		o.SetColor(false)
+		o.SetMode(ModeQuiet)
+
+		got, err := o.Confirm(bufio.NewReader(strings.NewReader(tt.input)), "Delete /data?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("answer %q = %v, want %v", tt.input, got, tt.want)
+		}
+		if !strings.HasPrefix(buf.String(), "CONFIRM Delete /data? [y/N] ") {
+			t.Errorf("prompt = %q, want the question even in quiet mode", buf.String())
+		}
+	}
+}
+
 func TestDebugOutput(t *testing.T) {
 	t.Run("debug enabled", func(t *testing.T) {
 		var buf bytes.Buffer
@@ -229,14 +266,15 @@ func TestDebugOutput(t *testing.T) {
 
 // mockStats implements the Stats interface for testing
 type mockStats struct {
-	ok, changed, failed, skipped int
-	duration                     time.Duration
+	ok, changed, failed, skipped, unreachable int
+	duration                                  time.Duration
 }
 
-func (m *mockStats) GetOK() int              { return m.ok }
-func (m *mockStats) GetChanged() int         { return m.changed }
-func (m *mockStats) GetFailed() int          { return m.failed }
-func (m *mockStats) GetSkipped() int         { return m.skipped }
+func (m *mockStats) GetOK() int                 { return m.ok }
+func (m *mockStats) GetChanged() int            { return m.changed }
+func (m *mockStats) GetFailed() int             { return m.failed }
+func (m *mockStats) GetUnreachable() int        { return m.unreachable }
+func (m *mockStats) GetSkipped() int            { return m.skipped }
 func (m *mockStats) GetDuration() time.Duration { return m.duration }
 
 func TestPlaybookEnd(t *testing.T) {
@@ -273,4 +311,197 @@ func TestPlaybookEnd(t *testing.T) {
 	if !strings.Contains(output, "2.50s") {
 		t.Error("expected duration in output")
 	}
+	if strings.Contains(output, "unreachable") {
+		t.Error("expected no unreachable count without unreachable tasks")
+	}
+
+	buf.Reset()
+	stats.unreachable = 2
+	o.PlaybookEnd(stats)
+	if !strings.Contains(buf.String(), "skipped=2 unreachable=2") {
+		t.Errorf("expected unreachable=2 in output, got %q", buf.String())
+	}
+}
+
+func TestTaskResultFailedMessage(t *testing.T) {
+	var buf bytes.Buffer
+	o := New(&buf)
+	o.SetColor(false)
+
+	o.TaskResult("Build", "failed", false, "command failed with exit code 2: make\nstderr: no rule")
+
+	output := buf.String()
+	for _, want := range []string{"✗ Build", "→ command failed", "\n      stderr: no rule"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestTaskResultDetailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		verbose bool
+		debug   bool
+		status  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "compact",
+			status:  "changed",
+			want:    []string{"✓ Install nginx\n"},
+			notWant: []string{"[apt]", "(web1)"},
+		},
+		{
+			name:    "verbose",
+			verbose: true,
+			status:  "changed",
+			want:    []string{"✓ [apt] Install nginx (web1) changed 1.50s\n"},
+			notWant: []string{"stdout:"},
+		},
+		{
+			name:    "verbose failure",
+			verbose: true,
+			status:  "failed",
+			want:    []string{"✗ [apt] Install nginx (web1) FAILED 1.50s\n", "→ package not found"},
+		},
+		{
+			name:   "debug",
+			debug:  true,
+			status: "ok",
+			want:   []string{"[apt] Install nginx (web1) ok", "→ package not found", "stdout:", "Reading package lists"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			o := New(&buf)
+			o.SetColor(false)
+			o.SetVerbose(tt.verbose)
+			o.SetDebug(tt.debug)
+
+			o.TaskResultDetailed("Install nginx", "apt", "web1", tt.status, "package not found",
+				1500*time.Millisecond, map[string]any{"stdout": "Reading package lists..."})
+
+			output := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected output to contain %q, got %q", want, output)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("expected output not to contain %q, got %q", notWant, output)
+				}
+			}
+		})
+	}
+}
+
+func TestModes(t *testing.T) {
+	// run prints a playbook with one play of ok tasks and one play with
+	// the given results
+	run := func(mode Mode, statuses ...string) string {
+		var buf bytes.Buffer
+		o := New(&buf)
+		o.SetColor(false)
+		o.SetMode(mode)
+
+		stats := &mockStats{}
+		o.PlaybookStart("site.yaml")
+		o.PlayStart(&playbook.Play{Name: "base"})
+		o.TaskResult("Check", "ok", false, "")
+		stats.ok++
+		o.PlayStart(&playbook.Play{Name: "web"})
+		for _, status := range statuses {
+			o.TaskResult("Task "+status, status, false, "details")
+			switch status {
+			case "changed":
+				stats.changed++
+			case "failed":
+				stats.failed++
+			}
+		}
+		o.Info("retrying")
+		o.PlaybookEnd(stats)
+		return buf.String()
+	}
+
+	tests := []struct {
+		name     string
+		mode     Mode
+		statuses []string
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "normal",
+			mode:     ModeNormal,
+			statuses: []string{"ok"},
+			want:     []string{"PLAYBOOK", "PLAY base", "Check", "PLAY web", "INFO", "RECAP"},
+		},
+		{
+			name:     "quiet without failures",
+			mode:     ModeQuiet,
+			statuses: []string{"changed"},
+			want:     []string{"RECAP"},
+			notWant:  []string{"PLAYBOOK", "PLAY", "Task", "Check", "INFO"},
+		},
+		{
+			name:     "quiet with failure",
+			mode:     ModeQuiet,
+			statuses: []string{"changed", "failed"},
+			want:     []string{"PLAYBOOK site.yaml\n\nPLAY web\n  ✗ Task failed", "→ details", "RECAP"},
+			notWant:  []string{"PLAY base", "Task changed"},
+		},
+		{
+			name:     "changed-only without changes",
+			mode:     ModeChangedOnly,
+			statuses: []string{"ok", "skipped"},
+		},
+		{
+			name:     "changed-only with changes",
+			mode:     ModeChangedOnly,
+			statuses: []string{"ok", "changed"},
+			want:     []string{"PLAYBOOK site.yaml\n\nPLAY web\n  ✓ Task changed", "RECAP"},
+			notWant:  []string{"PLAY base", "Task ok", "INFO"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := run(tt.mode, tt.statuses...)
+			if len(tt.want) == 0 && output != "" {
+				t.Errorf("expected no output, got %q", output)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected output to contain %q, got %q", want, output)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("expected output not to contain %q, got %q", notWant, output)
+				}
+			}
+		})
+	}
+}
+
+func TestSetUnicode(t *testing.T) {
+	var buf bytes.Buffer
+	o := New(&buf)
+	o.SetColor(false)
+	o.SetUnicode(false)
+
+	o.TaskResult("Installed", "ok", false, "")
+	o.TaskResult("Skipped", "skipped", false, "")
+	o.TaskResult("Broken", "failed", false, "exit code 1")
+
+	want := "  + Installed\n  - Skipped\n  x Broken\n    -> exit code 1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
 }
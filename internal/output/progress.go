@@ -0,0 +1,291 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spinnerFrames animate running hosts in the live display.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// asciiSpinnerFrames replace spinnerFrames when Unicode is disabled.
+var asciiSpinnerFrames = []string{"|", "/", "-", "\\"}
+
+// progressInterval is how often the live display is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// IsTerminal reports whether w is a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// hostProgress is the progress of a single host.
+type hostProgress struct {
+	name     string
+	total    int
+	done     int
+	changed  int
+	failed   int
+	task     string
+	transfer string // progress of a large transfer of the current task
+	running  bool
+	err      error
+}
+
+// Progress shows one status line per host: a spinner, the number of tasks
+// done, and the current task. On a terminal the lines are redrawn in place;
+// otherwise a plain line is printed when a host starts and finishes.
+//
+// Progress is an io.Writer: anything written to it is printed above the
+// live lines, so an Output writing to it (e.g., failed tasks in quiet mode)
+// does not garble the display. All methods are safe for concurrent use and
+// do nothing on a nil *Progress.
+type Progress struct {
+	mu       sync.Mutex
+	w        io.Writer
+	live     bool
+	useColor bool
+	unicode  bool
+	hosts    []*hostProgress
+	byName   map[string]*hostProgress
+	lines    int
+	frame    int
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewProgress creates a progress display writing to w. live redraws the
+// host lines in place and should only be set if w is a terminal.
+func NewProgress(w io.Writer, live bool) *Progress {
+	return &Progress{
+		w:        w,
+		live:     live,
+		useColor: true,
+		unicode:  true,
+		byName:   make(map[string]*hostProgress),
+	}
+}
+
+// SetColor enables or disables color output.
+func (p *Progress) SetColor(enabled bool) {
+	p.useColor = enabled
+}
+
+// SetUnicode enables or disables Unicode glyphs and the braille spinner.
+func (p *Progress) SetUnicode(enabled bool) {
+	p.unicode = enabled
+}
+
+// Start begins redrawing the live display.
+func (p *Progress) Start() {
+	if p == nil || !p.live {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.frame++
+				p.redraw()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Stop draws the final state and stops redrawing. The host lines stay on
+// screen and later writes are printed below them.
+func (p *Progress) Stop() {
+	if p == nil || p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+	p.stop = nil
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.redraw()
+	p.live = false
+	p.lines = 0
+}
+
+// HostStart marks host as running total tasks.
+func (p *Progress) HostStart(host string, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := p.host(host)
+	h.total += total
+	h.running = true
+	if !p.live {
+		fmt.Fprintf(p.w, "%s %s: running %d task(s)\n", p.color(colorCyan, p.glyph("→")), host, total)
+	}
+}
+
+// TaskStart records the task host is running.
+func (p *Progress) TaskStart(host, task string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.host(host)
+	h.task = task
+	h.transfer = ""
+}
+
+// TaskDone counts a finished task of host with the given status.
+func (p *Progress) TaskDone(host, status string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := p.host(host)
+	h.done++
+	switch {
+	case strings.HasPrefix(status, "changed"):
+		h.changed++
+	case isFailure(status):
+		h.failed++
+	}
+}
+
+// HostDone marks host as finished, failed if err is set.
+func (p *Progress) HostDone(host string, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := p.host(host)
+	h.running = false
+	h.err = err
+	if !p.live {
+		fmt.Fprintf(p.w, "%s\n", p.render(h))
+	}
+}
+
+// Write prints data above the live display.
+func (p *Progress) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.live {
+		return p.w.Write(data)
+	}
+
+	p.clear()
+	n, err := p.w.Write(data)
+	p.draw()
+	return n, err
+}
+
+// host returns the progress of host, adding it if it is new.
+func (p *Progress) host(name string) *hostProgress {
+	h, ok := p.byName[name]
+	if !ok {
+		h = &hostProgress{name: name}
+		p.byName[name] = h
+		p.hosts = append(p.hosts, h)
+	}
+	return h
+}
+
+// redraw replaces the live display with the current state.
+func (p *Progress) redraw() {
+	p.clear()
+	p.draw()
+}
+
+// clear erases the lines drawn last.
+func (p *Progress) clear() {
+	if p.lines == 0 {
+		return
+	}
+	// Move up to the first line and clear to the end of the screen
+	fmt.Fprintf(p.w, "\033[%dA\r\033[J", p.lines)
+	p.lines = 0
+}
+
+// draw prints one line per host.
+func (p *Progress) draw() {
+	var b strings.Builder
+	for _, h := range p.hosts {
+		b.WriteString(p.render(h))
+		b.WriteString("\n")
+	}
+	fmt.Fprint(p.w, b.String())
+	p.lines = len(p.hosts)
+}
+
+// render formats the status line of a host.
+func (p *Progress) render(h *hostProgress) string {
+	counts := fmt.Sprintf("[%d/%d]", h.done, h.total)
+	switch {
+	case h.running:
+		frames := spinnerFrames
+		if !p.unicode {
+			frames = asciiSpinnerFrames
+		}
+		frame := frames[p.frame%len(frames)]
+		line := fmt.Sprintf("%s %s %s %s", p.color(colorCyan, frame), h.name, p.color(colorGray, counts), h.task)
+		if h.transfer != "" {
+			line += " " + p.color(colorGray, h.transfer)
+		}
+		return line
+	case h.err != nil:
+		return fmt.Sprintf("%s %s %s %s", p.color(colorRed, p.glyph("✗")), h.name, counts, p.color(colorRed, "failed: "+firstLine(h.err.Error())))
+	}
+	summary := fmt.Sprintf("changed=%d failed=%d", h.changed, h.failed)
+	return fmt.Sprintf("%s %s %s %s", p.color(colorGreen, p.glyph("✓")), h.name, counts, p.color(colorGray, summary))
+}
+
+// color returns the string wrapped in color codes if enabled.
+func (p *Progress) color(c, s string) string {
+	if !p.useColor {
+		return s
+	}
+	return c + s + colorReset
+}
+
+// glyph returns g, or its ASCII replacement if Unicode is disabled.
+func (p *Progress) glyph(g string) string {
+	if p.unicode {
+		return g
+	}
+	return asciiGlyphs[g]
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
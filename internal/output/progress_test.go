@@ -0,0 +1,116 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+func TestProgressPlain(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, false)
+	p.SetColor(false)
+	p.Start()
+
+	p.HostStart("web1", 3)
+	p.HostStart("web2", 3)
+	for _, status := range []string{"ok", "changed", "changed (dry run)"} {
+		p.TaskStart("web1", "task")
+		p.TaskDone("web1", status)
+	}
+	p.TaskDone("web2", "failed")
+	p.HostDone("web1", nil)
+	p.HostDone("web2", errors.New("apt failed\nstderr: lock held"))
+	p.Stop()
+
+	want := "→ web1: running 3 task(s)\n" +
+		"→ web2: running 3 task(s)\n" +
+		"✓ web1 [3/3] changed=2 failed=0\n" +
+		"✗ web2 [1/3] failed: apt failed\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestProgressLive(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, true)
+	p.SetColor(false)
+
+	p.HostStart("web1", 2)
+	p.TaskStart("web1", "Install nginx")
+	p.redraw()
+
+	if !strings.Contains(buf.String(), "web1 [0/2] Install nginx\n") {
+		t.Errorf("expected running host line, got %q", buf.String())
+	}
+
+	// Writes clear the host lines, print, and draw them again
+	buf.Reset()
+	if _, err := p.Write([]byte("  ✗ Failed task\n")); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if !strings.HasPrefix(got, "\033[1A\r\033[J  ✗ Failed task\n") {
+		t.Errorf("expected clear before write, got %q", got)
+	}
+	if !strings.HasSuffix(got, "web1 [0/2] Install nginx\n") {
+		t.Errorf("expected host line redrawn after write, got %q", got)
+	}
+}
+
+func TestProgressNil(t *testing.T) {
+	var p *Progress
+	p.Start()
+	p.HostStart("web1", 1)
+	p.TaskStart("web1", "task")
+	p.TaskDone("web1", "ok")
+	p.HostDone("web1", nil)
+	p.Stop()
+}
+
+func TestProgressASCII(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, true)
+	p.SetColor(false)
+	p.SetUnicode(false)
+
+	p.HostStart("web1", 1)
+	p.redraw()
+	if !strings.HasPrefix(buf.String(), "| web1 [0/1]") {
+		t.Errorf("expected ASCII spinner, got %q", buf.String())
+	}
+
+	p.TaskDone("web1", "ok")
+	p.HostDone("web1", nil)
+	buf.Reset()
+	p.redraw()
+	if !strings.Contains(buf.String(), "+ web1 [1/1]") {
+		t.Errorf("expected ASCII check mark, got %q", buf.String())
+	}
+}
+
+func TestProgressTransfer(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, false)
+	p.SetColor(false)
+
+	p.HostStart("web1", 1)
+	p.TaskStart("web1", "Ship release")
+	p.Transfer("web1", connector.Transfer{Op: "upload", Path: "/opt/app.tar.gz", Bytes: 921_600_000, Total: 2_000_000_000, Elapsed: 20 * time.Second})
+	if got := p.render(p.host("web1")); !strings.HasSuffix(got, "Ship release uploading /opt/app.tar.gz: 46% (921.6 MB of 2.0 GB, 46.1 MB/s)") {
+		t.Errorf("host line = %q", got)
+	}
+
+	p.Transfer("web1", connector.Transfer{Op: "upload", Path: "/opt/app.tar.gz", Bytes: 2_000_000_000, Total: 2_000_000_000, Elapsed: 40 * time.Second, Done: true})
+	if got := p.render(p.host("web1")); strings.Contains(got, "uploading") {
+		t.Errorf("host line = %q, want the transfer cleared", got)
+	}
+	if want := "→ web1: uploaded /opt/app.tar.gz: 2.0 GB in 40s (50.0 MB/s)\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,63 @@
+package output
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Transfer prints the progress of a large upload or download to host.
+// Quiet and changed-only mode omit it.
+func (o *Output) Transfer(host string, t connector.Transfer) {
+	o.Info("%s: %s", host, describeTransfer(t))
+}
+
+// Transfer shows the progress of a large upload or download next to the
+// current task of host. In plain mode it is only printed when it ends.
+func (p *Progress) Transfer(host string, t connector.Transfer) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := p.host(host)
+	if !t.Done {
+		h.transfer = describeTransfer(t)
+		return
+	}
+	h.transfer = ""
+	if !p.live {
+		fmt.Fprintf(p.w, "%s %s: %s\n", p.color(colorCyan, p.glyph("→")), host, describeTransfer(t))
+	}
+}
+
+// describeTransfer formats the progress of a transfer:
+//
+//	uploading /opt/app.tar.gz: 45% (921.6 MB of 2.0 GB, 48.2 MB/s)
+//	uploaded /opt/app.tar.gz: 2.0 GB in 41s (48.2 MB/s)
+func describeTransfer(t connector.Transfer) string {
+	rate := formatBytes(int64(t.Rate())) + "/s"
+	if t.Done {
+		return fmt.Sprintf("%sed %s: %s in %s (%s)", t.Op, t.Path, formatBytes(t.Bytes), t.Elapsed.Round(time.Second), rate)
+	}
+	if pct := t.Percent(); pct >= 0 {
+		return fmt.Sprintf("%sing %s: %d%% (%s of %s, %s)", t.Op, t.Path, pct, formatBytes(t.Bytes), formatBytes(t.Total), rate)
+	}
+	return fmt.Sprintf("%sing %s: %s (%s)", t.Op, t.Path, formatBytes(t.Bytes), rate)
+}
+
+// formatBytes formats a size in decimal units, e.g. "921.6 MB".
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,94 @@
+package playbook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ansible compatibility
+//
+// The parser accepts the parts of Ansible's syntax that have the same
+// meaning in bolt, so simple Ansible playbooks run unchanged: fully
+// qualified module names (ansible.builtin.copy), the shell module, and
+// yes/no booleans. Everything else is left to `bolt convert`, which
+// rewrites a playbook and reports what it could not translate.
+
+// ansibleCollections are the collection prefixes stripped from module names.
+var ansibleCollections = []string{"ansible.builtin.", "ansible.legacy."}
+
+// moduleAliases maps Ansible module names to the bolt module that behaves
+// the same way.
+var moduleAliases = map[string]string{
+	"shell": "command", // bolt's command module always runs through a shell
+}
+
+// ModuleName returns the bolt module name for a module name that may be
+// written the Ansible way.
+func ModuleName(name string) string {
+	for _, prefix := range ansibleCollections {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	if alias, ok := moduleAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// ansibleDirectives are Ansible task and play keywords bolt does not
+// support. They are rejected with a hint instead of being mistaken for
+// module names.
+var ansibleDirectives = map[string]bool{
+	"block":         true,
+	"rescue":        true,
+	"always":        true,
+	"delegate_to":   true,
+	"run_once":      true,
+	"no_log":        true,
+	"environment":   true,
+	"check_mode":    true,
+	"diff":          true,
+	"until":         true,
+	"loop_control":  true,
+	"args":          true,
+	"local_action":  true,
+	"include_tasks": true,
+	"import_tasks":  true,
+	"include_role":  true,
+	"import_role":   true,
+}
+
+// unsupportedDirective returns the error for an Ansible keyword bolt does
+// not support.
+func unsupportedDirective(key string) error {
+	return fmt.Errorf("'%s' is not supported by bolt (run 'bolt convert' to migrate Ansible playbooks)", key)
+}
+
+// parseBool reads a boolean written as a YAML boolean or as one of
+// Ansible's yes/no/true/false strings.
+func parseBool(v any) (value bool, ok bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		switch strings.ToLower(b) {
+		case "yes", "true", "on":
+			return true, true
+		case "no", "false", "off":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// parseCondition reads a condition written as a string or, as Ansible
+// allows for changed_when and failed_when, as a plain boolean.
+func parseCondition(v any) (string, bool) {
+	switch c := v.(type) {
+	case string:
+		return c, true
+	case bool:
+		return strconv.FormatBool(c), true
+	}
+	return "", false
+}
@@ -0,0 +1,154 @@
+package playbook
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNoTaskGraph is the error for a task with after or requires in a play
+// that runs its tasks one by one.
+var ErrNoTaskGraph = errors.New("'after' and 'requires' need 'task_graph: true' on the play")
+
+// errNoTask is returned by matchTask if no task in scope has the name.
+var errNoTask = errors.New("no task named")
+
+// Dependency is a task that must finish before another task starts.
+type Dependency struct {
+	// Task is the task depended on.
+	Task *Task
+
+	// Required is set for requires: the dependent task is skipped unless
+	// Task succeeded.
+	Required bool
+}
+
+// TaskGraph holds the dependencies between the tasks of a play.
+type TaskGraph struct {
+	deps map[*Task][]Dependency
+}
+
+// NewTaskGraph resolves the after and requires entries of tasks to the
+// tasks they name. Names are looked up like notify looks up handlers: in
+// the task's own role first, then among the play's tasks, then in every
+// role; a name qualified with a role ("web : install") only matches that
+// role. It fails if a name matches no task or several, or if the
+// dependencies form a cycle.
+func NewTaskGraph(tasks []*Task) (*TaskGraph, error) {
+	g := &TaskGraph{deps: make(map[*Task][]Dependency)}
+	for _, task := range tasks {
+		for _, dep := range []struct {
+			key      string
+			names    []string
+			required bool
+		}{
+			{"after", task.After, false},
+			{"requires", task.Requires, true},
+		} {
+			for _, name := range dep.names {
+				target, err := findTask(tasks, task, name)
+				if err != nil {
+					return nil, ErrorAt(task.Pos, task.String(), fmt.Errorf("%s: %w", dep.key, err))
+				}
+				g.deps[task] = append(g.deps[task], Dependency{Task: target, Required: dep.required})
+			}
+		}
+	}
+
+	if err := g.checkCycles(tasks); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Dependencies returns the tasks task depends on.
+func (g *TaskGraph) Dependencies(task *Task) []Dependency {
+	return g.deps[task]
+}
+
+// checkCycles fails if a task depends on itself, directly or through
+// other tasks.
+func (g *TaskGraph) checkCycles(tasks []*Task) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[*Task]int, len(tasks))
+	var path []*Task
+
+	var visit func(task *Task) error
+	visit = func(task *Task) error {
+		switch marks[task] {
+		case visited:
+			return nil
+		case visiting:
+			// The cycle is the part of the path from the first visit on
+			var names []string
+			for _, t := range path[slices.Index(path, task):] {
+				names = append(names, t.String())
+			}
+			names = append(names, task.String())
+			return ErrorAt(task.Pos, task.String(), fmt.Errorf("dependency cycle: %s", strings.Join(names, " -> ")))
+		}
+
+		marks[task] = visiting
+		path = append(path, task)
+		for _, dep := range g.deps[task] {
+			if err := visit(dep.Task); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[task] = visited
+		return nil
+	}
+
+	for _, task := range tasks {
+		if err := visit(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findTask returns the task from refers to by name.
+func findTask(tasks []*Task, from *Task, name string) (*Task, error) {
+	if role, taskName, ok := strings.Cut(name, " : "); ok {
+		role = strings.TrimSpace(role)
+		return matchTask(tasks, name, strings.TrimSpace(taskName), func(t *Task) bool {
+			return t.RolePath != "" && filepath.Base(t.RolePath) == role
+		})
+	}
+
+	scopes := []func(*Task) bool{
+		func(t *Task) bool { return from.RolePath != "" && t.RolePath == from.RolePath },
+		func(t *Task) bool { return t.RolePath == "" },
+		func(t *Task) bool { return true },
+	}
+	for _, inScope := range scopes {
+		if task, err := matchTask(tasks, name, name, inScope); task != nil || !errors.Is(err, errNoTask) {
+			return task, err
+		}
+	}
+	return nil, fmt.Errorf("%w '%s'", errNoTask, name)
+}
+
+// matchTask returns the single task in scope named taskName. ref is the
+// name as written, for errors.
+func matchTask(tasks []*Task, ref, taskName string, inScope func(*Task) bool) (*Task, error) {
+	var matches []*Task
+	for _, t := range tasks {
+		if inScope(t) && t.Name == taskName {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w '%s'", errNoTask, ref)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("%d tasks are named '%s'; give them unique names or qualify the name with its role", len(matches), ref)
+}
@@ -0,0 +1,41 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTaskGraph(t *testing.T) {
+	webInstall := &Task{Name: "install", Module: "apt", RolePath: "/roles/web"}
+	dbInstall := &Task{Name: "install", Module: "apt", RolePath: "/roles/db"}
+	webStart := &Task{Name: "start", Module: "service", RolePath: "/roles/web", Requires: []string{"install"}}
+	migrate := &Task{Name: "migrate", Module: "command", After: []string{"db : install", "start"}}
+	tasks := []*Task{webInstall, dbInstall, webStart, migrate}
+
+	g, err := NewTaskGraph(tasks)
+	if err != nil {
+		t.Fatalf("NewTaskGraph() error = %v", err)
+	}
+
+	// A role task's own role comes first
+	if deps := g.Dependencies(webStart); len(deps) != 1 || deps[0].Task != webInstall || !deps[0].Required {
+		t.Errorf("start dependencies = %+v, want web's install, required", deps)
+	}
+	if deps := g.Dependencies(migrate); len(deps) != 2 || deps[0].Task != dbInstall || deps[1].Task != webStart || deps[0].Required {
+		t.Errorf("migrate dependencies = %+v, want db's install and start", deps)
+	}
+	if deps := g.Dependencies(webInstall); len(deps) != 0 {
+		t.Errorf("install dependencies = %+v, want none", deps)
+	}
+
+	// A play task naming a task of two roles is ambiguous
+	migrate.After = []string{"install"}
+	if _, err := NewTaskGraph(tasks); err == nil || !strings.Contains(err.Error(), "2 tasks are named 'install'") {
+		t.Errorf("NewTaskGraph() with an ambiguous name error = %v", err)
+	}
+
+	self := &Task{Name: "loop", Module: "command", After: []string{"loop"}}
+	if _, err := NewTaskGraph([]*Task{self}); err == nil || !strings.Contains(err.Error(), "dependency cycle: loop -> loop") {
+		t.Errorf("NewTaskGraph() with a self dependency error = %v", err)
+	}
+}
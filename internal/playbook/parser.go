@@ -3,6 +3,7 @@ package playbook
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -12,20 +13,32 @@ import (
 
 // knownTaskFields are fields that are task directives, not module names.
 var knownTaskFields = map[string]bool{
-	"name":         true,
-	"when":         true,
-	"register":     true,
-	"notify":       true,
-	"loop":         true,
-	"with_items":   true,
-	"loop_var":     true,
-	"ignore_errors": true,
-	"retries":      true,
-	"delay":        true,
-	"become":       true,
-	"become_user":  true,
-	"changed_when": true,
-	"failed_when":  true,
+	"name":               true,
+	"when":               true,
+	"register":           true,
+	"notify":             true,
+	"listen":             true,
+	"loop":               true,
+	"with_items":         true,
+	"with_first_found":   true,
+	"loop_var":           true,
+	"loop_parallel":      true,
+	"ignore_errors":      true,
+	"ignore_unreachable": true,
+	"retries":            true,
+	"delay":              true,
+	"become":             true,
+	"become_user":        true,
+	"changed_when":       true,
+	"failed_when":        true,
+	"creates":            true,
+	"removes":            true,
+	"confirm":            true,
+	"tags":               true,
+	"vars":               true,
+	"proxy":              true,
+	"after":              true,
+	"requires":           true,
 }
 
 // ParseFile parses a playbook from a YAML file.
@@ -114,27 +127,53 @@ func ParseFileRaw(path string) (*Playbook, error) {
 }
 
 // ParseRaw parses a playbook with proper module detection.
+// Plays and tasks record their file:line position so errors can point at
+// the offending YAML.
 func ParseRaw(data []byte, path string) (*Playbook, error) {
-	// First, try to unmarshal as a list of raw play maps
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid playbook format: %w", err)
+	}
+	root := documentRoot(&doc)
+
+	playbook := &Playbook{Path: path}
+	if root == nil {
+		return playbook, nil
+	}
+
+	// First, try to decode as a list of raw play maps
 	var rawPlays []map[string]any
-	if err := yaml.Unmarshal(data, &rawPlays); err != nil {
+	var playNodes []*yaml.Node
+	if root.Kind == yaml.SequenceNode {
+		if err := root.Decode(&rawPlays); err != nil {
+			return nil, fmt.Errorf("invalid playbook format: %w", err)
+		}
+		playNodes = root.Content
+	} else {
 		// Try as single play
 		var rawPlay map[string]any
-		if err := yaml.Unmarshal(data, &rawPlay); err != nil {
+		if err := root.Decode(&rawPlay); err != nil {
 			return nil, fmt.Errorf("invalid playbook format: %w", err)
 		}
 		rawPlays = []map[string]any{rawPlay}
+		playNodes = []*yaml.Node{root}
 	}
 
-	playbook := &Playbook{Path: path}
-
 	for i, rawPlay := range rawPlays {
-		play, err := parseRawPlay(rawPlay)
+		var node *yaml.Node
+		if i < len(playNodes) {
+			node = playNodes[i]
+		}
+		pos := nodePosition(path, node)
+		context := fmt.Sprintf("play %d", i+1)
+
+		play, err := parseRawPlay(rawPlay, node, path)
 		if err != nil {
-			return nil, fmt.Errorf("play %d: %w", i+1, err)
+			return nil, ErrorAt(pos, context, err)
 		}
+		play.Pos = pos
 		if err := play.Validate(); err != nil {
-			return nil, fmt.Errorf("play %d: %w", i+1, err)
+			return nil, ErrorAt(pos, context, err)
 		}
 		playbook.Plays = append(playbook.Plays, play)
 	}
@@ -143,36 +182,100 @@ func ParseRaw(data []byte, path string) (*Playbook, error) {
 }
 
 // parseRawPlay parses a single play from a raw map.
-func parseRawPlay(raw map[string]any) (*Play, error) {
+// node is the YAML node of the play and is used to record task positions.
+func parseRawPlay(raw map[string]any, node *yaml.Node, path string) (*Play, error) {
 	play := &Play{
 		Vars: make(map[string]any),
 	}
+	var err error
 
 	// Parse simple fields
 	if v, ok := raw["name"].(string); ok {
 		play.Name = v
 	}
-	if v, ok := raw["hosts"].(string); ok {
+	switch v := raw["hosts"].(type) {
+	case string:
 		play.Hosts = v
+	case []any:
+		patterns := make([]string, 0, len(v))
+		for _, item := range v {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("hosts must be a string or a list of strings")
+			}
+			patterns = append(patterns, pattern)
+		}
+		play.Hosts = strings.Join(patterns, ",")
 	}
 	if v, ok := raw["connection"].(string); ok {
 		play.Connection = v
 	}
-	if v, ok := raw["become"].(bool); ok {
+	if v, ok := parseBool(raw["become"]); ok {
 		play.Become = v
 	}
 	if v, ok := raw["become_user"].(string); ok {
 		play.BecomeUser = v
 	}
-	if v, ok := raw["gather_facts"].(bool); ok {
+	if v, ok := parseBool(raw["become_keep_ownership"]); ok {
+		play.BecomeKeepOwnership = v
+	}
+	if v, ok := parseBool(raw["gather_facts"]); ok {
 		play.GatherFacts = &v
 	}
+	if v, ok := parseBool(raw["strict_vars"]); ok {
+		play.StrictVars = &v
+	}
+	if v, ok := parseBool(raw["force_handlers"]); ok {
+		play.ForceHandlers = &v
+	}
+	if v, ok := parseBool(raw["ignore_unreachable"]); ok {
+		play.IgnoreUnreachable = v
+	}
+	if play.Confirm, err = parseConfirm(raw["confirm"], "Run this play?"); err != nil {
+		return nil, err
+	}
+	if play.Tags, err = parseTags(raw["tags"]); err != nil {
+		return nil, err
+	}
+	if v, ok := raw["connection_retries"].(int); ok {
+		play.ConnectionRetries = &v
+	}
+	if v, ok := raw["connection_retry_delay"].(int); ok {
+		play.ConnectionRetryDelay = &v
+	}
+	if v, ok := parseBool(raw["task_graph"]); ok {
+		play.TaskGraph = v
+	}
+	if v, ok := raw["task_parallel"].(int); ok {
+		play.TaskParallel = v
+	}
+
+	if play.Proxy, err = parseProxy(raw["proxy"]); err != nil {
+		return nil, err
+	}
+
+	if play.UpdateStrategy, err = parseUpdateStrategy(raw["update_strategy"], mappingValue(node, "update_strategy"), path); err != nil {
+		return nil, err
+	}
 
 	// Parse vars
 	if vars, ok := raw["vars"].(map[string]any); ok {
 		play.Vars = vars
 	}
 
+	if play.Collections, err = parseCollections(raw["collections"]); err != nil {
+		return nil, err
+	}
+	if play.ModuleAliases, err = parseModuleAliases(raw["module_aliases"]); err != nil {
+		return nil, err
+	}
+
+	defaults, err := parseModuleDefaults(raw["module_defaults"], play)
+	if err != nil {
+		return nil, err
+	}
+	play.ModuleDefaults = defaults
+
 	// Parse roles
 	if roles, ok := raw["roles"].([]any); ok {
 		for _, role := range roles {
@@ -183,36 +286,272 @@ func parseRawPlay(raw map[string]any) (*Play, error) {
 	}
 
 	// Parse tasks
-	if tasks, ok := raw["tasks"].([]any); ok {
-		for i, rawTask := range tasks {
-			taskMap, ok := rawTask.(map[string]any)
-			if !ok {
-				return nil, fmt.Errorf("task %d: invalid task format", i+1)
+	tasks, err := parseRawTaskList(raw["tasks"], mappingValue(node, "tasks"), path, "task")
+	if err != nil {
+		return nil, err
+	}
+	play.Tasks = tasks
+
+	// Parse handlers
+	handlers, err := parseRawTaskList(raw["handlers"], mappingValue(node, "handlers"), path, "handler")
+	if err != nil {
+		return nil, err
+	}
+	play.Handlers = handlers
+
+	play.ResolveModules(play.Tasks)
+	play.ResolveModules(play.Handlers)
+	if s := play.UpdateStrategy; s != nil {
+		play.ResolveModules(s.PreBatch)
+		play.ResolveModules(s.PostBatch)
+	}
+
+	return play, nil
+}
+
+// parseConfirm parses a confirm directive: true asks question, a string
+// asks itself, and false or nothing asks nothing.
+func parseConfirm(raw any, question string) (string, error) {
+	if raw == nil {
+		return "", nil
+	}
+	if v, ok := parseBool(raw); ok {
+		if v {
+			return question, nil
+		}
+		return "", nil
+	}
+	if v, ok := raw.(string); ok && v != "" {
+		return v, nil
+	}
+	return "", fmt.Errorf("confirm must be a boolean or a question")
+}
+
+// parseTags parses a tags directive: a tag, a comma-separated list of
+// tags, or a list of tags.
+func parseTags(raw any) ([]string, error) {
+	var items []any
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		for _, tag := range strings.Split(v, ",") {
+			items = append(items, strings.TrimSpace(tag))
+		}
+	case []any:
+		items = v
+	default:
+		return nil, fmt.Errorf("tags must be a tag or a list of tags")
+	}
+
+	tags := make([]string, 0, len(items))
+	for _, item := range items {
+		tag, ok := item.(string)
+		if !ok || !ValidTag(tag) {
+			return nil, fmt.Errorf("invalid tag %v: tags are words of letters, digits, '_', '-', '.', and ':' other than and, or, and not", item)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// parseCollections parses a play's collections: a list of collection
+// names, <namespace>.<collection>.
+func parseCollections(raw any) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("collections must be a list of collection names")
+	}
+
+	collections := make([]string, 0, len(list))
+	for _, item := range list {
+		name, ok := item.(string)
+		parts := strings.Split(name, ".")
+		if !ok || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("collection '%v' must be named <namespace>.<collection>", item)
+		}
+		collections = append(collections, name)
+	}
+	return collections, nil
+}
+
+// parseModuleAliases parses a play's module_aliases: a mapping of names
+// to the modules they stand for.
+func parseModuleAliases(raw any) (map[string]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("module_aliases must be a mapping of names to module names")
+	}
+
+	aliases := make(map[string]string, len(m))
+	for alias, target := range m {
+		name, ok := target.(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("module_aliases for '%s' must be a module name", alias)
+		}
+		if knownTaskFields[alias] || ansibleDirectives[alias] {
+			return nil, fmt.Errorf("module_aliases: '%s' is a task keyword", alias)
+		}
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
+
+// parseModuleDefaults parses a play's module_defaults: a mapping of module
+// names, which may be written the Ansible way or resolved through the
+// play's collections and module aliases, to default parameters.
+func parseModuleDefaults(raw any, play *Play) (map[string]map[string]any, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	modules, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("module_defaults must be a mapping of module names to parameters")
+	}
+
+	defaults := make(map[string]map[string]any, len(modules))
+	for name, params := range modules {
+		p, ok := params.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("module_defaults for '%s' must be a mapping of parameters", name)
+		}
+		name = play.ModuleName(ModuleName(name))
+		if defaults[name] == nil {
+			defaults[name] = make(map[string]any)
+		}
+		for k, v := range p {
+			defaults[name][k] = v
+		}
+	}
+	return defaults, nil
+}
+
+// parseProxy parses the proxy of a play or task: a URL used for both http
+// and https, or a mapping of http_proxy, https_proxy, and no_proxy, where
+// no_proxy may be a list.
+func parseProxy(raw any) (*Proxy, error) {
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return &Proxy{HTTP: v, HTTPS: v}, nil
+	case map[string]any:
+		proxy := &Proxy{}
+		for key, value := range v {
+			var s string
+			switch value := value.(type) {
+			case string:
+				s = value
+			case []any:
+				if key != "no_proxy" {
+					return nil, fmt.Errorf("proxy %s must be a string", key)
+				}
+				s = strings.Join(parseStringList(value), ",")
+			case nil:
+			default:
+				return nil, fmt.Errorf("proxy %s must be a string", key)
 			}
-			task, err := parseRawTask(taskMap)
-			if err != nil {
-				return nil, fmt.Errorf("task %d: %w", i+1, err)
+			switch key {
+			case "http_proxy":
+				proxy.HTTP = s
+			case "https_proxy":
+				proxy.HTTPS = s
+			case "no_proxy":
+				proxy.NoProxy = s
+			default:
+				return nil, fmt.Errorf("unknown proxy setting '%s' (must be http_proxy, https_proxy, or no_proxy)", key)
 			}
-			play.Tasks = append(play.Tasks, task)
 		}
+		return proxy, nil
 	}
+	return nil, fmt.Errorf("proxy must be a URL or a mapping of http_proxy, https_proxy, and no_proxy")
+}
 
-	// Parse handlers
-	if handlers, ok := raw["handlers"].([]any); ok {
-		for i, rawHandler := range handlers {
-			handlerMap, ok := rawHandler.(map[string]any)
-			if !ok {
-				return nil, fmt.Errorf("handler %d: invalid handler format", i+1)
-			}
-			handler, err := parseRawTask(handlerMap)
-			if err != nil {
-				return nil, fmt.Errorf("handler %d: %w", i+1, err)
+// parseUpdateStrategy parses a play's update_strategy: a mapping of
+// batch_size (a number of hosts or a percentage), delegate_to, and the
+// pre_batch and post_batch task lists.
+func parseUpdateStrategy(raw any, node *yaml.Node, path string) (*UpdateStrategy, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("update_strategy must be a mapping")
+	}
+
+	strategy := &UpdateStrategy{}
+	for key, value := range m {
+		var err error
+		switch key {
+		case "batch_size":
+			strategy.BatchSize, err = parseBatchSize(value)
+		case "delegate_to":
+			s, ok := value.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("update_strategy delegate_to must be a host name")
 			}
-			play.Handlers = append(play.Handlers, handler)
+			strategy.DelegateTo = s
+		case "pre_batch":
+			strategy.PreBatch, err = parseRawTaskList(value, mappingValue(node, key), path, "pre_batch task")
+		case "post_batch":
+			strategy.PostBatch, err = parseRawTaskList(value, mappingValue(node, key), path, "post_batch task")
+		default:
+			return nil, fmt.Errorf("unknown update_strategy setting '%s' (must be batch_size, delegate_to, pre_batch, or post_batch)", key)
+		}
+		if err != nil {
+			return nil, err
 		}
 	}
+	return strategy, nil
+}
 
-	return play, nil
+// parseBatchSize parses the batch_size of an update strategy: a positive
+// number of hosts, or a percentage from 1% to 100%.
+func parseBatchSize(v any) (string, error) {
+	s := fmt.Sprint(v)
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	switch {
+	case err != nil || n < 1:
+		return "", fmt.Errorf("update_strategy batch_size must be a positive number of hosts or a percentage, got '%v'", v)
+	case strings.HasSuffix(s, "%") && n > 100:
+		return "", fmt.Errorf("update_strategy batch_size must be at most 100%%, got '%s'", s)
+	}
+	return s, nil
+}
+
+// parseRawTaskList parses a list of raw task maps, recording the position
+// of each task from the matching sequence node.
+func parseRawTaskList(raw any, node *yaml.Node, path, kind string) ([]*Task, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	tasks := make([]*Task, 0, len(items))
+	for i, rawTask := range items {
+		pos := nodePosition(path, sequenceItem(node, i))
+		context := fmt.Sprintf("%s %d", kind, i+1)
+
+		taskMap, ok := rawTask.(map[string]any)
+		if !ok {
+			return nil, ErrorAt(pos, context, fmt.Errorf("invalid %s format", kind))
+		}
+		task, err := parseRawTask(taskMap)
+		if err != nil {
+			return nil, ErrorAt(pos, context, err)
+		}
+		task.Pos = pos
+		task.Warnings = taskWarnings(taskMap, task)
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
 }
 
 // parseRawTask parses a single task from a raw map.
@@ -234,41 +573,67 @@ func parseRawTask(raw map[string]any) (*Task, error) {
 	if v, ok := raw["loop_var"].(string); ok {
 		task.LoopVar = v
 	}
-	if v, ok := raw["ignore_errors"].(bool); ok {
+	if v, ok := raw["loop_parallel"].(int); ok {
+		task.LoopParallel = v
+	}
+	if v, ok := parseBool(raw["ignore_errors"]); ok {
 		task.IgnoreErrors = v
 	}
+	if v, ok := parseBool(raw["ignore_unreachable"]); ok {
+		task.IgnoreUnreachable = &v
+	}
 	if v, ok := raw["retries"].(int); ok {
 		task.Retries = v
 	}
 	if v, ok := raw["delay"].(int); ok {
 		task.Delay = v
 	}
-	if v, ok := raw["become"].(bool); ok {
+	if v, ok := parseBool(raw["become"]); ok {
 		task.Become = &v
 	}
 	if v, ok := raw["become_user"].(string); ok {
 		task.BecomeUser = v
 	}
-	if v, ok := raw["changed_when"].(string); ok {
+	if v, ok := parseCondition(raw["changed_when"]); ok {
 		task.ChangedWhen = v
 	}
-	if v, ok := raw["failed_when"].(string); ok {
+	if v, ok := parseCondition(raw["failed_when"]); ok {
 		task.FailedWhen = v
 	}
-
-	// Parse notify (can be string or list)
-	if notify, ok := raw["notify"]; ok {
-		switch n := notify.(type) {
-		case string:
-			task.Notify = []string{n}
-		case []any:
-			for _, item := range n {
-				if s, ok := item.(string); ok {
-					task.Notify = append(task.Notify, s)
-				}
-			}
-		}
+	if v, ok := raw["creates"].(string); ok {
+		task.Creates = v
+	}
+	if v, ok := raw["removes"].(string); ok {
+		task.Removes = v
 	}
+	confirm, err := parseConfirm(raw["confirm"], "Run this task?")
+	if err != nil {
+		return nil, err
+	}
+	task.Confirm = confirm
+	if task.Tags, err = parseTags(raw["tags"]); err != nil {
+		return nil, err
+	}
+	switch v := raw["vars"].(type) {
+	case nil:
+	case map[string]any:
+		task.Vars = v
+	default:
+		return nil, fmt.Errorf("vars must be a mapping")
+	}
+	proxy, err := parseProxy(raw["proxy"])
+	if err != nil {
+		return nil, err
+	}
+	task.Proxy = proxy
+
+	// Parse notify and listen (can be string or list)
+	task.Notify = parseStringList(raw["notify"])
+	task.Listen = parseStringList(raw["listen"])
+
+	// Parse after and requires (can be string or list)
+	task.After = parseStringList(raw["after"])
+	task.Requires = parseStringList(raw["requires"])
 
 	// Parse loop (can be "loop" or "with_items")
 	if loop, ok := raw["loop"]; ok {
@@ -281,18 +646,33 @@ func parseRawTask(raw map[string]any) (*Task, error) {
 		}
 	}
 
+	// with_first_found runs the task once, for the first existing file
+	if v, ok := raw["with_first_found"]; ok {
+		files, ok := v.([]any)
+		if !ok || len(files) == 0 {
+			return nil, fmt.Errorf("with_first_found must be a list of files")
+		}
+		if task.Loop != nil {
+			return nil, fmt.Errorf("with_first_found cannot be combined with loop")
+		}
+		task.FirstFound = files
+	}
+
 	// Find the module - it's a key that's not a known task field
 	for key, value := range raw {
 		if knownTaskFields[key] {
 			continue
 		}
+		if ansibleDirectives[key] {
+			return nil, unsupportedDirective(key)
+		}
 
 		// This must be the module name
 		if task.Module != "" {
 			return nil, fmt.Errorf("multiple modules specified: %s and %s", task.Module, key)
 		}
 
-		task.Module = key
+		task.Module = ModuleName(key)
 
 		// Parse module parameters
 		switch params := value.(type) {
@@ -368,3 +748,21 @@ func ResolveModule(task *Task) error {
 
 	return nil
 }
+
+// parseStringList reads a value written as a single string or a list of
+// strings.
+func parseStringList(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []any:
+		var list []string
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
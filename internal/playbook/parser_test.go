@@ -1,7 +1,11 @@
 package playbook
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/module"
 )
 
 func TestParseRaw(t *testing.T) {
@@ -139,6 +143,33 @@ tasks:
 	}
 }
 
+func TestParseGuards(t *testing.T) {
+	yaml := `
+hosts: localhost
+tasks:
+  - name: Install tool
+    apt:
+      name: tool
+    creates: /usr/bin/tool
+    removes: /tmp/tool.deb
+`
+	pb, err := ParseRaw([]byte(yaml), "test.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	task := pb.Plays[0].Tasks[0]
+	if task.Module != "apt" {
+		t.Errorf("expected module apt, got %q", task.Module)
+	}
+	if task.Creates != "/usr/bin/tool" {
+		t.Errorf("expected creates /usr/bin/tool, got %q", task.Creates)
+	}
+	if task.Removes != "/tmp/tool.deb" {
+		t.Errorf("expected removes /tmp/tool.deb, got %q", task.Removes)
+	}
+}
+
 func TestParseNotify(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -240,6 +271,35 @@ tasks:
 	}
 }
 
+func TestParseFirstFound(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+hosts: localhost
+tasks:
+  - copy:
+      src: "{{ item }}"
+      dest: /etc/app.conf
+    with_first_found:
+      - app.conf.{{ facts.os_family }}
+      - app.conf
+`), "test.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if task := pb.Plays[0].Tasks[0]; len(task.FirstFound) != 2 || task.Module != "copy" {
+		t.Errorf("task = %+v, want two candidate files", task)
+	}
+
+	for _, tasks := range []string{
+		"with_first_found: app.conf",
+		"with_first_found: [a, b]\n    loop: [1, 2]",
+	} {
+		_, err := ParseRaw([]byte("hosts: localhost\ntasks:\n  - command: echo\n    "+tasks+"\n"), "test.yaml")
+		if err == nil {
+			t.Errorf("expected error for %q", tasks)
+		}
+	}
+}
+
 func TestExpandShorthand(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -320,3 +380,568 @@ handlers:
 		t.Errorf("expected handler name 'restart nginx', got %q", handler.Name)
 	}
 }
+
+func TestParseRawPositions(t *testing.T) {
+	yaml := `- name: First
+  hosts: localhost
+  tasks:
+    - name: One
+      command:
+        cmd: echo one
+
+    - name: Two
+      command:
+        cmd: echo two
+  handlers:
+    - name: restart
+      command:
+        cmd: echo restart
+`
+	pb, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	play := pb.Plays[0]
+	if got := play.Pos.String(); got != "site.yaml:1:3" {
+		t.Errorf("play position: expected site.yaml:1:3, got %s", got)
+	}
+	if got := play.Tasks[1].Pos.String(); got != "site.yaml:8:7" {
+		t.Errorf("task position: expected site.yaml:8:7, got %s", got)
+	}
+	if got := play.Handlers[0].Pos.String(); got != "site.yaml:12:7" {
+		t.Errorf("handler position: expected site.yaml:12:7, got %s", got)
+	}
+}
+
+func TestParseRawErrorPosition(t *testing.T) {
+	tests := []struct {
+		name       string
+		yaml       string
+		wantPrefix string
+	}{
+		{
+			name: "multiple modules",
+			yaml: `
+hosts: localhost
+tasks:
+  - command:
+      cmd: echo ok
+  - command:
+      cmd: echo one
+    file:
+      path: /tmp/x
+`,
+			wantPrefix: "site.yaml:6:5: play 1: task 2:",
+		},
+		{
+			name: "task without module",
+			yaml: `
+hosts: localhost
+tasks:
+  - name: Nothing to do
+`,
+			wantPrefix: "site.yaml:4:5: play 1: Nothing to do:",
+		},
+		{
+			name: "missing hosts",
+			yaml: `
+- name: No hosts
+  tasks: []
+`,
+			wantPrefix: "site.yaml:2:3: play 1:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRaw([]byte(tt.yaml), "site.yaml")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.HasPrefix(err.Error(), tt.wantPrefix) {
+				t.Errorf("expected error to start with %q, got %q", tt.wantPrefix, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseIgnoreUnreachable(t *testing.T) {
+	yaml := `
+hosts: web
+ignore_unreachable: yes
+tasks:
+  - command: uptime
+  - command: hostname
+    ignore_unreachable: no
+`
+	pb, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	play := pb.Plays[0]
+	if !play.IgnoreUnreachable {
+		t.Error("expected play ignore_unreachable: yes to be true")
+	}
+	if !play.IgnoresUnreachable(play.Tasks[0]) {
+		t.Error("expected the first task to take the play's ignore_unreachable")
+	}
+	if play.IgnoresUnreachable(play.Tasks[1]) {
+		t.Error("expected ignore_unreachable: no on the task to override the play")
+	}
+}
+
+func TestParseConfirm(t *testing.T) {
+	yaml := `
+hosts: db
+confirm: true
+tasks:
+  - name: Drop data
+    file:
+      path: /var/lib/app
+      state: absent
+    confirm: "Delete {{ data_dir }}?"
+  - command: uptime
+    confirm: no
+`
+	pb, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	play := pb.Plays[0]
+	if play.Confirm != "Run this play?" {
+		t.Errorf("play confirm = %q, want the default question", play.Confirm)
+	}
+	if got := play.Tasks[0].Confirm; got != "Delete {{ data_dir }}?" {
+		t.Errorf("task confirm = %q, want its question", got)
+	}
+	if got := play.Tasks[1].Confirm; got != "" {
+		t.Errorf("confirm: no = %q, want none", got)
+	}
+
+	if _, err := ParseRaw([]byte("hosts: db\ntasks:\n  - command: uptime\n    confirm: [x]\n"), "site.yaml"); err == nil {
+		t.Error("expected an error for a confirm list")
+	}
+}
+
+// collectionModule is a module of the acme.tools collection.
+type collectionModule struct {
+	describedModule
+	name string
+}
+
+func (m collectionModule) Name() string { return "acme.tools." + m.name }
+
+func init() {
+	module.Register(collectionModule{name: "test_described"})
+	module.Register(collectionModule{name: "test_deploy"})
+}
+
+func TestParseCollections(t *testing.T) {
+	yaml := `
+- hosts: web
+  collections: [acme.tools]
+  module_aliases:
+    deploy: acme.tools.test_deploy
+  module_defaults:
+    test_described:
+      state: absent
+    bolt.core.test_described:
+      port: 80
+  tasks:
+    - test_described: {path: /a}
+    - bolt.core.test_described: {path: /b}
+    - deploy: {}
+    - acme.tools.test_deploy: {}
+- hosts: web
+  tasks:
+    - test_described: {path: /c}
+`
+	pb, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	var got []string
+	for _, play := range pb.Plays {
+		for _, task := range play.Tasks {
+			got = append(got, task.Module)
+		}
+	}
+	want := []string{"acme.tools.test_described", "test_described", "acme.tools.test_deploy", "acme.tools.test_deploy", "test_described"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("modules = %q, want %q", got, want)
+	}
+
+	defaults := pb.Plays[0].ModuleDefaults
+	if defaults["acme.tools.test_described"]["state"] != "absent" || defaults["test_described"]["port"] != 80 {
+		t.Errorf("module_defaults = %v, want them keyed by the resolved modules", defaults)
+	}
+}
+
+func TestParseCollectionsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"collections: acme.tools":              "collections must be a list",
+		"collections: [acme]":                  "collection 'acme' must be named <namespace>.<collection>",
+		"module_aliases: [deploy]":             "module_aliases must be a mapping",
+		"module_aliases: {when: acme.tools.x}": "module_aliases: 'when' is a task keyword",
+	}
+	for play, want := range tests {
+		_, err := ParseRaw([]byte("hosts: web\n"+play+"\n"), "site.yaml")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want %q", play, err, want)
+		}
+	}
+}
+
+func TestParseAnsibleCompat(t *testing.T) {
+	yaml := `
+hosts: localhost
+become: yes
+gather_facts: no
+tasks:
+  - name: Install nginx
+    ansible.builtin.apt:
+      name: nginx
+    ignore_errors: yes
+  - ansible.builtin.shell: echo hello
+    changed_when: false
+`
+	pb, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	play := pb.Plays[0]
+	if !play.Become {
+		t.Error("expected become: yes to be true")
+	}
+	if play.GatherFacts == nil || *play.GatherFacts {
+		t.Error("expected gather_facts: no to be false")
+	}
+
+	if got := play.Tasks[0].Module; got != "apt" {
+		t.Errorf("expected module apt, got %q", got)
+	}
+	if !play.Tasks[0].IgnoreErrors {
+		t.Error("expected ignore_errors: yes to be true")
+	}
+
+	task := play.Tasks[1]
+	if task.Module != "command" {
+		t.Errorf("expected shell to map to command, got %q", task.Module)
+	}
+	if task.Params["_raw"] != "echo hello" {
+		t.Errorf("expected raw 'echo hello', got %v", task.Params["_raw"])
+	}
+	if task.ChangedWhen != "false" {
+		t.Errorf("expected changed_when false, got %q", task.ChangedWhen)
+	}
+}
+
+func TestParseAnsibleUnsupported(t *testing.T) {
+	yaml := `
+hosts: localhost
+tasks:
+  - name: Secret
+    command: echo hi
+    no_log: true
+`
+	_, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err == nil {
+		t.Fatal("expected error for no_log")
+	}
+	if !strings.Contains(err.Error(), "'no_log' is not supported") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+hosts: localhost
+tags: web
+tasks:
+  - name: List
+    command: echo hi
+    tags: [setup, "always"]
+  - name: Comma-separated
+    command: echo hi
+    tags: db, slow
+  - name: Untagged
+    command: echo hi
+`), "site.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	play := pb.Plays[0]
+	if want := []string{"web"}; !reflect.DeepEqual(play.Tags, want) {
+		t.Errorf("play tags = %v, want %v", play.Tags, want)
+	}
+	for i, want := range [][]string{{"setup", "always", "web"}, {"db", "slow", "web"}, {"web"}} {
+		if got := play.AllTags(play.Tasks[i]); !reflect.DeepEqual(got, want) {
+			t.Errorf("task %d tags = %v, want %v", i, got, want)
+		}
+	}
+
+	for _, tags := range []string{"[1]", `"two words"`, "[and]", "{a: b}"} {
+		_, err := ParseRaw([]byte("hosts: localhost\ntasks:\n  - command: echo hi\n    tags: "+tags+"\n"), "site.yaml")
+		if err == nil {
+			t.Errorf("tags: %s: expected error", tags)
+		}
+	}
+}
+
+func TestParseModuleDefaults(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+hosts: localhost
+module_defaults:
+  ansible.builtin.apt:
+    update_cache: true
+  copy:
+    owner: app
+    group: app
+tasks:
+  - apt:
+      name: nginx
+  - copy:
+      dest: /etc/app.conf
+      content: x
+      owner: root
+`), "site.yaml")
+	if err != nil {
+		t.Fatalf("ParseRaw() error = %v", err)
+	}
+	play := pb.Plays[0]
+
+	apt := play.TaskParams(play.Tasks[0])
+	if apt["update_cache"] != true || apt["name"] != "nginx" {
+		t.Errorf("apt params = %v, want defaults merged under the task's", apt)
+	}
+	cp := play.TaskParams(play.Tasks[1])
+	if cp["owner"] != "root" || cp["group"] != "app" {
+		t.Errorf("copy params = %v, want task owner and default group", cp)
+	}
+	if _, ok := play.Tasks[1].Params["group"]; ok {
+		t.Error("TaskParams() modified the task's parameters")
+	}
+
+	_, err = ParseRaw([]byte(`
+hosts: localhost
+module_defaults:
+  apt: update_cache=yes
+`), "site.yaml")
+	if err == nil || !strings.Contains(err.Error(), "module_defaults for 'apt'") {
+		t.Errorf("ParseRaw() with non-mapping defaults error = %v", err)
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+hosts: localhost
+proxy: http://proxy.corp:3128
+tasks:
+  - command: curl https://example.com
+  - command: curl http://mirror.corp
+    proxy:
+      https_proxy: http://other.corp:8080
+      no_proxy: [localhost, .corp]
+`), "site.yaml")
+	if err != nil {
+		t.Fatalf("ParseRaw() error = %v", err)
+	}
+	play := pb.Plays[0]
+
+	if got := play.TaskProxy(play.Tasks[0]); *got != (Proxy{HTTP: "http://proxy.corp:3128", HTTPS: "http://proxy.corp:3128"}) {
+		t.Errorf("TaskProxy() = %+v, want the play's proxy", got)
+	}
+	want := Proxy{HTTP: "http://proxy.corp:3128", HTTPS: "http://other.corp:8080", NoProxy: "localhost,.corp"}
+	if got := play.TaskProxy(play.Tasks[1]); *got != want {
+		t.Errorf("TaskProxy() = %+v, want %+v", got, want)
+	}
+	if env := want.Env(); env["NO_PROXY"] != "localhost,.corp" || env["https_proxy"] != "http://other.corp:8080" || len(env) != 6 {
+		t.Errorf("Env() = %v", env)
+	}
+
+	_, err = ParseRaw([]byte(`
+hosts: localhost
+proxy:
+  ftp_proxy: http://proxy.corp:3128
+`), "site.yaml")
+	if err == nil || !strings.Contains(err.Error(), "unknown proxy setting 'ftp_proxy'") {
+		t.Errorf("ParseRaw() with unknown proxy setting error = %v", err)
+	}
+}
+
+func TestParseUpdateStrategy(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+hosts: web
+update_strategy:
+  batch_size: 25%
+  delegate_to: lb1
+  pre_batch:
+    - name: Disable backends
+      command: haproxy-ctl disable {{ batch_hosts | join(' ') }}
+  post_batch:
+    - name: Enable backends
+      command: haproxy-ctl enable {{ batch_hosts | join(' ') }}
+tasks:
+  - command: deploy
+`), "site.yaml")
+	if err != nil {
+		t.Fatalf("ParseRaw() error = %v", err)
+	}
+	s := pb.Plays[0].UpdateStrategy
+	if s == nil || s.BatchSize != "25%" || s.GetDelegateTo() != "lb1" || len(s.PreBatch) != 1 || len(s.PostBatch) != 1 {
+		t.Fatalf("UpdateStrategy = %+v", s)
+	}
+	if s.PostBatch[0].Module != "command" || s.PostBatch[0].Pos.Line != 10 {
+		t.Errorf("post_batch task = %+v", s.PostBatch[0])
+	}
+
+	for hosts, want := range map[int]int{1: 1, 3: 1, 8: 2, 10: 2} {
+		if got := s.BatchSizeFor(hosts); got != want {
+			t.Errorf("BatchSizeFor(%d) = %d, want %d", hosts, got, want)
+		}
+	}
+	if got := (&UpdateStrategy{}).BatchSizeFor(5); got != 1 {
+		t.Errorf("default BatchSizeFor(5) = %d, want 1", got)
+	}
+
+	for _, bad := range []string{"batch_size: 0", "batch_size: 150%", "batch_size: half", "serial: 2"} {
+		_, err := ParseRaw([]byte("hosts: web\nupdate_strategy:\n  "+bad+"\n"), "site.yaml")
+		if err == nil || !strings.Contains(err.Error(), "update_strategy") {
+			t.Errorf("ParseRaw() with %q error = %v", bad, err)
+		}
+	}
+}
+
+func TestParseTaskGraph(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+hosts: web
+task_graph: true
+task_parallel: 2
+tasks:
+  - name: Download app
+    command: fetch app
+  - name: Download config
+    command: fetch config
+  - name: Install
+    command: install
+    requires: [Download app, Download config]
+  - name: Report
+    command: report
+    after: Install
+`), "site.yaml")
+	if err != nil {
+		t.Fatalf("ParseRaw() error = %v", err)
+	}
+	play := pb.Plays[0]
+	if !play.TaskGraph || play.GetTaskParallel() != 2 {
+		t.Errorf("play TaskGraph = %v, GetTaskParallel() = %d", play.TaskGraph, play.GetTaskParallel())
+	}
+	if got := play.Tasks[2].Requires; len(got) != 2 || got[1] != "Download config" {
+		t.Errorf("Requires = %q", got)
+	}
+	if got := play.Tasks[3].After; len(got) != 1 || got[0] != "Install" {
+		t.Errorf("After = %q", got)
+	}
+
+	for name, tc := range map[string]struct{ yaml, want string }{
+		"no task_graph": {"tasks:\n  - command: b\n    after: a\n", "need 'task_graph: true'"},
+		"unknown task":  {"task_graph: true\ntasks:\n  - command: b\n    after: a\n", "site.yaml:4:5: play 1: command: {_raw=\"b\"}: after: no task named 'a'"},
+		"cycle": {"task_graph: true\ntasks:\n  - name: a\n    command: a\n    after: b\n  - name: b\n    command: b\n    requires: a\n",
+			"dependency cycle: a -> b -> a"},
+		"handler": {"handlers:\n  - name: h\n    command: h\n    after: a\n", "handlers cannot use"},
+	} {
+		_, err := ParseRaw([]byte("hosts: web\n"+tc.yaml), "site.yaml")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: ParseRaw() error = %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestParseTaskVars(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+hosts: localhost
+tasks:
+  - name: Greet
+    command: echo {{ greeting }}
+    vars:
+      greeting: hello
+`), "site.yaml")
+	if err != nil {
+		t.Fatalf("ParseRaw() error = %v", err)
+	}
+	task := pb.Plays[0].Tasks[0]
+	if task.Module != "command" || task.Vars["greeting"] != "hello" {
+		t.Errorf("task = %s with vars %v, want command with greeting", task.Module, task.Vars)
+	}
+
+	_, err = ParseRaw([]byte(`
+hosts: localhost
+tasks:
+  - command: echo hi
+    vars: [greeting]
+`), "site.yaml")
+	if err == nil || !strings.Contains(err.Error(), "vars must be a mapping") {
+		t.Errorf("ParseRaw() with list vars error = %v", err)
+	}
+}
+
+func TestParseListen(t *testing.T) {
+	yaml := `
+hosts: localhost
+tasks: []
+handlers:
+  - name: restart nginx
+    command: systemctl restart nginx
+    listen: restart web
+  - name: flush cache
+    command: rm -rf /var/cache/app
+    listen:
+      - restart web
+      - deploy
+`
+	pb, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	handlers := pb.Plays[0].Handlers
+	if len(handlers[0].Listen) != 1 || handlers[0].Listen[0] != "restart web" {
+		t.Errorf("expected listen [restart web], got %v", handlers[0].Listen)
+	}
+	if len(handlers[1].Listen) != 2 || handlers[1].Listen[1] != "deploy" {
+		t.Errorf("expected listen [restart web deploy], got %v", handlers[1].Listen)
+	}
+}
+
+func TestParseHostsList(t *testing.T) {
+	yaml := `
+hosts:
+  - webservers
+  - "!web3"
+  - db1
+tasks: []
+`
+	pb, err := ParseRaw([]byte(yaml), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	got := pb.Plays[0].HostPatterns()
+	want := []string{"webservers", "!web3", "db1"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected patterns %v, got %v", want, got)
+	}
+
+	pb, err = ParseRaw([]byte("hosts: web1, web2\ntasks: []\n"), "site.yaml")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if got := pb.Plays[0].HostPatterns(); len(got) != 2 || got[1] != "web2" {
+		t.Errorf("expected patterns [web1 web2], got %v", got)
+	}
+}
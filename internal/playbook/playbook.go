@@ -3,7 +3,12 @@ package playbook
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/module"
 )
 
 // Playbook represents a complete playbook with one or more plays.
@@ -20,7 +25,8 @@ type Play struct {
 	// Name is an optional description of the play.
 	Name string `yaml:"name"`
 
-	// Hosts specifies which hosts to target (host, group, or pattern).
+	// Hosts specifies which hosts to target: comma-separated host names,
+	// group names, or patterns (a YAML list is joined with commas).
 	Hosts string `yaml:"hosts"`
 
 	// Connection specifies how to connect (local, ssh, ssm).
@@ -29,6 +35,20 @@ type Play struct {
 	// Vars defines variables available to all tasks in the play.
 	Vars map[string]any `yaml:"vars"`
 
+	// ModuleDefaults holds default parameters per module name. They apply
+	// to every task of the play, including role tasks and handlers, that
+	// does not set the parameter itself.
+	ModuleDefaults map[string]map[string]any `yaml:"module_defaults"`
+
+	// Collections are the module collections searched, in order, for the
+	// modules the play's tasks name by their short name, before the
+	// built-in modules (see module.Resolve).
+	Collections []string `yaml:"collections"`
+
+	// ModuleAliases maps names the play's tasks may use to the modules
+	// they stand for, such as deploy: acme.cloud.deploy.
+	ModuleAliases map[string]string `yaml:"module_aliases"`
+
 	// Roles is the list of roles to include in the play.
 	Roles []string `yaml:"roles"`
 
@@ -44,8 +64,60 @@ type Play struct {
 	// BecomeUser is the user to become (default: root).
 	BecomeUser string `yaml:"become_user"`
 
+	// BecomeKeepOwnership gives files that copy and template create with
+	// become to the connecting user instead of the become user, unless
+	// the task sets an owner.
+	BecomeKeepOwnership bool `yaml:"become_keep_ownership"`
+
 	// GatherFacts controls whether to gather system facts (default: true).
 	GatherFacts *bool `yaml:"gather_facts"`
+
+	// StrictVars fails tasks that reference undefined variables
+	// (default: the executor's setting).
+	StrictVars *bool `yaml:"strict_vars"`
+
+	// ForceHandlers runs notified handlers even if a task fails
+	// (default: the executor's setting).
+	ForceHandlers *bool `yaml:"force_handlers"`
+
+	// IgnoreUnreachable keeps a host running the play's tasks after a
+	// task cannot reach it. Tasks can override it.
+	IgnoreUnreachable bool `yaml:"ignore_unreachable"`
+
+	// Confirm, if set, is the question asked before the play runs its
+	// tasks on each host. A host whose run is not approved fails.
+	Confirm string `yaml:"-"`
+
+	// Tags are added to the tags of every task of the play, including
+	// role tasks, for selecting tasks with --tags.
+	Tags []string `yaml:"-"`
+
+	// ConnectionRetries is how often operations that fail with a transient
+	// connection error are retried (default: 2, 0 disables).
+	ConnectionRetries *int `yaml:"connection_retries"`
+
+	// ConnectionRetryDelay is the wait in seconds before the first
+	// connection retry; it doubles with every further retry (default: 1).
+	ConnectionRetryDelay *int `yaml:"connection_retry_delay"`
+
+	// Proxy is the HTTP proxy for the commands of all tasks in the play.
+	Proxy *Proxy `yaml:"-"`
+
+	// UpdateStrategy rolls the play out over its hosts in batches, with
+	// hooks around each batch. Nil runs every host in one go.
+	UpdateStrategy *UpdateStrategy `yaml:"-"`
+
+	// TaskGraph runs the tasks on each host in the order of their after
+	// and requires dependencies instead of one by one, with independent
+	// tasks running concurrently.
+	TaskGraph bool `yaml:"task_graph"`
+
+	// TaskParallel is the number of tasks of a task graph to run on a host
+	// at a time (default: 4).
+	TaskParallel int `yaml:"task_parallel"`
+
+	// Pos is the location of the play in the playbook file.
+	Pos Position `yaml:"-"`
 }
 
 // Task represents a single task in a play.
@@ -62,6 +134,9 @@ type Task struct {
 	// RolePath is the path to the role this task belongs to (empty for play tasks).
 	RolePath string `yaml:"-"`
 
+	// Pos is the location of the task in the playbook or role file.
+	Pos Position `yaml:"-"`
+
 	// When is a conditional expression; task runs only if true.
 	When string `yaml:"when"`
 
@@ -69,17 +144,33 @@ type Task struct {
 	Register string `yaml:"register"`
 
 	// Notify lists handlers to trigger if the task changes something.
+	// Entries are handler names or listen topics, optionally qualified
+	// with a role name ("web : restart nginx").
 	Notify []string `yaml:"-"`
 
+	// Listen lists topics a handler responds to in addition to its name.
+	Listen []string `yaml:"-"`
+
 	// Loop iterates the task over a list of items.
 	Loop []any `yaml:"-"`
 
 	// LoopVar is the variable name for the current item (default: "item").
 	LoopVar string `yaml:"loop_var"`
 
+	// LoopParallel is the number of loop items to run concurrently (default: 1).
+	LoopParallel int `yaml:"loop_parallel"`
+
+	// FirstFound lists candidate files; the task runs once with the loop
+	// variable set to the first one that exists.
+	FirstFound []any `yaml:"-"`
+
 	// IgnoreErrors continues execution even if the task fails.
 	IgnoreErrors bool `yaml:"ignore_errors"`
 
+	// IgnoreUnreachable continues execution if the task cannot reach the
+	// host (default: the play's setting).
+	IgnoreUnreachable *bool `yaml:"ignore_unreachable"`
+
 	// Retries is the number of times to retry on failure.
 	Retries int `yaml:"retries"`
 
@@ -98,6 +189,118 @@ type Task struct {
 
 	// Failed controls when the task reports as failed.
 	FailedWhen string `yaml:"failed_when"`
+
+	// Creates skips the task if this path exists on the target.
+	Creates string `yaml:"creates"`
+
+	// Removes skips the task unless this path exists on the target.
+	Removes string `yaml:"removes"`
+
+	// Confirm, if set, is the question asked before the task runs on each
+	// host, e.g. before it deletes data. The task fails unless it is
+	// approved. It may contain variables.
+	Confirm string `yaml:"-"`
+
+	// Tags label the task for selecting tasks with --tags and
+	// --skip-tags. The tags always and never are special (see TagAlways
+	// and TagNever).
+	Tags []string `yaml:"-"`
+
+	// Vars defines variables for this task only. They take precedence
+	// over play, role, and host vars.
+	Vars map[string]any `yaml:"vars"`
+
+	// Proxy overrides the play's HTTP proxy settings for this task.
+	Proxy *Proxy `yaml:"-"`
+
+	// After names tasks that must finish before this task starts, in a
+	// play with task_graph. Names may be qualified with a role name
+	// ("web : install nginx").
+	After []string `yaml:"-"`
+
+	// Requires names tasks that must finish before this task starts, like
+	// After; the task is skipped unless they succeeded.
+	Requires []string `yaml:"-"`
+
+	// Warnings are the non-fatal problems found when parsing the task.
+	Warnings []Warning `yaml:"-"`
+}
+
+// Proxy holds the HTTP proxy settings exported to the commands a task runs
+// on the target. Settings may be templates.
+type Proxy struct {
+	// HTTP is the proxy for http:// URLs (http_proxy).
+	HTTP string
+
+	// HTTPS is the proxy for https:// URLs (https_proxy).
+	HTTPS string
+
+	// NoProxy is a comma-separated list of hosts and domains reached
+	// without the proxy (no_proxy).
+	NoProxy string
+}
+
+// Env returns the settings as environment variables. Each is set in lower
+// and upper case, since tools differ in which one they read; empty
+// settings are left out.
+func (p *Proxy) Env() map[string]string {
+	env := map[string]string{}
+	if p == nil {
+		return env
+	}
+	for name, value := range map[string]string{
+		"http_proxy":  p.HTTP,
+		"https_proxy": p.HTTPS,
+		"no_proxy":    p.NoProxy,
+	} {
+		if value != "" {
+			env[name] = value
+			env[strings.ToUpper(name)] = value
+		}
+	}
+	return env
+}
+
+// UpdateStrategy rolls a play out over its hosts in batches, for rolling
+// updates of a service behind a load balancer. Hook tasks run on another
+// host before and after each batch: pre_batch tasks take the hosts of the
+// batch out of rotation, post_batch tasks check their health and put them
+// back. A failing hook or host stops the rollout before the next batch.
+type UpdateStrategy struct {
+	// BatchSize is the number of hosts per batch, or a percentage of the
+	// play's hosts such as "25%" (default: 1).
+	BatchSize string
+
+	// DelegateTo is the host the hooks run on (default: localhost).
+	DelegateTo string
+
+	// PreBatch are the tasks run before each batch.
+	PreBatch []*Task
+
+	// PostBatch are the tasks run after each batch.
+	PostBatch []*Task
+}
+
+// BatchSizeFor returns the number of hosts per batch of a play with n
+// hosts. A percentage is rounded down; batches have at least one host.
+func (s *UpdateStrategy) BatchSizeFor(n int) int {
+	size := 1
+	if pct, ok := strings.CutSuffix(s.BatchSize, "%"); ok {
+		p, _ := strconv.Atoi(pct)
+		size = n * p / 100
+	} else if s.BatchSize != "" {
+		size, _ = strconv.Atoi(s.BatchSize)
+	}
+	return max(size, 1)
+}
+
+// GetDelegateTo returns the host the hooks run on, defaulting to
+// "localhost".
+func (s *UpdateStrategy) GetDelegateTo() string {
+	if s.DelegateTo == "" {
+		return "localhost"
+	}
+	return s.DelegateTo
 }
 
 // Role represents an Ansible-compatible role with tasks, handlers, and variables.
@@ -129,6 +332,125 @@ func (p *Play) ShouldGatherFacts() bool {
 	return *p.GatherFacts
 }
 
+// StrictVarsOr returns whether undefined variables are errors in this play,
+// falling back to def if the play does not say.
+func (p *Play) StrictVarsOr(def bool) bool {
+	if p.StrictVars == nil {
+		return def
+	}
+	return *p.StrictVars
+}
+
+// ForceHandlersOr returns whether notified handlers run after a failed task
+// in this play, falling back to def if the play does not say.
+func (p *Play) ForceHandlersOr(def bool) bool {
+	if p.ForceHandlers == nil {
+		return def
+	}
+	return *p.ForceHandlers
+}
+
+// IgnoresUnreachable returns whether the host goes on with the play's
+// next task after task cannot reach it.
+func (p *Play) IgnoresUnreachable(task *Task) bool {
+	if task.IgnoreUnreachable != nil {
+		return *task.IgnoreUnreachable
+	}
+	return p != nil && p.IgnoreUnreachable
+}
+
+// GetTaskParallel returns the number of tasks of a task graph to run on a
+// host at a time, defaulting to 4.
+func (p *Play) GetTaskParallel() int {
+	if p.TaskParallel <= 0 {
+		return 4
+	}
+	return p.TaskParallel
+}
+
+// HostPatterns returns the host patterns of the play.
+func (p *Play) HostPatterns() []string {
+	var patterns []string
+	for _, pattern := range strings.Split(p.Hosts, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// ModuleName returns the registered name of the module that name refers
+// to in the play, through its module aliases and collections. A name
+// that refers to no module is returned as is, to be reported as unknown.
+// A nil play resolves names as a play without collections.
+func (p *Play) ModuleName(name string) string {
+	var collections []string
+	if p != nil {
+		if target, ok := p.ModuleAliases[name]; ok {
+			name = ModuleName(target)
+		}
+		collections = p.Collections
+	}
+	if resolved := module.Resolve(name, collections); resolved != "" {
+		return resolved
+	}
+	return name
+}
+
+// ResolveModules sets the module of each of tasks to its registered name
+// in the play, so module defaults and the executor find it. The parser
+// resolves the play's own tasks; roles are resolved by the play that
+// includes them.
+func (p *Play) ResolveModules(tasks []*Task) {
+	for _, task := range tasks {
+		if task.Module != "" {
+			task.Module = p.ModuleName(task.Module)
+		}
+	}
+}
+
+// TaskParams returns the parameters of task with the play's module
+// defaults merged under them. The task's own parameters are not modified.
+// A nil play has no defaults.
+func (p *Play) TaskParams(task *Task) map[string]any {
+	if p == nil || len(p.ModuleDefaults[task.Module]) == 0 {
+		return task.Params
+	}
+
+	defaults := p.ModuleDefaults[task.Module]
+	params := make(map[string]any, len(defaults)+len(task.Params))
+	for k, v := range defaults {
+		params[k] = v
+	}
+	for k, v := range task.Params {
+		params[k] = v
+	}
+	return params
+}
+
+// TaskProxy returns the proxy settings for task: those of the play, with
+// each setting the task gives taking precedence. It returns nil if neither
+// sets a proxy.
+func (p *Play) TaskProxy(task *Task) *Proxy {
+	if p == nil || p.Proxy == nil {
+		return task.Proxy
+	}
+	if task.Proxy == nil {
+		return p.Proxy
+	}
+	proxy := *p.Proxy
+	if task.Proxy.HTTP != "" {
+		proxy.HTTP = task.Proxy.HTTP
+	}
+	if task.Proxy.HTTPS != "" {
+		proxy.HTTPS = task.Proxy.HTTPS
+	}
+	if task.Proxy.NoProxy != "" {
+		proxy.NoProxy = task.Proxy.NoProxy
+	}
+	return &proxy
+}
+
 // GetConnection returns the connection type, defaulting to "local".
 func (p *Play) GetConnection() string {
 	if p.Connection == "" {
@@ -171,7 +493,7 @@ func (t *Task) GetLoopVar() string {
 
 // Validate checks the play for common errors.
 func (p *Play) Validate() error {
-	if p.Hosts == "" {
+	if len(p.HostPatterns()) == 0 {
 		return fmt.Errorf("play is missing required 'hosts' field")
 	}
 
@@ -183,13 +505,50 @@ func (p *Play) Validate() error {
 		return fmt.Errorf("invalid connection type: %s (must be local, docker, ssh, or ssm)", conn)
 	}
 
+	if p.ConnectionRetries != nil && *p.ConnectionRetries < 0 {
+		return fmt.Errorf("connection_retries must not be negative")
+	}
+	if p.ConnectionRetryDelay != nil && *p.ConnectionRetryDelay < 0 {
+		return fmt.Errorf("connection_retry_delay must not be negative")
+	}
+	if p.TaskParallel < 0 {
+		return fmt.Errorf("task_parallel must not be negative")
+	}
+
 	for i, task := range p.Tasks {
 		if err := task.Validate(); err != nil {
 			taskName := task.Name
 			if taskName == "" {
 				taskName = fmt.Sprintf("task %d", i+1)
 			}
-			return fmt.Errorf("%s: %w", taskName, err)
+			return ErrorAt(task.Pos, taskName, err)
+		}
+		if task.HasDependencies() && !p.TaskGraph {
+			return ErrorAt(task.Pos, task.String(), ErrNoTaskGraph)
+		}
+	}
+
+	// Role tasks may be named by after and requires, so the graph of a
+	// play with roles is checked when the roles are loaded
+	if p.TaskGraph && len(p.Roles) == 0 {
+		if _, err := NewTaskGraph(p.Tasks); err != nil {
+			return err
+		}
+	}
+
+	if s := p.UpdateStrategy; s != nil {
+		hooks := append(append([]*Task{}, s.PreBatch...), s.PostBatch...)
+		for i, task := range hooks {
+			if err := task.Validate(); err != nil {
+				taskName := task.Name
+				if taskName == "" {
+					taskName = fmt.Sprintf("hook %d", i+1)
+				}
+				return ErrorAt(task.Pos, taskName, err)
+			}
+			if task.HasDependencies() {
+				return ErrorAt(task.Pos, task.String(), fmt.Errorf("update strategy hooks cannot use 'after' or 'requires'"))
+			}
 		}
 	}
 
@@ -199,10 +558,14 @@ func (p *Play) Validate() error {
 			if handlerName == "" {
 				handlerName = fmt.Sprintf("handler %d", i+1)
 			}
-			return fmt.Errorf("%s: %w", handlerName, err)
+			return ErrorAt(handler.Pos, handlerName, err)
+		}
+		if handler.HasDependencies() {
+			return ErrorAt(handler.Pos, handler.String(), fmt.Errorf("handlers cannot use 'after' or 'requires'"))
 		}
 		if handler.Name == "" {
-			return fmt.Errorf("handler %d: handlers must have a name for notify to reference", i+1)
+			return ErrorAt(handler.Pos, fmt.Sprintf("handler %d", i+1),
+				fmt.Errorf("handlers must have a name for notify to reference"))
 		}
 	}
 
@@ -226,6 +589,45 @@ func (t *Task) Validate() error {
 	return nil
 }
 
+// HasDependencies reports whether the task names tasks in after or
+// requires.
+func (t *Task) HasDependencies() bool {
+	return len(t.After) > 0 || len(t.Requires) > 0
+}
+
+// Special tags.
+const (
+	// TagAlways makes a task run whatever --tags selects, unless
+	// --skip-tags skips it.
+	TagAlways = "always"
+
+	// TagNever makes a task run only if --tags selects it by one of its
+	// other tags.
+	TagNever = "never"
+)
+
+// tagPattern matches tag names.
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// ValidTag reports whether name can be used as a tag: a word of letters,
+// digits, '_', '-', '.', and ':' other than the operators of tag
+// expressions (and, or, not).
+func ValidTag(name string) bool {
+	switch name {
+	case "and", "or", "not":
+		return false
+	}
+	return tagPattern.MatchString(name)
+}
+
+// AllTags returns the tags of task in play: its own and the play's.
+func (p *Play) AllTags(task *Task) []string {
+	if len(p.Tags) == 0 {
+		return task.Tags
+	}
+	return append(slices.Clip(task.Tags), p.Tags...)
+}
+
 // String returns a human-readable description of the task.
 func (t *Task) String() string {
 	if t.Name != "" {
@@ -0,0 +1,113 @@
+package playbook
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position identifies a location in a playbook or role file.
+type Position struct {
+	// File is the path of the YAML file (may be empty for in-memory playbooks).
+	File string `json:"file,omitempty"`
+
+	// Line is the 1-based line number.
+	Line int `json:"line,omitempty"`
+
+	// Column is the 1-based column number.
+	Column int `json:"column,omitempty"`
+}
+
+// IsValid returns whether the position refers to an actual location.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position in file:line:column form.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return p.File
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// PositionError is an error annotated with the playbook location it refers to.
+type PositionError struct {
+	Pos Position
+	Err error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Pos, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorAt annotates err with a context prefix and a position.
+// If err already carries a position, that (more specific) position is kept
+// and the context is inserted after it, so messages always start with file:line.
+func ErrorAt(pos Position, context string, err error) error {
+	if pe, ok := err.(*PositionError); ok {
+		if context == "" {
+			return pe
+		}
+		return &PositionError{Pos: pe.Pos, Err: fmt.Errorf("%s: %w", context, pe.Err)}
+	}
+	if context != "" {
+		err = fmt.Errorf("%s: %w", context, err)
+	}
+	if !pos.IsValid() {
+		return err
+	}
+	return &PositionError{Pos: pos, Err: err}
+}
+
+// nodePosition returns the position of a YAML node in the given file.
+func nodePosition(file string, node *yaml.Node) Position {
+	if node == nil {
+		return Position{File: file}
+	}
+	return Position{File: file, Line: node.Line, Column: node.Column}
+}
+
+// documentRoot returns the top-level content node of a parsed YAML document.
+// It returns nil for empty documents.
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node == nil || node.Kind == 0 {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return node.Content[0]
+	}
+	return node
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceItem returns the i-th item of a sequence node, or nil.
+func sequenceItem(node *yaml.Node, i int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+		return nil
+	}
+	return node.Content[i]
+}
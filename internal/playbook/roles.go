@@ -94,22 +94,22 @@ func loadTasksFile(path string) ([]*Task, error) {
 		return nil, fmt.Errorf("error reading %s: %w", path, err)
 	}
 
-	// Parse as list of raw task maps
-	var rawTasks []map[string]any
-	if err := yaml.Unmarshal(data, &rawTasks); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("error parsing %s: %w", path, err)
 	}
+	root := documentRoot(&doc)
+	if root == nil {
+		return nil, nil
+	}
 
-	tasks := make([]*Task, 0, len(rawTasks))
-	for i, raw := range rawTasks {
-		task, err := parseRawTask(raw)
-		if err != nil {
-			return nil, fmt.Errorf("task %d in %s: %w", i+1, path, err)
-		}
-		tasks = append(tasks, task)
+	// Parse as list of raw task maps
+	var rawTasks []any
+	if err := root.Decode(&rawTasks); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
 	}
 
-	return tasks, nil
+	return parseRawTaskList(rawTasks, root, path, "task")
 }
 
 // loadRoleVarsFile loads variables from a YAML file.
@@ -0,0 +1,264 @@
+package playbook
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+// SchemaID is the $id of the playbook JSON Schema.
+const SchemaID = "https://github.com/eugenetaranov/bolt/playbook.schema.json"
+
+// playKeys describes the keys of a play. roles, tasks, handlers,
+// module_defaults, and update_strategy are added by Schema, since they
+// refer to other definitions.
+var playKeys = []module.ParamSpec{
+	{Name: "name", Type: "string", Description: "Description of the play"},
+	{Name: "hosts", Type: "string/list", Required: true, Description: "Hosts, groups, or patterns to target"},
+	{Name: "connection", Type: "string", Default: "local", Choices: []string{"local", "docker", "ssh", "ssm"}, Description: "How to connect to the hosts"},
+	{Name: "vars", Type: "map", Description: "Variables available to all tasks in the play"},
+	{Name: "become", Type: "bool", Default: false, Description: "Run tasks with privilege escalation"},
+	{Name: "become_user", Type: "string", Default: "root", Description: "User to become"},
+	{Name: "become_keep_ownership", Type: "bool", Default: false, Description: "Give files that copy and template create with become to the connecting user"},
+	{Name: "gather_facts", Type: "bool", Default: true, Description: "Gather system facts before running tasks"},
+	{Name: "strict_vars", Type: "bool", Description: "Fail tasks that reference undefined variables"},
+	{Name: "force_handlers", Type: "bool", Description: "Run notified handlers even if a task fails"},
+	{Name: "ignore_unreachable", Type: "bool", Default: false, Description: "Continue with the next task if a task cannot reach the host"},
+	{Name: "confirm", Type: "string/bool", Description: "Ask for confirmation before running the play on each host: true, or the question to ask"},
+	{Name: "tags", Type: "string/list", Description: "Tags added to every task of the play, for selecting tasks with --tags"},
+	{Name: "connection_retries", Type: "int", Default: 2, Description: "Retries of operations that fail with a transient connection error"},
+	{Name: "connection_retry_delay", Type: "int", Default: 1, Description: "Seconds to wait before the first connection retry"},
+	{Name: "proxy", Type: "string/map", Description: "HTTP proxy for commands on the hosts: a URL, or http_proxy, https_proxy, and no_proxy"},
+	{Name: "task_graph", Type: "bool", Default: false, Description: "Run tasks in the order of their after and requires dependencies, independent tasks concurrently"},
+	{Name: "task_parallel", Type: "int", Default: 4, Description: "Number of tasks of a task graph to run on a host at a time"},
+	{Name: "collections", Type: "list", Description: "Module collections searched, in order, for short module names before the built-in modules"},
+	{Name: "module_aliases", Type: "map", Description: "Names tasks may use for modules, mapped to the module names"},
+}
+
+// updateStrategyKeys describes the keys of a play's update_strategy.
+// pre_batch and post_batch are added by Schema.
+var updateStrategyKeys = []module.ParamSpec{
+	{Name: "batch_size", Type: "string/int", Default: 1, Description: "Hosts per batch, or a percentage of the play's hosts (\"25%\")"},
+	{Name: "delegate_to", Type: "string", Default: "localhost", Description: "Host the hooks run on, e.g. the load balancer"},
+}
+
+// taskDirectives describes the task keys that are not module names. It
+// matches knownTaskFields.
+var taskDirectives = []module.ParamSpec{
+	{Name: "name", Type: "string", Description: "Description of the task"},
+	{Name: "when", Type: "string", Description: "Condition; the task runs only if it is true"},
+	{Name: "register", Type: "string", Description: "Variable to store the task result in"},
+	{Name: "notify", Type: "string/list", Description: "Handlers to trigger if the task changes something"},
+	{Name: "listen", Type: "string/list", Description: "Topics a handler responds to in addition to its name"},
+	{Name: "loop", Type: "list", Description: "Items to run the task for"},
+	{Name: "with_items", Type: "list", Description: "Items to run the task for (use loop)"},
+	{Name: "with_first_found", Type: "list", Description: "Candidate files; the task runs once for the first that exists"},
+	{Name: "loop_var", Type: "string", Default: "item", Description: "Variable name of the current item"},
+	{Name: "loop_parallel", Type: "int", Default: 1, Description: "Number of loop items to run concurrently"},
+	{Name: "ignore_errors", Type: "bool", Default: false, Description: "Continue if the task fails"},
+	{Name: "ignore_unreachable", Type: "bool", Description: "Continue if the task cannot reach the host (default: the play's setting)"},
+	{Name: "retries", Type: "int", Default: 0, Description: "Number of times to retry on failure"},
+	{Name: "delay", Type: "int", Default: 0, Description: "Seconds to wait between retries"},
+	{Name: "become", Type: "bool", Description: "Run the task with privilege escalation"},
+	{Name: "become_user", Type: "string", Description: "User to become"},
+	{Name: "changed_when", Type: "string/bool", Description: "Condition for reporting the task as changed"},
+	{Name: "failed_when", Type: "string/bool", Description: "Condition for reporting the task as failed"},
+	{Name: "creates", Type: "string", Description: "Skip the task if this path exists on the target"},
+	{Name: "removes", Type: "string", Description: "Skip the task unless this path exists on the target"},
+	{Name: "confirm", Type: "string/bool", Description: "Ask for confirmation before running the task on each host: true, or the question to ask"},
+	{Name: "tags", Type: "string/list", Description: "Tags for selecting tasks with --tags and --skip-tags; always and never are special"},
+	{Name: "vars", Type: "map", Description: "Variables for this task only"},
+	{Name: "proxy", Type: "string/map", Description: "HTTP proxy settings overriding the play's"},
+	{Name: "after", Type: "string/list", Description: "Tasks that must finish before this task starts (needs task_graph)"},
+	{Name: "requires", Type: "string/list", Description: "Tasks that must succeed before this task runs (needs task_graph)"},
+}
+
+// Schema returns a JSON Schema (draft 2020-12) for playbooks. It describes
+// plays, task directives, and the parameters of every registered module,
+// so YAML language servers can complete and validate playbooks.
+//
+// Values may always be templates, so every parameter also accepts a
+// string. Modules that do not describe their parameters accept any
+// mapping.
+func Schema() map[string]any {
+	names := module.List()
+	sort.Strings(names)
+
+	defs := map[string]any{}
+	task := objectSchema(taskDirectives)
+	taskProps := task["properties"].(map[string]any)
+	for _, name := range names {
+		defs["module."+name] = moduleSchema(module.Get(name))
+
+		ref := map[string]any{"$ref": "#/$defs/module." + name}
+		taskProps[name] = ref
+		if strings.Contains(name, ".") {
+			continue
+		}
+		taskProps[module.CoreCollection+"."+name] = ref
+		for _, prefix := range ansibleCollections {
+			taskProps[prefix+name] = ref
+		}
+	}
+	// Modules of collections may be named by their short name, through
+	// the play's collections
+	for _, name := range names {
+		short := name[strings.LastIndex(name, ".")+1:]
+		if _, ok := taskProps[short]; !ok {
+			taskProps[short] = map[string]any{"$ref": "#/$defs/module." + name}
+		}
+	}
+	for alias, name := range moduleAliases {
+		if _, ok := defs["module."+name]; ok {
+			taskProps[alias] = map[string]any{"$ref": "#/$defs/module." + name}
+		}
+	}
+	task["additionalProperties"] = false
+	defs["task"] = task
+
+	tasks := map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/task"}}
+	play := objectSchema(playKeys)
+	playProps := play["properties"].(map[string]any)
+	playProps["roles"] = map[string]any{
+		"type":        "array",
+		"items":       map[string]any{"type": "string"},
+		"description": "Roles to include in the play",
+	}
+	playProps["tasks"] = withDescription(tasks, "Tasks to run")
+	playProps["handlers"] = withDescription(tasks, "Tasks triggered by notify")
+	playProps["module_defaults"] = moduleDefaultsSchema(names)
+	strategy := objectSchema(updateStrategyKeys)
+	strategyProps := strategy["properties"].(map[string]any)
+	strategyProps["pre_batch"] = withDescription(tasks, "Tasks run on the delegate host before each batch")
+	strategyProps["post_batch"] = withDescription(tasks, "Tasks run on the delegate host after each batch, e.g. health checks")
+	strategy["additionalProperties"] = false
+	playProps["update_strategy"] = withDescription(strategy, "Roll the play out over its hosts in batches, with hooks around each batch")
+	play["additionalProperties"] = false
+	defs["play"] = play
+
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         SchemaID,
+		"title":       "Bolt playbook",
+		"description": "A bolt playbook: a list of plays, or a single play",
+		"anyOf": []any{
+			map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/play"}},
+			map[string]any{"$ref": "#/$defs/play"},
+		},
+		"$defs": defs,
+	}
+}
+
+// moduleSchema returns the schema of a module's value in a task: a mapping
+// of parameters, a short-form string, or nothing.
+func moduleSchema(m module.Module) map[string]any {
+	params := map[string]any{"type": "object"}
+	if specs := module.Params(m); specs != nil {
+		params = objectSchema(specs)
+		params["additionalProperties"] = false
+	}
+	return map[string]any{
+		"anyOf": []any{
+			params,
+			map[string]any{"type": "string", "description": "Short form (key=value pairs or a single argument)"},
+			map[string]any{"type": "null"},
+		},
+	}
+}
+
+// moduleDefaultsSchema returns the schema of a play's module_defaults: the
+// parameters of each module, none of them required.
+func moduleDefaultsSchema(names []string) map[string]any {
+	props := map[string]any{}
+	for _, name := range names {
+		params := map[string]any{"type": "object"}
+		if specs := module.Params(module.Get(name)); specs != nil {
+			params = objectSchema(specs)
+			delete(params, "required")
+			params["additionalProperties"] = false
+		}
+		props[name] = params
+	}
+	return map[string]any{
+		"type":        "object",
+		"properties":  props,
+		"description": "Default parameters per module, merged under each task's own parameters",
+	}
+}
+
+// objectSchema returns the schema of a mapping with the given keys.
+func objectSchema(specs []module.ParamSpec) map[string]any {
+	props := map[string]any{}
+	var required []any
+	for _, spec := range specs {
+		props[spec.Name] = paramSchema(spec)
+		if spec.Required {
+			required = append(required, spec.Name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// paramSchema returns the schema of a single value. Choices restrict plain
+// strings only, so templates ("{{ state }}") remain valid.
+func paramSchema(spec module.ParamSpec) map[string]any {
+	var types []any
+	for _, t := range strings.Split(spec.Type, "/") {
+		switch t {
+		case "bool":
+			types = append(types, "boolean")
+		case "int":
+			types = append(types, "integer")
+		case "list":
+			types = append(types, "array")
+		case "map":
+			types = append(types, "object")
+		}
+	}
+
+	var schema map[string]any
+	if len(spec.Choices) > 0 {
+		choices := make([]any, len(spec.Choices))
+		for i, c := range spec.Choices {
+			choices[i] = c
+		}
+		anyOf := []any{
+			map[string]any{"enum": choices},
+			map[string]any{"type": "string", "pattern": `\{\{`},
+		}
+		if len(types) > 0 {
+			anyOf = append(anyOf, map[string]any{"type": types})
+		}
+		schema = map[string]any{"anyOf": anyOf}
+	} else {
+		// Any value can be a template string
+		types = append(types, "string")
+		schema = map[string]any{"type": types}
+		if len(types) == 1 {
+			schema["type"] = "string"
+		}
+	}
+
+	if spec.Default != nil {
+		schema["default"] = spec.Default
+	}
+	return withDescription(schema, spec.Description)
+}
+
+// withDescription returns a copy of schema with a description.
+func withDescription(schema map[string]any, desc string) map[string]any {
+	out := make(map[string]any, len(schema)+1)
+	for k, v := range schema {
+		out[k] = v
+	}
+	if desc != "" {
+		out["description"] = desc
+	}
+	return out
+}
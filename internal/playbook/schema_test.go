@@ -0,0 +1,84 @@
+package playbook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/module"
+)
+
+// describedModule is a module that describes its parameters.
+type describedModule struct{}
+
+func (describedModule) Name() string { return "test_described" }
+
+func (describedModule) Run(ctx context.Context, conn connector.Connector, params map[string]any) (*module.Result, error) {
+	return module.Unchanged("ok"), nil
+}
+
+func (describedModule) Params() []module.ParamSpec {
+	return []module.ParamSpec{
+		{Name: "path", Type: "string", Required: true, Description: "Path"},
+		{Name: "state", Type: "string", Default: "present", Choices: []string{"present", "absent"}},
+		{Name: "port", Type: "int"},
+	}
+}
+
+func init() {
+	module.Register(describedModule{})
+}
+
+func TestSchemaDirectives(t *testing.T) {
+	seen := map[string]bool{}
+	for _, d := range taskDirectives {
+		if !knownTaskFields[d.Name] {
+			t.Errorf("schema directive %q is not a known task field", d.Name)
+		}
+		seen[d.Name] = true
+	}
+	for name := range knownTaskFields {
+		if !seen[name] {
+			t.Errorf("task field %q is missing from the schema", name)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	data, err := json.Marshal(Schema())
+	if err != nil {
+		t.Fatalf("Schema() is not JSON: %v", err)
+	}
+
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			AnyOf      []struct {
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"anyOf"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	task := schema.Defs["task"]
+	for _, key := range []string{"when", "test_described", "bolt.core.test_described", "ansible.builtin.test_described"} {
+		if _, ok := task.Properties[key]; !ok {
+			t.Errorf("task schema has no %q", key)
+		}
+	}
+
+	params := schema.Defs["module.test_described"].AnyOf[0]
+	if len(params.Required) != 1 || params.Required[0] != "path" {
+		t.Errorf("required = %v, want [path]", params.Required)
+	}
+	if got := params.Properties["port"]["type"]; len(got.([]any)) != 2 {
+		t.Errorf("port type = %v, want integer or template string", got)
+	}
+	if params.Properties["state"]["default"] != "present" || params.Properties["state"]["anyOf"] == nil {
+		t.Errorf("state = %v, want choices and default", params.Properties["state"])
+	}
+}
@@ -0,0 +1,202 @@
+package playbook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	param "github.com/eugenetaranov/bolt/internal/module/params"
+)
+
+// Warning kinds.
+const (
+	// WarnDeprecated marks syntax that still works but will be removed.
+	WarnDeprecated = "deprecated"
+
+	// WarnInsecure marks parameters that weaken the target's security or
+	// expose secrets.
+	WarnInsecure = "insecure"
+
+	// WarnCommand marks command tasks that a module does better.
+	WarnCommand = "command"
+)
+
+// Warning is a non-fatal problem with a task.
+type Warning struct {
+	// Kind is the kind of problem (WarnDeprecated, WarnInsecure, or
+	// WarnCommand).
+	Kind string `json:"kind"`
+
+	// Task is the name of the task.
+	Task string `json:"task"`
+
+	// Message describes the problem and how to fix it.
+	Message string `json:"message"`
+
+	// Pos is the location of the task.
+	Pos Position `json:"position"`
+}
+
+// String returns the warning with its location and task.
+func (w Warning) String() string {
+	s := fmt.Sprintf("%s: %s", w.Task, w.Message)
+	if loc := w.Pos.String(); loc != "" {
+		s = loc + ": " + s
+	}
+	return s
+}
+
+// Warnings returns the warnings of all tasks, handlers, and update
+// strategy hooks of the playbook.
+// Warnings of role tasks are found when the roles are loaded.
+func (pb *Playbook) Warnings() []Warning {
+	var warnings []Warning
+	for _, play := range pb.Plays {
+		warnings = append(warnings, TaskWarnings(play.Tasks)...)
+		warnings = append(warnings, TaskWarnings(play.Handlers)...)
+		if s := play.UpdateStrategy; s != nil {
+			warnings = append(warnings, TaskWarnings(s.PreBatch)...)
+			warnings = append(warnings, TaskWarnings(s.PostBatch)...)
+		}
+	}
+	return warnings
+}
+
+// Warnings returns the warnings of the role's tasks and handlers.
+func (r *Role) Warnings() []Warning {
+	return append(TaskWarnings(r.Tasks), TaskWarnings(r.Handlers)...)
+}
+
+// TaskWarnings returns the warnings of the given tasks.
+func TaskWarnings(tasks []*Task) []Warning {
+	var warnings []Warning
+	for _, task := range tasks {
+		warnings = append(warnings, task.Warnings...)
+	}
+	return warnings
+}
+
+// secretParams are module parameters that hold secrets.
+var secretParams = []string{"password", "login_password", "auth_key", "private_key"}
+
+// modeParamModules are the modules whose mode parameter sets permissions.
+var modeParamModules = map[string]bool{"copy": true, "file": true, "template": true}
+
+// commandModules maps commands to the module that manages the same thing
+// idempotently.
+var commandModules = map[string]string{
+	"apt":                 "apt",
+	"apt-get":             "apt",
+	"brew":                "brew",
+	"dnf":                 "dnf",
+	"yum":                 "dnf",
+	"pkg":                 "pkgng",
+	"mkdir":               "file",
+	"touch":               "file",
+	"rm":                  "file",
+	"ln":                  "file",
+	"chmod":               "file",
+	"chown":               "file",
+	"cp":                  "copy",
+	"update-alternatives": "alternatives",
+	"ssh-keyscan":         "known_hosts",
+	"mkfs":                "filesystem",
+	"vgcreate":            "lvg",
+	"lvcreate":            "lvol",
+	"tailscale":           "tailscale",
+}
+
+// packageCommands are the package manager subcommands that change
+// packages.
+var packageCommands = []string{"install", "remove", "purge", "uninstall", "delete", "upgrade"}
+
+// taskWarnings checks a parsed task and the raw map it was parsed from.
+func taskWarnings(raw map[string]any, task *Task) []Warning {
+	var warnings []Warning
+	warn := func(kind, format string, args ...any) {
+		warnings = append(warnings, Warning{
+			Kind:    kind,
+			Task:    task.String(),
+			Message: fmt.Sprintf(format, args...),
+			Pos:     task.Pos,
+		})
+	}
+
+	if _, ok := raw["with_items"]; ok {
+		warn(WarnDeprecated, "'with_items' is deprecated; use 'loop'")
+	}
+
+	for _, name := range secretParams {
+		if s, ok := task.Params[name].(string); ok && s != "" && !strings.Contains(s, "{{") {
+			warn(WarnInsecure, "parameter '%s' is a plain-text secret; use a variable or a lookup", name)
+		}
+	}
+
+	if modeParamModules[task.Module] {
+		if mode, ok := worldWritable(task.Params["mode"]); ok {
+			warn(WarnInsecure, "mode %s makes the file world-writable", mode)
+		}
+	}
+
+	if task.Module == "command" {
+		if module := commandModule(task.Params); module != "" {
+			warn(WarnCommand, "consider the %s module instead of running the command", module)
+		}
+	}
+
+	return warnings
+}
+
+// worldWritable reports whether a mode parameter grants write access to
+// others, octal or symbolic (o+w), returning the mode as written.
+func worldWritable(v any) (string, bool) {
+	var mode string
+	switch m := v.(type) {
+	case string:
+		mode = m
+	case int:
+		// YAML reads 0777 as an octal integer
+		mode = fmt.Sprintf("%04o", m)
+	default:
+		return "", false
+	}
+	set, _, err := param.ModeBits(mode, false)
+	if err != nil {
+		return "", false
+	}
+	return mode, set&0o002 != 0
+}
+
+// commandModule returns the module to use instead of a command task's
+// command, or "".
+func commandModule(params map[string]any) string {
+	cmd, ok := params["cmd"].(string)
+	if !ok {
+		cmd, _ = params["_raw"].(string)
+	}
+	fields := strings.Fields(cmd)
+	if len(fields) > 0 && fields[0] == "sudo" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+
+	name := fields[0]
+	if strings.HasPrefix(name, "mkfs.") {
+		name = "mkfs"
+	}
+	module := commandModules[name]
+	switch module {
+	case "apt", "brew", "dnf", "pkgng":
+		// Only package changes, not e.g. apt-cache or brew list
+		if len(fields) < 2 || !slices.Contains(packageCommands, fields[1]) {
+			return ""
+		}
+	case "tailscale":
+		if len(fields) < 2 || fields[1] != "up" {
+			return ""
+		}
+	}
+	return module
+}
@@ -0,0 +1,78 @@
+package playbook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	pb, err := ParseRaw([]byte(`
+- hosts: localhost
+  tasks:
+    - name: Install packages
+      command: apt-get install -y nginx
+    - name: Make directory
+      command:
+        cmd: sudo mkdir -p /opt/app
+    - name: Query cache
+      command: apt-cache policy nginx
+    - name: Loop
+      command: echo {{ item }}
+      with_items: [a, b]
+    - name: Open file
+      copy:
+        dest: /tmp/open
+        content: x
+        mode: 0777
+    - name: Private file
+      file:
+        path: /tmp/private
+        mode: "0640"
+    - name: Shared file
+      file:
+        path: /tmp/shared
+        mode: "a+w"
+    - name: Group file
+      file:
+        path: /tmp/group
+        mode: "g+w,o-w"
+    - name: Database user
+      mysql_user:
+        name: app
+        password: hunter2
+    - name: Templated password
+      mysql_user:
+        name: app
+        password: "{{ db_password }}"
+  handlers:
+    - name: Open dir
+      file:
+        path: /tmp/dir
+        mode: "1777"
+`), "site.yaml")
+	if err != nil {
+		t.Fatalf("ParseRaw() error = %v", err)
+	}
+
+	var got []string
+	for _, w := range pb.Warnings() {
+		got = append(got, w.Kind+" "+w.Task)
+	}
+	want := []string{
+		"command Install packages",
+		"command Make directory",
+		"deprecated Loop",
+		"insecure Open file",
+		"insecure Shared file",
+		"insecure Database user",
+		"insecure Open dir",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Warnings() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	w := pb.Warnings()[0]
+	if s := w.String(); s != "site.yaml:4:7: Install packages: consider the apt module instead of running the command" {
+		t.Errorf("String() = %q", s)
+	}
+}
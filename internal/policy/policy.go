@@ -0,0 +1,266 @@
+// Package policy checks the tasks of a run against the rules of a project
+// before any of them runs, such as "no command tasks on production hosts"
+// or "tasks that delete data must ask for confirmation".
+//
+// Rules are set in bolt.yaml:
+//
+//	policy:
+//	  - name: no-command-in-production
+//	    hosts: production
+//	    module: command
+//	    deny: true
+//	    message: use a module that reports changes instead
+//
+//	  - name: confirm-deletes
+//	    module: file
+//	    params:
+//	      state: absent
+//	    require: [confirm]
+//
+// A rule applies to the tasks that match all of its hosts, module, task,
+// and params. A rule with deny forbids them; one with require makes them
+// set the listed task directives.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/inventory"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// Rule is a rule the tasks of a run must follow.
+type Rule struct {
+	// Name identifies the rule in violations.
+	Name string `yaml:"name"`
+
+	// Hosts is a host pattern; the rule applies to the plays that run on
+	// a host matching it, e.g. a group (default: all plays).
+	Hosts string `yaml:"hosts"`
+
+	// Module is a glob of the modules the rule applies to.
+	Module string `yaml:"module"`
+
+	// Task is a glob of the names of the tasks the rule applies to. It is
+	// matched regardless of case.
+	Task string `yaml:"task"`
+
+	// Params are parameters the tasks must have for the rule to apply,
+	// with globs of their values as written in the playbook.
+	Params map[string]string `yaml:"params"`
+
+	// Deny forbids the tasks the rule applies to.
+	Deny bool `yaml:"deny"`
+
+	// Require lists the task directives the tasks the rule applies to
+	// must set (see Directives).
+	Require []string `yaml:"require"`
+
+	// Message explains the rule to whoever breaks it.
+	Message string `yaml:"message"`
+}
+
+// directives are the task directives a rule can require, and whether a
+// task of a play sets them. confirm on the play covers its tasks.
+var directives = map[string]func(play *playbook.Play, task *playbook.Task) bool{
+	"name":         func(_ *playbook.Play, t *playbook.Task) bool { return t.Name != "" },
+	"when":         func(_ *playbook.Play, t *playbook.Task) bool { return t.When != "" },
+	"register":     func(_ *playbook.Play, t *playbook.Task) bool { return t.Register != "" },
+	"confirm":      func(p *playbook.Play, t *playbook.Task) bool { return t.Confirm != "" || p.Confirm != "" },
+	"creates":      func(_ *playbook.Play, t *playbook.Task) bool { return t.Creates != "" },
+	"removes":      func(_ *playbook.Play, t *playbook.Task) bool { return t.Removes != "" },
+	"changed_when": func(_ *playbook.Play, t *playbook.Task) bool { return t.ChangedWhen != "" },
+	"failed_when":  func(_ *playbook.Play, t *playbook.Task) bool { return t.FailedWhen != "" },
+}
+
+// Directives returns the task directives a rule can require, sorted.
+func Directives() []string {
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that rules are complete: each has a unique name, and
+// denies or requires something it knows.
+func Validate(rules []Rule) error {
+	seen := map[string]bool{}
+	for i, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("policy rule %d: name is required", i+1)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("policy rule %s: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+		if r.Deny == (len(r.Require) > 0) {
+			return fmt.Errorf("policy rule %s: set either deny or require", r.Name)
+		}
+		for _, d := range r.Require {
+			if directives[d] == nil {
+				return fmt.Errorf("policy rule %s: cannot require %q (one of %s)", r.Name, d, strings.Join(Directives(), ", "))
+			}
+		}
+		for _, glob := range append([]string{r.Module, r.Task}, mapValues(r.Params)...) {
+			if _, err := path.Match(glob, ""); err != nil {
+				return fmt.Errorf("policy rule %s: invalid pattern %q", r.Name, glob)
+			}
+		}
+	}
+	return nil
+}
+
+// Violation is a task that breaks a rule.
+type Violation struct {
+	// Rule is the name of the rule.
+	Rule string
+
+	// Play is the name of the play (or its hosts if it has none).
+	Play string
+
+	// Task is the name of the task.
+	Task string
+
+	// Pos is the location of the task.
+	Pos playbook.Position
+
+	// Message says what is wrong.
+	Message string
+}
+
+// String returns the violation with its location and task.
+func (v Violation) String() string {
+	s := fmt.Sprintf("%s: %s (policy %s)", v.Task, v.Message, v.Rule)
+	if loc := v.Pos.String(); loc != "" {
+		s = loc + ": " + s
+	}
+	return s
+}
+
+// Error is the error of a run whose tasks break rules.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, 0, len(e.Violations)+1)
+	lines = append(lines, fmt.Sprintf("%d task(s) break the policy:", len(e.Violations)))
+	for _, v := range e.Violations {
+		lines = append(lines, "  "+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ErrViolated matches every *Error with errors.Is.
+var ErrViolated = errors.New("policy violated")
+
+// Is reports whether target is ErrViolated.
+func (e *Error) Is(target error) bool {
+	return target == ErrViolated
+}
+
+// Check returns the violations of rules by tasks, the tasks of play with
+// their module defaults merged into params, which runs on hosts.
+func Check(rules []Rule, play *playbook.Play, hosts []*inventory.Host, tasks []*playbook.Task, params func(*playbook.Task) map[string]any) []Violation {
+	var violations []Violation
+	for _, r := range rules {
+		if !r.appliesTo(hosts) {
+			continue
+		}
+		for _, task := range tasks {
+			if !r.matches(task, params(task)) {
+				continue
+			}
+			if msg := r.check(play, task); msg != "" {
+				violations = append(violations, Violation{
+					Rule:    r.Name,
+					Play:    playName(play),
+					Task:    task.String(),
+					Pos:     task.Pos,
+					Message: msg,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// appliesTo reports whether the rule applies to a play on hosts.
+func (r *Rule) appliesTo(hosts []*inventory.Host) bool {
+	if r.Hosts == "" {
+		return true
+	}
+	return slices.ContainsFunc(hosts, func(h *inventory.Host) bool { return h.Matches(r.Hosts) })
+}
+
+// matches reports whether the rule applies to task, which has params.
+func (r *Rule) matches(task *playbook.Task, params map[string]any) bool {
+	if r.Module != "" && !match(r.Module, task.Module) {
+		return false
+	}
+	if r.Task != "" && !match(strings.ToLower(r.Task), strings.ToLower(task.Name)) {
+		return false
+	}
+	for name, glob := range r.Params {
+		value, ok := params[name]
+		if !ok || !match(glob, fmt.Sprint(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns what is wrong with task, which the rule applies to, or "".
+func (r *Rule) check(play *playbook.Play, task *playbook.Task) string {
+	if r.Deny {
+		return r.message("denied")
+	}
+	var missing []string
+	for _, d := range r.Require {
+		if !directives[d](play, task) {
+			missing = append(missing, d)
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return r.message("must set " + strings.Join(missing, ", "))
+}
+
+// message returns the rule's message, or what if it has none.
+func (r *Rule) message(what string) string {
+	if r.Message != "" {
+		return r.Message
+	}
+	return what
+}
+
+// match reports whether name matches the glob pattern.
+func match(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// mapValues returns the values of m.
+func mapValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
+
+// playName returns the name of play, or its hosts if it has none.
+func playName(play *playbook.Play) string {
+	if play.Name != "" {
+		return play.Name
+	}
+	return play.Hosts
+}
@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/inventory"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		want  string
+	}{
+		{"valid", []Rule{{Name: "a", Module: "command", Deny: true}, {Name: "b", Require: []string{"confirm", "when"}}}, ""},
+		{"no name", []Rule{{Deny: true}}, "name is required"},
+		{"duplicate", []Rule{{Name: "a", Deny: true}, {Name: "a", Deny: true}}, "duplicate name"},
+		{"neither", []Rule{{Name: "a", Module: "command"}}, "set either deny or require"},
+		{"both", []Rule{{Name: "a", Deny: true, Require: []string{"when"}}}, "set either deny or require"},
+		{"unknown directive", []Rule{{Name: "a", Require: []string{"no_log"}}}, `cannot require "no_log"`},
+		{"bad glob", []Rule{{Name: "a", Task: "[", Deny: true}}, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.rules)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	web := &inventory.Host{Name: "web1", Groups: []string{"production", "web"}}
+	staging := &inventory.Host{Name: "web9", Groups: []string{"staging"}}
+	play := &playbook.Play{Name: "site"}
+	tasks := []*playbook.Task{
+		{Name: "uptime", Module: "command", Pos: playbook.Position{File: "site.yaml", Line: 4, Column: 7}},
+		{Name: "Remove old releases", Module: "file", Params: map[string]any{"path": "/srv/old", "state": "absent"}},
+		{Name: "Create dir", Module: "file", Params: map[string]any{"path": "/srv/new", "state": "directory"}},
+		{Name: "Remove cache", Module: "file", Params: map[string]any{"path": "/var/cache", "state": "absent"}, Confirm: "Sure?"},
+		{Module: "apt", Params: map[string]any{"name": "nginx"}},
+	}
+	params := func(task *playbook.Task) map[string]any { return task.Params }
+	rules := []Rule{
+		{Name: "no-command", Hosts: "production", Module: "command", Deny: true, Message: "use a module"},
+		{Name: "confirm-deletes", Module: "file", Params: map[string]string{"state": "absent"}, Require: []string{"confirm"}},
+		{Name: "named", Require: []string{"name", "register"}, Task: "REMOVE*"},
+		{Name: "unnamed", Module: "ap?", Require: []string{"name"}},
+	}
+
+	got := Check(rules, play, []*inventory.Host{web}, tasks, params)
+	want := []Violation{
+		{Rule: "no-command", Play: "site", Task: "uptime", Pos: tasks[0].Pos, Message: "use a module"},
+		{Rule: "confirm-deletes", Play: "site", Task: "Remove old releases", Message: "must set confirm"},
+		{Rule: "named", Play: "site", Task: "Remove old releases", Message: "must set register"},
+		{Rule: "named", Play: "site", Task: "Remove cache", Message: "must set register"},
+		{Rule: "unnamed", Play: "site", Task: `apt: {name="nginx"}`, Message: "must set name"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %+v\nwant %+v", got, want)
+	}
+	if s := got[0].String(); s != "site.yaml:4:7: uptime: use a module (policy no-command)" {
+		t.Errorf("String() = %q", s)
+	}
+
+	// Rules with hosts only apply to plays on matching hosts
+	if got := Check(rules[:1], play, []*inventory.Host{staging}, tasks, params); len(got) != 0 {
+		t.Errorf("Check() on staging = %+v, want none", got)
+	}
+
+	// Confirming the play confirms its tasks
+	confirmed := &playbook.Play{Name: "site", Confirm: "Run?"}
+	if got := Check(rules[1:2], confirmed, nil, tasks, params); len(got) != 0 {
+		t.Errorf("Check() of a confirmed play = %+v, want none", got)
+	}
+}
+
+func TestError(t *testing.T) {
+	err := error(&Error{Violations: []Violation{
+		{Rule: "a", Task: "one", Message: "denied"},
+		{Rule: "b", Task: "two", Message: "must set when"},
+	}})
+	if !errors.Is(err, ErrViolated) {
+		t.Error("errors.Is(err, ErrViolated) = false")
+	}
+	want := "2 task(s) break the policy:\n  one: denied (policy a)\n  two: must set when (policy b)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
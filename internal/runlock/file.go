@@ -0,0 +1,63 @@
+// Package runlock keeps concurrent bolt runs from interleaving changes.
+//
+// A run takes a lock on every host before changing it: an flock on the
+// controller for local connections, and a lock directory on the target
+// for remote ones. The agent also uses a file lock to keep its runs from
+// overlapping.
+package runlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrLocked is returned when another run holds a lock.
+var ErrLocked = errors.New("locked by another run")
+
+// FileLock is an exclusive advisory lock on a file. The kernel releases
+// it when the process exits, so a crashed run never leaves a stale lock.
+type FileLock struct {
+	f *os.File
+}
+
+// File takes the lock on path without waiting, creating the file and its
+// directory if needed. The holder's PID is written to the file for
+// diagnostics. If another process holds the lock, the error wraps
+// ErrLocked and names that process.
+func File(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder, _ := os.ReadFile(path)
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			if pid := strings.TrimSpace(string(holder)); pid != "" {
+				return nil, fmt.Errorf("%s: %w (pid %s)", path, ErrLocked, pid)
+			}
+			return nil, fmt.Errorf("%s: %w", path, ErrLocked)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	// The PID is left behind; an unlocked file is not held by anyone
+	return l.f.Close()
+}
@@ -0,0 +1,160 @@
+package runlock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// LocalPath is the lock file of runs with local connections. It is in
+// /tmp rather than the user's temporary directory so runs of different
+// users see each other. It is not named bolt.lock, which would clash with
+// the lock file of a playbook in /tmp.
+const LocalPath = "/tmp/bolt-run.lock"
+
+// RemotePath is the lock directory on remote targets. mkdir is atomic on
+// every POSIX system, which makes a directory the most portable lock.
+var RemotePath = "/tmp/bolt-run.lock.d"
+
+// DefaultStaleAfter is how old a remote lock of another controller must
+// be before it is considered left behind by a crashed run.
+const DefaultStaleAfter = 4 * time.Hour
+
+// Owner identifies the run holding a lock.
+type Owner struct {
+	// Controller is the hostname of the machine running bolt.
+	Controller string
+
+	// PID is the process ID of bolt on the controller.
+	PID int
+
+	// User is the user running bolt.
+	User string
+
+	// Started is when the lock was taken.
+	Started time.Time
+}
+
+// CurrentOwner returns the owner for locks taken by this process.
+func CurrentOwner() Owner {
+	o := Owner{PID: os.Getpid(), Started: time.Now()}
+	o.Controller, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		o.User = u.Username
+	}
+	return o
+}
+
+// String describes the owner for error messages.
+func (o Owner) String() string {
+	return fmt.Sprintf("%s@%s (pid %d) since %s", o.User, o.Controller, o.PID, o.Started.Format(time.RFC3339))
+}
+
+// encode returns the owner as stored in the lock.
+func (o Owner) encode() string {
+	return fmt.Sprintf("%s %d %s %d", o.Controller, o.PID, o.User, o.Started.Unix())
+}
+
+// parseOwner parses an encoded owner.
+func parseOwner(s string) (Owner, bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 4 {
+		return Owner{}, false
+	}
+	pid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Owner{}, false
+	}
+	started, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return Owner{}, false
+	}
+	return Owner{Controller: fields[0], PID: pid, User: fields[2], Started: time.Unix(started, 0)}, true
+}
+
+// stale reports whether the run that took the lock is gone: a process
+// of this controller that no longer exists, or a lock older than
+// staleAfter.
+func (o Owner) stale(self Owner, staleAfter time.Duration) bool {
+	if o.Controller == self.Controller {
+		return !processAlive(o.PID)
+	}
+	return staleAfter > 0 && time.Since(o.Started) > staleAfter
+}
+
+// processAlive reports whether a process with the PID exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// HostLock is the run lock on a remote target.
+type HostLock struct {
+	conn  connector.Connector
+	owner string
+
+	// Broken is the owner of a stale lock that was removed to take this
+	// one, or nil.
+	Broken *Owner
+}
+
+// Host takes the run lock on the target of conn without waiting. A lock
+// left behind by a crashed run (see Owner.stale) is removed; one held by
+// a live run is an error wrapping ErrLocked that names its owner.
+func Host(ctx context.Context, conn connector.Connector, staleAfter time.Duration) (*HostLock, error) {
+	self := CurrentOwner()
+	l := &HostLock{conn: conn, owner: self.encode()}
+
+	acquire := fmt.Sprintf("mkdir %s 2>/dev/null && printf '%%s\\n' %s > %s/owner",
+		RemotePath, shellutil.Quote(l.owner), RemotePath)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := conn.Execute(ctx, acquire)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %s: %w", conn, err)
+		}
+		if result.ExitCode == 0 {
+			return l, nil
+		}
+
+		result, err = conn.Execute(ctx, fmt.Sprintf("cat %s/owner", RemotePath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock owner on %s: %w", conn, err)
+		}
+		owner, ok := parseOwner(result.Stdout)
+		if !ok {
+			// Being created right now, or not ours to judge
+			return nil, fmt.Errorf("%s: %w (%s exists)", conn, ErrLocked, RemotePath)
+		}
+		if attempt > 0 || !owner.stale(self, staleAfter) {
+			return nil, fmt.Errorf("%s: %w: %s", conn, ErrLocked, owner)
+		}
+
+		// Remove the stale lock only if it is still the same one
+		remove := fmt.Sprintf("[ \"$(cat %s/owner)\" = %s ] && rm -rf %s",
+			RemotePath, shellutil.Quote(owner.encode()), RemotePath)
+		if _, err := conn.Execute(ctx, remove); err != nil {
+			return nil, fmt.Errorf("failed to remove stale lock on %s: %w", conn, err)
+		}
+		l.Broken = &owner
+	}
+	return nil, fmt.Errorf("%s: %w", conn, ErrLocked)
+}
+
+// Unlock releases the lock, unless another run broke it in the meantime.
+func (l *HostLock) Unlock(ctx context.Context) error {
+	release := fmt.Sprintf("[ \"$(cat %s/owner 2>/dev/null)\" = %s ] && rm -rf %s; true",
+		RemotePath, shellutil.Quote(l.owner), RemotePath)
+	if _, err := l.conn.Execute(ctx, release); err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", l.conn, err)
+	}
+	return nil
+}
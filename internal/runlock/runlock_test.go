@@ -0,0 +1,103 @@
+package runlock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector/local"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "agent.lock")
+
+	lock, err := File(path)
+	if err != nil {
+		t.Fatalf("File() error = %v", err)
+	}
+	if _, err := File(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("second File() error = %v, want ErrLocked", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = File(path)
+	if err != nil {
+		t.Fatalf("File() after Unlock() error = %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestHost(t *testing.T) {
+	RemotePath = filepath.Join(t.TempDir(), "bolt-run.lock.d")
+	ctx := context.Background()
+	conn := local.New()
+
+	lock, err := Host(ctx, conn, time.Hour)
+	if err != nil {
+		t.Fatalf("Host() error = %v", err)
+	}
+	if lock.Broken != nil {
+		t.Errorf("Broken = %v, want nil", lock.Broken)
+	}
+
+	// This process is alive, so its lock is not stale
+	_, err = Host(ctx, conn, time.Hour)
+	if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), "pid") {
+		t.Errorf("second Host() error = %v, want ErrLocked naming the owner", err)
+	}
+
+	if err := lock.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = Host(ctx, conn, time.Hour)
+	if err != nil {
+		t.Fatalf("Host() after Unlock() error = %v", err)
+	}
+	lock.Unlock(ctx)
+}
+
+func TestHostStale(t *testing.T) {
+	RemotePath = filepath.Join(t.TempDir(), "bolt-run.lock.d")
+	ctx := context.Background()
+	conn := local.New()
+	self := CurrentOwner()
+
+	tests := []struct {
+		name  string
+		owner Owner
+		stale bool
+	}{
+		{"dead process", Owner{Controller: self.Controller, PID: 1 << 22, User: "ops", Started: time.Now()}, true},
+		{"other controller", Owner{Controller: "elsewhere", PID: 1, User: "ops", Started: time.Now()}, false},
+		{"old lock", Owner{Controller: "elsewhere", PID: 1, User: "ops", Started: time.Now().Add(-2 * time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write := "mkdir -p " + RemotePath + " && echo " + shellutil.Quote(tt.owner.encode()) + " > " + RemotePath + "/owner"
+			if _, err := conn.Execute(ctx, write); err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Execute(ctx, "rm -rf "+RemotePath)
+
+			lock, err := Host(ctx, conn, time.Hour)
+			if !tt.stale {
+				if !errors.Is(err, ErrLocked) {
+					t.Errorf("Host() error = %v, want ErrLocked", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Host() error = %v, want the stale lock broken", err)
+			}
+			if lock.Broken == nil || lock.Broken.PID != tt.owner.PID {
+				t.Errorf("Broken = %v, want %v", lock.Broken, tt.owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/connector/docker"
+	"github.com/eugenetaranov/bolt/internal/executor"
+	"github.com/eugenetaranov/bolt/internal/output"
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// Stage names the step of a scenario run.
+type Stage string
+
+const (
+	StageCreate      Stage = "create"      // Start the container
+	StageConverge    Stage = "converge"    // Run the playbook
+	StageIdempotence Stage = "idempotence" // Run the playbook again, expecting no changes
+	StageVerify      Stage = "verify"      // Run the verify tasks
+)
+
+// defaultCommand keeps a container running without relying on the image's
+// entrypoint.
+var defaultCommand = []string{"tail", "-f", "/dev/null"}
+
+// Result holds the outcome of a scenario run.
+type Result struct {
+	Scenario *Scenario
+
+	// Passed is true if every stage succeeded.
+	Passed bool
+
+	// Stage is the stage that failed, if any.
+	Stage Stage
+
+	// Err describes the failure.
+	Err error
+
+	Duration time.Duration
+}
+
+// Runner runs scenarios in fresh containers.
+type Runner struct {
+	// Output receives playbook output for each stage.
+	Output *output.Output
+
+	// Debug enables detailed executor output.
+	Debug bool
+}
+
+// NewRunner creates a runner writing to w.
+func NewRunner(w io.Writer) *Runner {
+	return &Runner{Output: output.New(w)}
+}
+
+// Run starts a container for the scenario, converges the playbook, checks
+// idempotence if requested, and runs the verify tasks.
+func (r *Runner) Run(ctx context.Context, sc *Scenario) *Result {
+	start := time.Now()
+	result := &Result{Scenario: sc}
+
+	fail := func(stage Stage, err error) *Result {
+		result.Stage = stage
+		result.Err = err
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	// Parse everything before paying for a container
+	converge, err := playbook.ParseFileRaw(sc.PlaybookPath())
+	if err != nil {
+		return fail(StageConverge, err)
+	}
+	verify, err := sc.VerifyPlaybook()
+	if err != nil {
+		return fail(StageVerify, err)
+	}
+
+	r.Output.Section(fmt.Sprintf("SCENARIO %s", sc.Name))
+
+	container, err := r.startContainer(ctx, sc)
+	if err != nil {
+		return fail(StageCreate, err)
+	}
+	defer func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			r.Output.Warn("Failed to remove container: %v", err)
+		}
+	}()
+
+	conn := docker.New(container.GetContainerID())
+
+	if _, err := r.runPlaybook(ctx, converge, conn, sc.Vars); err != nil {
+		return fail(StageConverge, err)
+	}
+
+	if sc.Idempotence {
+		stats, err := r.runPlaybook(ctx, converge, conn, sc.Vars)
+		if err != nil {
+			return fail(StageIdempotence, err)
+		}
+		if stats.Changed > 0 {
+			return fail(StageIdempotence, fmt.Errorf("%d task(s) changed on the second run", stats.Changed))
+		}
+	}
+
+	if verify != nil {
+		if _, err := r.runPlaybook(ctx, verify, conn, sc.Vars); err != nil {
+			return fail(StageVerify, err)
+		}
+	}
+
+	result.Passed = true
+	result.Duration = time.Since(start)
+	return result
+}
+
+// runPlaybook runs pb with every play bound to conn and vars passed as
+// extra vars.
+func (r *Runner) runPlaybook(ctx context.Context, pb *playbook.Playbook, conn connector.Connector, vars map[string]any) (*executor.Stats, error) {
+	exec := executor.New()
+	exec.Output = r.Output
+	exec.Debug = r.Debug
+	exec.ExtraVars = vars
+	for _, play := range pb.Plays {
+		exec.SetConnector(play.Hosts, conn)
+	}
+
+	result, err := exec.Run(ctx, pb)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return result.Stats, fmt.Errorf("playbook %s failed", pb.Path)
+	}
+	return result.Stats, nil
+}
+
+// startContainer creates and starts the scenario's container.
+func (r *Runner) startContainer(ctx context.Context, sc *Scenario) (testcontainers.Container, error) {
+	cmd := sc.Command
+	if len(cmd) == 0 {
+		cmd = defaultCommand
+	}
+
+	req := testcontainers.ContainerRequest{
+		Image:      sc.Image,
+		Cmd:        cmd,
+		Labels:     map[string]string{"bolt.scenario": sc.Name},
+		WaitingFor: wait.ForExec([]string{"true"}).WithStartupTimeout(60 * time.Second),
+	}
+	if sc.Privileged {
+		req.HostConfigModifier = func(hc *dockercontainer.HostConfig) {
+			hc.Privileged = true
+		}
+	}
+	if sc.Dockerfile != "" {
+		dockerfile := sc.resolve(sc.Dockerfile)
+		req.FromDockerfile = testcontainers.FromDockerfile{
+			Context:    filepath.Dir(dockerfile),
+			Dockerfile: filepath.Base(dockerfile),
+		}
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		if container != nil {
+			_ = container.Terminate(context.Background())
+		}
+		return nil, fmt.Errorf("failed to start container: %w", err)
+	}
+	return container, nil
+}
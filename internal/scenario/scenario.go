@@ -0,0 +1,182 @@
+// Package scenario runs playbook self-tests against ephemeral containers.
+//
+// A scenario names an image, the playbook to converge, and a list of
+// verification tasks (typically the assert module) that run against the
+// same container afterwards:
+//
+//	name: nginx
+//	image: debian:12
+//	playbook: ../site.yaml
+//	idempotence: true
+//	verify:
+//	  - name: nginx is installed
+//	    assert:
+//	      command: nginx -v
+package scenario
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/eugenetaranov/bolt/internal/playbook"
+)
+
+// FileSuffix is the suffix that marks a YAML file as a test scenario.
+const FileSuffix = ".test.yaml"
+
+// Scenario describes a single playbook self-test.
+type Scenario struct {
+	// Name identifies the scenario in output (default: file name).
+	Name string `yaml:"name"`
+
+	// Image is the container image to test against.
+	Image string `yaml:"image"`
+
+	// Dockerfile builds the test image instead of pulling Image.
+	// It is resolved relative to the scenario file.
+	Dockerfile string `yaml:"dockerfile"`
+
+	// Command keeps the container running (default: tail -f /dev/null).
+	Command []string `yaml:"command"`
+
+	// Privileged runs the container in privileged mode (e.g., for systemd).
+	Privileged bool `yaml:"privileged"`
+
+	// Playbook is the playbook to converge, relative to the scenario file.
+	Playbook string `yaml:"playbook"`
+
+	// Vars are passed to the converged playbook and the verify tasks as
+	// extra vars, overriding play and role variables.
+	Vars map[string]any `yaml:"vars"`
+
+	// Idempotence runs the playbook a second time and fails if any task
+	// reports a change.
+	Idempotence bool `yaml:"idempotence"`
+
+	// Verify holds the tasks that check the converged container.
+	Verify []map[string]any `yaml:"verify"`
+
+	// Path is the file the scenario was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Load reads and validates a scenario file.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	sc.Path = path
+
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), FileSuffix)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &sc, nil
+}
+
+// Validate checks that the scenario is complete.
+func (s *Scenario) Validate() error {
+	if s.Image == "" && s.Dockerfile == "" {
+		return fmt.Errorf("either 'image' or 'dockerfile' is required")
+	}
+	if s.Image != "" && s.Dockerfile != "" {
+		return fmt.Errorf("'image' and 'dockerfile' are mutually exclusive")
+	}
+	if s.Playbook == "" {
+		return fmt.Errorf("'playbook' is required")
+	}
+	return nil
+}
+
+// resolve returns p relative to the scenario file's directory.
+func (s *Scenario) resolve(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(s.Path), p)
+}
+
+// PlaybookPath returns the path of the playbook to converge.
+func (s *Scenario) PlaybookPath() string {
+	return s.resolve(s.Playbook)
+}
+
+// VerifyPlaybook builds a playbook running the verify tasks as a single play.
+// It returns nil if the scenario has no verify tasks.
+func (s *Scenario) VerifyPlaybook() (*playbook.Playbook, error) {
+	if len(s.Verify) == 0 {
+		return nil, nil
+	}
+
+	play := map[string]any{
+		"name":         "Verify " + s.Name,
+		"hosts":        s.Name,
+		"gather_facts": false,
+		"tasks":        s.Verify,
+	}
+	data, err := yaml.Marshal([]any{play})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode verify tasks: %w", err)
+	}
+
+	pb, err := playbook.ParseRaw(data, s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verify tasks: %w", err)
+	}
+	return pb, nil
+}
+
+// Discover returns the scenario files under the given paths, sorted.
+// Files are used as given; directories are searched recursively for
+// files ending in FileSuffix, skipping hidden directories.
+func Discover(paths []string) ([]string, error) {
+	var files []string
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("scenario path not found: %s", root)
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(d.Name(), FileSuffix) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %w", root, err)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,114 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/eugenetaranov/bolt/internal/module/assert"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "web.test.yaml")
+	writeFile(t, path, `
+image: debian:12
+playbook: ../site.yaml
+idempotence: true
+verify:
+  - name: motd exists
+    assert:
+      path: /etc/motd
+      contains: welcome
+`)
+
+	sc, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sc.Name != "web" {
+		t.Errorf("name = %q, want web", sc.Name)
+	}
+	if !sc.Idempotence {
+		t.Error("expected idempotence to be enabled")
+	}
+	if want := filepath.Join(filepath.Dir(dir), "site.yaml"); sc.PlaybookPath() != want {
+		t.Errorf("playbook path = %q, want %q", sc.PlaybookPath(), want)
+	}
+
+	pb, err := sc.VerifyPlaybook()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pb.Plays) != 1 || len(pb.Plays[0].Tasks) != 1 {
+		t.Fatalf("expected one play with one task, got %+v", pb.Plays)
+	}
+	task := pb.Plays[0].Tasks[0]
+	if task.Module != "assert" || task.Params["path"] != "/etc/motd" {
+		t.Errorf("unexpected verify task: %s %v", task.Module, task.Params)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sc      Scenario
+		wantErr bool
+	}{
+		{"image", Scenario{Image: "alpine", Playbook: "site.yaml"}, false},
+		{"dockerfile", Scenario{Dockerfile: "Dockerfile", Playbook: "site.yaml"}, false},
+		{"no image", Scenario{Playbook: "site.yaml"}, true},
+		{"image and dockerfile", Scenario{Image: "alpine", Dockerfile: "Dockerfile", Playbook: "site.yaml"}, true},
+		{"no playbook", Scenario{Image: "alpine"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sc.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"b.test.yaml",
+		"a/a.test.yaml",
+		"a/playbook.yaml",
+		".hidden/skip.test.yaml",
+	} {
+		writeFile(t, filepath.Join(dir, name), "")
+	}
+	explicit := filepath.Join(dir, "a", "playbook.yaml")
+
+	got, err := Discover([]string{dir, explicit})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "a", "a.test.yaml"),
+		explicit,
+		filepath.Join(dir, "b.test.yaml"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %v, want %v", got, want)
+	}
+
+	if _, err := Discover([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
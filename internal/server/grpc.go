@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	boltv1 "github.com/eugenetaranov/bolt/api/bolt/v1"
+)
+
+// GRPC returns a gRPC server of the Bolt service of api/bolt/v1. Its runs
+// share the queue and history of the HTTP API. With a token, calls must
+// send it in the authorization metadata, as "Bearer <token>".
+func (s *Server) GRPC(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			if err := s.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context()); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}),
+	)
+	g := grpc.NewServer(opts...)
+	boltv1.RegisterBoltServer(g, &boltService{s: s})
+	return g
+}
+
+// authorize rejects calls without the token.
+func (s *Server) authorize(ctx context.Context) error {
+	if s.Token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+// boltService implements the Bolt gRPC service.
+type boltService struct {
+	boltv1.UnimplementedBoltServer
+	s *Server
+}
+
+func (b *boltService) ListPlaybooks(ctx context.Context, req *boltv1.ListPlaybooksRequest) (*boltv1.ListPlaybooksResponse, error) {
+	playbooks, err := b.s.playbooks()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &boltv1.ListPlaybooksResponse{Playbooks: playbooks}, nil
+}
+
+func (b *boltService) Run(req *boltv1.RunRequest, stream boltv1.Bolt_RunServer) error {
+	if _, err := b.s.playbookPath(req.GetPlaybook()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	r := &Request{
+		Playbook: req.GetPlaybook(),
+		DryRun:   req.GetDryRun(),
+		Diff:     req.GetDiff(),
+		Limit:    req.GetLimit(),
+	}
+	if len(req.GetExtraVars()) > 0 {
+		r.ExtraVars = make(map[string]any, len(req.GetExtraVars()))
+		for k, v := range req.GetExtraVars() {
+			r.ExtraVars[k] = v
+		}
+	}
+	run, err := b.s.submit(r)
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	// A run nobody follows any more is of no use to its caller
+	ctx := stream.Context()
+	defer func() {
+		if ctx.Err() != nil {
+			_ = b.s.cancel(run)
+		}
+	}()
+
+	snapshot := b.s.snapshot(run)
+	if err := stream.Send(&boltv1.RunEvent{Event: &boltv1.RunEvent_Queued{Queued: runProto(&snapshot)}}); err != nil {
+		return err
+	}
+	var c cursor
+	for {
+		entries, closed, wait := run.journal.read(&c)
+		for _, e := range entries {
+			ev := &boltv1.RunEvent{Event: &boltv1.RunEvent_Output{Output: e.output}}
+			switch {
+			case e.task != nil:
+				ev = &boltv1.RunEvent{Event: &boltv1.RunEvent_Task{Task: taskProto(e.task)}}
+			case e.confirm != nil:
+				ev = &boltv1.RunEvent{Event: &boltv1.RunEvent_Confirm{Confirm: confirmationProto(e.confirm)}}
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+		if closed {
+			snapshot := b.s.snapshot(run)
+			return stream.Send(&boltv1.RunEvent{Event: &boltv1.RunEvent_Finished{Finished: runProto(&snapshot)}})
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-wait:
+		}
+	}
+}
+
+func (b *boltService) Cancel(ctx context.Context, req *boltv1.CancelRequest) (*boltv1.CancelResponse, error) {
+	run := b.s.lookup(req.GetId())
+	if run == nil {
+		return nil, status.Error(codes.NotFound, "run not found")
+	}
+	if err := b.s.cancel(run); err != nil {
+		if errors.Is(err, errFinished) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, err
+	}
+	snapshot := b.s.snapshot(run)
+	return &boltv1.CancelResponse{Run: runProto(&snapshot)}, nil
+}
+
+func (b *boltService) Confirm(ctx context.Context, req *boltv1.ConfirmRequest) (*boltv1.ConfirmResponse, error) {
+	run := b.s.lookup(req.GetId())
+	if run == nil {
+		return nil, status.Error(codes.NotFound, "run not found")
+	}
+	if err := b.s.answer(run, req.GetApprove()); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	snapshot := b.s.snapshot(run)
+	return &boltv1.ConfirmResponse{Run: runProto(&snapshot)}, nil
+}
+
+// runStatuses maps the statuses of runs to their protobuf values.
+var runStatuses = map[string]boltv1.RunStatus{
+	StatusQueued:   boltv1.RunStatus_RUN_STATUS_QUEUED,
+	StatusRunning:  boltv1.RunStatus_RUN_STATUS_RUNNING,
+	StatusOK:       boltv1.RunStatus_RUN_STATUS_OK,
+	StatusChanged:  boltv1.RunStatus_RUN_STATUS_CHANGED,
+	StatusFailed:   boltv1.RunStatus_RUN_STATUS_FAILED,
+	StatusError:    boltv1.RunStatus_RUN_STATUS_ERROR,
+	StatusCanceled: boltv1.RunStatus_RUN_STATUS_CANCELED,
+}
+
+// taskStatuses maps the statuses of tasks to their protobuf values.
+var taskStatuses = map[string]boltv1.TaskStatus{
+	"ok":          boltv1.TaskStatus_TASK_STATUS_OK,
+	"changed":     boltv1.TaskStatus_TASK_STATUS_CHANGED,
+	"failed":      boltv1.TaskStatus_TASK_STATUS_FAILED,
+	"unreachable": boltv1.TaskStatus_TASK_STATUS_UNREACHABLE,
+	"skipped":     boltv1.TaskStatus_TASK_STATUS_SKIPPED,
+}
+
+// runProto converts run to its protobuf message.
+func runProto(run *Run) *boltv1.Run {
+	msg := &boltv1.Run{
+		Id:       run.ID,
+		Playbook: run.Playbook,
+		DryRun:   run.DryRun,
+		Status:   runStatuses[run.Status],
+		Created:  timestamppb.New(run.Created),
+		Started:  timestampProto(run.Started),
+		Finished: timestampProto(run.Finished),
+		Error:    run.Error,
+	}
+	if run.Confirmation != nil {
+		msg.Confirmation = confirmationProto(run.Confirmation)
+	}
+	if sum := run.Summary; sum != nil {
+		msg.Summary = &boltv1.Summary{
+			Success:  sum.Success,
+			Ok:       int32(sum.OK),
+			Changed:  int32(sum.Changed),
+			Failed:   int32(sum.Failed),
+			Skipped:  int32(sum.Skipped),
+			Duration: durationpb.New(sum.Duration),
+		}
+		for _, f := range sum.Failures {
+			msg.Summary.Failures = append(msg.Summary.Failures, &boltv1.Failure{Play: f.Play, Host: f.Host, Task: f.Task, Error: f.Error})
+		}
+	}
+	return msg
+}
+
+// taskProto converts t to its protobuf message.
+func taskProto(t *Task) *boltv1.TaskResult {
+	return &boltv1.TaskResult{
+		Play:     t.Play,
+		Host:     t.Host,
+		Task:     t.Task,
+		Module:   t.Module,
+		Status:   taskStatuses[t.Status],
+		Message:  t.Message,
+		Duration: durationpb.New(t.Duration),
+	}
+}
+
+// confirmationProto converts c to its protobuf message.
+func confirmationProto(c *Confirmation) *boltv1.Confirmation {
+	return &boltv1.Confirmation{Play: c.Play, Host: c.Host, Task: c.Task, Question: c.Question}
+}
+
+// timestampProto converts t, if set, to a protobuf timestamp.
+func timestampProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	boltv1 "github.com/eugenetaranov/bolt/api/bolt/v1"
+)
+
+// newClient serves the gRPC API of s in memory and returns a client.
+func newClient(t *testing.T, s *Server) boltv1.BoltClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	g := s.GRPC()
+	go g.Serve(ln)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return boltv1.NewBoltClient(conn)
+}
+
+// authorized returns a context sending the token of the test server.
+func authorized(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+}
+
+func TestGRPCRun(t *testing.T) {
+	s, _, release := newServer(t, "")
+	client := newClient(t, s)
+	ctx := authorized(context.Background())
+
+	list, err := client.ListPlaybooks(ctx, &boltv1.ListPlaybooksRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := list.GetPlaybooks(); len(got) != 1 || got[0] != "site.yaml" {
+		t.Errorf("ListPlaybooks = %v, want [site.yaml]", got)
+	}
+
+	stream, err := client.Run(ctx, &boltv1.RunRequest{Playbook: "site.yaml", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		release <- "TASK [motd]"
+		close(release)
+	}()
+
+	var events []*boltv1.RunEvent
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want queued, output, task, and finished: %v", len(events), events)
+	}
+	queued := events[0].GetQueued()
+	if queued == nil || queued.GetId() == "" || !queued.GetDryRun() {
+		t.Errorf("first event = %v, want the queued dry run", events[0])
+	}
+	if got := events[1].GetOutput(); got != "TASK [motd]\n" {
+		t.Errorf("output = %q, want the line of the run", got)
+	}
+	task := events[2].GetTask()
+	if task.GetHost() != "web1" || task.GetStatus() != boltv1.TaskStatus_TASK_STATUS_CHANGED {
+		t.Errorf("task = %v, want changed on web1", task)
+	}
+	finished := events[3].GetFinished()
+	if finished.GetId() != queued.GetId() || finished.GetStatus() != boltv1.RunStatus_RUN_STATUS_CHANGED || finished.GetSummary().GetChanged() != 1 {
+		t.Errorf("last event = %v, want the changed run", events[3])
+	}
+}
+
+func TestGRPCCancel(t *testing.T) {
+	s, _, _ := newServer(t, "")
+	client := newClient(t, s)
+	ctx := authorized(context.Background())
+
+	stream, err := client.Run(ctx, &boltv1.RunRequest{Playbook: "site.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := ev.GetQueued().GetId()
+
+	resp, err := client.Cancel(ctx, &boltv1.CancelRequest{Id: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetRun().GetId() != id {
+		t.Errorf("Cancel = %v, want run %s", resp.GetRun(), id)
+	}
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run := ev.GetFinished(); run != nil {
+			if run.GetStatus() != boltv1.RunStatus_RUN_STATUS_CANCELED {
+				t.Errorf("finished run status = %v, want canceled", run.GetStatus())
+			}
+			break
+		}
+	}
+
+	if _, err := client.Cancel(ctx, &boltv1.CancelRequest{Id: id}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Cancel finished run = %v, want FailedPrecondition", err)
+	}
+	if _, err := client.Cancel(ctx, &boltv1.CancelRequest{Id: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Cancel unknown run = %v, want NotFound", err)
+	}
+}
+
+func TestGRPCConfirm(t *testing.T) {
+	s, _, release := newServer(t, "")
+	client := newClient(t, s)
+	ctx := authorized(context.Background())
+
+	stream, err := client.Run(ctx, &boltv1.RunRequest{Playbook: "site.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		release <- "Delete the data?"
+		close(release)
+	}()
+
+	var id string
+	var output strings.Builder
+	for {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run := ev.GetQueued(); run != nil {
+			id = run.GetId()
+		}
+		output.WriteString(ev.GetOutput())
+		if c := ev.GetConfirm(); c != nil {
+			if c.GetQuestion() != "Delete the data?" || c.GetTask() != "drop" {
+				t.Errorf("confirm = %v, want the question", c)
+			}
+			if _, err := client.Confirm(ctx, &boltv1.ConfirmRequest{Id: "nope"}); status.Code(err) != codes.NotFound {
+				t.Errorf("Confirm unknown run = %v, want NotFound", err)
+			}
+			resp, err := client.Confirm(ctx, &boltv1.ConfirmRequest{Id: id, Approve: false})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.GetRun().GetConfirmation() != nil {
+				t.Errorf("Confirm = %v, want the answered run", resp.GetRun())
+			}
+			if _, err := client.Confirm(ctx, &boltv1.ConfirmRequest{Id: id}); status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("Confirm twice = %v, want FailedPrecondition", err)
+			}
+		}
+		if ev.GetFinished() != nil {
+			break
+		}
+	}
+	if !strings.Contains(output.String(), "approved: false") {
+		t.Errorf("output = %q, want the declined answer", output.String())
+	}
+}
+
+func TestGRPCCancelOnDisconnect(t *testing.T) {
+	s, _, _ := newServer(t, "")
+	client := newClient(t, s)
+	ctx, cancel := context.WithCancel(authorized(context.Background()))
+
+	stream, err := client.Run(ctx, &boltv1.RunRequest{Playbook: "site.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	run := s.lookup(ev.GetQueued().GetId())
+	for range 200 {
+		if snapshot := s.snapshot(run); snapshot.done() {
+			if snapshot.Status != StatusCanceled {
+				t.Errorf("run status = %s, want canceled", snapshot.Status)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("run was not canceled when its caller went away")
+}
+
+func TestGRPCRejects(t *testing.T) {
+	s, _, _ := newServer(t, "")
+	client := newClient(t, s)
+
+	if _, err := client.ListPlaybooks(context.Background(), &boltv1.ListPlaybooksRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListPlaybooks without token = %v, want Unauthenticated", err)
+	}
+	stream, err := client.Run(context.Background(), &boltv1.RunRequest{Playbook: "site.yaml"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Run without token = %v, want Unauthenticated", err)
+	}
+
+	stream, err = client.Run(authorized(context.Background()), &boltv1.RunRequest{Playbook: "../site.yaml"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Run outside the directory = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,822 @@
+// Package server serves a REST API and a web dashboard for running
+// playbooks: clients start runs, follow their output live over
+// server-sent events, and browse the runs before them.
+//
+// Runs are queued and run one at a time, so two runs never change the
+// same hosts at once. Each run is kept in a history directory as a JSON
+// record and a log, which outlive restarts of the server.
+//
+// The HTTP API (see also GRPC):
+//
+//	GET  /api/playbooks           playbooks in the playbook directory
+//	GET  /api/runs                runs, newest first
+//	POST /api/runs                start a run (body: Request)
+//	GET  /api/runs/{id}           a run
+//	GET  /api/runs/{id}/log       the output of a run, as text
+//	GET  /api/runs/{id}/events    the output of a run as server-sent events
+//	POST /api/runs/{id}/cancel    cancel a queued or running run
+//	POST /api/runs/{id}/confirm   answer the confirmation a run waits for
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/notify"
+	"github.com/eugenetaranov/bolt/internal/output"
+)
+
+//go:embed dashboard.html
+var dashboard []byte
+
+// DefaultMaxHistory is how many runs the history keeps by default.
+const DefaultMaxHistory = 100
+
+// Statuses of a run.
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusOK       = "ok"
+	StatusChanged  = "changed"
+	StatusFailed   = "failed"
+	StatusError    = "error"
+	StatusCanceled = "canceled"
+)
+
+// Request asks for a run of a playbook.
+type Request struct {
+	// Playbook is the path of the playbook, relative to the playbook
+	// directory.
+	Playbook string `json:"playbook"`
+
+	// DryRun shows what the run would change without changing it.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// Diff shows what changed tasks change.
+	Diff bool `json:"diff,omitempty"`
+
+	// Limit restricts the run to the hosts matching these patterns.
+	Limit []string `json:"limit,omitempty"`
+
+	// ExtraVars override the variables of the playbook.
+	ExtraVars map[string]any `json:"extra_vars,omitempty"`
+}
+
+// RunFunc runs the playbook at path as req asks, writing its output and
+// the results of its tasks to sink. An error means the run could not
+// start; failed tasks are reported in the summary.
+type RunFunc func(ctx context.Context, path string, req *Request, sink Sink) (*notify.Summary, error)
+
+// Sink receives the output of a run, as bolt run prints it, and the
+// results of its tasks.
+type Sink interface {
+	io.Writer
+
+	// Task adds the result of a task. It may be called concurrently.
+	Task(Task)
+
+	// Confirm asks the clients of the run whether a play or task may run,
+	// and waits for the answer or the end of ctx.
+	Confirm(ctx context.Context, c Confirmation) (bool, error)
+}
+
+// Task is the result of a task on a host.
+type Task struct {
+	Play     string        `json:"play,omitempty"`
+	Host     string        `json:"host,omitempty"`
+	Task     string        `json:"task"`
+	Module   string        `json:"module,omitempty"`
+	Status   string        `json:"status"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Confirmation asks whether a play or task may run on a host.
+type Confirmation struct {
+	Play string `json:"play,omitempty"`
+	Host string `json:"host,omitempty"`
+
+	// Task is the task about to run, or empty to confirm the play.
+	Task     string `json:"task,omitempty"`
+	Question string `json:"question"`
+}
+
+// Run is a run of a playbook requested from the server.
+type Run struct {
+	Request
+
+	// ID identifies the run. IDs sort in the order runs were requested.
+	ID string `json:"id"`
+
+	// Status is queued, running, or the outcome of the run: ok, changed,
+	// failed, error (the run could not start), or canceled.
+	Status string `json:"status"`
+
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+
+	// Summary is the outcome of a finished run.
+	Summary *notify.Summary `json:"summary,omitempty"`
+
+	// Error is why the run could not start.
+	Error string `json:"error,omitempty"`
+
+	// Confirmation is the question the run waits for an answer to.
+	Confirmation *Confirmation `json:"confirmation,omitempty"`
+
+	journal *journal
+	cancel  context.CancelFunc
+	answer  chan bool
+}
+
+// done reports whether the run has finished.
+func (r *Run) done() bool {
+	return r.Status != StatusQueued && r.Status != StatusRunning
+}
+
+// Server runs playbooks on request.
+type Server struct {
+	// Dir is the directory of the playbooks that may be run.
+	Dir string
+
+	// Run runs a playbook.
+	Run RunFunc
+
+	// Token, if set, must be sent with every API request, as a bearer
+	// token or in the token query parameter (for EventSource clients,
+	// which cannot set headers).
+	Token string
+
+	// HistoryDir is where runs are kept. Empty keeps them in memory only.
+	HistoryDir string
+
+	// MaxHistory is how many finished runs are kept (default:
+	// DefaultMaxHistory).
+	MaxHistory int
+
+	// Output logs the runs the server starts and finishes.
+	Output *output.Output
+
+	mu    sync.Mutex
+	runs  []*Run
+	queue chan *Run
+}
+
+// Start loads the history and runs queued runs until ctx is canceled.
+// It must be called before the server handles requests.
+func (s *Server) Start(ctx context.Context) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.queue = make(chan *Run, 64)
+	go s.work(ctx)
+	return nil
+}
+
+// Handler returns the HTTP handler of the API and the dashboard.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", serveDashboard)
+	mux.HandleFunc("GET /api/playbooks", s.auth(s.listPlaybooks))
+	mux.HandleFunc("GET /api/runs", s.auth(s.listRuns))
+	mux.HandleFunc("POST /api/runs", s.auth(s.createRun))
+	mux.HandleFunc("GET /api/runs/{id}", s.auth(s.getRun))
+	mux.HandleFunc("GET /api/runs/{id}/log", s.auth(s.getLog))
+	mux.HandleFunc("GET /api/runs/{id}/events", s.auth(s.streamEvents))
+	mux.HandleFunc("POST /api/runs/{id}/cancel", s.auth(s.cancelRun))
+	mux.HandleFunc("POST /api/runs/{id}/confirm", s.auth(s.confirmRun))
+	return mux
+}
+
+// auth rejects requests without the token.
+func (s *Server) auth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != "" {
+			token := r.URL.Query().Get("token")
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+				token = bearer
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "missing or invalid token")
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// serveDashboard serves the web dashboard. It holds no data; it asks the
+// API, with the token the user enters.
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(dashboard)
+}
+
+func (s *Server) listPlaybooks(w http.ResponseWriter, r *http.Request) {
+	playbooks, err := s.playbooks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"playbooks": playbooks})
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	runs := make([]Run, 0, len(s.runs))
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		runs = append(runs, *s.runs[i])
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+func (s *Server) createRun(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if _, err := s.playbookPath(req.Playbook); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run, err := s.submit(&req)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/api/runs/"+run.ID)
+	writeJSON(w, http.StatusAccepted, s.snapshot(run))
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.snapshot(run))
+}
+
+func (s *Server) getLog(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(run.journal.output())
+}
+
+// streamEvents sends the output of a run as it is written, as log events
+// of complete lines, the results of its tasks as task events, the
+// questions it waits for an answer to as confirm events, and an end event
+// with the run once it finished.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var c cursor
+	for {
+		entries, closed, wait := run.journal.read(&c)
+		for _, e := range entries {
+			if e.task != nil {
+				data, _ := json.Marshal(e.task)
+				fmt.Fprintf(w, "event: task\ndata: %s\n\n", data)
+				continue
+			}
+			if e.confirm != nil {
+				data, _ := json.Marshal(e.confirm)
+				fmt.Fprintf(w, "event: confirm\ndata: %s\n\n", data)
+				continue
+			}
+			fmt.Fprint(w, "event: log\n")
+			for _, line := range strings.Split(strings.TrimSuffix(e.output, "\n"), "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+		}
+		if len(entries) > 0 {
+			flusher.Flush()
+		}
+
+		if closed {
+			end, _ := json.Marshal(s.snapshot(run))
+			fmt.Fprintf(w, "event: end\ndata: %s\n\n", end)
+			flusher.Flush()
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wait:
+		}
+	}
+}
+
+func (s *Server) cancelRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	if err := s.cancel(run); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.snapshot(run))
+}
+
+func (s *Server) confirmRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Approve bool `json:"approve"`
+	}
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if err := s.answer(run, req.Approve); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.snapshot(run))
+}
+
+// find returns the run of the id in the path, or responds with not
+// found.
+func (s *Server) find(w http.ResponseWriter, r *http.Request) (*Run, bool) {
+	run := s.lookup(r.PathValue("id"))
+	if run == nil {
+		writeError(w, http.StatusNotFound, "run not found")
+		return nil, false
+	}
+	return run, true
+}
+
+// errFinished is returned when canceling a run that has finished.
+var errFinished = errors.New("run already finished")
+
+// errNotWaiting is returned when answering a run that waits for no
+// confirmation.
+var errNotWaiting = errors.New("run is not waiting for confirmation")
+
+// submit queues a run of req.
+func (s *Server) submit(req *Request) (*Run, error) {
+	run := &Run{Request: *req, ID: newID(), Status: StatusQueued, Created: time.Now(), journal: &journal{}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.queue <- run:
+	default:
+		return nil, errors.New("too many queued runs")
+	}
+	s.runs = append(s.runs, run)
+	s.save(run)
+	return run, nil
+}
+
+// cancel cancels a queued or running run.
+func (s *Server) cancel(run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch run.Status {
+	case StatusQueued:
+		// The worker skips it
+		s.finish(run, StatusCanceled)
+	case StatusRunning:
+		run.cancel()
+	default:
+		return errFinished
+	}
+	return nil
+}
+
+// answer answers the confirmation run waits for.
+func (s *Server) answer(run *Run, approve bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run.answer == nil {
+		return errNotWaiting
+	}
+	run.answer <- approve
+	run.Confirmation, run.answer = nil, nil
+	s.save(run)
+	return nil
+}
+
+// lookup returns the run with id, or nil.
+func (s *Server) lookup(id string) *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if run.ID == id {
+			return run
+		}
+	}
+	return nil
+}
+
+// snapshot returns a copy of run, safe to read while it goes on.
+func (s *Server) snapshot(run *Run) Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *run
+}
+
+// playbooks returns the playbooks in the playbook directory.
+func (s *Server) playbooks() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	playbooks := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && isYAML(e.Name()) && e.Name() != "bolt.yaml" {
+			playbooks = append(playbooks, e.Name())
+		}
+	}
+	return playbooks, nil
+}
+
+// playbookPath returns the path of a playbook named in a request. It must
+// be a YAML file inside the playbook directory.
+func (s *Server) playbookPath(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("playbook is required")
+	}
+	if !filepath.IsLocal(name) || !isYAML(name) {
+		return "", fmt.Errorf("invalid playbook %q: must be a YAML file in the playbook directory", name)
+	}
+	path := filepath.Join(s.Dir, name)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("playbook not found: %s", name)
+	}
+	return path, nil
+}
+
+// work runs queued runs one at a time until ctx is canceled.
+func (s *Server) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case run := <-s.queue:
+			s.execute(ctx, run)
+		}
+	}
+}
+
+// execute runs a queued run and records its outcome.
+func (s *Server) execute(ctx context.Context, run *Run) {
+	s.mu.Lock()
+	if run.Status != StatusQueued {
+		// Canceled while queued
+		s.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	now := time.Now()
+	run.Status, run.Started, run.cancel = StatusRunning, &now, cancel
+	s.save(run)
+	s.mu.Unlock()
+	s.logf("Run %s started: %s", run.ID, run.Playbook)
+
+	sum, err := s.runPlaybook(ctx, run)
+
+	s.mu.Lock()
+	status := StatusError
+	switch {
+	case ctx.Err() != nil:
+		status = StatusCanceled
+	case err != nil:
+		run.Error = err.Error()
+		fmt.Fprintf(run.journal, "ERROR %v\n", err)
+	default:
+		run.Summary = sum
+		status = sum.Status()
+	}
+	s.finish(run, status)
+	s.mu.Unlock()
+	s.logf("Run %s finished: %s", run.ID, status)
+}
+
+// runPlaybook runs the playbook of run, recovering from panics so one
+// broken run does not take down the server.
+func (s *Server) runPlaybook(ctx context.Context, run *Run) (sum *notify.Summary, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("run panicked: %v", p)
+		}
+	}()
+	path, err := s.playbookPath(run.Playbook)
+	if err != nil {
+		return nil, err
+	}
+	return s.Run(ctx, path, &run.Request, &runSink{journal: run.journal, s: s, run: run})
+}
+
+// runSink is the sink of a run: its journal, with confirmations answered
+// through the API.
+type runSink struct {
+	*journal
+	s   *Server
+	run *Run
+}
+
+// Confirm makes the run wait for an answer to c.
+func (r *runSink) Confirm(ctx context.Context, c Confirmation) (bool, error) {
+	target := c.Task
+	if target == "" {
+		target = "play " + c.Play
+	}
+	fmt.Fprintf(r.journal, "CONFIRM [%s] %s: %s\n", c.Host, target, c.Question)
+
+	answer := make(chan bool, 1)
+	r.s.mu.Lock()
+	r.run.Confirmation, r.run.answer = &c, answer
+	r.s.save(r.run)
+	r.s.mu.Unlock()
+	r.journal.confirm(c)
+	r.s.logf("Run %s waits for confirmation: %s", r.run.ID, c.Question)
+
+	select {
+	case ok := <-answer:
+		verdict := "declined"
+		if ok {
+			verdict = "approved"
+		}
+		fmt.Fprintf(r.journal, "CONFIRM %s\n", verdict)
+		return ok, nil
+	case <-ctx.Done():
+		r.s.mu.Lock()
+		r.run.Confirmation, r.run.answer = nil, nil
+		r.s.mu.Unlock()
+		return false, ctx.Err()
+	}
+}
+
+// finish records the outcome of run, saves it, and drops the oldest
+// finished runs beyond the history limit. s.mu must be held.
+func (s *Server) finish(run *Run, status string) {
+	now := time.Now()
+	run.Status, run.Finished, run.cancel = status, &now, nil
+	run.journal.close()
+	s.save(run)
+
+	limit := s.MaxHistory
+	if limit <= 0 {
+		limit = DefaultMaxHistory
+	}
+	finished := 0
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if !s.runs[i].done() {
+			continue
+		}
+		if finished++; finished > limit {
+			s.remove(s.runs[i])
+			s.runs = append(s.runs[:i], s.runs[i+1:]...)
+		}
+	}
+}
+
+// load reads the runs of the history directory. Runs that were queued or
+// running when the server stopped are marked as errors.
+func (s *Server) load() error {
+	if s.HistoryDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(s.HistoryDir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(s.HistoryDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("failed to read run: %w", err)
+		}
+		run := &Run{journal: &journal{}}
+		if err := json.Unmarshal(data, run); err != nil {
+			s.logf("Skipping invalid run %s: %v", f, err)
+			continue
+		}
+		if log, err := os.ReadFile(strings.TrimSuffix(f, ".json") + ".log"); err == nil {
+			_, _ = run.journal.Write(log)
+		}
+		if !run.done() {
+			run.Status, run.Error, run.Confirmation = StatusError, "the server stopped during the run", nil
+		}
+		run.journal.close()
+		s.runs = append(s.runs, run)
+	}
+	sort.Slice(s.runs, func(i, j int) bool { return s.runs[i].ID < s.runs[j].ID })
+	return nil
+}
+
+// save writes run and its log to the history directory. s.mu must be
+// held.
+func (s *Server) save(run *Run) {
+	if s.HistoryDir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(s.HistoryDir, run.ID+".json"), append(data, '\n'), 0600)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(s.HistoryDir, run.ID+".log"), run.journal.output(), 0600)
+	}
+	if err != nil {
+		s.logf("Failed to save run %s: %v", run.ID, err)
+	}
+}
+
+// remove deletes run from the history directory.
+func (s *Server) remove(run *Run) {
+	if s.HistoryDir == "" {
+		return
+	}
+	for _, ext := range []string{".json", ".log"} {
+		_ = os.Remove(filepath.Join(s.HistoryDir, run.ID+ext))
+	}
+}
+
+// logf logs to the server's output, if any.
+func (s *Server) logf(format string, args ...any) {
+	if s.Output != nil {
+		s.Output.Info(format, args...)
+	}
+}
+
+// newID returns a run ID that sorts by creation time.
+func newID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405.000") + "-" + hex.EncodeToString(b)
+}
+
+// isYAML reports whether name has a YAML extension.
+func isYAML(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// journal holds the output, the task results, and the confirmations of a
+// run, and wakes up readers waiting for more of them. Task results and
+// confirmations are kept in memory only.
+type journal struct {
+	mu     sync.Mutex
+	log    []byte
+	events []journalEvent
+	closed bool
+	wait   chan struct{}
+}
+
+// journalEvent is a task result or a confirmation, and the length of the
+// output when it was added.
+type journalEvent struct {
+	task    *Task
+	confirm *Confirmation
+	offset  int
+}
+
+// cursor is a position in a journal.
+type cursor struct {
+	log, events int
+}
+
+// entry is output, a task result, or a confirmation read from a journal.
+type entry struct {
+	output  string
+	task    *Task
+	confirm *Confirmation
+}
+
+// Write appends p to the output.
+func (j *journal) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.log = append(j.log, p...)
+	j.wake()
+	return len(p), nil
+}
+
+// Task adds a task result.
+func (j *journal) Task(t Task) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, journalEvent{task: &t, offset: len(j.log)})
+	j.wake()
+}
+
+// confirm adds a confirmation the run waits for.
+func (j *journal) confirm(c Confirmation) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, journalEvent{confirm: &c, offset: len(j.log)})
+	j.wake()
+}
+
+// close marks the journal complete.
+func (j *journal) close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.closed = true
+	j.wake()
+}
+
+// output returns the output so far.
+func (j *journal) output() []byte {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Clone(j.log)
+}
+
+// read returns the entries after c in the order they were added, and
+// advances c past them. Output is returned in complete lines until the
+// journal is complete. It also returns whether the journal is complete,
+// and a channel that is closed when there is more.
+func (j *journal) read(c *cursor) ([]entry, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.wait == nil {
+		j.wait = make(chan struct{})
+	}
+
+	var entries []entry
+	for ; c.events < len(j.events); c.events++ {
+		ev := j.events[c.events]
+		if ev.offset > c.log {
+			entries = append(entries, entry{output: string(j.log[c.log:ev.offset])})
+			c.log = ev.offset
+		}
+		entries = append(entries, entry{task: ev.task, confirm: ev.confirm})
+	}
+	rest := j.log[c.log:]
+	if !j.closed {
+		// Partial lines are returned once they are complete
+		rest = rest[:bytes.LastIndexByte(rest, '\n')+1]
+	}
+	if len(rest) > 0 {
+		entries = append(entries, entry{output: string(rest)})
+		c.log += len(rest)
+	}
+	return entries, j.closed, j.wait
+}
+
+// wake wakes up the readers waiting for more. j.mu must be held.
+func (j *journal) wake() {
+	if j.wait != nil {
+		close(j.wait)
+		j.wait = nil
+	}
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/notify"
+)
+
+// newServer starts a server over a directory with the playbook site.yaml.
+// Runs print the lines of the release channel, each followed by the result
+// of a task of that name, and succeed once it is closed. A line ending in
+// a question mark is asked for confirmation instead, and the answer
+// printed.
+func newServer(t *testing.T, historyDir string) (*Server, *httptest.Server, chan string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "site.yaml"), []byte("- hosts: all\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan string)
+	s := &Server{
+		Dir:        dir,
+		Token:      "secret",
+		HistoryDir: historyDir,
+		Run: func(ctx context.Context, path string, req *Request, sink Sink) (*notify.Summary, error) {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case line, ok := <-release:
+					if !ok {
+						return &notify.Summary{Playbook: path, Success: true, DryRun: req.DryRun, Changed: 1}, nil
+					}
+					if strings.HasSuffix(line, "?") {
+						approved, err := sink.Confirm(ctx, Confirmation{Host: "web1", Task: "drop", Question: line})
+						if err != nil {
+							return nil, err
+						}
+						fmt.Fprintln(sink, "approved:", approved)
+						continue
+					}
+					fmt.Fprintln(sink, line)
+					sink.Task(Task{Host: "web1", Task: line, Status: "changed"})
+				}
+			}
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts, release
+}
+
+// call sends an API request with the token and decodes the response into
+// out.
+func call(t *testing.T, ts *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// wait polls the run until it has finished.
+func wait(t *testing.T, ts *httptest.Server, id string) Run {
+	t.Helper()
+	for range 200 {
+		var run Run
+		call(t, ts, "GET", "/api/runs/"+id, "", &run)
+		if run.done() {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("run %s did not finish", id)
+	return Run{}
+}
+
+func TestRunAndStreamEvents(t *testing.T) {
+	_, ts, release := newServer(t, t.TempDir())
+
+	var run Run
+	if code := call(t, ts, "POST", "/api/runs", `{"playbook": "site.yaml", "dry_run": true}`, &run); code != http.StatusAccepted {
+		t.Fatalf("POST /api/runs = %d, want 202", code)
+	}
+	if run.ID == "" || run.Status != StatusQueued {
+		t.Fatalf("created run = %+v, want a queued run", run)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/runs/" + run.ID + "/events?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	go func() {
+		release <- "TASK [motd]"
+		release <- "changed: motd"
+		close(release)
+	}()
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			events = append(events, line)
+		}
+	}
+	got := strings.Join(events, "\n")
+	for _, want := range []string{"event: log", "data: TASK [motd]", "event: task", `data: {"host":"web1","task":"TASK [motd]","status":"changed","duration_ns":0}`, "data: changed: motd", "event: end", `"status":"changed"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("events missing %q:\n%s", want, got)
+		}
+	}
+
+	done := wait(t, ts, run.ID)
+	if done.Summary == nil || !done.Summary.DryRun || done.Started == nil || done.Finished == nil {
+		t.Errorf("finished run = %+v, want a dry run summary and times", done)
+	}
+
+	var list struct{ Runs []Run }
+	call(t, ts, "GET", "/api/runs", "", &list)
+	if len(list.Runs) != 1 || list.Runs[0].ID != run.ID {
+		t.Errorf("GET /api/runs = %+v, want the run", list.Runs)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	history := t.TempDir()
+	_, ts, release := newServer(t, history)
+
+	var run Run
+	call(t, ts, "POST", "/api/runs", `{"playbook": "site.yaml"}`, &run)
+	release <- "hello"
+	close(release)
+	wait(t, ts, run.ID)
+
+	// Another server over the same history
+	s, ts2, _ := newServer(t, history)
+	if len(s.runs) != 1 {
+		t.Fatalf("loaded %d runs, want 1", len(s.runs))
+	}
+	resp, err := http.Get(ts2.URL + "/api/runs/" + run.ID + "/log?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	log, _ := io.ReadAll(resp.Body)
+	if string(log) != "hello\n" {
+		t.Errorf("log = %q, want the output of the run", log)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	_, ts, _ := newServer(t, "")
+
+	var first, second Run
+	call(t, ts, "POST", "/api/runs", `{"playbook": "site.yaml"}`, &first)
+	call(t, ts, "POST", "/api/runs", `{"playbook": "site.yaml"}`, &second)
+
+	// The second waits for the first
+	if code := call(t, ts, "POST", "/api/runs/"+second.ID+"/cancel", "", nil); code != http.StatusAccepted {
+		t.Errorf("cancel queued run = %d, want 202", code)
+	}
+	for range 200 {
+		var run Run
+		if call(t, ts, "GET", "/api/runs/"+first.ID, "", &run); run.Status == StatusRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	call(t, ts, "POST", "/api/runs/"+first.ID+"/cancel", "", nil)
+
+	for _, id := range []string{first.ID, second.ID} {
+		if run := wait(t, ts, id); run.Status != StatusCanceled {
+			t.Errorf("run %s status = %s, want canceled", id, run.Status)
+		}
+	}
+	if code := call(t, ts, "POST", "/api/runs/"+first.ID+"/cancel", "", nil); code != http.StatusConflict {
+		t.Errorf("cancel finished run = %d, want 409", code)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	_, ts, release := newServer(t, "")
+
+	var run Run
+	call(t, ts, "POST", "/api/runs", `{"playbook": "site.yaml"}`, &run)
+	if code := call(t, ts, "POST", "/api/runs/"+run.ID+"/confirm", `{"approve": true}`, nil); code != http.StatusConflict {
+		t.Errorf("confirm before the question = %d, want 409", code)
+	}
+	release <- "Delete the data?"
+
+	for range 200 {
+		if call(t, ts, "GET", "/api/runs/"+run.ID, "", &run); run.Confirmation != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := run.Confirmation; c == nil || c.Question != "Delete the data?" || c.Host != "web1" {
+		t.Fatalf("run confirmation = %+v, want the question", c)
+	}
+	var answered Run
+	if code := call(t, ts, "POST", "/api/runs/"+run.ID+"/confirm", `{"approve": true}`, &answered); code != http.StatusOK || answered.Confirmation != nil {
+		t.Errorf("confirm = %d %+v, want the answered run", code, answered.Confirmation)
+	}
+	close(release)
+	wait(t, ts, run.ID)
+
+	resp, err := http.Get(ts.URL + "/api/runs/" + run.ID + "/log?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	log, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{"CONFIRM [web1] drop: Delete the data?", "CONFIRM approved", "approved: true"} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("log missing %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestRejects(t *testing.T) {
+	_, ts, _ := newServer(t, "")
+
+	resp, err := http.Get(ts.URL + "/api/runs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /api/runs without token = %d, want 401", resp.StatusCode)
+	}
+
+	for _, body := range []string{
+		`{"playbook": "../site.yaml"}`,
+		`{"playbook": "/etc/passwd"}`,
+		`{"playbook": "missing.yaml"}`,
+		`{"playbook": "site.yaml", "tags": ["x"]}`,
+	} {
+		if code := call(t, ts, "POST", "/api/runs", body, nil); code != http.StatusBadRequest {
+			t.Errorf("POST /api/runs %s = %d, want 400", body, code)
+		}
+	}
+	if code := call(t, ts, "GET", "/api/runs/nope", "", nil); code != http.StatusNotFound {
+		t.Errorf("GET unknown run = %d, want 404", code)
+	}
+
+	var list struct{ Playbooks []string }
+	call(t, ts, "GET", "/api/playbooks", "", &list)
+	if len(list.Playbooks) != 1 || list.Playbooks[0] != "site.yaml" {
+		t.Errorf("GET /api/playbooks = %v, want [site.yaml]", list.Playbooks)
+	}
+
+	// The dashboard itself needs no token
+	resp, err = http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET / = %d %s, want the dashboard", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
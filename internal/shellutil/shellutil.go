@@ -0,0 +1,36 @@
+// Package shellutil builds command lines for targets. Connectors run a
+// command line through a POSIX shell on the target, so any value that ends
+// up in one (paths, names, modes, user-supplied flags) must be quoted to
+// reach the command as a single argument instead of being interpreted by
+// the shell.
+//
+// Commands that need no shell features are better run from their
+// arguments with connector.ExecuteArgv, which skips the shell on
+// connectors that support it and quotes the arguments with Join on the
+// others.
+package shellutil
+
+import "strings"
+
+// Quote quotes s as a single shell word. Within single quotes nothing is
+// special, so only single quotes themselves need escaping: each one ends
+// the quoted string, is added in double quotes, and starts a new one.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// Join quotes each of args and joins them with spaces, so the shell passes
+// them to a command as exactly these arguments.
+func Join(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Command returns a command line that runs name with args. name is
+// quoted like the arguments, so it must be a command, not a shell snippet.
+func Command(name string, args ...string) string {
+	return Join(append([]string{name}, args...)...)
+}
@@ -0,0 +1,74 @@
+package shellutil
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// shellArgs runs cmdline as the arguments of a shell function and returns
+// the arguments the shell split it into.
+func shellArgs(t *testing.T, cmdline string) []string {
+	t.Helper()
+	script := "args() { for a; do printf '%s\\0' \"$a\"; done; }; args " + cmdline
+	out, err := exec.Command("/bin/sh", "-c", script).Output()
+	if err != nil {
+		t.Fatalf("sh -c %q: %v", script, err)
+	}
+	args := strings.Split(string(out), "\x00")
+	return args[:len(args)-1]
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "''"},
+		{"/etc/nginx.conf", "'/etc/nginx.conf'"},
+		{"it's", `'it'"'"'s'`},
+		{"$(reboot)", "'$(reboot)'"},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.in); got != tt.want {
+			t.Errorf("Quote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	got := Command("chown", "www-data:www-data", "/srv/a b")
+	if want := `'chown' 'www-data:www-data' '/srv/a b'`; got != want {
+		t.Errorf("Command() = %s, want %s", got, want)
+	}
+}
+
+func FuzzQuote(f *testing.F) {
+	for _, seed := range []string{"", "plain", "it's", "a b", "$HOME", "`id`", "$(id)", "; rm -rf /", "\n", "\\", `"'"`, "-rf", "*", "~root"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		// Arguments cannot hold NUL bytes
+		if strings.ContainsRune(s, 0) {
+			t.Skip()
+		}
+		if got := shellArgs(t, Quote(s)); !slices.Equal(got, []string{s}) {
+			t.Errorf("Quote(%q) reached the shell as %q", s, got)
+		}
+	})
+}
+
+func FuzzJoin(f *testing.F) {
+	f.Add("0644", "/etc/app's.conf")
+	f.Add("root; id", "$(id)")
+	f.Add("", "a\nb")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if strings.ContainsRune(a, 0) || strings.ContainsRune(b, 0) {
+			t.Skip()
+		}
+		if got := shellArgs(t, Join(a, b)); !slices.Equal(got, []string{a, b}) {
+			t.Errorf("Join(%q, %q) reached the shell as %q", a, b, got)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package state
+
+import (
+	"context"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Divergence is a recorded resource whose live state no longer matches.
+type Divergence struct {
+	Entry *Entry
+
+	// Live is the current fingerprint, empty if it could not be taken.
+	Live string
+
+	// Err is set if the resource could not be fingerprinted.
+	Err error
+}
+
+// Check fingerprints each recorded entry on the live target and returns the
+// ones that differ from what was last applied.
+func Check(ctx context.Context, conn connector.Connector, entries []*Entry) []Divergence {
+	var divergent []Divergence
+
+	for _, entry := range entries {
+		live, err := Fingerprint(ctx, conn, entry.Resource)
+		if err != nil {
+			divergent = append(divergent, Divergence{Entry: entry, Err: err})
+			continue
+		}
+		if live != entry.Fingerprint {
+			divergent = append(divergent, Divergence{Entry: entry, Live: live})
+		}
+	}
+
+	return divergent
+}
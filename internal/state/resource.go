@@ -0,0 +1,165 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+	"github.com/eugenetaranov/bolt/internal/shellutil"
+)
+
+// Kind identifies the type of a tracked resource.
+type Kind string
+
+const (
+	KindFile    Kind = "file"    // A path on the target
+	KindPackage Kind = "package" // A package managed by a package manager
+)
+
+// Resource identifies something a task manages on a host.
+type Resource struct {
+	Kind Kind   `json:"kind"`
+	ID   string `json:"id"`
+
+	// Manager is the package manager module for packages (apt, brew, dnf, pkgng).
+	Manager string `json:"manager,omitempty"`
+}
+
+// Key returns a unique key for the resource within a host.
+func (r Resource) Key() string {
+	if r.Manager != "" {
+		return fmt.Sprintf("%s:%s:%s", r.Kind, r.Manager, r.ID)
+	}
+	return fmt.Sprintf("%s:%s", r.Kind, r.ID)
+}
+
+// String returns a human-readable description of the resource.
+func (r Resource) String() string {
+	if r.Manager != "" {
+		return fmt.Sprintf("%s %s (%s)", r.Kind, r.ID, r.Manager)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.ID)
+}
+
+// pathParams maps file-managing modules to the parameter holding the path.
+var pathParams = map[string]string{
+	"copy":     "dest",
+	"template": "dest",
+	"file":     "path",
+}
+
+// packageModules are the package managers whose packages are tracked.
+var packageModules = map[string]bool{
+	"apt":   true,
+	"brew":  true,
+	"dnf":   true,
+	"pkgng": true,
+}
+
+// ResourcesFor returns the resources a task with the given module and
+// interpolated parameters manages. Modules that are not tracked yield none.
+func ResourcesFor(moduleName string, params map[string]any) []Resource {
+	if key, ok := pathParams[moduleName]; ok {
+		if path, ok := params[key].(string); ok && path != "" {
+			return []Resource{{Kind: KindFile, ID: path}}
+		}
+		return nil
+	}
+
+	if packageModules[moduleName] {
+		var resources []Resource
+		for _, name := range packageNames(params["name"]) {
+			resources = append(resources, Resource{Kind: KindPackage, ID: name, Manager: moduleName})
+		}
+		return resources
+	}
+
+	return nil
+}
+
+// packageNames extracts package names from a name parameter.
+func packageNames(v any) []string {
+	switch val := v.(type) {
+	case string:
+		if val != "" {
+			return []string{val}
+		}
+	case []any:
+		var names []string
+		for _, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				names = append(names, s)
+			}
+		}
+		return names
+	case []string:
+		return val
+	}
+	return nil
+}
+
+// Fingerprint describes the current state of a resource on the target.
+// Two fingerprints of the same resource are equal if nothing changed.
+func Fingerprint(ctx context.Context, conn connector.Connector, r Resource) (string, error) {
+	var cmd string
+	switch r.Kind {
+	case KindFile:
+		cmd = fileFingerprintCmd(r.ID)
+	case KindPackage:
+		var err error
+		if cmd, err = packageFingerprintCmd(r); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unknown resource kind: %s", r.Kind)
+	}
+
+	result, err := conn.Execute(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint %s: %w", r, err)
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("failed to fingerprint %s: %s", r, strings.TrimSpace(result.Stderr))
+	}
+
+	fp := strings.TrimSpace(result.Stdout)
+	if fp == "" {
+		return "absent", nil
+	}
+	return fp, nil
+}
+
+// fileFingerprintCmd prints the type, content checksum, mode, and ownership
+// of a path, or "absent".
+func fileFingerprintCmd(path string) string {
+	return fmt.Sprintf(`p=%s
+attrs() { stat -c '%%a %%U:%%G' "$p" 2>/dev/null || stat -f '%%Lp %%Su:%%Sg' "$p"; }
+if [ -L "$p" ]; then
+	echo "link $(readlink "$p")"
+elif [ -d "$p" ]; then
+	echo "directory $(attrs)"
+elif [ -e "$p" ]; then
+	sum=$(sha256sum "$p" 2>/dev/null || shasum -a 256 "$p")
+	echo "file ${sum%%%% *} $(attrs)"
+else
+	echo absent
+fi`, shellutil.Quote(path))
+}
+
+// packageFingerprintCmd prints "installed <version>" for an installed
+// package, and nothing otherwise.
+func packageFingerprintCmd(r Resource) (string, error) {
+	name := shellutil.Quote(r.ID)
+	switch r.Manager {
+	case "apt":
+		return fmt.Sprintf(`dpkg-query -W -f='${Status} ${Version}' %s 2>/dev/null | sed -n 's/^install ok installed /installed /p'`, name), nil
+	case "brew":
+		return fmt.Sprintf(`v=$(brew list --versions %s 2>/dev/null) && echo "installed ${v#* }" || true`, name), nil
+	case "dnf":
+		return fmt.Sprintf(`v=$(rpm -q --qf '%%{VERSION}-%%{RELEASE}' %s 2>/dev/null) && echo "installed $v" || true`, name), nil
+	case "pkgng":
+		return fmt.Sprintf(`v=$(pkg query '%%v' %s 2>/dev/null) && echo "installed $v" || true`, name), nil
+	}
+	return "", fmt.Errorf("unsupported package manager: %s", r.Manager)
+}
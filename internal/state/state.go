@@ -0,0 +1,164 @@
+// Package state records what bolt applied to each host so later runs can
+// detect drift.
+//
+// After every successful task the executor fingerprints the resources the
+// task manages (files and packages) and stores them per host. `bolt drift`
+// re-fingerprints those resources on the live hosts and reports any that
+// no longer match.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Version is the state file format version.
+const Version = 1
+
+// DefaultPath returns the state file used for a playbook: .bolt/state.json
+// next to the playbook.
+func DefaultPath(playbookPath string) string {
+	return filepath.Join(filepath.Dir(playbookPath), ".bolt", "state.json")
+}
+
+// Entry is the last applied fingerprint of a resource.
+type Entry struct {
+	Resource
+
+	// Fingerprint describes the resource as it was after the task ran.
+	Fingerprint string `json:"fingerprint"`
+
+	// Task is the name of the task that last managed the resource.
+	Task string `json:"task"`
+
+	// AppliedAt is when the task ran.
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// Host holds the recorded resources of a single host.
+type Host struct {
+	Resources map[string]*Entry `json:"resources"`
+}
+
+// State is the recorded state of all hosts. It is safe for concurrent use.
+type State struct {
+	mu    sync.Mutex
+	path  string
+	hosts map[string]*Host
+}
+
+// file is the on-disk representation of State.
+type file struct {
+	Version int              `json:"version"`
+	Hosts   map[string]*Host `json:"hosts"`
+}
+
+// Load reads the state file at path. A missing file yields an empty state.
+func Load(path string) (*State, error) {
+	s := &State{path: path, hosts: make(map[string]*Host)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("unsupported state file version %d in %s", f.Version, path)
+	}
+	for name, host := range f.Hosts {
+		if host.Resources == nil {
+			host.Resources = make(map[string]*Entry)
+		}
+		s.hosts[name] = host
+	}
+
+	return s, nil
+}
+
+// Path returns the file the state is saved to.
+func (s *State) Path() string {
+	return s.path
+}
+
+// Record stores the fingerprint of a resource on host.
+func (s *State) Record(host string, entry *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &Host{Resources: make(map[string]*Entry)}
+		s.hosts[host] = h
+	}
+	h.Resources[entry.Key()] = entry
+}
+
+// Entries returns the recorded resources of host, sorted by key.
+func (s *State) Entries(host string) []*Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hosts[host]
+	if !ok {
+		return nil
+	}
+
+	entries := make([]*Entry, 0, len(h.Resources))
+	for _, e := range h.Resources {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key() < entries[j].Key()
+	})
+	return entries
+}
+
+// Hosts returns the names of all recorded hosts, sorted.
+func (s *State) Hosts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.hosts))
+	for name := range s.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the state file, creating its directory if needed.
+func (s *State) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(file{Version: Version, Hosts: s.hosts}, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write to a temp file first so an interrupted run never leaves a
+	// truncated state file behind
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
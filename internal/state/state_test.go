@@ -0,0 +1,131 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".bolt", "state.json")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("loading missing file: %v", err)
+	}
+	if len(s.Hosts()) != 0 {
+		t.Fatalf("expected empty state, got hosts %v", s.Hosts())
+	}
+
+	applied := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.Record("web1", &Entry{
+		Resource:    Resource{Kind: KindFile, ID: "/etc/motd"},
+		Fingerprint: "file abc 644 root:root",
+		Task:        "Write motd",
+		AppliedAt:   applied,
+	})
+	s.Record("web1", &Entry{
+		Resource:    Resource{Kind: KindPackage, ID: "nginx", Manager: "apt"},
+		Fingerprint: "installed 1.24.0",
+	})
+	// Recording the same resource again replaces it
+	s.Record("web1", &Entry{
+		Resource:    Resource{Kind: KindFile, ID: "/etc/motd"},
+		Fingerprint: "file def 644 root:root",
+		Task:        "Write motd",
+		AppliedAt:   applied,
+	})
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Hosts(), []string{"web1"}) {
+		t.Errorf("hosts = %v, want [web1]", loaded.Hosts())
+	}
+
+	entries := loaded.Entries("web1")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Key() != "file:/etc/motd" || entries[0].Fingerprint != "file def 644 root:root" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if !entries[0].AppliedAt.Equal(applied) {
+		t.Errorf("applied_at = %v, want %v", entries[0].AppliedAt, applied)
+	}
+	if entries[1].Key() != "package:apt:nginx" {
+		t.Errorf("unexpected second entry key: %s", entries[1].Key())
+	}
+}
+
+func TestResourcesFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		module string
+		params map[string]any
+		want   []Resource
+	}{
+		{"copy", "copy", map[string]any{"dest": "/etc/motd"}, []Resource{{Kind: KindFile, ID: "/etc/motd"}}},
+		{"template", "template", map[string]any{"src": "a.j2", "dest": "/etc/a"}, []Resource{{Kind: KindFile, ID: "/etc/a"}}},
+		{"file", "file", map[string]any{"path": "/srv", "state": "directory"}, []Resource{{Kind: KindFile, ID: "/srv"}}},
+		{"apt list", "apt", map[string]any{"name": []any{"git", "curl"}}, []Resource{
+			{Kind: KindPackage, ID: "git", Manager: "apt"},
+			{Kind: KindPackage, ID: "curl", Manager: "apt"},
+		}},
+		{"brew single", "brew", map[string]any{"name": "jq"}, []Resource{{Kind: KindPackage, ID: "jq", Manager: "brew"}}},
+		{"dnf single", "dnf", map[string]any{"name": "nginx"}, []Resource{{Kind: KindPackage, ID: "nginx", Manager: "dnf"}}},
+		{"apt without name", "apt", map[string]any{"update_cache": true}, nil},
+		{"untracked", "command", map[string]any{"cmd": "true"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResourcesFor(tt.module, tt.params)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResourcesFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	conn := connectortest.New()
+	conn.OnMatch(`^p='/etc/motd'`).Return("file abc 644 root:root\n")
+	conn.OnMatch(`^p='/etc/issue'`).Return("absent\n")
+	conn.OnMatch(`dpkg-query .* 'nginx'`).Return("installed 1.24.0\n")
+	conn.OnMatch(`dpkg-query .* 'curl'`).Return("")
+
+	entries := []*Entry{
+		{Resource: Resource{Kind: KindFile, ID: "/etc/motd"}, Fingerprint: "file abc 644 root:root"},
+		{Resource: Resource{Kind: KindFile, ID: "/etc/issue"}, Fingerprint: "file def 644 root:root"},
+		{Resource: Resource{Kind: KindPackage, ID: "nginx", Manager: "apt"}, Fingerprint: "installed 1.24.0"},
+		{Resource: Resource{Kind: KindPackage, ID: "curl", Manager: "apt"}, Fingerprint: "installed 8.5.0"},
+	}
+
+	divergent := Check(context.Background(), conn, entries)
+	if len(divergent) != 2 {
+		t.Fatalf("expected 2 divergences, got %d: %+v", len(divergent), divergent)
+	}
+
+	var got []string
+	for _, d := range divergent {
+		if d.Err != nil {
+			t.Errorf("unexpected error for %s: %v", d.Entry.Resource, d.Err)
+		}
+		got = append(got, d.Entry.Key()+"="+d.Live)
+	}
+	want := "file:/etc/issue=absent package:apt:curl=absent"
+	if strings.Join(got, " ") != want {
+		t.Errorf("divergences = %v, want %s", got, want)
+	}
+}
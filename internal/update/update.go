@@ -0,0 +1,284 @@
+// Package update checks GitHub releases for newer versions of bolt and
+// replaces the running binary with a verified download.
+//
+// Releases are built by GoReleaser: every release has one
+// bolt_<version>_<os>_<arch>.tar.gz archive per platform and a
+// checksums.txt with the SHA256 checksum of each archive.
+package update
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Repo is the GitHub repository bolt is released from.
+const Repo = "eugenetaranov/bolt"
+
+// checksumsAsset is the name of the checksums file of a release.
+const checksumsAsset = "checksums.txt"
+
+// maxDownload caps the size of downloaded assets.
+const maxDownload = 200 << 20
+
+// Release is a GitHub release.
+type Release struct {
+	// Tag is the release tag (e.g., v1.4.0).
+	Tag string `json:"tag_name"`
+
+	// URL is the release page.
+	URL string `json:"html_url"`
+
+	// Assets are the files attached to the release.
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Version returns the release version without the leading v.
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+// asset returns the named asset.
+func (r *Release) asset(name string) (*Asset, bool) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// Client talks to the GitHub API.
+type Client struct {
+	// HTTP is the client used for all requests.
+	HTTP *http.Client
+
+	// BaseURL is the GitHub API URL (default: https://api.github.com).
+	BaseURL string
+
+	// Repo is the repository releases are read from (default: Repo).
+	Repo string
+}
+
+// NewClient creates a client for the GitHub API.
+func NewClient() *Client {
+	return &Client{
+		HTTP:    &http.Client{Timeout: 60 * time.Second},
+		BaseURL: "https://api.github.com",
+		Repo:    Repo,
+	}
+}
+
+// Latest returns the newest release that is not a draft or prerelease.
+func (c *Client) Latest(ctx context.Context) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", c.BaseURL, c.Repo)
+	body, err := c.get(ctx, url, "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+
+	var rel Release
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	if rel.Tag == "" {
+		return nil, fmt.Errorf("failed to parse release: no tag")
+	}
+	return &rel, nil
+}
+
+// Download fetches the release's binary for goos and goarch, verifies the
+// archive against the release's checksums, and returns the binary.
+func (c *Client) Download(ctx context.Context, rel *Release, goos, goarch string) ([]byte, error) {
+	name := fmt.Sprintf("bolt_%s_%s_%s.tar.gz", rel.Version(), goos, goarch)
+	archive, ok := rel.asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s", rel.Tag, goos, goarch)
+	}
+	sums, ok := rel.asset(checksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s; refusing to install an unverified binary", rel.Tag, checksumsAsset)
+	}
+
+	sumsData, err := c.get(ctx, sums.URL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", checksumsAsset, err)
+	}
+	want, err := findChecksum(sumsData, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, archive.URL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, got)
+	}
+
+	return extractBinary(data)
+}
+
+// get fetches url and returns the response body.
+func (c *Client) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && strings.HasPrefix(url, c.BaseURL) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxDownload {
+		return nil, fmt.Errorf("GET %s: response larger than %d bytes", url, maxDownload)
+	}
+	return body, nil
+}
+
+// findChecksum returns the checksum of name from a checksums file in
+// sha256sum format.
+func findChecksum(data []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s does not list %s", checksumsAsset, name)
+}
+
+// extractBinary returns the bolt binary from a release archive.
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("archive does not contain the bolt binary")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == "bolt" {
+			return io.ReadAll(io.LimitReader(tr, maxDownload))
+		}
+	}
+}
+
+// Newer reports whether version latest is newer than current. Versions
+// are compared as major.minor.patch; a current version that is not a
+// release (e.g., dev) is never older.
+func Newer(current, latest string) bool {
+	cur, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	lat, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	for i := range cur {
+		if lat[i] != cur[i] {
+			return lat[i] > cur[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses a [v]major.minor.patch version, ignoring any
+// pre-release or build suffix.
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// Replace atomically replaces the executable at path with binary. The
+// new binary is written next to it and renamed over it, so a failed
+// update never leaves a partial binary behind.
+func Replace(path string, binary []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".bolt-update-*")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("no permission to write to %s; run with sudo", filepath.Dir(path))
+		}
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm() | 0111); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,177 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+	}{
+		{"1.2.3", "v1.2.4", true},
+		{"v1.2.3", "1.10.0", true},
+		{"1.2.3", "2.0.0", true},
+		{"1.2.3", "1.2.3", false},
+		{"1.3.0", "1.2.9", false},
+		{"1.2.3-next", "1.2.3", false},
+		{"dev", "9.9.9", false},
+		{"1.2.3", "garbage", false},
+	}
+
+	for _, tt := range tests {
+		if got := Newer(tt.current, tt.latest); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+// archive returns a release archive containing a bolt binary with the
+// given content.
+func archive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range map[string]string{"README.md": "readme", "bolt": content} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// releaseServer serves a release v1.5.0 with one linux/amd64 archive and
+// the given checksums file.
+func releaseServer(t *testing.T, data []byte, checksums func(sum string) string) *Client {
+	t.Helper()
+	sum := sha256.Sum256(data)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/repos/eugenetaranov/bolt/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name": "v1.5.0", "html_url": "https://example.com/v1.5.0", "assets": [
+			{"name": "bolt_1.5.0_linux_amd64.tar.gz", "browser_download_url": "%[1]s/dl/archive"},
+			{"name": "checksums.txt", "browser_download_url": "%[1]s/dl/checksums"}
+		]}`, srv.URL)
+	})
+	mux.HandleFunc("/dl/archive", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	})
+	mux.HandleFunc("/dl/checksums", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, checksums(hex.EncodeToString(sum[:])))
+	})
+
+	c := NewClient()
+	c.BaseURL = srv.URL
+	return c
+}
+
+func TestDownload(t *testing.T) {
+	ctx := context.Background()
+	c := releaseServer(t, archive(t, "new binary"), func(sum string) string {
+		return fmt.Sprintf("0000  bolt_1.5.0_darwin_arm64.tar.gz\n%s  bolt_1.5.0_linux_amd64.tar.gz\n", sum)
+	})
+
+	rel, err := c.Latest(ctx)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if rel.Version() != "1.5.0" {
+		t.Errorf("Version() = %q, want 1.5.0", rel.Version())
+	}
+
+	binary, err := c.Download(ctx, rel, "linux", "amd64")
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if string(binary) != "new binary" {
+		t.Errorf("Download() = %q, want the binary from the archive", binary)
+	}
+
+	if _, err := c.Download(ctx, rel, "windows", "amd64"); err == nil || !strings.Contains(err.Error(), "no build") {
+		t.Errorf("Download() for a missing platform error = %v", err)
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	c := releaseServer(t, archive(t, "tampered"), func(sum string) string {
+		return strings.Repeat("ab", 32) + "  bolt_1.5.0_linux_amd64.tar.gz\n"
+	})
+
+	rel, err := c.Latest(ctx)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	_, err = c.Download(ctx, rel, "linux", "amd64")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Download() error = %v, want checksum mismatch", err)
+	}
+}
+
+func TestDownloadUnlisted(t *testing.T) {
+	ctx := context.Background()
+	c := releaseServer(t, archive(t, "new binary"), func(sum string) string {
+		return sum + "  bolt_1.5.0_darwin_arm64.tar.gz\n"
+	})
+
+	rel, err := c.Latest(ctx)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if _, err := c.Download(ctx, rel, "linux", "amd64"); err == nil {
+		t.Error("Download() of an archive missing from checksums.txt: want error")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bolt")
+	if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(path, []byte("new")); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("binary = %q, want new", data)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("mode = %v, want executable", info.Mode())
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("left %d files behind, want only the binary", len(entries))
+	}
+}
@@ -25,6 +25,26 @@ func Gather(ctx context.Context, conn connector.Connector) (map[string]any, erro
 		}
 	}
 
+	// Detect containers and virtual machines
+	osType, _ := facts["os_type"].(string)
+	if virt, err := gatherVirtualization(ctx, conn, osType); err == nil {
+		for k, v := range virt {
+			facts[k] = v
+		}
+	}
+	if facts["virtualization_type"] == "wsl" {
+		if wsl, err := gatherWSL(ctx, conn); err == nil {
+			for k, v := range wsl {
+				facts[k] = v
+			}
+		}
+	}
+
+	// Detect the init system, which is missing in most containers
+	if mgr, err := ServiceManager(ctx, conn); err == nil {
+		facts["service_mgr"] = mgr
+	}
+
 	// Gather hostname
 	if hostname, err := gatherHostname(ctx, conn); err == nil {
 		facts["hostname"] = hostname
@@ -111,6 +131,25 @@ func gatherOSInfo(ctx context.Context, conn connector.Connector) (map[string]any
 				info["os_family"] = "Suse"
 			}
 		}
+
+	case "FreeBSD":
+		info["os_family"] = "FreeBSD"
+		info["pkg_manager"] = "pkgng"
+		info["os_name"] = "FreeBSD"
+
+		// freebsd-version reports the userland version, which may differ from the kernel
+		if result, err := conn.Execute(ctx, "freebsd-version -u 2>/dev/null || uname -r"); err == nil && result.ExitCode == 0 {
+			info["os_version"] = strings.TrimSpace(result.Stdout)
+		}
+
+	case "OpenBSD":
+		info["os_family"] = "OpenBSD"
+		info["pkg_manager"] = "pkg_add"
+		info["os_name"] = "OpenBSD"
+
+		if result, err := conn.Execute(ctx, "uname -r"); err == nil {
+			info["os_version"] = strings.TrimSpace(result.Stdout)
+		}
 	}
 
 	// Get architecture
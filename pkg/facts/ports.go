@@ -0,0 +1,206 @@
+package facts
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Port is a socket listening on the target.
+type Port struct {
+	// Protocol is "tcp" or "udp".
+	Protocol string
+
+	// Address is the local address, "*" for all addresses.
+	Address string
+
+	// Port is the local port.
+	Port int
+
+	// PID and Process identify the process holding the socket. They are
+	// empty if the listing tool may not see it, e.g., a process of another
+	// user without root.
+	PID     int
+	Process string
+}
+
+// String describes the process holding the port, e.g. "nginx (pid 812)".
+func (p Port) String() string {
+	if p.Process == "" {
+		return "an unknown process"
+	}
+	return fmt.Sprintf("%s (pid %d)", p.Process, p.PID)
+}
+
+// ListeningPorts lists the TCP sockets listening and the UDP sockets bound
+// on the target, using ss on Linux, sockstat on FreeBSD, and lsof
+// elsewhere.
+func ListeningPorts(ctx context.Context, conn connector.Connector) ([]Port, error) {
+	tools := []struct {
+		name  string
+		cmd   string
+		parse func(string) []Port
+	}{
+		{"ss", "ss -tulnp", parseSS},
+		{"sockstat", "sockstat -46l", parseSockstat},
+		{"lsof", "lsof -nP -iTCP -sTCP:LISTEN -iUDP", parseLsof},
+	}
+
+	for _, tool := range tools {
+		result, err := conn.Execute(ctx, "command -v "+tool.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ports: %w", err)
+		}
+		if result.ExitCode != 0 {
+			continue
+		}
+
+		result, err = conn.Execute(ctx, tool.cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ports: %w", err)
+		}
+		// lsof exits with 1 when nothing matches
+		if result.ExitCode != 0 && strings.TrimSpace(result.Stderr) != "" {
+			return nil, fmt.Errorf("%s failed: %s", tool.cmd, strings.TrimSpace(result.Stderr))
+		}
+		return tool.parse(result.Stdout), nil
+	}
+
+	return nil, fmt.Errorf("failed to list ports: none of ss, sockstat, or lsof is installed")
+}
+
+// parseSS parses the output of ss -tulnp:
+//
+//	Netid State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+//	tcp   LISTEN 0      4096   0.0.0.0:22         0.0.0.0:*         users:(("sshd",pid=812,fd=3))
+func parseSS(out string) []Port {
+	var ports []Port
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || (fields[0] != "tcp" && fields[0] != "udp") {
+			continue
+		}
+		p, ok := splitAddress(fields[4])
+		if !ok {
+			continue
+		}
+		p.Protocol = fields[0]
+		if len(fields) > 6 {
+			p.Process, p.PID = parseSSUsers(strings.Join(fields[6:], " "))
+		}
+		ports = append(ports, p)
+	}
+	return ports
+}
+
+// parseSSUsers returns the first process of an ss users column,
+// users:(("nginx",pid=812,fd=6),("nginx",pid=813,fd=6)).
+func parseSSUsers(users string) (string, int) {
+	_, rest, ok := strings.Cut(users, `(("`)
+	if !ok {
+		return "", 0
+	}
+	name, rest, ok := strings.Cut(rest, `"`)
+	if !ok {
+		return "", 0
+	}
+	_, rest, ok = strings.Cut(rest, "pid=")
+	if !ok {
+		return name, 0
+	}
+	end := strings.IndexAny(rest, ",)")
+	if end < 0 {
+		return name, 0
+	}
+	pid, _ := strconv.Atoi(rest[:end])
+	return name, pid
+}
+
+// parseSockstat parses the output of sockstat -46l:
+//
+//	USER     COMMAND    PID   FD  PROTO  LOCAL ADDRESS         FOREIGN ADDRESS
+//	root     sshd       812   4   tcp4   *:22                  *:*
+func parseSockstat(out string) []Port {
+	var ports []Port
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		proto := strings.TrimRight(fields[4], "46")
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		p, ok := splitAddress(fields[5])
+		if !ok {
+			continue
+		}
+		p.Protocol = proto
+		if fields[1] != "?" {
+			p.Process = fields[1]
+			p.PID, _ = strconv.Atoi(fields[2])
+		}
+		ports = append(ports, p)
+	}
+	return ports
+}
+
+// parseLsof parses the output of lsof -nP -iTCP -sTCP:LISTEN -iUDP:
+//
+//	COMMAND   PID USER  FD  TYPE DEVICE             SIZE/OFF NODE NAME
+//	nginx     812 root  6u  IPv4 0x1234567890abcdef 0t0      TCP  *:443 (LISTEN)
+//	mDNSRespo 301 _mdns 7u  IPv6 0x1234567890abcdef 0t0      UDP  *:5353
+func parseLsof(out string) []Port {
+	var ports []Port
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[0] == "COMMAND" {
+			continue
+		}
+		name, node := fields[len(fields)-1], fields[len(fields)-2]
+		if name == "(LISTEN)" {
+			name, node = fields[len(fields)-2], fields[len(fields)-3]
+		}
+		// Connected UDP sockets have a peer
+		if strings.Contains(name, "->") {
+			continue
+		}
+		p, ok := splitAddress(name)
+		if !ok {
+			continue
+		}
+		p.Protocol = strings.ToLower(node)
+		if p.Protocol != "tcp" && p.Protocol != "udp" {
+			continue
+		}
+		p.Process = strings.ReplaceAll(fields[0], `\x20`, " ")
+		p.PID, _ = strconv.Atoi(fields[1])
+		ports = append(ports, p)
+	}
+	return ports
+}
+
+// splitAddress splits a local address such as 0.0.0.0:22, [::]:22, *:22,
+// or 127.0.0.53%lo:53 into address and port.
+func splitAddress(s string) (Port, bool) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Port{}, false
+	}
+	port, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return Port{}, false
+	}
+	addr := strings.Trim(s[:i], "[]")
+	if j := strings.Index(addr, "%"); j >= 0 {
+		addr = addr[:j]
+	}
+	switch addr {
+	case "0.0.0.0", "::", "":
+		addr = "*"
+	}
+	return Port{Address: addr, Port: port}, true
+}
@@ -0,0 +1,67 @@
+package facts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) []Port
+		out   string
+		want  []Port
+	}{
+		{
+			name:  "ss",
+			parse: parseSS,
+			out: `Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:PortProcess
+tcp   LISTEN 0      4096         0.0.0.0:22         0.0.0.0:*    users:(("sshd",pid=812,fd=3))
+tcp   LISTEN 0      511             [::]:443           [::]:*    users:(("nginx",pid=901,fd=7),("nginx",pid=900,fd=7))
+udp   UNCONN 0      0      127.0.0.53%lo:53         0.0.0.0:*
+`,
+			want: []Port{
+				{Protocol: "tcp", Address: "*", Port: 22, PID: 812, Process: "sshd"},
+				{Protocol: "tcp", Address: "*", Port: 443, PID: 901, Process: "nginx"},
+				{Protocol: "udp", Address: "127.0.0.53", Port: 53},
+			},
+		},
+		{
+			name:  "sockstat",
+			parse: parseSockstat,
+			out: `USER     COMMAND    PID   FD  PROTO  LOCAL ADDRESS         FOREIGN ADDRESS
+root     sshd       812   4   tcp4   *:22                  *:*
+www      nginx      900   6   tcp6   *:443                 *:*
+?        ?          ?     ?   udp4   127.0.0.1:514         *:*
+`,
+			want: []Port{
+				{Protocol: "tcp", Address: "*", Port: 22, PID: 812, Process: "sshd"},
+				{Protocol: "tcp", Address: "*", Port: 443, PID: 900, Process: "nginx"},
+				{Protocol: "udp", Address: "127.0.0.1", Port: 514},
+			},
+		},
+		{
+			name:  "lsof",
+			parse: parseLsof,
+			out: `COMMAND     PID   USER   FD   TYPE             DEVICE SIZE/OFF NODE NAME
+nginx       812   root    6u  IPv4 0x1234567890abcdef      0t0  TCP *:443 (LISTEN)
+postgres    455   user    7u  IPv6 0x1234567890abcdef      0t0  TCP [::1]:5432 (LISTEN)
+mDNSRespo   301  _mdns    7u  IPv4 0x1234567890abcdef      0t0  UDP *:5353
+ntpd        120   root    9u  IPv4 0x1234567890abcdef      0t0  UDP 10.0.0.2:123->10.0.0.1:123
+`,
+			want: []Port{
+				{Protocol: "tcp", Address: "*", Port: 443, PID: 812, Process: "nginx"},
+				{Protocol: "tcp", Address: "::1", Port: 5432, PID: 455, Process: "postgres"},
+				{Protocol: "udp", Address: "*", Port: 5353, PID: 301, Process: "mDNSRespo"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.parse(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
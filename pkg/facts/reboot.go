@@ -0,0 +1,160 @@
+package facts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// RestartStatus tells whether the target must be rebooted, or services
+// restarted, for updated packages to take effect.
+type RestartStatus struct {
+	// RebootRequired is set if the kernel or another component that
+	// cannot be restarted was updated.
+	RebootRequired bool
+
+	// RebootPackages lists the packages requiring the reboot, if known.
+	RebootPackages []string
+
+	// Services lists the services still running old versions of updated
+	// files.
+	Services []string
+}
+
+// Facts returns the status as facts: reboot_required,
+// reboot_required_pkgs, and restart_services.
+func (s *RestartStatus) Facts() map[string]any {
+	pkgs := make([]any, len(s.RebootPackages))
+	for i, p := range s.RebootPackages {
+		pkgs[i] = p
+	}
+	services := make([]any, len(s.Services))
+	for i, svc := range s.Services {
+		services[i] = svc
+	}
+	return map[string]any{
+		"reboot_required":      s.RebootRequired,
+		"reboot_required_pkgs": pkgs,
+		"restart_services":     services,
+	}
+}
+
+// Restarts checks whether the target needs a reboot or service restarts
+// after a package update. The reboot is detected from
+// /var/run/reboot-required (Debian and Ubuntu) or needs-restarting -r (RHEL
+// and Fedora). Services are listed with needrestart, checkrestart, or
+// needs-restarting -s, if one of them is installed.
+func Restarts(ctx context.Context, conn connector.Connector) (*RestartStatus, error) {
+	status := &RestartStatus{}
+
+	result, err := conn.Execute(ctx, `if [ -f /var/run/reboot-required ]; then
+	echo reboot
+	cat /var/run/reboot-required.pkgs 2>/dev/null
+elif command -v needs-restarting >/dev/null 2>&1; then
+	needs-restarting -r >/dev/null 2>&1 || [ $? -ne 1 ] || echo reboot
+fi`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for a required reboot: %w", err)
+	}
+	lines := strings.Fields(result.Stdout)
+	if len(lines) > 0 && lines[0] == "reboot" {
+		status.RebootRequired = true
+		for _, pkg := range lines[1:] {
+			if !slices.Contains(status.RebootPackages, pkg) {
+				status.RebootPackages = append(status.RebootPackages, pkg)
+			}
+		}
+	}
+
+	tools := []struct {
+		name  string
+		cmd   string
+		parse func(string) []string
+	}{
+		{"needrestart", "needrestart -b", parseNeedrestart},
+		{"checkrestart", "checkrestart", parseCheckrestart},
+		{"needs-restarting", "needs-restarting -s", parseNeedsRestarting},
+	}
+	for _, tool := range tools {
+		result, err := conn.Execute(ctx, "command -v "+tool.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services to restart: %w", err)
+		}
+		if result.ExitCode != 0 {
+			continue
+		}
+		result, err = conn.Execute(ctx, tool.cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services to restart: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return nil, fmt.Errorf("%s failed: %s", tool.cmd, strings.TrimSpace(result.Stderr))
+		}
+		status.Services = tool.parse(result.Stdout)
+		break
+	}
+
+	return status, nil
+}
+
+// parseNeedrestart parses the batch output of needrestart -b:
+//
+//	NEEDRESTART-VER: 3.6
+//	NEEDRESTART-KSTA: 3
+//	NEEDRESTART-SVC: nginx.service
+//
+// A kernel status (KSTA) of 2 or 3 means a newer kernel is installed; it
+// is reported through /var/run/reboot-required as well.
+func parseNeedrestart(out string) []string {
+	var services []string
+	for _, line := range strings.Split(out, "\n") {
+		if svc, ok := strings.CutPrefix(line, "NEEDRESTART-SVC:"); ok {
+			services = appendService(services, strings.TrimSpace(svc))
+		}
+	}
+	return services
+}
+
+// parseCheckrestart parses the restart commands checkrestart suggests:
+//
+//	openssh-server:
+//		812	/usr/sbin/sshd
+//		systemctl restart ssh.service
+func parseCheckrestart(out string) []string {
+	var services []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "systemctl" && fields[1] == "restart":
+			services = appendService(services, fields[2])
+		case len(fields) == 3 && fields[0] == "service" && fields[2] == "restart":
+			services = appendService(services, fields[1])
+		case len(fields) == 2 && strings.HasPrefix(fields[0], "/etc/init.d/") && fields[1] == "restart":
+			services = appendService(services, strings.TrimPrefix(fields[0], "/etc/init.d/"))
+		}
+	}
+	return services
+}
+
+// parseNeedsRestarting parses the output of needs-restarting -s, one unit
+// per line.
+func parseNeedsRestarting(out string) []string {
+	var services []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			services = appendService(services, line)
+		}
+	}
+	return services
+}
+
+// appendService adds a service to the list unless it is there already.
+func appendService(services []string, svc string) []string {
+	if svc == "" || slices.Contains(services, svc) {
+		return services
+	}
+	return append(services, svc)
+}
@@ -0,0 +1,67 @@
+package facts
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestRestarts(t *testing.T) {
+	conn := connectortest.New()
+	conn.OnPrefix("if [ -f /var/run/reboot-required ]").Return("reboot\nlinux-image-6.8.0-45-generic\nlinux-base\nlinux-base\n")
+	conn.On("command -v needrestart").Return("/usr/sbin/needrestart\n")
+	conn.On("needrestart -b").Return("NEEDRESTART-VER: 3.6\nNEEDRESTART-KCUR: 6.8.0-40-generic\nNEEDRESTART-KSTA: 3\nNEEDRESTART-SVC: nginx.service\nNEEDRESTART-SVC: ssh.service\n")
+
+	status, err := Restarts(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RestartStatus{
+		RebootRequired: true,
+		RebootPackages: []string{"linux-image-6.8.0-45-generic", "linux-base"},
+		Services:       []string{"nginx.service", "ssh.service"},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("Restarts() = %+v, want %+v", status, want)
+	}
+
+	conn = connectortest.New()
+	conn.OnPrefix("if [ -f /var/run/reboot-required ]").Return("")
+	conn.OnPrefix("command -v ").Fail(1, "")
+	status, err = Restarts(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RebootRequired || status.Services != nil {
+		t.Errorf("Restarts() = %+v, want nothing to restart", status)
+	}
+	if f := status.Facts(); f["reboot_required"] != false || len(f["restart_services"].([]any)) != 0 {
+		t.Errorf("Facts() = %v", f)
+	}
+}
+
+func TestParseCheckrestart(t *testing.T) {
+	out := `Found 3 processes using old versions of upgraded files
+(3 distinct programs)
+(3 distinct packages)
+
+Of these, 3 seem to contain systemd service definitions or init scripts which can be used to restart them.
+The following packages seem to have definitions that could be used
+to restart their services:
+openssh-server:
+	812	/usr/sbin/sshd
+	systemctl restart ssh.service
+cron:
+	455	/usr/sbin/cron
+	service cron restart
+rsyslog:
+	301	/usr/sbin/rsyslogd
+	/etc/init.d/rsyslog restart
+`
+	want := []string{"ssh.service", "cron", "rsyslog"}
+	if got := parseCheckrestart(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCheckrestart() = %v, want %v", got, want)
+	}
+}
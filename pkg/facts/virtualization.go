@@ -0,0 +1,151 @@
+package facts
+
+import (
+	"context"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Virtualization roles of a target.
+const (
+	VirtContainer = "container" // The target is a container, jail, or zone
+	VirtVM        = "vm"        // The target is a virtual machine
+	VirtNone      = "none"      // The target runs on bare metal
+)
+
+// linuxVirtScript collects the hints parseLinuxVirt needs in one command.
+// Reading /proc/1/environ needs root; the other hints do not.
+const linuxVirtScript = `if command -v systemd-detect-virt >/dev/null 2>&1; then
+	echo "container=$(systemd-detect-virt -c 2>/dev/null)"
+	echo "vm=$(systemd-detect-virt -v 2>/dev/null)"
+fi
+grep -qi microsoft /proc/sys/kernel/osrelease 2>/dev/null && echo wsl
+[ -f /.dockerenv ] && echo dockerenv
+[ -f /run/.containerenv ] && echo containerenv
+echo "environ=$(tr '\0' '\n' </proc/1/environ 2>/dev/null | sed -n 's/^container=//p')"
+echo "cgroup=$(tr '\n' ' ' </proc/1/cgroup 2>/dev/null)"
+echo "dmi=$(cat /sys/class/dmi/id/sys_vendor /sys/class/dmi/id/product_name 2>/dev/null | tr '\n' ' ')"`
+
+// dmiVendors maps DMI vendor and product names to the hypervisor, named as
+// systemd-detect-virt names it.
+var dmiVendors = []struct{ match, virt string }{
+	{"KVM", "kvm"},
+	{"QEMU", "qemu"},
+	{"VMware", "vmware"},
+	{"VirtualBox", "oracle"},
+	{"Xen", "xen"},
+	{"Amazon EC2", "amazon"},
+	{"Google Compute Engine", "google"},
+	{"Parallels", "parallels"},
+	{"Virtual Machine", "microsoft"},
+	{"BHYVE", "bhyve"},
+}
+
+// gatherVirtualization detects whether the target is a container or
+// virtual machine, and which kind. It returns the virtualization_type fact
+// (e.g. "docker", "lxc", "kvm", "wsl", or "none") and virtualization_role
+// (container, vm, or none).
+func gatherVirtualization(ctx context.Context, conn connector.Connector, osType string) (map[string]any, error) {
+	virtType, role := VirtNone, VirtNone
+
+	switch osType {
+	case "Linux":
+		result, err := conn.Execute(ctx, linuxVirtScript)
+		if err != nil {
+			return nil, err
+		}
+		virtType, role = parseLinuxVirt(result.Stdout)
+
+	case "FreeBSD":
+		result, err := conn.Execute(ctx, "sysctl -n security.jail.jailed kern.vm_guest 2>/dev/null")
+		if err != nil {
+			return nil, err
+		}
+		virtType, role = parseFreeBSDVirt(result.Stdout)
+
+	case "Darwin":
+		result, err := conn.Execute(ctx, "sysctl -n kern.hv_vmm_present 2>/dev/null")
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(result.Stdout) == "1" {
+			virtType, role = "hypervisor", VirtVM
+		}
+	}
+
+	return map[string]any{
+		"virtualization_type": virtType,
+		"virtualization_role": role,
+	}, nil
+}
+
+// parseLinuxVirt parses the output of linuxVirtScript. Containers are
+// checked before virtual machines, since a container running in a VM
+// behaves like a container.
+func parseLinuxVirt(out string) (string, string) {
+	hints := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		hints[key] = strings.TrimSpace(value)
+	}
+	set := func(key string) bool {
+		_, ok := hints[key]
+		return ok
+	}
+	detected := func(key string) string {
+		if v := hints[key]; v != "none" {
+			return v
+		}
+		return ""
+	}
+
+	if set("wsl") {
+		return "wsl", VirtVM
+	}
+	if v := detected("container"); v != "" {
+		return v, VirtContainer
+	}
+	switch {
+	case set("dockerenv"):
+		return "docker", VirtContainer
+	case set("containerenv"):
+		return "podman", VirtContainer
+	case hints["environ"] != "":
+		return hints["environ"], VirtContainer
+	}
+	cgroup := hints["cgroup"]
+	for _, c := range []struct{ match, virt string }{
+		{"/docker", "docker"},
+		{"/lxc", "lxc"},
+		{"/libpod", "podman"},
+		{"/kubepods", "container-other"},
+	} {
+		if strings.Contains(cgroup, c.match) {
+			return c.virt, VirtContainer
+		}
+	}
+
+	if v := detected("vm"); v != "" {
+		return v, VirtVM
+	}
+	for _, d := range dmiVendors {
+		if strings.Contains(hints["dmi"], d.match) {
+			return d.virt, VirtVM
+		}
+	}
+	return VirtNone, VirtNone
+}
+
+// parseFreeBSDVirt parses the values of the security.jail.jailed and
+// kern.vm_guest sysctls.
+func parseFreeBSDVirt(out string) (string, string) {
+	fields := strings.Fields(out)
+	if len(fields) > 0 && fields[0] == "1" {
+		return "jail", VirtContainer
+	}
+	if len(fields) > 1 && fields[1] != "none" {
+		return fields[1], VirtVM
+	}
+	return VirtNone, VirtNone
+}
@@ -0,0 +1,49 @@
+package facts
+
+import "testing"
+
+func TestParseLinuxVirt(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      string
+		wantType string
+		wantRole string
+	}{
+		{"systemd container", "container=lxc\nvm=kvm\nenviron=lxc\ncgroup=0::/\ndmi=QEMU Standard PC\n", "lxc", VirtContainer},
+		{"systemd vm", "container=none\nvm=kvm\nenviron=\ncgroup=0::/init.scope \ndmi=QEMU KVM\n", "kvm", VirtVM},
+		{"bare metal", "container=none\nvm=none\nenviron=\ncgroup=0::/init.scope \ndmi=Dell Inc. PowerEdge R640 \n", VirtNone, VirtNone},
+		{"dockerenv", "dockerenv\nenviron=\ncgroup=0::/ \ndmi=\n", "docker", VirtContainer},
+		{"podman", "containerenv\nenviron=\ncgroup=0::/ \ndmi=\n", "podman", VirtContainer},
+		{"cgroup v1 docker", "environ=\ncgroup=12:pids:/docker/0123abcd 0::/docker/0123abcd \ndmi=\n", "docker", VirtContainer},
+		{"kubernetes", "environ=\ncgroup=0::/kubepods/burstable/pod1/abc \ndmi=\n", "container-other", VirtContainer},
+		{"wsl", "container=wsl\nvm=none\nwsl\nenviron=\ncgroup=0::/ \ndmi=\n", "wsl", VirtVM},
+		{"dmi only", "environ=\ncgroup=0::/init.scope \ndmi=innotek GmbH VirtualBox \n", "oracle", VirtVM},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotRole := parseLinuxVirt(tt.out)
+			if gotType != tt.wantType || gotRole != tt.wantRole {
+				t.Errorf("parseLinuxVirt() = %q, %q, want %q, %q", gotType, gotRole, tt.wantType, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestParseFreeBSDVirt(t *testing.T) {
+	tests := []struct {
+		out      string
+		wantType string
+		wantRole string
+	}{
+		{"1\nnone\n", "jail", VirtContainer},
+		{"0\nbhyve\n", "bhyve", VirtVM},
+		{"0\nnone\n", VirtNone, VirtNone},
+	}
+	for _, tt := range tests {
+		gotType, gotRole := parseFreeBSDVirt(tt.out)
+		if gotType != tt.wantType || gotRole != tt.wantRole {
+			t.Errorf("parseFreeBSDVirt(%q) = %q, %q, want %q, %q", tt.out, gotType, gotRole, tt.wantType, tt.wantRole)
+		}
+	}
+}
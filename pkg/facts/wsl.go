@@ -0,0 +1,120 @@
+package facts
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eugenetaranov/bolt/internal/connector"
+)
+
+// Service managers reported by ServiceManager.
+const (
+	ServiceSystemd  = "systemd"
+	ServiceOpenRC   = "openrc"
+	ServiceSysVinit = "sysvinit"
+	ServiceLaunchd  = "launchd"
+	ServiceRC       = "rc"
+	ServiceNone     = "none"
+)
+
+// ServiceManager returns the init system that manages services on the
+// target: systemd, openrc, sysvinit, launchd, rc (BSD), or none. A
+// systemctl binary alone does not count, since WSL1 and most containers
+// ship one without systemd running.
+func ServiceManager(ctx context.Context, conn connector.Connector) (string, error) {
+	result, err := conn.Execute(ctx, `if [ -d /run/systemd/system ]; then echo systemd
+elif [ -d /run/openrc ]; then echo openrc
+else
+	case "$(uname -s)" in
+	Darwin) echo launchd ;;
+	FreeBSD|OpenBSD|NetBSD) echo rc ;;
+	*) if [ "$(cat /proc/1/comm 2>/dev/null)" = init ] && [ -d /etc/init.d ]; then echo sysvinit; else echo none; fi ;;
+	esac
+fi`)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the service manager: %w", err)
+	}
+	if mgr := strings.TrimSpace(result.Stdout); mgr != "" {
+		return mgr, nil
+	}
+	return ServiceNone, nil
+}
+
+// gatherWSL collects facts of a WSL distribution: wsl_version (1 or 2),
+// wsl_distro, and windows_mounts, the mount points of the Windows drives
+// by lowercase drive letter.
+func gatherWSL(ctx context.Context, conn connector.Connector) (map[string]any, error) {
+	result, err := conn.Execute(ctx, `cat /proc/sys/kernel/osrelease; echo "distro=$WSL_DISTRO_NAME"; cat /proc/mounts`)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(result.Stdout, "\n")
+
+	info := map[string]any{
+		"wsl_version": wslVersion(lines[0]),
+	}
+	mounts := map[string]any{}
+	for _, line := range lines[1:] {
+		if distro, ok := strings.CutPrefix(line, "distro="); ok {
+			info["wsl_distro"] = distro
+			continue
+		}
+		if drive, dir, ok := parseDriveMount(line); ok {
+			mounts[drive] = dir
+		}
+	}
+	info["windows_mounts"] = mounts
+	return info, nil
+}
+
+// wslVersion returns the WSL version from the kernel release. WSL2 runs a
+// real kernel named like "5.15.153.1-microsoft-standard-WSL2"; WSL1
+// emulates one named like "4.4.0-19041-Microsoft".
+func wslVersion(osrelease string) int {
+	if strings.Contains(osrelease, "Microsoft") {
+		return 1
+	}
+	return 2
+}
+
+// parseDriveMount parses a /proc/mounts line of a Windows drive and
+// returns its lowercase drive letter and mount point. WSL1 mounts drives
+// as drvfs ("C: /mnt/c drvfs rw,..."), WSL2 as 9p
+// ("C:\134 /mnt/c 9p rw,...,aname=drvfs;path=C:\;...").
+func parseDriveMount(line string) (string, string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return "", "", false
+	}
+	source, dir, fstype, opts := fields[0], unescapeMount(fields[1]), fields[2], fields[3]
+	if fstype != "drvfs" && (fstype != "9p" || !strings.Contains(opts, "aname=drvfs")) {
+		return "", "", false
+	}
+	source = unescapeMount(source)
+	if len(source) < 2 || source[1] != ':' {
+		return "", "", false
+	}
+	return strings.ToLower(source[:1]), dir, true
+}
+
+// unescapeMount decodes the octal escapes of /proc/mounts, e.g. "\040"
+// for a space.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package facts
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eugenetaranov/bolt/internal/connector/connectortest"
+)
+
+func TestGatherWSL(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want map[string]any
+	}{
+		{
+			name: "wsl2",
+			out: `5.15.153.1-microsoft-standard-WSL2
+distro=Ubuntu-24.04
+none /mnt/wsl tmpfs rw,relatime 0 0
+C:\134 /mnt/c 9p rw,noatime,dirsync,aname=drvfs;path=C:\;uid=1000;gid=1000;symlinkroot=/mnt/ 0 0
+D:\134 /mnt/d 9p rw,noatime,dirsync,aname=drvfs;path=D:\;uid=1000;gid=1000;symlinkroot=/mnt/ 0 0
+drivers /usr/lib/wsl/drivers 9p ro,nosuid,nodev,noatime,aname=drivers;fmask=222;dmask=222 0 0
+`,
+			want: map[string]any{
+				"wsl_version":    2,
+				"wsl_distro":     "Ubuntu-24.04",
+				"windows_mounts": map[string]any{"c": "/mnt/c", "d": "/mnt/d"},
+			},
+		},
+		{
+			name: "wsl1",
+			out: `4.4.0-19041-Microsoft
+distro=Debian
+rootfs / lxfs rw,noatime 0 0
+C: /mnt/c drvfs rw,noatime,uid=1000,gid=1000 0 0
+E: /mnt/usb\040stick drvfs rw,noatime 0 0
+`,
+			want: map[string]any{
+				"wsl_version":    1,
+				"wsl_distro":     "Debian",
+				"windows_mounts": map[string]any{"c": "/mnt/c", "e": "/mnt/usb stick"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connectortest.New()
+			conn.OnPrefix("cat /proc/sys/kernel/osrelease").Return(tt.out)
+
+			got, err := gatherWSL(context.Background(), conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gatherWSL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/eugenetaranov/bolt/internal/scenario"
 )
 
 // execInContainer runs a command in the container and returns stdout
@@ -100,3 +102,17 @@ func assertCommandOutput(t *testing.T, ctx context.Context, container testcontai
 		assert.Contains(t, output, expected, "command output should contain %q", expected)
 	}
 }
+
+// assertScenarioPassed fails the test if a scenario run failed, naming the
+// stage. A failed idempotence stage means the second run of the playbook
+// reported changes, i.e. a module is not idempotent on this target.
+func assertScenarioPassed(t *testing.T, result *scenario.Result) {
+	t.Helper()
+	switch {
+	case result.Passed:
+	case result.Stage == scenario.StageIdempotence:
+		t.Errorf("not idempotent: %v", result.Err)
+	default:
+		t.Errorf("%s failed: %v", result.Stage, result.Err)
+	}
+}
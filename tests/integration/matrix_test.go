@@ -0,0 +1,126 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/eugenetaranov/bolt/internal/scenario"
+)
+
+// matrixDistro is an image the module fixtures run on.
+type matrixDistro struct {
+	Name  string
+	Image string
+}
+
+// matrixDistros are the distros of the matrix. BOLT_MATRIX_DISTROS limits
+// a run to some of them (comma-separated names), as does
+// -run 'Matrix/<distro>'.
+var matrixDistros = []matrixDistro{
+	{Name: "ubuntu", Image: "ubuntu:22.04"},
+	{Name: "debian", Image: "debian:12"},
+	{Name: "alpine", Image: "alpine:3.20"},
+	{Name: "rocky", Image: "rockylinux:9"},
+	{Name: "arch", Image: "archlinux:latest"},
+}
+
+// matrixFixture is a module fixture: a scenario without an image, which
+// the matrix fills in for every distro. Fixtures live in testdata/matrix as
+// <module>.matrix.yaml, next to the playbooks they converge. They do not
+// use the .test.yaml suffix, so bolt test does not pick them up.
+type matrixFixture struct {
+	scenario.Scenario `yaml:",inline"`
+
+	// Distros limits the fixture to the distros with these names, e.g.
+	// for modules of one package manager (default: all).
+	Distros []string `yaml:"distros"`
+}
+
+// TestMatrix converges every module fixture on every distro in a fresh
+// container, checks that a second run changes nothing, and runs the
+// fixture's verify tasks. Run it with:
+//
+//	go test -v -run Matrix ./tests/integration/
+//	go test -v -run 'Matrix/alpine/file' ./tests/integration/
+func TestMatrix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping matrix test in short mode")
+	}
+
+	fixtures := loadMatrixFixtures(t)
+	for _, distro := range selectedDistros() {
+		t.Run(distro.Name, func(t *testing.T) {
+			t.Parallel()
+			for _, fixture := range fixtures {
+				if len(fixture.Distros) > 0 && !slices.Contains(fixture.Distros, distro.Name) {
+					continue
+				}
+				t.Run(fixture.Name, func(t *testing.T) {
+					runMatrixFixture(t, fixture, distro)
+				})
+			}
+		})
+	}
+}
+
+// selectedDistros returns the distros chosen by BOLT_MATRIX_DISTROS, or
+// all of them.
+func selectedDistros() []matrixDistro {
+	names := os.Getenv("BOLT_MATRIX_DISTROS")
+	if names == "" {
+		return matrixDistros
+	}
+	var selected []matrixDistro
+	for _, d := range matrixDistros {
+		if slices.Contains(strings.Split(names, ","), d.Name) {
+			selected = append(selected, d)
+		}
+	}
+	return selected
+}
+
+// loadMatrixFixtures reads the fixtures in testdata/matrix, named after
+// their file.
+func loadMatrixFixtures(t *testing.T) []matrixFixture {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(projectRoot, "tests", "integration", "testdata", "matrix", "*.matrix.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths, "no matrix fixtures found")
+
+	var fixtures []matrixFixture
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		var fixture matrixFixture
+		require.NoError(t, yaml.Unmarshal(data, &fixture), "failed to parse %s", path)
+		fixture.Path = path
+		fixture.Name = strings.TrimSuffix(filepath.Base(path), ".matrix.yaml")
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures
+}
+
+// runMatrixFixture runs fixture as a scenario on distro.
+func runMatrixFixture(t *testing.T, fixture matrixFixture, distro matrixDistro) {
+	sc := fixture.Scenario
+	sc.Name = fixture.Name + "-" + distro.Name
+	sc.Image = distro.Image
+	sc.Idempotence = true
+	require.NoError(t, sc.Validate(), fixture.Path)
+
+	var out strings.Builder
+	runner := scenario.NewRunner(&out)
+	result := runner.Run(context.Background(), &sc)
+	if !result.Passed {
+		t.Logf("Output:\n%s", out.String())
+	}
+	assertScenarioPassed(t, result)
+}